@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/phhowardchen/case-tracker/internal/config"
+	"github.com/phhowardchen/case-tracker/internal/source"
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// errAccountLoggingIn is why the cases of an additional account fail before its first
+// login attempt is done
+var errAccountLoggingIn = errors.New("its login is in progress")
+
+// pendingAccount serves the cases of an additional USCIS account until its login
+// succeeds: they fail with the reason, while the other accounts' cases are polled
+type pendingAccount struct {
+	name string
+	mu   sync.Mutex
+	err  error
+}
+
+// set records why the account isn't logged in
+func (p *pendingAccount) set(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.err = err
+}
+
+// FetchCaseStatus fails: the account isn't logged in
+// The login error is not wrapped, so a case of the account doesn't raise another auth alert
+func (p *pendingAccount) FetchCaseStatus(caseID string) (map[string]interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return nil, fmt.Errorf("USCIS account %q is not logged in: %v", p.name, p.err)
+}
+
+// accountLogins are the logins of the additional USCIS accounts (USCIS_ACCOUNT_<N>_*)
+type accountLogins struct {
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	clients []*uscis.BrowserClient
+}
+
+// loginAccounts logs in to the additional USCIS accounts, each on its own, and registers
+// an account's client as the source of its cases once its login succeeds. Until then its
+// cases fail with the reason (pendingAccount); the other accounts aren't held up
+// A failed login is retried with the account's own backoff. One rejected for bad
+// credentials isn't, and the account's cases keep failing until they're fixed
+// An account without a TOTP secret of its own gets its 2FA code like the main account
+// (codes), so that mailbox or number must receive the account's codes too
+// With RUN_ONCE each account gets one attempt; wait returns once they're done
+func (a *app) loginAccounts(ctx context.Context, codes uscis.CodeProvider, sessions uscis.SessionStore, sources *source.Registry, configure func(*uscis.BrowserClient)) *accountLogins {
+	ctx, cancel := context.WithCancel(ctx)
+	logins := &accountLogins{cancel: cancel}
+	for _, account := range a.cfg.Accounts {
+		pending := &pendingAccount{name: account.Name, err: errAccountLoggingIn}
+		sources.Register(account.Source(), pending)
+		logins.wg.Add(1)
+		go func() {
+			defer logins.wg.Done()
+			client, err := a.loginAccount(ctx, account, codes, sessions)
+			if err != nil {
+				pending.set(err)
+				if ctx.Err() == nil {
+					a.accountLoginFailed(account, err)
+				}
+				return
+			}
+			configure(client)
+			logins.add(client)
+			sources.Register(account.Source(), client)
+			log.Printf("Logged in to USCIS account %q", account.Name)
+		}()
+	}
+	return logins
+}
+
+// loginAccount logs in to an additional USCIS account
+func (a *app) loginAccount(ctx context.Context, account config.Account, codes uscis.CodeProvider, sessions uscis.SessionStore) (*uscis.BrowserClient, error) {
+	if account.TOTPSecret != "" {
+		totp, err := uscis.NewTOTP(account.TOTPSecret)
+		if err != nil {
+			return nil, err
+		}
+		codes = totp
+	}
+	log.Printf("Logging in to USCIS account %q (%d case(s))...", account.Name, len(account.CaseIDs))
+	login := func() (*uscis.BrowserClient, error) {
+		return uscis.NewBrowserClientWithCodes(ctx, account.Username, account.Password, codes, sessions)
+	}
+	if a.cfg.RunOnce {
+		return login()
+	}
+	backoff := newLoginBackoff(a.cfg.StateFileDir, account.Name, account.Username, account.Password)
+	return a.loginWithBackoff(ctx, backoff, login)
+}
+
+// accountLoginFailed reports an additional account that won't be logged in
+// The daemon keeps polling the other accounts instead of exiting
+func (a *app) accountLoginFailed(account config.Account, err error) {
+	log.Printf("CRITICAL: Failed to log in to USCIS account %q: %v", account.Name, err)
+	log.Printf("Its %d case(s) fail until the account is logged in. Fix its credentials and redeploy to retry.", len(account.CaseIDs))
+	if !errors.Is(err, errRejectedBefore) {
+		a.sendAuthFailureEmail(err, fmt.Sprintf("browser initialization of USCIS account %q", account.Name))
+	}
+}
+
+// add keeps a logged-in client for close
+func (l *accountLogins) add(client *uscis.BrowserClient) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.clients = append(l.clients, client)
+}
+
+// count returns the number of accounts logged in
+func (l *accountLogins) count() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.clients)
+}
+
+// wait blocks until every account's login has succeeded or given up
+func (l *accountLogins) wait() {
+	l.wg.Wait()
+}
+
+// close stops the logins still running and closes the accounts' browser clients
+func (l *accountLogins) close() {
+	l.cancel()
+	l.wg.Wait()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, client := range l.clients {
+		client.Close()
+	}
+}
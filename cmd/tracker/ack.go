@@ -0,0 +1,194 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/locale"
+	"github.com/phhowardchen/case-tracker/internal/notifier"
+	"github.com/phhowardchen/case-tracker/internal/storage"
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// needsAck reports whether a change is critical enough to ask the recipient to confirm they saw it
+func (a *app) needsAck(r *caseResult) bool {
+	return len(a.cfg.AckEscalation) > 0 && a.linksEnabled() && r.isCritical()
+}
+
+// ackLinkHTML renders the acknowledgement link of a critical change notification
+// The acknowledgement is only tracked once the notification is committed (see trackAck)
+func (a *app) ackLinkHTML(r *caseResult) string {
+	if !a.needsAck(r) {
+		return ""
+	}
+	if r.ackID == "" {
+		buf := make([]byte, 8)
+		if _, err := rand.Read(buf); err != nil {
+			log.Printf("[%s] Warning: Failed to create acknowledgement ID: %v", r.caseID, err)
+			return ""
+		}
+		r.ackID = hex.EncodeToString(buf)
+	}
+	return a.ackButtonHTML(r.caseID, r.ackID)
+}
+
+// ackButtonHTML renders the "I've seen this" link with what happens without a click
+func (a *app) ackButtonHTML(caseID, ackID string) string {
+	link := a.signedLink("ack", caseID, url.Values{"id": {ackID}})
+	return fmt.Sprintf(`<p style="margin: 16px 0;"><a href="%s" style="background: #0b5394; color: #fff; padding: 8px 16px; border-radius: 4px; text-decoration: none;">I've seen this update</a></p>
+<p style="color: #777;"><small>This update may need action. If it isn't confirmed within %v, it is sent again via %s.</small></p>`,
+		template.HTMLEscapeString(link), shortDuration(a.cfg.AckWindow), strings.Join(a.cfg.AckEscalation, ", then "))
+}
+
+// shortDuration drops the zero minutes and seconds Duration.String adds ("12h0m0s" -> "12h")
+func shortDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// trackAck starts waiting for the acknowledgement of a critical notification
+// A notification committed to the outbox counts as sent, since the outbox retries it
+func (a *app) trackAck(r *caseResult, notifyErr error) {
+	if r.ackID == "" || (notifyErr != nil && !r.saved) {
+		return
+	}
+	now := time.Now()
+	ack := &storage.PendingAck{
+		ID:     r.ackID,
+		CaseID: r.caseID,
+		Status: uscis.StatusSummary(r.status),
+		SentAt: now,
+		NextAt: now.Add(a.cfg.AckWindow),
+	}
+	if err := a.acks.Add(ack); err != nil {
+		log.Printf("[%s] Warning: Failed to record pending acknowledgement: %v", r.caseID, err)
+		return
+	}
+	log.Printf("[%s] Critical update (%s) - waiting up to %v for an acknowledgement", r.caseID, ack.Status, a.cfg.AckWindow)
+}
+
+// escalateAcks re-sends unacknowledged critical notifications through the next channel of
+// ACK_ESCALATION, one channel per window
+// After the last channel has had its window the acknowledgement is given up on
+// Snoozing a case counts as having seen it
+func (a *app) escalateAcks() {
+	if len(a.cfg.AckEscalation) == 0 {
+		return
+	}
+	due, err := a.acks.Due(time.Now())
+	if err != nil {
+		log.Printf("Warning: Failed to load pending acknowledgements: %v", err)
+		return
+	}
+
+	for _, ack := range due {
+		_, snoozed := a.snoozedUntil(ack.CaseID)
+		if snoozed || ack.Escalations >= len(a.cfg.AckEscalation) {
+			if snoozed {
+				log.Printf("[%s] Case was snoozed - no longer waiting for an acknowledgement", ack.CaseID)
+			} else {
+				log.Printf("[%s] Critical update (%s) was never acknowledged; every escalation channel was tried", ack.CaseID, ack.Status)
+			}
+			if _, err := a.acks.Remove(ack.ID); err != nil {
+				log.Printf("[%s] Warning: Failed to drop pending acknowledgement: %v", ack.CaseID, err)
+			}
+			continue
+		}
+
+		channel := a.cfg.AckEscalation[ack.Escalations]
+		log.Printf("[%s] Critical update not acknowledged after %v - escalating via %s", ack.CaseID, time.Since(ack.SentAt).Round(time.Minute), channel)
+		msgs := a.localized([]string{ack.CaseID}, func(loc locale.Settings) notifier.Message {
+			msg := a.message([]string{ack.CaseID}, fmt.Sprintf("Action may be needed: %s - %s", ack.CaseID, ack.Status), a.formatEscalation(ack, loc))
+			msg.Channels = []string{channel}
+			return msg
+		})
+		var errs []error
+		for _, msg := range msgs {
+			errs = append(errs, a.notifier.SendChange(msg))
+		}
+		if err := errors.Join(errs...); err != nil {
+			// Move on anyway, so one broken channel doesn't stall the chain
+			log.Printf("[%s] Failed to escalate via %s: %v", ack.CaseID, channel, err)
+		}
+
+		ack.Escalations++
+		ack.NextAt = time.Now().Add(a.cfg.AckWindow)
+		if err := a.acks.Update(ack); err != nil {
+			log.Printf("[%s] Warning: Failed to save pending acknowledgement: %v", ack.CaseID, err)
+		}
+	}
+}
+
+// formatEscalation renders the reminder sent for an unacknowledged critical notification
+func (a *app) formatEscalation(ack *storage.PendingAck, loc locale.Settings) string {
+	return fmt.Sprintf(`
+		<h2>Please confirm you saw this case update</h2>
+		<p><strong>Case ID:</strong> %s</p>
+		<p><strong>New status:</strong> %s</p>
+		<p>The notification about it was sent %s and hasn't been confirmed yet. This status usually comes with a deadline or an appointment, so please check your USCIS account and mail.</p>
+		%s
+	`, ack.CaseID, template.HTMLEscapeString(loc.Status(ack.Status)), loc.FormatDateTime(ack.SentAt), a.ackButtonHTML(ack.CaseID, ack.ID))
+}
+
+var ackConfirmTemplate = template.Must(template.New("ack").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>Confirm case update</title></head>
+<body style="font-family: -apple-system, Helvetica, Arial, sans-serif; max-width: 640px; margin: 2em auto; padding: 0 1em;">
+{{if .Done}}
+<h2>Thanks</h2>
+<p>The update for {{.CaseID}} is confirmed{{if .Status}} ({{.Status}}){{end}}. No further reminders will be sent.</p>
+{{else if .Missing}}
+<h2>Nothing to confirm</h2>
+<p>This update for {{.CaseID}} was already confirmed, or reminders for it have ended.</p>
+{{else}}
+<h2>Confirm you saw this update?</h2>
+<p>Confirming {{.CaseID}} stops the reminders on other channels.</p>
+<form method="post"><button type="submit">I've seen it</button></form>
+{{end}}
+</body>
+</html>
+`))
+
+// handleAckLink serves /link/ack from critical notifications
+// GET only shows a confirmation page so link scanners can't acknowledge by prefetching it
+func (a *app) handleAckLink(w http.ResponseWriter, r *http.Request) {
+	caseID, err := a.verifyLink("ack", r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	view := map[string]interface{}{"CaseID": caseID}
+	if r.Method == http.MethodPost {
+		ack, err := a.acks.Remove(r.Form.Get("id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if ack == nil {
+			view["Missing"] = true
+		} else {
+			log.Printf("[%s] Critical update acknowledged after %v", caseID, time.Since(ack.SentAt).Round(time.Minute))
+			view["Done"] = true
+			view["Status"] = ack.Status
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	ackConfirmTemplate.Execute(w, view)
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/phhowardchen/case-tracker/internal/logging"
+	"github.com/phhowardchen/case-tracker/internal/source"
+)
+
+// sessionRefresher is implemented by fetchers that can log in to USCIS again on demand
+type sessionRefresher interface {
+	RefreshSession() error
+}
+
+// runtimeToggles are operational switches flipped through the admin API without a restart
+// They are not persisted: every start begins with notifications on and polling running
+type runtimeToggles struct {
+	mu        sync.Mutex
+	dryRun    bool
+	paused    bool
+	logFilter *logging.LevelFilter // nil when logs aren't filtered (one-shot commands)
+	sources   *source.Registry     // set once the fetchers are logged in
+}
+
+// toggleState is the JSON view of the runtime toggles
+type toggleState struct {
+	DryRun   bool   `json:"dry_run"`
+	Paused   bool   `json:"paused"`
+	LogLevel string `json:"log_level"`
+}
+
+// isDryRun reports whether notifications are logged instead of sent
+func (t *runtimeToggles) isDryRun() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.dryRun
+}
+
+// isPaused reports whether poll cycles are skipped
+func (t *runtimeToggles) isPaused() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.paused
+}
+
+// setSources makes the logged-in fetchers available for session refreshes
+func (t *runtimeToggles) setSources(sources *source.Registry) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sources = sources
+}
+
+// state returns the current toggles
+func (t *runtimeToggles) state() toggleState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state := toggleState{DryRun: t.dryRun, Paused: t.paused, LogLevel: logging.Levels[0]}
+	if t.logFilter != nil {
+		state.LogLevel = t.logFilter.Level()
+	}
+	return state
+}
+
+// refreshSessions logs in again with every fetcher that supports it
+func (t *runtimeToggles) refreshSessions() ([]string, error) {
+	t.mu.Lock()
+	sources := t.sources
+	t.mu.Unlock()
+	if sources == nil {
+		return nil, errors.New("the tracker is still starting up")
+	}
+
+	var refreshed []string
+	var errs []error
+	for _, name := range sources.Names() {
+		fetcher, _ := sources.Get(name)
+		refresher, ok := fetcher.(sessionRefresher)
+		if !ok {
+			continue
+		}
+		if err := refresher.RefreshSession(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		refreshed = append(refreshed, name)
+	}
+	if len(refreshed) == 0 && len(errs) == 0 {
+		return nil, errors.New("no source has a session to refresh")
+	}
+	return refreshed, errors.Join(errs...)
+}
+
+// handleTogglesAPI serves /api/admin/toggles
+//
+//	GET                                          current toggles
+//	POST ?dry_run=true&paused=false&log_level=warning   change the given toggles
+func (a *app) handleTogglesAPI(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, a.toggles.state())
+
+	case http.MethodPost:
+		if !a.authorizeAPIWrite(w, r) {
+			return
+		}
+		if err := a.setToggles(r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusOK, a.toggles.state())
+
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// setToggles applies the toggles given in a request
+// Every value is validated before any toggle changes
+func (a *app) setToggles(r *http.Request) error {
+	parseBool := func(name string) (*bool, error) {
+		value := r.FormValue(name)
+		if value == "" {
+			return nil, nil
+		}
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q", name, value)
+		}
+		return &b, nil
+	}
+	dryRun, err := parseBool("dry_run")
+	if err != nil {
+		return err
+	}
+	paused, err := parseBool("paused")
+	if err != nil {
+		return err
+	}
+	level := r.FormValue("log_level")
+	if level != "" {
+		if a.toggles.logFilter == nil {
+			return errors.New("log level can't be changed in this mode")
+		}
+		if !slices.Contains(logging.Levels, level) {
+			return fmt.Errorf("invalid log_level %q (want one of %v)", level, logging.Levels)
+		}
+	}
+
+	t := a.toggles
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if level != "" {
+		// Logged before the change so raising the level doesn't hide it
+		log.Printf("Admin: log level changed from %s to %s (via api)", t.logFilter.Level(), level)
+		if err := t.logFilter.SetLevel(level); err != nil {
+			return err
+		}
+	}
+	if dryRun != nil && *dryRun != t.dryRun {
+		t.dryRun = *dryRun
+		if t.dryRun {
+			log.Printf("Admin: dry run enabled, notifications are logged instead of sent (via api)")
+		} else {
+			log.Printf("Admin: dry run disabled (via api)")
+		}
+	}
+	if paused != nil && *paused != t.paused {
+		t.paused = *paused
+		if t.paused {
+			log.Printf("Admin: polling paused (via api)")
+		} else {
+			log.Printf("Admin: polling resumed (via api)")
+		}
+	}
+	return nil
+}
+
+// handleResetWatchdogAPI serves POST /api/admin/reset-watchdog
+// It clears the consecutive timeout count that trips a browser recycle
+func (a *app) handleResetWatchdogAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !a.authorizeAPIWrite(w, r) {
+		return
+	}
+	count := a.watchdog.reset()
+	log.Printf("Admin: fetch watchdog reset at %d consecutive timeout(s) (via api)", count)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"consecutive_timeouts": count})
+}
+
+// handleRefreshSessionAPI serves POST /api/admin/refresh-session
+// It logs in to USCIS again instead of waiting for the session to be rejected
+func (a *app) handleRefreshSessionAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !a.authorizeAPIWrite(w, r) {
+		return
+	}
+	log.Printf("Admin: refreshing sessions (via api)")
+	refreshed, err := a.toggles.refreshSessions()
+	if err != nil {
+		log.Printf("Admin: session refresh failed: %v", err)
+		writeJSON(w, http.StatusBadGateway, map[string]interface{}{"refreshed": refreshed, "error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"refreshed": refreshed})
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/archive"
+	"github.com/phhowardchen/case-tracker/internal/config"
+	"github.com/phhowardchen/case-tracker/internal/health"
+	"github.com/phhowardchen/case-tracker/internal/storage"
+)
+
+// archiveInterval is how often the daemon moves old history to the archive
+const archiveInterval = 24 * time.Hour
+
+// archiveNameTime formats the time range in archive object names
+const archiveNameTime = "20060102T150405Z"
+
+// archiveHistory moves every case's history older than ARCHIVE_AFTER to the archive
+// A case's records are only deleted once their archive object was written
+func (a *app) archiveHistory(store archive.Store) {
+	cutoff := time.Now().Add(-a.cfg.ArchiveAfter)
+	for _, caseID := range a.cfg.CaseIDs {
+		if err := a.archiveCase(store, caseID, cutoff); err != nil {
+			log.Printf("[%s] Warning: Failed to archive history: %v", caseID, err)
+		}
+	}
+}
+
+// archiveCase moves one case's records from before cutoff into a single archive object
+func (a *app) archiveCase(store archive.Store, caseID string, cutoff time.Time) error {
+	archiver, ok := a.caseStorage(caseID).(storage.Archiver)
+	if !ok {
+		return fmt.Errorf("storage backend doesn't support archival")
+	}
+	records, err := archiver.ArchiveRecords(cutoff)
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	data, err := storage.EncodeArchive(records)
+	if err != nil {
+		return err
+	}
+	name := archiveObjectName(caseID, records)
+	if err := store.Put(name, data); err != nil {
+		return err
+	}
+	if err := archiver.DeleteRecords(records); err != nil {
+		return fmt.Errorf("archived to %s but failed to delete from hot storage: %w", name, err)
+	}
+	log.Printf("[%s] Archived %d record(s) older than %s to %s (%d bytes)", caseID, len(records), cutoff.Format("2006-01-02"), name, len(data))
+	return nil
+}
+
+// archiveObjectName names the archive object of a case's records by their time range
+// The same records always get the same name, so a retried upload replaces the first one
+func archiveObjectName(caseID string, records []storage.ArchiveRecord) string {
+	first, last := records[0].Time, records[0].Time
+	for _, record := range records {
+		if record.Time.Before(first) {
+			first = record.Time
+		}
+		if record.Time.After(last) {
+			last = record.Time
+		}
+	}
+	return fmt.Sprintf("%s/%s_%s_%s.jsonl.gz", caseID, caseID, first.UTC().Format(archiveNameTime), last.UTC().Format(archiveNameTime))
+}
+
+// runArchive implements `tracker archive run | list | restore`
+func runArchive(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: tracker archive run | list [-case ID] | restore [-case ID]")
+		return 2
+	}
+
+	fs := flag.NewFlagSet("archive "+args[0], flag.ExitOnError)
+	caseID := fs.String("case", "", "only this case")
+	fs.Parse(args[1:])
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return 1
+	}
+	store, err := archive.Open(cfg.ArchiveLocation)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	caseIDs := cfg.CaseIDs
+	if *caseID != "" {
+		caseIDs = []string{strings.ToUpper(*caseID)}
+	}
+
+	switch args[0] {
+	case "run":
+		if cfg.ArchiveAfter == 0 {
+			fmt.Fprintln(os.Stderr, "ARCHIVE_AFTER is not set")
+			return 1
+		}
+		a, err := newApp(cfg, nil, health.NewTracker("archive", cfg.CaseIDs))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to initialize: %v\n", err)
+			return 1
+		}
+		a.archiveHistory(store)
+		return 0
+
+	case "list":
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "CASE\tOBJECT")
+		for _, id := range caseIDs {
+			names, err := store.List(id + "/")
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+			for _, name := range names {
+				fmt.Fprintf(tw, "%s\t%s\n", id, name)
+			}
+		}
+		tw.Flush()
+		return 0
+
+	case "restore":
+		a, err := newApp(cfg, nil, health.NewTracker("archive", cfg.CaseIDs))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to initialize: %v\n", err)
+			return 1
+		}
+		for _, id := range caseIDs {
+			if err := a.restoreCase(store, id); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", id, err)
+				return 1
+			}
+		}
+		if cfg.ArchiveAfter > 0 {
+			fmt.Println("Note: ARCHIVE_AFTER is set, so restored history is archived again on the next run")
+		}
+		return 0
+
+	default:
+		fmt.Fprintf(os.Stderr, "unknown archive command %q\n", args[0])
+		return 2
+	}
+}
+
+// restoreCase puts every archived record of a case back into its storage
+// Archive objects are kept; records already in storage are skipped
+func (a *app) restoreCase(store archive.Store, caseID string) error {
+	archiver, ok := a.caseStorage(caseID).(storage.Archiver)
+	if !ok {
+		return fmt.Errorf("storage backend doesn't support archival")
+	}
+	names, err := store.List(caseID + "/")
+	if err != nil {
+		return err
+	}
+
+	total := 0
+	for _, name := range names {
+		data, err := store.Get(name)
+		if err != nil {
+			return err
+		}
+		records, err := storage.DecodeArchive(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		restored, err := archiver.RestoreRecords(records)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		total += restored
+	}
+	fmt.Printf("%s: restored %d record(s) from %d archive object(s)\n", caseID, total, len(names))
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/locale"
+	"github.com/phhowardchen/case-tracker/internal/notifier"
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// staggerBootstrap spaces out the first fetch of cases that have no saved state yet
+// so a fresh deployment with many cases doesn't hit USCIS with a burst of requests
+// It fails when the tracker shuts down during the wait
+func (a *app) staggerBootstrap(caseID string) error {
+	if a.cfg.BootstrapStagger <= 0 {
+		return nil
+	}
+
+	// Reserve the next slot, then wait outside the lock so other workers can queue behind it
+	a.bootstrapMu.Lock()
+	slot := a.lastBootstrapFetch.Add(a.cfg.BootstrapStagger)
+	if now := time.Now(); slot.Before(now) {
+		slot = now
+	}
+	a.lastBootstrapFetch = slot
+	a.bootstrapMu.Unlock()
+
+	if wait := time.Until(slot); wait > 0 {
+		log.Printf("[%s] No saved state - waiting %v before first fetch", caseID, wait.Round(time.Second))
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-a.ctx.Done():
+			return a.ctx.Err()
+		}
+	}
+	return nil
+}
+
+// splitBootstrap separates first-run results when there are enough of them to be summarized
+// in one email instead of one initial-status email per case
+func (a *app) splitBootstrap(results []*caseResult) (bootstrap, rest []*caseResult) {
+	for _, r := range results {
+		if r.isFirstRun() {
+			if _, snoozed := a.snoozedUntil(r.caseID); !snoozed {
+				bootstrap = append(bootstrap, r)
+				continue
+			}
+		}
+		rest = append(rest, r)
+	}
+
+	if len(bootstrap) < a.cfg.BootstrapSummaryMin {
+		return nil, results
+	}
+	return bootstrap, rest
+}
+
+// notifyBootstrap sends one summary email covering the initial status of many cases
+func (a *app) notifyBootstrap(results []*caseResult) error {
+	log.Printf("First run for %d cases - sending one summary email", len(results))
+
+	caseIDs := make([]string, 0, len(results))
+	for _, r := range results {
+		caseIDs = append(caseIDs, r.caseID)
+	}
+
+	subject := fmt.Sprintf("%s - Now Tracking %d Cases", a.cfg.BrandName, len(results))
+	msgs := a.localized(caseIDs, func(loc locale.Settings) notifier.Message {
+		return a.message(caseIDs, subject, formatBootstrapSummaryEmail(results, loc))
+	})
+	if err := a.deliver(results, outboxInitial, msgs...); err != nil {
+		return fmt.Errorf("failed to send initial summary: %w", err)
+	}
+	log.Printf("Initial summary email sent successfully")
+	return nil
+}
+
+// formatBootstrapSummaryEmail renders the current status of every newly tracked case as one table
+func formatBootstrapSummaryEmail(results []*caseResult, loc locale.Settings) string {
+	rows := ""
+	for _, r := range results {
+		form := uscis.FormType(r.status)
+		if form == "" {
+			form = "-"
+		}
+		summary := "unknown"
+		if s := uscis.StatusSummary(r.status); s != "" {
+			summary = loc.Status(s)
+		}
+		rows += fmt.Sprintf("<tr><td style='padding: 4px 12px;'>%s</td><td style='padding: 4px 12px;'>%s</td><td style='padding: 4px 12px;'>%s</td></tr>", r.caseID, form, summary)
+	}
+
+	html := fmt.Sprintf(`
+		<h2>Initial Case Status</h2>
+		<p><strong>Checked:</strong> %s</p>
+		<p>The tracker is now monitoring %d cases. This is their current status; future emails will only be sent when a case changes.</p>
+		<table style="border-collapse: collapse;">
+			<tr><th style="text-align: left; padding: 4px 12px;">Receipt</th><th style="text-align: left; padding: 4px 12px;">Form</th><th style="text-align: left; padding: 4px 12px;">Current Status</th></tr>
+			%s
+		</table>
+	`, loc.FormatDateTime(time.Now()), len(results), rows)
+
+	return html
+}
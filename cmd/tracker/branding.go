@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+
+	"github.com/phhowardchen/case-tracker/internal/config"
+	"github.com/phhowardchen/case-tracker/internal/notifier"
+)
+
+// newEmailClient creates the email client of the configured provider (NOTIFIER) with
+// the sender branding
+func newEmailClient(cfg *config.Config) notifier.Notifier {
+	if cfg.Notifier == config.NotifierSMTP {
+		client := notifier.NewSMTPClient(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPSecurity, cfg.SMTPUsername, cfg.SMTPPassword)
+		client.SetFrom(cfg.EmailFrom)
+		client.SetRecipients(cfg.RecipientEmails)
+		client.SetCopies(cfg.EmailCC, cfg.EmailBCC)
+		if cfg.EmailReplyTo != "" {
+			client.SetReplyTo(cfg.EmailReplyTo)
+		}
+		if cfg.ManageURL != "" {
+			client.SetUnsubscribeURL(cfg.ManageURL)
+		}
+		return client
+	}
+
+	client := notifier.NewResendClient(cfg.ResendAPIKey)
+	client.SetFrom(cfg.EmailFrom)
+	client.SetRecipients(cfg.RecipientEmails)
+	client.SetCopies(cfg.EmailCC, cfg.EmailBCC)
+	client.SetDailyQuota(cfg.ResendDailyQuota)
+	if cfg.EmailReplyTo != "" {
+		client.SetReplyTo(cfg.EmailReplyTo)
+	}
+	if cfg.ManageURL != "" {
+		client.SetUnsubscribeURL(cfg.ManageURL)
+	}
+	return client
+}
+
+// emailFooterHTML renders the footer appended to every outgoing email
+func (a *app) emailFooterHTML() string {
+	footer := template.HTMLEscapeString(a.cfg.EmailFooter)
+	if a.cfg.ManageURL != "" {
+		footer += fmt.Sprintf(` · <a href="%s">Manage notifications</a>`, template.HTMLEscapeString(a.cfg.ManageURL))
+	}
+
+	return fmt.Sprintf(`
+		<hr style="border: none; border-top: 1px solid #eee; margin-top: 24px;">
+		<p style="color: #999;"><small>%s</small></p>
+		<p style="color: #bbb;"><small>Instance %s</small></p>
+	`, footer, template.HTMLEscapeString(a.instanceID))
+}
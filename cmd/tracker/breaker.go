@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"sync"
+	"time"
+)
+
+// errCircuitOpen is returned instead of fetching while the circuit breaker holds a case back
+type errCircuitOpen struct {
+	scope string // the case ID, or "all cases"
+	until time.Time
+}
+
+func (e *errCircuitOpen) Error() string {
+	return fmt.Sprintf("circuit breaker open for %s until %s", e.scope, e.until.Format("15:04:05"))
+}
+
+// breakerTrip describes a circuit the breaker just opened
+type breakerTrip struct {
+	caseID   string // empty when every case is held back
+	failures int
+	until    time.Time
+	alert    bool // the first trip since everything last worked
+}
+
+// fetchBreaker stops fetching after repeated failures, so an outage or a block by USCIS
+// doesn't get an error (and a request) on every poll
+// After the cooldown one more fetch is let through: a success closes the circuit, a
+// failure opens it again right away
+type fetchBreaker struct {
+	mu              sync.Mutex
+	threshold       int
+	globalThreshold int
+	cooldown        time.Duration
+	failures        map[string]int
+	openUntil       map[string]time.Time
+	globalFailures  int
+	globalOpenUntil time.Time
+	alerted         bool // a degraded alert went out and nothing recovered since
+}
+
+// newFetchBreaker creates a breaker that opens after threshold failures of one case, or
+// globalThreshold failures in a row across cases (0 = never), for cooldown
+func newFetchBreaker(threshold, globalThreshold int, cooldown time.Duration) *fetchBreaker {
+	return &fetchBreaker{
+		threshold:       threshold,
+		globalThreshold: globalThreshold,
+		cooldown:        cooldown,
+		failures:        make(map[string]int),
+		openUntil:       make(map[string]time.Time),
+	}
+}
+
+// allow returns an *errCircuitOpen while a case must not be fetched
+func (b *fetchBreaker) allow(caseID string, now time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if now.Before(b.globalOpenUntil) {
+		return &errCircuitOpen{scope: "all cases", until: b.globalOpenUntil}
+	}
+	if until := b.openUntil[caseID]; now.Before(until) {
+		return &errCircuitOpen{scope: caseID, until: until}
+	}
+	return nil
+}
+
+// observe records the outcome of a fetch and returns the circuit it opened, if any
+// recovered is true for a success that closes the breaker after a degraded alert
+func (b *fetchBreaker) observe(caseID string, fetchErr error, now time.Time) (trip *breakerTrip, recovered bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if fetchErr == nil {
+		b.failures[caseID] = 0
+		b.globalFailures = 0
+		b.globalOpenUntil = time.Time{}
+		delete(b.openUntil, caseID)
+		if b.alerted && len(b.openUntil) == 0 {
+			b.alerted = false
+			recovered = true
+		}
+		return nil, recovered
+	}
+
+	b.failures[caseID]++
+	b.globalFailures++
+	switch {
+	case b.globalThreshold > 0 && b.globalFailures >= b.globalThreshold:
+		b.globalOpenUntil = now.Add(b.cooldown)
+		trip = &breakerTrip{failures: b.globalFailures, until: b.globalOpenUntil}
+	case b.threshold > 0 && b.failures[caseID] >= b.threshold:
+		b.openUntil[caseID] = now.Add(b.cooldown)
+		trip = &breakerTrip{caseID: caseID, failures: b.failures[caseID], until: b.openUntil[caseID]}
+	default:
+		return nil, false
+	}
+	trip.alert = !b.alerted
+	b.alerted = true
+	return trip, false
+}
+
+// problem describes the open circuits for /health, or ""
+func (b *fetchBreaker) problem(now time.Time) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if now.Before(b.globalOpenUntil) {
+		return fmt.Sprintf("fetching paused for all cases after repeated failures (until %s)", b.globalOpenUntil.Format("15:04:05"))
+	}
+	open := 0
+	for _, until := range b.openUntil {
+		if now.Before(until) {
+			open++
+		}
+	}
+	if open > 0 {
+		return fmt.Sprintf("fetching paused for %d case(s) after repeated failures", open)
+	}
+	return ""
+}
+
+// observeFetch feeds a fetch outcome to the circuit breaker and reports what changed
+func (a *app) observeFetch(caseID string, fetchErr error) {
+	trip, recovered := a.breaker.observe(caseID, fetchErr, time.Now())
+	if recovered {
+		log.Printf("[%s] Fetch succeeded - circuit breaker closed, USCIS is reachable again", caseID)
+		return
+	}
+	if trip == nil {
+		return
+	}
+
+	scope := "all cases"
+	if trip.caseID != "" {
+		scope = trip.caseID
+	}
+	log.Printf("[%s] CIRCUIT BREAKER OPEN: %d failed fetch(es) in a row - not fetching %s until %s",
+		caseID, trip.failures, scope, trip.until.Format("2006-01-02 15:04:05"))
+	if trip.alert {
+		a.sendDegradedAlert(scope, trip, fetchErr)
+	}
+}
+
+// sendDegradedAlert tells the user, once per outage, that fetching is paused
+func (a *app) sendDegradedAlert(scope string, trip *breakerTrip, fetchErr error) {
+	loc := a.recipientLocale()
+	subject := a.cfg.BrandName + " - Service Degraded"
+	body := fmt.Sprintf(`
+		<h2>⚠️ Service Degraded</h2>
+		<p>The last %d fetch(es) failed, so the tracker stopped fetching <strong>%s</strong> until %s to protect your USCIS account. It then tries again.</p>
+		<p><strong>Last error:</strong> %s</p>
+		<p>USCIS may be down, or it may be blocking the tracker. Status changes in the meantime are picked up once fetching works again.</p>
+		<p>This alert is sent once; the tracker logs when fetching recovers.</p>
+	`, trip.failures, template.HTMLEscapeString(scope), loc.FormatDateTime(trip.until), template.HTMLEscapeString(fetchErr.Error()))
+
+	var caseIDs []string
+	if trip.caseID != "" {
+		caseIDs = []string{trip.caseID}
+	}
+	if err := a.sendAlert(caseIDs, subject, body); err != nil {
+		log.Printf("Failed to send service degraded alert: %v", err)
+	}
+}
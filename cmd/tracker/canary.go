@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/config"
+	"github.com/phhowardchen/case-tracker/internal/storage"
+)
+
+// canaryMonitor remembers whether the canary (CANARY_INTERVAL) missed its deliveries
+type canaryMonitor struct {
+	mu      sync.Mutex
+	problem string // why the canary is overdue; empty while it is delivered on schedule
+}
+
+// splitCanary takes the canary out of a cycle's results when it has a notification to send
+// It is notified on its own, so it never ends up in a digest, bundle or summary of real cases
+func (a *app) splitCanary(results []*caseResult) (*caseResult, []*caseResult) {
+	for i, r := range results {
+		if a.cfg.IsCanary(r.caseID) && r.needsNotification() {
+			return r, slices.Delete(slices.Clone(results), i, i+1)
+		}
+	}
+	return nil, results
+}
+
+// isCanaryEntry reports whether an outbox entry is a notification about the canary
+func (a *app) isCanaryEntry(entry *storage.OutboxEntry) bool {
+	return len(entry.CaseIDs) == 1 && a.cfg.IsCanary(entry.CaseIDs[0])
+}
+
+// canaryMaxAge is how long the canary may go without a delivered notification: two
+// changes and a poll interval, so one slow cycle doesn't count as a miss
+func (a *app) canaryMaxAge() time.Duration {
+	return 2*a.cfg.CanaryInterval + a.cfg.PollInterval
+}
+
+// checkCanary verifies after a poll cycle that the canary's notifications keep arriving
+// A miss means storage, detection or delivery is broken; it is alerted once over every
+// channel and shown on /health until a canary notification is delivered again
+func (a *app) checkCanary(now time.Time) {
+	if a.cfg.CanaryInterval == 0 || a.toggles.isDryRun() {
+		return
+	}
+
+	problem := ""
+	maxAge := a.canaryMaxAge()
+	if since := now.Add(-maxAge); a.health.Snapshot().StartedAt.Before(since) {
+		deliveries, err := a.deliveries.Since(since)
+		if err != nil {
+			log.Printf("Warning: Failed to read the delivery log for the canary: %v", err)
+			return
+		}
+		delivered := slices.ContainsFunc(deliveries, func(d storage.Delivery) bool {
+			return d.Error == "" && slices.Contains(d.CaseIDs, config.CanaryCaseID)
+		})
+		if !delivered {
+			problem = fmt.Sprintf("no canary notification delivered in the last %s", maxAge)
+		}
+	}
+
+	a.canary.mu.Lock()
+	previous := a.canary.problem
+	a.canary.problem = problem
+	a.canary.mu.Unlock()
+
+	switch {
+	case problem != "" && previous == "":
+		log.Printf("[%s] CANARY MISSED: %s", config.CanaryCaseID, problem)
+		a.sendCanaryAlert(problem)
+	case problem == "" && previous != "":
+		log.Printf("[%s] Canary delivered again - pipeline recovered", config.CanaryCaseID)
+	}
+}
+
+// canaryProblem returns why the canary is overdue, or ""
+func (a *app) canaryProblem() string {
+	a.canary.mu.Lock()
+	defer a.canary.mu.Unlock()
+	return a.canary.problem
+}
+
+// sendCanaryAlert tells the user that the canary stopped coming through
+func (a *app) sendCanaryAlert(problem string) {
+	channels := "every channel"
+	if len(a.cfg.CanaryChannels) > 0 {
+		channels = fmt.Sprint(a.cfg.CanaryChannels)
+	}
+	subject := a.cfg.BrandName + " - Pipeline Canary Missed"
+	body := fmt.Sprintf(`
+		<h2>⚠️ Pipeline Canary Missed</h2>
+		<p>The synthetic canary case changes every %s and is notified to %s. There was %s, so updates of your real cases may not be detected or delivered either.</p>
+		<ul>
+			<li>Check the tracker logs for errors saving state or sending notifications</li>
+			<li>Check the delivery log (<code>STATE_FILE_DIR/deliveries.jsonl</code>) for failed sends</li>
+			<li>Check the credentials of the canary's channels</li>
+		</ul>
+		<p>This alert is sent once; the tracker logs when the canary comes through again.</p>
+	`, a.cfg.CanaryInterval, channels, problem)
+
+	if err := a.sendAlert(nil, subject, body); err != nil {
+		log.Printf("[%s] Failed to send canary alert: %v", config.CanaryCaseID, err)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"log"
+
+	"github.com/phhowardchen/case-tracker/internal/source"
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// historyFetcher is implemented by fetchers that can read the case history endpoints
+type historyFetcher interface {
+	FetchCaseHistory(caseID string) ([]interface{}, error)
+}
+
+// requestsPerCase is how many USCIS requests checking one case takes
+func (a *app) requestsPerCase() int {
+	if a.cfg.FetchCaseHistory {
+		return 1 + len(uscis.HistoryEndpoints)
+	}
+	return 1
+}
+
+// withCaseHistory merges the case history endpoints into a fetched payload (FETCH_CASE_HISTORY)
+// New entries then show up as "New Action" changes. Returns the payload and the previous
+// state to compare it with: the first fetch with history is a baseline, so the previous
+// state gets the same entries instead of reporting the whole history as new
+func (a *app) withCaseHistory(caseID string, fetcher source.Fetcher, previous, status map[string]interface{}) (map[string]interface{}, map[string]interface{}) {
+	historian, ok := fetcher.(historyFetcher)
+	if !ok {
+		// The public status service has no history
+		return status, previous
+	}
+
+	for range uscis.HistoryEndpoints {
+		a.countRequest(caseID)
+	}
+	entries, err := historian.FetchCaseHistory(caseID)
+	if err != nil {
+		// Keep the last known history so its entries don't look new once the endpoints are back
+		log.Printf("[%s] Warning: Failed to fetch case history, keeping the last known one: %v", caseID, err)
+		known, ok := uscis.HistoryEntries(previous)
+		if !ok {
+			return status, previous
+		}
+		return uscis.WithHistory(status, known), previous
+	}
+
+	if _, ok := uscis.HistoryEntries(previous); !ok && previous != nil {
+		log.Printf("[%s] Recording %d case history entries as the baseline", caseID, len(entries))
+		previous = uscis.WithHistory(previous, entries)
+	}
+	return uscis.WithHistory(status, entries), previous
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/phhowardchen/case-tracker/internal/locale"
+	"github.com/phhowardchen/case-tracker/internal/notifier"
+	"github.com/phhowardchen/case-tracker/internal/storage"
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// snapshotLister is implemented by storage backends that keep every saved state
+type snapshotLister interface {
+	ListSnapshots() ([]storage.Snapshot, error)
+}
+
+// isApprovalTransition reports whether this poll moved the case into an approved status
+func (r *caseResult) isApprovalTransition() bool {
+	if r.isFirstRun() {
+		return false
+	}
+	return !uscis.IsApproved(uscis.StatusSummary(r.previous)) && uscis.IsApproved(uscis.StatusSummary(r.status))
+}
+
+// celebrateIfApproved sends the journey summary email when a case has just been approved
+// Called after state is saved so the history already includes the approval
+func (a *app) celebrateIfApproved(r *caseResult) {
+	if !a.cfg.CelebrationEmail || !r.isApprovalTransition() {
+		return
+	}
+	if _, snoozed := a.snoozedUntil(r.caseID); snoozed {
+		log.Printf("[%s] Case approved but notifications are snoozed - skipping celebration summary", r.caseID)
+		return
+	}
+
+	log.Printf("[%s] Case approved - sending celebration summary", r.caseID)
+
+	var observations []uscis.Observation
+	if lister, ok := r.storage.(snapshotLister); ok {
+		snapshots, err := lister.ListSnapshots()
+		if err != nil {
+			log.Printf("[%s] Warning: Failed to load history for celebration email: %v", r.caseID, err)
+		}
+		for _, snap := range snapshots {
+			observations = append(observations, uscis.Observation{Time: snap.Timestamp, Status: snap.Data})
+		}
+	}
+
+	subject := fmt.Sprintf("🎉 USCIS Case Approved - %s", a.cfg.CaseLabel(r.caseID))
+	milestones := uscis.BuildMilestones(observations)
+	msgs := a.localized([]string{r.caseID}, func(loc locale.Settings) notifier.Message {
+		return a.message([]string{r.caseID}, subject, formatCelebrationEmail(r.caseID, r.status, milestones, loc))
+	})
+	var errs []error
+	for _, msg := range msgs {
+		errs = append(errs, a.notifier.SendChange(msg))
+	}
+	if err := errors.Join(errs...); err != nil {
+		log.Printf("[%s] Failed to send celebration email: %v", r.caseID, err)
+		return
+	}
+	log.Printf("[%s] Celebration email sent successfully", r.caseID)
+}
+
+// formatCelebrationEmail renders the whole case journey from filing to approval
+func formatCelebrationEmail(caseID string, status map[string]interface{}, milestones []uscis.Milestone, loc locale.Settings) string {
+	filed, hasFiled := uscis.FiledDate(status)
+	if !hasFiled && len(milestones) > 0 {
+		// Fall back to the first time the tracker saw the case
+		filed, hasFiled = milestones[0].Date, true
+	}
+
+	filedHTML := "unknown"
+	totalHTML := ""
+	if hasFiled {
+		filedHTML = loc.FormatDate(filed)
+		if len(milestones) > 0 {
+			approvedAt := milestones[len(milestones)-1].Date
+			days := int(math.Round(approvedAt.Sub(filed).Hours() / 24))
+			totalHTML = fmt.Sprintf("<p><strong>Total time:</strong> %d days</p>", days)
+		}
+	}
+
+	rows := ""
+	for _, m := range milestones {
+		rows += fmt.Sprintf("<tr><td style='padding: 4px 12px;'>%s</td><td style='padding: 4px 12px;'>%s</td></tr>", loc.FormatDate(m.Date), loc.Status(m.Status))
+	}
+
+	form := uscis.FormType(status)
+	if form == "" {
+		form = "Your application"
+	}
+
+	html := fmt.Sprintf(`
+		<h2>🎉 Congratulations - your case was approved!</h2>
+		<p><strong>Case ID:</strong> %s</p>
+		<p><strong>Form:</strong> %s</p>
+		<p><strong>Filed:</strong> %s</p>
+		%s
+		<h3>Your Journey:</h3>
+		<table style="border-collapse: collapse;">
+			<tr><th style="text-align: left; padding: 4px 12px;">Date</th><th style="text-align: left; padding: 4px 12px;">Milestone</th></tr>
+			%s
+		</table>
+		<p>You will still receive the regular update emails for any further changes (e.g. card production and delivery).</p>
+	`, caseID, form, filedHTML, totalHTML, rows)
+
+	return html
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/phhowardchen/case-tracker/internal/config"
+	"github.com/phhowardchen/case-tracker/internal/health"
+	"github.com/phhowardchen/case-tracker/internal/source"
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// runCheck implements `tracker check <caseID>`
+// It fetches one case, diffs it against the saved state and prints the result
+// without the daemon: no notifications are sent, and the state is only saved with -save
+func runCheck(args []string) int {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	save := fs.Bool("save", false, "save the fetched status as the case's state (the daemon then won't report these changes)")
+	sourceName := fs.String("source", "", "fetch from this source instead of the configured one ("+strings.Join(source.Known, ", ")+")")
+	printJSON := fs.Bool("json", false, "also print the fetched status as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: tracker check [-save] [-source name] [-json] <caseID>")
+		fs.PrintDefaults()
+	}
+	// Accept flags after the case ID too
+	var positional []string
+	for {
+		fs.Parse(args)
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return 2
+	}
+	caseID := strings.ToUpper(strings.TrimSpace(positional[0]))
+
+	cfg, err := config.LoadPartial()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return exitConfigError
+	}
+	if *sourceName != "" && !source.IsKnown(*sourceName) {
+		fmt.Fprintf(os.Stderr, "unknown source %q (known: %s)\n", *sourceName, strings.Join(source.Known, ", "))
+		return 2
+	}
+	if *sourceName == "" {
+		*sourceName = cfg.SourceFor(caseID)
+	}
+	// Only this case is polled, so its source decides what needs credentials
+	cfg.CaseIDs = []string{caseID}
+	cfg.CaseSources = map[string]string{caseID: *sourceName}
+	cfg.BootstrapStagger = 0
+
+	// The app comes first, so a failed login is captured in the configured backend
+	sources := source.NewRegistry(*sourceName, nil)
+	a, err := newApp(cfg, sources, health.NewTracker("check", cfg.CaseIDs))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize: %v\n", err)
+		return exitUnexpected
+	}
+	fetcher, closeFetcher, note, err := newCommandFetcher(cfg, caseID)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfigError
+	}
+	defer closeFetcher()
+	sources.Register(*sourceName, fetcher)
+	// A failed check must not alert the recipients the daemon notifies
+	a.toggles.dryRun = true
+	result, err := a.checkCase(caseID)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		var authErr *uscis.ErrAuthenticationFailed
+		var lockedErr *uscis.ErrAccountLocked
+		if errors.As(err, &authErr) || errors.As(err, &lockedErr) {
+			return exitAuthFailure
+		}
+		return exitUnexpected
+	}
+
+	printCheck(result, note)
+	if *printJSON {
+		data, _ := json.MarshalIndent(result.status, "", "  ")
+		fmt.Println(string(data))
+	}
+	if *save {
+		a.saveState(result, a.redactor.Apply(result.status))
+		fmt.Println("Saved as the case's state")
+	}
+	return exitOK
+}
+
+// printCheck prints the status of a checked case and what changed since its saved state
+func printCheck(r *caseResult, note string) {
+	summary := uscis.StatusSummary(r.status)
+	if summary == "" {
+		summary = "no recognizable status field"
+	}
+	fmt.Printf("%s: %s\n", r.caseID, summary)
+	if note != "" {
+		fmt.Printf("  (%s)\n", note)
+	}
+
+	switch {
+	case r.previous == nil:
+		fmt.Println("No saved state: this would be the case's first status")
+	case len(r.changes) == 0:
+		fmt.Println("No changes since the saved state")
+	default:
+		fmt.Printf("%d change(s) since the saved state:\n", len(r.changes))
+		for _, change := range r.changes {
+			fmt.Printf("  %s: %s -> %s\n", change.Field, checkValue(change.OldValue), checkValue(change.NewValue))
+		}
+	}
+}
+
+// checkValue formats a changed value on one line
+func checkValue(value interface{}) string {
+	if value == nil {
+		return "(none)"
+	}
+	if s, ok := value.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+// newCommandFetcher creates the fetcher a one-shot command uses for a case, the way the
+// tracker would, Chrome fallback included
+// The returned note explains a fallback (empty if none), and close releases the browser
+func newCommandFetcher(cfg *config.Config, caseID string) (source.Fetcher, func(), string, error) {
+	if cfg.SourceFor(caseID) == source.Canary {
+		return uscis.NewCanaryClient(cfg.CanaryInterval), func() {}, "", nil
+	}
+	strategy, fallbackReason := chooseFetchStrategy(cfg)
+	if cfg.SourceFor(caseID) == source.Public {
+		strategy, fallbackReason = strategyPublic, ""
+	}
+	note := ""
+	if fallbackReason != "" {
+		note = "via " + strategy + " fallback: " + fallbackReason
+	}
+
+	switch strategy {
+	case strategyBrowser:
+		if cfg.USCISUsername == "" || cfg.USCISPassword == "" {
+			return nil, nil, "", fmt.Errorf("USCIS_USERNAME and USCIS_PASSWORD are required when AUTO_LOGIN=true")
+		}
+		codes, err := newCodeProvider(cfg, nil)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		// A case of an additional account is fetched through that account's login
+		username, password := cfg.USCISUsername, cfg.USCISPassword
+		if account, ok := cfg.AccountFor(caseID); ok {
+			username, password = account.Username, account.Password
+			if account.TOTPSecret != "" {
+				if codes, err = uscis.NewTOTP(account.TOTPSecret); err != nil {
+					return nil, nil, "", err
+				}
+			}
+		}
+		browserClient, err := uscis.NewBrowserClientWithCodes(context.Background(), username, password, codes, nil)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		browserClient.SetFetchTimeout(cfg.FetchTimeout)
+		return browserClient, func() { browserClient.Close() }, note, nil
+	case strategyPublic:
+		client := uscis.NewPublicClient()
+		client.SetFetchTimeout(cfg.FetchTimeout)
+		return client, func() {}, note, nil
+	default:
+		if cfg.USCISCookie == "" {
+			return nil, nil, "", fmt.Errorf("USCIS_COOKIE is not set: run `tracker login` to get one, or pass -source %s", source.Public)
+		}
+		client := uscis.NewClient(cfg.USCISCookie)
+		client.SetFetchTimeout(cfg.FetchTimeout)
+		return client, func() {}, note, nil
+	}
+}
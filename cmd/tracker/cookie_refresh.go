@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/phhowardchen/case-tracker/internal/config"
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// setupCookieRefresh lets manual-cookie mode heal itself when USCIS_USERNAME and
+// USCIS_PASSWORD are also set: an expired cookie is replaced by logging in with
+// the browser instead of only sending an auth-failure alert
+// Returns false (and logs why) when the refresh can't be enabled
+func setupCookieRefresh(ctx context.Context, cfg *config.Config, client *uscis.Client, sessions uscis.SessionStore, sms *uscis.SMSCodes) bool {
+	if cfg.USCISUsername == "" || cfg.USCISPassword == "" {
+		log.Printf("  Cookie refresh: disabled (set USCIS_USERNAME and USCIS_PASSWORD to log in when the cookie expires)")
+		return false
+	}
+	if _, err := prepareChrome(cfg); err != nil {
+		log.Printf("  Cookie refresh: disabled (%v)", err)
+		return false
+	}
+
+	codes, err := newCodeProvider(cfg, sms)
+	if err != nil {
+		log.Printf("  Cookie refresh: disabled (%v)", err)
+		return false
+	}
+	switch {
+	case cfg.USCISTOTPSecret != "":
+		log.Printf("  Cookie refresh: enabled (browser login as %s, 2FA code from the authenticator secret)", cfg.USCISUsername)
+	case sms != nil:
+		log.Printf("  Cookie refresh: enabled (browser login as %s, 2FA code texted to the Twilio number)", cfg.USCISUsername)
+	case codes != nil:
+		log.Printf("  Cookie refresh: enabled (browser login as %s, 2FA from %s)", cfg.USCISUsername, cfg.EmailUsername)
+	default:
+		log.Printf("  Cookie refresh: enabled (browser login as %s, 2FA code from stdin)", cfg.USCISUsername)
+	}
+	uscis.SetLoginSpacing(cfg.LoginSpacing, cfg.LoginJitter)
+
+	client.SetCookieRefresher(func() (string, error) {
+		log.Printf("Logging in with the browser to mint a new session cookie...")
+		// Same 2FA settings as auto-login mode
+		browserClient, err := uscis.NewBrowserClientWithCodes(ctx, cfg.USCISUsername, cfg.USCISPassword, codes, sessions)
+		if err != nil {
+			return "", fmt.Errorf("failed to log in: %w", err)
+		}
+		// The browser is only needed to log in; fetches stay on the HTTP client
+		defer browserClient.Close()
+
+		cookie, err := browserClient.CookieHeader()
+		if err != nil {
+			return "", err
+		}
+		log.Printf("New session cookie obtained")
+		return cookie, nil
+	})
+	return true
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/config"
+	"github.com/phhowardchen/case-tracker/internal/health"
+	"github.com/phhowardchen/case-tracker/internal/storage"
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// dashboardHistory is how many history entries the dashboard shows per case
+const dashboardHistory = 20
+
+// caseOverview is the last known state of one case, served at /cases and /api/cases
+type caseOverview struct {
+	CaseID       string             `json:"case_id"`
+	Nickname     string             `json:"nickname,omitempty"`  // From CASE_NICKNAMES
+	Applicant    string             `json:"applicant,omitempty"` // From CASE_APPLICANTS
+	Source       string             `json:"source,omitempty"`
+	Status       string             `json:"status,omitempty"`
+	Stage        string             `json:"stage,omitempty"`
+	Description  string             `json:"description,omitempty"`
+	Form         string             `json:"form,omitempty"`         // From the status, or else CASE_FORM_TYPES
+	LastUpdated  *time.Time         `json:"last_updated,omitempty"` // When USCIS last updated the case
+	Health       health.CaseHealth  `json:"health"`
+	SnoozedUntil *time.Time         `json:"snoozed_until,omitempty"`
+	History      []caseHistoryEntry `json:"history"` // Newest first
+	HistoryError string             `json:"history_error,omitempty"`
+}
+
+// caseHistoryEntry is a saved state that differed from the one before it
+type caseHistoryEntry struct {
+	Time    time.Time      `json:"time"`
+	Status  string         `json:"status"`
+	Changes []uscis.Change `json:"changes,omitempty"` // Empty for the first state
+}
+
+// caseOverviews builds the overview of tracked cases from storage and the health tracker
+// At most historyLimit history entries are kept per case (0 = all)
+func (a *app) caseOverviews(caseIDs []string, historyLimit int) []caseOverview {
+	healthByCase := make(map[string]health.CaseHealth)
+	for _, c := range a.health.Snapshot().Cases {
+		healthByCase[c.CaseID] = c
+	}
+
+	overviews := make([]caseOverview, 0, len(caseIDs))
+	for _, caseID := range caseIDs {
+		overview := caseOverview{
+			CaseID:    caseID,
+			Nickname:  a.cfg.CaseNicknames[caseID],
+			Applicant: a.cfg.CaseApplicants[caseID],
+			Source:    a.cfg.SourceFor(caseID),
+			Health:    healthByCase[caseID],
+			History:   []caseHistoryEntry{},
+		}
+		if until, snoozed := a.snoozedUntil(caseID); snoozed {
+			overview.SnoozedUntil = &until
+		}
+		if err := a.loadCaseHistory(&overview, historyLimit); err != nil {
+			log.Printf("[%s] Dashboard: %v", caseID, err)
+			overview.HistoryError = err.Error()
+		}
+		if overview.Form == "" {
+			overview.Form = a.cfg.CaseFormTypes[caseID]
+		}
+		overviews = append(overviews, overview)
+	}
+	return overviews
+}
+
+// loadCaseHistory fills in the latest status and the change history of a case
+// Backends without history still report the latest state
+func (a *app) loadCaseHistory(overview *caseOverview, historyLimit int) error {
+	store := a.caseStorage(overview.CaseID)
+
+	lister, ok := store.(snapshotLister)
+	if !ok {
+		latest, err := store.Load()
+		if err != nil {
+			return err
+		}
+		overview.setLatest(latest)
+		return nil
+	}
+
+	snapshots, err := lister.ListSnapshots()
+	if err != nil {
+		return err
+	}
+	if len(snapshots) == 0 {
+		return nil
+	}
+	overview.setLatest(snapshots[len(snapshots)-1].Data)
+
+	// Every poll saves a snapshot; only the ones that changed something are history
+	var previous *uscis.CaseStatus
+	var history []caseHistoryEntry
+	options := a.changeOptions()
+	for _, snap := range snapshots {
+		current := uscis.NewCaseStatus(snap.Data)
+		changes := options.Filter(uscis.DetectStatusChanges(previous, current))
+		if previous == nil || len(changes) > 0 {
+			history = append(history, caseHistoryEntry{Time: snap.Timestamp, Status: current.StatusTitle, Changes: changes})
+		}
+		previous = current
+	}
+
+	for i := len(history) - 1; i >= 0; i-- {
+		if historyLimit > 0 && len(overview.History) == historyLimit {
+			break
+		}
+		overview.History = append(overview.History, history[i])
+	}
+	return nil
+}
+
+// setLatest fills in the status fields from the latest saved state
+func (o *caseOverview) setLatest(data map[string]interface{}) {
+	status := uscis.NewCaseStatus(data)
+	if status == nil {
+		return
+	}
+	o.Status = status.StatusTitle
+	o.Description = status.Description
+	o.Form = status.FormType
+	if stage := uscis.Stage(status.StatusTitle); stage >= 0 {
+		o.Stage = uscis.Stages[stage]
+	}
+	if !status.LastUpdated.IsZero() {
+		o.LastUpdated = &status.LastUpdated
+	}
+}
+
+// handleCasesAPI serves the case overviews as JSON
+// ?history=N limits the history entries per case (default all)
+func (a *app) handleCasesAPI(w http.ResponseWriter, r *http.Request) {
+	limit := 0
+	if value := r.FormValue("history"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid history", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"generated": time.Now(),
+		"cases":     a.caseOverviews(a.visibleCases(r), limit),
+	})
+}
+
+var dashboardTemplate = template.Must(template.New("cases").Funcs(template.FuncMap{
+	"changes":   uscis.FormatChanges,
+	"highlight": highlightSnippet,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta http-equiv="refresh" content="60">
+<meta name="theme-color" content="{{.ThemeColor}}">
+<meta name="apple-mobile-web-app-capable" content="yes">
+<link rel="manifest" href="/manifest.webmanifest">
+<link rel="icon" href="/icon.svg" type="image/svg+xml">
+<link rel="apple-touch-icon" href="/icon.svg">
+<title>{{.Brand}} - Cases</title>
+<style>
+body { font-family: -apple-system, Helvetica, Arial, sans-serif; max-width: 900px; margin: 2em auto; padding: 0 1em; color: #222; }
+.case { border: 1px solid #ddd; border-radius: 6px; padding: 12px 16px; margin-bottom: 16px; }
+.case.failing { border-color: #d9534f; }
+.case h3 { margin: 0 0 4px 0; }
+.muted { color: #777; }
+.error { color: #d9534f; }
+table { border-collapse: collapse; width: 100%; margin-top: 8px; font-size: 0.9em; }
+td { border-top: 1px solid #eee; padding: 4px 8px 4px 0; vertical-align: top; }
+pre { margin: 0; white-space: pre-wrap; font-family: inherit; }
+form.search { margin-bottom: 16px; }
+form.search input[name=q] { width: 40%; }
+mark { background: #fff3a3; }
+a.download { float: right; font-size: 0.75em; font-weight: normal; }
+td.when { white-space: nowrap; }
+#push { margin-bottom: 16px; }
+@media (max-width: 600px) {
+body { margin: 1em auto; font-size: 15px; }
+.case { padding: 10px 12px; }
+form.search input, form.search button { display: block; width: 100%; box-sizing: border-box; margin-bottom: 6px; font-size: 16px; }
+form.search input[name=q] { width: 100%; }
+a.download { float: none; display: block; margin-top: 4px; }
+td.when { white-space: normal; width: 30%; }
+}
+</style>
+</head>
+<body>
+<h2>{{.Brand}}</h2>
+<p class="muted">Poll interval {{.PollInterval}} · generated {{.Generated}}{{with .User}} · signed in as {{.Name}} (<a href="/logout">sign out</a>){{end}}</p>
+{{if .PushEnabled}}<div id="push" hidden><button type="button"></button> <span class="muted"></span></div>{{end}}
+{{if .SearchEnabled}}
+<form class="search" method="get" action="/cases">
+<input type="search" name="q" value="{{.Search.Text}}" placeholder="Search changes, notices and notes, e.g. RFE">
+<input type="text" name="since" value="{{.Search.Since}}" placeholder="since YYYY-MM(-DD)" size="14">
+<input type="text" name="until" value="{{.Search.Until}}" placeholder="until YYYY-MM(-DD)" size="14">
+<button type="submit">Search</button>
+</form>
+{{end}}
+{{if .Search.Text}}
+<div class="case">
+<h3>Search results</h3>
+{{if .Search.Error}}<div class="error">{{.Search.Error}}</div>
+{{else if .Search.Results}}
+<table>
+{{range .Search.Results}}
+<tr><td class="muted when">{{call $.FormatTime .At}}</td><td>{{.CaseID}}</td><td class="muted">{{.Kind}}</td><td>{{highlight .Snippet}}</td></tr>
+{{end}}
+</table>
+{{else}}<div class="muted">No matches</div>{{end}}
+</div>
+{{end}}
+{{range .Cases}}
+<div class="case{{if .Health.ConsecutiveFailures}} failing{{end}}">
+<h3>{{if .Nickname}}{{.Nickname}} <span class="muted">{{.CaseID}}</span>{{else}}{{.CaseID}}{{end}}{{if .Applicant}} <span class="muted">· {{.Applicant}}</span>{{end}}{{if .Form}} <span class="muted">· {{.Form}}</span>{{end}}{{if .Status}} <a class="download" href="/cases/report?case={{.CaseID}}">Download timeline (PDF)</a>{{end}}</h3>
+{{if .Status}}<div><strong>{{.Status}}</strong>{{if .Stage}} <span class="muted">({{.Stage}})</span>{{end}}</div>{{else}}<div class="muted">Not checked yet</div>{{end}}
+{{if .Description}}<div>{{.Description}}</div>{{end}}
+<div class="muted">
+Source {{.Source}}
+{{if .LastUpdated}}· updated by USCIS {{call $.FormatTime .LastUpdated}}{{end}}
+· last check {{if .Health.LastCheck.IsZero}}never{{else}}{{call $.FormatTime .Health.LastCheck}}{{end}}
+{{if not .Health.LastSuccess.IsZero}}· last success {{call $.FormatTime .Health.LastSuccess}}{{end}}
+{{if .SnoozedUntil}}· snoozed until {{call $.FormatTime .SnoozedUntil}}{{end}}
+</div>
+{{if .Health.LastError}}<div class="error">{{.Health.ConsecutiveFailures}} consecutive failure(s): {{.Health.LastError}}</div>{{end}}
+{{if .HistoryError}}<div class="error">History unavailable: {{.HistoryError}}</div>{{end}}
+{{if .History}}
+<table>
+{{range .History}}
+<tr><td class="muted when">{{call $.FormatTime .Time}}</td><td>{{if .Changes}}<pre>{{changes .Changes}}</pre>{{else}}First seen: {{.Status}}{{end}}</td></tr>
+{{end}}
+</table>
+{{end}}
+</div>
+{{else}}
+<p class="muted">No cases are tracked.</p>
+{{end}}
+<script>
+if ("serviceWorker" in navigator) {
+  navigator.serviceWorker.register("/sw.js").then(setupPush, () => {});
+}
+{{if .EventsEnabled}}
+// Reload as soon as the tracker logs a change, instead of waiting for the refresh
+if (window.EventSource) {
+  let pending;
+  const events = new EventSource("/api/events?since={{.LastEventSeq}}");
+  events.addEventListener("log", (e) => {
+    const event = JSON.parse(e.data);
+    if (event.case_id && /^(Changes detected|First run)/.test(event.message)) {
+      clearTimeout(pending);
+      pending = setTimeout(() => location.reload(), 3000);
+    }
+  });
+}
+{{end}}
+function setupPush(registration) {
+  const box = document.getElementById("push");
+  if (!box || !registration.pushManager || !("Notification" in window)) {
+    return;
+  }
+  const button = box.querySelector("button");
+  const note = box.querySelector("span");
+  const show = (subscription) => {
+    box.hidden = false;
+    button.textContent = subscription ? "Turn off notifications on this device" : "Notify me on this device";
+    note.textContent = Notification.permission === "denied" ? "Notifications are blocked in the browser settings" : "";
+    button.onclick = () => (subscription ? unsubscribe(subscription) : subscribe()).then(show, (err) => { note.textContent = err.message; });
+  };
+  const subscribe = async () => {
+    const key = await (await fetch("/api/push/key")).json();
+    const subscription = await registration.pushManager.subscribe({
+      userVisibleOnly: true,
+      applicationServerKey: base64ToBytes(key.public_key),
+    });
+    const resp = await fetch("/api/push/subscriptions", {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify(subscription),
+    });
+    if (!resp.ok) {
+      await subscription.unsubscribe();
+      throw new Error(await resp.text());
+    }
+    return subscription;
+  };
+  const unsubscribe = async (subscription) => {
+    await fetch("/api/push/subscriptions", {
+      method: "DELETE",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ endpoint: subscription.endpoint }),
+    });
+    await subscription.unsubscribe();
+    return null;
+  };
+  registration.pushManager.getSubscription().then(show);
+}
+function base64ToBytes(value) {
+  const raw = atob(value.replace(/-/g, "+").replace(/_/g, "/") + "=".repeat((4 - value.length % 4) % 4));
+  return Uint8Array.from(raw, (c) => c.charCodeAt(0));
+}
+</script>
+</body>
+</html>
+`))
+
+// dashboardSearch is the search form state and its results on the dashboard
+type dashboardSearch struct {
+	Text, Since, Until string
+	Results            []storage.SearchResult
+	Error              string
+}
+
+// handleCasesPage renders the case dashboard
+func (a *app) handleCasesPage(w http.ResponseWriter, r *http.Request) {
+	loc := a.recipientLocale()
+	formatTime := func(t interface{}) string {
+		switch v := t.(type) {
+		case time.Time:
+			return loc.FormatDateTime(v)
+		case *time.Time:
+			return loc.FormatDateTime(*v)
+		}
+		return ""
+	}
+
+	search := dashboardSearch{Text: r.FormValue("q"), Since: r.FormValue("since"), Until: r.FormValue("until")}
+	if search.Text != "" {
+		var err error
+		if search.Results, err = a.search(r); err != nil {
+			search.Error = err.Error()
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := dashboardTemplate.Execute(w, map[string]interface{}{
+		"Brand":         a.cfg.BrandName,
+		"PollInterval":  a.cfg.PollInterval,
+		"Generated":     loc.FormatDateTime(time.Now()),
+		"Cases":         a.caseOverviews(a.visibleCases(r), dashboardHistory),
+		"FormatTime":    formatTime,
+		"SearchEnabled": a.stateDB != nil,
+		"Search":        search,
+		"ThemeColor":    pwaThemeColor,
+		"PushEnabled":   a.push != nil && isAdminRequest(r),
+		"EventsEnabled": a.logs != nil && a.cfg.EndpointsEnabled(config.EndpointsAPI) && isAdminRequest(r),
+		"User":          requestUserFrom(r),
+		"LastEventSeq":  a.lastLogSeq(),
+	}); err != nil {
+		log.Printf("Warning: Failed to render dashboard: %v", err)
+	}
+}
+
+// lastLogSeq returns the sequence number of the newest buffered log event, so the
+// dashboard's event stream starts after it
+func (a *app) lastLogSeq() uint64 {
+	if a.logs == nil {
+		return 0
+	}
+	events := a.logs.Since(0)
+	if len(events) == 0 {
+		return 0
+	}
+	return events[len(events)-1].Seq
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/config"
+	"github.com/phhowardchen/case-tracker/internal/health"
+	"github.com/phhowardchen/case-tracker/internal/source"
+	"github.com/phhowardchen/case-tracker/internal/storage"
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// deadLetterPreviewBytes limits how much of a raw body is shown in alerts and listings
+const deadLetterPreviewBytes = 500
+
+// recordDeadLetter persists an unparseable response and alerts on the first one for a case
+// Further failures for the same case are stored silently until the dead letters are handled
+func (a *app) recordDeadLetter(caseID string, parseErr *uscis.ParseError) {
+	firstForCase := a.deadLetters.CountForCase(caseID) == 0
+
+	reason := "unparseable response"
+	if errors.Is(parseErr, uscis.ErrHTMLResponse) {
+		reason = "HTML page instead of JSON"
+	}
+	letter := &storage.DeadLetter{
+		CaseID: caseID,
+		Source: a.sources.SourceOf(caseID),
+		Reason: reason,
+		Error:  parseErr.Err.Error(),
+		Body:   parseErr.Body,
+	}
+	if err := a.deadLetters.Put(letter); err != nil {
+		log.Printf("[%s] Warning: Failed to store dead letter: %v", caseID, err)
+		return
+	}
+	log.Printf("[%s] Stored unparseable response as dead letter %s (%d bytes)", caseID, letter.ID, len(letter.Body))
+
+	if !firstForCase {
+		return
+	}
+
+	subject := fmt.Sprintf("%s - Unreadable Response for %s", a.cfg.BrandName, a.cfg.CaseLabel(caseID))
+	body := fmt.Sprintf(`
+		<h2>⚠️ Unreadable USCIS Response</h2>
+		<p><strong>Case ID:</strong> %s</p>
+		<p><strong>Error:</strong> %s</p>
+		<p>The USCIS response could not be parsed (often a WAF challenge page or a truncated body). It was saved as dead letter <code>%s</code>.</p>
+		<h3>Response preview:</h3>
+		<pre style="background-color: #f5f5f5; padding: 15px; border-radius: 5px; overflow-x: auto; font-family: monospace;">%s</pre>
+		<p>Inspect and replay stored responses with:</p>
+		<pre style="background-color: #f5f5f5; padding: 10px; border-radius: 5px;">tracker deadletter list
+tracker deadletter show %s
+tracker deadletter replay %s</pre>
+		<p>You will not be alerted again for this case until its dead letters are replayed or deleted.</p>
+	`, caseID, html.EscapeString(letter.Error), letter.ID, html.EscapeString(preview(letter.Body, deadLetterPreviewBytes)), letter.ID, letter.ID)
+
+	if err := a.sendAlert([]string{caseID}, subject, body); err != nil {
+		log.Printf("[%s] Failed to send dead-letter alert email: %v", caseID, err)
+	}
+}
+
+// quarantinePayload keeps a fetched payload that doesn't fit the case as a dead letter
+// instead of diffing it, and alerts on the first dead letter for the case like recordDeadLetter
+func (a *app) quarantinePayload(caseID string, status map[string]interface{}, anomalies []string) {
+	firstForCase := a.deadLetters.CountForCase(caseID) == 0
+
+	body, _ := json.MarshalIndent(status, "", "  ")
+	letter := &storage.DeadLetter{
+		CaseID: caseID,
+		Source: a.sources.SourceOf(caseID),
+		Reason: "anomalous payload",
+		Error:  strings.Join(anomalies, "; "),
+		Body:   string(body),
+	}
+	if err := a.deadLetters.Put(letter); err != nil {
+		log.Printf("[%s] Warning: Failed to store quarantined payload: %v", caseID, err)
+		return
+	}
+	log.Printf("[%s] Quarantined anomalous payload as dead letter %s: %s", caseID, letter.ID, letter.Error)
+
+	if !firstForCase {
+		return
+	}
+
+	subject := fmt.Sprintf("%s - Suspicious Response for %s", a.cfg.BrandName, a.cfg.CaseLabel(caseID))
+	alert := fmt.Sprintf(`
+		<h2>⚠️ Suspicious USCIS Response</h2>
+		<p><strong>Case ID:</strong> %s</p>
+		<p><strong>Problem:</strong> %s</p>
+		<p>The response doesn't look like this case (a login on another account, or a response for another case), so it was not compared with the saved status and no change was reported. It was saved as dead letter <code>%s</code>.</p>
+		<p>If the response is genuine, apply it with:</p>
+		<pre style="background-color: #f5f5f5; padding: 10px; border-radius: 5px;">tracker deadletter show %s
+tracker deadletter replay %s</pre>
+		<p>You will not be alerted again for this case until its dead letters are replayed or deleted.</p>
+	`, caseID, html.EscapeString(letter.Error), letter.ID, letter.ID, letter.ID)
+
+	if err := a.sendAlert([]string{caseID}, subject, alert); err != nil {
+		log.Printf("[%s] Failed to send quarantine alert email: %v", caseID, err)
+	}
+}
+
+// runDeadLetter implements `tracker deadletter list|show|replay|delete`
+func runDeadLetter(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: tracker deadletter list | show <id> | replay <id> | delete <id>")
+		return 2
+	}
+
+	stateDir := os.Getenv("STATE_FILE_DIR")
+	if stateDir == "" {
+		stateDir = "/tmp/case-tracker-states/"
+	}
+	store := storage.NewDeadLetterStore(stateDir)
+
+	switch args[0] {
+	case "list":
+		letters, err := store.List()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if len(letters) == 0 {
+			fmt.Println("No dead letters.")
+			return 0
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tCASE\tCREATED\tBYTES\tERROR")
+		for _, letter := range letters {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n", letter.ID, letter.CaseID, letter.CreatedAt.Format("2006-01-02 15:04:05"), len(letter.Body), letter.Error)
+		}
+		tw.Flush()
+		return 0
+
+	case "show":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "usage: tracker deadletter show <id>")
+			return 2
+		}
+		letter, err := store.Get(args[1])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("ID:      %s\nCase:    %s\nSource:  %s\nCreated: %s\nReason:  %s\nError:   %s\n\n%s\n",
+			letter.ID, letter.CaseID, letter.Source, letter.CreatedAt.Format("2006-01-02 15:04:05"), letter.Reason, letter.Error, letter.Body)
+		return 0
+
+	case "delete":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "usage: tracker deadletter delete <id>")
+			return 2
+		}
+		if err := store.Delete(args[1]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("Deleted %s\n", args[1])
+		return 0
+
+	case "replay":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "usage: tracker deadletter replay <id>")
+			return 2
+		}
+		return replayDeadLetter(store, args[1])
+
+	default:
+		fmt.Fprintf(os.Stderr, "unknown deadletter command %q\n", args[0])
+		return 2
+	}
+}
+
+// replayDeadLetter re-parses a stored response with the current parser and, if it
+// now succeeds, runs it through the normal detect and notify pipeline
+// Replaying vouches for the payload, so a quarantined one skips the anomaly checks
+func replayDeadLetter(store *storage.DeadLetterStore, id string) int {
+	letter, err := store.Get(id)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	status, err := uscis.ParseCaseResponse([]byte(letter.Body))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Still unparseable: %v\n", err)
+		return 1
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return 1
+	}
+
+	sources := source.NewRegistry("replay", nil)
+	sources.Register("replay", &replayFetcher{caseID: letter.CaseID, status: status})
+	a, err := newApp(cfg, sources, health.NewTracker("replay", []string{letter.CaseID}))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize: %v\n", err)
+		return 1
+	}
+	a.acceptAnomalies = true
+
+	result, err := a.checkCase(letter.CaseID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Replay failed: %v\n", err)
+		return 1
+	}
+	// Only the notification is replayed: state saved since the dead letter is newer than
+	// its payload, which is compared with the state of its time and never saved
+	if previous, ok := stateAt(result.storage, letter.CreatedAt); ok {
+		if previous == nil {
+			// The first status was saved after the dead letter and notified with it
+			previous = result.status
+		}
+		result.previous = previous
+		result.changes = a.detectChanges(letter.CaseID, previous, result.status)
+		result.event = uscis.Classify(result.changes)
+	}
+	result.saved = true
+	a.dispatch([]*caseResult{result})
+
+	if a.health.Snapshot().Cases[0].ConsecutiveFailures > 0 {
+		fmt.Fprintln(os.Stderr, "Replay parsed the response but notification failed; dead letter kept")
+		return 1
+	}
+
+	if err := store.Delete(id); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("Replayed %s for case %s (%d change(s))\n", id, letter.CaseID, len(result.changes))
+	return 0
+}
+
+// stateAt returns the state of a case saved last before t, if states were saved after
+// it; false if the latest state is from before t or the backend keeps no history
+func stateAt(store storage.Storage, t time.Time) (map[string]interface{}, bool) {
+	lister, ok := store.(snapshotLister)
+	if !ok {
+		return nil, false
+	}
+	snapshots, err := lister.ListSnapshots()
+	if err != nil || len(snapshots) == 0 {
+		return nil, false
+	}
+	slices.SortFunc(snapshots, func(x, y storage.Snapshot) int { return x.Timestamp.Compare(y.Timestamp) })
+	if !snapshots[len(snapshots)-1].Timestamp.After(t) {
+		return nil, false
+	}
+	var state map[string]interface{}
+	for _, snapshot := range snapshots {
+		if snapshot.Timestamp.After(t) {
+			break
+		}
+		state = snapshot.Data
+	}
+	return state, true
+}
+
+// replayFetcher serves a previously stored payload instead of calling USCIS
+type replayFetcher struct {
+	caseID string
+	status map[string]interface{}
+}
+
+func (f *replayFetcher) FetchCaseStatus(caseID string) (map[string]interface{}, error) {
+	if caseID != f.caseID {
+		return nil, fmt.Errorf("replay has no payload for case %s", caseID)
+	}
+	return f.status, nil
+}
+
+// preview truncates s to at most n bytes for display
+func preview(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
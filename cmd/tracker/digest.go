@@ -0,0 +1,197 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/locale"
+	"github.com/phhowardchen/case-tracker/internal/notifier"
+	"github.com/phhowardchen/case-tracker/internal/notifier/templates"
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// dispatchChanges sends the change notifications of cases outside bundles
+// With CHANGE_DIGEST_MIN set, enough simultaneous changes for the same recipients
+// are combined into one digest instead of one email per case
+func (a *app) dispatchChanges(changed []*caseResult) {
+	for _, group := range a.groupByRecipients(changed) {
+		if a.cfg.ChangeDigestMin == 0 || len(group) < a.cfg.ChangeDigestMin {
+			for _, r := range group {
+				a.complete([]*caseResult{r}, a.notifyCase(r))
+			}
+			continue
+		}
+		a.notifyDigest(group)
+	}
+}
+
+// notifyDigest sends one digest for several changed cases to the digest channels
+// and a message per case to every other channel
+// A case counts as notified when either reached at least one channel
+func (a *app) notifyDigest(results []*caseResult) {
+	digestChannels, perCaseChannels := a.splitDigestChannels()
+	if len(digestChannels) == 0 && len(perCaseChannels) > 0 {
+		// None of CHANGE_DIGEST_CHANNELS is configured
+		for _, r := range results {
+			a.complete([]*caseResult{r}, a.notifyCase(r))
+		}
+		return
+	}
+
+	caseIDs := make([]string, 0, len(results))
+	for _, r := range results {
+		caseIDs = append(caseIDs, r.caseID)
+	}
+	event := topEvent(results)
+	subject := changeSubject(event, fmt.Sprintf("%d cases changed", len(results)))
+	digests := a.localized(caseIDs, func(loc locale.Settings) notifier.Message {
+		extras := func(r *caseResult) string { return a.digestExtrasHTML(r, loc) }
+		digest := a.message(caseIDs, subject, formatDigestEmail(results, a.caseRenderer(loc), extras, loc))
+		digest.Text = a.digestText(results, loc)
+		for _, r := range results {
+			digest.Attachments = append(digest.Attachments, a.noticeAttachments(r)...)
+		}
+		digest.Channels = digestChannels
+		if len(digestChannels) == 0 {
+			// CHANGE_DIGEST_CHANNELS unset: the digest replaces the per-case messages on every
+			// channel, and the email goes to the default recipients if the cases have none
+			digest.Channels = nil
+			if len(digest.Recipients) == 0 {
+				digest.Recipients = a.cfg.RecipientEmails
+			}
+		}
+		return digest
+	})
+	digests = a.routeAllBySeverity(digests, event.Severity)
+	if len(digests) == 0 {
+		// No digest channel takes changes this minor; the other channels may still
+		log.Printf("No digest channel takes %s changes (SEVERITY_CHANNELS) - notifying the %d cases one by one", event.Severity, len(results))
+		for _, r := range results {
+			a.complete([]*caseResult{r}, a.notifyCase(r))
+		}
+		return
+	}
+	log.Printf("%d cases changed in this cycle - sending one digest (per-case messages to: %v)", len(results), perCaseChannels)
+
+	// The digests of the other recipient locales come first among the follow-ups
+	followups := digests[1:]
+	perCaseIndex := make(map[string][]int) // positions of a case's messages in followups
+	for _, r := range results {
+		if len(perCaseChannels) == 0 {
+			continue
+		}
+		for _, msg := range a.routeAllBySeverity(a.changeMessages(r, perCaseChannels), r.event.Severity) {
+			perCaseIndex[r.caseID] = append(perCaseIndex[r.caseID], len(followups))
+			followups = append(followups, msg)
+		}
+	}
+
+	// One commit for the digest and the per-case messages, so a restart while sending
+	// them leaves the rest pending in the outbox
+	entry, queued, err := a.commit(results, outboxChange, digests[0], followups)
+	if err != nil {
+		a.complete(results, fmt.Errorf("failed to send change digest: %w", err))
+		return
+	}
+	digestErrs := []error{a.sendEntry(entry)}
+	for _, followup := range queued[:len(digests)-1] {
+		digestErrs = append(digestErrs, a.sendEntry(followup))
+	}
+	digestErr := errors.Join(digestErrs...)
+	if digestErr != nil {
+		digestErr = fmt.Errorf("failed to send change digest: %w", digestErr)
+	} else {
+		log.Printf("Change digest sent successfully")
+	}
+
+	for _, r := range results {
+		indexes, ok := perCaseIndex[r.caseID]
+		if !ok {
+			a.complete([]*caseResult{r}, digestErr)
+			continue
+		}
+		var caseErrs []error
+		for _, i := range indexes {
+			caseErrs = append(caseErrs, a.sendEntry(queued[i]))
+		}
+		caseErr := errors.Join(caseErrs...)
+		if caseErr != nil {
+			caseErr = fmt.Errorf("failed to send change notification: %w", caseErr)
+		}
+		if digestErr != nil && caseErr != nil {
+			a.complete([]*caseResult{r}, errors.Join(digestErr, caseErr))
+			continue
+		}
+		a.complete([]*caseResult{r}, nil)
+	}
+}
+
+// digestExtrasHTML renders what follows a case's section in a digest, as in its own change
+// notification: the new notices, the acknowledgement link of a critical change (tracked
+// like one sent on its own) and the snooze links
+func (a *app) digestExtrasHTML(r *caseResult, loc locale.Settings) string {
+	return a.noticesHTML(r, loc) + a.ackLinkHTML(r) + a.snoozeLinksHTML(r.caseID)
+}
+
+// splitDigestChannels separates the configured channels that get the digest from the rest
+// Returns nil, nil without CHANGE_DIGEST_CHANNELS: every channel gets the digest
+func (a *app) splitDigestChannels() (digest, perCase []string) {
+	multi, ok := a.notifier.(*notifier.MultiNotifier)
+	if !ok || len(a.cfg.ChangeDigestChannels) == 0 {
+		return nil, nil
+	}
+	for _, ch := range multi.Channels() {
+		if slices.Contains(digest, ch.Name) || slices.Contains(perCase, ch.Name) {
+			// Several webhooks share a name
+			continue
+		}
+		if slices.Contains(a.cfg.ChangeDigestChannels, ch.Name) {
+			digest = append(digest, ch.Name)
+		} else {
+			perCase = append(perCase, ch.Name)
+		}
+	}
+	return digest, perCase
+}
+
+// formatDigestEmail renders the changes of several cases as one email with a section per case
+func formatDigestEmail(results []*caseResult, render func(name string, r *caseResult) string, extras func(r *caseResult) string, loc locale.Settings) string {
+	rows := ""
+	for _, r := range results {
+		form := uscis.FormType(r.status)
+		if form == "" {
+			form = "-"
+		}
+		summary := "unknown"
+		if s := uscis.StatusSummary(r.status); s != "" {
+			summary = loc.Status(s)
+		}
+		rows += fmt.Sprintf("<tr><td style='padding: 4px 12px;'><a href='#%s'>%s</a></td><td style='padding: 4px 12px;'>%s</td><td style='padding: 4px 12px;'>%s</td></tr>", r.caseID, r.caseID, form, summary)
+	}
+
+	sections := ""
+	for _, r := range results {
+		sections += fmt.Sprintf(`
+		<hr>
+		<h3 id="%s">%s</h3>
+		%s
+		%s
+		%s`, r.caseID, r.caseID, render(templates.Changes, r), render(templates.Status, r), extras(r))
+	}
+
+	html := fmt.Sprintf(`
+		<h2>USCIS Case Status Updates</h2>
+		<p><strong>Detected:</strong> %s</p>
+		<p>%d cases changed at the same time:</p>
+		<table style="border-collapse: collapse;">
+			<tr><th style="text-align: left; padding: 4px 12px;">Receipt</th><th style="text-align: left; padding: 4px 12px;">Form</th><th style="text-align: left; padding: 4px 12px;">Current Status</th></tr>
+			%s
+		</table>
+		%s
+	`, loc.FormatDateTime(time.Now()), len(results), rows, sections)
+
+	return html
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"log"
+	"slices"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/source"
+)
+
+// caseDiscoveryRetry is the wait after a failed look at the account's cases
+const caseDiscoveryRetry = 30 * time.Minute
+
+// caseLister lists the cases on the USCIS account (*uscis.BrowserClient)
+type caseLister interface {
+	FetchAllCases() ([]string, error)
+}
+
+// caseIDs returns the tracked cases. Handlers must use it rather than cfg.CaseIDs,
+// which the main loop extends when case discovery finds a new case
+func (a *app) caseIDs() []string {
+	a.casesMu.RLock()
+	defer a.casesMu.RUnlock()
+	return a.cfg.CaseIDs
+}
+
+// startCaseDiscovery tracks the cases on the account with CASE_IDS=auto, when the
+// myUSCIS source can list them, and returns the wait before the next look
+// Returns false if discovery isn't possible in this mode
+func (a *app) startCaseDiscovery(fetcher source.Fetcher) (time.Duration, bool) {
+	lister, ok := fetcher.(caseLister)
+	if !ok {
+		log.Printf("Warning: Case discovery (CASE_IDS=auto) needs the browser login; tracking only the %d listed case(s)", len(a.cfg.CaseIDs))
+		return 0, false
+	}
+	a.lister = lister
+	log.Printf("Case discovery: tracking every case on the USCIS account, checking for new ones every %v", a.cfg.CaseDiscoveryInterval)
+	return a.discoverCasesOnSchedule(), true
+}
+
+// discoverCases starts tracking the cases on the account that aren't tracked yet and
+// returns how many it added. They are due right away, so the next cycle sends their
+// first-run summary
+// Only the main loop calls it
+func (a *app) discoverCases() (int, error) {
+	a.countRequest("")
+	found, err := a.lister.FetchAllCases()
+	if err != nil {
+		return 0, err
+	}
+
+	var added []string
+	for _, caseID := range found {
+		if !slices.Contains(a.cfg.CaseIDs, caseID) {
+			added = append(added, caseID)
+		}
+	}
+	for _, caseID := range added {
+		a.scheduler.add(caseID, a.cfg.PollIntervalFor(caseID))
+		a.health.SetCaseSource(caseID, a.sources.SourceOf(caseID))
+		a.report.addCase(caseID)
+		log.Printf("[%s] Found on the USCIS account, tracking it from now on", caseID)
+	}
+	if len(added) > 0 {
+		// A new slice, so handlers holding the old one never see it change
+		a.casesMu.Lock()
+		a.cfg.CaseIDs = append(slices.Clip(a.cfg.CaseIDs), added...)
+		a.casesMu.Unlock()
+	}
+	return len(added), nil
+}
+
+// discoverCasesOnSchedule looks for new cases on the account and returns the wait
+// before the next look
+func (a *app) discoverCasesOnSchedule() time.Duration {
+	if a.toggles.isPaused() {
+		return a.cfg.CaseDiscoveryInterval
+	}
+	added, err := a.discoverCases()
+	if err != nil {
+		log.Printf("Warning: Failed to list the cases on the USCIS account, trying again after %v: %v", caseDiscoveryRetry, err)
+		return caseDiscoveryRetry
+	}
+	if added > 0 {
+		log.Printf("Case discovery: now tracking %d case(s)", len(a.cfg.CaseIDs))
+	}
+	if len(a.cfg.CaseIDs) == 0 {
+		log.Printf("Warning: No cases on the USCIS account yet; looking again after %v", caseDiscoveryRetry)
+		return caseDiscoveryRetry
+	}
+	return a.cfg.CaseDiscoveryInterval
+}
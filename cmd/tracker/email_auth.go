@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/config"
+	"github.com/phhowardchen/case-tracker/internal/email"
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// emailAuthTimeout is how long email-auth waits for the browser to come back
+const emailAuthTimeout = 5 * time.Minute
+
+// newIMAPClient creates the client of the 2FA mailbox, logging in with OAuth2 when
+// EMAIL_OAUTH_CLIENT_ID is set and with EMAIL_PASSWORD otherwise
+func newIMAPClient(cfg *config.Config) *email.IMAPClient {
+	client := email.NewIMAPClient(cfg.EmailIMAPServer, cfg.EmailUsername, cfg.EmailPassword)
+	if cfg.EmailOAuthClientID != "" {
+		client.SetOAuth(email.NewOAuthTokens(emailOAuthConfig(cfg), cfg.EmailOAuthRefreshToken, cfg.StateFileDir))
+	}
+	return client
+}
+
+// newCodeProvider returns where browser logins get their 2FA code: the authenticator
+// secret (USCIS_TOTP_SECRET) first, then texts to the Twilio number (sms, nil outside
+// the daemon), then the mailbox; nil means the stdin prompt
+func newCodeProvider(cfg *config.Config, sms *uscis.SMSCodes) (uscis.CodeProvider, error) {
+	if cfg.USCISTOTPSecret != "" {
+		return uscis.NewTOTP(cfg.USCISTOTPSecret)
+	}
+	if sms != nil {
+		return sms, nil
+	}
+	if cfg.EmailIMAPServer != "" {
+		return uscis.NewEmailCodes(newIMAPClient(cfg), cfg.Email2FASender, 10*time.Minute), nil
+	}
+	return nil, nil
+}
+
+// emailOAuthConfig returns the OAuth2 client the mailbox is read as
+func emailOAuthConfig(cfg *config.Config) email.OAuthConfig {
+	return email.OAuthConfig{
+		ClientID:     cfg.EmailOAuthClientID,
+		ClientSecret: cfg.EmailOAuthClientSecret,
+		TokenURL:     cfg.EmailOAuthTokenURL,
+	}
+}
+
+// runEmailAuth implements `tracker email-auth`
+// It lets the mailbox owner grant the tracker access in a browser (OAuth2 authorization
+// code flow with PKCE and a loopback redirect) and prints the refresh token to use as
+// EMAIL_OAUTH_REFRESH_TOKEN
+func runEmailAuth(args []string) int {
+	fs := flag.NewFlagSet("email-auth", flag.ExitOnError)
+	write := fs.String("write", "", "also set EMAIL_OAUTH_REFRESH_TOKEN in this env file (e.g. .env)")
+	authURL := fs.String("auth-url", "", "authorization endpoint (default: Gmail's or Microsoft's, by EMAIL_IMAP_SERVER)")
+	scope := fs.String("scope", "", "IMAP scope to request (default: by EMAIL_IMAP_SERVER)")
+	port := fs.Int("port", 0, "local port the browser is sent back to (default: any free port)")
+	fs.Parse(args)
+
+	cfg, err := config.LoadPartial()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return exitConfigError
+	}
+	if cfg.EmailIMAPServer == "" || cfg.EmailUsername == "" || cfg.EmailOAuthClientID == "" {
+		fmt.Fprintln(os.Stderr, "EMAIL_IMAP_SERVER, EMAIL_USERNAME and EMAIL_OAUTH_CLIENT_ID are required")
+		return exitConfigError
+	}
+	if provider, ok := email.ProviderFor(cfg.EmailIMAPServer); ok {
+		if *authURL == "" {
+			*authURL = provider.AuthURL
+		}
+		if *scope == "" {
+			*scope = provider.Scope
+		}
+	}
+	if *authURL == "" || *scope == "" {
+		fmt.Fprintf(os.Stderr, "No known OAuth2 endpoints for %s: pass -auth-url and -scope, and set EMAIL_OAUTH_TOKEN_URL\n", cfg.EmailIMAPServer)
+		return exitConfigError
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", *port))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to listen for the redirect: %v\n", err)
+		return exitUnexpected
+	}
+	redirectURI := fmt.Sprintf("http://127.0.0.1:%d/callback", listener.Addr().(*net.TCPAddr).Port)
+
+	state, err := newAccessToken()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUnexpected
+	}
+	// PKCE wants 43 to 128 characters
+	buf := make([]byte, 48)
+	if _, err := rand.Read(buf); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create PKCE verifier: %v\n", err)
+		return exitUnexpected
+	}
+	verifier := base64.RawURLEncoding.EncodeToString(buf)
+	challenge := sha256.Sum256([]byte(verifier))
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {cfg.EmailOAuthClientID},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {*scope},
+		"state":                 {state},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+		"login_hint":            {cfg.EmailUsername},
+		// Google only hands out a refresh token with these
+		"access_type": {"offline"},
+		"prompt":      {"consent"},
+	}
+	fmt.Fprintf(os.Stderr, "Open this link in a browser and allow access to %s:\n\n  %s?%s\n\nWaiting for the browser (redirect URI %s)...\n", cfg.EmailUsername, *authURL, query.Encode(), redirectURI)
+
+	codes := make(chan string, 1)
+	failures := make(chan error, 1)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/callback" {
+			http.NotFound(w, r)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(r.FormValue("state")), []byte(state)) != 1 {
+			http.Error(w, "state mismatch, start over with tracker email-auth", http.StatusBadRequest)
+			return
+		}
+		if reason := r.FormValue("error"); reason != "" {
+			http.Error(w, "access was not granted: "+reason, http.StatusForbidden)
+			select {
+			case failures <- fmt.Errorf("access was not granted: %s %s", reason, r.FormValue("error_description")):
+			default:
+			}
+			return
+		}
+		fmt.Fprintln(w, "Access granted. You can close this window and go back to the terminal.")
+		select {
+		case codes <- r.FormValue("code"):
+		default:
+		}
+	})}
+	go server.Serve(listener)
+	defer server.Shutdown(context.Background())
+
+	var code string
+	select {
+	case code = <-codes:
+	case err = <-failures:
+	case <-time.After(emailAuthTimeout):
+		err = errors.New("timed out waiting for the browser")
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitAuthFailure
+	}
+
+	refreshToken, err := email.ExchangeCode(emailOAuthConfig(cfg), code, redirectURI, verifier)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitAuthFailure
+	}
+
+	// Try the token before handing it out; a provider may still refuse IMAP (e.g. IMAP turned off)
+	imapClient := email.NewIMAPClient(cfg.EmailIMAPServer, cfg.EmailUsername, "")
+	imapClient.SetOAuth(email.NewOAuthTokens(emailOAuthConfig(cfg), refreshToken, ""))
+	if err := imapClient.CheckLogin(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Got a refresh token, but logging in to %s with it failed: %v\n", cfg.EmailIMAPServer, err)
+	} else {
+		fmt.Fprintf(os.Stderr, "Logged in to %s as %s\n", cfg.EmailIMAPServer, cfg.EmailUsername)
+	}
+
+	fmt.Printf("EMAIL_OAUTH_REFRESH_TOKEN=%s\n", shellQuote(refreshToken))
+	if *write != "" {
+		if err := setEnvFileValue(*write, "EMAIL_OAUTH_REFRESH_TOKEN", refreshToken); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitUnexpected
+		}
+		fmt.Fprintf(os.Stderr, "Updated EMAIL_OAUTH_REFRESH_TOKEN in %s\n", *write)
+	}
+	return exitOK
+}
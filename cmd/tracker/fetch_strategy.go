@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/phhowardchen/case-tracker/internal/config"
+	"github.com/phhowardchen/case-tracker/internal/source"
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// How cases are fetched
+const (
+	strategyBrowser = "browser" // auto-login in headless Chrome
+	strategyCookie  = "cookie"  // account API with a pasted session cookie
+	strategyPublic  = "public"  // public case status service, no login
+)
+
+// chooseFetchStrategy decides how cases are fetched on this machine
+// Auto-login needs a working Chrome; without one CHROME_FALLBACK picks the
+// replacement, and the returned reason explains the fallback (empty if none)
+func chooseFetchStrategy(cfg *config.Config) (strategy, fallbackReason string) {
+	if !cfg.UsesSource(source.MyUSCIS) && len(cfg.Accounts) == 0 {
+		// Every case uses the public service; no account or browser needed
+		return strategyPublic, ""
+	}
+	if !cfg.AutoLogin {
+		return strategyCookie, ""
+	}
+
+	chrome, err := prepareChrome(cfg)
+	if err == nil {
+		log.Printf("  Chrome: %s", chrome)
+		return strategyBrowser, ""
+	}
+	if cfg.ChromeFallback == "off" {
+		// Fail in browser startup as before
+		log.Printf("  Chrome: %v (CHROME_FALLBACK=off)", err)
+		return strategyBrowser, ""
+	}
+
+	fallbackReason = "auto-login needs Chrome: " + err.Error()
+	switch cfg.ChromeFallback {
+	case strategyCookie:
+		return strategyCookie, fallbackReason
+	case strategyPublic:
+		return strategyPublic, fallbackReason
+	}
+	if cfg.USCISCookie != "" {
+		return strategyCookie, fallbackReason
+	}
+	return strategyPublic, fallbackReason
+}
+
+// prepareChrome gets browser logins ready: they connect to CHROME_REMOTE_URL if it is set,
+// otherwise a local Chrome must run here
+// It returns the browser for the startup log
+func prepareChrome(cfg *config.Config) (string, error) {
+	if cfg.ChromeRemoteURL != "" {
+		uscis.SetRemoteChrome(cfg.ChromeRemoteURL)
+		return "remote at " + uscis.RemoteChromeLabel(cfg.ChromeRemoteURL), nil
+	}
+	path, version, err := uscis.FindChrome()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s (%s)", version, path), nil
+}
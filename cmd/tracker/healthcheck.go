@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/health"
+	"github.com/phhowardchen/case-tracker/internal/storage"
+)
+
+// Values of healthResponse.Status
+const (
+	healthOK       = "ok"
+	healthDegraded = "degraded" // still 200: a restart wouldn't fix it
+	healthFailing  = "failing"  // 503: polling has failed for HEALTH_FAIL_AFTER
+)
+
+// healthResponse is the JSON document served at /health
+type healthResponse struct {
+	Status         string              `json:"status"`
+	Problems       []string            `json:"problems,omitempty"`
+	StartedAt      time.Time           `json:"started_at"`
+	AuthMode       string              `json:"auth_mode"`
+	FetchStrategy  string              `json:"fetch_strategy,omitempty"`
+	FallbackReason string              `json:"fallback_reason,omitempty"`
+	Auth           *health.AuthHealth  `json:"auth,omitempty"`
+	LastSuccess    time.Time           `json:"last_success,omitzero"` // Latest successful fetch of any case
+	FailingCases   int                 `json:"failing_cases"`
+	Cases          []health.CaseHealth `json:"cases,omitempty"` // Only for requests that may see the cases
+}
+
+// handleHealth serves /health: 200 while polling works or a restart wouldn't help
+// (status ok or degraded), 503 once it has failed for HEALTH_FAIL_AFTER, so Cloud Run
+// and Docker restart a tracker whose fetches all fail
+// Case IDs are private, so per-case details need the access a viewer has
+func (a *app) handleHealth(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	snapshot := a.health.Snapshot()
+	response := healthResponse{
+		Status:         healthOK,
+		StartedAt:      snapshot.StartedAt,
+		AuthMode:       snapshot.AuthMode,
+		FetchStrategy:  snapshot.FetchStrategy,
+		FallbackReason: snapshot.FallbackReason,
+		Auth:           snapshot.Auth,
+	}
+
+	code := http.StatusOK
+	if a.cfg.HealthFailAfter > 0 {
+		if problem := snapshot.PollingFailure(now, a.cfg.HealthFailAfter, a.cfg.IsCanary); problem != "" {
+			response.Status = healthFailing
+			response.Problems = append(response.Problems, problem)
+			code = http.StatusServiceUnavailable
+		}
+	}
+	for _, problem := range append([]string{fallbackProblem(snapshot), a.health.NetworkProblem(), a.breaker.problem(now), a.canaryProblem()}, a.loginProblems()...) {
+		if problem == "" {
+			continue
+		}
+		if response.Status == healthOK {
+			response.Status = healthDegraded
+		}
+		response.Problems = append(response.Problems, problem)
+	}
+
+	for _, c := range snapshot.Cases {
+		if c.LastSuccess.After(response.LastSuccess) {
+			response.LastSuccess = c.LastSuccess
+		}
+		if c.ConsecutiveFailures > 0 {
+			response.FailingCases++
+		}
+	}
+	if r, ok := a.healthViewer(r); ok {
+		response.Cases = []health.CaseHealth{}
+		for _, c := range snapshot.Cases {
+			if a.canSeeCase(r, c.CaseID) {
+				response.Cases = append(response.Cases, c)
+			}
+		}
+	}
+	writeJSON(w, code, response)
+}
+
+// fallbackProblem reports a fetcher chosen because Chrome isn't available here, or ""
+// Restarting doesn't bring Chrome back, so it only degrades the status
+func fallbackProblem(snapshot health.Status) string {
+	if snapshot.FallbackReason == "" {
+		return ""
+	}
+	return fmt.Sprintf("fetching with the %s fallback instead of auto-login (%s)", snapshot.FetchStrategy, snapshot.FallbackReason)
+}
+
+// healthViewer returns the request with its user if it may see case details, the way
+// restrict would let a viewer through: anyone without users.json, else a signed-in user
+func (a *app) healthViewer(r *http.Request) (*http.Request, bool) {
+	users, err := a.users.List()
+	if err != nil {
+		return r, false
+	}
+	if len(users) == 0 {
+		return r, true
+	}
+	user, err := a.requestUser(r)
+	if err != nil || user == nil {
+		return r, false
+	}
+	return r.WithContext(context.WithValue(r.Context(), userContextKey{}, user)), true
+}
+
+// runHealthcheck implements `tracker healthcheck`
+// It exits 0 when the tracker is healthy and 1 otherwise, for Docker HEALTHCHECK and exec probes
+func runHealthcheck(args []string) int {
+	fs := flag.NewFlagSet("healthcheck", flag.ExitOnError)
+	url := fs.String("url", "", "health endpoint to query (default http://localhost:$PORT/health)")
+	timeout := fs.Duration("timeout", 5*time.Second, "HTTP request timeout")
+	stateMaxAge := fs.Duration("state-max-age", 0, "instead of querying HTTP, require a completed poll cycle within this age (for run-once setups)")
+	fs.Parse(args)
+
+	if *stateMaxAge > 0 {
+		return checkStateFreshness(*stateMaxAge)
+	}
+
+	if *url == "" {
+		port := os.Getenv("PORT")
+		if port == "" {
+			port = "8080"
+		}
+		*url = fmt.Sprintf("http://localhost:%s/health", port)
+	}
+
+	client := &http.Client{Timeout: *timeout}
+	resp, err := client.Get(*url)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unhealthy: %v\n", err)
+		return 1
+	}
+	defer resp.Body.Close()
+
+	var report healthResponse
+	json.NewDecoder(resp.Body).Decode(&report)
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "unhealthy: %s returned %d\n", *url, resp.StatusCode)
+		for _, problem := range report.Problems {
+			fmt.Fprintf(os.Stderr, "  %s\n", problem)
+		}
+		return 1
+	}
+	if report.Status == healthDegraded {
+		fmt.Printf("healthy (degraded: %s)\n", strings.Join(report.Problems, "; "))
+		return 0
+	}
+	fmt.Println("healthy")
+	return 0
+}
+
+// checkStateFreshness reports healthy when the last poll cycle finished within maxAge
+func checkStateFreshness(maxAge time.Duration) int {
+	stateDir := os.Getenv("STATE_FILE_DIR")
+	if stateDir == "" {
+		stateDir = "/tmp/case-tracker-states/"
+	}
+
+	last, err := storage.LastHeartbeat(stateDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unhealthy: %v\n", err)
+		return 1
+	}
+
+	age := time.Since(last).Round(time.Second)
+	if age > maxAge {
+		fmt.Fprintf(os.Stderr, "unhealthy: last poll cycle finished %v ago (max %v)\n", age, maxAge)
+		return 1
+	}
+	fmt.Printf("healthy: last poll cycle finished %v ago\n", age)
+	return 0
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/storage"
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// snapshotEntry is one saved state of a case with what changed since the one before it
+type snapshotEntry struct {
+	Time    time.Time              `json:"time"`
+	Status  string                 `json:"status,omitempty"`
+	Changes []uscis.Change         `json:"changes"` // Empty for the first snapshot and unchanged polls
+	Data    map[string]interface{} `json:"data,omitempty"`
+}
+
+// handleCaseHistoryAPI serves /api/cases/{id}/history: every saved snapshot of a case,
+// oldest first, with the diff against the previous one
+// Query parameters: changed=true keeps only the first snapshot and those that changed
+// something, limit=N keeps the newest N, data=false leaves out the raw snapshots
+func (a *app) handleCaseHistoryAPI(w http.ResponseWriter, r *http.Request) {
+	caseID := r.PathValue("id")
+	if !a.canSeeCase(r, caseID) {
+		http.Error(w, "unknown case", http.StatusNotFound)
+		return
+	}
+
+	limit := 0
+	if value := r.FormValue("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	changedOnly := r.FormValue("changed") == "true"
+	withData := r.FormValue("data") != "false"
+
+	snapshots, ok := a.caseSnapshots(w, caseID)
+	if !ok {
+		return
+	}
+
+	entries := []snapshotEntry{}
+	var previous *uscis.CaseStatus
+	options := a.changeOptions()
+	for i, snap := range snapshots {
+		current := uscis.NewCaseStatus(snap.Data)
+		changes := []uscis.Change{}
+		if i > 0 {
+			changes = append(changes, options.Filter(uscis.DetectStatusChanges(previous, current))...)
+		}
+		previous = current
+		if changedOnly && i > 0 && len(changes) == 0 {
+			continue
+		}
+
+		entry := snapshotEntry{Time: snap.Timestamp, Changes: changes}
+		if current != nil {
+			entry.Status = current.StatusTitle
+		}
+		if withData {
+			entry.Data = snap.Data
+		}
+		entries = append(entries, entry)
+	}
+	if limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"case_id":   caseID,
+		"snapshots": entries,
+	})
+}
+
+// fieldPoint is the value a field had from a point in time on
+type fieldPoint struct {
+	Time  time.Time   `json:"time"`
+	Value interface{} `json:"value"` // null while the payload lacks the field
+}
+
+// handleCaseFieldAPI serves /api/cases/{id}/field?name=...: the values one field of a
+// case took over time, read from its saved snapshots, for charting
+// name is a display name ("Status") or a payload path ("actionCodeText"), as in change
+// emails. Only transitions are returned unless all=true; format=grafana returns the
+// series in the shape Grafana's JSON datasource expects
+func (a *app) handleCaseFieldAPI(w http.ResponseWriter, r *http.Request) {
+	caseID := r.PathValue("id")
+	if !a.canSeeCase(r, caseID) {
+		http.Error(w, "unknown case", http.StatusNotFound)
+		return
+	}
+	field := r.FormValue("name")
+	if field == "" {
+		http.Error(w, "missing field name", http.StatusBadRequest)
+		return
+	}
+	everySnapshot := r.FormValue("all") == "true"
+
+	snapshots, ok := a.caseSnapshots(w, caseID)
+	if !ok {
+		return
+	}
+
+	points := []fieldPoint{}
+	for _, snap := range snapshots {
+		value, _ := uscis.NewCaseStatus(snap.Data).Field(field)
+		if !everySnapshot && len(points) > 0 && reflect.DeepEqual(points[len(points)-1].Value, value) {
+			continue
+		}
+		points = append(points, fieldPoint{Time: snap.Timestamp, Value: value})
+	}
+
+	if r.FormValue("format") == "grafana" {
+		datapoints := make([][2]interface{}, 0, len(points))
+		for _, point := range points {
+			datapoints = append(datapoints, [2]interface{}{point.Value, point.Time.UnixMilli()})
+		}
+		writeJSON(w, http.StatusOK, []map[string]interface{}{{
+			"target":     caseID + " " + field,
+			"datapoints": datapoints,
+		}})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"case_id": caseID,
+		"field":   field,
+		"points":  points,
+	})
+}
+
+// caseSnapshots returns the saved snapshots of a case, oldest first
+// On failure it writes the error response and returns false
+func (a *app) caseSnapshots(w http.ResponseWriter, caseID string) ([]storage.Snapshot, bool) {
+	lister, ok := a.caseStorage(caseID).(snapshotLister)
+	if !ok {
+		http.Error(w, "the storage backend keeps no history", http.StatusNotImplemented)
+		return nil, false
+	}
+	snapshots, err := lister.ListSnapshots()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	return snapshots, true
+}
+
+// changeLister is a backend with a change log across cases
+type changeLister interface {
+	ListChanges(since time.Time) ([]storage.ChangeRecord, error)
+}
+
+// changeLog returns the backend's change log, or nil when it keeps none
+func (a *app) changeLog() changeLister {
+	if a.stateDB != nil {
+		return a.stateDB
+	}
+	if a.statePG != nil {
+		return a.statePG
+	}
+	return nil
+}
+
+// handleChangesAPI serves /api/changes: the field changes of every case the user may
+// see, oldest first, from the change log of the sqlite or postgres backend
+// Query parameters: since=YYYY-MM-DD (default a week ago), case=ID for a single case
+func (a *app) handleChangesAPI(w http.ResponseWriter, r *http.Request) {
+	since := time.Now().AddDate(0, 0, -7)
+	if value := r.FormValue("since"); value != "" {
+		t, err := parseSearchDate(value, false)
+		if err != nil {
+			http.Error(w, "invalid since: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		since = t
+	}
+	caseID := r.FormValue("case")
+	if caseID != "" && !a.canSeeCase(r, caseID) {
+		http.Error(w, "unknown case", http.StatusNotFound)
+		return
+	}
+
+	records, err := a.changeLog().ListChanges(since)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	changes := []storage.ChangeRecord{}
+	for _, record := range records {
+		if caseID != "" && record.CaseID != caseID {
+			continue
+		}
+		if !a.canSeeCase(r, record.CaseID) {
+			continue
+		}
+		changes = append(changes, record)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"since":   since,
+		"changes": changes,
+	})
+}
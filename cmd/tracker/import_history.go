@@ -0,0 +1,220 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/config"
+	"github.com/phhowardchen/case-tracker/internal/email"
+	"github.com/phhowardchen/case-tracker/internal/health"
+)
+
+// uscisSender is matched against the From header of archived emails
+// USCIS sends case updates from several addresses, all under uscis.dhs.gov
+const uscisSender = "uscis.dhs.gov"
+
+var (
+	// receiptPattern finds receipt numbers anywhere in an email
+	receiptPattern = regexp.MustCompile(`\b[A-Z]{3}[0-9]{10}\b`)
+	// statusLabelPattern matches an explicitly labelled status, e.g. "Your Current Status: Case Was Received"
+	statusLabelPattern = regexp.MustCompile(`(?i)(?:current|case) status(?: is)?\s*:\s*([^\n.]+)`)
+	// statusPhrasePattern matches USCIS status titles, e.g. "Case Was Approved" or "New Card Is Being Produced"
+	statusPhrasePattern = regexp.MustCompile(`\b(?:Case|Card|New Card|Fingerprint Fee|Interview|Request for (?:Additional )?Evidence|Response To USCIS' Request For Evidence) (?:Was|Is|Were) [A-Z][a-z]+(?: [A-Z][a-z]+)*`)
+)
+
+// importSaver is implemented by storage backends that can record a snapshot at a past time
+type importSaver interface {
+	SaveAt(at time.Time, data map[string]interface{}) error
+}
+
+// historyEntry is a status reconstructed from one archived email
+type historyEntry struct {
+	caseID  string
+	date    time.Time
+	status  string
+	subject string
+}
+
+// runImportHistory implements `tracker import-history`
+// It scans the mailbox for past USCIS notification emails and seeds each case's
+// history with the statuses they mention, so timelines start before the tracker did
+func runImportHistory(args []string) int {
+	fs := flag.NewFlagSet("import-history", flag.ExitOnError)
+	mailbox := fs.String("mailbox", "INBOX", "mailbox to scan (Gmail: \"[Gmail]/All Mail\" includes archived mail)")
+	sinceFlag := fs.String("since", "", "only scan emails received on or after this date (YYYY-MM-DD, default: all)")
+	dryRun := fs.Bool("dry-run", false, "print the reconstructed history without saving it")
+	fs.Parse(args)
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		return 1
+	}
+	if cfg.EmailIMAPServer == "" {
+		fmt.Fprintln(os.Stderr, "EMAIL_IMAP_SERVER, EMAIL_USERNAME and EMAIL_PASSWORD (or EMAIL_OAUTH_*) are required to read the mailbox")
+		return 1
+	}
+
+	var since time.Time
+	if *sinceFlag != "" {
+		since, err = time.ParseInLocation("2006-01-02", *sinceFlag, time.Local)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid -since date %q: %v\n", *sinceFlag, err)
+			return 1
+		}
+	}
+
+	imapClient := newIMAPClient(cfg)
+	messages, err := imapClient.SearchMessages(*mailbox, uscisSender, since)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to search %s: %v\n", *mailbox, err)
+		return 1
+	}
+	fmt.Printf("Found %d email(s) from %s in %s\n", len(messages), uscisSender, *mailbox)
+
+	tracked := make(map[string]bool, len(cfg.CaseIDs))
+	for _, caseID := range cfg.CaseIDs {
+		tracked[caseID] = true
+	}
+	history := make(map[string][]historyEntry)
+	for _, msg := range messages {
+		for _, entry := range parseHistoryEmail(msg) {
+			if tracked[entry.caseID] {
+				history[entry.caseID] = append(history[entry.caseID], entry)
+			}
+		}
+	}
+
+	a, err := newApp(cfg, nil, health.NewTracker("import-history", cfg.CaseIDs))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize: %v\n", err)
+		return 1
+	}
+
+	exitCode := 0
+	for _, caseID := range cfg.CaseIDs {
+		entries := collapseHistory(history[caseID])
+		if len(entries) == 0 {
+			fmt.Printf("\n%s: no status emails found\n", caseID)
+			continue
+		}
+
+		imported, err := a.importCaseHistory(caseID, entries, *dryRun)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "\n%s: %v\n", caseID, err)
+			exitCode = 1
+			continue
+		}
+		fmt.Printf("\n%s: %d milestone(s) from email\n", caseID, len(entries))
+		for _, entry := range entries {
+			fmt.Printf("  %s  %s\n", entry.date.Local().Format("2006-01-02 15:04"), entry.status)
+		}
+		if *dryRun {
+			fmt.Println("  (dry run - nothing saved)")
+		} else {
+			fmt.Printf("  Imported %d, skipped %d already covered by tracked history\n", imported, len(entries)-imported)
+		}
+	}
+	return exitCode
+}
+
+// importCaseHistory saves the entries older than the case's tracked history as snapshots
+// A case must have been polled once first, so the first real poll compares full payloads
+// rather than a reconstructed status and the initial-status email still goes out
+func (a *app) importCaseHistory(caseID string, entries []historyEntry, dryRun bool) (int, error) {
+	store := a.caseStorage(caseID)
+	saver, ok := store.(importSaver)
+	if !ok {
+		return 0, fmt.Errorf("the %s storage backend cannot record past snapshots", a.cfg.StorageBackend)
+	}
+	lister, ok := store.(snapshotLister)
+	if !ok {
+		return 0, fmt.Errorf("the %s storage backend does not keep history", a.cfg.StorageBackend)
+	}
+
+	snapshots, err := lister.ListSnapshots()
+	if err != nil {
+		return 0, fmt.Errorf("failed to load history: %w", err)
+	}
+	if len(snapshots) == 0 {
+		return 0, fmt.Errorf("no tracked state yet - run the tracker once before importing history")
+	}
+	firstTracked := snapshots[0].Timestamp
+
+	imported := 0
+	for _, entry := range entries {
+		if !entry.date.Before(firstTracked) {
+			continue
+		}
+		if dryRun {
+			imported++
+			continue
+		}
+		snapshot := map[string]interface{}{
+			"data": map[string]interface{}{
+				"receiptNumber": caseID,
+				"statusTitle":   entry.status,
+			},
+			"importedFrom": "email",
+			"emailSubject": entry.subject,
+		}
+		if err := saver.SaveAt(entry.date, a.redactor.Apply(snapshot)); err != nil {
+			return imported, fmt.Errorf("failed to save imported snapshot: %w", err)
+		}
+		imported++
+	}
+	return imported, nil
+}
+
+// parseHistoryEmail extracts the case statuses an archived USCIS email reports
+// Emails without a recognizable status (2FA codes, account notices) yield nothing
+func parseHistoryEmail(msg email.Message) []historyEntry {
+	text := msg.Subject + "\n" + msg.Text
+	status := extractEmailStatus(text)
+	if status == "" {
+		return nil
+	}
+
+	var entries []historyEntry
+	seen := make(map[string]bool)
+	for _, caseID := range receiptPattern.FindAllString(text, -1) {
+		if seen[caseID] {
+			continue
+		}
+		seen[caseID] = true
+		entries = append(entries, historyEntry{caseID: caseID, date: msg.Date, status: status, subject: msg.Subject})
+	}
+	return entries
+}
+
+// extractEmailStatus finds the status title in an email, preferring an explicit label
+func extractEmailStatus(text string) string {
+	if m := statusLabelPattern.FindStringSubmatch(text); m != nil {
+		if status := strings.TrimSpace(m[1]); status != "" {
+			return status
+		}
+	}
+	return strings.TrimSpace(statusPhrasePattern.FindString(text))
+}
+
+// collapseHistory sorts entries by date and drops repeats of the same status
+// USCIS often sends several emails (text, email, account notice) for one update
+func collapseHistory(entries []historyEntry) []historyEntry {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].date.Before(entries[j].date)
+	})
+
+	var collapsed []historyEntry
+	for _, entry := range entries {
+		if n := len(collapsed); n > 0 && strings.EqualFold(collapsed[n-1].status, entry.status) {
+			continue
+		}
+		collapsed = append(collapsed, entry)
+	}
+	return collapsed
+}
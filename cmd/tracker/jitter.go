@@ -0,0 +1,46 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+// jittered returns d moved randomly by up to ±fraction of it
+func jittered(d time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 {
+		return d
+	}
+	return d + time.Duration((rand.Float64()*2-1)*fraction*float64(d))
+}
+
+// nextPollDelay returns the wait before the next poll cycle: the poll tick with POLL_JITTER
+// applied, so the cycles don't start on an exact cadence
+func (a *app) nextPollDelay() time.Duration {
+	return jittered(a.cfg.PollTick(), a.cfg.PollJitter)
+}
+
+// requestDelay returns a random pause between two case fetches (REQUEST_DELAY)
+func (a *app) requestDelay() time.Duration {
+	spread := a.cfg.RequestDelayMax - a.cfg.RequestDelayMin
+	if spread <= 0 {
+		return a.cfg.RequestDelayMin
+	}
+	return a.cfg.RequestDelayMin + time.Duration(rand.Int63n(int64(spread)+1))
+}
+
+// pauseBetweenRequests waits a random REQUEST_DELAY before the next case fetch
+// It fails when the tracker shuts down during the wait
+func (a *app) pauseBetweenRequests() error {
+	wait := a.requestDelay()
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-a.ctx.Done():
+		return a.ctx.Err()
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// linkTTL is how long links embedded in emails stay valid
+const linkTTL = 14 * 24 * time.Hour
+
+// linksEnabled reports whether emails may contain action links back to the tracker
+func (a *app) linksEnabled() bool {
+	return a.cfg.PublicURL != "" && a.cfg.LinkSecret != ""
+}
+
+// signedLink builds an expiring, HMAC-signed URL for an action on a case
+// Extra params are covered by the signature so they can't be altered
+func (a *app) signedLink(action, caseID string, params url.Values) string {
+	q := url.Values{}
+	for key, values := range params {
+		q[key] = values
+	}
+	q.Set("case", caseID)
+	q.Set("exp", strconv.FormatInt(time.Now().Add(linkTTL).Unix(), 10))
+	q.Set("sig", a.linkSignature(action, q))
+
+	return strings.TrimRight(a.cfg.PublicURL, "/") + "/link/" + action + "?" + q.Encode()
+}
+
+// verifyLink checks the signature and expiry of a link request and returns its case ID
+func (a *app) verifyLink(action string, r *http.Request) (string, error) {
+	if err := r.ParseForm(); err != nil {
+		return "", err
+	}
+	q := r.Form
+
+	exp, err := strconv.ParseInt(q.Get("exp"), 10, 64)
+	if err != nil {
+		return "", errors.New("invalid link")
+	}
+	if !hmac.Equal([]byte(q.Get("sig")), []byte(a.linkSignature(action, q))) {
+		return "", errors.New("invalid link signature")
+	}
+	if time.Now().After(time.Unix(exp, 0)) {
+		return "", errors.New("link has expired")
+	}
+	return q.Get("case"), nil
+}
+
+// linkSignature signs the action and every parameter except the signature itself
+func (a *app) linkSignature(action string, q url.Values) string {
+	unsigned := url.Values{}
+	for key, values := range q {
+		if key != "sig" {
+			unsigned[key] = values
+		}
+	}
+
+	mac := hmac.New(sha256.New, []byte(a.cfg.LinkSecret))
+	fmt.Fprintf(mac, "%s?%s", action, unsigned.Encode())
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
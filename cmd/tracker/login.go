@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/phhowardchen/case-tracker/internal/config"
+	"github.com/phhowardchen/case-tracker/internal/health"
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// runLogin implements `tracker login`
+// It logs in with the browser, asking for whatever isn't configured (credentials, the
+// 2FA code), and prints a session cookie to use as USCIS_COOKIE
+func runLogin(args []string) int {
+	fs := flag.NewFlagSet("login", flag.ExitOnError)
+	write := fs.String("write", "", "also set USCIS_COOKIE in this env file (e.g. .env)")
+	fresh := fs.Bool("fresh", false, "log in even if a saved browser session is still valid")
+	fs.Parse(args)
+
+	cfg, err := config.LoadPartial()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return exitConfigError
+	}
+	if _, err := prepareChrome(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Logging in needs Chrome: %v\n", err)
+		return exitConfigError
+	}
+
+	// Prompts go to stderr so stdout only carries the cookie
+	p := newPrompter(os.Stdin, os.Stderr)
+	username, password := cfg.USCISUsername, cfg.USCISPassword
+	if username == "" {
+		username = p.required("USCIS username (email)", false)
+	}
+	if password == "" {
+		password = p.required("USCIS password", true)
+	}
+
+	codes, err := newCodeProvider(cfg, nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfigError
+	}
+	switch {
+	case cfg.USCISTOTPSecret != "":
+		fmt.Fprintf(os.Stderr, "Logging in as %s (2FA code from USCIS_TOTP_SECRET)...\n", username)
+	case codes != nil:
+		fmt.Fprintf(os.Stderr, "Logging in as %s (2FA code from %s)...\n", username, cfg.EmailUsername)
+	default:
+		fmt.Fprintf(os.Stderr, "Logging in as %s (you'll be asked for the 2FA code USCIS emails you)...\n", username)
+	}
+	// Opens the state backend, where a failed login is captured too
+	a, err := newApp(cfg, nil, health.NewTracker("login", cfg.CaseIDs))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize: %v\n", err)
+		return exitUnexpected
+	}
+	// Share the daemon's saved session, so its next restart can skip 2FA too
+	var sessions uscis.SessionStore
+	if cfg.PersistBrowserSession && !*fresh {
+		sessions = a.sessionStore()
+	}
+
+	browserClient, err := uscis.NewBrowserClientWithCodes(context.Background(), username, password, codes, sessions)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to log in: %v\n", err)
+		return exitAuthFailure
+	}
+	defer browserClient.Close()
+	cookie, err := browserClient.CookieHeader()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read the session cookie: %v\n", err)
+		return exitUnexpected
+	}
+
+	fmt.Printf("USCIS_COOKIE=%s\n", shellQuote(cookie))
+	if *write != "" {
+		if err := setEnvFileValue(*write, "USCIS_COOKIE", cookie); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitUnexpected
+		}
+		fmt.Fprintf(os.Stderr, "Updated USCIS_COOKIE in %s\n", *write)
+	}
+	return exitOK
+}
+
+// setEnvFileValue sets one variable in an env file, replacing its line or appending one
+// A missing file is created readable by the owner only
+func setEnvFileValue(path, key, value string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	line := key + "=" + shellQuote(value)
+	var lines []string
+	if len(data) > 0 {
+		lines = strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	}
+	replaced := false
+	for i, l := range lines {
+		trimmed := strings.TrimPrefix(strings.TrimSpace(l), "export ")
+		if strings.HasPrefix(trimmed, key+"=") {
+			lines[i] = line
+			replaced = true
+		}
+	}
+	if !replaced {
+		lines = append(lines, line)
+	}
+
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
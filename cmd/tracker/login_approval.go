@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// loginHold tracks an automatic re-login held by LOGIN_HOLD_HOURS
+// It is not persisted: after a restart the next expired session asks again
+type loginHold struct {
+	mu         sync.Mutex
+	heldSince  time.Time // when the pending re-login was first held; zero when none is
+	refreshing bool      // an approved login is running
+}
+
+// loginGate holds automatic re-logins during LOGIN_HOLD_HOURS
+// The first held re-login sends an alert with a link that logs in when the user is ready
+// for the 2FA code; the hold ends by itself when the window does
+func (a *app) loginGate() error {
+	now := time.Now()
+	until, inside := a.cfg.LoginHoldHours.Until(a.cfg.Locale.In(now))
+
+	h := a.loginHold
+	h.mu.Lock()
+	if !inside {
+		h.heldSince = time.Time{}
+		h.mu.Unlock()
+		return nil
+	}
+	first := h.heldSince.IsZero()
+	if first {
+		h.heldSince = now
+	}
+	h.mu.Unlock()
+
+	if first {
+		log.Printf("Session expired during LOGIN_HOLD_HOURS (%s) - holding the new login until %s or until it is approved",
+			a.cfg.LoginHoldHours, a.cfg.Locale.FormatDateTime(until))
+		a.sendLoginApprovalRequest(until)
+	}
+	return &uscis.ErrLoginHeld{Until: until}
+}
+
+// loginHeld reports whether an automatic re-login is waiting for approval
+func (a *app) loginHeld() bool {
+	if a.loginHold == nil {
+		return false
+	}
+	a.loginHold.mu.Lock()
+	defer a.loginHold.mu.Unlock()
+	return !a.loginHold.heldSince.IsZero()
+}
+
+// sendLoginApprovalRequest tells the user a re-login is held, with the link that starts it
+func (a *app) sendLoginApprovalRequest(until time.Time) {
+	loc := a.recipientLocale()
+	link := a.signedLink("login", "", nil)
+	codeSource := "USCIS sends a verification code to your email or phone; enter it with <code>tracker login</code> on the server."
+	switch {
+	case a.cfg.USCISTOTPSecret != "":
+		codeSource = "The tracker computes the verification code from your authenticator secret."
+	case a.smsCodes != nil:
+		codeSource = "USCIS texts a verification code to your phone; the tracker receives it through Twilio."
+	case a.cfg.EmailIMAPServer != "":
+		codeSource = "USCIS sends a verification code to your email; the tracker reads it from " + template.HTMLEscapeString(a.cfg.EmailUsername) + "."
+	}
+
+	subject := a.cfg.BrandName + " - USCIS Login Waiting for You"
+	body := fmt.Sprintf(`
+		<h2>USCIS Login Waiting for You</h2>
+		<p>The USCIS session of the tracker on <strong>%s</strong> expired. Logging in again needs a 2FA code, and it is currently inside LOGIN_HOLD_HOURS (%s), so the login is on hold. Cases aren't checked until then.</p>
+		<p>When you're ready for the code, <a href="%s">log in now</a>. %s</p>
+		<p>Otherwise the tracker logs in by itself at %s.</p>
+	`, template.HTMLEscapeString(a.hostname), a.cfg.LoginHoldHours, template.HTMLEscapeString(link), codeSource, loc.FormatDateTime(until))
+
+	if err := a.sendAlert(nil, subject, body); err != nil {
+		log.Printf("Failed to send login approval request: %v", err)
+	}
+}
+
+var loginConfirmTemplate = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>Log in to USCIS</title></head>
+<body style="font-family: -apple-system, Helvetica, Arial, sans-serif; max-width: 640px; margin: 2em auto; padding: 0 1em;">
+{{if .Started}}
+<h2>Logging in</h2>
+<p>The tracker is logging in to USCIS now. Watch for the verification code; polling resumes once the login succeeds.</p>
+{{else if .Running}}
+<h2>Already logging in</h2>
+<p>A login started from this link is still running. Watch for the verification code.</p>
+{{else if not .Held}}
+<h2>Nothing to approve</h2>
+<p>No login is waiting: the session is fine or the tracker already logged in again.</p>
+{{else}}
+<h2>Log in to USCIS now?</h2>
+<p>USCIS will send a verification code right away. Only continue when you can receive it.</p>
+<form method="post"><button type="submit">Log in now</button></form>
+{{end}}
+</body>
+</html>
+`))
+
+// handleLoginLink serves /link/login from login approval alerts
+// GET only shows a confirmation page so link scanners can't start a login by prefetching it
+func (a *app) handleLoginLink(w http.ResponseWriter, r *http.Request) {
+	if _, err := a.verifyLink("login", r); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	h := a.loginHold
+	h.mu.Lock()
+	view := map[string]interface{}{"Held": !h.heldSince.IsZero(), "Running": h.refreshing}
+	start := r.Method == http.MethodPost && !h.heldSince.IsZero() && !h.refreshing
+	if start {
+		h.refreshing = true
+		view["Started"] = true
+	}
+	h.mu.Unlock()
+
+	if start {
+		// The login waits for the 2FA code, far longer than the request should
+		go a.approvedLogin()
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	loginConfirmTemplate.Execute(w, view)
+}
+
+// approvedLogin runs the login the user approved through the link
+func (a *app) approvedLogin() {
+	log.Printf("Held login approved via link - logging in to USCIS")
+	refreshed, err := a.toggles.refreshSessions()
+
+	h := a.loginHold
+	h.mu.Lock()
+	h.refreshing = false
+	if err == nil {
+		h.heldSince = time.Time{}
+	}
+	h.mu.Unlock()
+
+	if err != nil {
+		log.Printf("Approved login failed: %v", err)
+		a.sendAuthFailureEmail(err, "approved login")
+		return
+	}
+	log.Printf("Approved login succeeded (%s) - polling resumes with the next cycle", strings.Join(refreshed, ", "))
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/storage"
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// loginBackoffDelays are the waits after the first, second and third failed startup login
+// in a row; later failures wait as long as the last
+var loginBackoffDelays = []time.Duration{30 * time.Minute, 2 * time.Hour, 6 * time.Hour}
+
+// loginBackoffHeartbeat is how often the heartbeat is written while a login waits, so
+// `tracker healthcheck --state-max-age` doesn't restart the container meanwhile
+const loginBackoffHeartbeat = 5 * time.Minute
+
+// errRejectedBefore is returned for credentials USCIS rejected in an earlier run, which
+// already sent the alert
+var errRejectedBefore = errors.New("USCIS rejected these credentials in an earlier run")
+
+// loginBackoff retries the startup browser login instead of exiting, so a USCIS outage,
+// a firewall block or a missed 2FA code doesn't make Cloud Run or Docker restart the
+// tracker into another login right away
+// The state is saved in STATE_FILE_DIR and tied to the credentials: a restart waits out
+// the backoff, while new credentials are tried at once
+// Each additional USCIS account has a backoff of its own
+type loginBackoff struct {
+	store       *storage.LoginBackoffStore
+	account     string // name of the additional account, "" for USCIS_USERNAME
+	credentials string // fingerprint of the username and password
+
+	mu    sync.Mutex
+	state *storage.LoginBackoff // nil when the last login succeeded
+}
+
+// newLoginBackoff loads the backoff of earlier runs with the same credentials, of the
+// main account or, when account is set, of that additional account
+func newLoginBackoff(stateDir, account, username, password string) *loginBackoff {
+	store := storage.NewLoginBackoffStore(stateDir)
+	if account != "" {
+		store = storage.NewAccountLoginBackoffStore(stateDir, account)
+	}
+	b := &loginBackoff{
+		store:       store,
+		account:     account,
+		credentials: credentialsFingerprint(stateDir, username, password),
+	}
+	state, err := b.store.Load()
+	switch {
+	case err != nil:
+		log.Printf("Warning: %v; logging in without waiting", err)
+	case state != nil && state.Credentials == legacyCredentialsFingerprint(username, password):
+		// Saved by an earlier version: keep the backoff, under the keyed fingerprint
+		state.Credentials = b.credentials
+		if err := b.store.Save(state); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		b.state = state
+	case state != nil && state.Credentials != b.credentials:
+		log.Printf("%s: credentials changed since the last failed login; logging in without waiting", b.what())
+	default:
+		b.state = state
+	}
+	return b
+}
+
+// credentialsFingerprint identifies a username and password in the saved backoff with an
+// HMAC under the install key, so the file can't be used to check guessed passwords
+// Without a key only the username is fingerprinted, and a new password isn't noticed
+func credentialsFingerprint(stateDir, username, password string) string {
+	key, err := storage.LoadOrCreateInstallKey(stateDir)
+	if err != nil {
+		log.Printf("Warning: %v; the login backoff won't notice a changed password", err)
+		sum := sha256.Sum256([]byte(username))
+		return "user:" + hex.EncodeToString(sum[:8])
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(username + "\x00" + password))
+	return "hmac:" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// legacyCredentialsFingerprint is the unkeyed fingerprint earlier versions saved; it is
+// only compared with, never saved
+func legacyCredentialsFingerprint(username, password string) string {
+	sum := sha256.Sum256([]byte(username + "\x00" + password))
+	return hex.EncodeToString(sum[:8])
+}
+
+// what names the login in logs and alerts
+func (b *loginBackoff) what() string {
+	if b.account == "" {
+		return "USCIS login"
+	}
+	return fmt.Sprintf("USCIS login of account %q", b.account)
+}
+
+// delay returns the wait after the given number of failed logins in a row
+func (b *loginBackoff) delay(failures int) time.Duration {
+	return loginBackoffDelays[min(failures, len(loginBackoffDelays))-1]
+}
+
+// rejected returns the saved rejection of the current credentials, nil if there is none
+func (b *loginBackoff) rejected() *storage.LoginBackoff {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == nil || !b.state.Rejected {
+		return nil
+	}
+	return b.state
+}
+
+// pending returns the backoff if the next login must wait past now, nil otherwise
+func (b *loginBackoff) pending(now time.Time) *storage.LoginBackoff {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == nil || !now.Before(b.state.NextAttempt) {
+		return nil
+	}
+	return b.state
+}
+
+// failed records a failed login and returns when the next attempt may be made
+func (b *loginBackoff) failed(err error, now time.Time) time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	failures := 1
+	if b.state != nil {
+		failures = b.state.Failures + 1
+	}
+	b.state = &storage.LoginBackoff{
+		Failures:    failures,
+		LastFailure: now,
+		NextAttempt: now.Add(b.delay(failures)),
+		LastError:   err.Error(),
+		Credentials: b.credentials,
+		Rejected:    uscis.IsBadCredentials(err),
+	}
+	if saveErr := b.store.Save(b.state); saveErr != nil {
+		log.Printf("Warning: %v", saveErr)
+	}
+	return b.state.NextAttempt
+}
+
+// succeeded forgets the failed logins
+func (b *loginBackoff) succeeded() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == nil {
+		return
+	}
+	b.state = nil
+	if err := b.store.Clear(); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// loginProblems describes the startup logins waiting to be retried, for /health
+func (a *app) loginProblems() []string {
+	a.loginMu.Lock()
+	defer a.loginMu.Unlock()
+	var problems []string
+	for _, b := range a.loginBackoffs {
+		if problem := b.problem(); problem != "" {
+			problems = append(problems, problem)
+		}
+	}
+	return problems
+}
+
+// problem describes a login waiting to be retried for /health, "" if none is
+func (b *loginBackoff) problem() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == nil || b.state.Rejected {
+		return ""
+	}
+	return fmt.Sprintf("%s failed %d time(s) in a row, retrying after %s (last error: %s)",
+		b.what(), b.state.Failures, b.state.NextAttempt.Local().Format("2006-01-02 15:04"), b.state.LastError)
+}
+
+// loginWithBackoff runs the startup browser login until it succeeds, waiting 30m, 2h
+// and then 6h between failed attempts; a restart first waits out what is left of the wait
+// Only a login USCIS rejected for its credentials is returned as an error, since trying
+// again can only lock the account; a restart with the same credentials doesn't try them
+// Returns ctx's error if it ends first
+func (a *app) loginWithBackoff(ctx context.Context, b *loginBackoff, login func() (*uscis.BrowserClient, error)) (*uscis.BrowserClient, error) {
+	a.loginMu.Lock()
+	a.loginBackoffs = append(a.loginBackoffs, b)
+	a.loginMu.Unlock()
+	if rejected := b.rejected(); rejected != nil {
+		return nil, fmt.Errorf("%w (%s): %s", errRejectedBefore, a.cfg.Locale.FormatDateTime(rejected.LastFailure), rejected.LastError)
+	}
+	for {
+		if err := a.waitForLogin(ctx, b); err != nil {
+			return nil, err
+		}
+		client, err := login()
+		if err == nil {
+			b.succeeded()
+			return client, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		next := b.failed(err, time.Now())
+		if uscis.IsBadCredentials(err) {
+			return nil, err
+		}
+		log.Printf("ERROR: Browser login failed: %v", err)
+		log.Printf("Trying again after %s instead of exiting, so the login isn't repeated right away", a.cfg.Locale.FormatDateTime(next))
+		during := "browser initialization"
+		if b.account != "" {
+			during += fmt.Sprintf(" of USCIS account %q", b.account)
+		}
+		a.sendAuthFailureEmail(err, during+" (trying again after "+a.cfg.Locale.FormatDateTime(next)+")")
+	}
+}
+
+// waitForLogin blocks until the backoff allows the next login and polling isn't paused,
+// writing the heartbeat meanwhile
+func (a *app) waitForLogin(ctx context.Context, b *loginBackoff) error {
+	waitingFor := ""
+	for {
+		now := time.Now()
+		wait := loginBackoffHeartbeat
+		reason := ""
+		if state := b.pending(now); state != nil {
+			wait = min(wait, state.NextAttempt.Sub(now))
+			reason = fmt.Sprintf("%s failed %d time(s) in a row; waiting until %s before the next attempt",
+				b.what(), state.Failures, a.cfg.Locale.FormatDateTime(state.NextAttempt))
+		} else if a.toggles.isPaused() {
+			reason = "Polling is paused via the admin API - holding the USCIS login"
+		}
+		if reason == "" {
+			return nil
+		}
+		if reason != waitingFor {
+			log.Print(reason)
+			waitingFor = reason
+		}
+
+		if err := storage.WriteHeartbeat(a.cfg.StateFileDir, now); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import "log"
+
+// loginRefused alerts about a login USCIS refused (a locked account, a firewall block)
+// once, however many cases fail on it, until a fetch authenticates again
+// After a lock the client doesn't log in again on its own until the session is refreshed
+// explicitly (/api/admin/refresh-session) or the tracker restarts
+func (a *app) loginRefused(err error) {
+	a.refusalMu.Lock()
+	alerted := a.refusalAlerted
+	a.refusalAlerted = true
+	a.refusalMu.Unlock()
+
+	if alerted {
+		a.health.RecordAuthFailure(err)
+		log.Printf("Login refused by USCIS, already alerted: %v", err)
+		return
+	}
+	log.Printf("Login refused by USCIS! Sending email notification...")
+	a.sendAuthFailureEmail(err, "polling")
+}
+
+// loginAccepted ends the refused login alerted about, so the next refusal is alerted again
+func (a *app) loginAccepted() {
+	a.refusalMu.Lock()
+	defer a.refusalMu.Unlock()
+	a.refusalAlerted = false
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/config"
+	"github.com/phhowardchen/case-tracker/internal/logging"
+	"github.com/phhowardchen/case-tracker/internal/storage"
+)
+
+// maxLogEvents caps how many events one /api/logs response returns
+const maxLogEvents = 1000
+
+// eventsKeepAlive is how often an idle event stream gets a comment, so proxies
+// such as Cloud Run's front end don't close it
+const eventsKeepAlive = 25 * time.Second
+
+// newLogScrubber masks credentials, session cookies, 2FA codes and REDACT_FIELDS values
+// in a log line
+// Credentials are read from the environment on every line; those a CONFIG_FILE or
+// Secret Manager provides are registered when the configuration is loaded. Without a
+// configuration yet, REDACT_FIELDS isn't applied
+func newLogScrubber(cfg *config.Config) func(string) string {
+	var redactor *storage.Redactor
+	if cfg != nil {
+		redactor = storage.NewRedactor(cfg.RedactFields, cfg.RedactHashKey)
+	}
+	return func(line string) string {
+		return redactor.RedactText(logging.Scrub(line, config.SecretValues()...))
+	}
+}
+
+// logOutput masks secrets in the log lines written to out
+// DEBUG_UNSAFE_LOGS=true writes them as they are, for debugging a login locally; lines
+// served by the log API are masked either way
+func logOutput(out io.Writer, cfg *config.Config) io.Writer {
+	unsafe := config.UnsafeLogs()
+	if cfg != nil {
+		unsafe = cfg.UnsafeLogs
+	}
+	if unsafe {
+		return out
+	}
+	return logging.NewScrubber(out, newLogScrubber(cfg))
+}
+
+// handleLogsAPI serves recent log events as JSON
+// Query parameters: since (last seen seq), case (case ID) and limit
+func (a *app) handleLogsAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	since, err := parseSeq(r.FormValue("since"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit := maxLogEvents
+	if value := r.FormValue("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(limit, maxLogEvents)
+	}
+
+	events := filterLogEvents(a.logs.Since(since), r.FormValue("case"))
+	if len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"events": events})
+}
+
+// handleEvents streams log events as server-sent events
+// A reconnecting client resumes after the Last-Event-ID it received
+func (a *app) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.FormValue("since")
+	}
+	since, err := parseSeq(lastID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	caseID := r.FormValue("case")
+
+	// Subscribe before reading the backlog so no event falls in between
+	events, unsubscribe := a.logs.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// Events that arrived while the backlog was read are in both; skip them once
+	var lastSent uint64
+	for _, event := range filterLogEvents(a.logs.Since(since), caseID) {
+		writeLogEvent(w, event)
+		lastSent = event.Seq
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.Seq <= lastSent || (caseID != "" && event.CaseID != caseID) {
+				continue
+			}
+			writeLogEvent(w, event)
+			flusher.Flush()
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// writeLogEvent writes one event in the server-sent events format
+func writeLogEvent(w http.ResponseWriter, event logging.Event) {
+	data, _ := json.Marshal(event)
+	fmt.Fprintf(w, "id: %d\nevent: log\ndata: %s\n\n", event.Seq, data)
+}
+
+// filterLogEvents keeps the events of one case, or all events when caseID is empty
+func filterLogEvents(events []logging.Event, caseID string) []logging.Event {
+	if caseID == "" {
+		return events
+	}
+	filtered := events[:0]
+	for _, event := range events {
+		if event.CaseID == caseID {
+			filtered = append(filtered, event)
+		}
+	}
+	return filtered
+}
+
+// parseSeq parses an event sequence number; empty means from the start
+func parseSeq(value string) (uint64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	seq, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid event id %q", value)
+	}
+	return seq, nil
+}
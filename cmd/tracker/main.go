@@ -1,292 +1,951 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"flag"
 	"fmt"
+	"html"
+	"html/template"
+	"io"
 	"log"
-	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/phhowardchen/case-tracker/internal/archive"
 	"github.com/phhowardchen/case-tracker/internal/config"
-	"github.com/phhowardchen/case-tracker/internal/email"
+	"github.com/phhowardchen/case-tracker/internal/health"
+	"github.com/phhowardchen/case-tracker/internal/locale"
+	"github.com/phhowardchen/case-tracker/internal/logging"
 	"github.com/phhowardchen/case-tracker/internal/notifier"
+	"github.com/phhowardchen/case-tracker/internal/notifier/templates"
+	"github.com/phhowardchen/case-tracker/internal/s3"
+	"github.com/phhowardchen/case-tracker/internal/source"
 	"github.com/phhowardchen/case-tracker/internal/storage"
 	"github.com/phhowardchen/case-tracker/internal/uscis"
 )
 
-// CaseStatusFetcher is an interface for fetching case status
-// Implemented by both Client (HTTP) and BrowserClient (chromedp)
-type CaseStatusFetcher interface {
-	FetchCaseStatus(caseID string) (map[string]interface{}, error)
+// app holds the long-lived dependencies shared by every poll
+type app struct {
+	ctx         context.Context // cancelled on SIGINT/SIGTERM: no new fetches, in-flight ones abort
+	cfg         *config.Config
+	sources     *source.Registry // routes each case to the fetcher of its source
+	watchdog    *fetchWatchdog
+	breaker     *fetchBreaker
+	notifier    notifier.Notifier
+	health      *health.Tracker
+	metrics     *trackerMetrics
+	deadLetters *storage.DeadLetterStore
+	outbox      *storage.OutboxStore
+	snoozes     *storage.SnoozeStore
+	acks        *storage.AckStore
+	push        *storage.PushStore   // web push subscriptions when WEB_PUSH is on, nil otherwise
+	vapidKey    *ecdsa.PrivateKey    // signs web push requests
+	notices     *storage.NoticeStore // downloaded notice PDFs when NOTICE_PDFS is set, nil otherwise
+	users       *storage.UserStore   // dashboard and API users; none means no access control
+	scheduler   *pollScheduler
+	requests    *storage.RequestCounter // USCIS requests made today, for DAILY_REQUEST_BUDGET
+	deliveries  *storage.DeliveryLog
+	stateDB     *storage.SQLiteDB   // shared database when STORAGE_BACKEND=sqlite, nil otherwise
+	stateBucket *storage.S3Bucket   // state bucket when STORAGE_BACKEND=s3, nil otherwise
+	statePG     *storage.PostgresDB // shared database when STORAGE_BACKEND=postgres, nil otherwise
+	redactor    *storage.Redactor   // strips PII from snapshots before they are saved
+	instanceID  string
+	hostname    string
+	warnedOwner map[string]bool   // cases already alerted about a foreign writer
+	report      *runReport        // outcome summary in run-once mode, nil otherwise
+	logs        *logging.Buffer   // recent log lines for the log API, nil when disabled
+	toggles     *runtimeToggles   // switches flipped through the admin API
+	loginHold   *loginHold        // automatic re-login waiting for approval, for LOGIN_HOLD_HOURS
+	canary      *canaryMonitor    // whether the canary (CANARY_INTERVAL) is delivered on schedule
+	pollLock    *pollLock         // lease electing the polling instance (POLL_LOCK), nil without one
+	triggers    chan *pollTrigger // on-demand polls from /api/trigger, run by the main loop
+
+	casesMu sync.RWMutex // guards cfg.CaseIDs, which case discovery extends; see caseIDs
+	lister  caseLister   // lists the account's cases for CASE_IDS=auto, nil otherwise
+
+	loginMu       sync.Mutex
+	loginBackoffs []*loginBackoff // retried startup logins of the daemon in auto-login mode, one per account
+
+	refusalMu      sync.Mutex
+	refusalAlerted bool // a refused login (locked account, firewall block) was alerted about; see loginRefused
+
+	savedMu sync.Mutex
+	saved   map[string]map[string]interface{} // last saved status of each case before redaction; see detectChanges
+
+	sessionRefresh *sessionSchedule // scheduled re-login (SESSION_REFRESH_INTERVAL), nil without one
+	smsCodes       *uscis.SMSCodes  // 2FA codes texted to the Twilio number, nil unless TWILIO_AUTH_TOKEN is set
+
+	emailTemplates *templates.Renderer // case notification emails, with EMAIL_TEMPLATE_DIR replacements
+
+	acceptAnomalies bool // skip the anomaly checks, for payloads an operator vouched for
+
+	bootstrapMu        sync.Mutex
+	lastBootstrapFetch time.Time // when the latest first fetch of a case without saved state is scheduled
 }
 
+// newApp wires the shared dependencies used by the daemon and one-shot commands
+func newApp(cfg *config.Config, sources *source.Registry, healthTracker *health.Tracker) (*app, error) {
+	hostname, _ := os.Hostname()
+
+	instanceID := cfg.InstanceID
+	if instanceID == "" {
+		var err error
+		instanceID, err = storage.LoadOrCreateInstanceID(cfg.StateFileDir)
+		if err != nil {
+			log.Printf("Warning: Failed to persist instance ID, using hostname: %v", err)
+			instanceID = hostname
+		}
+	}
+
+	a := &app{
+		ctx:         context.Background(),
+		cfg:         cfg,
+		sources:     sources,
+		watchdog:    newFetchWatchdog(cfg.BrowserRecycleAfter),
+		breaker:     newFetchBreaker(cfg.BreakerFailures, cfg.BreakerGlobalFailures, cfg.BreakerCooldown),
+		health:      healthTracker,
+		metrics:     newTrackerMetrics(),
+		deadLetters: storage.NewDeadLetterStore(cfg.StateFileDir),
+		outbox:      storage.NewOutboxStore(cfg.StateFileDir),
+		snoozes:     storage.NewSnoozeStore(cfg.StateFileDir),
+		acks:        storage.NewAckStore(cfg.StateFileDir),
+		users:       storage.NewUserStore(cfg.StateFileDir),
+		scheduler:   newPollScheduler(cfg.CaseIDs, cfg.PollIntervalFor, cfg.PollCycleBudget, cfg.PollFairness),
+		requests:    storage.NewRequestCounter(cfg.StateFileDir),
+		deliveries:  storage.NewDeliveryLog(cfg.StateFileDir),
+		redactor:    storage.NewRedactor(cfg.RedactFields, cfg.RedactHashKey),
+		instanceID:  instanceID,
+		hostname:    hostname,
+		warnedOwner: make(map[string]bool),
+		saved:       make(map[string]map[string]interface{}),
+		toggles:     &runtimeToggles{},
+		loginHold:   &loginHold{},
+		canary:      &canaryMonitor{},
+		triggers:    make(chan *pollTrigger),
+	}
+	if cfg.StorageBackend == "sqlite" {
+		db, err := storage.OpenSQLite(cfg.SQLitePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open state database: %w", err)
+		}
+		a.stateDB = db
+		if err := a.importFileState(); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to import state files into the state database: %w", err)
+		}
+	}
+	if cfg.StorageBackend == "s3" {
+		bucket, err := openStateBucket(cfg)
+		if err != nil {
+			return nil, err
+		}
+		a.stateBucket = bucket
+	}
+	if cfg.StorageBackend == "postgres" {
+		db, err := storage.OpenPostgres(cfg.PostgresURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open state database: %w", err)
+		}
+		// Every command reads the tables, so the schema is brought up to date right away
+		applied, err := db.Migrate()
+		for _, m := range applied {
+			log.Printf("Migrated state database to schema %d (%s)", m.Version, m.Name)
+		}
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to migrate state database: %w", err)
+		}
+		a.statePG = db
+	}
+	if cfg.WebPush {
+		a.push = storage.NewPushStore(cfg.StateFileDir)
+		key, err := a.loadVAPIDKey()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load web push key: %w", err)
+		}
+		a.vapidKey = key
+	}
+	if cfg.NoticePDFs != "" {
+		a.notices = storage.NewNoticeStore(cfg.StateFileDir)
+	}
+	if cfg.LoginCaptures != "" {
+		uscis.SetCaptureStore(a.captureStore())
+	}
+	renderer, err := templates.New(cfg.EmailTemplateDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load email templates: %w", err)
+	}
+	a.emailTemplates = renderer
+	a.notifier = a.newNotifier()
+	return a, nil
+}
+
+// caseStorage returns the state storage of one case in the configured backend
+func (a *app) caseStorage(caseID string) storage.Storage {
+	if a.stateDB != nil {
+		return a.stateDB.ForCase(caseID)
+	}
+	if a.stateBucket != nil {
+		return a.stateBucket.ForCase(caseID)
+	}
+	if a.statePG != nil {
+		return a.statePG.ForCase(caseID)
+	}
+	return storage.NewFileStorage(a.cfg.CaseStateDir(caseID), caseID)
+}
+
+// sessionStore returns where saved browser sessions are kept in the configured backend
+func (a *app) sessionStore() uscis.SessionStore {
+	if a.stateDB != nil {
+		return a.stateDB
+	}
+	if a.stateBucket != nil {
+		return a.stateBucket
+	}
+	if a.statePG != nil {
+		return a.statePG
+	}
+	return storage.NewSessionStore(a.cfg.StateFileDir)
+}
+
+// captureStore returns where failed login captures are kept in the configured backend
+func (a *app) captureStore() uscis.CaptureStore {
+	if a.stateDB != nil {
+		return a.stateDB
+	}
+	if a.stateBucket != nil {
+		return a.stateBucket
+	}
+	if a.statePG != nil {
+		return a.statePG
+	}
+	return storage.NewLoginCaptureStore(a.cfg.StateFileDir)
+}
+
+// openStateBucket opens the S3 bucket of STORAGE_BACKEND=s3
+func openStateBucket(cfg *config.Config) (*storage.S3Bucket, error) {
+	client, err := s3.New(s3.Config{
+		Bucket:          cfg.S3Bucket,
+		Region:          cfg.S3Region,
+		Endpoint:        cfg.S3Endpoint,
+		PathStyle:       cfg.S3PathStyle,
+		AccessKeyID:     cfg.S3AccessKeyID,
+		SecretAccessKey: cfg.S3SecretAccessKey,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state bucket: %w", err)
+	}
+	return storage.OpenS3(client, cfg.S3Prefix), nil
+}
+
+// usage lists the subcommands
+const usage = `usage: tracker [command] [flags]
+
+Commands:
+  run             poll the cases (the default without a command); -once polls a single time and exits
+  check <caseID>  fetch one case, diff it against the saved state and print the result
+  login           log in with the browser and print a session cookie for USCIS_COOKIE
+  email-auth      grant OAuth2 access to the 2FA mailbox and print EMAIL_OAUTH_REFRESH_TOKEN
+  notify-test     send a test email (-all: every configured channel)
+  init            write a configuration interactively
+  selftest        check every configured component
+  healthcheck     exit 0 if the running tracker is healthy
+  search          search status changes, notices and notes
+  note            attach a searchable note to a case
+  report          export a case timeline as PDF or text (-case ID)
+  import-history  seed history from USCIS emails in your mailbox
+  archive         move old history to cold storage, list or restore it
+  migrate         upgrade stored state to this version (-status: only show what is pending)
+  users           manage who may sign in to the dashboard and API
+  deadletter      inspect responses that couldn't be parsed
+  support-bundle  collect redacted diagnostics
+
+Run "tracker <command> -h" for the flags of a command.
+`
+
 func main() {
-	log.Printf("USCIS Case Tracker starting...")
+	// Commands log before (or without) loading the configuration; mask secrets from the start
+	log.SetOutput(logOutput(os.Stderr, nil))
+
+	// Subcommands run instead of the polling daemon
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "run":
+			os.Exit(runTracker(os.Args[2:]))
+		case "check":
+			os.Exit(runCheck(os.Args[2:]))
+		case "login":
+			os.Exit(runLogin(os.Args[2:]))
+		case "notify-test":
+			os.Exit(runNotifyTest(os.Args[2:]))
+		case "support-bundle":
+			os.Exit(runSupportBundle(os.Args[2:]))
+		case "deadletter":
+			os.Exit(runDeadLetter(os.Args[2:]))
+		case "healthcheck":
+			os.Exit(runHealthcheck(os.Args[2:]))
+		case "selftest":
+			os.Exit(runSelftest(os.Args[2:]))
+		case "init":
+			os.Exit(runInit(os.Args[2:]))
+		case "import-history":
+			os.Exit(runImportHistory(os.Args[2:]))
+		case "search":
+			os.Exit(runSearch(os.Args[2:]))
+		case "note":
+			os.Exit(runNote(os.Args[2:]))
+		case "archive":
+			os.Exit(runArchive(os.Args[2:]))
+		case "report":
+			os.Exit(runCaseReport(os.Args[2:]))
+		case "users":
+			os.Exit(runUsers(os.Args[2:]))
+		case "migrate":
+			os.Exit(runMigrate(os.Args[2:]))
+		case "email-auth":
+			os.Exit(runEmailAuth(os.Args[2:]))
+		case "help", "-h", "-help", "--help":
+			fmt.Print(usage)
+			os.Exit(exitOK)
+		default:
+			// Flags without a command are flags of `run`
+			if strings.HasPrefix(os.Args[1], "-") {
+				os.Exit(runTracker(os.Args[1:]))
+			}
+			fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+			os.Exit(2)
+		}
+	}
+
+	os.Exit(runTracker(nil))
+}
+
+// runTracker starts the polling daemon, or polls once with RUN_ONCE=true or -once
+// It returns the process exit code
+func runTracker(args []string) (code int) {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	once := fs.Bool("once", false, "poll every case a single time and exit (same as RUN_ONCE=true)")
+	resultFile := fs.String("result-file", "", "write the run-once JSON summary here, - for stdout (same as RESULT_FILE)")
+	fs.Parse(args)
+
+	log.Printf("USCIS Case Tracker %s starting...", versionString())
 
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+		log.Printf("Failed to load configuration: %v", err)
+		if *once || config.RunOnceEnv() {
+			path := *resultFile
+			if path == "" {
+				path = os.Getenv("RESULT_FILE")
+			}
+			return newRunReport("", nil).finish(path, exitConfigError, err)
+		}
+		return exitConfigError
+	}
+	if *once {
+		cfg.RunOnce = true
+	}
+	if *resultFile != "" {
+		cfg.ResultFile = *resultFile
+	}
+	// In run-once mode the result file is written whichever way the run ends; the paths
+	// that know more about the failure write it first
+	var report *runReport
+	if cfg.RunOnce {
+		report = newRunReport("", cfg.CaseIDs)
+		defer func() { report.finish(cfg.ResultFile, code, nil) }()
+	}
+
+	// A signal cancels logins, 2FA waits and fetches in flight; results already fetched
+	// are still saved and notified before exiting
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Mirror logs to a rotating file for self-hosted runs
+	logWriters := []io.Writer{os.Stderr}
+	if cfg.LogFile != "" {
+		logFile, err := logging.NewRotatingFile(cfg.LogFile, logging.RotateOptions{
+			MaxSize:    int64(cfg.LogMaxSizeMB) << 20,
+			Interval:   cfg.LogRotateInterval,
+			MaxBackups: cfg.LogMaxBackups,
+			MaxAge:     cfg.LogMaxAge,
+		})
+		if err != nil {
+			log.Printf("Failed to open log file: %v", err)
+			return report.finish(cfg.ResultFile, exitConfigError, err)
+		}
+		defer logFile.Close()
+		logWriters = append(logWriters, logFile)
+	}
+	// Keep recent lines for the log API, masked before they are stored
+	var logBuffer *logging.Buffer
+	if cfg.LogBufferSize > 0 {
+		logBuffer = logging.NewBuffer(cfg.LogBufferSize, newLogScrubber(cfg))
+		logWriters = append(logWriters, logBuffer)
+	}
+	// The admin API can raise the level at runtime
+	logFilter := logging.NewLevelFilter(logOutput(io.MultiWriter(logWriters...), cfg))
+	var logOut io.Writer = logFilter
+	if len(cfg.CaseNicknames) > 0 {
+		// Receipt numbers alone are hard to tell apart
+		logOut = logging.NewScrubber(logFilter, logging.LabelCases(cfg.CaseNicknames))
+	}
+	log.SetOutput(logOut)
+	if cfg.UnsafeLogs {
+		log.Printf("WARNING: DEBUG_UNSAFE_LOGS is set - passwords, session cookies and 2FA codes may appear in the logs")
+	}
+	if cfg.LogFile != "" {
+		log.Printf("Logging to %s (max %dMB, %d backups)", cfg.LogFile, cfg.LogMaxSizeMB, cfg.LogMaxBackups)
 	}
 
 	log.Printf("Configuration loaded successfully")
 	log.Printf("  Case IDs: %v", cfg.CaseIDs)
-	log.Printf("  Recipient: %s", cfg.RecipientEmail)
-	log.Printf("  Poll Interval: %v", cfg.PollInterval)
+	if cfg.CaseDiscovery {
+		log.Printf("  Case discovery: every case on the USCIS account (CASE_IDS=auto)")
+	}
+	log.Printf("  Recipient: %s", strings.Join(cfg.RecipientEmails, ", "))
+	if len(cfg.EmailCC) > 0 || len(cfg.EmailBCC) > 0 {
+		log.Printf("  Copies: cc %v, bcc %v", cfg.EmailCC, cfg.EmailBCC)
+	}
+	for _, caseID := range cfg.CaseIDs {
+		if recipients, ok := cfg.CaseRecipients[caseID]; ok {
+			log.Printf("    %s -> %s", caseID, strings.Join(recipients, ", "))
+		}
+	}
+	for _, tenant := range cfg.Tenants {
+		log.Printf("  Tenant %s: %v in %s", tenant.Name, tenant.CaseIDs, cfg.CaseStateDir(tenant.CaseIDs[0]))
+	}
+	log.Printf("  Poll Interval: %v (cycle budget %v, %s, %d worker(s))", cfg.PollInterval, cfg.PollCycleBudget, cfg.PollFairness, cfg.PollWorkers)
+	if cfg.PollJitter > 0 || cfg.RequestDelayMax > 0 {
+		log.Printf("  Poll Jitter: ±%.0f%%, %v-%v between requests", cfg.PollJitter*100, cfg.RequestDelayMin, cfg.RequestDelayMax)
+	}
+	for _, caseID := range cfg.CaseIDs {
+		if interval, ok := cfg.CaseSchedule[caseID]; ok {
+			log.Printf("    %s every %v", caseID, interval)
+		}
+	}
+	if cfg.DailyRequestBudget > 0 {
+		log.Printf("  Daily Request Budget: %d", cfg.DailyRequestBudget)
+	}
 	log.Printf("  State Directory: %s", cfg.StateFileDir)
-
-	// Start HTTP health check server for Cloud Run
-	// Cloud Run requires services to listen on PORT (default 8080)
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	if cfg.StorageBackend == "sqlite" {
+		log.Printf("  State Database: %s", cfg.SQLitePath)
 	}
+	if cfg.StorageBackend == "s3" {
+		location := "s3://" + cfg.S3Bucket
+		if cfg.S3Prefix != "" {
+			location += "/" + cfg.S3Prefix
+		}
+		if cfg.S3Endpoint != "" {
+			location += " at " + cfg.S3Endpoint
+		}
+		log.Printf("  State Bucket: %s (%s)", location, cfg.S3Region)
+	}
+	if cfg.StorageBackend == "postgres" {
+		// The URL carries the password, so only its host and database are logged
+		location := "PostgreSQL"
+		if u, err := url.Parse(cfg.PostgresURL); err == nil && u.Host != "" {
+			location += " at " + u.Host + u.Path
+		}
+		log.Printf("  State Database: %s", location)
+	}
+	log.Printf("  Timezone/Locale: %s", cfg.Locale)
+	log.Printf("  Fetch Timeout: %v (recycle browser after %d consecutive timeouts)", cfg.FetchTimeout, cfg.BrowserRecycleAfter)
 
-	go func() {
-		http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(http.StatusOK)
-			fmt.Fprintf(w, "USCIS Case Tracker is running")
-		})
+	authMode := "cookie"
+	if cfg.AutoLogin {
+		authMode = "browser"
+	}
+	healthTracker := health.NewTracker(authMode, cfg.CaseIDs)
 
-		http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(http.StatusOK)
-			fmt.Fprintf(w, "OK")
-		})
+	// Initialize notification channels early so we can send alerts
+	a, err := newApp(cfg, nil, healthTracker)
+	if err != nil {
+		log.Printf("Failed to initialize: %v", err)
+		return report.finish(cfg.ResultFile, exitUnexpected, err)
+	}
+	a.ctx = ctx
+	if report != nil {
+		report.InstanceID = a.instanceID
+	}
+	if a.stateDB != nil {
+		// Checkpoints the write-ahead log on the way out
+		defer a.stateDB.Close()
+	}
+	if a.statePG != nil {
+		defer a.statePG.Close()
+	}
+	a.logs = logBuffer
+	a.toggles.logFilter = logFilter
+	log.Printf("Instance ID: %s", a.instanceID)
+	if files := a.emailTemplates.Files(); len(files) > 0 {
+		log.Printf("Email templates: %v from %s", files, cfg.EmailTemplateDir)
+	}
+	if err := a.migrateState(); err != nil {
+		log.Printf("Failed to migrate stored state: %v", err)
+		return report.finish(cfg.ResultFile, exitConfigError, err)
+	}
+	if err := a.relocateTenantState(); err != nil {
+		log.Printf("Failed to move state files to tenant directories: %v", err)
+		return report.finish(cfg.ResultFile, exitConfigError, err)
+	}
+	if cfg.PollLock != "" {
+		if a.pollLock, err = a.openPollLock(); err != nil {
+			log.Printf("Failed to open poll lock: %v", err)
+			return report.finish(cfg.ResultFile, exitConfigError, err)
+		}
+		log.Printf("Poll lock: %s (TTL %v)", a.pollLock.locker, cfg.PollLockTTL)
+	}
+	// Losing the poll lock cancels polling like a shutdown signal
+	ctx, cancelPolling := context.WithCancel(ctx)
+	defer cancelPolling()
+	a.ctx = ctx
+
+	if cfg.RunOnce {
+		log.Printf("Run-once mode: polling every case a single time")
+		a.report = report
+	} else {
+		// Texted 2FA codes arrive through the HTTP server, so only a running daemon can wait for them
+		if cfg.TwilioAuthToken != "" {
+			a.smsCodes = uscis.NewSMSCodes(10 * time.Minute)
+		}
+		// Start HTTP health check server for Cloud Run
+		go a.serveHTTP()
+	}
 
-		log.Printf("Starting HTTP health check server on port %s", port)
-		if err := http.ListenAndServe(":"+port, nil); err != nil {
-			log.Fatalf("Failed to start HTTP server: %v", err)
+	// Only the instance holding the poll lock logs in, polls and notifies; the others
+	// wait as standbys and take over once it stops renewing the lease
+	if a.pollLock != nil {
+		if cfg.RunOnce {
+			acquired, err := a.pollLock.tryAcquire()
+			if err != nil {
+				log.Printf("Failed to acquire the poll lock: %v", err)
+				return a.report.finish(cfg.ResultFile, exitUnexpected, err)
+			}
+			if !acquired {
+				current := a.pollLock.state()
+				log.Printf("Poll lock is held by %s (%s), which polls the cases; skipping this run", current.Holder, current.Hostname)
+				return a.report.finish(cfg.ResultFile, exitOK, nil)
+			}
+		} else if err := a.pollLock.wait(ctx); err != nil {
+			log.Printf("Received shutdown signal while waiting for the poll lock, exiting")
+			return exitOK
 		}
-	}()
+		defer a.pollLock.release()
+		go a.pollLock.keep(ctx, cancelPolling)
+	}
 
-	// Initialize email client early so we can send notifications
-	emailClient := notifier.NewResendClient(cfg.ResendAPIKey)
+	// Notifications committed before a restart go out now, not after the first login
+	a.recoverOutbox()
 
 	// Initialize USCIS client based on authentication mode
-	var fetcher CaseStatusFetcher
+	// Initialize the case sources: the public status service needs nothing, the
+	// myUSCIS source depends on the auth mode and whether Chrome can run here
+	publicClient := uscis.NewPublicClient()
+	publicClient.SetFetchTimeout(cfg.FetchTimeout)
+	publicClient.SetContext(ctx)
+	sources := source.NewRegistry(cfg.DefaultSource, cfg.CaseSources)
+	sources.Register(source.Public, publicClient)
+	if cfg.CanaryInterval > 0 {
+		log.Printf("Canary: %s changes every %v (channels: %v)", config.CanaryCaseID, cfg.CanaryInterval, cfg.CanaryChannels)
+		sources.Register(source.Canary, uscis.NewCanaryClient(cfg.CanaryInterval))
+	}
 
-	if cfg.AutoLogin {
+	// fetcher serves the myUSCIS source; the additional USCIS accounts register their own
+	var fetcher source.Fetcher
+
+	strategy, fallbackReason := chooseFetchStrategy(cfg)
+	healthTracker.SetFetchStrategy(strategy, fallbackReason)
+	if fallbackReason != "" {
+		log.Printf("WARNING: %s", fallbackReason)
+		log.Printf("WARNING: Falling back to %s mode (CHROME_FALLBACK=%s). Install Chromium or run the tracker on a machine with Chrome to use auto-login.", strategy, cfg.ChromeFallback)
+	}
+
+	// Tell "no egress" apart from "wrong password" before the first login
+	if err := a.runPreflight(strategy); err != nil {
+		return a.exitPreflightFailed(err)
+	}
+
+	switch strategy {
+	case strategyBrowser:
 		log.Printf("Authentication: Auto-login mode (chromedp browser)")
+		log.Printf("  Login spacing: %v (+ up to %v jitter)", cfg.LoginSpacing, cfg.LoginJitter)
+		uscis.SetLoginSpacing(cfg.LoginSpacing, cfg.LoginJitter)
+
+		// Reuse the session cookies of the last login to avoid a 2FA email on every restart
+		var sessions uscis.SessionStore
+		if cfg.PersistBrowserSession {
+			location := filepath.Join(cfg.StateFileDir, "sessions.json")
+			if cfg.StorageBackend != "file" {
+				location = cfg.StorageBackend + " state backend"
+			}
+			log.Printf("  Session reuse: enabled (%s)", location)
+			sessions = a.sessionStore()
+		}
 
-		// Check if email 2FA settings are configured
-		var browserClient *uscis.BrowserClient
-		if cfg.EmailIMAPServer != "" && cfg.EmailUsername != "" && cfg.EmailPassword != "" {
+		codes, err := newCodeProvider(cfg, a.smsCodes)
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			return exitConfigError
+		}
+		switch {
+		case cfg.USCISTOTPSecret != "":
+			log.Printf("2FA: Authenticator app codes (TOTP from USCIS_TOTP_SECRET)")
+		case a.smsCodes != nil:
+			log.Printf("2FA: Codes texted to the Twilio number (webhook: %s)", strings.TrimRight(cfg.PublicURL, "/")+smsWebhookPath)
+			log.Printf("  2FA Timeout: 10m (hardcoded)")
+		case codes != nil:
 			log.Printf("2FA: Automated email fetch enabled")
 			log.Printf("  Email Server: %s", cfg.EmailIMAPServer)
 			log.Printf("  Email Account: %s", cfg.EmailUsername)
-			log.Printf("  2FA Sender: MyAccount@uscis.dhs.gov (hardcoded)")
+			if cfg.EmailOAuthClientID != "" {
+				log.Printf("  Email Login: OAuth2 (XOAUTH2)")
+			}
+			log.Printf("  2FA Sender: %s", cfg.Email2FASender)
 			log.Printf("  2FA Timeout: 10m (hardcoded)")
+		default:
+			log.Printf("2FA: Manual stdin input (email settings not configured)")
+		}
 
-			// Create IMAP client for automated 2FA
-			imapClient := email.NewIMAPClient(cfg.EmailIMAPServer, cfg.EmailUsername, cfg.EmailPassword)
-
-			// Create browser client with email support (hardcoded 2FA settings)
-			browserClient, err = uscis.NewBrowserClientWithEmail(
-				cfg.USCISUsername,
-				cfg.USCISPassword,
-				imapClient,
-				"MyAccount@uscis.dhs.gov", // Hardcoded 2FA sender
-				10*time.Minute,            // Hardcoded 2FA timeout
-			)
-			if err != nil {
-				log.Printf("CRITICAL: Failed to create browser client: %v", err)
-				log.Printf("This could indicate:")
-				log.Printf("  - Incorrect USCIS username or password")
-				log.Printf("  - Account locked due to too many failed attempts")
-				log.Printf("  - USCIS website issues")
-				log.Printf("")
-				log.Printf("Sending email notification and exiting to prevent account lockout.")
-
-				// Send email notification about authentication failure
-				sendAuthFailureEmail(emailClient, cfg.RecipientEmail, err, "browser initialization")
-
-				log.Printf("Fix credentials and redeploy to retry.")
-				os.Exit(1)
+		configure := func(client *uscis.BrowserClient) {
+			client.SetFetchTimeout(cfg.FetchTimeout)
+			client.SetParallelFetches(cfg.PollWorkers)
+			if cfg.LoginHoldHours != nil {
+				client.SetLoginGate(a.loginGate)
 			}
+		}
+		// The additional accounts log in alongside the main one, each on its own
+		var accounts *accountLogins
+		if len(cfg.Accounts) > 0 {
+			accounts = a.loginAccounts(ctx, codes, sessions, sources, configure)
+			defer accounts.close()
+		}
+
+		login := func() (*uscis.BrowserClient, error) {
+			return uscis.NewBrowserClientWithCodes(ctx, cfg.USCISUsername, cfg.USCISPassword, codes, sessions)
+		}
+		var browserClient *uscis.BrowserClient
+		if cfg.RunOnce {
+			browserClient, err = login()
 		} else {
-			log.Printf("2FA: Manual stdin input (email settings not configured)")
-			// Create browser client without email support (falls back to stdin for 2FA)
-			browserClient, err = uscis.NewBrowserClient(cfg.USCISUsername, cfg.USCISPassword)
-			if err != nil {
-				log.Printf("CRITICAL: Failed to create browser client: %v", err)
-				log.Printf("This could indicate:")
-				log.Printf("  - Incorrect USCIS username or password")
-				log.Printf("  - Account locked due to too many failed attempts")
-				log.Printf("  - USCIS website issues")
-				log.Printf("")
-				log.Printf("Sending email notification and exiting to prevent account lockout.")
-
-				// Send email notification about authentication failure
-				sendAuthFailureEmail(emailClient, cfg.RecipientEmail, err, "browser initialization")
-
-				log.Printf("Fix credentials and redeploy to retry.")
-				os.Exit(1)
-			}
+			// Failed logins are retried with a long backoff rather than crash-looping
+			backoff := newLoginBackoff(cfg.StateFileDir, "", cfg.USCISUsername, cfg.USCISPassword)
+			browserClient, err = a.loginWithBackoff(ctx, backoff, login)
+		}
+		if err != nil && ctx.Err() != nil {
+			log.Printf("Shutdown signal received during login, exiting")
+			return exitOK
+		}
+		if errors.Is(err, errRejectedBefore) {
+			log.Printf("CRITICAL: %v", err)
+			log.Printf("Not trying them again to prevent account lockout. Fix credentials and redeploy to retry.")
+			return a.exitAuthFailed(err)
+		}
+		if err != nil {
+			log.Printf("CRITICAL: Failed to create browser client: %v", err)
+			log.Printf("This could indicate:")
+			log.Printf("  - Incorrect USCIS username or password")
+			log.Printf("  - Account locked due to too many failed attempts")
+			log.Printf("  - USCIS website issues")
+			log.Printf("")
+			log.Printf("Sending email notification and exiting to prevent account lockout.")
+
+			// Send email notification about authentication failure
+			a.sendAuthFailureEmail(err, "browser initialization")
+
+			log.Printf("Fix credentials and redeploy to retry.")
+			return a.exitAuthFailed(err)
 		}
 
 		defer browserClient.Close()
 		log.Printf("Successfully logged in with browser")
+		if accounts != nil {
+			if cfg.RunOnce {
+				accounts.wait()
+			}
+			log.Printf("  Additional USCIS accounts: %d, %d logged in so far, each with a browser session of its own", len(cfg.Accounts), accounts.count())
+		}
+		if cfg.LoginHoldHours != nil {
+			log.Printf("  Login hold: %s (re-logins wait for approval by link)", cfg.LoginHoldHours)
+		}
+		configure(browserClient)
 		fetcher = browserClient
-	} else {
+	case strategyPublic:
+		log.Printf("Authentication: None (public case status service)")
+		log.Printf("  Only the headline status, description and form are available in this mode")
+		fetcher = publicClient
+	default:
 		log.Printf("Authentication: Manual cookie mode (HTTP client)")
-		fetcher = uscis.NewClient(cfg.USCISCookie)
+		client := uscis.NewClient(cfg.USCISCookie)
+		client.SetFetchTimeout(cfg.FetchTimeout)
+		client.SetContext(ctx)
+		var sessions uscis.SessionStore
+		if cfg.PersistBrowserSession {
+			sessions = a.sessionStore()
+		}
+		if setupCookieRefresh(ctx, cfg, client, sessions, a.smsCodes) && cfg.LoginHoldHours != nil {
+			log.Printf("  Login hold: %s (re-logins wait for approval by link)", cfg.LoginHoldHours)
+			client.SetLoginGate(a.loginGate)
+		}
+		fetcher = client
 	}
 
-	// Create ticker for polling
-	ticker := time.NewTicker(cfg.PollInterval)
-	defer ticker.Stop()
+	sources.Register(source.MyUSCIS, fetcher)
+	for _, account := range cfg.Accounts {
+		if strategy == strategyBrowser {
+			continue
+		}
+		// Without the browser login only the public status service can fetch its cases
+		log.Printf("Warning: USCIS account %q needs the browser login; fetching its %d case(s) from the public status service", account.Name, len(account.CaseIDs))
+		sources.Register(account.Source(), publicClient)
+	}
+	a.sources = sources
+	a.toggles.setSources(sources)
+	for _, caseID := range cfg.CaseIDs {
+		healthTracker.SetCaseSource(caseID, sources.SourceOf(caseID))
+		if name := sources.SourceOf(caseID); name != source.MyUSCIS {
+			log.Printf("  %s: fetched from the %s source", caseID, name)
+		}
+	}
 
-	// Setup signal handling for graceful shutdown
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	var discoveryWait time.Duration
+	discovering := false
+	if cfg.CaseDiscovery {
+		discoveryWait, discovering = a.startCaseDiscovery(fetcher)
+	}
+
+	if cfg.RunOnce {
+		a.pollCases("run-once check")
+		code := a.report.exitCode()
+		log.Printf("Run-once check complete (exit code %d)", code)
+		return a.report.finish(cfg.ResultFile, code, nil)
+	}
+
+	// Create timer for polling: it fires as often as the most frequently polled case
+	// (give or take POLL_JITTER), and each cycle checks the cases that are due
+	pollTimer := time.NewTimer(a.nextPollDelay())
+	defer pollTimer.Stop()
 
 	// Run initial check immediately for all cases
 	log.Printf("Running initial check for %d case(s)...", len(cfg.CaseIDs))
-	for _, caseID := range cfg.CaseIDs {
-		if err := checkAndNotifyCase(fetcher, emailClient, cfg, caseID); err != nil {
-			log.Printf("[%s] Error during initial check: %v", caseID, err)
-			// Don't exit - continue running and retry on next poll
+	// Errors don't exit - failed cases are retried on the next poll
+	a.pollCases("initial check")
+
+	// Move old history to cold storage now and then once a day
+	var archiveStore archive.Store
+	var archiveTick <-chan time.Time // nil (never fires) without archival
+	if cfg.ArchiveAfter > 0 {
+		if archiveStore, err = archive.Open(cfg.ArchiveLocation); err != nil {
+			log.Printf("Warning: Archival disabled: %v", err)
+		} else {
+			log.Printf("Archiving history older than %v to %s every %v", cfg.ArchiveAfter, cfg.ArchiveLocation, archiveInterval)
+			a.archiveHistory(archiveStore)
+			archiveTicker := time.NewTicker(archiveInterval)
+			defer archiveTicker.Stop()
+			archiveTick = archiveTicker.C
 		}
 	}
 
+	// Start tracking cases filed after startup
+	var discoveryTimer *time.Timer
+	var discoveryTick <-chan time.Time // nil (never fires) without case discovery
+	if discovering {
+		discoveryTimer = time.NewTimer(discoveryWait)
+		defer discoveryTimer.Stop()
+		discoveryTick = discoveryTimer.C
+	}
+
+	// Log in again before the session expires rather than in the middle of a poll
+	var sessionTick <-chan time.Time // nil (never fires) without a scheduled refresh
+	if cfg.SessionRefreshInterval > 0 {
+		a.sessionRefresh = newSessionSchedule(cfg, time.Now())
+		log.Printf("Refreshing the USCIS session %s", a.sessionRefresh)
+		sessionTicker := time.NewTicker(sessionRefreshCheck)
+		defer sessionTicker.Stop()
+		sessionTick = sessionTicker.C
+	}
+
 	// Main loop
 	for {
 		select {
-		case <-ticker.C:
-			log.Printf("Polling %d case(s)...", len(cfg.CaseIDs))
-			for _, caseID := range cfg.CaseIDs {
-				if err := checkAndNotifyCase(fetcher, emailClient, cfg, caseID); err != nil {
-					log.Printf("[%s] Error during poll: %v", caseID, err)
-					// Continue checking other cases even if one fails
-				}
+		case <-pollTimer.C:
+			// Continue checking other cases even if one fails
+			started := time.Now()
+			a.pollCases("poll")
+			pollTimer.Reset(time.Until(started.Add(a.nextPollDelay())))
+		case t := <-a.triggers:
+			// Between scheduled cycles, so the two never fetch or notify at once
+			a.pollTriggered(t)
+		case <-archiveTick:
+			a.archiveHistory(archiveStore)
+		case now := <-sessionTick:
+			a.refreshSessionOnSchedule(now)
+		case <-discoveryTick:
+			discoveryTimer.Reset(a.discoverCasesOnSchedule())
+		case <-ctx.Done():
+			if a.pollLock.wasLost() {
+				// A restart comes back as a standby
+				log.Printf("Lost the poll lock, exiting")
+				return exitUnexpected
 			}
-		case sig := <-sigChan:
-			log.Printf("Received signal %v, shutting down gracefully...", sig)
-			return
+			log.Printf("Received shutdown signal, shutting down gracefully...")
+			return exitOK
 		}
 	}
 }
 
-func checkAndNotifyCase(fetcher CaseStatusFetcher, emailClient *notifier.ResendClient, cfg *config.Config, caseID string) error {
-	log.Printf("Fetching case status for %s...", caseID)
-
-	// Create storage for this specific case
-	stateStorage := storage.NewFileStorage(cfg.StateFileDir, caseID)
-
-	// Load previous state for this case
-	previousState, err := stateStorage.Load()
-	if err != nil {
-		log.Printf("Warning: Failed to load previous state for %s: %v", caseID, err)
-	}
-
-	// Fetch case status
-	status, err := fetcher.FetchCaseStatus(caseID)
-	if err != nil {
-		// Check if it's an authentication error (both manual cookie and browser auto-login modes)
-		if _, ok := err.(*uscis.ErrAuthenticationFailed); ok {
-			log.Printf("Authentication failed! Sending email notification...")
-			// Send alert email (works for both modes)
-			sendAuthFailureEmail(emailClient, cfg.RecipientEmail, err, "polling")
-			return fmt.Errorf("authentication failed: %w", err)
-		}
-
-		return fmt.Errorf("failed to fetch case status: %w", err)
-	}
-
-	log.Printf("Case status fetched successfully")
-
-	// Detect changes
-	changes := uscis.DetectChanges(previousState, status)
-
-	// Determine if we should send email
-	isFirstRun := previousState == nil
-	hasChanges := len(changes) > 0
-
-	if isFirstRun {
-		log.Printf("[%s] First run - sending initial status email", caseID)
-		subject := fmt.Sprintf("USCIS Case Tracker - Initial Status for %s", caseID)
-		body := formatInitialStatusEmail(status, caseID)
-		if err := emailClient.SendEmail(cfg.RecipientEmail, subject, body); err != nil {
-			return fmt.Errorf("failed to send initial email: %w", err)
-		}
-		log.Printf("[%s] Initial status email sent successfully", caseID)
-	} else if hasChanges {
-		log.Printf("[%s] Changes detected: %d fields changed", caseID, len(changes))
-		subject := fmt.Sprintf("USCIS Case Status Update - %s", caseID)
-		body := formatChangeNotificationEmail(changes, status, caseID)
-		if err := emailClient.SendEmail(cfg.RecipientEmail, subject, body); err != nil {
-			return fmt.Errorf("failed to send change notification: %w", err)
+// formatBundleEmail renders one email covering every receipt of an application bundle
+// The milestone table lists all receipts; updated receipts get their own section below it
+func formatBundleEmail(bundle *config.Bundle, updated []*caseResult, byCase map[string]*caseResult, render func(name string, r *caseResult) string, loc locale.Settings) string {
+	rows := ""
+	for _, caseID := range bundle.CaseIDs {
+		form, summary := "-", "not checked this cycle"
+		if r, ok := byCase[caseID]; ok {
+			if f := uscis.FormType(r.status); f != "" {
+				form = f
+			}
+			summary = "unknown"
+			if s := uscis.StatusSummary(r.status); s != "" {
+				summary = loc.Status(s)
+			}
 		}
-		log.Printf("[%s] Change notification email sent successfully", caseID)
-	} else {
-		log.Printf("[%s] No changes detected - skipping email notification", caseID)
+		rows += fmt.Sprintf("<tr><td style='padding: 4px 12px;'>%s</td><td style='padding: 4px 12px;'>%s</td><td style='padding: 4px 12px;'>%s</td></tr>",
+			template.HTMLEscapeString(caseID), template.HTMLEscapeString(form), template.HTMLEscapeString(summary))
 	}
 
-	// Save current state to storage if has first run or has changes
-	if isFirstRun || hasChanges {
-		if err := stateStorage.Save(status); err != nil {
-			log.Printf("Warning: Failed to save state: %v", err)
+	sections := ""
+	for _, r := range updated {
+		if r.isFirstRun() {
+			sections += fmt.Sprintf("<h3>%s</h3><p>First status check for this receipt.</p>", template.HTMLEscapeString(r.caseID))
+			continue
 		}
+		sections += fmt.Sprintf("<h3>%s</h3>%s", template.HTMLEscapeString(r.caseID), render(templates.Changes, r))
 	}
 
-	return nil
-}
-
-func formatInitialStatusEmail(status map[string]interface{}, caseID string) string {
-	jsonBytes, _ := json.MarshalIndent(status, "", "  ")
-
 	html := fmt.Sprintf(`
-		<h2>USCIS Case Tracker - Initial Status</h2>
-		<p><strong>Case ID:</strong> %s</p>
-		<p>This is the first status check for your case. Future emails will only be sent when changes are detected.</p>
-		<h3>Current Status:</h3>
-		<pre style="background-color: #f5f5f5; padding: 15px; border-radius: 5px; overflow-x: auto; font-family: monospace;">%s</pre>
-		<p><small>This email was sent by USCIS Case Tracker</small></p>
-	`, caseID, string(jsonBytes))
+		<h2>USCIS Case Status Update - %s</h2>
+		<p>%d receipt(s) in this application were updated at the same time (%s).</p>
+		<h3>Application Milestones:</h3>
+		<table style="border-collapse: collapse;">
+			<tr><th style="text-align: left; padding: 4px 12px;">Receipt</th><th style="text-align: left; padding: 4px 12px;">Form</th><th style="text-align: left; padding: 4px 12px;">Current Status</th></tr>
+			%s
+		</table>
+		%s
+	`, template.HTMLEscapeString(bundle.Name), len(updated), loc.FormatDateTime(time.Now()), rows, sections)
 
 	return html
 }
 
-func formatChangeNotificationEmail(changes []uscis.Change, status map[string]interface{}, caseID string) string {
-	jsonBytes, _ := json.MarshalIndent(status, "", "  ")
-
-	// Build changes list
-	changesHTML := "<ul>"
-	for _, change := range changes {
-		if change.OldValue == nil {
-			changesHTML += fmt.Sprintf("<li><strong>%s</strong>: <span style='color: green;'>%v</span> (new field)</li>", change.Field, change.NewValue)
-		} else if change.NewValue == nil {
-			changesHTML += fmt.Sprintf("<li><strong>%s</strong>: <span style='color: red;'>%v</span> (removed)</li>", change.Field, change.OldValue)
-		} else {
-			changesHTML += fmt.Sprintf("<li><strong>%s</strong>: <span style='color: red;'>%v</span> → <span style='color: green;'>%v</span></li>", change.Field, change.OldValue, change.NewValue)
-		}
+// exitAuthFailed returns the exit code for a failed login at startup
+// Run-once mode reports it in the result file with its own code
+func (a *app) exitAuthFailed(err error) int {
+	if a.report == nil {
+		// The daemon has always exited 1 here; restart policies depend on it
+		return exitUnexpected
+	}
+	for _, caseID := range a.cfg.CaseIDs {
+		a.report.caseFailed(caseID, err)
 	}
-	changesHTML += "</ul>"
+	code := exitAuthFailure
+	var blocked *uscis.ErrWAFBlocked
+	if errors.As(err, &blocked) {
+		// The credentials were never checked; USCIS can't be reached from here
+		code = exitNetwork
+	}
+	return a.report.finish(a.cfg.ResultFile, code, err)
+}
 
-	html := fmt.Sprintf(`
-		<h2>USCIS Case Status Update Detected!</h2>
-		<p><strong>Case ID:</strong> %s</p>
-		<p>The following changes were detected in your case status:</p>
-		%s
-		<h3>Full Current Status:</h3>
-		<pre style="background-color: #f5f5f5; padding: 15px; border-radius: 5px; overflow-x: auto; font-family: monospace;">%s</pre>
-		<p><small>This email was sent by USCIS Case Tracker</small></p>
-	`, caseID, changesHTML, string(jsonBytes))
+// loginCapturesHTML lists the captures of a failed login in the auth failure email
+func loginCapturesHTML(captures []string, backend string, attached bool) string {
+	if len(captures) == 0 {
+		return ""
+	}
+	where := "saved on the tracker's host"
+	if backend != "file" {
+		where = "saved in the " + backend + " state backend"
+	}
+	if attached {
+		where += " and attached to this email"
+	}
+	var items strings.Builder
+	for _, path := range captures {
+		fmt.Fprintf(&items, "<li><code>%s</code></li>", html.EscapeString(path))
+	}
+	return fmt.Sprintf(`<p><strong>Diagnostics:</strong> a screenshot and the page the login failed on are %s:</p><ul>%s</ul>`, where, items.String())
+}
 
-	return html
+// captureAttachments returns local files of a failed login's captures to attach to an email
+// Captures kept in another backend are copied to a temporary directory, which cleanup removes
+func (a *app) captureAttachments(loginErr error, captures []string) (files []string, cleanup func()) {
+	if a.cfg.StorageBackend == "file" {
+		return captures, func() {}
+	}
+	capture := uscis.SavedLoginCapture(loginErr)
+	if capture == nil {
+		return nil, func() {}
+	}
+	dir, err := os.MkdirTemp("", "login-capture-")
+	if err != nil {
+		log.Printf("Warning: Failed to attach the login failure capture: %v", err)
+		return nil, func() {}
+	}
+	cleanup = func() { os.RemoveAll(dir) }
+	files, err = storage.NewLoginCaptureStore(dir).SaveCapture(capture)
+	if err != nil {
+		log.Printf("Warning: Failed to attach the login failure capture: %v", err)
+	}
+	return files, cleanup
 }
 
 // sendAuthFailureEmail sends an email notification when authentication fails
-func sendAuthFailureEmail(emailClient *notifier.ResendClient, recipientEmail string, err error, context string) {
-	subject := "USCIS Case Tracker - Authentication Failed"
+func (a *app) sendAuthFailureEmail(err error, context string) {
+	a.metrics.authFailures.Inc(context)
+	a.health.RecordAuthFailure(err)
+	captures := uscis.LoginCaptures(err)
+
+	title, advice := "Authentication Failed", authFailureAdvice
+	var locked *uscis.ErrAccountLocked
+	var blocked *uscis.ErrWAFBlocked
+	switch {
+	case errors.As(err, &locked):
+		title, advice = "USCIS Account Locked", accountLockedAdvice
+	case errors.As(err, &blocked):
+		title, advice = "Login Blocked by the USCIS Firewall", wafBlockedAdvice
+	}
+
+	subject := a.cfg.BrandName + " - " + title
 	body := fmt.Sprintf(`
-		<h2>⚠️ Authentication Failed</h2>
+		<h2>⚠️ %s</h2>
 		<p><strong>Context:</strong> %s</p>
 		<p><strong>Error:</strong> %v</p>
+		%s
+		%s
+	`, title, context, err, advice, loginCapturesHTML(captures, a.cfg.StorageBackend, a.cfg.LoginCaptures == "attach"))
+
+	msg := a.message(nil, subject, body)
+	if a.cfg.LoginCaptures == "attach" {
+		files, cleanup := a.captureAttachments(err, captures)
+		defer cleanup()
+		msg.Attachments = files
+	}
+	if sendErr := a.notifier.SendAlert(msg); sendErr != nil {
+		log.Printf("Failed to send authentication failure alert email: %v", sendErr)
+	} else {
+		log.Printf("Authentication failure alert email sent successfully to %s", strings.Join(a.cfg.RecipientEmails, ", "))
+	}
+}
 
+// authFailureAdvice explains a rejected login or session in the auth failure email
+const authFailureAdvice = `
 		<h3>What this means:</h3>
 		<ul>
 			<li><strong>Browser auto-login mode:</strong> USCIS username/password may be incorrect, or your account may be locked</li>
@@ -303,17 +962,32 @@ func sendAuthFailureEmail(emailClient *notifier.ResendClient, recipientEmail str
 gcloud secrets versions add uscis-username --data-file=- --project=your-project-id
 gcloud secrets versions add uscis-password --data-file=- --project=your-project-id</pre>
 			</li>
-			<li><strong>Redeploy:</strong> Redeploy the service to pick up new credentials</li>
+			<li><strong>Restart:</strong> Restart the tracker if its settings reference the secrets (<code>USCIS_PASSWORD=sm://...</code>), since they are read at startup; otherwise redeploy the service to pick up new credentials</li>
 		</ol>
 
-		<p><strong>Note:</strong> The service will automatically exit to prevent account lockout from repeated failed login attempts.</p>
+		<p><strong>Note:</strong> If USCIS rejected the username or password, the service exits and won't try them again until they change, to prevent account lockout. Other failed logins at startup are retried after 30 minutes, 2 hours and then every 6 hours.</p>`
 
-		<p><small>This alert was sent by USCIS Case Tracker</small></p>
-	`, context, err)
+// accountLockedAdvice explains ErrAccountLocked in the auth failure email
+const accountLockedAdvice = `
+		<h3>What this means:</h3>
+		<p>USCIS refused the sign-in because the account is locked or saw too many sign-in attempts. The username and password may well be right, but every further attempt can extend the lock.</p>
 
-	if sendErr := emailClient.SendEmail(recipientEmail, subject, body); sendErr != nil {
-		log.Printf("Failed to send authentication failure alert email: %v", sendErr)
-	} else {
-		log.Printf("Authentication failure alert email sent successfully to %s", recipientEmail)
-	}
-}
+		<h3>What to do:</h3>
+		<ol>
+			<li><strong>Stop further attempts:</strong> The tracker has stopped logging in to the account, and this alert isn't repeated for its other cases. Don't restart the tracker or refresh its session until the account is unlocked</li>
+			<li><strong>Unlock the account:</strong> Sign in at https://my.uscis.gov yourself, or use "Forgot your password?" to reset the password, which also unlocks it</li>
+			<li><strong>Update secrets:</strong> If the password changed, update <code>USCIS_PASSWORD</code> (or the uscis-password secret) and restart the tracker</li>
+			<li><strong>Resume:</strong> Otherwise log in again with <code>POST /api/admin/refresh-session</code></li>
+		</ol>`
+
+// wafBlockedAdvice explains ErrWAFBlocked in the auth failure email
+const wafBlockedAdvice = `
+		<h3>What this means:</h3>
+		<p>The firewall in front of myUSCIS (AWS WAF) blocked the tracker's browser before it could sign in. The username and password were never checked, so there is no need to change them. The firewall usually reacts to the IP address (cloud provider ranges are blocked more often) or to requests arriving too often or too regularly.</p>
+
+		<h3>What to do:</h3>
+		<ol>
+			<li><strong>Wait:</strong> Blocks usually lift by themselves after a while; the tracker tries again at the next poll, and the circuit breaker spaces out the attempts</li>
+			<li><strong>Slow down:</strong> Raise <code>POLL_INTERVAL</code>, and set <code>POLL_JITTER</code> and <code>REQUEST_DELAY</code> so requests look less regular</li>
+			<li><strong>Change where requests come from:</strong> Deploy to another region, or log in through a Chrome on a home connection with <code>CHROME_REMOTE_URL</code></li>
+		</ol>`
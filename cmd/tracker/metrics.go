@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/metrics"
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// trackerMetrics are the counters and histograms served at /metrics
+type trackerMetrics struct {
+	registry         *metrics.Registry
+	polls            *metrics.Counter
+	lastPoll         *metrics.Gauge
+	fetchErrors      *metrics.Counter
+	fetchDuration    *metrics.Histogram
+	authFailures     *metrics.Counter
+	notifications    *metrics.Counter
+	notifyQueueDepth *metrics.Gauge
+	notifyDuration   *metrics.Histogram
+	changes          *metrics.Counter
+	outboxPending    *metrics.Gauge
+	dailyRequests    *metrics.Gauge
+}
+
+// newTrackerMetrics registers the tracker's metrics
+func newTrackerMetrics() *trackerMetrics {
+	r := metrics.NewRegistry()
+	return &trackerMetrics{
+		registry:         r,
+		polls:            r.NewCounter("case_tracker_polls_total", "Poll cycles run"),
+		lastPoll:         r.NewGauge("case_tracker_last_poll_timestamp_seconds", "Unix time the last poll cycle finished"),
+		fetchErrors:      r.NewCounter("case_tracker_fetch_errors_total", "Failed case fetches by reason (timeout, locked, waf, auth, html, parse, other)", "case", "reason"),
+		fetchDuration:    r.NewHistogram("case_tracker_fetch_duration_seconds", "Time to fetch a case status, successful or not", metrics.DefaultBuckets, "case", "source"),
+		authFailures:     r.NewCounter("case_tracker_auth_failures_total", "Authentication failures by where they happened", "context"),
+		notifications:    r.NewCounter("case_tracker_notifications_total", "Notification sends by channel, kind and result (sent or failed)", "channel", "kind", "result"),
+		notifyQueueDepth: r.NewGauge("case_tracker_notify_queue_depth", "Notification sends waiting for a free slot, by channel", "channel"),
+		notifyDuration:   r.NewHistogram("case_tracker_notify_send_duration_seconds", "Time to send a notification over a channel, timed-out sends included", metrics.DefaultBuckets, "channel"),
+		changes:          r.NewCounter("case_tracker_status_changes_total", "Detected case status changes", "case"),
+		outboxPending:    r.NewGauge("case_tracker_outbox_pending", "Notifications still undelivered after the last outbox retry"),
+		dailyRequests:    r.NewGauge("case_tracker_daily_requests", "USCIS requests made today, counted when DAILY_REQUEST_BUDGET is set"),
+	}
+}
+
+// observeFetch records the duration and, on failure, the reason of a case fetch
+func (m *trackerMetrics) observeFetch(caseID, sourceName string, elapsed time.Duration, err error) {
+	m.fetchDuration.Observe(elapsed.Seconds(), caseID, sourceName)
+	if err != nil {
+		m.fetchErrors.Inc(caseID, fetchErrorReason(err))
+	}
+}
+
+// fetchErrorReason classifies a fetch error for the reason label
+func fetchErrorReason(err error) string {
+	var timeoutErr *uscis.ErrFetchTimeout
+	var authErr *uscis.ErrAuthenticationFailed
+	var lockedErr *uscis.ErrAccountLocked
+	var blockedErr *uscis.ErrWAFBlocked
+	switch {
+	case errors.As(err, &timeoutErr):
+		return "timeout"
+	case errors.As(err, &lockedErr):
+		return "locked"
+	case errors.As(err, &blockedErr):
+		return "waf"
+	case errors.As(err, &authErr):
+		return "auth"
+	case errors.Is(err, uscis.ErrRateLimited):
+		return "rate_limited"
+	case errors.Is(err, uscis.ErrCaseNotFound), errors.Is(err, uscis.ErrInvalidReceipt):
+		return "not_found"
+	case errors.Is(err, uscis.ErrHTMLResponse):
+		return "html"
+	case errors.Is(err, uscis.ErrParse):
+		return "parse"
+	default:
+		return "other"
+	}
+}
+
+// notificationResult is the result label of a notification send
+func notificationResult(err error) string {
+	if err != nil {
+		return "failed"
+	}
+	return "sent"
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/phhowardchen/case-tracker/internal/config"
+	"github.com/phhowardchen/case-tracker/internal/storage"
+)
+
+// migrateState upgrades the stored state before the tracker uses it
+// State written by a newer tracker is refused rather than misread. The PostgreSQL
+// schema is migrated when newApp opens the database
+func (a *app) migrateState() error {
+	applied, err := storage.Migrate(&storage.MigrationEnv{
+		StateDir: a.cfg.StateFileDir,
+		DB:       a.stateDB,
+		Logf:     func(format string, args ...interface{}) { log.Printf("  "+format, args...) },
+	})
+	for _, m := range applied {
+		log.Printf("Migrated stored state to version %d (%s)", m.Version, m.Name)
+	}
+	return err
+}
+
+// importFileState copies the state files of every case into a state database that has
+// no snapshot yet, so switching STORAGE_BACKEND from file to sqlite keeps the history
+// Without this every case would look new and send its initial notification again
+func (a *app) importFileState() error {
+	empty, err := a.stateDB.Empty()
+	if err != nil || !empty {
+		return err
+	}
+	for _, caseID := range a.cfg.CaseIDs {
+		imported, err := a.stateDB.ImportFiles(a.cfg.CaseStateDir(caseID), caseID)
+		if err != nil {
+			return fmt.Errorf("%s: %w", caseID, err)
+		}
+		if imported > 0 {
+			log.Printf("[%s] Imported %d state file(s) into %s", caseID, imported, a.cfg.SQLitePath)
+		}
+	}
+	return nil
+}
+
+// relocateTenantState moves the state files of each case into the directory it now
+// belongs in, after TENANTS assigned it to a tenant, moved it to another or released it
+// Without this a reassigned case would look new and send its initial notification again
+func (a *app) relocateTenantState() error {
+	if a.cfg.StorageBackend != "file" {
+		// The database and the bucket key state by case ID, wherever the case belongs
+		return nil
+	}
+	dirs := []string{a.cfg.StateFileDir}
+	tenantDirs, err := filepath.Glob(filepath.Join(a.cfg.StateFileDir, config.TenantsDir, "*"))
+	if err != nil {
+		return fmt.Errorf("failed to list tenant directories: %w", err)
+	}
+	dirs = append(dirs, tenantDirs...)
+
+	for _, caseID := range a.cfg.CaseIDs {
+		target := a.cfg.CaseStateDir(caseID)
+		for _, dir := range dirs {
+			if dir == target {
+				continue
+			}
+			moved, err := storage.NewFileStorage(dir, caseID).MoveTo(target)
+			if err != nil {
+				return fmt.Errorf("%s: %w", caseID, err)
+			}
+			if moved > 0 {
+				log.Printf("[%s] Moved %d state file(s) to %s", caseID, moved, target)
+			}
+		}
+	}
+	return nil
+}
+
+// runMigrate implements `tracker migrate`
+// It upgrades the stored state in place, or with -status only shows what would be done.
+// The daemon migrates on start too; the command lets an upgrade be checked or run ahead
+func runMigrate(args []string) int {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	status := fs.Bool("status", false, "show the state version and pending migrations without applying them")
+	fs.Parse(args)
+
+	cfg, err := config.LoadPartial()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return exitConfigError
+	}
+	if code := migrateStateDir(cfg, *status); code != exitOK {
+		return code
+	}
+	if cfg.StorageBackend == "postgres" {
+		return migratePostgres(cfg, *status)
+	}
+	return exitOK
+}
+
+// migrateStateDir shows and applies the migrations of the state directory
+func migrateStateDir(cfg *config.Config, status bool) int {
+	version, err := storage.LoadStateVersion(cfg.StateFileDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUnexpected
+	}
+	pending, err := storage.PendingMigrations(version.Version)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfigError
+	}
+
+	fmt.Printf("State version: %d (current: %d) in %s\n", version.Version, storage.CurrentStateVersion(), cfg.StateFileDir)
+	for _, m := range version.Applied {
+		fmt.Printf("  applied %d %s at %s\n", m.Version, m.Name, m.AppliedAt.Format("2006-01-02 15:04:05"))
+	}
+	if len(pending) == 0 {
+		fmt.Println("Up to date")
+		return exitOK
+	}
+	for _, m := range pending {
+		fmt.Printf("  pending %d %s: %s\n", m.Version, m.Name, m.Description)
+	}
+	if status {
+		return exitOK
+	}
+
+	env := &storage.MigrationEnv{
+		StateDir: cfg.StateFileDir,
+		Logf:     func(format string, args ...interface{}) { fmt.Printf("    "+format+"\n", args...) },
+	}
+	if cfg.StorageBackend == "sqlite" {
+		db, err := storage.OpenSQLite(cfg.SQLitePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open state database: %v\n", err)
+			return exitUnexpected
+		}
+		defer db.Close()
+		env.DB = db
+	}
+
+	applied, err := storage.Migrate(env)
+	for _, m := range applied {
+		fmt.Printf("Migrated to version %d (%s)\n", m.Version, m.Name)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUnexpected
+	}
+	return exitOK
+}
+
+// migratePostgres shows and applies the schema migrations of the state database
+func migratePostgres(cfg *config.Config, status bool) int {
+	db, err := storage.OpenPostgres(cfg.PostgresURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open state database: %v\n", err)
+		return exitUnexpected
+	}
+	defer db.Close()
+
+	pending, err := db.PendingMigrations()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfigError
+	}
+	version, err := db.SchemaVersion()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUnexpected
+	}
+	migrations, err := storage.PostgresMigrations()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUnexpected
+	}
+	fmt.Printf("Database schema: %d (current: %d)\n", version, migrations[len(migrations)-1].Version)
+	if len(pending) == 0 {
+		fmt.Println("Up to date")
+		return exitOK
+	}
+	for _, m := range pending {
+		fmt.Printf("  pending %d %s\n", m.Version, m.Name)
+	}
+	if status {
+		return exitOK
+	}
+
+	applied, err := db.Migrate()
+	for _, m := range applied {
+		fmt.Printf("Migrated database to schema %d (%s)\n", m.Version, m.Name)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUnexpected
+	}
+	return exitOK
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/locale"
+	"github.com/phhowardchen/case-tracker/internal/source"
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// documentFetcher is implemented by fetchers that can download notice PDFs with their session
+type documentFetcher interface {
+	FetchDocument(doc uscis.Document) ([]byte, error)
+}
+
+// savedNotice is a notice linked to by a new case entry, downloaded for its notification
+type savedNotice struct {
+	doc  uscis.Document
+	path string // where the PDF was saved; empty if the download failed
+}
+
+// downloadNotices saves the notices a case payload links to and the previous one didn't
+// (NOTICE_PDFS). A failed download is logged and listed without a file; the notice isn't
+// tried again, since the state it is new in gets saved with the notification
+func (a *app) downloadNotices(caseID string, fetcher source.Fetcher, previous, status map[string]interface{}) []savedNotice {
+	docs := uscis.NewDocuments(previous, status)
+	if len(docs) == 0 {
+		return nil
+	}
+	downloader, ok := fetcher.(documentFetcher)
+	if !ok {
+		return nil
+	}
+
+	notices := make([]savedNotice, 0, len(docs))
+	for _, doc := range docs {
+		notice := savedNotice{doc: doc}
+		a.countRequest(caseID)
+		data, err := downloader.FetchDocument(doc)
+		if err == nil {
+			notice.path, err = a.notices.Save(caseID, noticeFileName(doc), data)
+		}
+		if err != nil {
+			log.Printf("[%s] Warning: Failed to download notice %q: %v", caseID, doc.Title, err)
+		} else {
+			log.Printf("[%s] Notice %q saved to %s", caseID, doc.Title, notice.path)
+		}
+		notices = append(notices, notice)
+	}
+	return notices
+}
+
+// noticeFileName names the saved PDF of a notice: its date, title and a short hash of
+// its ID, e.g. "2024-03-01-Receipt_Notice-1a2b3c4d.pdf"
+func noticeFileName(doc uscis.Document) string {
+	date := doc.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	sum := sha256.Sum256([]byte(doc.Key()))
+	title := strings.Join(strings.Fields(doc.Title), "_")
+	if len(title) > 60 {
+		title = title[:60]
+	}
+	return fmt.Sprintf("%s-%s-%s.pdf", date.Format("2006-01-02"), title, hex.EncodeToString(sum[:4]))
+}
+
+// noticesHTML lists the new notices of a change notification, dated for loc
+func (a *app) noticesHTML(r *caseResult, loc locale.Settings) string {
+	if len(r.notices) == 0 {
+		return ""
+	}
+
+	var items []string
+	for _, notice := range r.notices {
+		item := template.HTMLEscapeString(notice.doc.Title)
+		if !notice.doc.Date.IsZero() {
+			item += " (" + loc.FormatDate(notice.doc.Date) + ")"
+		}
+		if notice.path == "" {
+			item += ` - <em>couldn't be downloaded, see your USCIS account</em>`
+		}
+		items = append(items, "<li>"+item+"</li>")
+	}
+
+	where := "saved on the tracker"
+	if a.cfg.NoticePDFs == "attach" {
+		where = "attached to the email version of this notification"
+	}
+	return fmt.Sprintf(`<h3>New Notices</h3>
+<ul>%s</ul>
+<p style="color: #777;"><small>The PDFs are %s.</small></p>`, strings.Join(items, ""), where)
+}
+
+// noticeAttachments returns the saved PDFs to attach to a notification (NOTICE_PDFS=attach)
+func (a *app) noticeAttachments(r *caseResult) []string {
+	if a.cfg.NoticePDFs != "attach" {
+		return nil
+	}
+	var paths []string
+	for _, notice := range r.notices {
+		if notice.path != "" {
+			paths = append(paths, notice.path)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/locale"
+	"github.com/phhowardchen/case-tracker/internal/notifier"
+	"github.com/phhowardchen/case-tracker/internal/notifier/templates"
+	"github.com/phhowardchen/case-tracker/internal/storage"
+)
+
+// newNotifier builds the fan-out over every configured notification channel
+// Every attempt is recorded in the delivery log
+func (a *app) newNotifier() *notifier.MultiNotifier {
+	multi := notifier.NewMultiNotifier(notifier.Channel{
+		Name:        "email",
+		Destination: strings.Join(a.cfg.RecipientEmails, ", "),
+		Notifier:    newEmailClient(a.cfg),
+	})
+
+	if a.cfg.TelegramBotToken != "" {
+		multi.Add(notifier.Channel{
+			Name:        "telegram",
+			Destination: a.cfg.TelegramChatID,
+			Notifier:    notifier.NewTelegramClient(a.cfg.TelegramBotToken, a.cfg.TelegramChatID),
+		})
+	}
+	// A tenant's own chat only gets the tenant's cases
+	for _, tenant := range a.cfg.Tenants {
+		if tenant.TelegramChatID == "" {
+			continue
+		}
+		multi.Add(notifier.Channel{
+			Name:        "telegram",
+			Destination: tenant.TelegramChatID,
+			Notifier:    notifier.NewTelegramClient(a.cfg.TelegramBotToken, tenant.TelegramChatID),
+			CaseIDs:     tenant.CaseIDs,
+		})
+	}
+
+	if a.cfg.SlackWebhookURL != "" {
+		multi.Add(notifier.Channel{
+			Name:        "slack",
+			Destination: "incoming webhook",
+			Notifier:    notifier.NewSlackClient(a.cfg.SlackWebhookURL),
+		})
+	}
+
+	if a.push != nil {
+		multi.Add(a.pushChannel())
+	}
+
+	for _, ch := range a.webhookChannels() {
+		multi.Add(ch)
+	}
+
+	// Each channel sends through its own bounded pool, so a slow one can't hold up the rest
+	for _, ch := range multi.Channels() {
+		limits := a.cfg.LimitsFor(ch.Name)
+		multi.SetLimits(ch.Name, notifier.Limits{
+			Concurrency: limits.Concurrency,
+			Timeout:     limits.Timeout,
+			QueueDepth:  limits.QueueDepth,
+		})
+	}
+	multi.OnQueue = func(channel string, depth int) {
+		a.metrics.notifyQueueDepth.Set(float64(depth), channel)
+	}
+	multi.OnSent = func(channel string, elapsed time.Duration) {
+		a.metrics.notifyDuration.Observe(elapsed.Seconds(), channel)
+	}
+
+	multi.OnResult = a.recordDelivery
+	multi.DryRun = a.toggles.isDryRun
+	return multi
+}
+
+// webhookChannels returns a channel per distinct webhook URL
+// A URL that is both global and per-case is global; a URL shared by several cases
+// is posted to once per notification
+func (a *app) webhookChannels() []notifier.Channel {
+	var channels []notifier.Channel
+	var urls []string
+	for _, hook := range a.cfg.WebhookURLs {
+		if slices.Contains(urls, hook) {
+			continue
+		}
+		urls = append(urls, hook)
+		channels = append(channels, webhookChannel(hook, nil))
+	}
+
+	caseIDsByURL := make(map[string][]string)
+	var caseURLs []string
+	for _, caseID := range a.cfg.CaseIDs {
+		for _, hook := range a.cfg.CaseWebhooks[caseID] {
+			if slices.Contains(urls, hook) {
+				continue
+			}
+			if _, seen := caseIDsByURL[hook]; !seen {
+				caseURLs = append(caseURLs, hook)
+			}
+			caseIDsByURL[hook] = append(caseIDsByURL[hook], caseID)
+		}
+	}
+	for _, hook := range caseURLs {
+		channels = append(channels, webhookChannel(hook, caseIDsByURL[hook]))
+	}
+	return channels
+}
+
+// webhookChannel creates the channel posting to one webhook URL
+func webhookChannel(hook string, caseIDs []string) notifier.Channel {
+	return notifier.Channel{
+		Name:        "webhook",
+		Destination: notifier.WebhookDestination(hook),
+		Notifier:    notifier.NewWebhookClient(hook),
+		CaseIDs:     caseIDs,
+	}
+}
+
+// message builds a notification with the branded email footer, addressed to the cases' recipients
+// Notifications about the canary only go to CANARY_CHANNELS
+func (a *app) message(caseIDs []string, subject, body string) notifier.Message {
+	msg := notifier.Message{
+		CaseIDs:    caseIDs,
+		Recipients: a.cfg.RecipientsFor(caseIDs),
+		Subject:    subject,
+		HTML:       body,
+		Footer:     a.emailFooterHTML(),
+	}
+	if len(caseIDs) == 1 && a.cfg.IsCanary(caseIDs[0]) {
+		msg.Channels = a.cfg.CanaryChannels
+	}
+	return msg
+}
+
+// templateData fills in the email template variables of a case
+func (a *app) templateData(r *caseResult, loc locale.Settings) templates.Data {
+	data := templates.NewData(r.caseID, r.current, r.changes, loc, time.Now())
+	data.Brand = a.cfg.BrandName
+	if nickname := a.cfg.CaseNicknames[r.caseID]; nickname != "" {
+		data.Nickname, data.Name = nickname, nickname
+	}
+	if data.FormType == "" {
+		data.FormType = a.cfg.CaseFormTypes[r.caseID]
+	}
+	data.Applicant = a.cfg.CaseApplicants[r.caseID]
+	if !r.isFirstRun() {
+		data.Event = r.event.Label
+		data.Severity = r.event.Severity.String()
+	}
+	return data
+}
+
+// renderCase renders one of the email templates for a case
+func (a *app) renderCase(name string, r *caseResult, loc locale.Settings) string {
+	return a.emailTemplates.Render(name, a.templateData(r, loc))
+}
+
+// caseRenderer binds renderCase to a locale, for emails covering several cases
+func (a *app) caseRenderer(loc locale.Settings) func(name string, r *caseResult) string {
+	return func(name string, r *caseResult) string {
+		return a.renderCase(name, r, loc)
+	}
+}
+
+// sendAlert sends an operational alert to every channel
+func (a *app) sendAlert(caseIDs []string, subject, body string) error {
+	return a.notifier.SendAlert(a.message(caseIDs, subject, body))
+}
+
+// recordDelivery appends one channel's send attempt to the delivery log
+func (a *app) recordDelivery(ch notifier.Channel, kind string, msg notifier.Message, err error) {
+	delivery := storage.Delivery{
+		Time:       time.Now(),
+		InstanceID: a.instanceID,
+		CaseIDs:    msg.CaseIDs,
+		Channel:    ch.Name,
+		Recipient:  ch.Destination,
+		Subject:    msg.Subject,
+	}
+	if ch.Name == "email" && kind != "alert" {
+		delivery.Recipient = strings.Join(msg.Recipients, ", ")
+	}
+	if err != nil {
+		delivery.Error = fmt.Sprint(err)
+		log.Printf("Warning: Failed to send %s notification via %s: %v", kind, ch.Name, err)
+	}
+
+	a.metrics.notifications.Inc(ch.Name, kind, notificationResult(err))
+	a.report.delivery(delivery)
+	if logErr := a.deliveries.Append(delivery); logErr != nil {
+		log.Printf("Warning: Failed to write delivery log: %v", logErr)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/phhowardchen/case-tracker/internal/config"
+	"github.com/phhowardchen/case-tracker/internal/health"
+	"github.com/phhowardchen/case-tracker/internal/notifier"
+)
+
+// runNotifyTest implements `tracker notify-test`
+// It sends a test email, or a test notification over every configured channel with -all
+func runNotifyTest(args []string) int {
+	fs := flag.NewFlagSet("notify-test", flag.ExitOnError)
+	to := fs.String("to", "", "send the test email here instead of RECIPIENT_EMAIL")
+	all := fs.Bool("all", false, "also test Telegram, Slack and webhooks when configured")
+	fs.Parse(args)
+
+	cfg, err := config.LoadPartial()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return exitConfigError
+	}
+	if *to != "" {
+		// Only to the given address, without the CC and BCC addresses
+		cfg.RecipientEmail, cfg.RecipientEmails = *to, []string{*to}
+		cfg.EmailCC, cfg.EmailBCC = nil, nil
+	}
+	if cfg.Notifier == config.NotifierResend && cfg.ResendAPIKey == "" {
+		fmt.Fprintln(os.Stderr, "RESEND_API_KEY is not set (or set NOTIFIER=smtp)")
+		return exitConfigError
+	}
+	if cfg.Notifier == config.NotifierSMTP && cfg.SMTPHost == "" {
+		fmt.Fprintln(os.Stderr, "SMTP_HOST is not set")
+		return exitConfigError
+	}
+	if cfg.RecipientEmail == "" {
+		fmt.Fprintln(os.Stderr, "RECIPIENT_EMAIL is not set (or pass -to)")
+		return exitConfigError
+	}
+
+	a, err := newApp(cfg, nil, health.NewTracker("notify-test", cfg.CaseIDs))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize: %v\n", err)
+		return exitUnexpected
+	}
+
+	var checks []selftestCheck
+	failed := false
+	for _, ch := range a.notifier.(*notifier.MultiNotifier).Channels() {
+		if ch.Name != "email" && !*all {
+			continue
+		}
+		name, result, detail := a.checkChannel(ch, "notify-test")
+		checks = append(checks, selftestCheck{name: name, result: result, detail: detail})
+		failed = failed || result == checkFail
+	}
+	printSelftest(checks)
+	if failed {
+		return exitUnexpected
+	}
+	return exitOK
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/notifier"
+	"github.com/phhowardchen/case-tracker/internal/storage"
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// Kinds of notifications sent through the outbox
+const (
+	outboxInitial = "initial"
+	outboxChange  = "change"
+)
+
+// deliver commits a notification together with the new state of its results, saves
+// that state and sends the notification: one message, or one per recipient locale
+// A crash or failed send after the commit leaves the entry pending for flushOutbox:
+// the notification is retried until delivered, and since the state is already saved
+// the change is never detected (and emailed) a second time
+func (a *app) deliver(results []*caseResult, kind string, msgs ...notifier.Message) error {
+	entry, queued, err := a.commit(results, kind, msgs[0], msgs[1:])
+	if err != nil {
+		return err
+	}
+	errs := []error{a.sendEntry(entry)}
+	for _, followup := range queued {
+		errs = append(errs, a.sendEntry(followup))
+	}
+	return errors.Join(errs...)
+}
+
+// commit writes a notification and its follow-ups to the outbox in one step, saves the
+// state of its results and queues the follow-ups as entries of their own
+// Nothing is sent yet, so a restart part way through sending the messages of one
+// change (a digest and its per-case messages) leaves the rest pending instead of lost
+// After an error, results that are marked saved are retried from the outbox
+// A dry run saves the state without touching the outbox, and returns the follow-ups
+// without queuing them; sendEntry then only logs the notifications
+func (a *app) commit(results []*caseResult, kind string, msg notifier.Message, followups []notifier.Message) (*storage.OutboxEntry, []*storage.OutboxEntry, error) {
+	entry := newOutboxEntry(kind, msg)
+	entry.Snapshots = make(map[string]map[string]interface{}, len(results))
+	entry.Urgent = slices.ContainsFunc(results, (*caseResult).isCritical)
+	for _, followup := range followups {
+		queued := newOutboxEntry(kind, followup)
+		queued.Urgent = slices.ContainsFunc(results, func(r *caseResult) bool {
+			return slices.Contains(followup.CaseIDs, r.caseID) && r.isCritical()
+		})
+		entry.Followups = append(entry.Followups, queued)
+	}
+	for _, r := range results {
+		if r.saved {
+			// Already committed with another notification of the same cycle
+			continue
+		}
+		// The unredacted status stays in memory for the notification; only storage is redacted
+		entry.Snapshots[r.caseID] = a.redactor.Apply(r.status)
+	}
+	dryRun := a.toggles.isDryRun()
+	if !dryRun {
+		if err := a.outbox.Put(entry); err != nil {
+			return nil, nil, fmt.Errorf("failed to commit notification: %w", err)
+		}
+	}
+
+	for _, r := range results {
+		if snapshot, ok := entry.Snapshots[r.caseID]; ok {
+			a.saveState(r, snapshot)
+			r.saved = true
+		}
+	}
+	if dryRun {
+		return entry, entry.Followups, nil
+	}
+	a.markApplied(entry)
+
+	if len(entry.Followups) == 0 {
+		return entry, nil, nil
+	}
+	queued, err := a.outbox.Expand(entry)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to queue notifications: %w", err)
+	}
+	return entry, queued, nil
+}
+
+// newOutboxEntry converts a message into an outbox entry
+func newOutboxEntry(kind string, msg notifier.Message) *storage.OutboxEntry {
+	return &storage.OutboxEntry{
+		Kind:        kind,
+		CaseIDs:     msg.CaseIDs,
+		Recipients:  msg.Recipients,
+		Subject:     msg.Subject,
+		HTML:        msg.HTML,
+		Text:        msg.Text,
+		Footer:      msg.Footer,
+		Attachments: msg.Attachments,
+		Channels:    msg.Channels,
+	}
+}
+
+// recoverOutbox delivers what the last run left pending (a crash, a deploy, a scale-down)
+// at startup, before a login that may take minutes or fail
+func (a *app) recoverOutbox() {
+	entries, err := a.outbox.Pending()
+	if err != nil {
+		log.Printf("Warning: Failed to read outbox: %v", err)
+		return
+	}
+	due := slices.DeleteFunc(entries, func(entry *storage.OutboxEntry) bool {
+		return entry.NotBefore.After(time.Now())
+	})
+	if len(due) == 0 {
+		return
+	}
+	log.Printf("Outbox: %d notification(s) pending from the last run - delivering them before the first poll", len(due))
+	a.flushOutbox()
+}
+
+// flushOutbox retries every pending notification
+// Entries committed just before a crash get their state saved first. During a dry run
+// pending notifications are left as they are, to go out once it ends
+func (a *app) flushOutbox() {
+	if a.toggles.isDryRun() {
+		return
+	}
+	entries, err := a.pendingOutbox()
+	if err != nil {
+		log.Printf("Warning: Failed to read outbox: %v", err)
+		return
+	}
+
+	pending, held := 0, 0
+	now := time.Now()
+	for _, entry := range entries {
+		if !entry.Applied {
+			for caseID, snapshot := range entry.Snapshots {
+				if err := a.applySnapshot(caseID, snapshot); err != nil {
+					log.Printf("[%s] Warning: Failed to save committed state: %v", caseID, err)
+				}
+			}
+			a.markApplied(entry)
+		}
+		if entry.NotBefore.After(now) {
+			held++
+			continue
+		}
+
+		log.Printf("Outbox: retrying %s notification %s (attempt %d)", entry.Kind, entry.ID, entry.Attempts+1)
+		if err := a.sendEntry(entry); err != nil {
+			log.Printf("Outbox: %s still pending: %v", entry.ID, err)
+			pending++
+			continue
+		}
+		log.Printf("Outbox: %s delivered", entry.ID)
+	}
+	a.metrics.outboxPending.Set(float64(pending))
+	if held > 0 {
+		log.Printf("Outbox: %d notification(s) held for quiet hours, NOTIFY_MAX_PER_HOUR or the Resend quota", held)
+	}
+}
+
+// pendingOutbox returns every undelivered notification
+// Follow-ups of an entry committed just before a crash are queued on their own first
+func (a *app) pendingOutbox() ([]*storage.OutboxEntry, error) {
+	entries, err := a.outbox.Pending()
+	if err != nil {
+		return nil, err
+	}
+	expanded := false
+	for _, entry := range entries {
+		if len(entry.Followups) == 0 {
+			continue
+		}
+		if _, err := a.outbox.Expand(entry); err != nil {
+			return nil, err
+		}
+		expanded = true
+	}
+	if !expanded {
+		return entries, nil
+	}
+	// Re-read rather than append, since some follow-ups may have been queued before the crash
+	return a.outbox.Pending()
+}
+
+// sendEntry sends a committed notification and removes it once delivered
+// Channels in their quiet hours or over their hourly limit get it later (see holdChannels)
+// During a dry run the notifier only logs it, and the outbox is left alone
+func (a *app) sendEntry(entry *storage.OutboxEntry) error {
+	dryRun := a.toggles.isDryRun()
+	if !dryRun && a.holdChannels(entry, time.Now()) {
+		return nil
+	}
+
+	msg := notifier.Message{
+		ID:          entry.ID,
+		CaseIDs:     entry.CaseIDs,
+		Recipients:  entry.Recipients,
+		Subject:     entry.Subject,
+		HTML:        entry.HTML,
+		Text:        entry.Text,
+		Footer:      entry.Footer,
+		Attachments: entry.Attachments,
+		Channels:    entry.Channels,
+		CopiesSent:  entry.CopiesSent,
+	}
+
+	var err error
+	if entry.Kind == outboxInitial {
+		err = a.notifier.SendInitial(msg)
+	} else {
+		err = a.notifier.SendChange(msg)
+	}
+	if dryRun {
+		return err
+	}
+	if err != nil {
+		entry.Attempts++
+		entry.LastError = err.Error()
+		// Recipients the email reached don't get it again on retry
+		var partial *notifier.RecipientsError
+		if errors.As(err, &partial) {
+			entry.Recipients = partial.Failed
+			entry.CopiesSent = entry.CopiesSent || partial.Copied
+		}
+		if updateErr := a.outbox.Update(entry); updateErr != nil {
+			log.Printf("Warning: %v", updateErr)
+		}
+		return err
+	}
+
+	if err := a.outbox.MarkDelivered(entry.ID); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	return nil
+}
+
+// markApplied records that an entry's state has been saved, so a retry never
+// overwrites newer state with it
+func (a *app) markApplied(entry *storage.OutboxEntry) {
+	entry.Applied = true
+	if err := a.outbox.Update(entry); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// applySnapshot saves committed state unless it is already the latest saved state
+func (a *app) applySnapshot(caseID string, snapshot map[string]interface{}) error {
+	store := a.caseStorage(caseID)
+	current, err := store.Load()
+	if err != nil {
+		return err
+	}
+	if current != nil && uscis.Fingerprint(current) == uscis.Fingerprint(snapshot) {
+		return nil
+	}
+	return store.Save(snapshot)
+}
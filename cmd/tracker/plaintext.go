@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/config"
+	"github.com/phhowardchen/case-tracker/internal/locale"
+	"github.com/phhowardchen/case-tracker/internal/notifier"
+	"github.com/phhowardchen/case-tracker/internal/notifier/templates"
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// caseText lists the changes and current status of a case
+// Plain-text parts of change emails are written from the changes rather than converted
+// from the HTML, so text clients get the same "~ Field: old → new" lines as the logs
+func (a *app) caseText(r *caseResult, loc locale.Settings) string {
+	data := a.templateData(r, loc)
+	header := r.caseID
+	if data.Nickname != "" {
+		header += " (" + data.Nickname + ")"
+	}
+	lines := []string{header}
+	if data.Applicant != "" {
+		lines = append(lines, "Applicant: "+data.Applicant)
+	}
+	if r.isFirstRun() {
+		lines = append(lines, "First status check for this receipt.")
+	} else {
+		lines = append(lines, uscis.FormatChanges(templates.LocalizeChanges(r.changes, loc)))
+	}
+	if data.Status != "" {
+		lines = append(lines, "Current status: "+data.Status)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// changeText is the plain-text part of a change email; extrasHTML is what follows the
+// template in the HTML part (notices, acknowledgement and snooze links)
+func (a *app) changeText(r *caseResult, loc locale.Settings, extrasHTML string) string {
+	text := fmt.Sprintf("USCIS case status update detected (%s)\n\n%s\n\nView your cases on myUSCIS: %s",
+		loc.FormatDateTime(time.Now()), a.caseText(r, loc), templates.AccountURL)
+	if extras := notifier.EmailText(extrasHTML); extras != "" {
+		text += "\n\n" + extras
+	}
+	return text
+}
+
+// bundleText is the plain-text part of a bundle email
+func (a *app) bundleText(bundle *config.Bundle, updated []*caseResult, loc locale.Settings) string {
+	sections := []string{fmt.Sprintf("USCIS case status update - %s\n%d receipt(s) in this application were updated at the same time (%s).",
+		bundle.Name, len(updated), loc.FormatDateTime(time.Now()))}
+	for _, r := range updated {
+		sections = append(sections, a.caseText(r, loc))
+	}
+	return strings.Join(sections, "\n\n")
+}
+
+// digestText is the plain-text part of a digest email
+func (a *app) digestText(results []*caseResult, loc locale.Settings) string {
+	sections := []string{fmt.Sprintf("USCIS case status updates\n%d cases changed at the same time (%s).", len(results), loc.FormatDateTime(time.Now()))}
+	for _, r := range results {
+		section := a.caseText(r, loc)
+		if extras := notifier.EmailText(a.digestExtrasHTML(r, loc)); extras != "" {
+			section += "\n" + extras
+		}
+		sections = append(sections, section)
+	}
+	return strings.Join(sections, "\n\n")
+}
@@ -0,0 +1,695 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/config"
+	"github.com/phhowardchen/case-tracker/internal/locale"
+	"github.com/phhowardchen/case-tracker/internal/notifier"
+	"github.com/phhowardchen/case-tracker/internal/notifier/templates"
+	"github.com/phhowardchen/case-tracker/internal/source"
+	"github.com/phhowardchen/case-tracker/internal/storage"
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// caseResult is the outcome of checking one case during a poll cycle
+type caseResult struct {
+	caseID   string
+	storage  storage.Storage
+	previous map[string]interface{}
+	status   map[string]interface{}
+	current  *uscis.CaseStatus // typed view of status
+	changes  []uscis.Change
+	event    uscis.Event   // what the changes amount to, e.g. an RFE
+	saved    bool          // status was committed with its notification in the outbox
+	ackID    string        // acknowledgement requested in the notification, if it was critical
+	notices  []savedNotice // notices new in this status, downloaded for NOTICE_PDFS
+}
+
+// isFirstRun reports whether there was no saved state for the case
+func (r *caseResult) isFirstRun() bool {
+	return r.previous == nil
+}
+
+// isCritical reports whether the case just moved into a status that asks the applicant
+// to act or show up, like an RFE or a scheduled interview
+func (r *caseResult) isCritical() bool {
+	if r.isFirstRun() {
+		return false
+	}
+	summary := uscis.StatusSummary(r.status)
+	return uscis.IsCritical(summary) && summary != uscis.StatusSummary(r.previous)
+}
+
+// needsNotification reports whether the result should produce an email
+func (r *caseResult) needsNotification() bool {
+	return r.isFirstRun() || len(r.changes) > 0
+}
+
+// pollCases checks the scheduled cases, then dispatches the notifications for the cycle
+// Collecting results first lets related receipts be combined into one email
+func (a *app) pollCases(phase string) {
+	if a.toggles.isPaused() {
+		log.Printf("Polling is paused via the admin API - skipping %s", phase)
+		// Paused on purpose: keep the heartbeat fresh so the healthcheck doesn't restart the container
+		if err := storage.WriteHeartbeat(a.cfg.StateFileDir, time.Now()); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		return
+	}
+
+	// Deliver what a failed send or a crash left behind before looking for new changes
+	a.flushOutbox()
+
+	now := time.Now()
+	due := a.scheduler.due(now)
+	if phase == "poll" && len(due) > 0 {
+		log.Printf("Polling %d case(s)...", len(due))
+	}
+	a.runCycle(phase, now, due)
+}
+
+// runCycle checks the given cases within the cycle and request budgets, dispatches the
+// notifications and returns the results of the cases that were fetched
+func (a *app) runCycle(phase string, now time.Time, caseIDs []string) []*caseResult {
+	c := a.scheduler.start(now, caseIDs, a.requestAllowance(now, len(caseIDs)))
+	results := a.checkCases(c, phase)
+
+	if skipped := c.skipped(); len(skipped) > 0 && a.ctx.Err() != nil {
+		// They are polled first thing on the next start
+		log.Printf("Shutting down - %d case(s) not checked: %v", len(skipped), skipped)
+	} else if len(skipped) > 0 && c.limited() {
+		// Held back on purpose to stay within the daily request budget
+		log.Printf("Daily request budget - %d case(s) deferred to a later cycle: %v", len(skipped), skipped)
+		for _, caseID := range skipped {
+			a.report.caseDeferred(caseID)
+		}
+	} else if len(skipped) > 0 {
+		log.Printf("Poll cycle budget (%v) exhausted - %d case(s) carried over to the next cycle: %v", a.cfg.PollCycleBudget, len(skipped), skipped)
+		for _, caseID := range skipped {
+			a.health.RecordSkipped(caseID)
+		}
+	}
+	c.finish()
+
+	// Also on shutdown: what was fetched is saved and notified before exiting
+	a.dispatch(results)
+	if a.ctx.Err() != nil {
+		return results
+	}
+	a.escalateAcks()
+
+	now = time.Now()
+	a.checkCanary(now)
+	a.metrics.polls.Inc()
+	a.metrics.lastPoll.Set(float64(now.Unix()))
+	if err := storage.WriteHeartbeat(a.cfg.StateFileDir, now); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	return results
+}
+
+// checkCases fetches the cycle's cases with up to POLL_WORKERS fetches in flight
+// A case is only handed out once a worker is free, so the cycle budget still
+// decides which cases start; results keep the schedule order
+func (a *app) checkCases(c *cycle, phase string) []*caseResult {
+	type job struct {
+		index  int
+		caseID string
+	}
+
+	jobs := make(chan job)
+	checked := make([]*caseResult, len(c.order))
+	var wg sync.WaitGroup
+	for i := 0; i < a.cfg.PollWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				result, err := a.checkCase(j.caseID)
+				if err != nil && a.ctx.Err() != nil {
+					// Aborted by the shutdown, not a failure of the case
+					log.Printf("[%s] Not checked during %s: shutting down", j.caseID, phase)
+					a.report.caseDeferred(j.caseID)
+					continue
+				}
+				var held *uscis.ErrLoginHeld
+				var open *errCircuitOpen
+				if errors.As(err, &held) || errors.As(err, &open) {
+					// Not a failure: the case is checked again once the login goes ahead
+					// or the circuit breaker's cooldown is over
+					log.Printf("[%s] Not checked during %s: %v", j.caseID, phase, err)
+					a.health.RecordSkipped(j.caseID)
+					a.report.caseDeferred(j.caseID)
+					continue
+				}
+				if err != nil {
+					log.Printf("[%s] Error during %s: %v", j.caseID, phase, err)
+					a.health.RecordFailure(j.caseID, err)
+					a.report.caseFailed(j.caseID, err)
+					continue
+				}
+				checked[j.index] = result
+			}
+		}()
+	}
+
+	for index := 0; a.ctx.Err() == nil; index++ {
+		// Space the fetches out randomly so they don't hit USCIS back to back
+		if index > 0 && c.pending() && a.pauseBetweenRequests() != nil {
+			break
+		}
+		caseID, ok := c.next()
+		if !ok {
+			break
+		}
+		jobs <- job{index: index, caseID: caseID}
+	}
+	close(jobs)
+	wg.Wait()
+
+	var results []*caseResult
+	for _, result := range checked {
+		if result != nil {
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+// checkCase fetches a case and compares it with the last saved state
+func (a *app) checkCase(caseID string) (*caseResult, error) {
+	log.Printf("Fetching case status for %s...", caseID)
+
+	// Create storage for this specific case
+	stateStorage := a.caseStorage(caseID)
+
+	// Load previous state for this case
+	previousState, err := stateStorage.Load()
+	if err != nil {
+		log.Printf("Warning: Failed to load previous state for %s: %v", caseID, err)
+	}
+	if previousState == nil {
+		if err := a.staggerBootstrap(caseID); err != nil {
+			return nil, err
+		}
+	}
+
+	// Fetch case status from the case's source
+	fetcher, err := a.sources.For(caseID)
+	if err != nil {
+		return nil, err
+	}
+	if !a.cfg.IsCanary(caseID) {
+		if err := a.breaker.allow(caseID, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	a.countRequest(caseID)
+	fetchStart := time.Now()
+	status, err := fetcher.FetchCaseStatus(caseID)
+	a.metrics.observeFetch(caseID, a.sources.SourceOf(caseID), time.Since(fetchStart), err)
+	if recycleErr := a.watchdog.observe(fetcher, caseID, err); recycleErr != nil {
+		log.Printf("[%s] Watchdog: %v", caseID, recycleErr)
+		a.sendRecycleFailedAlert(recycleErr)
+	}
+	// A held login, a malformed receipt number or a shutdown says nothing about USCIS,
+	// and the canary never fails
+	var held *uscis.ErrLoginHeld
+	if !errors.As(err, &held) && !errors.Is(err, uscis.ErrInvalidReceipt) && a.ctx.Err() == nil && !a.cfg.IsCanary(caseID) {
+		a.observeFetch(caseID, err)
+	}
+	if name := a.sources.SourceOf(caseID); err == nil && (name == source.MyUSCIS || source.IsAccount(name)) {
+		a.health.RecordAuthSuccess()
+		a.loginAccepted()
+	}
+	if err != nil {
+		var timeoutErr *uscis.ErrFetchTimeout
+		if errors.As(err, &timeoutErr) {
+			// Record the timeout and let the caller move on to the next case
+			return nil, fmt.Errorf("fetch timed out: %w", err)
+		}
+		if errors.As(err, &held) {
+			return nil, err
+		}
+		if errors.Is(err, uscis.ErrInvalidReceipt) || errors.Is(err, uscis.ErrCaseNotFound) {
+			log.Printf("Warning: Check the receipt number of this case: %v", err)
+			return nil, err
+		}
+
+		// Check if it's an authentication error (both manual cookie and browser auto-login modes)
+		var authErr *uscis.ErrAuthenticationFailed
+		if errors.As(err, &authErr) {
+			log.Printf("Authentication failed! Sending email notification...")
+			// Send alert email (works for both modes)
+			a.sendAuthFailureEmail(err, "polling")
+			return nil, fmt.Errorf("authentication failed: %w", err)
+		}
+		if uscis.IsLoginBlocked(err) {
+			a.loginRefused(err)
+			return nil, fmt.Errorf("login refused: %w", err)
+		}
+
+		// Keep unparseable responses (WAF pages, truncated bodies) for inspection
+		var parseErr *uscis.ParseError
+		if errors.As(err, &parseErr) {
+			a.recordDeadLetter(caseID, parseErr)
+		}
+
+		return nil, fmt.Errorf("failed to fetch case status: %w", err)
+	}
+
+	log.Printf("Case status fetched successfully")
+	if a.cfg.FetchCaseHistory {
+		status, previousState = a.withCaseHistory(caseID, fetcher, previousState, status)
+	}
+	// The same data must compare, save and hash the same whichever fetch path produced it
+	status = uscis.Canonicalize(status)
+
+	// A payload for another case or account must never be diffed into a notification
+	if anomalies := a.payloadAnomalies(caseID, previousState, status); len(anomalies) > 0 {
+		a.quarantinePayload(caseID, status, anomalies)
+		return nil, fmt.Errorf("quarantined anomalous payload: %s", strings.Join(anomalies, "; "))
+	}
+
+	changes := a.detectChanges(caseID, previousState, status)
+	var notices []savedNotice
+	if a.notices != nil && previousState != nil {
+		notices = a.downloadNotices(caseID, fetcher, previousState, status)
+	}
+	return &caseResult{
+		caseID:   caseID,
+		storage:  stateStorage,
+		previous: previousState,
+		status:   status,
+		current:  uscis.NewCaseStatus(status),
+		changes:  changes,
+		event:    uscis.Classify(changes),
+		notices:  notices,
+	}, nil
+}
+
+// detectChanges compares a fetched status with the previous state of a case, leaving
+// out the fields of CHANGE_IGNORE_FIELDS
+// The fetched payload is compared unredacted: with the status saved last by this process
+// when the saved state is still its redacted form, and with the saved state otherwise
+func (a *app) detectChanges(caseID string, previous, status map[string]interface{}) []uscis.Change {
+	var allChanges []uscis.Change
+	if saved := a.unredacted(caseID, previous); saved != nil {
+		allChanges = uscis.DetectStatusChanges(uscis.NewCaseStatus(saved), uscis.NewCaseStatus(status))
+	} else {
+		allChanges = a.storedChanges(previous, status,
+			uscis.DetectStatusChanges(uscis.NewCaseStatus(previous), uscis.NewCaseStatus(status)))
+	}
+	changes := a.changeOptions().Filter(allChanges)
+	if ignored := len(allChanges) - len(changes); ignored > 0 {
+		log.Printf("[%s] Ignoring %d change(s) in CHANGE_IGNORE_FIELDS", caseID, ignored)
+	}
+	return changes
+}
+
+// unredacted returns the status a saved state was redacted from, if this process saved it
+func (a *app) unredacted(caseID string, previous map[string]interface{}) map[string]interface{} {
+	if previous == nil {
+		return nil
+	}
+	a.savedMu.Lock()
+	saved := a.saved[caseID]
+	a.savedMu.Unlock()
+	if saved == nil || uscis.Fingerprint(a.redactor.Apply(saved)) != uscis.Fingerprint(previous) {
+		return nil
+	}
+	return saved
+}
+
+// storedChanges keeps the changes of a fetched payload that are changes of what is saved
+// The saved state only holds the redacted form of REDACT_FIELDS, so comparing it with the
+// fetched payload would report those fields on every poll. Which fields changed is decided
+// on the redacted forms (the previous state is redacted again in case the rules were
+// added after it was saved); the changes keep the values fetched from USCIS
+func (a *app) storedChanges(previous, status map[string]interface{}, changes []uscis.Change) []uscis.Change {
+	stored := uscis.DetectStatusChanges(
+		uscis.NewCaseStatus(a.redactor.Apply(previous)),
+		uscis.NewCaseStatus(a.redactor.Apply(status)),
+	)
+	// Fields reported more than once ("New Action") are the same either way
+	fetched := make(map[string][]uscis.Change, len(changes))
+	for _, change := range changes {
+		fetched[change.Field] = append(fetched[change.Field], change)
+	}
+	kept := make([]uscis.Change, 0, len(stored))
+	for _, change := range stored {
+		if raw := fetched[change.Field]; len(raw) == 1 {
+			change.NewValue = raw[0].NewValue
+		}
+		kept = append(kept, change)
+	}
+	return kept
+}
+
+// payloadAnomalies returns why a fetched payload doesn't fit the case, if it doesn't
+// The receipt number is checked on the raw payload; the shape is compared as stored
+func (a *app) payloadAnomalies(caseID string, previous, status map[string]interface{}) []string {
+	if !a.cfg.AnomalyCheck || a.acceptAnomalies {
+		return nil
+	}
+	var anomalies []string
+	if anomaly := uscis.ReceiptAnomaly(caseID, uscis.NewCaseStatus(status)); anomaly != "" {
+		anomalies = append(anomalies, anomaly)
+	}
+	return append(anomalies, uscis.ShapeAnomalies(
+		uscis.NewCaseStatus(a.redactor.Apply(previous)),
+		uscis.NewCaseStatus(a.redactor.Apply(status)),
+	)...)
+}
+
+// changeOptions returns the change detection settings from the config
+func (a *app) changeOptions() uscis.ChangeOptions {
+	return uscis.ChangeOptions{IgnoreFields: a.cfg.ChangeIgnoreFields}
+}
+
+// dispatch sends the notifications for a poll cycle and saves state for delivered results
+// Results from the same application bundle are combined into a single email, and
+// many first-run results are combined into one summary
+func (a *app) dispatch(results []*caseResult) {
+	canary, results := a.splitCanary(results)
+	if canary != nil {
+		a.complete([]*caseResult{canary}, a.notifyCase(canary))
+	}
+
+	bootstrap, results := a.splitBootstrap(results)
+	// A summary only lists cases that go to the same people
+	for _, group := range a.groupByRecipients(bootstrap) {
+		if len(group) == 1 {
+			a.complete(group, a.notifyCase(group[0]))
+			continue
+		}
+		a.complete(group, a.notifyBootstrap(group))
+	}
+
+	byCase := make(map[string]*caseResult, len(results))
+	bundled := make(map[string][]*caseResult)
+	var bundleOrder []*config.Bundle
+	var changed []*caseResult // unbundled changes, sent after the loop so they can be combined
+
+	for _, r := range results {
+		byCase[r.caseID] = r
+		if len(r.changes) > 0 {
+			a.metrics.changes.Inc(r.caseID)
+		}
+
+		if !r.needsNotification() {
+			log.Printf("[%s] No changes detected - skipping email notification", r.caseID)
+			a.health.RecordSuccess(r.caseID)
+			a.report.caseChecked(r, outcomeUnchanged, nil)
+			continue
+		}
+
+		if until, snoozed := a.snoozedUntil(r.caseID); snoozed {
+			// Keep recording history; only the email is muted
+			log.Printf("[%s] Update detected but notifications are snoozed until %s", r.caseID, until.Format(time.RFC3339))
+			a.complete([]*caseResult{r}, nil)
+			a.report.caseChecked(r, outcomeSnoozed, nil)
+			continue
+		}
+
+		if bundle := a.cfg.BundleFor(r.caseID); bundle != nil {
+			if _, seen := bundled[bundle.Name]; !seen {
+				bundleOrder = append(bundleOrder, bundle)
+			}
+			bundled[bundle.Name] = append(bundled[bundle.Name], r)
+			continue
+		}
+
+		if !r.isFirstRun() {
+			changed = append(changed, r)
+			continue
+		}
+		a.complete([]*caseResult{r}, a.notifyCase(r))
+	}
+
+	a.dispatchChanges(changed)
+
+	for _, bundle := range bundleOrder {
+		members := bundled[bundle.Name]
+		if len(members) == 1 {
+			// A lone update doesn't need the combined template
+			a.complete(members, a.notifyCase(members[0]))
+			continue
+		}
+		a.complete(members, a.notifyBundle(bundle, members, byCase))
+	}
+}
+
+// notifyCase sends the initial-status or change email for a single case
+func (a *app) notifyCase(r *caseResult) error {
+	if r.isFirstRun() {
+		log.Printf("[%s] First run - sending initial status email", r.caseID)
+		subject := fmt.Sprintf("%s - Initial Status for %s", a.cfg.BrandName, a.cfg.CaseLabel(r.caseID))
+		msgs := a.localized([]string{r.caseID}, func(loc locale.Settings) notifier.Message {
+			return a.message([]string{r.caseID}, subject, a.renderCase(templates.Initial, r, loc))
+		})
+		if err := a.deliver([]*caseResult{r}, outboxInitial, msgs...); err != nil {
+			return fmt.Errorf("failed to send initial email: %w", err)
+		}
+		log.Printf("[%s] Initial status email sent successfully", r.caseID)
+		return nil
+	}
+
+	log.Printf("[%s] Changes detected: %d fields changed (%s, severity %s)", r.caseID, len(r.changes), r.event.Type, r.event.Severity)
+	msgs := a.routeAllBySeverity(a.changeMessages(r, nil), r.event.Severity)
+	if len(msgs) == 0 {
+		log.Printf("[%s] No channel takes %s changes (SEVERITY_CHANNELS) - not sending a notification", r.caseID, r.event.Severity)
+		return nil
+	}
+	if err := a.deliver([]*caseResult{r}, outboxChange, msgs...); err != nil {
+		return fmt.Errorf("failed to send change notification: %w", err)
+	}
+	log.Printf("[%s] Change notification email sent successfully", r.caseID)
+	return nil
+}
+
+// changeMessages renders the change notification of a single case, once per recipient
+// locale, for the given channels (nil: every channel)
+func (a *app) changeMessages(r *caseResult, channels []string) []notifier.Message {
+	subject := changeSubject(r.event, a.cfg.CaseLabel(r.caseID))
+	return a.localized([]string{r.caseID}, func(loc locale.Settings) notifier.Message {
+		extras := a.noticesHTML(r, loc) + a.ackLinkHTML(r) + a.snoozeLinksHTML(r.caseID)
+		msg := a.message([]string{r.caseID}, subject, a.renderCase(templates.Change, r, loc)+extras)
+		msg.Text = a.changeText(r, loc, extras)
+		msg.Attachments = a.noticeAttachments(r)
+		msg.Channels = channels
+		return msg
+	})
+}
+
+// notifyBundle sends one combined email for several updated receipts of a bundle
+func (a *app) notifyBundle(bundle *config.Bundle, updated []*caseResult, byCase map[string]*caseResult) error {
+	log.Printf("[Bundle: %s] %d of %d receipts updated - sending combined email", bundle.Name, len(updated), len(bundle.CaseIDs))
+
+	event := topEvent(updated)
+	subject := changeSubject(event, fmt.Sprintf("%s (%d receipts)", bundle.Name, len(updated)))
+	msgs := a.localized(bundle.CaseIDs, func(loc locale.Settings) notifier.Message {
+		msg := a.message(bundle.CaseIDs, subject, formatBundleEmail(bundle, updated, byCase, a.caseRenderer(loc), loc))
+		msg.Text = a.bundleText(bundle, updated, loc)
+		return msg
+	})
+	msgs = a.routeAllBySeverity(msgs, event.Severity)
+	if len(msgs) == 0 {
+		log.Printf("[Bundle: %s] No channel takes %s changes (SEVERITY_CHANNELS) - not sending a notification", bundle.Name, event.Severity)
+		return nil
+	}
+	if err := a.deliver(updated, outboxChange, msgs...); err != nil {
+		return fmt.Errorf("failed to send bundle notification for %s: %w", bundle.Name, err)
+	}
+
+	log.Printf("[Bundle: %s] Combined email sent successfully", bundle.Name)
+	return nil
+}
+
+// complete records the outcome of a notification and saves state for results without one
+// Notified results were saved when their notification was committed to the outbox; if it
+// couldn't be committed nothing is saved, so the change is detected again next poll
+func (a *app) complete(results []*caseResult, notifyErr error) {
+	for _, r := range results {
+		outcome := outcomeChanged
+		if r.isFirstRun() {
+			outcome = outcomeFirstRun
+		}
+		a.report.caseChecked(r, outcome, notifyErr)
+		a.trackAck(r, notifyErr)
+
+		if notifyErr != nil {
+			if r.saved {
+				log.Printf("[%s] Notification failed, will retry from the outbox: %v", r.caseID, notifyErr)
+			} else {
+				log.Printf("[%s] Notification failed: %v", r.caseID, notifyErr)
+			}
+			a.health.RecordFailure(r.caseID, notifyErr)
+			continue
+		}
+
+		if !r.saved {
+			// Snoozed results have no notification to commit
+			// The unredacted status stays in memory for the notification; only storage is redacted
+			a.saveState(r, a.redactor.Apply(r.status))
+		}
+		a.health.RecordSuccess(r.caseID)
+		a.celebrateIfApproved(r)
+	}
+}
+
+// saveState saves a case's new state, checking first that no other instance owns it
+func (a *app) saveState(r *caseResult, state map[string]interface{}) {
+	a.checkStateOwner(r)
+	if err := r.storage.Save(state); err != nil {
+		log.Printf("Warning: Failed to save state: %v", err)
+		return
+	}
+	a.savedMu.Lock()
+	a.saved[r.caseID] = r.status
+	a.savedMu.Unlock()
+}
+
+// stateMetaStore is implemented by storage backends that record who wrote a case's state
+type stateMetaStore interface {
+	LoadMeta() (*storage.StateMeta, error)
+	SaveMeta(meta storage.StateMeta) error
+}
+
+// checkStateOwner warns when another tracker instance last wrote this case's state
+// Two deployments writing the same state is the usual cause of duplicate emails
+func (a *app) checkStateOwner(r *caseResult) {
+	metaStore, ok := r.storage.(stateMetaStore)
+	if !ok {
+		return
+	}
+
+	meta, err := metaStore.LoadMeta()
+	if err != nil {
+		log.Printf("[%s] Warning: %v", r.caseID, err)
+	}
+	if meta != nil && meta.InstanceID != a.instanceID {
+		log.Printf("[%s] WARNING: state was last written by instance %s (host %s, %s) - this is instance %s. Are two trackers running?",
+			r.caseID, meta.InstanceID, meta.Hostname, meta.UpdatedAt.Format(time.RFC3339), a.instanceID)
+		if !a.warnedOwner[r.caseID] {
+			a.warnedOwner[r.caseID] = true
+			a.sendDuplicateInstanceAlert(r.caseID, meta)
+		}
+	}
+
+	if err := metaStore.SaveMeta(storage.StateMeta{InstanceID: a.instanceID, Hostname: a.hostname, UpdatedAt: time.Now()}); err != nil {
+		log.Printf("[%s] Warning: Failed to save state metadata: %v", r.caseID, err)
+	}
+}
+
+// sendDuplicateInstanceAlert tells the user that two instances appear to share state
+func (a *app) sendDuplicateInstanceAlert(caseID string, meta *storage.StateMeta) {
+	subject := fmt.Sprintf("%s - Possible Duplicate Deployment (%s)", a.cfg.BrandName, caseID)
+	body := fmt.Sprintf(`
+		<h2>⚠️ Possible Duplicate Deployment</h2>
+		<p>The state for case <strong>%s</strong> was last written by a different tracker instance.</p>
+		<ul>
+			<li><strong>Other instance:</strong> %s (host %s, at %s)</li>
+			<li><strong>This instance:</strong> %s (host %s)</li>
+		</ul>
+		<p>If two trackers are running against the same state, every update will be emailed twice. Check for leftover deployments (Cloud Run revisions, GCE containers, local runs).</p>
+		<p>If the previous instance was simply replaced (e.g. a redeploy with a fresh state volume), you can ignore this message.</p>
+	`, caseID, meta.InstanceID, meta.Hostname, a.recipientLocale().FormatDateTime(meta.UpdatedAt), a.instanceID, a.hostname)
+
+	if err := a.sendAlert([]string{caseID}, subject, body); err != nil {
+		log.Printf("[%s] Failed to send duplicate instance alert: %v", caseID, err)
+	}
+}
+
+// recipientLocale returns the date rendering settings for the notification recipient
+func (a *app) recipientLocale() locale.Settings {
+	return a.cfg.LocaleFor(a.cfg.RecipientEmail)
+}
+
+// localeFor returns the date rendering settings of the first recipient of the given cases,
+// for what is rendered once for everyone, such as reports
+// Notifications are rendered per recipient locale instead (see localized)
+func (a *app) localeFor(caseIDs []string) locale.Settings {
+	return a.cfg.LocaleFor(a.cfg.RecipientsFor(caseIDs)[0])
+}
+
+// localeGroup is the recipients of a notification who get dates rendered the same way
+type localeGroup struct {
+	loc        locale.Settings
+	recipients []string
+}
+
+// localeGroups splits the recipients of the given cases by their RECIPIENT_LOCALES
+// settings. Groups keep the order of their first recipient
+func (a *app) localeGroups(caseIDs []string) []localeGroup {
+	var groups []localeGroup
+	index := make(map[string]int)
+	for _, recipient := range a.cfg.RecipientsFor(caseIDs) {
+		loc := a.cfg.LocaleFor(recipient)
+		key := loc.Location.String() + " " + loc.Locale
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, localeGroup{loc: loc})
+		}
+		groups[i].recipients = append(groups[i].recipients, recipient)
+	}
+	if len(groups) == 0 {
+		groups = append(groups, localeGroup{loc: a.cfg.Locale})
+	}
+	return groups
+}
+
+// localized renders a notification about the given cases once per locale group of their
+// recipients, so a beneficiary abroad gets dates in their own timezone
+// The first group's message goes to the channels render chose; the others only email
+// their group, so chat channels, webhooks and CC addresses get the notification once
+func (a *app) localized(caseIDs []string, render func(loc locale.Settings) notifier.Message) []notifier.Message {
+	groups := a.localeGroups(caseIDs)
+	if len(groups) == 1 {
+		return []notifier.Message{render(groups[0].loc)}
+	}
+	var msgs []notifier.Message
+	for i, group := range groups {
+		msg := render(group.loc)
+		msg.Recipients = group.recipients
+		if i > 0 {
+			if len(msg.Channels) > 0 && !slices.Contains(msg.Channels, "email") {
+				continue
+			}
+			msg.Channels = []string{"email"}
+			// The CC and BCC addresses get the first group's copy only
+			msg.CopiesSent = true
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+// groupByRecipients splits results into groups whose notifications go to the same people
+// and the same per-case webhooks
+// Groups keep the order of their first result
+func (a *app) groupByRecipients(results []*caseResult) [][]*caseResult {
+	var groups [][]*caseResult
+	index := make(map[string]int)
+	for _, r := range results {
+		caseIDs := []string{r.caseID}
+		key := strings.ToLower(strings.Join(a.cfg.RecipientsFor(caseIDs), ",")) + " " + strings.Join(a.cfg.WebhooksFor(caseIDs), ",") + " " + a.cfg.TenantName(r.caseID)
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], r)
+	}
+	return groups
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/lease"
+)
+
+// pollLockFile is the lease file in STATE_FILE_DIR with POLL_LOCK=file
+const pollLockFile = "poll.lease"
+
+// pollLock keeps this instance the only one logging in, polling and notifying while
+// POLL_LOCK is set: the lease is renewed every third of POLL_LOCK_TTL, and standbys
+// take it over once it runs out
+type pollLock struct {
+	locker   lease.Locker
+	ttl      time.Duration
+	holder   string // instance ID and a nonce of this process, see leaseHolder
+	hostname string
+
+	renewMu  sync.Mutex // keeps a renewal from racing the release at exit
+	released bool
+
+	mu      sync.Mutex
+	current lease.Lease // last lease seen, ours or another instance's
+	held    bool
+	lost    bool // held, then taken over or not renewed in time
+}
+
+// pollLockState is the poll lock at /status
+type pollLockState struct {
+	Location string    `json:"location"`
+	Held     bool      `json:"held"`
+	Holder   string    `json:"holder,omitempty"`
+	Hostname string    `json:"hostname,omitempty"`
+	Expires  time.Time `json:"expires,omitempty"`
+}
+
+// openPollLock opens the lease POLL_LOCK points at
+func (a *app) openPollLock() (*pollLock, error) {
+	var locker lease.Locker
+	if a.cfg.PollLock == "postgres" {
+		locker = a.statePG.Lease("poll")
+	} else {
+		location := a.cfg.PollLock
+		if location == "file" {
+			location = filepath.Join(a.cfg.StateFileDir, pollLockFile)
+		}
+		var err error
+		if locker, err = lease.Open(location); err != nil {
+			return nil, err
+		}
+	}
+	holder, err := leaseHolder(a.instanceID)
+	if err != nil {
+		return nil, err
+	}
+	return &pollLock{locker: locker, ttl: a.cfg.PollLockTTL, holder: holder, hostname: a.hostname}, nil
+}
+
+// leaseHolder returns the token this process holds the lease as: the instance ID and
+// a random nonce. Instances sharing STATE_FILE_DIR or INSTANCE_ID have the same instance
+// ID, and the lease would take one for a renewal by the other
+func leaseHolder(instanceID string) (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to create poll lock holder token: %w", err)
+	}
+	return instanceID + "/" + hex.EncodeToString(buf), nil
+}
+
+// holderInstance returns the instance ID of a lease holder token, for display
+func holderInstance(holder string) string {
+	if i := strings.LastIndex(holder, "/"); i >= 0 {
+		return holder[:i]
+	}
+	return holder
+}
+
+// tryAcquire takes or renews the lease once
+func (l *pollLock) tryAcquire() (bool, error) {
+	current, acquired, err := l.locker.Acquire(l.holder, l.hostname, l.ttl)
+	if err != nil {
+		return false, err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.current = current
+	l.held = acquired
+	return acquired, nil
+}
+
+// wait blocks until this instance holds the lease, retrying every quarter of the TTL
+// Returns the context's error if it ends first
+func (l *pollLock) wait(ctx context.Context) error {
+	standby := false
+	for {
+		acquired, err := l.tryAcquire()
+		switch {
+		case err != nil:
+			log.Printf("Warning: Failed to acquire the poll lock: %v", err)
+		case acquired:
+			if standby {
+				log.Printf("Poll lock acquired, taking over polling")
+			}
+			return nil
+		case !standby:
+			current := l.state()
+			log.Printf("Standby: poll lock %s is held by %s (%s) until %s; waiting to take over",
+				l.locker, current.Holder, current.Hostname, current.Expires.Local().Format("15:04:05"))
+			standby = true
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(l.ttl / 4):
+		}
+	}
+}
+
+// keep renews the lease until ctx ends, and calls lost if it was taken over or can't
+// be renewed in time, so this instance stops before another starts polling
+func (l *pollLock) keep(ctx context.Context, lost func()) {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		l.renewMu.Lock()
+		if l.released {
+			l.renewMu.Unlock()
+			return
+		}
+		expires := l.state().Expires
+		acquired, err := l.tryAcquire()
+		l.renewMu.Unlock()
+		reason := ""
+		switch {
+		case err != nil && time.Until(expires) < l.ttl/2:
+			// The next attempt would come after the lease expired and a standby took it
+			reason = fmt.Sprintf("could not be renewed before it expires: %v", err)
+		case err != nil:
+			log.Printf("Warning: Failed to renew the poll lock (expires %s): %v", expires.Local().Format("15:04:05"), err)
+		case !acquired:
+			current := l.state()
+			reason = fmt.Sprintf("was taken over by %s (%s)", current.Holder, current.Hostname)
+		}
+		if reason != "" {
+			log.Printf("ERROR: The poll lock %s; stopping", reason)
+			l.mu.Lock()
+			l.held, l.lost = false, true
+			l.mu.Unlock()
+			lost()
+			return
+		}
+	}
+}
+
+// release gives the lease up so a standby can take over without waiting for it to expire
+func (l *pollLock) release() {
+	l.renewMu.Lock()
+	defer l.renewMu.Unlock()
+	l.released = true
+
+	l.mu.Lock()
+	held := l.held
+	l.held = false
+	l.mu.Unlock()
+	if !held {
+		return
+	}
+	if err := l.locker.Release(l.holder); err != nil {
+		log.Printf("Warning: Failed to release the poll lock: %v", err)
+		return
+	}
+	log.Printf("Released the poll lock")
+}
+
+// wasLost reports whether the lease was lost while polling
+func (l *pollLock) wasLost() bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lost
+}
+
+// state returns the lease as last seen
+func (l *pollLock) state() pollLockState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return pollLockState{
+		Location: l.locker.String(),
+		Held:     l.held,
+		Holder:   holderInstance(l.current.Holder),
+		Hostname: l.current.Hostname,
+		Expires:  l.current.Expires,
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/health"
+	"github.com/phhowardchen/case-tracker/internal/source"
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// preflightTimeout bounds each endpoint probe of the startup connectivity check
+const preflightTimeout = 10 * time.Second
+
+// preflightHints explain what each connectivity problem usually means
+var preflightHints = map[string]string{
+	uscis.ProblemDNS:         "DNS resolution failed - check the DNS settings of the host or container network",
+	uscis.ProblemConnect:     "no connection - the host probably has no outbound internet access (firewall, VPC egress or proxy settings)",
+	uscis.ProblemTimeout:     "no answer in time - outbound traffic may be dropped by a firewall, or USCIS is very slow",
+	uscis.ProblemTLS:         "TLS failed - an intercepting proxy or missing CA certificates (install ca-certificates)",
+	uscis.ProblemBlocked:     "rejected by the USCIS firewall - this IP is likely rate limited or blocked; this is not a password problem",
+	uscis.ProblemUnavailable: "USCIS answered with a server error - likely an outage; polling will keep retrying",
+}
+
+// runPreflight checks that the USCIS endpoints needed by the configured sources are
+// reachable, before any login, so network problems are never reported as auth failures
+// Returns an error only with PREFLIGHT_CHECK=strict
+func (a *app) runPreflight(strategy string) error {
+	if a.cfg.PreflightCheck == "off" {
+		return nil
+	}
+
+	usesMyUSCIS := a.cfg.UsesSource(source.MyUSCIS) || len(a.cfg.Accounts) > 0
+	urls := uscis.PreflightURLs(
+		usesMyUSCIS && strategy != strategyPublic,
+		a.cfg.UsesSource(source.Public) || (usesMyUSCIS && strategy == strategyPublic),
+	)
+	if len(urls) == 0 {
+		return nil
+	}
+
+	log.Printf("Pre-flight: checking connectivity to %d USCIS endpoint(s)...", len(urls))
+	checks := uscis.CheckConnectivity(urls, preflightTimeout)
+
+	endpoints := make([]health.EndpointStatus, 0, len(checks))
+	var problems []uscis.EndpointCheck
+	for _, check := range checks {
+		endpoints = append(endpoints, health.EndpointStatus{
+			URL:        check.URL,
+			Problem:    check.Problem,
+			Detail:     check.Detail,
+			StatusCode: check.StatusCode,
+			LatencyMS:  float64(check.Latency.Microseconds()) / 1000,
+		})
+		if check.OK() {
+			log.Printf("Pre-flight: %s reachable (HTTP %d, %v)", check.URL, check.StatusCode, check.Latency.Round(time.Millisecond))
+			continue
+		}
+		problems = append(problems, check)
+		log.Printf("Pre-flight: WARNING: %s unreachable [%s]: %s", check.URL, check.Problem, check.Detail)
+		log.Printf("Pre-flight:   %s", preflightHints[check.Problem])
+	}
+	a.health.SetConnectivity(endpoints)
+
+	if len(problems) == 0 {
+		log.Printf("Pre-flight: all USCIS endpoints reachable")
+		return nil
+	}
+	if a.cfg.PreflightAlert {
+		a.sendPreflightAlert(problems)
+	}
+	if a.cfg.PreflightCheck == "strict" {
+		return fmt.Errorf("USCIS unreachable: %s (%s)", problems[0].URL, problems[0].Problem)
+	}
+	log.Printf("Pre-flight: continuing anyway (PREFLIGHT_CHECK=warn); fetch errors until this is fixed are network errors, not authentication failures")
+	return nil
+}
+
+// sendPreflightAlert notifies about the connectivity problems found at startup
+func (a *app) sendPreflightAlert(problems []uscis.EndpointCheck) {
+	var rows []string
+	for _, p := range problems {
+		rows = append(rows, fmt.Sprintf("<li><strong>%s</strong> - %s: %s<br><small>%s</small></li>",
+			template.HTMLEscapeString(p.URL), p.Problem, template.HTMLEscapeString(p.Detail), preflightHints[p.Problem]))
+	}
+
+	subject := a.cfg.BrandName + " - USCIS Unreachable at Startup"
+	body := fmt.Sprintf(`
+		<h2>⚠️ USCIS Unreachable at Startup</h2>
+		<p>The tracker on <strong>%s</strong> could not reach these USCIS endpoints before logging in:</p>
+		<ul>%s</ul>
+		<p>This is a network problem, not a problem with your USCIS credentials. Polling continues and will recover on its own once the endpoints are reachable.</p>
+	`, template.HTMLEscapeString(a.hostname), strings.Join(rows, "\n"))
+
+	if err := a.sendAlert(nil, subject, body); err != nil {
+		log.Printf("Failed to send pre-flight alert: %v", err)
+	}
+}
+
+// exitPreflightFailed returns the exit code for PREFLIGHT_CHECK=strict finding USCIS unreachable
+func (a *app) exitPreflightFailed(err error) int {
+	log.Printf("Pre-flight check failed: %v", err)
+	if a.report == nil {
+		return exitUnexpected
+	}
+	for _, caseID := range a.cfg.CaseIDs {
+		a.report.caseFailed(caseID, err)
+	}
+	return a.report.finish(a.cfg.ResultFile, exitNetwork, err)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/config"
+	"github.com/phhowardchen/case-tracker/internal/storage"
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// publicCaseView is the anonymized view of one case on the public page
+type publicCaseView struct {
+	Alias      string
+	Step       string
+	Stage      string
+	Status     string
+	Form       string
+	LastUpdate string
+}
+
+var publicPageTemplate = template.Must(template.New("public").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Case Progress</title>
+<style>
+body { font-family: -apple-system, Helvetica, Arial, sans-serif; max-width: 640px; margin: 2em auto; padding: 0 1em; color: #222; }
+.case { border: 1px solid #ddd; border-radius: 6px; padding: 12px 16px; margin-bottom: 12px; }
+.alias { font-weight: bold; font-size: 1.1em; }
+.muted { color: #777; }
+</style>
+</head>
+<body>
+<h2>Case Progress</h2>
+{{range .Cases}}
+<div class="case">
+<div class="alias">{{.Alias}}</div>
+{{if .Step}}<div>{{.Step}}</div>{{end}}
+{{if .Stage}}<div>Stage: {{.Stage}}</div>{{end}}
+{{if .Status}}<div>Status: {{.Status}}</div>{{end}}
+{{if .Form}}<div>Form: {{.Form}}</div>{{end}}
+{{if .LastUpdate}}<div class="muted">Last update {{.LastUpdate}}</div>{{end}}
+</div>
+{{else}}
+<p class="muted">No cases are shared.</p>
+{{end}}
+<p class="muted"><small>Generated {{.Generated}}</small></p>
+</body>
+</html>
+`))
+
+// publicStatusHandler serves the unauthenticated status page
+// Only the configured cases and fields are rendered, and receipt numbers never appear
+func publicStatusHandler(cfg *config.Config, caseStorage func(caseID string) storage.Storage) http.HandlerFunc {
+	show := make(map[string]bool)
+	for _, field := range cfg.PublicStatusFields {
+		show[field] = true
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var views []publicCaseView
+		for _, pc := range cfg.PublicStatusCases {
+			view, err := buildPublicCaseView(caseStorage(pc.CaseID), pc, show)
+			if err != nil {
+				log.Printf("[%s] Public status page: %v", pc.CaseID, err)
+				view = publicCaseView{Alias: pc.Alias, Step: "Status unavailable"}
+			}
+			views = append(views, view)
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		publicPageTemplate.Execute(w, map[string]interface{}{
+			"Cases":     views,
+			"Generated": cfg.Locale.FormatDate(time.Now()),
+		})
+	}
+}
+
+// buildPublicCaseView reads the stored history of a case and reduces it to the allowed fields
+func buildPublicCaseView(store storage.Storage, pc config.PublicCase, show map[string]bool) (publicCaseView, error) {
+	view := publicCaseView{Alias: pc.Alias}
+
+	lister, ok := store.(snapshotLister)
+	if !ok {
+		return view, fmt.Errorf("storage backend does not keep history")
+	}
+	snapshots, err := lister.ListSnapshots()
+	if err != nil {
+		return view, err
+	}
+	if len(snapshots) == 0 {
+		view.Step = "Not checked yet"
+		return view, nil
+	}
+
+	latest := snapshots[len(snapshots)-1]
+	summary := uscis.StatusSummary(latest.Data)
+	stage := uscis.Stage(summary)
+
+	if show["step"] {
+		if stage >= 0 {
+			view.Step = fmt.Sprintf("Step %d of %d", stage+1, len(uscis.Stages))
+		} else {
+			view.Step = "In progress"
+		}
+	}
+	if show["stage"] && stage >= 0 {
+		view.Stage = uscis.Stages[stage]
+	}
+	if show["status"] {
+		view.Status = summary
+	}
+	if show["form"] {
+		view.Form = uscis.FormType(latest.Data)
+	}
+	if show["last_update"] {
+		view.LastUpdate = humanizeAge(time.Since(latest.Timestamp))
+	}
+
+	return view, nil
+}
+
+// humanizeAge renders a duration as "today", "1 day ago" or "N days ago"
+func humanizeAge(age time.Duration) string {
+	days := int(age.Hours() / 24)
+	switch days {
+	case 0:
+		return "today"
+	case 1:
+		return "1 day ago"
+	default:
+		return fmt.Sprintf("%d days ago", days)
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/notifier"
+	"github.com/phhowardchen/case-tracker/internal/storage"
+)
+
+// maxPushSubscriptions caps the devices that can subscribe, so an exposed dashboard
+// can't be used to fill the state directory
+const maxPushSubscriptions = 50
+
+// loadVAPIDKey returns the key signing push requests, creating it on first use
+func (a *app) loadVAPIDKey() (*ecdsa.PrivateKey, error) {
+	encoded, err := a.push.Key(notifier.GenerateVAPIDKey)
+	if err != nil {
+		return nil, err
+	}
+	return notifier.ParseVAPIDKey(encoded)
+}
+
+// pushChannel creates the channel pushing to every subscribed device
+// Subscriptions the push service reports gone are removed
+func (a *app) pushChannel() notifier.Channel {
+	client := notifier.NewWebPushClient(a.vapidKey, a.cfg.WebPushContact, "/cases", a.pushSubscriptions)
+	client.OnGone = func(endpoint string) {
+		log.Printf("Push subscription at %s expired, removing it", notifier.PushDestination(endpoint))
+		if err := a.push.Remove(endpoint); err != nil {
+			log.Printf("Warning: Failed to remove push subscription: %v", err)
+		}
+	}
+	return notifier.Channel{
+		Name:        "push",
+		Destination: "dashboard devices",
+		Notifier:    client,
+	}
+}
+
+// pushSubscriptions lists the subscribed devices for the push channel
+func (a *app) pushSubscriptions() ([]notifier.PushSubscription, error) {
+	stored, err := a.push.List()
+	if err != nil {
+		return nil, err
+	}
+	subs := make([]notifier.PushSubscription, 0, len(stored))
+	for _, sub := range stored {
+		subs = append(subs, notifier.PushSubscription{Endpoint: sub.Endpoint, P256dh: sub.P256dh, Auth: sub.Auth})
+	}
+	return subs, nil
+}
+
+// pushSubscriptionRequest is a PushSubscription as the browser serializes it
+type pushSubscriptionRequest struct {
+	Endpoint string `json:"endpoint"`
+	Keys     struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+}
+
+// handlePushKeyAPI serves the public application server key browsers subscribe with
+func (a *app) handlePushKeyAPI(w http.ResponseWriter, r *http.Request) {
+	key, err := notifier.VAPIDPublicKey(a.vapidKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"public_key": key})
+}
+
+// handlePushSubscribeAPI saves the push subscription of a device (POST) or removes it (DELETE)
+// Like the dashboard itself, it needs no API_TOKEN: whoever can open the dashboard can
+// receive its notifications
+func (a *app) handlePushSubscribeAPI(w http.ResponseWriter, r *http.Request) {
+	// A JSON content type can't be sent cross-site without a CORS preflight, which fails
+	if r.Header.Get("Content-Type") != "application/json" {
+		http.Error(w, "expected a JSON body", http.StatusUnsupportedMediaType)
+		return
+	}
+	var req pushSubscriptionRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if u, err := url.Parse(req.Endpoint); err != nil || u.Scheme != "https" || u.Host == "" {
+		http.Error(w, "endpoint must be an https:// URL", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		if err := a.push.Remove(req.Endpoint); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Push subscription at %s removed", notifier.PushDestination(req.Endpoint))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	sub := notifier.PushSubscription{Endpoint: req.Endpoint, P256dh: req.Keys.P256dh, Auth: req.Keys.Auth}
+	if err := sub.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	existing, err := a.push.List()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(existing) >= maxPushSubscriptions {
+		http.Error(w, fmt.Sprintf("at most %d devices can subscribe", maxPushSubscriptions), http.StatusConflict)
+		return
+	}
+	if err := a.push.Add(storage.PushSubscription{
+		Endpoint:  sub.Endpoint,
+		P256dh:    sub.P256dh,
+		Auth:      sub.Auth,
+		UserAgent: r.UserAgent(),
+		CreatedAt: time.Now(),
+	}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Push subscription added at %s", notifier.PushDestination(req.Endpoint))
+	w.WriteHeader(http.StatusCreated)
+}
+
+// handleManifest serves the web app manifest that makes the dashboard installable
+func (a *app) handleManifest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/manifest+json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"name":             a.cfg.BrandName,
+		"short_name":       a.cfg.BrandName,
+		"start_url":        "/cases",
+		"scope":            "/",
+		"display":          "standalone",
+		"background_color": "#ffffff",
+		"theme_color":      pwaThemeColor,
+		"icons": []map[string]string{
+			{"src": "/icon.svg", "sizes": "any", "type": "image/svg+xml", "purpose": "any"},
+		},
+	})
+}
+
+// handleAppIcon serves the icon of the installed app
+func handleAppIcon(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	fmt.Fprint(w, appIconSVG)
+}
+
+// handleServiceWorker serves the service worker: it shows push notifications and keeps
+// the last dashboard page for offline use
+// It is served from the root so its scope covers /cases
+func handleServiceWorker(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/javascript")
+	w.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprint(w, serviceWorkerJS)
+}
+
+// pwaThemeColor colors the installed app's title bar
+const pwaThemeColor = "#1f4e79"
+
+const appIconSVG = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 512 512">
+<rect width="512" height="512" rx="96" fill="#1f4e79"/>
+<path d="M136 268l80 80 160-176" fill="none" stroke="#fff" stroke-width="48" stroke-linecap="round" stroke-linejoin="round"/>
+</svg>
+`
+
+const serviceWorkerJS = `const CACHE = "case-tracker-v1";
+
+self.addEventListener("install", () => self.skipWaiting());
+self.addEventListener("activate", (event) => event.waitUntil(self.clients.claim()));
+
+// Dashboard pages: network first, the last copy when offline
+self.addEventListener("fetch", (event) => {
+  const url = new URL(event.request.url);
+  if (event.request.method !== "GET" || url.pathname !== "/cases") {
+    return;
+  }
+  event.respondWith(
+    fetch(event.request)
+      .then((response) => {
+        const copy = response.clone();
+        caches.open(CACHE).then((cache) => cache.put(event.request, copy));
+        return response;
+      })
+      .catch(() => caches.match(event.request))
+  );
+});
+
+self.addEventListener("push", (event) => {
+  let data = {};
+  try {
+    data = event.data ? event.data.json() : {};
+  } catch (e) {
+    data = { title: "Case update", body: event.data.text() };
+  }
+  event.waitUntil(
+    self.registration.showNotification(data.title || "Case update", {
+      body: data.body || "",
+      tag: data.tag || undefined,
+      icon: "/icon.svg",
+      requireInteraction: data.kind === "alert",
+      data: { url: data.url || "/cases" },
+    })
+  );
+});
+
+self.addEventListener("notificationclick", (event) => {
+  event.notification.close();
+  const target = event.notification.data.url;
+  event.waitUntil(
+    self.clients.matchAll({ type: "window", includeUncontrolled: true }).then((windows) => {
+      for (const w of windows) {
+        if (new URL(w.url).pathname === target && "focus" in w) {
+          w.navigate(target);
+          return w.focus();
+        }
+      }
+      return self.clients.openWindow(target);
+    })
+  );
+});
+`
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/locale"
+	"github.com/phhowardchen/case-tracker/internal/notifier"
+	"github.com/phhowardchen/case-tracker/internal/storage"
+)
+
+// holdChannels holds a non-urgent notification back from the channels that are in their
+// quiet hours (QUIET_HOURS), have sent their hourly limit (NOTIFY_MAX_PER_HOUR) or are
+// near their provider's quota (RESEND_DAILY_QUOTA)
+// Each held channel gets a copy of the entry that flushOutbox sends once the channel is
+// free again; the entry keeps the other channels. It reports whether nothing is left to
+// send now. The canary is never held, or a held canary would look like a broken pipeline
+func (a *app) holdChannels(entry *storage.OutboxEntry, now time.Time) bool {
+	if entry.Urgent || a.isCanaryEntry(entry) {
+		return false
+	}
+	channels := entry.Channels
+	if len(channels) == 0 {
+		channels = a.channelNames()
+	}
+
+	var sendNow []string
+	held := make(map[string]time.Time)
+	for _, channel := range channels {
+		loc := a.cfg.Locale
+		if channel == "email" && len(entry.Recipients) > 0 {
+			// Notifications are rendered per recipient locale, so an entry's recipients
+			// share a timezone: quiet hours follow theirs
+			loc = a.cfg.LocaleFor(entry.Recipients[0])
+		}
+		if until, reason := a.channelHeldUntil(channel, now, loc); !until.IsZero() {
+			log.Printf("Holding %q for %s until %s (%s)", entry.Subject, channel, loc.FormatDateTime(until), reason)
+			held[channel] = until
+			continue
+		}
+		sendNow = append(sendNow, channel)
+	}
+	if len(held) == 0 {
+		return false
+	}
+
+	if len(sendNow) == 0 && len(channels) == 1 {
+		// Already a single channel's copy: just push it back
+		entry.NotBefore = held[channels[0]]
+		if err := a.outbox.Update(entry); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		return true
+	}
+
+	// Copies first: a crash before the entry is narrowed down sends twice rather than never
+	for _, channel := range channels {
+		until, ok := held[channel]
+		if !ok {
+			continue
+		}
+		copied := *entry
+		copied.ID = entry.ID + "_" + channel
+		copied.Channels = []string{channel}
+		copied.NotBefore = until
+		copied.Snapshots = nil
+		copied.Applied = true
+		if err := a.outbox.Update(&copied); err != nil {
+			// Send it now rather than risk losing it
+			log.Printf("Warning: Failed to hold notification for %s: %v", channel, err)
+			sendNow = append(sendNow, channel)
+		}
+	}
+
+	if len(sendNow) == 0 {
+		if err := a.outbox.MarkDelivered(entry.ID); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		return true
+	}
+	entry.Channels = sendNow
+	if err := a.outbox.Update(entry); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	return false
+}
+
+// channelHeldUntil returns until when a channel gets no non-urgent notifications and
+// why, or the zero time if it can send now. Quiet hours are taken in loc
+func (a *app) channelHeldUntil(channel string, now time.Time, loc locale.Settings) (time.Time, string) {
+	var until time.Time
+	var reasons []string
+	if window, ok := a.cfg.QuietHoursFor(channel); ok {
+		if end, quiet := window.Until(loc.In(now)); quiet {
+			until = end
+			reasons = append(reasons, "quiet hours")
+		}
+	}
+
+	if limit := a.cfg.LimitsFor(channel).PerHour; limit > 0 {
+		sent, err := a.deliveries.Since(now.Add(-time.Hour))
+		if err != nil {
+			log.Printf("Warning: Failed to count recent notifications: %v", err)
+		}
+		var times []time.Time
+		for _, d := range sent {
+			if d.Channel == channel && d.Error == "" {
+				times = append(times, d.Time)
+			}
+		}
+		if len(times) >= limit {
+			// Free again once enough of the last hour's sends are more than an hour old
+			free := times[len(times)-limit].Add(time.Hour)
+			if free.After(until) {
+				until = free
+			}
+			reasons = append(reasons, "hourly limit reached")
+		}
+	}
+
+	for _, limited := range a.backpressured(channel) {
+		if free, reason := limited.HeldUntil(now); !free.IsZero() {
+			if free.After(until) {
+				until = free
+			}
+			reasons = append(reasons, reason)
+		}
+	}
+	return until, strings.Join(reasons, ", ")
+}
+
+// backpressured returns the notifiers of a channel that report their provider's limits
+func (a *app) backpressured(channel string) []notifier.Backpressured {
+	multi, ok := a.notifier.(*notifier.MultiNotifier)
+	if !ok {
+		return nil
+	}
+	var limited []notifier.Backpressured
+	for _, ch := range multi.Channels() {
+		if b, ok := ch.Notifier.(notifier.Backpressured); ok && ch.Name == channel {
+			limited = append(limited, b)
+		}
+	}
+	return limited
+}
+
+// channelNames returns the distinct names of the configured channels
+func (a *app) channelNames() []string {
+	multi, ok := a.notifier.(*notifier.MultiNotifier)
+	if !ok {
+		return nil
+	}
+	var names []string
+	for _, ch := range multi.Channels() {
+		if !slices.Contains(names, ch.Name) {
+			names = append(names, ch.Name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"time"
+)
+
+// requestAllowance returns how many of the due cases the next poll cycle may fetch under
+// DAILY_REQUEST_BUDGET, or -1 without a budget
+// The remaining budget is spread over the cycles left in the day, so a budget too small
+// to check every case every cycle checks a few cases per cycle (carried over round-robin)
+// instead of running out by morning
+// Days start at midnight in TIMEZONE, not in the server's timezone
+func (a *app) requestAllowance(now time.Time, due int) int {
+	if a.cfg.DailyRequestBudget == 0 {
+		return -1
+	}
+	now = a.cfg.Locale.In(now)
+	used, err := a.requests.Used(now)
+	if err != nil {
+		log.Printf("Warning: Failed to load the daily request count, skipping this cycle: %v", err)
+		return 0
+	}
+	a.metrics.dailyRequests.Set(float64(used))
+
+	remaining := a.cfg.DailyRequestBudget - used
+	if remaining <= 0 {
+		log.Printf("Daily request budget exhausted (%d/%d) - no USCIS requests until midnight", used, a.cfg.DailyRequestBudget)
+		a.alertBudgetExhausted(now, used)
+		return 0
+	}
+
+	cycles := 1
+	if tick := a.cfg.PollTick(); tick > 0 {
+		cycles = int((endOfDay(now).Sub(now) + tick - 1) / tick)
+		cycles = max(cycles, 1)
+	}
+	// Each case may take more than one request (FETCH_CASE_HISTORY)
+	allowance := (remaining/a.requestsPerCase() + cycles - 1) / cycles
+	if allowance < due {
+		log.Printf("Daily request budget: %d/%d used, %d cycle(s) left today - checking %d of %d case(s) this cycle",
+			used, a.cfg.DailyRequestBudget, cycles, allowance, due)
+	}
+	return allowance
+}
+
+// endOfDay returns the midnight after now in now's timezone, when the request count
+// starts over
+func endOfDay(now time.Time) time.Time {
+	year, month, day := now.Date()
+	return time.Date(year, month, day+1, 0, 0, 0, 0, now.Location())
+}
+
+// countRequest counts one USCIS request against the daily budget
+func (a *app) countRequest(caseID string) {
+	if a.cfg.DailyRequestBudget == 0 || a.cfg.IsCanary(caseID) {
+		return
+	}
+	used, err := a.requests.Add(a.cfg.Locale.In(time.Now()))
+	if err != nil {
+		log.Printf("[%s] Warning: Failed to save the daily request count: %v", caseID, err)
+	}
+	a.metrics.dailyRequests.Set(float64(used))
+}
+
+// alertBudgetExhausted tells the user, once a day, that polling stopped for the rest of it
+func (a *app) alertBudgetExhausted(now time.Time, used int) {
+	if !a.cfg.BudgetAlert {
+		return
+	}
+	first, err := a.requests.MarkAlerted(now)
+	if err != nil {
+		log.Printf("Warning: Failed to record the request budget alert: %v", err)
+	}
+	if !first {
+		return
+	}
+
+	loc := a.recipientLocale()
+	subject := a.cfg.BrandName + " - Daily Request Budget Exhausted"
+	body := fmt.Sprintf(`
+		<h2>Daily Request Budget Exhausted</h2>
+		<p>The tracker on <strong>%s</strong> has made %d of the %d USCIS requests allowed per day (DAILY_REQUEST_BUDGET).</p>
+		<p>No cases are checked until %s. Status changes in the meantime are picked up after that.</p>
+		<p>If this happens every day, raise DAILY_REQUEST_BUDGET or lengthen POLL_INTERVAL.</p>
+	`, template.HTMLEscapeString(a.hostname), used, a.cfg.DailyRequestBudget, loc.FormatDateTime(endOfDay(now)))
+
+	if err := a.sendAlert(nil, subject, body); err != nil {
+		log.Printf("Failed to send request budget alert: %v", err)
+	}
+}
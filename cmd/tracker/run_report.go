@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/storage"
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// Exit codes of run-once mode
+//
+//	0  every case was checked and every notification was delivered
+//	1  unexpected error
+//	2  one or more cases failed (fetch, parse or notification) or ran out of cycle budget
+//	3  authentication failed (login, session refresh or expired cookie)
+//	4  configuration error
+//	5  USCIS unreachable at startup (PREFLIGHT_CHECK=strict)
+const (
+	exitOK          = 0
+	exitUnexpected  = 1
+	exitPartial     = 2
+	exitAuthFailure = 3
+	exitConfigError = 4
+	exitNetwork     = 5
+)
+
+// Case outcomes in the run report
+const (
+	outcomeUnchanged    = "unchanged"
+	outcomeChanged      = "changed"
+	outcomeFirstRun     = "first_run"
+	outcomeSnoozed      = "snoozed"
+	outcomeSkipped      = "skipped"
+	outcomeDeferred     = "deferred" // held back by DAILY_REQUEST_BUDGET; not a failure
+	outcomeFetchFailed  = "fetch_failed"
+	outcomeAuthFailed   = "auth_failed"
+	outcomeNotifyFailed = "notify_failed"
+)
+
+// caseOutcome is the result of one case in a run
+type caseOutcome struct {
+	CaseID  string         `json:"case_id"`
+	Outcome string         `json:"outcome"`
+	Error   string         `json:"error,omitempty"`
+	Changes []uscis.Change `json:"changes,omitempty"`
+}
+
+// runReport is the machine-readable summary of a run-once invocation
+type runReport struct {
+	mu sync.Mutex
+
+	Version       string                  `json:"version"`
+	InstanceID    string                  `json:"instance_id"`
+	StartedAt     time.Time               `json:"started_at"`
+	FinishedAt    time.Time               `json:"finished_at"`
+	ExitCode      int                     `json:"exit_code"`
+	Error         string                  `json:"error,omitempty"`
+	Cases         []*caseOutcome          `json:"cases"`
+	Notifications []storage.Delivery      `json:"notifications"`
+	byCase        map[string]*caseOutcome `json:"-"`
+}
+
+// newRunReport starts a report for the given cases
+func newRunReport(instanceID string, caseIDs []string) *runReport {
+	report := &runReport{
+		Version:       currentVersion().Version,
+		InstanceID:    instanceID,
+		StartedAt:     time.Now(),
+		Cases:         make([]*caseOutcome, 0, len(caseIDs)),
+		Notifications: []storage.Delivery{},
+		byCase:        make(map[string]*caseOutcome, len(caseIDs)),
+	}
+	for _, caseID := range caseIDs {
+		outcome := &caseOutcome{CaseID: caseID, Outcome: outcomeSkipped}
+		report.Cases = append(report.Cases, outcome)
+		report.byCase[caseID] = outcome
+	}
+	return report
+}
+
+// caseFailed records a case whose check failed
+// Every method is a no-op on a nil report, i.e. when not in run-once mode
+func (r *runReport) caseFailed(caseID string, err error) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	outcome := r.outcomeLocked(caseID)
+	outcome.Outcome = outcomeFetchFailed
+	var authErr *uscis.ErrAuthenticationFailed
+	var lockedErr *uscis.ErrAccountLocked
+	if errors.As(err, &authErr) || errors.As(err, &lockedErr) {
+		// A firewall block (ErrWAFBlocked) stays a fetch failure: the credentials are fine
+		outcome.Outcome = outcomeAuthFailed
+	}
+	outcome.Error = err.Error()
+}
+
+// addCase adds a case found after the report was created, skipped until checked
+func (r *runReport) addCase(caseID string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.byCase[caseID]; !ok {
+		r.outcomeLocked(caseID).Outcome = outcomeSkipped
+	}
+}
+
+// caseChecked records a fetched case and whether its notification was delivered
+func (r *runReport) caseChecked(result *caseResult, outcomeName string, notifyErr error) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	outcome := r.outcomeLocked(result.caseID)
+	outcome.Outcome = outcomeName
+	outcome.Changes = result.changes
+	if notifyErr != nil {
+		outcome.Outcome = outcomeNotifyFailed
+		outcome.Error = notifyErr.Error()
+	}
+}
+
+// caseDeferred records a case left for a later run to stay within the daily request budget
+// or while a re-login waits for approval
+func (r *runReport) caseDeferred(caseID string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.outcomeLocked(caseID).Outcome = outcomeDeferred
+}
+
+// delivery records one notification attempt
+func (r *runReport) delivery(d storage.Delivery) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Notifications = append(r.Notifications, d)
+}
+
+// outcomeLocked returns the entry for a case, creating it if needed
+// Caller must hold the lock
+func (r *runReport) outcomeLocked(caseID string) *caseOutcome {
+	outcome, ok := r.byCase[caseID]
+	if !ok {
+		outcome = &caseOutcome{CaseID: caseID}
+		r.Cases = append(r.Cases, outcome)
+		r.byCase[caseID] = outcome
+	}
+	return outcome
+}
+
+// exitCode derives the process exit code from the case outcomes
+func (r *runReport) exitCode() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	code := exitOK
+	for _, outcome := range r.Cases {
+		switch outcome.Outcome {
+		case outcomeAuthFailed:
+			return exitAuthFailure
+		case outcomeFetchFailed, outcomeNotifyFailed, outcomeSkipped:
+			code = exitPartial
+		}
+	}
+	return code
+}
+
+// finish sets the exit code and writes the report to path ("-" for stdout, "" to skip)
+// Only the first call writes it, so a deferred finish keeps the error of an earlier one
+func (r *runReport) finish(path string, exitCode int, runErr error) int {
+	if r == nil {
+		return exitCode
+	}
+	r.mu.Lock()
+	if !r.FinishedAt.IsZero() {
+		r.mu.Unlock()
+		return exitCode
+	}
+	r.FinishedAt = time.Now()
+	r.ExitCode = exitCode
+	if runErr != nil {
+		r.Error = runErr.Error()
+	}
+	r.mu.Unlock()
+
+	if err := r.write(path); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write result file: %v\n", err)
+	}
+	return exitCode
+}
+
+// write encodes the report as JSON, replacing the file atomically so a reader never sees
+// a partial report
+func (r *runReport) write(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	r.mu.Lock()
+	data, err := json.MarshalIndent(r, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal result: %w", err)
+	}
+	data = append(data, '\n')
+
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	tempFile := path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write result: %w", err)
+	}
+	if err := os.Rename(tempFile, path); err != nil {
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to rename result: %w", err)
+	}
+	return nil
+}
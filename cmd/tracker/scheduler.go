@@ -0,0 +1,165 @@
+package main
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Poll fairness policies
+const (
+	fairnessRoundRobin = "round-robin" // start each cycle with the cases the last cycle didn't reach
+	fairnessFixed      = "fixed"       // always poll in CASE_IDS order
+)
+
+// pollScheduler decides which cases a poll cycle checks and in what order
+// Each case is polled on its own interval (CASE_SCHEDULE); a cycle checks the cases that
+// are due. With a cycle budget or a request limit, cases that don't fit stay due and are
+// carried over to the front of the next cycle
+type pollScheduler struct {
+	mu        sync.Mutex
+	order     []string
+	intervals map[string]time.Duration
+	nextDue   map[string]time.Time // cases not polled yet are due right away
+	slack     time.Duration        // a case this close to due is polled now rather than a tick late
+	budget    time.Duration
+	fairness  string
+}
+
+// newPollScheduler creates a scheduler for the given cases, each polled every intervalFor(caseID)
+// A zero budget means every cycle checks every due case
+func newPollScheduler(caseIDs []string, intervalFor func(string) time.Duration, budget time.Duration, fairness string) *pollScheduler {
+	s := &pollScheduler{
+		order:     append([]string(nil), caseIDs...),
+		intervals: make(map[string]time.Duration, len(caseIDs)),
+		nextDue:   make(map[string]time.Time, len(caseIDs)),
+		budget:    budget,
+		fairness:  fairness,
+	}
+	for _, caseID := range caseIDs {
+		interval := intervalFor(caseID)
+		s.intervals[caseID] = interval
+		if s.slack == 0 || interval/2 < s.slack {
+			s.slack = interval / 2
+		}
+	}
+	return s
+}
+
+// add schedules a case found after startup, due right away
+func (s *pollScheduler) add(caseID string, interval time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if slices.Contains(s.order, caseID) {
+		return
+	}
+	s.order = append(s.order, caseID)
+	s.intervals[caseID] = interval
+	if s.slack == 0 || interval/2 < s.slack {
+		s.slack = interval / 2
+	}
+}
+
+// cycle is one poll cycle's view of the schedule
+type cycle struct {
+	s        *pollScheduler
+	started  time.Time
+	order    []string // the due cases, in the order they are polled
+	deadline time.Time
+	limit    int // cases the cycle may fetch, -1 for no limit
+	polled   int
+}
+
+// due returns the cases due for a poll at now, in scheduling order
+func (s *pollScheduler) due(now time.Time) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []string
+	for _, caseID := range s.order {
+		if !now.Before(s.nextDue[caseID].Add(-s.slack)) {
+			due = append(due, caseID)
+		}
+	}
+	return due
+}
+
+// start begins a poll cycle over the due cases that fetches at most limit of them
+// (-1 for no limit)
+func (s *pollScheduler) start(now time.Time, due []string, limit int) *cycle {
+	c := &cycle{s: s, started: now, order: due, limit: limit}
+	if s.budget > 0 {
+		c.deadline = now.Add(s.budget)
+	}
+	return c
+}
+
+// next returns the next case to poll, or false when the cycle is done or out of budget
+func (c *cycle) next() (string, bool) {
+	if c.polled >= len(c.order) || c.limited() {
+		return "", false
+	}
+	if !c.deadline.IsZero() && time.Now().After(c.deadline) {
+		return "", false
+	}
+	caseID := c.order[c.polled]
+	c.polled++
+	c.s.scheduleNext(caseID, c.started)
+	return caseID, true
+}
+
+// scheduleNext sets when a case polled by the cycle started at now is due again
+// It stays on its own rhythm unless it fell a whole interval behind; cycles moved by
+// POLL_JITTER still poll it, as a case within the slack of its due time is polled
+func (s *pollScheduler) scheduleNext(caseID string, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	interval := s.intervals[caseID]
+	next := s.nextDue[caseID].Add(interval)
+	if !next.After(now) {
+		next = now.Add(interval)
+	}
+	s.nextDue[caseID] = next
+}
+
+// pending reports whether the cycle has cases left to hand out
+func (c *cycle) pending() bool {
+	return c.polled < len(c.order) && !c.limited()
+}
+
+// limited reports whether the cycle has fetched as many cases as its limit allows
+func (c *cycle) limited() bool {
+	return c.limit >= 0 && c.polled >= c.limit
+}
+
+// skipped returns the cases the cycle didn't reach
+func (c *cycle) skipped() []string {
+	return c.order[c.polled:]
+}
+
+// finish records the cycle so the next one starts with the skipped cases
+// A cycle cut short by its request limit rotates even with fixed fairness, or the
+// cases at the end of the order would never be polled
+func (c *cycle) finish() {
+	if c.s.fairness != fairnessRoundRobin && !c.limited() {
+		return
+	}
+
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+
+	// Skipped cases go first, in their original relative order, then the cases that
+	// weren't due; polled cases queue up behind them
+	polled := make(map[string]bool, c.polled)
+	for _, caseID := range c.order[:c.polled] {
+		polled[caseID] = true
+	}
+	order := append([]string(nil), c.order[c.polled:]...)
+	for _, caseID := range c.s.order {
+		if !polled[caseID] && !slices.Contains(c.order[c.polled:], caseID) {
+			order = append(order, caseID)
+		}
+	}
+	c.s.order = append(order, c.order[:c.polled]...)
+}
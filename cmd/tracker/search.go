@@ -0,0 +1,215 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/config"
+	"github.com/phhowardchen/case-tracker/internal/storage"
+)
+
+// runSearch implements `tracker search [-case ID] [-since DATE] [-until DATE] TEXT`
+// It searches status changes, case history notices and notes in the SQLite database
+func runSearch(args []string) int {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	caseID := fs.String("case", "", "only search this case")
+	since := fs.String("since", "", "only entries on or after this date (YYYY-MM-DD or YYYY-MM)")
+	until := fs.String("until", "", "only entries up to and including this date (YYYY-MM-DD or YYYY-MM)")
+	limit := fs.Int("limit", 50, "maximum number of results")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: tracker search [flags] TEXT")
+		fmt.Fprintln(os.Stderr, "Example: tracker search -since 2026-03 -until 2026-03 RFE")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 1
+	}
+
+	query, err := newSearchQuery(strings.Join(fs.Args(), " "), *caseID, *since, *until, *limit)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	db, err := openSearchDB()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.Close()
+
+	results, err := db.Search(query)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Search failed: %v\n", err)
+		return 1
+	}
+	if len(results) == 0 {
+		fmt.Println("No matches")
+		return 0
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DATE\tCASE\tKIND\tMATCH")
+	for _, r := range results {
+		snippet := strings.NewReplacer(storage.SnippetStart, "[", storage.SnippetEnd, "]", "\n", " ").Replace(r.Snippet)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.At.Local().Format("2006-01-02"), r.CaseID, r.Kind, snippet)
+	}
+	w.Flush()
+	return 0
+}
+
+// runNote implements `tracker note CASE TEXT` to attach a searchable note to a case
+// With only CASE it lists the case's notes
+func runNote(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: tracker note CASE [TEXT]")
+		return 1
+	}
+	caseID := strings.ToUpper(args[0])
+
+	db, err := openSearchDB()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.Close()
+
+	if len(args) == 1 {
+		notes, err := db.ListNotes(caseID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to list notes: %v\n", err)
+			return 1
+		}
+		if len(notes) == 0 {
+			fmt.Printf("No notes for %s\n", caseID)
+			return 0
+		}
+		for _, note := range notes {
+			fmt.Printf("%s  %s\n", note.CreatedAt.Local().Format("2006-01-02 15:04"), note.Text)
+		}
+		return 0
+	}
+
+	text := strings.TrimSpace(strings.Join(args[1:], " "))
+	if text == "" {
+		fmt.Fprintln(os.Stderr, "Note text is empty")
+		return 1
+	}
+	if err := db.AddNote(caseID, text, time.Now()); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to add note: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Note added to %s\n", caseID)
+	return 0
+}
+
+// openSearchDB opens the state database for the search and note commands
+func openSearchDB() (*storage.SQLiteDB, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	if cfg.StorageBackend != "sqlite" {
+		return nil, fmt.Errorf("search and notes need STORAGE_BACKEND=sqlite")
+	}
+	db, err := storage.OpenSQLite(cfg.SQLitePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state database: %w", err)
+	}
+	return db, nil
+}
+
+// newSearchQuery validates search parameters given as text (CLI flags or query string)
+func newSearchQuery(text, caseID, since, until string, limit int) (storage.SearchQuery, error) {
+	query := storage.SearchQuery{Text: text, CaseID: strings.ToUpper(caseID), Limit: limit}
+	if strings.TrimSpace(text) == "" {
+		return query, fmt.Errorf("search text is empty")
+	}
+	var err error
+	if query.Since, err = parseSearchDate(since, false); err != nil {
+		return query, fmt.Errorf("invalid since date: %w", err)
+	}
+	if query.Until, err = parseSearchDate(until, true); err != nil {
+		return query, fmt.Errorf("invalid until date: %w", err)
+	}
+	return query, nil
+}
+
+// parseSearchDate parses a YYYY-MM-DD or YYYY-MM date in local time
+// With end set it returns the end of that day or month, so the bound is inclusive
+func parseSearchDate(value string, end bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		if end {
+			return t.AddDate(0, 0, 1), nil
+		}
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not YYYY-MM-DD or YYYY-MM", value)
+	}
+	if end {
+		return t.AddDate(0, 1, 0), nil
+	}
+	return t, nil
+}
+
+// search runs a query from request parameters (q, case, since, until, limit)
+func (a *app) search(r *http.Request) ([]storage.SearchResult, error) {
+	if a.stateDB == nil {
+		return nil, fmt.Errorf("search needs STORAGE_BACKEND=sqlite")
+	}
+	limit := 0
+	if value := r.FormValue("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid limit")
+		}
+		limit = n
+	}
+	query, err := newSearchQuery(r.FormValue("q"), r.FormValue("case"), r.FormValue("since"), r.FormValue("until"), limit)
+	if err != nil {
+		return nil, err
+	}
+	results, err := a.stateDB.Search(query)
+	if err != nil || isAdminRequest(r) {
+		return results, err
+	}
+	// Viewers only find their own cases
+	return slices.DeleteFunc(results, func(result storage.SearchResult) bool { return !a.canSeeCase(r, result.CaseID) }), nil
+}
+
+// handleSearchAPI serves search results as JSON
+func (a *app) handleSearchAPI(w http.ResponseWriter, r *http.Request) {
+	results, err := a.search(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if results == nil {
+		results = []storage.SearchResult{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"query":   r.FormValue("q"),
+		"results": results,
+	})
+}
+
+// highlightSnippet renders a search snippet as HTML with the matched terms marked
+func highlightSnippet(snippet string) template.HTML {
+	escaped := template.HTMLEscapeString(snippet)
+	return template.HTML(strings.NewReplacer(storage.SnippetStart, "<mark>", storage.SnippetEnd, "</mark>").Replace(escaped))
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/config"
+	"github.com/phhowardchen/case-tracker/internal/health"
+	"github.com/phhowardchen/case-tracker/internal/notifier"
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// selftestCaseID is the synthetic case written by the storage check
+const selftestCaseID = "SELFTEST0000000000"
+
+// Results of one self-test check
+const (
+	checkPass = "PASS"
+	checkFail = "FAIL"
+	checkSkip = "SKIP"
+)
+
+// selftestCheck is one row of the self-test report
+type selftestCheck struct {
+	name   string
+	result string
+	detail string
+}
+
+// runSelftest implements `tracker selftest`
+// It exercises every configured component with synthetic data and prints a pass/fail table
+func runSelftest(args []string) int {
+	fs := flag.NewFlagSet("selftest", flag.ExitOnError)
+	to := fs.String("to", "", "send the test email here instead of RECIPIENT_EMAIL")
+	noNotify := fs.Bool("no-notify", false, "skip sending test notifications")
+	fetch := fs.Bool("fetch", false, "also fetch the first case from USCIS (logs in and may trigger 2FA in auto-login mode)")
+	fs.Parse(args)
+
+	var checks []selftestCheck
+	report := func(name, result, detail string) {
+		checks = append(checks, selftestCheck{name: name, result: result, detail: detail})
+	}
+	defer func() { printSelftest(checks) }()
+
+	cfg, err := config.Load()
+	if err != nil {
+		report("config", checkFail, err.Error())
+		return 1
+	}
+	report("config", checkPass, fmt.Sprintf("%d case(s), auth mode %s", len(cfg.CaseIDs), authModeName(cfg)))
+	report(checkSecrets(cfg))
+
+	if *to != "" {
+		// Only to the given address, without the CC and BCC addresses
+		cfg.RecipientEmail, cfg.RecipientEmails = *to, []string{*to}
+		cfg.EmailCC, cfg.EmailBCC = nil, nil
+	}
+	a, err := newApp(cfg, nil, health.NewTracker("selftest", cfg.CaseIDs))
+	if err != nil {
+		report("storage", checkFail, err.Error())
+		return 1
+	}
+
+	report(a.checkStorage())
+
+	if *noNotify {
+		report("notify", checkSkip, "-no-notify")
+	} else {
+		for _, ch := range a.notifier.(*notifier.MultiNotifier).Channels() {
+			report(a.checkChannel(ch, "selftest"))
+		}
+	}
+
+	if cfg.EmailIMAPServer == "" {
+		report("imap", checkSkip, "EMAIL_IMAP_SERVER not set")
+	} else if err := newIMAPClient(cfg).CheckLogin(); err != nil {
+		report("imap", checkFail, err.Error())
+	} else {
+		report("imap", checkPass, "logged in to "+cfg.EmailIMAPServer+" as "+cfg.EmailUsername)
+	}
+
+	switch {
+	case len(cfg.CaseIDs) == 0:
+		report("uscis", checkSkip, "no case listed to fetch (CASE_IDS=auto)")
+	case *fetch:
+		report(checkFetch(cfg))
+	default:
+		report("uscis", checkSkip, "pass -fetch to fetch "+cfg.CaseIDs[0])
+	}
+
+	for _, check := range checks {
+		if check.result == checkFail {
+			return 1
+		}
+	}
+	return 0
+}
+
+// authModeName describes how the tracker authenticates with USCIS
+func authModeName(cfg *config.Config) string {
+	if cfg.AutoLogin {
+		return "auto-login"
+	}
+	return "cookie"
+}
+
+// checkSecrets verifies the credentials the configuration needs were resolved cleanly
+// Stray quotes or whitespace are a common copy-paste mistake that config validation accepts
+func checkSecrets(cfg *config.Config) (string, string, string) {
+	secrets := map[string]string{}
+	if cfg.Notifier == config.NotifierSMTP {
+		if cfg.SMTPPassword != "" {
+			secrets["SMTP_PASSWORD"] = cfg.SMTPPassword
+		}
+	} else {
+		secrets["RESEND_API_KEY"] = cfg.ResendAPIKey
+	}
+	if cfg.AutoLogin {
+		secrets["USCIS_USERNAME"] = cfg.USCISUsername
+		secrets["USCIS_PASSWORD"] = cfg.USCISPassword
+	} else {
+		secrets["USCIS_COOKIE"] = cfg.USCISCookie
+	}
+	if cfg.EmailPassword != "" {
+		secrets["EMAIL_PASSWORD"] = cfg.EmailPassword
+	}
+	if cfg.EmailOAuthRefreshToken != "" {
+		secrets["EMAIL_OAUTH_REFRESH_TOKEN"] = cfg.EmailOAuthRefreshToken
+	}
+	if cfg.TelegramBotToken != "" {
+		secrets["TELEGRAM_BOT_TOKEN"] = cfg.TelegramBotToken
+	}
+	if cfg.SlackWebhookURL != "" {
+		secrets["SLACK_WEBHOOK_URL"] = cfg.SlackWebhookURL
+	}
+
+	var suspicious []string
+	for key, value := range secrets {
+		if value != strings.TrimSpace(value) || strings.HasPrefix(value, `"`) || strings.HasPrefix(value, "'") {
+			suspicious = append(suspicious, key)
+		}
+	}
+	if len(suspicious) > 0 {
+		return "secrets", checkFail, "surrounding quotes or whitespace in " + strings.Join(suspicious, ", ")
+	}
+	return "secrets", checkPass, fmt.Sprintf("%d credential(s) resolved from the environment", len(secrets))
+}
+
+// checkStorage writes a synthetic snapshot, reads it back and removes it again
+func (a *app) checkStorage() (string, string, string) {
+	name := "storage (" + a.cfg.StorageBackend + ")"
+	store := a.caseStorage(selftestCaseID)
+	if deleter, ok := store.(interface{ Delete() error }); ok {
+		defer deleter.Delete()
+	}
+
+	written := map[string]interface{}{
+		"receiptNumber": selftestCaseID,
+		"status":        "Self-test " + time.Now().Format(time.RFC3339),
+	}
+	if err := store.Save(written); err != nil {
+		return name, checkFail, err.Error()
+	}
+	read, err := store.Load()
+	if err != nil {
+		return name, checkFail, err.Error()
+	}
+	if !reflect.DeepEqual(read, written) {
+		return name, checkFail, "snapshot read back differs from the one written"
+	}
+
+	location := a.cfg.StateFileDir
+	if a.stateDB != nil {
+		location = a.cfg.SQLitePath
+	}
+	if a.stateBucket != nil {
+		location = a.stateBucket.String() + " (credentials: " + a.stateBucket.CredentialSource() + ")"
+	}
+	if a.statePG != nil {
+		location = "the PostgreSQL database"
+	}
+	return name, checkPass, "wrote and read a snapshot in " + location
+}
+
+// checkChannel sends a test notification over one channel on behalf of a command
+func (a *app) checkChannel(ch notifier.Channel, command string) (string, string, string) {
+	name := "notify (" + ch.Name + ")"
+	msg := a.message(nil, a.cfg.BrandName+" - Test notification",
+		"<h2>Test notification</h2><p>This is a test notification from <code>tracker "+command+"</code>. No action is needed.</p>")
+	if err := ch.Notifier.SendChange(msg); err != nil {
+		return name, checkFail, err.Error()
+	}
+	return name, checkPass, "sent to " + ch.Destination
+}
+
+// checkFetch fetches the first configured case the way the tracker would, Chrome fallback included
+func checkFetch(cfg *config.Config) (string, string, string) {
+	caseID := cfg.CaseIDs[0]
+	fetcher, closeFetcher, note, err := newCommandFetcher(cfg, caseID)
+	if err != nil {
+		return "uscis", checkFail, err.Error()
+	}
+	defer closeFetcher()
+
+	status, err := fetcher.FetchCaseStatus(caseID)
+	if err != nil {
+		return "uscis", checkFail, err.Error()
+	}
+	summary := uscis.StatusSummary(status)
+	if summary == "" {
+		summary = "no recognizable status field"
+	}
+	if note != "" {
+		summary += " (" + note + ")"
+	}
+	return "uscis", checkPass, caseID + ": " + summary
+}
+
+// printSelftest prints the report as an aligned table
+func printSelftest(checks []selftestCheck) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHECK\tRESULT\tDETAIL")
+	for _, check := range checks {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", check.name, check.result, check.detail)
+	}
+	w.Flush()
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/phhowardchen/case-tracker/internal/config"
+	"github.com/phhowardchen/case-tracker/internal/health"
+	"github.com/phhowardchen/case-tracker/internal/storage"
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// statusResponse is the JSON document served at /status
+type statusResponse struct {
+	health.Status
+	InstanceID string                `json:"instance_id"`
+	LoginQueue uscis.LoginQueueState `json:"login_queue"`
+	Toggles    toggleState           `json:"toggles"`
+	PollLock   *pollLockState        `json:"poll_lock,omitempty"`
+}
+
+// serveHTTP runs the embedded HTTP server until it fails
+// Cloud Run requires services to listen on PORT (default 8080)
+func (a *app) serveHTTP() {
+	log.Printf("Starting HTTP server on port %s (endpoints: %s)", a.cfg.Port, strings.Join(a.enabledEndpointGroups(), ", "))
+	if err := http.ListenAndServe(":"+a.cfg.Port, a.newHTTPMux()); err != nil {
+		log.Fatalf("Failed to start HTTP server: %v", err)
+	}
+}
+
+// newHTTPMux registers the handlers of every enabled endpoint group
+func (a *app) newHTTPMux() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	// The default, health only, serves nothing but /health; the banner comes with the
+	// other groups
+	mux.HandleFunc("/health", a.handleHealth)
+	if slices.ContainsFunc([]string{config.EndpointsAPI, config.EndpointsMetrics, config.EndpointsDashboard}, a.cfg.EndpointsEnabled) {
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintf(w, "%s is running", a.cfg.BrandName)
+		})
+	}
+
+	// With users in users.json, case data needs a signed-in user and operations the admin role
+	viewer := func(h http.HandlerFunc) http.HandlerFunc { return a.restrict(storage.RoleViewer, h) }
+	admin := func(h http.HandlerFunc) http.HandlerFunc { return a.restrict(storage.RoleAdmin, h) }
+
+	if a.cfg.EndpointsEnabled(config.EndpointsAPI) {
+		mux.HandleFunc("/status", admin(func(w http.ResponseWriter, r *http.Request) {
+			response := statusResponse{
+				Status:     a.health.Snapshot(),
+				InstanceID: a.instanceID,
+				LoginQueue: uscis.CurrentLoginQueueState(),
+				Toggles:    a.toggles.state(),
+			}
+			if a.pollLock != nil {
+				state := a.pollLock.state()
+				response.PollLock = &state
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(response)
+		}))
+		mux.HandleFunc("/api/snooze", viewer(a.handleSnoozeAPI))
+		mux.HandleFunc("/api/cases", viewer(a.handleCasesAPI))
+		mux.HandleFunc("GET /api/cases/{id}/history", viewer(a.handleCaseHistoryAPI))
+		mux.HandleFunc("GET /api/cases/{id}/field", viewer(a.handleCaseFieldAPI))
+		mux.HandleFunc("/api/admin/toggles", admin(a.handleTogglesAPI))
+		mux.HandleFunc("/api/admin/reset-watchdog", admin(a.handleResetWatchdogAPI))
+		mux.HandleFunc("/api/admin/refresh-session", admin(a.handleRefreshSessionAPI))
+		mux.HandleFunc("/api/trigger", admin(a.handleTriggerAPI))
+		if a.stateDB != nil {
+			mux.HandleFunc("/api/search", viewer(a.handleSearchAPI))
+		}
+		if a.changeLog() != nil {
+			mux.HandleFunc("GET /api/changes", viewer(a.handleChangesAPI))
+		}
+		if a.logs != nil {
+			mux.HandleFunc("/api/logs", admin(a.handleLogsAPI))
+			mux.HandleFunc("/api/events", admin(a.handleEvents))
+		}
+	}
+
+	if a.cfg.EndpointsEnabled(config.EndpointsDashboard) {
+		mux.HandleFunc("/cases", viewer(a.handleCasesPage))
+		mux.HandleFunc("/cases/report", viewer(a.handleCaseReport))
+		mux.HandleFunc("/login", a.handleLogin)
+		mux.HandleFunc("/logout", handleLogout)
+		mux.HandleFunc("/manifest.webmanifest", a.handleManifest)
+		mux.HandleFunc("/sw.js", handleServiceWorker)
+		mux.HandleFunc("/icon.svg", handleAppIcon)
+		if a.push != nil {
+			// Push notifications cover every case, so only admins may subscribe
+			mux.HandleFunc("GET /api/push/key", admin(a.handlePushKeyAPI))
+			mux.HandleFunc("POST /api/push/subscriptions", admin(a.handlePushSubscribeAPI))
+			mux.HandleFunc("DELETE /api/push/subscriptions", admin(a.handlePushSubscribeAPI))
+		}
+	}
+
+	if a.cfg.EndpointsEnabled(config.EndpointsMetrics) {
+		mux.HandleFunc("/metrics", admin(a.metrics.registry.Handler().ServeHTTP))
+	}
+
+	// Action links in emails have their own opt-in (PUBLIC_URL and LINK_SECRET)
+	if a.linksEnabled() {
+		mux.HandleFunc("/link/snooze", a.handleSnoozeLink)
+		mux.HandleFunc("/link/ack", a.handleAckLink)
+		mux.HandleFunc("/link/login", a.handleLoginLink)
+	}
+
+	// The SMS webhook has its own opt-in (TWILIO_AUTH_TOKEN) and checks Twilio's signature
+	if a.smsCodes != nil {
+		mux.HandleFunc("POST "+smsWebhookPath, a.handleTwilioSMS)
+	}
+
+	// The bot commands have their own opt-in (TELEGRAM_WEBHOOK_SECRET) and check its secret
+	if a.cfg.TelegramWebhookSecret != "" {
+		mux.HandleFunc("POST "+telegramWebhookPath, a.handleTelegramUpdate)
+	}
+
+	// The public page has its own opt-in (PUBLIC_STATUS_CASES)
+	if len(a.cfg.PublicStatusCases) > 0 {
+		log.Printf("Public status page enabled at /public for %d case(s)", len(a.cfg.PublicStatusCases))
+		mux.HandleFunc("/public", publicStatusHandler(a.cfg, a.caseStorage))
+	}
+
+	return mux
+}
+
+// enabledEndpointGroups lists the enabled groups for the startup log
+func (a *app) enabledEndpointGroups() []string {
+	var groups []string
+	for _, group := range []string{config.EndpointsHealth, config.EndpointsAPI, config.EndpointsMetrics, config.EndpointsDashboard} {
+		if a.cfg.EndpointsEnabled(group) {
+			groups = append(groups, group)
+		}
+	}
+	if len(a.cfg.PublicStatusCases) > 0 {
+		groups = append(groups, "public")
+	}
+	if a.smsCodes != nil {
+		groups = append(groups, "twilio-sms")
+	}
+	return groups
+}
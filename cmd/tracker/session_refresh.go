@@ -0,0 +1,75 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/config"
+)
+
+// Timings of the scheduled session refresh
+const (
+	sessionRefreshCheck = 5 * time.Minute  // how often the main loop looks whether a refresh is due
+	sessionRefreshRetry = 30 * time.Minute // wait after a failed refresh
+)
+
+// sessionSchedule logs in to USCIS again every SESSION_REFRESH_INTERVAL, inside
+// SESSION_REFRESH_WINDOW when set, so the session is renewed while nothing is polled
+// instead of expiring in the middle of a poll
+// It is only used from the main loop
+type sessionSchedule struct {
+	interval time.Duration
+	window   *config.QuietWindow
+	next     time.Time // earliest time of the next refresh
+}
+
+// newSessionSchedule schedules the first refresh an interval after the login at startup
+func newSessionSchedule(cfg *config.Config, now time.Time) *sessionSchedule {
+	return &sessionSchedule{
+		interval: cfg.SessionRefreshInterval,
+		window:   cfg.SessionRefreshWindow,
+		next:     now.Add(cfg.SessionRefreshInterval),
+	}
+}
+
+// String describes the schedule for the startup log
+func (s *sessionSchedule) String() string {
+	if s.window == nil {
+		return "every " + s.interval.String()
+	}
+	return "every " + s.interval.String() + " during " + s.window.String()
+}
+
+// refreshSessionOnSchedule refreshes the sessions when the schedule says so
+// A failed refresh is only logged and retried: the session may well still be valid, and
+// if it isn't, the next poll's own refresh reports it
+func (a *app) refreshSessionOnSchedule(now time.Time) {
+	s := a.sessionRefresh
+	if now.Before(s.next) || a.toggles.isPaused() {
+		return
+	}
+	local := a.cfg.Locale.In(now)
+	if s.window != nil {
+		if _, inside := s.window.Until(local); !inside {
+			return
+		}
+	}
+	if a.cfg.LoginHoldHours != nil {
+		if _, held := a.cfg.LoginHoldHours.Until(local); held {
+			// Nobody is there for the 2FA code
+			return
+		}
+	}
+
+	log.Printf("Refreshing the USCIS session on schedule (%s)", s)
+	refreshed, err := a.toggles.refreshSessions()
+	if err != nil {
+		s.next = now.Add(sessionRefreshRetry)
+		log.Printf("Warning: Scheduled session refresh failed, trying again after %s: %v", a.cfg.Locale.FormatDateTime(s.next), err)
+		return
+	}
+	s.next = now.Add(s.interval)
+	a.health.RecordAuthSuccess()
+	log.Printf("Scheduled session refresh done (%s); next one after %s", strings.Join(refreshed, ", "), a.cfg.Locale.FormatDateTime(s.next))
+}
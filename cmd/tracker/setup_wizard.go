@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"golang.org/x/term"
+
+	"github.com/phhowardchen/case-tracker/internal/config"
+	"github.com/phhowardchen/case-tracker/internal/health"
+	"github.com/phhowardchen/case-tracker/internal/notifier"
+	"github.com/phhowardchen/case-tracker/internal/source"
+)
+
+var (
+	// caseIDPattern matches a USCIS receipt number (three letters, ten digits)
+	caseIDPattern = regexp.MustCompile(`^[A-Z]{3}[0-9]{10}$`)
+	// shellSafePattern matches values that need no quoting in a sourced .env file
+	shellSafePattern = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]*$`)
+)
+
+// gcpSecretNames maps credential variables to the Secret Manager secrets the deploy scripts read
+var gcpSecretNames = map[string]string{
+	"RESEND_API_KEY": "resend-api-key",
+	"USCIS_USERNAME": "uscis-username",
+	"USCIS_PASSWORD": "uscis-password",
+	"EMAIL_PASSWORD": "email-app-password",
+}
+
+// envEntry is one line of the generated config file
+type envEntry struct {
+	key   string
+	value string
+}
+
+// runInit implements `tracker init`
+// It interactively collects the configuration, stores credentials in the chosen
+// secret backend, sends a test notification and writes a ready-to-use .env file
+func runInit(args []string) int {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	output := fs.String("o", ".env", "config file to write")
+	force := fs.Bool("force", false, "overwrite an existing config file")
+	fs.Parse(args)
+
+	if _, err := os.Stat(*output); err == nil && !*force {
+		fmt.Fprintf(os.Stderr, "%s already exists; pass -force to overwrite it\n", *output)
+		return 1
+	}
+
+	p := newPrompter(os.Stdin, os.Stdout)
+	fmt.Println("USCIS Case Tracker setup")
+	fmt.Println("Press Enter to accept the [default]. Secrets are not echoed.")
+
+	var entries []envEntry
+	add := func(key, value string) {
+		entries = append(entries, envEntry{key: key, value: value})
+	}
+
+	// Authentication
+	p.section("USCIS authentication")
+	fmt.Println("  cookie     - paste a session cookie from your browser (local testing; expires quickly)")
+	fmt.Println("  auto-login - log in with username and password in a headless browser (servers)")
+	fmt.Println("  public     - no account: the public case status page (headline status, description and form only)")
+	switch p.choice("Authentication mode", []string{"cookie", "auto-login", "public"}, "auto-login") {
+	case "cookie":
+		add("AUTO_LOGIN", "false")
+		add("USCIS_COOKIE", p.required("Cookie (name=value, e.g. _myuscis_session_rx=...)", true))
+	case "public":
+		add("DEFAULT_CASE_SOURCE", source.Public)
+	default:
+		add("AUTO_LOGIN", "true")
+		add("USCIS_USERNAME", p.required("USCIS username", false))
+		add("USCIS_PASSWORD", p.required("USCIS password", true))
+
+		if p.yesNo("Fetch 2FA codes from a mailbox automatically?", true) {
+			add("EMAIL_IMAP_SERVER", p.ask("IMAP server", "imap.gmail.com:993"))
+			add("EMAIL_USERNAME", p.required("Mailbox username", false))
+			add("EMAIL_PASSWORD", p.required("Mailbox app password", true))
+		}
+	}
+
+	// Cases
+	p.section("Cases")
+	for {
+		ids := strings.Split(strings.ToUpper(p.required("Receipt numbers (comma-separated)", false)), ",")
+		var invalid []string
+		for i, id := range ids {
+			ids[i] = strings.TrimSpace(id)
+			if !caseIDPattern.MatchString(ids[i]) {
+				invalid = append(invalid, ids[i])
+			}
+		}
+		if len(invalid) == 0 {
+			add("CASE_IDS", strings.Join(ids, ","))
+			break
+		}
+		fmt.Printf("  Not a receipt number (e.g. IOE0123456789): %s\n", strings.Join(invalid, ", "))
+	}
+	add("POLL_INTERVAL", p.ask("Poll interval", "15m"))
+
+	// Notifications
+	p.section("Notifications")
+	fmt.Println("  Email is sent with Resend (https://resend.com/api-keys)")
+	add("RESEND_API_KEY", p.required("Resend API key", true))
+	add("RECIPIENT_EMAIL", p.required("Send notifications to", false))
+	if p.yesNo("Also notify a Telegram chat?", false) {
+		add("TELEGRAM_BOT_TOKEN", p.required("Bot token (from @BotFather)", true))
+		add("TELEGRAM_CHAT_ID", p.required("Chat ID", false))
+	}
+	if p.yesNo("Also post to a Slack channel?", false) {
+		add("SLACK_WEBHOOK_URL", p.required("Incoming webhook URL (https://hooks.slack.com/services/...)", true))
+	}
+
+	// Secret backend
+	p.section("Secret storage")
+	fmt.Println("  env - write credentials into the config file (readable only by you)")
+	fmt.Println("  gcp - store them in GCP Secret Manager for deploy_gce.sh / deploy_cloud_run.sh")
+	backend := p.choice("Store credentials in", []string{"env", "gcp"}, "env")
+	var project string
+	if backend == "gcp" {
+		project = p.required("GCP project ID", false)
+		add("GCP_PROJECT_ID", project)
+	}
+
+	// Validate through the normal loader and send a test notification
+	values := make(map[string]string, len(entries))
+	for _, e := range entries {
+		values[e.key] = e.value
+	}
+	cfg, err := config.LoadWith(values)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nConfiguration is invalid: %v\n", err)
+		return 1
+	}
+
+	if p.yesNo("\nSend a test notification now?", true) {
+		a, err := newApp(cfg, nil, health.NewTracker("init", cfg.CaseIDs))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to initialize: %v\n", err)
+			return 1
+		}
+		for _, ch := range a.notifier.(*notifier.MultiNotifier).Channels() {
+			name, result, detail := a.checkChannel(ch, "init")
+			fmt.Printf("  %s: %s %s\n", name, result, detail)
+		}
+	}
+
+	if backend == "gcp" {
+		for _, e := range entries {
+			secretName, ok := gcpSecretNames[e.key]
+			if !ok {
+				continue
+			}
+			if err := storeGCPSecret(project, secretName, e.value); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to store %s: %v\n", secretName, err)
+				return 1
+			}
+			fmt.Printf("  Stored %s in Secret Manager\n", secretName)
+		}
+	}
+
+	if err := writeEnvFile(*output, entries, project); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write %s: %v\n", *output, err)
+		return 1
+	}
+
+	fmt.Printf("\nWrote %s. Start the tracker with:\n\n", *output)
+	fmt.Printf("  set -a && source %s && set +a\n  ./tracker selftest && ./tracker\n\n", *output)
+	fmt.Println("See .env.example for the optional settings.")
+	return 0
+}
+
+// storeGCPSecret creates a secret or adds a new version if it already exists
+// The value is passed on stdin so it never appears in the process list
+func storeGCPSecret(project, name, value string) error {
+	args := []string{"secrets", "create", name, "--data-file=-", "--project=" + project}
+	if exec.Command("gcloud", "secrets", "describe", name, "--project="+project).Run() == nil {
+		args = []string{"secrets", "versions", "add", name, "--data-file=-", "--project=" + project}
+	}
+
+	cmd := exec.Command("gcloud", args...)
+	cmd.Stdin = strings.NewReader(value)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("gcloud %s: %w: %s", strings.Join(args[:3], " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// writeEnvFile writes the entries in `source`-able form
+// Credentials kept in the Secret Manager of secretsProject are written as sm:// references,
+// which the tracker resolves at startup; the file is private either way
+func writeEnvFile(path string, entries []envEntry, secretsProject string) error {
+	var b strings.Builder
+	b.WriteString("# Generated by `tracker init`. See .env.example for every option.\n")
+	for _, e := range entries {
+		if name := gcpSecretNames[e.key]; secretsProject != "" && name != "" {
+			fmt.Fprintf(&b, "%s=%sprojects/%s/secrets/%s\n", e.key, config.SecretRefPrefix, secretsProject, name)
+			continue
+		}
+		fmt.Fprintf(&b, "%s=%s\n", e.key, shellQuote(e.value))
+	}
+	return os.WriteFile(path, []byte(b.String()), 0600)
+}
+
+// shellQuote single-quotes a value unless it is plainly safe
+func shellQuote(value string) string {
+	if shellSafePattern.MatchString(value) {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// prompter asks questions on a terminal
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+	fd  int // terminal file descriptor for hidden input, -1 if not a terminal
+}
+
+// newPrompter creates a prompter reading answers from in
+func newPrompter(in *os.File, out io.Writer) *prompter {
+	fd := int(in.Fd())
+	if !term.IsTerminal(fd) {
+		fd = -1
+	}
+	return &prompter{in: bufio.NewReader(in), out: out, fd: fd}
+}
+
+// section prints a heading
+func (p *prompter) section(title string) {
+	fmt.Fprintf(p.out, "\n== %s ==\n", title)
+}
+
+// ask reads one answer, returning def when it is empty
+func (p *prompter) ask(question, def string) string {
+	if def != "" {
+		fmt.Fprintf(p.out, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(p.out, "%s: ", question)
+	}
+	line, err := p.in.ReadString('\n')
+	if err != nil && line == "" {
+		// Input closed: nothing more will come, so don't loop forever
+		fmt.Fprintln(p.out)
+		fmt.Fprintln(os.Stderr, "Setup aborted")
+		os.Exit(1)
+	}
+	if answer := strings.TrimSpace(line); answer != "" {
+		return answer
+	}
+	return def
+}
+
+// required asks until a non-empty answer is given, hiding it for secrets
+func (p *prompter) required(question string, secret bool) string {
+	for {
+		var answer string
+		if secret && p.fd >= 0 {
+			fmt.Fprintf(p.out, "%s: ", question)
+			data, err := term.ReadPassword(p.fd)
+			fmt.Fprintln(p.out)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to read input: %v\n", err)
+				os.Exit(1)
+			}
+			answer = strings.TrimSpace(string(data))
+		} else {
+			answer = p.ask(question, "")
+		}
+		if answer != "" {
+			return answer
+		}
+		fmt.Fprintln(p.out, "  A value is required")
+	}
+}
+
+// choice asks until one of the options is chosen
+func (p *prompter) choice(question string, options []string, def string) string {
+	for {
+		answer := strings.ToLower(p.ask(question+" ("+strings.Join(options, "/")+")", def))
+		for _, option := range options {
+			if answer == option {
+				return option
+			}
+		}
+		fmt.Fprintf(p.out, "  Choose one of: %s\n", strings.Join(options, ", "))
+	}
+}
+
+// yesNo asks a yes/no question
+func (p *prompter) yesNo(question string, def bool) bool {
+	defAnswer := "n"
+	if def {
+		defAnswer = "y"
+	}
+	switch strings.ToLower(p.ask(question+" (y/n)", defAnswer)) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
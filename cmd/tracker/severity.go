@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/phhowardchen/case-tracker/internal/notifier"
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// changeSubject titles a change notification about name (a case, bundle or digest) by
+// what the change is, so an RFE doesn't look like a field update in the inbox
+func changeSubject(event uscis.Event, name string) string {
+	switch event.Severity {
+	case uscis.SeverityCritical:
+		return fmt.Sprintf("⚠️ USCIS Action Needed: %s - %s", event.Label, name)
+	case uscis.SeverityImportant:
+		return fmt.Sprintf("USCIS %s - %s", event.Label, name)
+	case uscis.SeverityNotice:
+		return fmt.Sprintf("USCIS Case Status Update: %s - %s", event.Label, name)
+	default:
+		return fmt.Sprintf("USCIS Case Status Update - %s", name)
+	}
+}
+
+// topEvent returns the most severe event of several results
+func topEvent(results []*caseResult) uscis.Event {
+	top := results[0].event
+	for _, r := range results[1:] {
+		if r.event.Severity > top.Severity {
+			top = r.event
+		}
+	}
+	return top
+}
+
+// routeBySeverity narrows a change message to the channels that take changes of its
+// severity (SEVERITY_CHANNELS). It returns false when none of them does
+// Canary messages keep CANARY_CHANNELS
+func (a *app) routeBySeverity(msg *notifier.Message, severity uscis.Severity) bool {
+	if len(a.cfg.SeverityChannels) == 0 || (len(msg.CaseIDs) == 1 && a.cfg.IsCanary(msg.CaseIDs[0])) {
+		return true
+	}
+	multi, ok := a.notifier.(*notifier.MultiNotifier)
+	if !ok {
+		return true
+	}
+	var channels []string
+	for _, ch := range multi.Channels() {
+		if slices.Contains(channels, ch.Name) || (len(msg.Channels) > 0 && !slices.Contains(msg.Channels, ch.Name)) {
+			continue
+		}
+		if minimum, ok := a.cfg.SeverityChannels[ch.Name]; ok && severity < minimum {
+			continue
+		}
+		channels = append(channels, ch.Name)
+	}
+	msg.Channels = channels
+	return len(channels) > 0
+}
+
+// routeAllBySeverity routes each message of a notification rendered per recipient locale
+// and returns those some channel takes
+func (a *app) routeAllBySeverity(msgs []notifier.Message, severity uscis.Severity) []notifier.Message {
+	var routed []notifier.Message
+	for _, msg := range msgs {
+		if a.routeBySeverity(&msg, severity) {
+			routed = append(routed, msg)
+		}
+	}
+	return routed
+}
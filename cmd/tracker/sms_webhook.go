@@ -0,0 +1,73 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// smsWebhookPath is where Twilio posts texts received on the 2FA number
+const smsWebhookPath = "/webhooks/twilio/sms"
+
+// emptyTwiML answers Twilio without replying to the text
+const emptyTwiML = `<?xml version="1.0" encoding="UTF-8"?><Response></Response>`
+
+// handleTwilioSMS receives a text sent to the Twilio number and passes a 2FA code in it
+// on to a waiting login. Requests without Twilio's signature are rejected, and texts
+// from senders other than TWILIO_SMS_FROM ignored
+func (a *app) handleTwilioSMS(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	webhookURL := strings.TrimRight(a.cfg.PublicURL, "/") + r.URL.RequestURI()
+	if !validTwilioSignature(a.cfg.TwilioAuthToken, webhookURL, r.PostForm, r.Header.Get("X-Twilio-Signature")) {
+		log.Printf("Rejected an SMS webhook request without a valid Twilio signature (check PUBLIC_URL matches the webhook URL set in Twilio)")
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	// Anyone can text the number; only a code from USCIS may reach the login
+	if from := r.PostForm.Get("From"); !a.cfg.IsSMSSender(from) {
+		log.Printf("Ignoring an SMS from %s, which isn't in TWILIO_SMS_FROM", from)
+		w.Header().Set("Content-Type", "text/xml")
+		w.Write([]byte(emptyTwiML))
+		return
+	}
+	if a.smsCodes.Deliver(r.PostForm.Get("Body"), time.Now()) {
+		log.Printf("Received a 2FA code by SMS")
+	} else {
+		log.Printf("Received an SMS without a 2FA code, ignoring it")
+	}
+	w.Header().Set("Content-Type", "text/xml")
+	w.Write([]byte(emptyTwiML))
+}
+
+// validTwilioSignature checks X-Twilio-Signature: the base64 HMAC-SHA1, keyed with the
+// auth token, of the URL followed by every POST parameter name and value sorted by name
+func validTwilioSignature(authToken, webhookURL string, params url.Values, signature string) bool {
+	if signature == "" {
+		return false
+	}
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	mac := hmac.New(sha1.New, []byte(authToken))
+	mac.Write([]byte(webhookURL))
+	for _, name := range names {
+		for _, value := range params[name] {
+			mac.Write([]byte(name + value))
+		}
+	}
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/storage"
+)
+
+// maxSnooze caps how long a case can be muted
+const maxSnooze = 90 * 24 * time.Hour
+
+// snoozeLinkDurations are offered as one-click links in notification emails
+var snoozeLinkDurations = []string{"1d", "3d", "7d"}
+
+// snoozedUntil reports whether notifications for a case are currently muted
+func (a *app) snoozedUntil(caseID string) (time.Time, bool) {
+	snooze, err := a.snoozes.Active(caseID, time.Now())
+	if err != nil {
+		log.Printf("[%s] Warning: Failed to check snooze: %v", caseID, err)
+		return time.Time{}, false
+	}
+	if snooze == nil {
+		return time.Time{}, false
+	}
+	return snooze.Until, true
+}
+
+// snooze mutes a case for the given duration
+func (a *app) snooze(caseID string, d time.Duration, source string) (time.Time, error) {
+	if !slices.Contains(a.caseIDs(), caseID) {
+		return time.Time{}, fmt.Errorf("unknown case %q", caseID)
+	}
+	if d <= 0 || d > maxSnooze {
+		return time.Time{}, fmt.Errorf("snooze duration must be between 0 and %v", maxSnooze)
+	}
+
+	snooze, err := a.snoozes.Set(caseID, time.Now().Add(d), source)
+	if err != nil {
+		return time.Time{}, err
+	}
+	log.Printf("[%s] Notifications snoozed until %s (via %s)", caseID, snooze.Until.Format(time.RFC3339), source)
+	return snooze.Until, nil
+}
+
+// parseSnoozeDuration accepts Go durations ("36h") and whole days ("3d")
+func parseSnoozeDuration(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// snoozeLinksHTML renders the one-click snooze links for a notification email
+func (a *app) snoozeLinksHTML(caseID string) string {
+	if !a.linksEnabled() {
+		return ""
+	}
+
+	var links []string
+	for _, d := range snoozeLinkDurations {
+		link := a.signedLink("snooze", caseID, url.Values{"for": {d}})
+		links = append(links, fmt.Sprintf(`<a href="%s">%s</a>`, template.HTMLEscapeString(link), d))
+	}
+	return fmt.Sprintf(`<p style="color: #777;"><small>Expecting several updates? Snooze emails for %s: %s</small></p>`, caseID, strings.Join(links, " · "))
+}
+
+var snoozeConfirmTemplate = template.Must(template.New("snooze").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>Snooze notifications</title></head>
+<body style="font-family: -apple-system, Helvetica, Arial, sans-serif; max-width: 640px; margin: 2em auto; padding: 0 1em;">
+{{if .Done}}
+<h2>Snoozed</h2>
+<p>Notifications for {{.CaseID}} are muted until {{.Until}}. Polling and history recording continue.</p>
+{{else}}
+<h2>Snooze notifications?</h2>
+<p>Mute emails for {{.CaseID}} for {{.For}}. Polling and history recording continue.</p>
+<form method="post"><button type="submit">Snooze</button></form>
+{{end}}
+</body>
+</html>
+`))
+
+// handleSnoozeLink serves /link/snooze from notification emails
+// GET only shows a confirmation page so link scanners can't snooze a case by prefetching it
+func (a *app) handleSnoozeLink(w http.ResponseWriter, r *http.Request) {
+	caseID, err := a.verifyLink("snooze", r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	d, err := parseSnoozeDuration(r.Form.Get("for"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	view := map[string]interface{}{"CaseID": caseID, "For": r.Form.Get("for")}
+	if r.Method == http.MethodPost {
+		until, err := a.snooze(caseID, d, "link")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		view["Done"] = true
+		view["Until"] = a.recipientLocale().FormatDateTime(until)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	snoozeConfirmTemplate.Execute(w, view)
+}
+
+// handleSnoozeAPI serves /api/snooze
+//
+//	GET                                  list active snoozes
+//	POST   ?case=IOE...&for=3d           snooze a case
+//	DELETE ?case=IOE...                  unsnooze a case
+func (a *app) handleSnoozeAPI(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		snoozes, err := a.snoozes.List(time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !isAdminRequest(r) {
+			snoozes = slices.DeleteFunc(snoozes, func(s *storage.Snooze) bool { return !a.canSeeCase(r, s.CaseID) })
+		}
+		writeJSON(w, http.StatusOK, snoozes)
+
+	case http.MethodPost:
+		if !a.authorizeAPIWrite(w, r) {
+			return
+		}
+		d, err := parseSnoozeDuration(r.FormValue("for"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		until, err := a.snooze(r.FormValue("case"), d, "api")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"case_id": r.FormValue("case"), "until": until})
+
+	case http.MethodDelete:
+		if !a.authorizeAPIWrite(w, r) {
+			return
+		}
+		caseID := r.FormValue("case")
+		if err := a.snoozes.Clear(caseID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("[%s] Snooze cleared via api", caseID)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// authorizeAPIWrite checks the bearer token for API calls that change state
+// Without API_TOKEN configured, the API is read-only. With users, admins may write
+// and viewers may not (see restrict)
+func (a *app) authorizeAPIWrite(w http.ResponseWriter, r *http.Request) bool {
+	if user := requestUserFrom(r); user != nil {
+		if user.Role != storage.RoleAdmin {
+			http.Error(w, "forbidden: needs the admin role", http.StatusForbidden)
+			return false
+		}
+		return true
+	}
+	if a.cfg.APIToken == "" {
+		http.Error(w, "API is read-only: set API_TOKEN to enable changes", http.StatusForbidden)
+		return false
+	}
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(a.cfg.APIToken)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// writeJSON writes a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
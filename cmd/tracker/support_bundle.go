@@ -0,0 +1,313 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/config"
+	"github.com/phhowardchen/case-tracker/internal/logging"
+	"github.com/phhowardchen/case-tracker/internal/storage"
+)
+
+const (
+	// maxBundleLogBytes limits how much of each log file is included (tail)
+	maxBundleLogBytes = 2 << 20
+	// maxBundleCaptures limits how many diagnostic files are included
+	maxBundleCaptures = 10
+)
+
+// runSupportBundle implements `tracker support-bundle`
+// It gathers redacted diagnostics into a single tar.gz archive for bug reports
+func runSupportBundle(args []string) int {
+	fs := flag.NewFlagSet("support-bundle", flag.ExitOnError)
+	output := fs.String("o", fmt.Sprintf("support-bundle-%s.tar.gz", time.Now().Format("20060102-150405")), "output archive path")
+	logFiles := fs.String("logs", "", "comma-separated log files or globs to include (tail only, default $LOG_FILE and its backups)")
+	statusURL := fs.String("status-url", "", "status endpoint of a running tracker (default http://localhost:$PORT/status)")
+	includeScreenshots := fs.Bool("include-screenshots", false, "include screenshots of failed logins, which can show the account's name and can't be scrubbed")
+	fs.Parse(args)
+
+	// A configuration that doesn't validate is likely what the bug report is about:
+	// the bundle records why and takes its paths from what still loads
+	cfg, loadErr := config.Load()
+	if loadErr != nil {
+		var err error
+		if cfg, err = config.LoadPartial(); err != nil {
+			cfg = &config.Config{
+				StateFileDir: cmp.Or(os.Getenv("STATE_FILE_DIR"), "/tmp/case-tracker-states/"),
+				Port:         cmp.Or(os.Getenv("PORT"), "8080"),
+				LogFile:      os.Getenv("LOG_FILE"),
+				APIToken:     os.Getenv("API_TOKEN"),
+				SecretValues: config.SecretValues(),
+			}
+		}
+	}
+
+	if *statusURL == "" {
+		*statusURL = fmt.Sprintf("http://localhost:%s/status", cfg.Port)
+	}
+
+	if *logFiles == "" && cfg.LogFile != "" {
+		paths := []string{cfg.LogFile}
+		if backups, err := logging.ListBackups(cfg.LogFile); err == nil {
+			// Current file plus the most recent backup covers the latest activity
+			if len(backups) > 1 {
+				backups = backups[:1]
+			}
+			paths = append(paths, backups...)
+		}
+		*logFiles = strings.Join(paths, ",")
+	}
+
+	bundle := newBundleWriter()
+
+	// Version information
+	bundle.addJSON("version.json", currentVersion())
+
+	// Redacted configuration plus the validation result
+	configReport := map[string]interface{}{
+		"env": config.RedactedEnv(),
+	}
+	if loadErr != nil {
+		configReport["load_error"] = string(scrubSecrets([]byte(loadErr.Error()), cfg.SecretValues))
+	} else {
+		configReport["load_error"] = nil
+	}
+	bundle.addJSON("config.json", configReport)
+
+	// Live status from a running tracker, if reachable
+	if status, err := fetchStatusJSON(*statusURL, cfg.APIToken); err != nil {
+		bundle.addFile("status.txt", []byte(fmt.Sprintf("status endpoint %s unavailable: %v\n", *statusURL, err)))
+	} else {
+		bundle.addFile("status.json", status)
+	}
+
+	// State directory listing (names and sizes only - contents hold case data)
+	bundle.addFile("state-files.txt", []byte(listStateFiles(cfg.StateFileDir)))
+
+	// Recent logs with known secret values scrubbed
+	for _, path := range expandGlobs(*logFiles) {
+		data, err := readTail(path, maxBundleLogBytes)
+		if err != nil {
+			bundle.addFile("logs/"+filepath.Base(path)+".error.txt", []byte(err.Error()+"\n"))
+			continue
+		}
+		bundle.addFile("logs/"+filepath.Base(path), scrubSecrets(data, cfg.SecretValues))
+	}
+
+	// Recent DOM captures of failed logins, scrubbed like the logs; screenshots only on request
+	if captures, closeCaptures, err := openCaptureReader(cfg); err != nil {
+		bundle.addFile("diagnostics.error.txt", scrubSecrets([]byte(err.Error()+"\n"), cfg.SecretValues))
+	} else {
+		files, err := captures.RecentCaptures(maxBundleCaptures)
+		closeCaptures()
+		if err != nil {
+			bundle.addFile("diagnostics.error.txt", scrubSecrets([]byte(err.Error()+"\n"), cfg.SecretValues))
+		}
+		for _, file := range files {
+			isHTML := path.Ext(file.Name) == ".html"
+			if !isHTML && !*includeScreenshots {
+				continue
+			}
+			data := file.Data
+			if isHTML {
+				data = scrubSecrets(data, cfg.SecretValues)
+			}
+			bundle.addFile("diagnostics/"+path.Base(file.Name), data)
+		}
+	}
+
+	if err := bundle.writeTo(*output); err != nil {
+		log.Printf("Failed to write support bundle: %v", err)
+		return 1
+	}
+
+	fmt.Printf("Support bundle written to %s\n", *output)
+	fmt.Printf("Review its contents before attaching it to a bug report.\n")
+	return 0
+}
+
+// openCaptureReader opens where the configured backend keeps login captures, without
+// the migrations and imports the tracker runs on startup. The returned func closes it
+func openCaptureReader(cfg *config.Config) (storage.CaptureReader, func(), error) {
+	switch cfg.StorageBackend {
+	case "sqlite":
+		db, err := storage.OpenSQLite(cfg.SQLitePath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open state database: %w", err)
+		}
+		return db, func() { db.Close() }, nil
+	case "postgres":
+		db, err := storage.OpenPostgres(cfg.PostgresURL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open state database: %w", err)
+		}
+		return db, func() { db.Close() }, nil
+	case "s3":
+		bucket, err := openStateBucket(cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		return bucket, func() {}, nil
+	}
+	return storage.NewLoginCaptureStore(cfg.StateFileDir), func() {}, nil
+}
+
+// bundleWriter accumulates files for a tar.gz archive
+type bundleWriter struct {
+	buf bytes.Buffer
+	gz  *gzip.Writer
+	tw  *tar.Writer
+	err error
+}
+
+func newBundleWriter() *bundleWriter {
+	b := &bundleWriter{}
+	b.gz = gzip.NewWriter(&b.buf)
+	b.tw = tar.NewWriter(b.gz)
+	return b
+}
+
+// addFile adds a file to the archive under the support-bundle/ prefix
+func (b *bundleWriter) addFile(name string, data []byte) {
+	if b.err != nil {
+		return
+	}
+	header := &tar.Header{
+		Name:    "support-bundle/" + name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}
+	if err := b.tw.WriteHeader(header); err != nil {
+		b.err = err
+		return
+	}
+	_, b.err = b.tw.Write(data)
+}
+
+// addJSON adds an indented JSON document to the archive
+func (b *bundleWriter) addJSON(name string, v interface{}) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		buf.Reset()
+		fmt.Fprintf(&buf, "failed to encode %s: %v\n", name, err)
+	}
+	b.addFile(name, buf.Bytes())
+}
+
+// writeTo finalizes the archive and writes it to path
+func (b *bundleWriter) writeTo(path string) error {
+	if b.err != nil {
+		return b.err
+	}
+	if err := b.tw.Close(); err != nil {
+		return err
+	}
+	if err := b.gz.Close(); err != nil {
+		return err
+	}
+	return os.WriteFile(path, b.buf.Bytes(), 0600)
+}
+
+// fetchStatusJSON queries the status endpoint of a running tracker
+// The token is needed when the tracker has users (see restrict)
+func fetchStatusJSON(url, token string) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// listStateFiles describes the files in the state directory without their contents
+func listStateFiles(stateDir string) string {
+	entries, err := os.ReadDir(stateDir)
+	if err != nil {
+		return fmt.Sprintf("failed to read %s: %v\n", stateDir, err)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s\n", stateDir)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(&sb, "  %-60s %10d  %s\n", entry.Name(), info.Size(), info.ModTime().Format(time.RFC3339))
+	}
+	return sb.String()
+}
+
+// expandGlobs resolves a comma-separated list of paths or glob patterns
+func expandGlobs(list string) []string {
+	var paths []string
+	for _, pattern := range strings.Split(list, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil || len(matches) == 0 {
+			paths = append(paths, pattern)
+			continue
+		}
+		paths = append(paths, matches...)
+	}
+	return paths
+}
+
+// readTail reads at most limit bytes from the end of a file
+func readTail(path string, limit int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > limit {
+		if _, err := f.Seek(info.Size()-limit, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+	return io.ReadAll(f)
+}
+
+// scrubSecrets masks the configured credentials found in data, and anything else
+// shaped like a credential
+func scrubSecrets(data []byte, secrets []string) []byte {
+	// Logs written with DEBUG_UNSAFE_LOGS may hold cookies and codes minted at runtime
+	return []byte(logging.Scrub(string(data), secrets...))
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// telegramWebhookPath is where Telegram posts the messages sent to the bot
+const telegramWebhookPath = "/webhooks/telegram"
+
+// telegramUpdate is the part of a Bot API update the commands need
+type telegramUpdate struct {
+	Message *struct {
+		Chat struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+		Text string `json:"text"`
+	} `json:"message"`
+}
+
+// botCommand is a command the Telegram bot answers
+type botCommand struct {
+	name string
+	args string
+	help string
+	run  func(a *app, args []string) (string, error)
+}
+
+// botCommands is the bot's command table; /help lists it
+var botCommands = []botCommand{
+	{name: "/snooze", args: "CASE DURATION", help: "mute notifications for a case, e.g. /snooze IOE0123456789 3d", run: (*app).botSnooze},
+	{name: "/unsnooze", args: "CASE", help: "notify about a case again", run: (*app).botUnsnooze},
+	{name: "/snoozes", help: "list the snoozed cases", run: (*app).botSnoozes},
+}
+
+// handleTelegramUpdate answers a command sent to the bot in TELEGRAM_CHAT_ID
+// Telegram sends TELEGRAM_WEBHOOK_SECRET with every update; requests without it are
+// rejected. The reply goes back in the response, so no further Bot API call is made
+func (a *app) handleTelegramUpdate(w http.ResponseWriter, r *http.Request) {
+	secret := r.Header.Get("X-Telegram-Bot-Api-Secret-Token")
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(a.cfg.TelegramWebhookSecret)) != 1 {
+		log.Printf("Rejected a Telegram webhook request without the secret token (check the secret_token given to setWebhook)")
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	var update telegramUpdate
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&update); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	message := update.Message
+	if message == nil || !strings.HasPrefix(message.Text, "/") {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	chatID := strconv.FormatInt(message.Chat.ID, 10)
+	if chatID != a.cfg.TelegramChatID {
+		log.Printf("Ignoring a Telegram bot command from chat %s, which isn't TELEGRAM_CHAT_ID", chatID)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"method":  "sendMessage",
+		"chat_id": message.Chat.ID,
+		"text":    a.runBotCommand(message.Text),
+	})
+}
+
+// runBotCommand runs a command message and returns the reply
+func (a *app) runBotCommand(text string) string {
+	fields := strings.Fields(text)
+	// In groups commands may be addressed to the bot: /snooze@case_tracker_bot
+	name, _, _ := strings.Cut(strings.ToLower(fields[0]), "@")
+	i := slices.IndexFunc(botCommands, func(c botCommand) bool { return c.name == name })
+	if i < 0 {
+		return botHelp()
+	}
+	command := botCommands[i]
+	reply, err := command.run(a, fields[1:])
+	if err != nil {
+		return fmt.Sprintf("%s failed: %v\nUsage: %s %s", command.name, err, command.name, command.args)
+	}
+	return reply
+}
+
+// botHelp lists the bot's commands
+func botHelp() string {
+	lines := []string{"Commands:"}
+	for _, c := range botCommands {
+		lines = append(lines, strings.TrimSpace(c.name+" "+c.args)+" - "+c.help)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// botSnooze implements /snooze CASE DURATION
+func (a *app) botSnooze(args []string) (string, error) {
+	if len(args) != 2 {
+		return "", fmt.Errorf("expected a case and a duration")
+	}
+	d, err := parseSnoozeDuration(args[1])
+	if err != nil {
+		return "", err
+	}
+	caseID := uscis.NormalizeReceiptNumber(args[0])
+	until, err := a.snooze(caseID, d, "telegram")
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Notifications for %s are muted until %s. Polling and history recording continue.", caseID, a.recipientLocale().FormatDateTime(until)), nil
+}
+
+// botUnsnooze implements /unsnooze CASE
+func (a *app) botUnsnooze(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("expected a case")
+	}
+	caseID := uscis.NormalizeReceiptNumber(args[0])
+	if !slices.Contains(a.caseIDs(), caseID) {
+		return "", fmt.Errorf("unknown case %q", caseID)
+	}
+	if err := a.snoozes.Clear(caseID); err != nil {
+		return "", err
+	}
+	log.Printf("[%s] Snooze cleared via telegram", caseID)
+	return fmt.Sprintf("Notifications for %s are on again.", caseID), nil
+}
+
+// botSnoozes implements /snoozes
+func (a *app) botSnoozes(args []string) (string, error) {
+	snoozes, err := a.snoozes.List(time.Now())
+	if err != nil {
+		return "", err
+	}
+	if len(snoozes) == 0 {
+		return "No case is snoozed.", nil
+	}
+	loc := a.recipientLocale()
+	lines := []string{"Snoozed:"}
+	for _, s := range snoozes {
+		lines = append(lines, fmt.Sprintf("%s until %s", s.CaseID, loc.FormatDateTime(s.Until)))
+	}
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/config"
+	"github.com/phhowardchen/case-tracker/internal/health"
+	"github.com/phhowardchen/case-tracker/internal/locale"
+	"github.com/phhowardchen/case-tracker/internal/pdf"
+	"github.com/phhowardchen/case-tracker/internal/storage"
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// caseReport is everything known about one case, for a printable timeline
+type caseReport struct {
+	caseID      string
+	form        string
+	status      string
+	stage       string
+	description string
+	filed       time.Time // zero if unknown
+	lastUpdated time.Time // zero if unknown
+	generated   time.Time
+	milestones  []uscis.Milestone
+	notices     []uscis.CaseAction // USCIS's own case history, oldest first
+	history     []caseHistoryEntry // Oldest first
+	notes       []storage.Note
+}
+
+// buildCaseReport collects the saved history and notes of a case
+// Fails when nothing was ever saved for the case
+func (a *app) buildCaseReport(caseID string) (*caseReport, error) {
+	overview := caseOverview{CaseID: caseID}
+	if err := a.loadCaseHistory(&overview, 0); err != nil {
+		return nil, fmt.Errorf("failed to load history: %w", err)
+	}
+	if overview.Status == "" && len(overview.History) == 0 {
+		return nil, fmt.Errorf("no saved state for %s", caseID)
+	}
+
+	report := &caseReport{
+		caseID:      caseID,
+		form:        overview.Form,
+		status:      overview.Status,
+		stage:       overview.Stage,
+		description: overview.Description,
+		generated:   time.Now(),
+	}
+	if overview.LastUpdated != nil {
+		report.lastUpdated = *overview.LastUpdated
+	}
+	for i := len(overview.History) - 1; i >= 0; i-- {
+		report.history = append(report.history, overview.History[i])
+	}
+
+	// Backends without history only have the latest state, so no milestones
+	var latest map[string]interface{}
+	var observations []uscis.Observation
+	store := a.caseStorage(caseID)
+	if lister, ok := store.(snapshotLister); ok {
+		snapshots, err := lister.ListSnapshots()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load history: %w", err)
+		}
+		for _, snap := range snapshots {
+			observations = append(observations, uscis.Observation{Time: snap.Timestamp, Status: snap.Data})
+			latest = snap.Data
+		}
+	} else {
+		var err error
+		if latest, err = store.Load(); err != nil {
+			return nil, fmt.Errorf("failed to load state: %w", err)
+		}
+	}
+	report.milestones = uscis.BuildMilestones(observations)
+	if latest != nil {
+		report.filed, _ = uscis.FiledDate(latest)
+		report.notices = uscis.NewCaseStatus(latest).Actions
+	}
+
+	if a.stateDB != nil {
+		notes, err := a.stateDB.ListNotes(caseID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load notes: %w", err)
+		}
+		report.notes = notes
+	}
+	return report, nil
+}
+
+// reportDates formats dates for a report in the recipient's time zone and locale
+// The PDF fonts only cover Latin text, so locales with other scripts fall back to
+// English dates there
+type reportDates struct {
+	loc       locale.Settings
+	latinOnly bool
+}
+
+// date formats a day
+func (d reportDates) date(t time.Time) string {
+	if s := d.loc.FormatDate(t); !d.latinOnly || pdf.Encodable(s) {
+		return s
+	}
+	return d.loc.In(t).Format("Jan 2, 2006")
+}
+
+// dateTime formats a day and time
+func (d reportDates) dateTime(t time.Time) string {
+	if s := d.loc.FormatDateTime(t); !d.latinOnly || pdf.Encodable(s) {
+		return s
+	}
+	return d.loc.In(t).Format("Jan 2, 2006 3:04 PM MST")
+}
+
+// summary lists the headline fields of the report as label/value pairs
+func (r *caseReport) summary(dates reportDates) [][2]string {
+	fields := [][2]string{{"Case", r.caseID}}
+	if r.form != "" {
+		fields = append(fields, [2]string{"Form", r.form})
+	}
+	status := r.status
+	if status == "" {
+		status = "unknown"
+	}
+	if r.stage != "" {
+		status += " (" + r.stage + ")"
+	}
+	fields = append(fields, [2]string{"Status", status})
+	if !r.filed.IsZero() {
+		fields = append(fields, [2]string{"Filed", dates.date(r.filed)})
+	}
+	if !r.lastUpdated.IsZero() {
+		fields = append(fields, [2]string{"Updated by USCIS", dates.date(r.lastUpdated)})
+	}
+	if !r.filed.IsZero() && len(r.milestones) > 0 {
+		days := int(r.milestones[len(r.milestones)-1].Date.Sub(r.filed).Hours() / 24)
+		fields = append(fields, [2]string{"Days since filing", fmt.Sprintf("%d (to the latest milestone)", days)})
+	}
+	return fields
+}
+
+// historyText describes one history entry
+func historyText(entry caseHistoryEntry) string {
+	if len(entry.Changes) == 0 {
+		return "First seen: " + entry.Status
+	}
+	return strings.TrimSpace(uscis.FormatChanges(entry.Changes))
+}
+
+// renderPDF lays the report out as a PDF document
+func (r *caseReport) renderPDF(brand string, loc locale.Settings) ([]byte, error) {
+	dates := reportDates{loc: loc, latinOnly: true}
+	doc := pdf.New(fmt.Sprintf("%s - %s timeline", brand, r.caseID))
+	doc.Title(fmt.Sprintf("Case timeline: %s", r.caseID))
+	doc.Text(fmt.Sprintf("Generated by %s on %s", brand, dates.dateTime(r.generated)))
+	doc.Space(8)
+	for _, field := range r.summary(dates) {
+		doc.Field(field[0], field[1])
+	}
+	if r.description != "" {
+		doc.Space(4)
+		doc.Text(r.description)
+	}
+
+	doc.Heading("Milestones")
+	if len(r.milestones) == 0 {
+		doc.Text("No saved history")
+	}
+	for _, m := range r.milestones {
+		doc.Row(dates.date(m.Date), m.Status, 110)
+	}
+
+	doc.Heading("Notices and USCIS history")
+	if len(r.notices) == 0 {
+		doc.Text("USCIS reported no case history")
+	}
+	for _, action := range r.notices {
+		date := "undated"
+		if !action.Date.IsZero() {
+			date = dates.date(action.Date)
+		}
+		doc.Row(date, action.Description, 110)
+	}
+
+	doc.Heading("Changes seen by the tracker")
+	if len(r.history) == 0 {
+		doc.Text("No saved history")
+	}
+	for _, entry := range r.history {
+		doc.Row(dates.dateTime(entry.Time), historyText(entry), 160)
+	}
+
+	if len(r.notes) > 0 {
+		doc.Heading("Notes")
+		for _, note := range r.notes {
+			doc.Row(dates.dateTime(note.CreatedAt), note.Text, 160)
+		}
+	}
+	return doc.Bytes()
+}
+
+// renderText writes the report as plain text
+func (r *caseReport) renderText(w io.Writer, loc locale.Settings) {
+	dates := reportDates{loc: loc}
+	fmt.Fprintf(w, "Case timeline: %s (generated %s)\n\n", r.caseID, dates.dateTime(r.generated))
+	for _, field := range r.summary(dates) {
+		fmt.Fprintf(w, "%-18s %s\n", field[0]+":", field[1])
+	}
+	if r.description != "" {
+		fmt.Fprintf(w, "\n%s\n", r.description)
+	}
+
+	fmt.Fprintln(w, "\nMilestones")
+	for _, m := range r.milestones {
+		fmt.Fprintf(w, "  %s  %s\n", dates.date(m.Date), m.Status)
+	}
+	fmt.Fprintln(w, "\nNotices and USCIS history")
+	for _, action := range r.notices {
+		date := "undated"
+		if !action.Date.IsZero() {
+			date = dates.date(action.Date)
+		}
+		fmt.Fprintf(w, "  %s  %s\n", date, action.Description)
+	}
+	fmt.Fprintln(w, "\nChanges seen by the tracker")
+	for _, entry := range r.history {
+		text := strings.ReplaceAll(historyText(entry), "\n", "\n    ")
+		fmt.Fprintf(w, "  %s\n    %s\n", dates.dateTime(entry.Time), text)
+	}
+	if len(r.notes) > 0 {
+		fmt.Fprintln(w, "\nNotes")
+		for _, note := range r.notes {
+			fmt.Fprintf(w, "  %s  %s\n", dates.dateTime(note.CreatedAt), note.Text)
+		}
+	}
+}
+
+// runCaseReport implements `tracker report -case ID` to export a case's timeline
+func runCaseReport(args []string) int {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	caseID := fs.String("case", "", "the case to report on (required)")
+	format := fs.String("format", "pdf", "pdf or text")
+	output := fs.String("o", "", "output file (default <case>-timeline.pdf; text goes to stdout); - for stdout")
+	fs.Parse(args)
+
+	if *caseID == "" {
+		fmt.Fprintln(os.Stderr, "usage: tracker report -case ID [-format pdf|text] [-o FILE]")
+		return 2
+	}
+	if *format != "pdf" && *format != "text" {
+		fmt.Fprintf(os.Stderr, "Unknown format %q (want pdf or text)\n", *format)
+		return 2
+	}
+	id := strings.ToUpper(*caseID)
+
+	cfg, err := config.LoadPartial()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return exitConfigError
+	}
+	a, err := newApp(cfg, nil, health.NewTracker("report", cfg.CaseIDs))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize: %v\n", err)
+		return exitUnexpected
+	}
+	report, err := a.buildCaseReport(id)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to build report: %v\n", err)
+		return exitUnexpected
+	}
+	loc := a.localeFor([]string{id})
+
+	if *format == "text" {
+		if *output == "" || *output == "-" {
+			report.renderText(os.Stdout, loc)
+			return exitOK
+		}
+		var b strings.Builder
+		report.renderText(&b, loc)
+		return writeReport(*output, []byte(b.String()))
+	}
+
+	data, err := report.renderPDF(cfg.BrandName, loc)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to render report: %v\n", err)
+		return exitUnexpected
+	}
+	if *output == "-" {
+		os.Stdout.Write(data)
+		return exitOK
+	}
+	if *output == "" {
+		*output = id + "-timeline.pdf"
+	}
+	return writeReport(*output, data)
+}
+
+// writeReport saves a report readable by the owner only, since it holds case details
+func writeReport(path string, data []byte) int {
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write %s: %v\n", path, err)
+		return exitUnexpected
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %s\n", path)
+	return exitOK
+}
+
+// handleCaseReport serves the PDF timeline of a tracked case at /cases/report?case=ID
+func (a *app) handleCaseReport(w http.ResponseWriter, r *http.Request) {
+	caseID := strings.ToUpper(r.FormValue("case"))
+	if !a.canSeeCase(r, caseID) {
+		http.Error(w, "unknown case", http.StatusNotFound)
+		return
+	}
+	report, err := a.buildCaseReport(caseID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	data, err := report.renderPDF(a.cfg.BrandName, a.localeFor([]string{caseID}))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-timeline.pdf"`, caseID))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(data)
+}
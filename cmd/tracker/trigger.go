@@ -0,0 +1,125 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// pollTrigger asks the main loop for an immediate poll of some cases
+type pollTrigger struct {
+	caseIDs []string
+	done    chan triggerResponse // receives the result once the poll is over
+}
+
+// triggerResponse is the result of an on-demand poll
+type triggerResponse struct {
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt time.Time     `json:"finished_at"`
+	Cases      []triggerCase `json:"cases"`
+}
+
+// triggerCase is what an on-demand poll found for one case
+type triggerCase struct {
+	caseOutcome
+	Status string `json:"status,omitempty"` // headline status after the poll
+}
+
+// handleTriggerAPI polls one case or all of them right away, outside the schedule, and
+// returns what changed. Notifications go out as for a scheduled poll
+// POST /api/trigger polls every case; POST /api/trigger?case=IOE0123456789 just one
+func (a *app) handleTriggerAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !a.authorizeAPIWrite(w, r) {
+		return
+	}
+
+	caseIDs := a.caseIDs()
+	// Case IDs are configured normalized, so "ioe 0123456789" finds IOE0123456789
+	if caseID := uscis.NormalizeReceiptNumber(r.URL.Query().Get("case")); caseID != "" {
+		if !slices.Contains(caseIDs, caseID) {
+			http.Error(w, "unknown case", http.StatusNotFound)
+			return
+		}
+		caseIDs = []string{caseID}
+	}
+	if a.toggles.isPaused() {
+		http.Error(w, "polling is paused via the admin API", http.StatusConflict)
+		return
+	}
+	if a.pollLock != nil && !a.pollLock.state().Held {
+		http.Error(w, "standby: another instance holds the poll lock", http.StatusServiceUnavailable)
+		return
+	}
+
+	// Queued until a running cycle is over
+	t := &pollTrigger{caseIDs: caseIDs, done: make(chan triggerResponse, 1)}
+	select {
+	case a.triggers <- t:
+	case <-a.ctx.Done():
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	case <-r.Context().Done():
+		return
+	}
+	log.Printf("Admin: on-demand poll of %d case(s) (via api)", len(caseIDs))
+	writeJSON(w, http.StatusOK, <-t.done)
+}
+
+// pollTriggered runs an on-demand poll for the main loop and answers the trigger
+func (a *app) pollTriggered(t *pollTrigger) {
+	response := triggerResponse{StartedAt: time.Now()}
+	a.flushOutbox()
+	results := a.runCycle("on-demand poll", response.StartedAt, t.caseIDs)
+	response.FinishedAt = time.Now()
+	response.Cases = a.triggerOutcomes(t.caseIDs, results, response.StartedAt)
+	t.done <- response
+}
+
+// triggerOutcomes describes each case of an on-demand poll started at the given time
+// Cases without a result failed, or weren't fetched (cycle or request budget, circuit
+// breaker, held login, shutdown); the health tracker tells the two apart
+func (a *app) triggerOutcomes(caseIDs []string, results []*caseResult, started time.Time) []triggerCase {
+	byCase := make(map[string]*caseResult, len(results))
+	for _, r := range results {
+		byCase[r.caseID] = r
+	}
+	failures := make(map[string]string) // last error of the cases that failed since started
+	for _, c := range a.health.Snapshot().Cases {
+		if c.ConsecutiveFailures > 0 && !c.LastCheck.Before(started) {
+			failures[c.CaseID] = c.LastError
+		}
+	}
+
+	outcomes := make([]triggerCase, 0, len(caseIDs))
+	for _, caseID := range caseIDs {
+		outcome := triggerCase{caseOutcome: caseOutcome{CaseID: caseID}}
+		r := byCase[caseID]
+		switch {
+		case r != nil && r.isFirstRun():
+			outcome.Outcome = outcomeFirstRun
+		case r != nil && len(r.changes) > 0:
+			outcome.Outcome = outcomeChanged
+			outcome.Changes = r.changes
+		case r != nil:
+			outcome.Outcome = outcomeUnchanged
+		case failures[caseID] != "":
+			outcome.Outcome = outcomeFetchFailed
+			outcome.Error = failures[caseID]
+		default:
+			outcome.Outcome = outcomeSkipped
+		}
+		if r != nil {
+			outcome.Status = uscis.StatusSummary(r.status)
+		}
+		outcomes = append(outcomes, outcome)
+	}
+	return outcomes
+}
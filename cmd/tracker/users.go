@@ -0,0 +1,354 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/config"
+	"github.com/phhowardchen/case-tracker/internal/storage"
+)
+
+// sessionCookie holds the access token of a user signed in to the dashboard
+const sessionCookie = "tracker_session"
+
+// sessionCookieTTL is how long a dashboard sign-in lasts
+const sessionCookieTTL = 30 * 24 * time.Hour
+
+// userContextKey is the request context key of the signed-in user
+type userContextKey struct{}
+
+// apiTokenUser is who a request with API_TOKEN acts as
+var apiTokenUser = &storage.User{Name: "API_TOKEN", Role: storage.RoleAdmin}
+
+// restrict guards a handler of case data or operations
+// Without users in users.json everything stays as it was: reads are open and writes need
+// API_TOKEN. With users, the request must come from one with at least the given role;
+// pages redirect to /login, API calls get a 401. The user is passed on in the context,
+// so handlers can limit what they return to the user's cases (see canSeeCase)
+func (a *app) restrict(role string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		users, err := a.users.List()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(users) == 0 {
+			next(w, r)
+			return
+		}
+
+		user, err := a.requestUser(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if user == nil {
+			if r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept"), "text/html") {
+				http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="case-tracker"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if role == storage.RoleAdmin && user.Role != storage.RoleAdmin {
+			http.Error(w, "forbidden: needs the admin role", http.StatusForbidden)
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), userContextKey{}, user)))
+	}
+}
+
+// requestUser identifies the user of a request by its bearer token or session cookie
+// Returns nil for an anonymous request or an unknown token
+func (a *app) requestUser(r *http.Request) (*storage.User, error) {
+	token, bearer := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !bearer {
+		cookie, err := r.Cookie(sessionCookie)
+		if err != nil {
+			return nil, nil
+		}
+		token = cookie.Value
+	}
+	if token == "" {
+		return nil, nil
+	}
+	if a.cfg.APIToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(a.cfg.APIToken)) == 1 {
+		return apiTokenUser, nil
+	}
+	return a.users.ByToken(token)
+}
+
+// requestUserFrom returns the user restrict let through, or nil without access control
+func requestUserFrom(r *http.Request) *storage.User {
+	user, _ := r.Context().Value(userContextKey{}).(*storage.User)
+	return user
+}
+
+// canSeeCase reports whether the request may see a tracked case
+// Admins see every case; viewers the cases, bundles (CASE_BUNDLES) and tenants (TENANTS) granted to them
+func (a *app) canSeeCase(r *http.Request, caseID string) bool {
+	if !slices.Contains(a.caseIDs(), caseID) {
+		return false
+	}
+	user := requestUserFrom(r)
+	if user == nil || user.Role == storage.RoleAdmin {
+		return true
+	}
+	for _, grant := range user.Cases {
+		if strings.EqualFold(grant, caseID) {
+			return true
+		}
+		if bundle := a.cfg.BundleFor(caseID); bundle != nil && grant == bundle.Name {
+			return true
+		}
+		if tenant := a.cfg.TenantName(caseID); tenant != "" && grant == tenant {
+			return true
+		}
+	}
+	return false
+}
+
+// visibleCases returns the tracked cases the request may see, in configuration order
+func (a *app) visibleCases(r *http.Request) []string {
+	var caseIDs []string
+	for _, caseID := range a.caseIDs() {
+		if a.canSeeCase(r, caseID) {
+			caseIDs = append(caseIDs, caseID)
+		}
+	}
+	return caseIDs
+}
+
+// isAdminRequest reports whether the request may use admin features of the dashboard
+func isAdminRequest(r *http.Request) bool {
+	user := requestUserFrom(r)
+	return user == nil || user.Role == storage.RoleAdmin
+}
+
+var loginTemplate = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>Sign in - {{.Brand}}</title></head>
+<body style="font-family: -apple-system, Helvetica, Arial, sans-serif; max-width: 480px; margin: 2em auto; padding: 0 1em;">
+<h2>Sign in to {{.Brand}}</h2>
+{{if .Error}}<p style="color: #b00020;">{{.Error}}</p>{{end}}
+<form method="post" action="/login">
+<input type="hidden" name="next" value="{{.Next}}">
+<p><label>Access token<br><input type="password" name="token" autocomplete="current-password" style="width: 100%;" autofocus></label></p>
+<p><button type="submit">Sign in</button></p>
+</form>
+<p style="color: #777;"><small>Ask whoever runs this tracker for your access token.</small></p>
+</body>
+</html>
+`))
+
+// handleLogin signs a user in to the dashboard with their access token
+// The token is only read from the posted form: in the URL it would end up in access
+// logs and the browser history
+func (a *app) handleLogin(w http.ResponseWriter, r *http.Request) {
+	next := r.FormValue("next")
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") {
+		next = "/cases"
+	}
+
+	token := r.PostFormValue("token")
+	view := map[string]interface{}{"Brand": a.cfg.BrandName, "Next": next}
+	if token != "" {
+		user, err := a.users.ByToken(token)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if user != nil {
+			log.Printf("Dashboard sign-in by %s (%s)", user.Name, user.Role)
+			http.SetCookie(w, &http.Cookie{
+				Name:     sessionCookie,
+				Value:    token,
+				Path:     "/",
+				MaxAge:   int(sessionCookieTTL.Seconds()),
+				HttpOnly: true,
+				Secure:   r.TLS != nil || strings.HasPrefix(a.cfg.PublicURL, "https://"),
+				SameSite: http.SameSiteLaxMode,
+			})
+			http.Redirect(w, r, next, http.StatusSeeOther)
+			return
+		}
+		view["Error"] = "Unknown access token"
+		w.WriteHeader(http.StatusUnauthorized)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	loginTemplate.Execute(w, view)
+}
+
+// handleLogout signs the dashboard user out
+func handleLogout(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: "", Path: "/", MaxAge: -1, HttpOnly: true})
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+// newAccessToken creates a random access token
+func newAccessToken() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to create access token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// runUsers manages the dashboard and API users
+func runUsers(args []string) int {
+	const usageLine = "usage: tracker users list | add <name> [-role viewer|admin] [-cases ID,bundle...] | token <name> | remove <name>"
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, usageLine)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("users "+args[0], flag.ExitOnError)
+	role := fs.String("role", storage.RoleViewer, "admin (every case, may change state) or viewer (read-only)")
+	cases := fs.String("cases", "", "comma-separated case IDs and CASE_BUNDLES names a viewer may see")
+	var name string
+	rest := args[1:]
+	if len(rest) > 0 && !strings.HasPrefix(rest[0], "-") {
+		name, rest = rest[0], rest[1:]
+	}
+	fs.Parse(rest)
+	if name == "" && fs.NArg() > 0 {
+		name = fs.Arg(0)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return 1
+	}
+	store := storage.NewUserStore(cfg.StateFileDir)
+
+	switch args[0] {
+	case "list":
+		users, err := store.List()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tROLE\tCASES\tCREATED")
+		for _, user := range users {
+			grants := strings.Join(user.Cases, ",")
+			if user.Role == storage.RoleAdmin {
+				grants = "all"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", user.Name, user.Role, grants, user.CreatedAt.Format("2006-01-02"))
+		}
+		tw.Flush()
+		return 0
+
+	case "add":
+		if name == "" {
+			fmt.Fprintln(os.Stderr, usageLine)
+			return 2
+		}
+		user := storage.User{Name: name, Role: strings.ToLower(*role), CreatedAt: time.Now()}
+		if user.Role != storage.RoleAdmin && user.Role != storage.RoleViewer {
+			fmt.Fprintf(os.Stderr, "invalid role %q (allowed: admin, viewer)\n", *role)
+			return 2
+		}
+		if *cases != "" {
+			for _, grant := range strings.Split(*cases, ",") {
+				if grant = strings.TrimSpace(grant); grant == "" {
+					continue
+				}
+				if err := checkGrant(cfg, grant); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					return 2
+				}
+				user.Cases = append(user.Cases, grant)
+			}
+		}
+		if user.Role == storage.RoleViewer && len(user.Cases) == 0 {
+			fmt.Fprintln(os.Stderr, "a viewer needs -cases")
+			return 2
+		}
+		return issueToken(store, user)
+
+	case "token":
+		users, err := store.List()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		i := slices.IndexFunc(users, func(u storage.User) bool { return u.Name == name })
+		if i < 0 {
+			fmt.Fprintf(os.Stderr, "no user named %q\n", name)
+			return 1
+		}
+		return issueToken(store, users[i])
+
+	case "remove":
+		removed, err := store.Remove(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if !removed {
+			fmt.Fprintf(os.Stderr, "no user named %q\n", name)
+			return 1
+		}
+		fmt.Printf("Removed %s\n", name)
+		return 0
+
+	default:
+		fmt.Fprintln(os.Stderr, usageLine)
+		return 2
+	}
+}
+
+// checkGrant verifies that a -cases entry names a tracked case, a bundle or a tenant
+func checkGrant(cfg *config.Config, grant string) error {
+	if slices.Contains(cfg.CaseIDs, strings.ToUpper(grant)) {
+		return nil
+	}
+	for _, bundle := range cfg.Bundles {
+		if bundle.Name == grant {
+			return nil
+		}
+	}
+	for _, tenant := range cfg.Tenants {
+		if tenant.Name == grant {
+			return nil
+		}
+	}
+	return fmt.Errorf("%q is neither a tracked case, a bundle in CASE_BUNDLES nor a tenant in TENANTS", grant)
+}
+
+// issueToken gives a user a new access token, saves them and prints the token once
+// Any earlier token of the user stops working
+func issueToken(store *storage.UserStore, user storage.User) int {
+	token, err := newAccessToken()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	user.TokenHash = storage.HashToken(token)
+	if err := store.Put(user); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("Access token for %s (%s), shown only once:\n\n  %s\n\n", user.Name, user.Role, token)
+	fmt.Println("Sign in at /login, or send it as \"Authorization: Bearer <token>\" to the API.")
+	return 0
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// version is set at build time via -ldflags "-X main.version=..."
+var version = "dev"
+
+// versionInfo describes the running binary for diagnostics
+type versionInfo struct {
+	Version   string `json:"version"`
+	Revision  string `json:"revision,omitempty"`
+	BuildTime string `json:"build_time,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+// currentVersion collects version details from the linker flag and embedded build info
+func currentVersion() versionInfo {
+	info := versionInfo{
+		Version:   version,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range buildInfo.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				info.Revision = setting.Value
+			case "vcs.time":
+				info.BuildTime = setting.Value
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+
+	return info
+}
+
+// versionString returns a short human-readable version
+func versionString() string {
+	info := currentVersion()
+	if info.Revision == "" {
+		return info.Version
+	}
+	revision := info.Revision
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	return fmt.Sprintf("%s (%s)", info.Version, revision)
+}
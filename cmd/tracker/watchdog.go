@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"sync"
+
+	"github.com/phhowardchen/case-tracker/internal/source"
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// browserRecycler is implemented by fetchers that can restart their underlying browser
+type browserRecycler interface {
+	Recycle() error
+}
+
+// fetchWatchdog tracks consecutive fetch timeouts across cases and recycles
+// the browser once too many navigations in a row have hung
+type fetchWatchdog struct {
+	mu          sync.Mutex
+	threshold   int
+	consecutive int
+}
+
+// newFetchWatchdog creates a watchdog that recycles after threshold consecutive timeouts
+func newFetchWatchdog(threshold int) *fetchWatchdog {
+	return &fetchWatchdog{threshold: threshold}
+}
+
+// observe records the outcome of a fetch and recycles the browser when needed
+// Returns an error only if a recycle was attempted and failed
+func (w *fetchWatchdog) observe(fetcher source.Fetcher, caseID string, fetchErr error) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var timeout *uscis.ErrFetchTimeout
+	if !errors.As(fetchErr, &timeout) {
+		// Any non-timeout outcome means navigation is not stuck
+		w.consecutive = 0
+		return nil
+	}
+
+	w.consecutive++
+	log.Printf("[%s] Fetch timed out (%d consecutive timeout(s), recycle at %d)", caseID, w.consecutive, w.threshold)
+
+	if w.consecutive < w.threshold {
+		return nil
+	}
+
+	recycler, ok := fetcher.(browserRecycler)
+	if !ok {
+		// HTTP client has nothing to recycle - the per-request timeout is enough
+		w.consecutive = 0
+		return nil
+	}
+
+	log.Printf("Watchdog: %d consecutive fetch timeouts, recycling browser...", w.consecutive)
+	w.consecutive = 0
+	if err := recycler.Recycle(); err != nil {
+		return fmt.Errorf("browser recycle failed: %w", err)
+	}
+	return nil
+}
+
+// reset clears the consecutive timeout count and returns what it was
+func (w *fetchWatchdog) reset() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	count := w.consecutive
+	w.consecutive = 0
+	return count
+}
+
+// sendRecycleFailedAlert tells the user that the browser was restarted after hung fetches
+// and didn't come back
+func (a *app) sendRecycleFailedAlert(recycleErr error) {
+	subject := a.cfg.BrandName + " - Browser Restart Failed"
+	body := fmt.Sprintf(`
+		<h2>⚠️ Browser Restart Failed</h2>
+		<p>%d fetch(es) in a row timed out after FETCH_TIMEOUT (%v), so the tracker restarted its Chrome, but the new browser isn't working.</p>
+		<p><strong>Error:</strong> %s</p>
+		<p>The next fetches try again with it. If they keep timing out, check that the machine has enough memory for Chrome and that USCIS is reachable from it, then restart the tracker.</p>
+	`, a.cfg.BrowserRecycleAfter, a.cfg.FetchTimeout, template.HTMLEscapeString(recycleErr.Error()))
+
+	if err := a.sendAlert(nil, subject, body); err != nil {
+		log.Printf("Failed to send browser restart alert: %v", err)
+	}
+}
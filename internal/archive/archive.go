@@ -0,0 +1,100 @@
+package archive
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Store keeps archive objects, e.g. in a cold storage bucket
+// Object names use "/" separators whatever the backend
+type Store interface {
+	// Put writes an object, replacing any object of the same name
+	Put(name string, data []byte) error
+	// Get reads an object
+	Get(name string) ([]byte, error)
+	// List returns the names of the objects starting with prefix, sorted
+	List(prefix string) ([]string, error)
+}
+
+// Open returns the store at a location: "gs://bucket/prefix" for Google Cloud Storage,
+// otherwise a local directory (which may be a mounted bucket)
+func Open(location string) (Store, error) {
+	switch {
+	case location == "":
+		return nil, errors.New("no archive location configured")
+	case strings.HasPrefix(location, "gs://"):
+		bucket, prefix, _ := strings.Cut(strings.TrimPrefix(location, "gs://"), "/")
+		if bucket == "" {
+			return nil, fmt.Errorf("invalid archive location %q: expected gs://bucket/prefix", location)
+		}
+		return newGCSStore(bucket, strings.Trim(prefix, "/")), nil
+	case strings.Contains(location, "://"):
+		return nil, fmt.Errorf("unsupported archive location %q: use gs://bucket/prefix or a directory (e.g. a mounted S3 bucket)", location)
+	default:
+		return &dirStore{dir: location}, nil
+	}
+}
+
+// dirStore keeps archive objects as files below a directory
+type dirStore struct {
+	dir string
+}
+
+// Put writes an object atomically
+func (d *dirStore) Put(name string, data []byte) error {
+	path := filepath.Join(d.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write archive object: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to rename archive object: %w", err)
+	}
+	return nil
+}
+
+// Get reads an object
+func (d *dirStore) Get(name string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(d.dir, filepath.FromSlash(name)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive object: %w", err)
+	}
+	return data, nil
+}
+
+// List returns the objects starting with prefix
+func (d *dirStore) List(prefix string) ([]string, error) {
+	var names []string
+	err := filepath.WalkDir(d.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() || strings.HasSuffix(path, ".tmp") {
+			return nil
+		}
+		rel, err := filepath.Rel(d.dir, path)
+		if err != nil {
+			return err
+		}
+		if name := filepath.ToSlash(rel); strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archive: %w", err)
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,157 @@
+package archive
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/gcp"
+)
+
+// gcsStorageClass is the storage class archive objects are written with
+const gcsStorageClass = "COLDLINE"
+
+// gcsStore keeps archive objects in a Cloud Storage bucket through the XML API
+// It authenticates as the service account of the GCE VM or Cloud Run service,
+// which needs write access to the bucket
+type gcsStore struct {
+	httpClient *http.Client
+	bucket     string
+	prefix     string // object name prefix without trailing slash, may be empty
+	tokens     *gcp.TokenSource
+}
+
+// newGCSStore creates a store for objects below prefix in a bucket
+func newGCSStore(bucket, prefix string) *gcsStore {
+	httpClient := &http.Client{Timeout: 2 * time.Minute}
+	return &gcsStore{
+		httpClient: httpClient,
+		bucket:     bucket,
+		prefix:     prefix,
+		tokens:     gcp.NewTokenSource(httpClient),
+	}
+}
+
+// Put uploads an object in the coldline storage class
+func (g *gcsStore) Put(name string, data []byte) error {
+	req, err := http.NewRequest(http.MethodPut, g.objectURL(name), bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create upload request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/gzip")
+	req.Header.Set("x-goog-storage-class", gcsStorageClass)
+	if _, err := g.do(req); err != nil {
+		return fmt.Errorf("failed to upload gs://%s/%s: %w", g.bucket, g.key(name), err)
+	}
+	return nil
+}
+
+// Get downloads an object
+func (g *gcsStore) Get(name string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, g.objectURL(name), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create download request: %w", err)
+	}
+	data, err := g.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download gs://%s/%s: %w", g.bucket, g.key(name), err)
+	}
+	return data, nil
+}
+
+// gcsListResult is the XML API response to a bucket listing
+type gcsListResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated bool   `xml:"IsTruncated"`
+	NextMarker  string `xml:"NextMarker"`
+}
+
+// List returns the objects starting with prefix, following pagination
+func (g *gcsStore) List(prefix string) ([]string, error) {
+	var names []string
+	marker := ""
+	for {
+		query := url.Values{"prefix": {g.key(prefix)}}
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+		req, err := http.NewRequest(http.MethodGet, "https://storage.googleapis.com/"+url.PathEscape(g.bucket)+"?"+query.Encode(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create list request: %w", err)
+		}
+		data, err := g.do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list gs://%s: %w", g.bucket, err)
+		}
+
+		var result gcsListResult
+		if err := xml.Unmarshal(data, &result); err != nil {
+			return nil, fmt.Errorf("failed to parse bucket listing: %w", err)
+		}
+		for _, object := range result.Contents {
+			names = append(names, g.name(object.Key))
+		}
+		if !result.IsTruncated || result.NextMarker == "" {
+			break
+		}
+		marker = result.NextMarker
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// do sends an authenticated request and returns the response body
+func (g *gcsStore) do(req *http.Request) ([]byte, error) {
+	token, err := g.tokens.Token()
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if len(body) > 500 {
+			body = body[:500]
+		}
+		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+	return body, nil
+}
+
+// key returns the object key of an archive object name
+func (g *gcsStore) key(name string) string {
+	if g.prefix == "" {
+		return name
+	}
+	return g.prefix + "/" + name
+}
+
+// name returns the archive object name of an object key
+func (g *gcsStore) name(key string) string {
+	if g.prefix == "" {
+		return key
+	}
+	return key[len(g.prefix)+1:]
+}
+
+// objectURL returns the XML API URL of an object
+func (g *gcsStore) objectURL(name string) string {
+	u := url.URL{Scheme: "https", Host: "storage.googleapis.com", Path: "/" + g.bucket + "/" + g.key(name)}
+	return u.String()
+}
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/phhowardchen/case-tracker/internal/source"
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// Account is an additional USCIS account whose cases are tracked too, e.g. a spouse's
+// or a parent's, logged in with a browser session of its own
+type Account struct {
+	Number     int    // N of its USCIS_ACCOUNT_<N>_* settings
+	Name       string // Label in logs and alerts (default: account<N>)
+	Username   string
+	Password   string
+	TOTPSecret string // Authenticator secret of the account; without one the 2FA settings of USCIS_USERNAME apply
+	CaseIDs    []string
+}
+
+// Source returns the name of the source the account's cases are fetched from
+func (a Account) Source() string {
+	return source.Account(a.Name)
+}
+
+// AccountFor returns the additional USCIS account a case is fetched through, if any
+func (c *Config) AccountFor(caseID string) (Account, bool) {
+	for _, account := range c.Accounts {
+		if c.SourceFor(caseID) == account.Source() {
+			return account, true
+		}
+	}
+	return Account{}, false
+}
+
+// MaxAccounts is the number of USCIS accounts: USCIS_USERNAME, then USCIS_ACCOUNT_2_*
+// to USCIS_ACCOUNT_5_*
+const MaxAccounts = 5
+
+// accountFields are the settings of each additional account
+var accountFields = []string{"NAME", "USERNAME", "PASSWORD", "TOTP_SECRET", "CASE_IDS"}
+
+// accountKey returns the name of a setting of the Nth account: USCIS_ACCOUNT_<N>_<field>
+func accountKey(n int, field string) string {
+	return fmt.Sprintf("USCIS_ACCOUNT_%d_%s", n, field)
+}
+
+// accountEnvKeys lists the settings of every additional account, for knownEnvKeys
+func accountEnvKeys() []string {
+	var keys []string
+	for n := 2; n <= MaxAccounts; n++ {
+		for _, field := range accountFields {
+			keys = append(keys, accountKey(n, field))
+		}
+	}
+	return keys
+}
+
+// parseAccounts reads the additional accounts configured with USCIS_ACCOUNT_<N>_*
+// A case belongs to one account at most, and an account is listed once
+func parseAccounts(env environment, mainUsername string) ([]Account, error) {
+	var accounts []Account
+	assigned := make(map[string]string)
+	for n := 2; n <= MaxAccounts; n++ {
+		configured := slices.ContainsFunc(accountFields, func(field string) bool {
+			return env[accountKey(n, field)] != ""
+		})
+		if !configured {
+			continue
+		}
+
+		account := Account{
+			Number:     n,
+			Name:       strings.TrimSpace(env[accountKey(n, "NAME")]),
+			Username:   env[accountKey(n, "USERNAME")],
+			Password:   env[accountKey(n, "PASSWORD")],
+			TOTPSecret: env[accountKey(n, "TOTP_SECRET")],
+		}
+		if account.Name == "" {
+			account.Name = fmt.Sprintf("account%d", n)
+		}
+		if !tenantNamePattern.MatchString(account.Name) {
+			return nil, fmt.Errorf("invalid %s %q: use letters, digits, - and _", accountKey(n, "NAME"), account.Name)
+		}
+		if account.Username == "" || account.Password == "" {
+			return nil, fmt.Errorf("%s and %s are both required for USCIS account %d", accountKey(n, "USERNAME"), accountKey(n, "PASSWORD"), n)
+		}
+		if strings.EqualFold(account.Username, mainUsername) {
+			return nil, fmt.Errorf("%s is USCIS_USERNAME again; list its cases in CASE_IDS instead", accountKey(n, "USERNAME"))
+		}
+		for _, other := range accounts {
+			if other.Name == account.Name {
+				return nil, fmt.Errorf("USCIS accounts %d and %d are both named %q", other.Number, n, account.Name)
+			}
+			if strings.EqualFold(other.Username, account.Username) {
+				return nil, fmt.Errorf("USCIS accounts %d and %d have the same username", other.Number, n)
+			}
+		}
+		if account.TOTPSecret != "" {
+			if _, err := uscis.DecodeTOTPSecret(account.TOTPSecret); err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", accountKey(n, "TOTP_SECRET"), err)
+			}
+		}
+
+		idList := env[accountKey(n, "CASE_IDS")]
+		if strings.EqualFold(strings.TrimSpace(idList), CaseIDsAuto) {
+			return nil, fmt.Errorf("%s=auto is not supported: list the account's receipt numbers", accountKey(n, "CASE_IDS"))
+		}
+		for _, id := range strings.Split(idList, ",") {
+			id = uscis.NormalizeReceiptNumber(id)
+			if id == "" {
+				continue
+			}
+			if other, dup := assigned[id]; dup {
+				return nil, fmt.Errorf("%s is listed for both USCIS accounts %q and %q", id, other, account.Name)
+			}
+			assigned[id] = account.Name
+			account.CaseIDs = append(account.CaseIDs, id)
+		}
+		if len(account.CaseIDs) == 0 {
+			return nil, fmt.Errorf("%s is required for USCIS account %d", accountKey(n, "CASE_IDS"), n)
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, nil
+}
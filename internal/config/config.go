@@ -1,95 +1,702 @@
 package config
 
 import (
+	"cmp"
 	"fmt"
+	"maps"
+	"net/mail"
+	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/email"
+	"github.com/phhowardchen/case-tracker/internal/locale"
+	"github.com/phhowardchen/case-tracker/internal/logging"
+	"github.com/phhowardchen/case-tracker/internal/notifier"
+	"github.com/phhowardchen/case-tracker/internal/source"
+	"github.com/phhowardchen/case-tracker/internal/uscis"
 )
 
+// Bundle groups receipt numbers that belong to one application filed together
+// (e.g. I-485 + I-765 + I-131) so their notifications can be combined
+type Bundle struct {
+	Name    string
+	CaseIDs []string
+}
+
+// PublicCase is a case exposed on the public status page under an alias
+type PublicCase struct {
+	CaseID string
+	Alias  string
+}
+
 // Config holds the application configuration
 type Config struct {
-	USCISCookie    string
-	CaseIDs        []string
-	ResendAPIKey   string
-	RecipientEmail string
-	PollInterval   time.Duration
-	StateFileDir   string
+	USCISCookie      string
+	CaseIDs          []string
+	CaseSources      map[string]string // Source each case is fetched from, when not DefaultSource
+	DefaultSource    string            // Source of cases without an entry in CaseSources (default: myuscis)
+	ResendAPIKey     string
+	ResendDailyQuota int    // Emails the Resend plan allows per day; near it non-urgent email waits (0 = unknown)
+	Notifier         string // Email provider: "resend" (default) or "smtp"
+	SMTPHost         string
+	SMTPPort         int    // default: 587 with STARTTLS, 465 with SSL, 25 without encryption
+	SMTPSecurity     string // "starttls" (default), "ssl" or "none"
+	SMTPUsername     string // no authentication when empty
+	SMTPPassword     string
+	RecipientEmail   string              // First of RecipientEmails; its locale applies to alerts
+	RecipientEmails  []string            // Receive alerts and the updates of cases without their own recipients
+	EmailCC          []string            // Copied on every email notification
+	EmailBCC         []string            // Blind-copied on every email notification
+	CaseRecipients   map[string][]string // Per-case email recipients; unlisted cases go to RecipientEmails
+	PollInterval     time.Duration
+	CaseSchedule     map[string]time.Duration // Poll interval of each case, when not PollInterval
+	CaseNicknames    map[string]string        // Name of each case in notifications, e.g. "Mom's green card"
+	CaseFormTypes    map[string]string        // Form each case is for, e.g. "I-485", shown before USCIS reports it
+	CaseApplicants   map[string]string        // Who each case is for, shown in emails and on the dashboard
+	PollCycleBudget  time.Duration            // Maximum time one poll cycle may spend fetching (0 = unlimited)
+	PollFairness     string                   // "round-robin" (carry skipped cases over) or "fixed"
+	PollWorkers      int                      // Cases fetched concurrently within a cycle
+	PollJitter       float64                  // Random deviation of each wait between cycles, as a fraction (0.2 = ±20%)
+	RequestDelayMin  time.Duration            // Random pause between two case fetches of a cycle, at least
+	RequestDelayMax  time.Duration            // and at most (0 = no pause)
+	BootstrapStagger time.Duration            // Minimum spacing between first fetches of cases without saved state
+	PollLock         string                   // Lease electing the one polling instance: "file", a path, gs://bucket/object or "postgres" ("" = none)
+	PollLockTTL      time.Duration            // How long the lease outlives its holder's last renewal
+
+	// Also track every case on the USCIS account (CASE_IDS=auto, or CASE_IDS unset with
+	// AUTO_LOGIN), checking it for new ones every CaseDiscoveryInterval
+	CaseDiscovery         bool
+	CaseDiscoveryInterval time.Duration
+
+	// Daily cap on USCIS requests across every case and source (0 = unlimited)
+	DailyRequestBudget int
+	BudgetAlert        bool // Also send an alert when the day's budget runs out
+
+	// Run-once mode (Cloud Scheduler, cron, CI)
+	RunOnce    bool   // Poll every case once and exit with a status code
+	ResultFile string // Where to write the JSON result in run-once mode ("-" for stdout)
+
+	// Combining simultaneous notifications
+	BootstrapSummaryMin  int      // First-run cases in one cycle that trigger a single summary email
+	ChangeDigestMin      int      // Changed cases for the same recipients in one cycle that trigger one digest (0 = off)
+	ChangeDigestChannels []string // Channels that get the digest; the others get one message per case (empty = all)
+
+	// Lowest severity of change a channel is sent (channels not listed get every change)
+	SeverityChannels map[string]uscis.Severity
+
+	// Synthetic canary case whose status changes every CanaryInterval (0 = off), tracked
+	// under CanaryCaseID to verify storage, detection and delivery end to end
+	CanaryInterval time.Duration
+	CanaryChannels []string // Channels that get the canary's notifications (empty = all)
+
+	StateFileDir      string
+	StorageBackend    string // "file" (JSON snapshots in StateFileDir), "sqlite", "postgres" or "s3"
+	SQLitePath        string // Database file when StorageBackend is "sqlite"
+	PostgresURL       string // postgres:// connection URL when StorageBackend is "postgres"
+	S3Bucket          string // Bucket when StorageBackend is "s3"
+	S3Prefix          string // Key prefix of the state objects, may be empty
+	S3Region          string
+	S3Endpoint        string // S3-compatible service such as MinIO; "" for AWS
+	S3PathStyle       bool   // Bucket in the URL path rather than the host name
+	S3AccessKeyID     string // Static keys; without them the AWS credential chain is used
+	S3SecretAccessKey string
+	ArchiveAfter      time.Duration // Move history older than this to ArchiveLocation (0 = keep everything)
+	ArchiveLocation   string        // "gs://bucket/prefix" or a directory
+	InstanceID        string        // Optional override; otherwise persisted in the state directory
+	Bundles           []Bundle
+	Tenants           []Tenant // Named owners of cases (TENANTS), e.g. an attorney's clients
+
+	// Snapshot fields redacted before they are written to storage
+	RedactFields  map[string]string // field name -> "strip" or "hash"
+	RedactHashKey string            // Optional HMAC key for hashed fields
+
+	// Fields whose changes never trigger a notification (volatile timestamps, ETags...)
+	ChangeIgnoreFields []string
+
+	// Quarantine fetched payloads that don't fit the case instead of diffing them (default: true)
+	AnomalyCheck bool
+
+	// Public status page (unauthenticated, coarse progress only)
+	PublicStatusCases  []PublicCase
+	PublicStatusFields []string
+
+	// Date rendering (timezone and locale) for recipients without an override
+	Locale           locale.Settings
+	RecipientLocales map[string]locale.Settings
+
+	// Embedded HTTP server endpoint groups (health is always enabled)
+	HTTPEndpoints map[string]bool
+	APIToken      string // Bearer token required for API calls that change state
+	Port          string // PORT the embedded HTTP server listens on (default 8080)
+
+	// Credentials set in the environment, CONFIG_FILE or Secret Manager, to mask
+	// wherever text leaves the process
+	SecretValues []string
+
+	// Telegram notifications (optional - sent alongside email)
+	TelegramBotToken string
+	TelegramChatID   string
+
+	// secret_token of the bot's webhook; enables the bot commands (/snooze) from TelegramChatID
+	TelegramWebhookSecret string
+
+	// Slack notifications (optional - sent alongside email)
+	SlackWebhookURL string
+
+	// Web push to devices that installed the dashboard (optional - sent alongside email)
+	WebPush        bool   // Let the dashboard subscribe devices to push notifications
+	WebPushContact string // mailto: or https: contact sent to push services (default: mailto:RECIPIENT_EMAIL)
+
+	// Generic JSON webhooks (optional - sent alongside email)
+	WebhookURLs  []string            // Receive every notification
+	CaseWebhooks map[string][]string // Per-case webhooks, receiving only notifications about their cases
+
+	// Outbound email branding
+	BrandName    string // Product name in subjects and footers (default: USCIS Case Tracker)
+	EmailFrom    string // Sender address (default: "<BrandName> <onboarding@resend.dev>", with SMTP the SMTP account)
+	EmailReplyTo string // Optional reply-to address
+	EmailFooter  string // Footer text (default: "This email was sent by <BrandName>")
+	ManageURL    string // Unsubscribe/manage link (default: PUBLIC_URL/cases with the dashboard)
+
+	// Directory of *.html files replacing the built-in email templates ("" = built-in only)
+	EmailTemplateDir string
+
+	// Action links in emails (snooze, ...) - enabled when both are set
+	PublicURL  string // Base URL the tracker's HTTP server is reachable at
+	LinkSecret string // HMAC key used to sign links
+
+	// Acknowledgement links in critical notifications (RFE, interview) - needs action links
+	AckEscalation []string      // Channels notified in turn while a critical notification is unacknowledged (empty = off)
+	AckWindow     time.Duration // How long to wait for the acknowledgement before each escalation
+
+	// Send a journey summary email when a case is approved (default: true)
+	CelebrationEmail bool
+
+	// Startup connectivity check
+	PreflightCheck string // "warn" (log and report), "strict" (exit when USCIS is unreachable) or "off"
+	PreflightAlert bool   // Send an alert when the check finds a problem
+
+	// /health answers 503 once no case was fetched successfully for this long while
+	// fetches fail (0 = never)
+	HealthFailAfter time.Duration
+
+	// Notification send limits: defaults for every channel and per-channel overrides
+	NotifyLimits        ChannelLimits
+	NotifyChannelLimits map[string]ChannelLimits
+
+	// Quiet hours per channel ("" = every channel without its own), in the Locale timezone
+	QuietHours map[string]QuietWindow
+
+	// Watchdog configuration
+	FetchTimeout        time.Duration // Maximum time a single case fetch may take
+	BrowserRecycleAfter int           // Consecutive fetch timeouts before the browser is restarted
+
+	// Circuit breaker: after this many failed fetches in a row, of one case or of any
+	// cases, fetching pauses for the cooldown (0 = never, the default)
+	BreakerFailures       int
+	BreakerGlobalFailures int
+	BreakerCooldown       time.Duration
+
+	// Log file configuration (optional - stdout logging is always on)
+	LogFile           string        // Path of the rotating log file (empty = disabled)
+	LogMaxSizeMB      int           // Rotate once the file exceeds this size
+	LogMaxBackups     int           // Rotated files to keep (0 = unlimited)
+	LogMaxAge         time.Duration // Delete rotated files older than this (0 = never)
+	LogRotateInterval time.Duration // Rotate after this much time (0 = size-based only)
+	LogBufferSize     int           // Recent log lines kept for /api/logs (0 = disabled)
+	UnsafeLogs        bool          // Write passwords, session cookies and 2FA codes unmasked (DEBUG_UNSAFE_LOGS)
 
 	// Auto-login configuration
 	AutoLogin     bool
 	USCISUsername string
 	USCISPassword string
 
+	// Additional USCIS accounts (USCIS_ACCOUNT_<N>_*), each logged in on its own and
+	// serving its cases as source.Account(name)
+	Accounts []Account
+
+	// Reuse saved browser session cookies across restarts (default: true)
+	PersistBrowserSession bool
+
+	// Also fetch the myUSCIS case history endpoints, for interim actions the status misses
+	FetchCaseHistory bool
+
+	// Download the notice PDFs new case entries link to: "" (off), "store" or "attach"
+	NoticePDFs string
+
+	// What to use instead of the browser when Chrome can't run here: auto, cookie, public or off
+	ChromeFallback string
+
+	// DevTools URL of an external Chrome to log in with instead of a local one (e.g. browserless)
+	ChromeRemoteURL string
+
+	// Screenshot and DOM of failed browser logins: "store", "attach" (also to the alert email) or "" (off)
+	LoginCaptures string
+
+	// Login sequencing (spacing between browser logins across sessions)
+	LoginSpacing time.Duration
+	LoginJitter  time.Duration
+
+	// Daily period during which automatic re-logins wait for approval through an emailed
+	// link instead of sending a 2FA code (nil = never held); needs action links
+	LoginHoldHours *QuietWindow
+
+	// Scheduled login before the session expires, so it isn't renewed in the middle of a
+	// poll (0 = only when USCIS rejects it); kept to the daily window when one is set
+	SessionRefreshInterval time.Duration
+	SessionRefreshWindow   *QuietWindow
+
+	// Authenticator app (TOTP) secret for automated 2FA; used instead of the email when set
+	USCISTOTPSecret string
+
+	// Twilio auth token: USCIS texts the 2FA code to a Twilio number, whose inbound SMS
+	// webhook (PUBLIC_URL/webhooks/twilio/sms) passes it on; checks the webhook's signature
+	TwilioAuthToken string
+	// Numbers or short codes USCIS texts the code from; texts from others are ignored
+	TwilioSMSFrom []string
+
 	// Email 2FA configuration (optional - for automated 2FA)
 	EmailIMAPServer string
 	EmailUsername   string
 	EmailPassword   string
+	Email2FASender  string // From address of the 2FA emails, or @domain for any sender there
+
+	// OAuth2 (XOAUTH2) mailbox login, for providers without app passwords; replaces EmailPassword
+	EmailOAuthClientID     string
+	EmailOAuthClientSecret string
+	EmailOAuthRefreshToken string
+	EmailOAuthTokenURL     string // Token endpoint (default: Gmail's or Microsoft's, by EmailIMAPServer)
 }
 
 // Load loads configuration from environment variables (multi-case aware)
+// If CONFIG_FILE is set, the file is read first and the environment overrides it
 func Load() (*Config, error) {
+	return LoadWith(nil)
+}
+
+// LoadPartial loads configuration like Load, but without requiring the settings only
+// the daemon needs: CASE_IDS, RESEND_API_KEY, RECIPIENT_EMAIL and the USCIS credentials
+// One-shot commands use it and check for what they need themselves
+func LoadPartial() (*Config, error) {
+	env, err := readEnvironment(nil)
+	if err != nil {
+		return nil, err
+	}
+	return load(env, true)
+}
+
+// LoadWith loads configuration like Load, with values taking precedence over the
+// environment and CONFIG_FILE, e.g. settings that aren't written anywhere yet
+func LoadWith(values map[string]string) (*Config, error) {
+	env, err := readEnvironment(values)
+	if err != nil {
+		return nil, err
+	}
+	return load(env, false)
+}
+
+// environment holds the settings configuration is built from, by variable name
+// It is read from the process environment and the config file once, and passed to
+// the parsers instead of changing the process environment
+type environment map[string]string
+
+// processEnvironment returns the variables of the process environment
+func processEnvironment() environment {
+	env := make(environment)
+	for _, kv := range os.Environ() {
+		if key, value, ok := strings.Cut(kv, "="); ok {
+			env[key] = value
+		}
+	}
+	return env
+}
+
+// readEnvironment returns the process environment with values added, and the settings
+// of CONFIG_FILE that neither sets
+func readEnvironment(values map[string]string) (environment, error) {
+	env := processEnvironment()
+	maps.Copy(env, values)
+	if path := env["CONFIG_FILE"]; path != "" {
+		if err := env.applyConfigFile(path); err != nil {
+			return nil, err
+		}
+	}
+	return env, nil
+}
+
+// load builds the configuration from the settings in env
+// A partial configuration skips the checks for required settings
+func load(env environment, partial bool) (*Config, error) {
+	if err := env.resolveSecretRefs(); err != nil {
+		return nil, err
+	}
+	// Secrets from the config file or Secret Manager aren't in the process environment,
+	// which SecretValues reads
+	logging.AddSecret(env.secretValues()...)
+
 	cfg := &Config{
-		USCISCookie:     os.Getenv("USCIS_COOKIE"),
-		ResendAPIKey:    os.Getenv("RESEND_API_KEY"),
-		RecipientEmail:  os.Getenv("RECIPIENT_EMAIL"),
-		USCISUsername:   os.Getenv("USCIS_USERNAME"),
-		USCISPassword:   os.Getenv("USCIS_PASSWORD"),
-		EmailIMAPServer: os.Getenv("EMAIL_IMAP_SERVER"),
-		EmailUsername:   os.Getenv("EMAIL_USERNAME"),
-		EmailPassword:   os.Getenv("EMAIL_PASSWORD"),
+		USCISCookie:      env["USCIS_COOKIE"],
+		ResendAPIKey:     env["RESEND_API_KEY"],
+		USCISUsername:    env["USCIS_USERNAME"],
+		USCISPassword:    env["USCIS_PASSWORD"],
+		EmailIMAPServer:  env["EMAIL_IMAP_SERVER"],
+		EmailUsername:    env["EMAIL_USERNAME"],
+		EmailPassword:    env["EMAIL_PASSWORD"],
+		InstanceID:       env["INSTANCE_ID"],
+		APIToken:         env["API_TOKEN"],
+		SecretValues:     env.secretValues(),
+		TelegramBotToken: env["TELEGRAM_BOT_TOKEN"],
+		TelegramChatID:   env["TELEGRAM_CHAT_ID"],
+		SlackWebhookURL:  env["SLACK_WEBHOOK_URL"],
+		PublicURL:        env["PUBLIC_URL"],
+		LinkSecret:       env["LINK_SECRET"],
 	}
 
 	// Parse AUTO_LOGIN flag
-	autoLoginStr := strings.ToLower(os.Getenv("AUTO_LOGIN"))
+	autoLoginStr := strings.ToLower(env["AUTO_LOGIN"])
 	cfg.AutoLogin = autoLoginStr == "true" || autoLoginStr == "1" || autoLoginStr == "yes"
 
-	// Parse CASE_IDS as comma-separated list
-	caseIDsStr := os.Getenv("CASE_IDS")
-	if caseIDsStr != "" {
-		ids := strings.Split(caseIDsStr, ",")
-		for i, id := range ids {
-			ids[i] = strings.TrimSpace(id)
+	// Parse run-once settings
+	cfg.RunOnce = RunOnceEnv()
+	cfg.ResultFile = env["RESULT_FILE"]
+
+	// Parse RECIPIENT_EMAIL as comma-separated list
+	recipients, err := parseEmailList("RECIPIENT_EMAIL", env["RECIPIENT_EMAIL"])
+	if err != nil {
+		return nil, err
+	}
+	cfg.RecipientEmails = recipients
+	if len(recipients) > 0 {
+		cfg.RecipientEmail = recipients[0]
+	}
+
+	// Parse CASE_IDS as comma-separated list; receipt numbers are compared in their
+	// normalized form (no whitespace, upper case) everywhere
+	caseIDsStr := env["CASE_IDS"]
+	if strings.EqualFold(strings.TrimSpace(caseIDsStr), CaseIDsAuto) {
+		cfg.CaseDiscovery = true
+	} else if caseIDsStr != "" {
+		for _, id := range strings.Split(caseIDsStr, ",") {
+			if id = uscis.NormalizeReceiptNumber(id); id != "" {
+				cfg.CaseIDs = append(cfg.CaseIDs, id)
+			}
+		}
+	}
+	// CASES lists cases with their nicknames in one setting, in addition to CASE_IDS
+	casesIDs, casesNicknames, err := parseCases(env["CASES"])
+	if err != nil {
+		return nil, err
+	}
+	for _, caseID := range casesIDs {
+		if !slices.Contains(cfg.CaseIDs, caseID) {
+			cfg.CaseIDs = append(cfg.CaseIDs, caseID)
 		}
-		cfg.CaseIDs = ids
 	}
 
-	// Validate authentication method (either manual cookie or auto-login)
-	if cfg.AutoLogin {
-		// Auto-login mode requires username and password
-		if cfg.USCISUsername == "" {
-			return nil, fmt.Errorf("USCIS_USERNAME environment variable is required when AUTO_LOGIN=true")
+	// Parse tenants; their cases are tracked whether or not CASE_IDS lists them
+	if cfg.Tenants, err = parseTenants(env["TENANTS"]); err != nil {
+		return nil, err
+	}
+	for _, tenant := range cfg.Tenants {
+		for _, caseID := range tenant.CaseIDs {
+			if !slices.Contains(cfg.CaseIDs, caseID) {
+				cfg.CaseIDs = append(cfg.CaseIDs, caseID)
+			}
+		}
+	}
+
+	// Without any case listed, auto-login tracks the cases it finds on the account
+	if caseIDsStr == "" && len(cfg.CaseIDs) == 0 && cfg.AutoLogin {
+		cfg.CaseDiscovery = true
+	}
+	if cfg.CaseDiscovery {
+		if !cfg.AutoLogin {
+			return nil, fmt.Errorf("CASE_IDS=auto requires AUTO_LOGIN=true: only the browser login can list the cases on the account")
 		}
-		if cfg.USCISPassword == "" {
-			return nil, fmt.Errorf("USCIS_PASSWORD environment variable is required when AUTO_LOGIN=true")
+		if cfg.CaseDiscoveryInterval, err = durationEnv(env, "CASE_DISCOVERY_INTERVAL", 6*time.Hour); err != nil {
+			return nil, err
 		}
-	} else {
-		// Manual cookie mode requires USCIS_COOKIE
-		if cfg.USCISCookie == "" {
-			return nil, fmt.Errorf("USCIS_COOKIE environment variable is required when AUTO_LOGIN is not enabled")
+		if cfg.CaseDiscoveryInterval < 15*time.Minute {
+			return nil, fmt.Errorf("invalid CASE_DISCOVERY_INTERVAL %v (minimum: 15m)", cfg.CaseDiscoveryInterval)
+		}
+	}
+
+	// Parse the additional USCIS accounts; their cases are tracked whether or not
+	// CASE_IDS lists them
+	if cfg.Accounts, err = parseAccounts(env, cfg.USCISUsername); err != nil {
+		return nil, err
+	}
+	if len(cfg.Accounts) > 0 && !cfg.AutoLogin {
+		return nil, fmt.Errorf("USCIS_ACCOUNT_%d_* requires AUTO_LOGIN=true: each account logs in with the browser", cfg.Accounts[0].Number)
+	}
+	for _, account := range cfg.Accounts {
+		for _, caseID := range account.CaseIDs {
+			if !slices.Contains(cfg.CaseIDs, caseID) {
+				cfg.CaseIDs = append(cfg.CaseIDs, caseID)
+			}
+		}
+	}
+
+	// Parse where each case is fetched from
+	cfg.DefaultSource = strings.ToLower(stringEnv(env, "DEFAULT_CASE_SOURCE", source.MyUSCIS))
+	if !source.IsKnown(cfg.DefaultSource) {
+		return nil, fmt.Errorf("DEFAULT_CASE_SOURCE must be one of %v, got %q", source.Known, cfg.DefaultSource)
+	}
+	caseSources, err := parseCaseSources(env["CASE_SOURCES"], cfg.CaseIDs)
+	if err != nil {
+		return nil, err
+	}
+	cfg.CaseSources = caseSources
+	for _, account := range cfg.Accounts {
+		for _, caseID := range account.CaseIDs {
+			if name, ok := cfg.CaseSources[caseID]; ok {
+				return nil, fmt.Errorf("CASE_SOURCES sets %s to %s, but it is fetched through USCIS account %q", caseID, name, account.Name)
+			}
+			if cfg.CaseSources == nil {
+				cfg.CaseSources = make(map[string]string)
+			}
+			cfg.CaseSources[caseID] = account.Source()
+		}
+	}
+
+	// Validate authentication method (either manual cookie or auto-login)
+	// Only the myUSCIS source needs an account; the additional accounts log in next to it
+	if (cfg.UsesSource(source.MyUSCIS) || len(cfg.Accounts) > 0) && !partial {
+		if cfg.AutoLogin {
+			// Auto-login mode requires username and password
+			if cfg.USCISUsername == "" {
+				return nil, fmt.Errorf("USCIS_USERNAME environment variable is required when AUTO_LOGIN=true")
+			}
+			if cfg.USCISPassword == "" {
+				return nil, fmt.Errorf("USCIS_PASSWORD environment variable is required when AUTO_LOGIN=true")
+			}
+		} else {
+			// Manual cookie mode requires USCIS_COOKIE
+			if cfg.USCISCookie == "" {
+				return nil, fmt.Errorf("USCIS_COOKIE environment variable is required when AUTO_LOGIN is not enabled")
+			}
+		}
+	}
+
+	// Pick the email provider
+	cfg.Notifier = strings.ToLower(stringEnv(env, "NOTIFIER", NotifierResend))
+	if cfg.Notifier != NotifierResend && cfg.Notifier != NotifierSMTP {
+		return nil, fmt.Errorf("NOTIFIER must be %s or %s, got %q", NotifierResend, NotifierSMTP, cfg.Notifier)
+	}
+	if cfg.Notifier == NotifierSMTP {
+		if err := cfg.loadSMTP(env, partial); err != nil {
+			return nil, err
 		}
 	}
 
 	// Validate other required fields
-	if len(cfg.CaseIDs) == 0 || (len(cfg.CaseIDs) == 1 && cfg.CaseIDs[0] == "") {
-		return nil, fmt.Errorf("CASE_IDS environment variable is required (comma-separated list)")
+	if !partial {
+		if len(cfg.CaseIDs) == 0 && !cfg.CaseDiscovery {
+			return nil, fmt.Errorf("CASE_IDS (or CASES) environment variable is required (comma-separated list, or auto with AUTO_LOGIN=true)")
+		}
+		if cfg.Notifier == NotifierResend && cfg.ResendAPIKey == "" {
+			return nil, fmt.Errorf("RESEND_API_KEY environment variable is required (or set NOTIFIER=smtp)")
+		}
+		if cfg.RecipientEmail == "" {
+			return nil, fmt.Errorf("RECIPIENT_EMAIL environment variable is required")
+		}
+	}
+
+	// The canary is tracked like a case, from a generator instead of USCIS
+	if cfg.CanaryInterval, err = durationEnv(env, "CANARY_INTERVAL", 0); err != nil {
+		return nil, err
+	}
+	if cfg.CanaryInterval > 0 {
+		if cfg.CanaryInterval < time.Minute {
+			return nil, fmt.Errorf("CANARY_INTERVAL must be at least 1m, got %v", cfg.CanaryInterval)
+		}
+		if slices.Contains(cfg.CaseIDs, CanaryCaseID) {
+			return nil, fmt.Errorf("CASE_IDS must not contain %s, the ID of the canary (CANARY_INTERVAL)", CanaryCaseID)
+		}
+		if cfg.CanaryChannels, err = parseChannelList(env["CANARY_CHANNELS"]); err != nil {
+			return nil, fmt.Errorf("invalid CANARY_CHANNELS: %w", err)
+		}
+		cfg.CaseIDs = append(cfg.CaseIDs, CanaryCaseID)
+		if cfg.CaseSources == nil {
+			cfg.CaseSources = make(map[string]string)
+		}
+		cfg.CaseSources[CanaryCaseID] = source.Canary
+	}
+	// A typo would otherwise fail every poll with a confusing USCIS error
+	for _, caseID := range cfg.CaseIDs {
+		if cfg.IsCanary(caseID) {
+			continue
+		}
+		if _, err := uscis.ValidateReceiptNumber(caseID); err != nil {
+			return nil, fmt.Errorf("CASE_IDS: %w", err)
+		}
+	}
+
+	caseRecipients, err := parseCaseRecipients(env["CASE_RECIPIENTS"], cfg.CaseIDs)
+	if err != nil {
+		return nil, err
+	}
+	cfg.CaseRecipients = caseRecipients
+
+	for _, hook := range strings.Split(env["WEBHOOK_URLS"], ",") {
+		if hook = strings.TrimSpace(hook); hook == "" {
+			continue
+		}
+		if err := validateWebhookURL(hook); err != nil {
+			return nil, fmt.Errorf("invalid WEBHOOK_URLS entry: %w", err)
+		}
+		cfg.WebhookURLs = append(cfg.WebhookURLs, hook)
+	}
+	caseWebhooks, err := parseCaseWebhooks(env["CASE_WEBHOOKS"], cfg.CaseIDs)
+	if err != nil {
+		return nil, err
+	}
+	cfg.CaseWebhooks = caseWebhooks
+
+	// Parse optional public status page settings
+	publicCases, err := parsePublicCases(env["PUBLIC_STATUS_CASES"], cfg.CaseIDs)
+	if err != nil {
+		return nil, err
+	}
+	cfg.PublicStatusCases = publicCases
+	cfg.PublicStatusFields, err = parsePublicFields(env["PUBLIC_STATUS_FIELDS"])
+	if err != nil {
+		return nil, err
+	}
+
+	// Detect timezone/locale and parse per-recipient overrides
+	timezone, tag := locale.Configured(env["TIMEZONE"], env["LOCALE"])
+	if cfg.Locale, err = locale.Detect(timezone, tag); err != nil {
+		return nil, fmt.Errorf("invalid TIMEZONE: %w", err)
+	}
+	if cfg.RecipientLocales, err = parseRecipientLocales(env["RECIPIENT_LOCALES"], cfg.Locale); err != nil {
+		return nil, err
+	}
+	// What nothing set follows the first recipient's own settings, who is most likely
+	// the person running the tracker
+	if first, ok := cfg.RecipientLocales[strings.ToLower(cfg.RecipientEmail)]; ok && (timezone == "" || tag == "") {
+		if timezone == "" {
+			timezone = first.Location.String()
+		}
+		if tag == "" {
+			tag = first.Locale
+		}
+		if cfg.Locale, err = locale.New(timezone, tag); err != nil {
+			return nil, fmt.Errorf("invalid TIMEZONE: %w", err)
+		}
+		// Entries leaving a part out inherit the new defaults
+		if cfg.RecipientLocales, err = parseRecipientLocales(env["RECIPIENT_LOCALES"], cfg.Locale); err != nil {
+			return nil, err
+		}
+	}
+
+	// Apply branding defaults
+	cfg.BrandName = stringEnv(env, "BRAND_NAME", "USCIS Case Tracker")
+	cfg.EmailFrom = stringEnv(env, "EMAIL_FROM", cfg.BrandName+" <"+cfg.defaultSender()+">")
+	cfg.EmailReplyTo = env["EMAIL_REPLY_TO"]
+	if cfg.EmailCC, err = parseEmailList("EMAIL_CC", env["EMAIL_CC"]); err != nil {
+		return nil, err
+	}
+	if cfg.EmailBCC, err = parseEmailList("EMAIL_BCC", env["EMAIL_BCC"]); err != nil {
+		return nil, err
+	}
+	cfg.EmailFooter = stringEnv(env, "EMAIL_FOOTER", "This email was sent by "+cfg.BrandName)
+	cfg.EmailTemplateDir = env["EMAIL_TEMPLATE_DIR"]
+
+	// Parse enabled HTTP endpoint groups
+	cfg.Port = stringEnv(env, "PORT", "8080")
+	if cfg.HTTPEndpoints, err = parseHTTPEndpoints(env["HTTP_ENDPOINTS"]); err != nil {
+		return nil, err
+	}
+	if cfg.ManageURL, err = parseManageURL(env["MANAGE_URL"], cfg.PublicURL, cfg.HTTPEndpoints[EndpointsDashboard]); err != nil {
+		return nil, err
+	}
+
+	// Web push is off unless enabled; subscribing happens on the dashboard
+	webPushStr := strings.ToLower(env["WEB_PUSH"])
+	cfg.WebPush = webPushStr == "true" || webPushStr == "1" || webPushStr == "yes"
+	cfg.WebPushContact = stringEnv(env, "WEB_PUSH_CONTACT", "mailto:"+cfg.RecipientEmail)
+	if cfg.WebPush && !cfg.HTTPEndpoints[EndpointsDashboard] {
+		return nil, fmt.Errorf("WEB_PUSH needs the dashboard: add dashboard to HTTP_ENDPOINTS")
 	}
-	if cfg.ResendAPIKey == "" {
-		return nil, fmt.Errorf("RESEND_API_KEY environment variable is required")
+	if !strings.HasPrefix(cfg.WebPushContact, "mailto:") && !strings.HasPrefix(cfg.WebPushContact, "https://") {
+		return nil, fmt.Errorf("WEB_PUSH_CONTACT must be a mailto: or https:// URL")
 	}
-	if cfg.RecipientEmail == "" {
-		return nil, fmt.Errorf("RECIPIENT_EMAIL environment variable is required")
+
+	// Celebration email is on unless explicitly disabled
+	celebrationStr := strings.ToLower(env["CELEBRATION_EMAIL"])
+	cfg.CelebrationEmail = !(celebrationStr == "false" || celebrationStr == "0" || celebrationStr == "no")
+
+	// Parse optional application bundles
+	bundles, err := parseBundles(env["CASE_BUNDLES"], cfg.CaseIDs)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Bundles = bundles
+	if err := cfg.loadTenantChannels(env); err != nil {
+		return nil, err
 	}
 
 	// Set default for state file directory
-	stateFileDir := os.Getenv("STATE_FILE_DIR")
+	stateFileDir := env["STATE_FILE_DIR"]
 	if stateFileDir == "" {
 		stateFileDir = "/tmp/case-tracker-states/"
 	}
 	cfg.StateFileDir = stateFileDir
 
+	// Parse storage backend
+	cfg.StorageBackend = strings.ToLower(stringEnv(env, "STORAGE_BACKEND", "file"))
+	switch cfg.StorageBackend {
+	case "file", "sqlite":
+	case "postgres":
+		cfg.PostgresURL = env["POSTGRES_URL"]
+		if cfg.PostgresURL == "" && !partial {
+			return nil, fmt.Errorf("POSTGRES_URL environment variable is required when STORAGE_BACKEND=postgres")
+		}
+	case "s3":
+		if err := cfg.loadS3(env, partial); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid STORAGE_BACKEND %q (allowed: file, sqlite, postgres, s3)", cfg.StorageBackend)
+	}
+	cfg.SQLitePath = stringEnv(env, "SQLITE_PATH", filepath.Join(cfg.StateFileDir, "tracker.db"))
+
+	// Parse long-term archival
+	if cfg.ArchiveAfter, err = durationEnv(env, "ARCHIVE_AFTER", 0); err != nil {
+		return nil, err
+	}
+	cfg.ArchiveLocation = env["ARCHIVE_LOCATION"]
+	if cfg.ArchiveAfter > 0 {
+		if cfg.ArchiveAfter < 24*time.Hour {
+			return nil, fmt.Errorf("ARCHIVE_AFTER must be at least 24h")
+		}
+		if cfg.ArchiveLocation == "" {
+			return nil, fmt.Errorf("ARCHIVE_AFTER requires ARCHIVE_LOCATION (gs://bucket/prefix or a directory)")
+		}
+	}
+
+	// Parse snapshot redaction rules
+	if cfg.RedactFields, err = parseRedactFields(env["REDACT_FIELDS"]); err != nil {
+		return nil, err
+	}
+	cfg.RedactHashKey = env["REDACT_HASH_KEY"]
+
+	if cfg.ChangeIgnoreFields, err = parseChangeIgnoreFields(env["CHANGE_IGNORE_FIELDS"]); err != nil {
+		return nil, err
+	}
+	anomalyStr := strings.ToLower(env["ANOMALY_CHECK"])
+	cfg.AnomalyCheck = !(anomalyStr == "false" || anomalyStr == "0" || anomalyStr == "no")
+
 	// Parse poll interval with default
-	pollIntervalStr := os.Getenv("POLL_INTERVAL")
+	pollIntervalStr := env["POLL_INTERVAL"]
 	if pollIntervalStr == "" {
 		cfg.PollInterval = 15 * time.Minute
 	} else {
@@ -99,27 +706,1630 @@ func Load() (*Config, error) {
 		}
 		cfg.PollInterval = interval
 	}
+	if cfg.PollInterval <= 0 {
+		return nil, fmt.Errorf("POLL_INTERVAL must be positive, got %v", cfg.PollInterval)
+	}
+	if cfg.CaseSchedule, err = parseCaseSchedule(env["CASE_SCHEDULE"], cfg.CaseIDs); err != nil {
+		return nil, err
+	}
+	if cfg.CaseNicknames, err = parseCaseValues(env, "CASE_NICKNAMES", "nickname", cfg.CaseIDs); err != nil {
+		return nil, err
+	}
+	for caseID, nickname := range casesNicknames {
+		if _, ok := cfg.CaseNicknames[caseID]; !ok {
+			cfg.CaseNicknames[caseID] = nickname
+		}
+	}
+	if cfg.CaseFormTypes, err = parseCaseValues(env, "CASE_FORM_TYPES", "form", cfg.CaseIDs); err != nil {
+		return nil, err
+	}
+	if cfg.CaseApplicants, err = parseCaseValues(env, "CASE_APPLICANTS", "name", cfg.CaseIDs); err != nil {
+		return nil, err
+	}
 
-	// Validate email settings if any are provided (all-or-nothing)
-	emailFieldsSet := []bool{
-		cfg.EmailIMAPServer != "",
-		cfg.EmailUsername != "",
-		cfg.EmailPassword != "",
+	// Parse poll cycle budget (defaults to the poll tick so cycles never overlap ticks)
+	if cfg.PollCycleBudget, err = durationEnv(env, "POLL_CYCLE_BUDGET", cfg.PollTick()); err != nil {
+		return nil, err
 	}
-	someEmailFieldsSet := false
-	allEmailFieldsSet := true
-	for _, set := range emailFieldsSet {
-		if set {
-			someEmailFieldsSet = true
-		} else {
-			allEmailFieldsSet = false
+	cfg.PollFairness = strings.ToLower(strings.TrimSpace(env["POLL_FAIRNESS"]))
+	switch cfg.PollFairness {
+	case "":
+		cfg.PollFairness = "round-robin"
+	case "round-robin", "fixed":
+	default:
+		return nil, fmt.Errorf("invalid POLL_FAIRNESS %q (allowed: round-robin, fixed)", cfg.PollFairness)
+	}
+	if cfg.PollWorkers, err = intEnv(env, "POLL_WORKERS", 1); err != nil {
+		return nil, err
+	}
+	if cfg.PollWorkers < 1 || cfg.PollWorkers > maxPollWorkers {
+		return nil, fmt.Errorf("invalid POLL_WORKERS %d (allowed: 1-%d)", cfg.PollWorkers, maxPollWorkers)
+	}
+	if cfg.PollJitter, err = parseJitter(env["POLL_JITTER"]); err != nil {
+		return nil, err
+	}
+	if cfg.RequestDelayMin, cfg.RequestDelayMax, err = parseDelayRange(env["REQUEST_DELAY"]); err != nil {
+		return nil, err
+	}
+
+	// Parse the poll lock that keeps replicas from polling (and emailing) twice
+	cfg.PollLock = strings.TrimSpace(env["POLL_LOCK"])
+	if cfg.PollLock == "postgres" && cfg.StorageBackend != "postgres" {
+		return nil, fmt.Errorf("POLL_LOCK=postgres needs STORAGE_BACKEND=postgres")
+	}
+	if cfg.PollLockTTL, err = durationEnv(env, "POLL_LOCK_TTL", time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.PollLockTTL < 15*time.Second {
+		return nil, fmt.Errorf("invalid POLL_LOCK_TTL %v (minimum: 15s)", cfg.PollLockTTL)
+	}
+
+	// Parse daily request budget
+	if cfg.DailyRequestBudget, err = intEnv(env, "DAILY_REQUEST_BUDGET", 0); err != nil {
+		return nil, err
+	}
+	if cfg.DailyRequestBudget < 0 {
+		return nil, fmt.Errorf("invalid DAILY_REQUEST_BUDGET %d (must be 0 for unlimited, or positive)", cfg.DailyRequestBudget)
+	}
+	budgetAlertStr := strings.ToLower(env["BUDGET_ALERT"])
+	cfg.BudgetAlert = !(budgetAlertStr == "false" || budgetAlertStr == "0" || budgetAlertStr == "no")
+
+	// Parse startup connectivity check settings
+	cfg.PreflightCheck = strings.ToLower(strings.TrimSpace(env["PREFLIGHT_CHECK"]))
+	switch cfg.PreflightCheck {
+	case "":
+		cfg.PreflightCheck = "warn"
+	case "warn", "strict", "off":
+	default:
+		return nil, fmt.Errorf("invalid PREFLIGHT_CHECK %q (allowed: warn, strict, off)", cfg.PreflightCheck)
+	}
+	preflightAlert := strings.ToLower(env["PREFLIGHT_ALERT"])
+	cfg.PreflightAlert = preflightAlert == "true" || preflightAlert == "1" || preflightAlert == "yes"
+	if cfg.HealthFailAfter, err = durationEnv(env, "HEALTH_FAIL_AFTER", 6*time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.HealthFailAfter < 0 {
+		return nil, fmt.Errorf("invalid HEALTH_FAIL_AFTER %v (must be 0 to never fail, or positive)", cfg.HealthFailAfter)
+	}
+
+	// Parse bootstrap settings
+	if cfg.BootstrapStagger, err = durationEnv(env, "BOOTSTRAP_STAGGER", 5*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.BootstrapSummaryMin, err = intEnv(env, "BOOTSTRAP_SUMMARY_MIN", 4); err != nil {
+		return nil, err
+	}
+	if cfg.BootstrapSummaryMin < 2 {
+		return nil, fmt.Errorf("BOOTSTRAP_SUMMARY_MIN must be at least 2")
+	}
+
+	// Parse change digest settings
+	if cfg.ChangeDigestMin, err = intEnv(env, "CHANGE_DIGEST_MIN", 0); err != nil {
+		return nil, err
+	}
+	if cfg.ChangeDigestMin == 1 {
+		return nil, fmt.Errorf("CHANGE_DIGEST_MIN must be 0 (off) or at least 2")
+	}
+	if cfg.ChangeDigestChannels, err = parseChannelList(env["CHANGE_DIGEST_CHANNELS"]); err != nil {
+		return nil, fmt.Errorf("invalid CHANGE_DIGEST_CHANNELS: %w", err)
+	}
+
+	if cfg.SeverityChannels, err = parseSeverityChannels(env["SEVERITY_CHANNELS"]); err != nil {
+		return nil, err
+	}
+
+	// Parse acknowledgement settings
+	if value := strings.TrimSpace(env["ACK_ESCALATION"]); strings.EqualFold(value, "all") {
+		return nil, fmt.Errorf("ACK_ESCALATION lists channels in escalation order; \"all\" is not allowed")
+	}
+	if cfg.AckEscalation, err = parseChannelList(env["ACK_ESCALATION"]); err != nil {
+		return nil, fmt.Errorf("invalid ACK_ESCALATION: %w", err)
+	}
+	if cfg.AckWindow, err = durationEnv(env, "ACK_WINDOW", 12*time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.AckWindow <= 0 {
+		return nil, fmt.Errorf("ACK_WINDOW must be positive")
+	}
+	if len(cfg.AckEscalation) > 0 && (cfg.PublicURL == "" || cfg.LinkSecret == "") {
+		return nil, fmt.Errorf("ACK_ESCALATION needs PUBLIC_URL and LINK_SECRET for the acknowledgement links")
+	}
+
+	// Parse notification send limits
+	if cfg.NotifyLimits.Concurrency, err = intEnv(env, "NOTIFY_CONCURRENCY", 2); err != nil {
+		return nil, err
+	}
+	if cfg.NotifyLimits.Timeout, err = durationEnv(env, "NOTIFY_TIMEOUT", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.NotifyLimits.QueueDepth, err = intEnv(env, "NOTIFY_QUEUE_DEPTH", 100); err != nil {
+		return nil, err
+	}
+	if cfg.NotifyLimits.PerHour, err = intEnv(env, "NOTIFY_MAX_PER_HOUR", 0); err != nil {
+		return nil, err
+	}
+	if cfg.ResendDailyQuota, err = intEnv(env, "RESEND_DAILY_QUOTA", 0); err != nil {
+		return nil, err
+	}
+	if err := cfg.NotifyLimits.validate(); err != nil {
+		return nil, fmt.Errorf("invalid NOTIFY_CONCURRENCY, NOTIFY_TIMEOUT, NOTIFY_QUEUE_DEPTH or NOTIFY_MAX_PER_HOUR: %w", err)
+	}
+	if cfg.NotifyChannelLimits, err = parseChannelLimits(env["NOTIFY_CHANNEL_LIMITS"], cfg.NotifyLimits); err != nil {
+		return nil, err
+	}
+	if cfg.QuietHours, err = parseQuietHours(env["QUIET_HOURS"]); err != nil {
+		return nil, err
+	}
+
+	// Parse watchdog settings
+	fetchTimeout, err := durationEnv(env, "FETCH_TIMEOUT", 2*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	if fetchTimeout <= 0 {
+		return nil, fmt.Errorf("FETCH_TIMEOUT must be positive")
+	}
+	cfg.FetchTimeout = fetchTimeout
+
+	recycleAfter, err := intEnv(env, "BROWSER_RECYCLE_AFTER", 3)
+	if err != nil {
+		return nil, err
+	}
+	if recycleAfter < 1 {
+		return nil, fmt.Errorf("BROWSER_RECYCLE_AFTER must be at least 1")
+	}
+	cfg.BrowserRecycleAfter = recycleAfter
+
+	// Parse circuit breaker settings
+	if cfg.BreakerFailures, err = intEnv(env, "CIRCUIT_BREAKER_FAILURES", 0); err != nil {
+		return nil, err
+	}
+	if cfg.BreakerGlobalFailures, err = intEnv(env, "CIRCUIT_BREAKER_GLOBAL_FAILURES", 0); err != nil {
+		return nil, err
+	}
+	if cfg.BreakerCooldown, err = durationEnv(env, "CIRCUIT_BREAKER_COOLDOWN", time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.BreakerCooldown <= 0 {
+		return nil, fmt.Errorf("CIRCUIT_BREAKER_COOLDOWN must be positive")
+	}
+
+	// Browser session reuse is on unless explicitly disabled
+	persistStr := strings.ToLower(env["PERSIST_BROWSER_SESSION"])
+	cfg.PersistBrowserSession = !(persistStr == "false" || persistStr == "0" || persistStr == "no")
+
+	historyStr := strings.ToLower(env["FETCH_CASE_HISTORY"])
+	cfg.FetchCaseHistory = historyStr == "true" || historyStr == "1" || historyStr == "yes"
+
+	cfg.NoticePDFs = strings.ToLower(strings.TrimSpace(env["NOTICE_PDFS"]))
+	switch cfg.NoticePDFs {
+	case "", "store", "attach":
+	case "off", "false", "no":
+		cfg.NoticePDFs = ""
+	default:
+		return nil, fmt.Errorf("invalid NOTICE_PDFS %q (allowed: store, attach, off)", cfg.NoticePDFs)
+	}
+
+	// Without Chrome, auto-login falls back to the cookie if one is set, else the public status service
+	cfg.ChromeFallback = strings.ToLower(env["CHROME_FALLBACK"])
+	if cfg.ChromeFallback == "" {
+		cfg.ChromeFallback = "auto"
+	}
+	switch cfg.ChromeFallback {
+	case "auto", "public", "off":
+	case "cookie":
+		if cfg.AutoLogin && cfg.USCISCookie == "" {
+			return nil, fmt.Errorf("CHROME_FALLBACK=cookie requires USCIS_COOKIE")
 		}
+	default:
+		return nil, fmt.Errorf("CHROME_FALLBACK must be auto, cookie, public or off, got %q", cfg.ChromeFallback)
+	}
+	cfg.ChromeRemoteURL = strings.TrimSpace(env["CHROME_REMOTE_URL"])
+	if cfg.ChromeRemoteURL != "" {
+		u, err := url.Parse(cfg.ChromeRemoteURL)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid CHROME_REMOTE_URL: expected ws://, wss://, http:// or https:// with a host")
+		}
+		switch u.Scheme {
+		case "ws", "wss", "http", "https":
+		default:
+			return nil, fmt.Errorf("invalid CHROME_REMOTE_URL scheme %q (allowed: ws, wss, http, https)", u.Scheme)
+		}
+		// Hosted browsers take an API token in the query (or the path); errors of the
+		// connection quote the URL
+		logging.AddSecret(cfg.ChromeRemoteURL)
+		for _, values := range u.Query() {
+			logging.AddSecret(values...)
+		}
+	}
+	cfg.LoginCaptures = strings.ToLower(strings.TrimSpace(env["LOGIN_CAPTURES"]))
+	switch cfg.LoginCaptures {
+	case "":
+		cfg.LoginCaptures = "store"
+	case "store", "attach":
+	case "off", "false", "no":
+		cfg.LoginCaptures = ""
+	default:
+		return nil, fmt.Errorf("invalid LOGIN_CAPTURES %q (allowed: store, attach, off)", cfg.LoginCaptures)
 	}
 
-	// If any email field is set, all must be set
-	if someEmailFieldsSet && !allEmailFieldsSet {
-		return nil, fmt.Errorf("if any email settings are provided, all of EMAIL_IMAP_SERVER, EMAIL_USERNAME, and EMAIL_PASSWORD must be set")
+	// Parse login sequencing settings
+	if cfg.LoginSpacing, err = durationEnv(env, "LOGIN_SPACING", 2*time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.LoginJitter, err = durationEnv(env, "LOGIN_JITTER", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if value := strings.TrimSpace(env["LOGIN_HOLD_HOURS"]); value != "" {
+		window, err := parseDailyWindow(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LOGIN_HOLD_HOURS: %w", err)
+		}
+		if cfg.PublicURL == "" || cfg.LinkSecret == "" {
+			return nil, fmt.Errorf("LOGIN_HOLD_HOURS needs PUBLIC_URL and LINK_SECRET for the approval link")
+		}
+		cfg.LoginHoldHours = &window
+	}
+	if cfg.SessionRefreshInterval, err = durationEnv(env, "SESSION_REFRESH_INTERVAL", 0); err != nil {
+		return nil, err
+	}
+	if cfg.SessionRefreshInterval != 0 && cfg.SessionRefreshInterval < 15*time.Minute {
+		return nil, fmt.Errorf("invalid SESSION_REFRESH_INTERVAL %v (minimum: 15m, or 0 to disable)", cfg.SessionRefreshInterval)
+	}
+	if cfg.SessionRefreshInterval != 0 && !cfg.AutoLogin {
+		return nil, fmt.Errorf("SESSION_REFRESH_INTERVAL needs AUTO_LOGIN=true: a manual cookie can't be renewed on a schedule")
+	}
+	if value := strings.TrimSpace(env["SESSION_REFRESH_WINDOW"]); value != "" {
+		if cfg.SessionRefreshInterval == 0 {
+			return nil, fmt.Errorf("SESSION_REFRESH_WINDOW needs SESSION_REFRESH_INTERVAL")
+		}
+		window, err := parseDailyWindow(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SESSION_REFRESH_WINDOW: %w", err)
+		}
+		cfg.SessionRefreshWindow = &window
 	}
 
-	return cfg, nil
+	// Parse optional rotating log file settings
+	cfg.LogFile = env["LOG_FILE"]
+	if cfg.LogMaxSizeMB, err = intEnv(env, "LOG_MAX_SIZE_MB", 10); err != nil {
+		return nil, err
+	}
+	if cfg.LogMaxBackups, err = intEnv(env, "LOG_MAX_BACKUPS", 5); err != nil {
+		return nil, err
+	}
+	if cfg.LogMaxAge, err = durationEnv(env, "LOG_MAX_AGE", 0); err != nil {
+		return nil, err
+	}
+	if cfg.LogRotateInterval, err = durationEnv(env, "LOG_ROTATE_INTERVAL", 0); err != nil {
+		return nil, err
+	}
+	if cfg.LogBufferSize, err = intEnv(env, "LOG_BUFFER_SIZE", 500); err != nil {
+		return nil, err
+	}
+	cfg.UnsafeLogs = unsafeLogs(env)
+
+	// The secret shown when adding an authenticator app to the USCIS account
+	cfg.USCISTOTPSecret = env["USCIS_TOTP_SECRET"]
+	if cfg.USCISTOTPSecret != "" {
+		if _, err := uscis.DecodeTOTPSecret(cfg.USCISTOTPSecret); err != nil {
+			return nil, fmt.Errorf("invalid USCIS_TOTP_SECRET: %w", err)
+		}
+	}
+
+	cfg.TwilioAuthToken = env["TWILIO_AUTH_TOKEN"]
+	if cfg.TwilioAuthToken != "" && cfg.PublicURL == "" {
+		return nil, fmt.Errorf("TWILIO_AUTH_TOKEN needs PUBLIC_URL: Twilio signs the webhook URL it calls")
+	}
+	if cfg.TwilioSMSFrom, err = parsePhoneList("TWILIO_SMS_FROM", env["TWILIO_SMS_FROM"]); err != nil {
+		return nil, err
+	}
+	if cfg.TwilioAuthToken != "" && len(cfg.TwilioSMSFrom) == 0 {
+		return nil, fmt.Errorf("TWILIO_AUTH_TOKEN needs TWILIO_SMS_FROM: the number USCIS texts the code from")
+	}
+
+	// Validate email settings if any are provided (all-or-nothing)
+	// The mailbox password can be replaced by an OAuth2 client and refresh token
+	cfg.EmailOAuthClientID = env["EMAIL_OAUTH_CLIENT_ID"]
+	cfg.EmailOAuthClientSecret = env["EMAIL_OAUTH_CLIENT_SECRET"]
+	cfg.EmailOAuthRefreshToken = env["EMAIL_OAUTH_REFRESH_TOKEN"]
+	cfg.EmailOAuthTokenURL = env["EMAIL_OAUTH_TOKEN_URL"]
+	cfg.Email2FASender = strings.TrimSpace(env["EMAIL_2FA_SENDER"])
+	if cfg.Email2FASender == "" {
+		cfg.Email2FASender = email.DefaultCodeSender
+	}
+	if !strings.Contains(cfg.Email2FASender, "@") {
+		return nil, fmt.Errorf("invalid EMAIL_2FA_SENDER %q: use an email address or @domain", cfg.Email2FASender)
+	}
+	emailFieldsSet := []bool{
+		cfg.EmailIMAPServer != "",
+		cfg.EmailUsername != "",
+		cfg.EmailPassword != "" || cfg.EmailOAuthClientID != "",
+	}
+	someEmailFieldsSet := false
+	allEmailFieldsSet := true
+	for _, set := range emailFieldsSet {
+		if set {
+			someEmailFieldsSet = true
+		} else {
+			allEmailFieldsSet = false
+		}
+	}
+
+	// If any email field is set, all must be set
+	if someEmailFieldsSet && !allEmailFieldsSet {
+		return nil, fmt.Errorf("if any email settings are provided, all of EMAIL_IMAP_SERVER, EMAIL_USERNAME, and EMAIL_PASSWORD (or EMAIL_OAUTH_CLIENT_ID) must be set")
+	}
+	if cfg.EmailOAuthClientID != "" {
+		if cfg.EmailOAuthRefreshToken == "" && !partial {
+			return nil, fmt.Errorf("EMAIL_OAUTH_CLIENT_ID needs EMAIL_OAUTH_REFRESH_TOKEN (get one with: tracker email-auth)")
+		}
+		if cfg.EmailOAuthTokenURL == "" {
+			provider, ok := email.ProviderFor(cfg.EmailIMAPServer)
+			if !ok {
+				return nil, fmt.Errorf("set EMAIL_OAUTH_TOKEN_URL: no known OAuth2 token endpoint for %s", cfg.EmailIMAPServer)
+			}
+			cfg.EmailOAuthTokenURL = provider.TokenURL
+		}
+	}
+
+	// Telegram needs both the bot token and the chat to post to
+	if (cfg.TelegramBotToken == "") != (cfg.TelegramChatID == "") {
+		return nil, fmt.Errorf("TELEGRAM_BOT_TOKEN and TELEGRAM_CHAT_ID must be set together")
+	}
+	cfg.TelegramWebhookSecret = env["TELEGRAM_WEBHOOK_SECRET"]
+	if cfg.TelegramWebhookSecret != "" {
+		if cfg.TelegramBotToken == "" {
+			return nil, fmt.Errorf("TELEGRAM_WEBHOOK_SECRET needs TELEGRAM_BOT_TOKEN and TELEGRAM_CHAT_ID")
+		}
+		if !telegramSecretPattern.MatchString(cfg.TelegramWebhookSecret) {
+			return nil, fmt.Errorf("invalid TELEGRAM_WEBHOOK_SECRET: use 1-256 letters, digits, - and _, as Telegram requires")
+		}
+	}
+
+	if cfg.SlackWebhookURL != "" && !strings.HasPrefix(cfg.SlackWebhookURL, "https://") {
+		return nil, fmt.Errorf("SLACK_WEBHOOK_URL must be an https:// incoming webhook URL")
+	}
+
+	return cfg, nil
+}
+
+// BundleFor returns the bundle a case belongs to, or nil if it is tracked on its own
+func (c *Config) BundleFor(caseID string) *Bundle {
+	for i := range c.Bundles {
+		for _, id := range c.Bundles[i].CaseIDs {
+			if id == caseID {
+				return &c.Bundles[i]
+			}
+		}
+	}
+	return nil
+}
+
+// CaseLabel names a case for subjects and logs: "Mom's I-485 (IOE0123456789)" with a
+// nickname, otherwise the case ID
+func (c *Config) CaseLabel(caseID string) string {
+	if nickname := c.CaseNicknames[caseID]; nickname != "" {
+		return nickname + " (" + caseID + ")"
+	}
+	return caseID
+}
+
+// SourceFor returns the source a case is fetched from
+func (c *Config) SourceFor(caseID string) string {
+	if name, ok := c.CaseSources[caseID]; ok {
+		return name
+	}
+	return c.DefaultSource
+}
+
+// UsesSource reports whether any tracked case is fetched from the given source
+func (c *Config) UsesSource(name string) bool {
+	for _, caseID := range c.CaseIDs {
+		if c.SourceFor(caseID) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCaseSources parses CASE_SOURCES: "ID1:public;ID2:myuscis"
+// Every case must also appear in CASE_IDS
+func parseCaseSources(value string, caseIDs []string) (map[string]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	tracked := make(map[string]bool, len(caseIDs))
+	for _, id := range caseIDs {
+		tracked[id] = true
+	}
+
+	sources := make(map[string]string)
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		caseID, name, ok := strings.Cut(entry, ":")
+		caseID = uscis.NormalizeReceiptNumber(caseID)
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !ok || caseID == "" {
+			return nil, fmt.Errorf("invalid CASE_SOURCES entry %q: expected ID:source", entry)
+		}
+		if !tracked[caseID] {
+			return nil, fmt.Errorf("CASE_SOURCES references %s which is not in CASE_IDS", caseID)
+		}
+		if !source.IsKnown(name) {
+			return nil, fmt.Errorf("CASE_SOURCES: unknown source %q for %s (known: %v)", name, caseID, source.Known)
+		}
+		sources[caseID] = name
+	}
+	return sources, nil
+}
+
+// parseCaseSchedule parses CASE_SCHEDULE: "ID1=5m,ID2=1h"
+// Every case must also appear in CASE_IDS
+func parseCaseSchedule(value string, caseIDs []string) (map[string]time.Duration, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	tracked := make(map[string]bool, len(caseIDs))
+	for _, id := range caseIDs {
+		tracked[id] = true
+	}
+
+	schedule := make(map[string]time.Duration)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		caseID, interval, ok := strings.Cut(entry, "=")
+		caseID = uscis.NormalizeReceiptNumber(caseID)
+		if !ok || caseID == "" {
+			return nil, fmt.Errorf("invalid CASE_SCHEDULE entry %q: expected ID=interval", entry)
+		}
+		if !tracked[caseID] {
+			return nil, fmt.Errorf("CASE_SCHEDULE references %s which is not in CASE_IDS", caseID)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(interval))
+		if err != nil {
+			return nil, fmt.Errorf("CASE_SCHEDULE: invalid interval for %s: %w", caseID, err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("CASE_SCHEDULE: interval for %s must be positive, got %v", caseID, d)
+		}
+		schedule[caseID] = d
+	}
+	return schedule, nil
+}
+
+// parseCases parses CASES: "ID1=Mom's I-485,ID2=My I-765,ID3", case IDs with optional
+// nicknames. Returns the IDs in order and the nicknames by case
+func parseCases(value string) ([]string, map[string]string, error) {
+	var caseIDs []string
+	nicknames := make(map[string]string)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		caseID, nickname, _ := strings.Cut(entry, "=")
+		caseID, nickname = uscis.NormalizeReceiptNumber(caseID), strings.TrimSpace(nickname)
+		if caseID == "" {
+			return nil, nil, fmt.Errorf("invalid CASES entry %q: expected ID or ID=nickname", entry)
+		}
+		if slices.Contains(caseIDs, caseID) {
+			return nil, nil, fmt.Errorf("CASES lists %s more than once", caseID)
+		}
+		caseIDs = append(caseIDs, caseID)
+		if nickname != "" {
+			nicknames[caseID] = nickname
+		}
+	}
+	return caseIDs, nicknames, nil
+}
+
+// parseCaseValues parses a per-case text setting like CASE_NICKNAMES:
+// "ID1=Mom's green card,ID2=Work permit"; what names the value in errors
+func parseCaseValues(env environment, key, what string, caseIDs []string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, entry := range strings.Split(env[key], ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		caseID, value, ok := strings.Cut(entry, "=")
+		caseID, value = uscis.NormalizeReceiptNumber(caseID), strings.TrimSpace(value)
+		if !ok || caseID == "" || value == "" {
+			return nil, fmt.Errorf("invalid %s entry %q: expected ID=%s", key, entry, what)
+		}
+		if !slices.Contains(caseIDs, caseID) {
+			return nil, fmt.Errorf("%s references %s which is not in CASE_IDS", key, caseID)
+		}
+		values[caseID] = value
+	}
+	return values, nil
+}
+
+// parseJitter parses POLL_JITTER: a percentage ("20%") or a fraction ("0.2") of at most 50%
+func parseJitter(value string) (float64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	number, percent := strings.CutSuffix(value, "%")
+	jitter, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid POLL_JITTER %q: expected a percentage like 20%%", value)
+	}
+	if percent {
+		jitter /= 100
+	}
+	if jitter < 0 || jitter > 0.5 {
+		return 0, fmt.Errorf("invalid POLL_JITTER %q (allowed: 0%%-50%%)", value)
+	}
+	return jitter, nil
+}
+
+// parseDelayRange parses REQUEST_DELAY: a range ("2s-8s"), or a single duration for
+// a pause of up to that long
+func parseDelayRange(value string) (time.Duration, time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, 0, nil
+	}
+	low, high, isRange := strings.Cut(value, "-")
+	if !isRange {
+		low, high = "0s", value
+	}
+	shortest, err := time.ParseDuration(strings.TrimSpace(low))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid REQUEST_DELAY %q: %w", value, err)
+	}
+	longest, err := time.ParseDuration(strings.TrimSpace(high))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid REQUEST_DELAY %q: %w", value, err)
+	}
+	if shortest < 0 || longest < shortest {
+		return 0, 0, fmt.Errorf("invalid REQUEST_DELAY %q: expected min-max with 0 <= min <= max", value)
+	}
+	return shortest, longest, nil
+}
+
+// PollIntervalFor returns how often a case is polled
+func (c *Config) PollIntervalFor(caseID string) time.Duration {
+	if d, ok := c.CaseSchedule[caseID]; ok {
+		return d
+	}
+	return c.PollInterval
+}
+
+// PollTick returns how often the scheduler wakes up: the shortest poll interval of any case
+func (c *Config) PollTick() time.Duration {
+	tick := time.Duration(0)
+	for _, caseID := range c.CaseIDs {
+		if d := c.PollIntervalFor(caseID); tick == 0 || d < tick {
+			tick = d
+		}
+	}
+	if tick == 0 {
+		return c.PollInterval
+	}
+	return tick
+}
+
+// RecipientsFor returns the email recipients of a notification about the given cases
+// System notifications (no cases) and cases without their own recipients go to RecipientEmails
+func (c *Config) RecipientsFor(caseIDs []string) []string {
+	if len(caseIDs) == 0 {
+		return c.RecipientEmails
+	}
+
+	var recipients []string
+	seen := make(map[string]bool)
+	for _, caseID := range caseIDs {
+		routed, ok := c.CaseRecipients[caseID]
+		if !ok {
+			routed = c.RecipientEmails
+		}
+		for _, recipient := range routed {
+			if key := strings.ToLower(recipient); !seen[key] {
+				seen[key] = true
+				recipients = append(recipients, recipient)
+			}
+		}
+	}
+	return recipients
+}
+
+// parseManageURL parses MANAGE_URL, the manage link of the email footer
+// A path is taken relative to PUBLIC_URL. Unset, the link opens the dashboard's case list,
+// which asks for a sign-in, and is left out without PUBLIC_URL or the dashboard
+func parseManageURL(value, publicURL string, dashboard bool) (string, error) {
+	value = strings.TrimSpace(value)
+	base := strings.TrimRight(publicURL, "/")
+	switch {
+	case value == "" && (base == "" || !dashboard):
+		return "", nil
+	case value == "":
+		return base + "/cases", nil
+	case strings.HasPrefix(value, "/"):
+		if base == "" {
+			return "", fmt.Errorf("MANAGE_URL %q is a path, which needs PUBLIC_URL", value)
+		}
+		return base + value, nil
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid MANAGE_URL %q: expected a path or an http(s):// URL", value)
+	}
+	return value, nil
+}
+
+// parseEmailList parses a comma-separated list of email addresses
+func parseEmailList(key, value string) ([]string, error) {
+	var addresses []string
+	for _, address := range strings.Split(value, ",") {
+		address = strings.TrimSpace(address)
+		if address == "" {
+			continue
+		}
+		if _, err := mail.ParseAddress(address); err != nil {
+			return nil, fmt.Errorf("invalid %s address %q: %w", key, address, err)
+		}
+		addresses = append(addresses, address)
+	}
+	return addresses, nil
+}
+
+// parseCaseRecipients parses CASE_RECIPIENTS: "ID1:alice@x.com,carol@x.com;ID2:bob@y.com"
+// Every case must also appear in CASE_IDS
+func parseCaseRecipients(value string, caseIDs []string) (map[string][]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	tracked := make(map[string]bool, len(caseIDs))
+	for _, id := range caseIDs {
+		tracked[id] = true
+	}
+
+	routes := make(map[string][]string)
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		caseID, list, ok := strings.Cut(entry, ":")
+		caseID = uscis.NormalizeReceiptNumber(caseID)
+		if !ok || caseID == "" {
+			return nil, fmt.Errorf("invalid CASE_RECIPIENTS entry %q: expected ID:email,email", entry)
+		}
+		if !tracked[caseID] {
+			return nil, fmt.Errorf("CASE_RECIPIENTS references %s which is not in CASE_IDS", caseID)
+		}
+		if _, dup := routes[caseID]; dup {
+			return nil, fmt.Errorf("CASE_RECIPIENTS lists %s more than once", caseID)
+		}
+
+		for _, recipient := range strings.Split(list, ",") {
+			recipient = strings.TrimSpace(recipient)
+			if recipient == "" {
+				continue
+			}
+			if _, err := mail.ParseAddress(recipient); err != nil {
+				return nil, fmt.Errorf("invalid CASE_RECIPIENTS address %q for %s: %w", recipient, caseID, err)
+			}
+			routes[caseID] = append(routes[caseID], recipient)
+		}
+		if len(routes[caseID]) == 0 {
+			return nil, fmt.Errorf("CASE_RECIPIENTS entry for %s has no email address", caseID)
+		}
+	}
+	return routes, nil
+}
+
+// WebhooksFor returns the per-case webhooks of a notification about the given cases, without duplicates
+func (c *Config) WebhooksFor(caseIDs []string) []string {
+	var hooks []string
+	for _, caseID := range caseIDs {
+		for _, hook := range c.CaseWebhooks[caseID] {
+			if !slices.Contains(hooks, hook) {
+				hooks = append(hooks, hook)
+			}
+		}
+	}
+	return hooks
+}
+
+// parseCaseWebhooks parses CASE_WEBHOOKS: "ID1:https://a.example/hook,https://b.example/hook;ID2:https://c.example/hook"
+// Every case must also appear in CASE_IDS
+func parseCaseWebhooks(value string, caseIDs []string) (map[string][]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	hooks := make(map[string][]string)
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		// Case IDs have no colon, so the first one ends the ID even though URLs contain more
+		caseID, list, ok := strings.Cut(entry, ":")
+		caseID = uscis.NormalizeReceiptNumber(caseID)
+		if !ok || caseID == "" {
+			return nil, fmt.Errorf("invalid CASE_WEBHOOKS entry %q: expected ID:url,url", entry)
+		}
+		if !slices.Contains(caseIDs, caseID) {
+			return nil, fmt.Errorf("CASE_WEBHOOKS references %s which is not in CASE_IDS", caseID)
+		}
+		if _, dup := hooks[caseID]; dup {
+			return nil, fmt.Errorf("CASE_WEBHOOKS lists %s more than once", caseID)
+		}
+
+		for _, hook := range strings.Split(list, ",") {
+			hook = strings.TrimSpace(hook)
+			if hook == "" {
+				continue
+			}
+			if err := validateWebhookURL(hook); err != nil {
+				return nil, fmt.Errorf("invalid CASE_WEBHOOKS entry for %s: %w", caseID, err)
+			}
+			// A webhook URL is its own credential, and shows up in logs one at a time
+			logging.AddSecret(hook)
+			if !slices.Contains(hooks[caseID], hook) {
+				hooks[caseID] = append(hooks[caseID], hook)
+			}
+		}
+		if len(hooks[caseID]) == 0 {
+			return nil, fmt.Errorf("CASE_WEBHOOKS entry for %s has no URL", caseID)
+		}
+	}
+	return hooks, nil
+}
+
+// validateWebhookURL checks that a webhook is an absolute http(s) URL
+// The URL itself is left out of errors: its path or query often holds a secret
+func validateWebhookURL(hook string) error {
+	u, err := url.Parse(hook)
+	if err != nil {
+		return fmt.Errorf("webhook URL doesn't parse")
+	}
+	if (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("webhook URL must start with https:// or http://")
+	}
+	return nil
+}
+
+// parseRedactFields parses REDACT_FIELDS: "applicantName,aNumber:hash,address:strip"
+// Fields without a mode are stripped
+func parseRedactFields(value string) (map[string]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	fields := make(map[string]string)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, mode, _ := strings.Cut(entry, ":")
+		name = strings.TrimSpace(name)
+		mode = strings.ToLower(strings.TrimSpace(mode))
+		if mode == "" {
+			mode = "strip"
+		}
+		if name == "" || (mode != "strip" && mode != "hash") {
+			return nil, fmt.Errorf("invalid REDACT_FIELDS entry %q: expected field or field:strip|hash", entry)
+		}
+		fields[name] = mode
+	}
+	return fields, nil
+}
+
+// parseChangeIgnoreFields parses CHANGE_IGNORE_FIELDS: "updatedAt,meta.etag,*.requestId"
+func parseChangeIgnoreFields(value string) ([]string, error) {
+	var fields []string
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if _, err := path.Match(field, ""); err != nil {
+			return nil, fmt.Errorf("invalid CHANGE_IGNORE_FIELDS pattern %q: %w", field, err)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// parseSeverityChannels parses SEVERITY_CHANNELS: "telegram:important,push:critical"
+func parseSeverityChannels(value string) (map[string]uscis.Severity, error) {
+	channels := append(slices.Clone(NotificationChannels), "webhook")
+	minimums := make(map[string]uscis.Severity)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		channel, name, ok := strings.Cut(entry, ":")
+		channel = strings.ToLower(strings.TrimSpace(channel))
+		if !ok || !slices.Contains(channels, channel) {
+			return nil, fmt.Errorf("invalid SEVERITY_CHANNELS entry %q (expected channel:severity with channel one of %s)", entry, strings.Join(channels, ", "))
+		}
+		if _, dup := minimums[channel]; dup {
+			return nil, fmt.Errorf("SEVERITY_CHANNELS lists %s more than once", channel)
+		}
+		severity, err := uscis.ParseSeverity(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SEVERITY_CHANNELS entry %q: %w", entry, err)
+		}
+		minimums[channel] = severity
+	}
+	return minimums, nil
+}
+
+// parseBundles parses CASE_BUNDLES: "name=ID1,ID2,ID3;other=ID4,ID5"
+// Every bundled case must also appear in CASE_IDS and may belong to only one bundle
+func parseBundles(value string, caseIDs []string) ([]Bundle, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	tracked := make(map[string]bool, len(caseIDs))
+	for _, id := range caseIDs {
+		tracked[id] = true
+	}
+
+	var bundles []Bundle
+	assigned := make(map[string]string)
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, idList, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid CASE_BUNDLES entry %q: expected name=ID1,ID2", entry)
+		}
+
+		bundle := Bundle{Name: name}
+		for _, id := range strings.Split(idList, ",") {
+			id = uscis.NormalizeReceiptNumber(id)
+			if id == "" {
+				continue
+			}
+			if !tracked[id] {
+				return nil, fmt.Errorf("CASE_BUNDLES bundle %q references %s which is not in CASE_IDS", name, id)
+			}
+			if other, dup := assigned[id]; dup {
+				return nil, fmt.Errorf("CASE_BUNDLES: %s is in both %q and %q", id, other, name)
+			}
+			assigned[id] = name
+			bundle.CaseIDs = append(bundle.CaseIDs, id)
+		}
+		if len(bundle.CaseIDs) < 2 {
+			return nil, fmt.Errorf("CASE_BUNDLES bundle %q must contain at least two case IDs", name)
+		}
+		bundles = append(bundles, bundle)
+	}
+
+	return bundles, nil
+}
+
+// publicStatusFields are the fields that may be shown on the public status page
+var publicStatusFields = map[string]bool{"step": true, "stage": true, "last_update": true, "status": true, "form": true}
+
+// parsePublicCases parses PUBLIC_STATUS_CASES: "ID1=Case A,ID2=Case B"
+// Cases without an alias get a generic one so receipt numbers are never exposed
+func parsePublicCases(value string, caseIDs []string) ([]PublicCase, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	tracked := make(map[string]bool, len(caseIDs))
+	for _, id := range caseIDs {
+		tracked[id] = true
+	}
+
+	var cases []PublicCase
+	for i, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, alias, _ := strings.Cut(entry, "=")
+		id, alias = uscis.NormalizeReceiptNumber(id), strings.TrimSpace(alias)
+		if !tracked[id] {
+			return nil, fmt.Errorf("PUBLIC_STATUS_CASES references %s which is not in CASE_IDS", id)
+		}
+		if alias == "" {
+			alias = fmt.Sprintf("Case %c", 'A'+rune(i%26))
+		}
+		cases = append(cases, PublicCase{CaseID: id, Alias: alias})
+	}
+	return cases, nil
+}
+
+// parsePublicFields parses PUBLIC_STATUS_FIELDS, defaulting to step and last update
+func parsePublicFields(value string) ([]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return []string{"step", "last_update"}, nil
+	}
+
+	var fields []string
+	for _, field := range strings.Split(value, ",") {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if field == "" {
+			continue
+		}
+		if !publicStatusFields[field] {
+			return nil, fmt.Errorf("invalid PUBLIC_STATUS_FIELDS entry %q (allowed: step, stage, last_update, status, form)", field)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// LocaleFor returns the date rendering settings for a recipient
+func (c *Config) LocaleFor(recipient string) locale.Settings {
+	if settings, ok := c.RecipientLocales[strings.ToLower(recipient)]; ok {
+		return settings
+	}
+	return c.Locale
+}
+
+// parseRecipientLocales parses RECIPIENT_LOCALES: "email=Timezone:locale;email=Timezone"
+// Either part may be omitted ("email=:de-DE") to inherit it from the default settings
+func parseRecipientLocales(value string, defaults locale.Settings) (map[string]locale.Settings, error) {
+	overrides := make(map[string]locale.Settings)
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		recipient, spec, ok := strings.Cut(entry, "=")
+		recipient = strings.ToLower(strings.TrimSpace(recipient))
+		if !ok || recipient == "" {
+			return nil, fmt.Errorf("invalid RECIPIENT_LOCALES entry %q (expected email=Timezone:locale)", entry)
+		}
+
+		timezone, tag, _ := strings.Cut(strings.TrimSpace(spec), ":")
+		if timezone == "" {
+			timezone = defaults.Location.String()
+		}
+		if tag == "" {
+			tag = defaults.Locale
+		}
+		settings, err := locale.New(timezone, tag)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RECIPIENT_LOCALES entry for %s: %w", recipient, err)
+		}
+		overrides[recipient] = settings
+	}
+	return overrides, nil
+}
+
+// ChannelLimits bound how a notification channel sends
+type ChannelLimits struct {
+	Concurrency int           // Sends in flight at once
+	Timeout     time.Duration // How long one send may take
+	QueueDepth  int           // Sends waiting for a free slot before new ones fail
+	PerHour     int           // Non-urgent notifications per hour before the rest wait (0 = unlimited)
+}
+
+// validate checks that limits are usable
+func (l ChannelLimits) validate() error {
+	if l.Concurrency < 1 || l.Concurrency > maxNotifyConcurrency {
+		return fmt.Errorf("invalid notification concurrency %d (allowed: 1-%d)", l.Concurrency, maxNotifyConcurrency)
+	}
+	if l.Timeout <= 0 {
+		return fmt.Errorf("notification timeout must be positive")
+	}
+	if l.QueueDepth < 0 {
+		return fmt.Errorf("notification queue depth must not be negative")
+	}
+	if l.PerHour < 0 {
+		return fmt.Errorf("notifications per hour must not be negative")
+	}
+	return nil
+}
+
+// maxNotifyConcurrency caps the sends in flight per channel
+const maxNotifyConcurrency = 32
+
+// LimitsFor returns the send limits of a channel
+func (c *Config) LimitsFor(channel string) ChannelLimits {
+	if limits, ok := c.NotifyChannelLimits[channel]; ok {
+		return limits
+	}
+	return c.NotifyLimits
+}
+
+// parseChannelLimits parses NOTIFY_CHANNEL_LIMITS: "slack:concurrency=1,timeout=10s;email:queue=50"
+// Settings left out of an entry keep the defaults
+func parseChannelLimits(value string, defaults ChannelLimits) (map[string]ChannelLimits, error) {
+	channels := append(slices.Clone(NotificationChannels), "webhook")
+	overrides := make(map[string]ChannelLimits)
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		channel, spec, ok := strings.Cut(entry, ":")
+		channel = strings.ToLower(strings.TrimSpace(channel))
+		if !ok || !slices.Contains(channels, channel) {
+			return nil, fmt.Errorf("invalid NOTIFY_CHANNEL_LIMITS entry %q (expected channel:setting=value,... with channel one of %s)", entry, strings.Join(channels, ", "))
+		}
+		if _, dup := overrides[channel]; dup {
+			return nil, fmt.Errorf("NOTIFY_CHANNEL_LIMITS lists %s more than once", channel)
+		}
+
+		limits := defaults
+		for _, setting := range strings.Split(spec, ",") {
+			key, raw, _ := strings.Cut(strings.TrimSpace(setting), "=")
+			var err error
+			switch strings.ToLower(strings.TrimSpace(key)) {
+			case "concurrency":
+				limits.Concurrency, err = strconv.Atoi(strings.TrimSpace(raw))
+			case "timeout":
+				limits.Timeout, err = time.ParseDuration(strings.TrimSpace(raw))
+			case "queue":
+				limits.QueueDepth, err = strconv.Atoi(strings.TrimSpace(raw))
+			case "per_hour":
+				limits.PerHour, err = strconv.Atoi(strings.TrimSpace(raw))
+			default:
+				return nil, fmt.Errorf("invalid NOTIFY_CHANNEL_LIMITS setting %q for %s (allowed: concurrency, timeout, queue, per_hour)", setting, channel)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid NOTIFY_CHANNEL_LIMITS setting %q for %s: %w", setting, channel, err)
+			}
+		}
+		if err := limits.validate(); err != nil {
+			return nil, fmt.Errorf("invalid NOTIFY_CHANNEL_LIMITS entry for %s: %w", channel, err)
+		}
+		overrides[channel] = limits
+	}
+	return overrides, nil
+}
+
+// QuietWindow is a daily period during which a channel gets no non-urgent notifications
+// Start and End are offsets from midnight; a window with End before Start spans midnight
+type QuietWindow struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// Until returns when the window ends if t falls inside it
+func (w QuietWindow) Until(t time.Time) (time.Time, bool) {
+	year, month, day := t.Date()
+	midnight := time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+	offset := t.Sub(midnight)
+	switch {
+	case w.Start < w.End && offset >= w.Start && offset < w.End:
+		return midnight.Add(w.End), true
+	case w.Start > w.End && offset >= w.Start:
+		return time.Date(year, month, day+1, 0, 0, 0, 0, t.Location()).Add(w.End), true
+	case w.Start > w.End && offset < w.End:
+		return midnight.Add(w.End), true
+	}
+	return time.Time{}, false
+}
+
+// String formats the window as HH:MM-HH:MM
+func (w QuietWindow) String() string {
+	clock := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return clock(w.Start) + "-" + clock(w.End)
+}
+
+// QuietHoursFor returns the quiet hours of a channel, if it has any
+func (c *Config) QuietHoursFor(channel string) (QuietWindow, bool) {
+	if window, ok := c.QuietHours[channel]; ok {
+		return window, true
+	}
+	window, ok := c.QuietHours[""]
+	return window, ok
+}
+
+// parseQuietHours parses QUIET_HOURS: "23:00-07:00" for every channel, or per channel
+// "email:23:00-07:00;telegram:22:00-06:30"; both can be combined
+func parseQuietHours(value string) (map[string]QuietWindow, error) {
+	channels := append(slices.Clone(NotificationChannels), "webhook")
+	windows := make(map[string]QuietWindow)
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		channel, spec := "", entry
+		if name, rest, ok := strings.Cut(entry, ":"); ok && slices.Contains(channels, strings.ToLower(strings.TrimSpace(name))) {
+			channel, spec = strings.ToLower(strings.TrimSpace(name)), rest
+		}
+		if _, dup := windows[channel]; dup {
+			return nil, fmt.Errorf("QUIET_HOURS lists %q more than once", cmp.Or(channel, "every channel"))
+		}
+
+		if !strings.Contains(spec, "-") {
+			return nil, fmt.Errorf("invalid QUIET_HOURS entry %q (expected HH:MM-HH:MM or channel:HH:MM-HH:MM with channel one of %s)", entry, strings.Join(channels, ", "))
+		}
+		window, err := parseDailyWindow(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid QUIET_HOURS entry %q: %w", entry, err)
+		}
+		windows[channel] = window
+	}
+	return windows, nil
+}
+
+// parseDailyWindow parses a daily period "HH:MM-HH:MM"; "23:00-07:00" spans midnight
+func parseDailyWindow(spec string) (QuietWindow, error) {
+	start, end, ok := strings.Cut(spec, "-")
+	if !ok {
+		return QuietWindow{}, fmt.Errorf("expected HH:MM-HH:MM, got %q", spec)
+	}
+	var window QuietWindow
+	var err error
+	if window.Start, err = parseTimeOfDay(start); err != nil {
+		return QuietWindow{}, err
+	}
+	if window.End, err = parseTimeOfDay(end); err != nil {
+		return QuietWindow{}, err
+	}
+	if window.Start == window.End {
+		return QuietWindow{}, fmt.Errorf("start and end are the same")
+	}
+	return window, nil
+}
+
+// parseTimeOfDay parses "HH:MM" into an offset from midnight
+func parseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a time of day (HH:MM)", strings.TrimSpace(value))
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// CaseIDsAuto is the CASE_IDS value that tracks every case on the USCIS account
+const CaseIDsAuto = "auto"
+
+// CanaryCaseID is the case ID the pipeline canary is tracked under
+const CanaryCaseID = "CANARY"
+
+// IsCanary reports whether a case is the pipeline canary
+func (c *Config) IsCanary(caseID string) bool {
+	return c.CanaryInterval > 0 && caseID == CanaryCaseID
+}
+
+// IsSMSSender reports whether a text received on the Twilio number comes from a
+// TWILIO_SMS_FROM sender
+func (c *Config) IsSMSSender(from string) bool {
+	return slices.Contains(c.TwilioSMSFrom, normalizePhone(from))
+}
+
+// phonePattern matches a normalized phone number or short code
+var phonePattern = regexp.MustCompile(`^\+?[0-9]{3,15}$`)
+
+// parsePhoneList parses a comma-separated list of phone numbers or short codes
+func parsePhoneList(key, value string) ([]string, error) {
+	var numbers []string
+	for _, number := range strings.Split(value, ",") {
+		if strings.TrimSpace(number) == "" {
+			continue
+		}
+		normalized := normalizePhone(number)
+		if !phonePattern.MatchString(normalized) {
+			return nil, fmt.Errorf("invalid %s number %q: expected e.g. +15551234567 or a short code", key, strings.TrimSpace(number))
+		}
+		numbers = append(numbers, normalized)
+	}
+	return numbers, nil
+}
+
+// normalizePhone drops the spaces, dashes, dots and parentheses a number is written with
+func normalizePhone(number string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(" -.()", r) {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(number))
+}
+
+// NotificationChannels are the channel names notification settings can refer to
+var NotificationChannels = []string{"email", "telegram", "slack", "push"}
+
+// parseChannelList parses a comma-separated list of channel names; "" and "all" mean every channel
+func parseChannelList(value string) ([]string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" || value == "all" {
+		return nil, nil
+	}
+	var channels []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !slices.Contains(NotificationChannels, name) {
+			return nil, fmt.Errorf("unknown channel %q (allowed: %s, all)", name, strings.Join(NotificationChannels, ", "))
+		}
+		channels = append(channels, name)
+	}
+	return channels, nil
+}
+
+// maxPollWorkers caps concurrent fetches; each browser fetch is a Chrome tab
+const maxPollWorkers = 16
+
+// HTTP endpoint groups that can be enabled with HTTP_ENDPOINTS
+const (
+	EndpointsHealth    = "health"    // /health
+	EndpointsAPI       = "api"       // /status and JSON APIs
+	EndpointsMetrics   = "metrics"   // /metrics
+	EndpointsDashboard = "dashboard" // HTML dashboard pages
+)
+
+var httpEndpointGroups = []string{EndpointsHealth, EndpointsAPI, EndpointsMetrics, EndpointsDashboard}
+
+// EndpointsEnabled reports whether an HTTP endpoint group is exposed
+func (c *Config) EndpointsEnabled(group string) bool {
+	return group == EndpointsHealth || c.HTTPEndpoints[group]
+}
+
+// parseHTTPEndpoints parses HTTP_ENDPOINTS: "health,api,metrics,dashboard" or "all"
+// Defaults to health only so new endpoints are never exposed without opting in
+func parseHTTPEndpoints(value string) (map[string]bool, error) {
+	groups := map[string]bool{EndpointsHealth: true}
+	for _, group := range strings.Split(value, ",") {
+		group = strings.ToLower(strings.TrimSpace(group))
+		switch {
+		case group == "":
+			continue
+		case group == "all":
+			for _, g := range httpEndpointGroups {
+				groups[g] = true
+			}
+		case slices.Contains(httpEndpointGroups, group):
+			groups[group] = true
+		default:
+			return nil, fmt.Errorf("invalid HTTP_ENDPOINTS entry %q (allowed: %s, all)", group, strings.Join(httpEndpointGroups, ", "))
+		}
+	}
+	return groups, nil
+}
+
+// knownEnvKeys lists every environment variable the tracker reads
+var knownEnvKeys = append([]string{
+	"CONFIG_FILE",
+	"AUTO_LOGIN",
+	"USCIS_COOKIE",
+	"USCIS_USERNAME",
+	"USCIS_PASSWORD",
+	"CASE_IDS",
+	"CASES",
+	"CASE_DISCOVERY_INTERVAL",
+	"CASE_SOURCES",
+	"DEFAULT_CASE_SOURCE",
+	"CASE_BUNDLES",
+	"CELEBRATION_EMAIL",
+	"PUBLIC_STATUS_CASES",
+	"PUBLIC_STATUS_FIELDS",
+	"HTTP_ENDPOINTS",
+	"API_TOKEN",
+	"PUBLIC_URL",
+	"BRAND_NAME",
+	"EMAIL_FROM",
+	"EMAIL_REPLY_TO",
+	"EMAIL_CC",
+	"EMAIL_BCC",
+	"EMAIL_FOOTER",
+	"EMAIL_TEMPLATE_DIR",
+	"MANAGE_URL",
+	"LINK_SECRET",
+	"TIMEZONE",
+	"LOCALE",
+	"RECIPIENT_LOCALES",
+	"RESEND_API_KEY",
+	"RECIPIENT_EMAIL",
+	"CASE_RECIPIENTS",
+	"TELEGRAM_BOT_TOKEN",
+	"TELEGRAM_CHAT_ID",
+	"TELEGRAM_WEBHOOK_SECRET",
+	"SLACK_WEBHOOK_URL",
+	"WEB_PUSH",
+	"WEB_PUSH_CONTACT",
+	"WEBHOOK_URLS",
+	"CASE_WEBHOOKS",
+	"TENANTS",
+	"TENANT_RECIPIENTS",
+	"TENANT_WEBHOOK_URLS",
+	"TENANT_TELEGRAM_CHATS",
+	"POLL_INTERVAL",
+	"CASE_SCHEDULE",
+	"CASE_NICKNAMES",
+	"CASE_FORM_TYPES",
+	"CASE_APPLICANTS",
+	"POLL_CYCLE_BUDGET",
+	"POLL_FAIRNESS",
+	"POLL_WORKERS",
+	"POLL_JITTER",
+	"REQUEST_DELAY",
+	"POLL_LOCK",
+	"POLL_LOCK_TTL",
+	"DAILY_REQUEST_BUDGET",
+	"BUDGET_ALERT",
+	"PREFLIGHT_CHECK",
+	"PREFLIGHT_ALERT",
+	"HEALTH_FAIL_AFTER",
+	"RUN_ONCE",
+	"RESULT_FILE",
+	"BOOTSTRAP_STAGGER",
+	"BOOTSTRAP_SUMMARY_MIN",
+	"CHANGE_DIGEST_MIN",
+	"CHANGE_DIGEST_CHANNELS",
+	"SEVERITY_CHANNELS",
+	"ACK_ESCALATION",
+	"ACK_WINDOW",
+	"STATE_FILE_DIR",
+	"STORAGE_BACKEND",
+	"SQLITE_PATH",
+	"POSTGRES_URL",
+	"S3_BUCKET",
+	"S3_PREFIX",
+	"S3_REGION",
+	"S3_ENDPOINT",
+	"S3_PATH_STYLE",
+	"S3_ACCESS_KEY_ID",
+	"S3_SECRET_ACCESS_KEY",
+	"ARCHIVE_AFTER",
+	"ARCHIVE_LOCATION",
+	"REDACT_FIELDS",
+	"CHANGE_IGNORE_FIELDS",
+	"ANOMALY_CHECK",
+	"NOTIFY_CONCURRENCY",
+	"NOTIFY_MAX_PER_HOUR",
+	"RESEND_DAILY_QUOTA",
+	"NOTIFIER",
+	"SMTP_HOST",
+	"SMTP_PORT",
+	"SMTP_SECURITY",
+	"SMTP_USERNAME",
+	"SMTP_PASSWORD",
+	"CANARY_INTERVAL",
+	"CANARY_CHANNELS",
+	"QUIET_HOURS",
+	"NOTIFY_TIMEOUT",
+	"NOTIFY_QUEUE_DEPTH",
+	"NOTIFY_CHANNEL_LIMITS",
+	"REDACT_HASH_KEY",
+	"INSTANCE_ID",
+	"FETCH_TIMEOUT",
+	"BROWSER_RECYCLE_AFTER",
+	"CIRCUIT_BREAKER_FAILURES",
+	"CIRCUIT_BREAKER_GLOBAL_FAILURES",
+	"CIRCUIT_BREAKER_COOLDOWN",
+	"USCIS_TOTP_SECRET",
+	"TWILIO_AUTH_TOKEN",
+	"TWILIO_SMS_FROM",
+	"EMAIL_IMAP_SERVER",
+	"EMAIL_USERNAME",
+	"EMAIL_PASSWORD",
+	"EMAIL_2FA_SENDER",
+	"EMAIL_OAUTH_CLIENT_ID",
+	"EMAIL_OAUTH_CLIENT_SECRET",
+	"EMAIL_OAUTH_REFRESH_TOKEN",
+	"EMAIL_OAUTH_TOKEN_URL",
+	"PERSIST_BROWSER_SESSION",
+	"FETCH_CASE_HISTORY",
+	"NOTICE_PDFS",
+	"CHROME_FALLBACK",
+	"CHROME_REMOTE_URL",
+	"LOGIN_CAPTURES",
+	"LOGIN_SPACING",
+	"LOGIN_JITTER",
+	"LOGIN_HOLD_HOURS",
+	"SESSION_REFRESH_INTERVAL",
+	"SESSION_REFRESH_WINDOW",
+	"LOG_FILE",
+	"LOG_MAX_SIZE_MB",
+	"LOG_MAX_BACKUPS",
+	"LOG_MAX_AGE",
+	"LOG_ROTATE_INTERVAL",
+	"LOG_BUFFER_SIZE",
+	"DEBUG_UNSAFE_LOGS",
+	"PORT",
+}, accountEnvKeys()...)
+
+// RunOnceEnv reports whether RUN_ONCE asks for a single poll, for callers that have no
+// loaded configuration
+func RunOnceEnv() bool {
+	runOnceStr := strings.ToLower(os.Getenv("RUN_ONCE"))
+	return runOnceStr == "true" || runOnceStr == "1" || runOnceStr == "yes"
+}
+
+// telegramSecretPattern is what Telegram accepts as a webhook's secret_token
+var telegramSecretPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
+// secretKeyMarkers identify environment variables whose values are credentials
+var secretKeyMarkers = []string{"PASSWORD", "COOKIE", "API_KEY", "HASH_KEY", "SECRET", "TOKEN", "WEBHOOK_URL", "CASE_WEBHOOKS", "POSTGRES_URL", "CHROME_REMOTE_URL"}
+
+// IsSecretKey reports whether an environment variable holds a credential
+func IsSecretKey(key string) bool {
+	for _, marker := range secretKeyMarkers {
+		if strings.Contains(key, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactedEnv returns the tracker's environment configuration with credentials masked
+// Unset variables are omitted; secrets are reduced to "<redacted, N chars>"
+func RedactedEnv() map[string]string {
+	env := make(map[string]string)
+	for _, key := range knownEnvKeys {
+		value, ok := os.LookupEnv(key)
+		if !ok {
+			continue
+		}
+		if IsSecretKey(key) && value != "" {
+			value = fmt.Sprintf("<redacted, %d chars>", len(value))
+		}
+		env[key] = value
+	}
+	return env
+}
+
+// UnsafeLogs reports whether DEBUG_UNSAFE_LOGS turns off masking secrets in the logs
+// It reads the process environment, so logging can be set up before the configuration
+// is loaded; Config.UnsafeLogs also covers a CONFIG_FILE setting
+func UnsafeLogs() bool {
+	return unsafeLogs(processEnvironment())
+}
+
+// unsafeLogs reports whether DEBUG_UNSAFE_LOGS is set in env
+func unsafeLogs(env environment) bool {
+	value := strings.ToLower(strings.TrimSpace(env["DEBUG_UNSAFE_LOGS"]))
+	return value == "true" || value == "1" || value == "yes"
+}
+
+// SecretValues returns the values of every credential set in the environment
+// so they can be masked wherever text leaves the process
+func SecretValues() []string {
+	return processEnvironment().secretValues()
+}
+
+// secretValues returns the values of every credential in env
+func (env environment) secretValues() []string {
+	var values []string
+	for _, key := range knownEnvKeys {
+		if value := env[key]; IsSecretKey(key) && value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// Email providers (NOTIFIER)
+const (
+	NotifierResend = "resend"
+	NotifierSMTP   = "smtp"
+)
+
+// loadSMTP reads the SMTP server settings (NOTIFIER=smtp)
+func (c *Config) loadSMTP(env environment, partial bool) error {
+	c.SMTPHost = env["SMTP_HOST"]
+	c.SMTPUsername = env["SMTP_USERNAME"]
+	c.SMTPPassword = env["SMTP_PASSWORD"]
+	c.SMTPSecurity = strings.ToLower(stringEnv(env, "SMTP_SECURITY", notifier.SMTPStartTLS))
+	if !slices.Contains(notifier.SMTPSecurityModes, c.SMTPSecurity) {
+		return fmt.Errorf("SMTP_SECURITY must be one of %v, got %q", notifier.SMTPSecurityModes, c.SMTPSecurity)
+	}
+	defaultPort := map[string]int{notifier.SMTPStartTLS: 587, notifier.SMTPSSL: 465, notifier.SMTPNone: 25}[c.SMTPSecurity]
+	port, err := intEnv(env, "SMTP_PORT", defaultPort)
+	if err != nil {
+		return err
+	}
+	if port == 0 || port > 65535 {
+		return fmt.Errorf("invalid SMTP_PORT: must be between 1 and 65535")
+	}
+	c.SMTPPort = port
+
+	if c.SMTPHost == "" && !partial {
+		return fmt.Errorf("SMTP_HOST environment variable is required when NOTIFIER=smtp")
+	}
+	if c.SMTPUsername != "" && c.SMTPPassword == "" {
+		return fmt.Errorf("SMTP_PASSWORD environment variable is required when SMTP_USERNAME is set")
+	}
+	return nil
+}
+
+// loadS3 reads the bucket settings (STORAGE_BACKEND=s3)
+func (c *Config) loadS3(env environment, partial bool) error {
+	c.S3Bucket = env["S3_BUCKET"]
+	c.S3Prefix = strings.Trim(env["S3_PREFIX"], "/")
+	c.S3Region = env["S3_REGION"]
+	if c.S3Region == "" {
+		// The AWS SDKs' variables, e.g. set by Lambda and ECS
+		c.S3Region = stringEnv(env, "AWS_REGION", stringEnv(env, "AWS_DEFAULT_REGION", "us-east-1"))
+	}
+	c.S3Endpoint = strings.TrimSuffix(env["S3_ENDPOINT"], "/")
+	if c.S3Endpoint != "" {
+		u, err := url.Parse(c.S3Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid S3_ENDPOINT %q: expected http(s)://host[:port]", c.S3Endpoint)
+		}
+	}
+	// MinIO and most self-hosted services only serve buckets in the path
+	pathStyle := strings.ToLower(env["S3_PATH_STYLE"])
+	if pathStyle == "" {
+		c.S3PathStyle = c.S3Endpoint != ""
+	} else {
+		c.S3PathStyle = pathStyle == "true" || pathStyle == "1" || pathStyle == "yes"
+	}
+	c.S3AccessKeyID = env["S3_ACCESS_KEY_ID"]
+	c.S3SecretAccessKey = env["S3_SECRET_ACCESS_KEY"]
+
+	if c.S3Bucket == "" && !partial {
+		return fmt.Errorf("S3_BUCKET environment variable is required when STORAGE_BACKEND=s3")
+	}
+	if (c.S3AccessKeyID == "") != (c.S3SecretAccessKey == "") {
+		return fmt.Errorf("S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY must be set together")
+	}
+	return nil
+}
+
+// defaultSender is the sender address when EMAIL_FROM isn't set: Resend's shared test
+// address, or with SMTP the account itself, which is what most providers accept
+func (c *Config) defaultSender() string {
+	if c.Notifier != NotifierSMTP {
+		return "onboarding@resend.dev"
+	}
+	if strings.Contains(c.SMTPUsername, "@") {
+		return c.SMTPUsername
+	}
+	return c.RecipientEmail
+}
+
+// stringEnv returns an optional environment variable or its default
+func stringEnv(env environment, key, def string) string {
+	if value := strings.TrimSpace(env[key]); value != "" {
+		return value
+	}
+	return def
+}
+
+// durationEnv parses an optional duration environment variable
+func durationEnv(env environment, key string, def time.Duration) (time.Duration, error) {
+	value := env[key]
+	if value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return d, nil
+}
+
+// intEnv parses an optional non-negative integer environment variable
+func intEnv(env environment, key string, def int) (int, error) {
+	value := env[key]
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s: must be a non-negative integer", key)
+	}
+	return n, nil
 }
@@ -0,0 +1,310 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/locale"
+)
+
+func TestParsePhoneList(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{name: "empty", value: "", want: nil},
+		{name: "e164", value: "+15551234567", want: []string{"+15551234567"}},
+		{name: "formatted", value: "+1 (555) 123-4567, 555.987.6543", want: []string{"+15551234567", "5559876543"}},
+		{name: "short code", value: "28734", want: []string{"28734"}},
+		{name: "blank entries", value: " ,28734, ", want: []string{"28734"}},
+		{name: "letters", value: "USCIS", wantErr: true},
+		{name: "too short", value: "12", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePhoneList("TWILIO_SMS_FROM", tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePhoneList(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePhoneList(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsSMSSender(t *testing.T) {
+	cfg := &Config{TwilioSMSFrom: []string{"+15551234567", "28734"}}
+	tests := []struct {
+		from string
+		want bool
+	}{
+		{from: "+15551234567", want: true},
+		{from: "+1 555-123-4567", want: true},
+		{from: "28734", want: true},
+		{from: "+15550000000", want: false},
+		{from: "", want: false},
+	}
+	for _, tt := range tests {
+		if got := cfg.IsSMSSender(tt.from); got != tt.want {
+			t.Errorf("IsSMSSender(%q) = %v, want %v", tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestParseQuietHours(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    map[string]QuietWindow
+		wantErr bool
+	}{
+		{name: "empty", value: "", want: map[string]QuietWindow{}},
+		{name: "every channel", value: "23:00-07:00", want: map[string]QuietWindow{"": {Start: 23 * time.Hour, End: 7 * time.Hour}}},
+		{
+			name:  "per channel",
+			value: "email:23:00-07:00; Telegram:22:00-06:30",
+			want: map[string]QuietWindow{
+				"email":    {Start: 23 * time.Hour, End: 7 * time.Hour},
+				"telegram": {Start: 22 * time.Hour, End: 6*time.Hour + 30*time.Minute},
+			},
+		},
+		{
+			name:  "combined",
+			value: "23:00-07:00;slack:20:00-09:00",
+			want: map[string]QuietWindow{
+				"":      {Start: 23 * time.Hour, End: 7 * time.Hour},
+				"slack": {Start: 20 * time.Hour, End: 9 * time.Hour},
+			},
+		},
+		{name: "duplicate", value: "email:23:00-07:00;email:22:00-07:00", wantErr: true},
+		{name: "unknown channel", value: "fax:23:00-07:00", wantErr: true},
+		{name: "same start and end", value: "07:00-07:00", wantErr: true},
+		{name: "not a time", value: "25:00-07:00", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseQuietHours(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseQuietHours(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseQuietHours(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuietWindowUntil(t *testing.T) {
+	overnight := QuietWindow{Start: 23 * time.Hour, End: 7 * time.Hour}
+	daytime := QuietWindow{Start: 9 * time.Hour, End: 17 * time.Hour}
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2025, 10, day, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name      string
+		window    QuietWindow
+		t         time.Time
+		wantUntil time.Time
+		wantQuiet bool
+	}{
+		{name: "before midnight", window: overnight, t: at(11, 23, 30), wantUntil: at(12, 7, 0), wantQuiet: true},
+		{name: "after midnight", window: overnight, t: at(12, 3, 0), wantUntil: at(12, 7, 0), wantQuiet: true},
+		{name: "end is outside", window: overnight, t: at(12, 7, 0), wantQuiet: false},
+		{name: "daytime inside", window: daytime, t: at(12, 12, 0), wantUntil: at(12, 17, 0), wantQuiet: true},
+		{name: "daytime outside", window: daytime, t: at(12, 8, 59), wantQuiet: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			until, quiet := tt.window.Until(tt.t)
+			if quiet != tt.wantQuiet || !until.Equal(tt.wantUntil) {
+				t.Errorf("Until(%v) = %v, %v, want %v, %v", tt.t, until, quiet, tt.wantUntil, tt.wantQuiet)
+			}
+		})
+	}
+}
+
+func TestParseRecipientLocales(t *testing.T) {
+	defaults, err := locale.New("America/New_York", "en-US")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		value   string
+		want    map[string][2]string // recipient -> timezone, locale
+		wantErr bool
+	}{
+		{name: "empty", value: "", want: map[string][2]string{}},
+		{
+			name:  "full and partial entries",
+			value: "Me@Example.com=America/Chicago;mom@example.com=Asia/Taipei:zh-TW;dad@example.com=:es",
+			want: map[string][2]string{
+				"me@example.com":  {"America/Chicago", "en-US"},
+				"mom@example.com": {"Asia/Taipei", "zh-TW"},
+				"dad@example.com": {"America/New_York", "es"},
+			},
+		},
+		{name: "missing recipient", value: "=America/Chicago", wantErr: true},
+		{name: "missing separator", value: "me@example.com", wantErr: true},
+		{name: "unknown timezone", value: "me@example.com=Mars/Olympus", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRecipientLocales(tt.value, defaults)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRecipientLocales(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			simplified := make(map[string][2]string, len(got))
+			for recipient, settings := range got {
+				simplified[recipient] = [2]string{settings.Location.String(), settings.Locale}
+			}
+			if !reflect.DeepEqual(simplified, tt.want) {
+				t.Errorf("parseRecipientLocales(%q) = %v, want %v", tt.value, simplified, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCaseSchedule(t *testing.T) {
+	caseIDs := []string{"IOE0933798378", "IOE0933798379"}
+	tests := []struct {
+		name    string
+		value   string
+		want    map[string]time.Duration
+		wantErr bool
+	}{
+		{name: "empty", value: "", want: nil},
+		{name: "one case", value: "IOE0933798378=1h", want: map[string]time.Duration{"IOE0933798378": time.Hour}},
+		{name: "lowercase id", value: "ioe0933798379 = 30m", want: map[string]time.Duration{"IOE0933798379": 30 * time.Minute}},
+		{name: "untracked case", value: "IOE0000000000=1h", wantErr: true},
+		{name: "missing interval", value: "IOE0933798378", wantErr: true},
+		{name: "negative interval", value: "IOE0933798378=-1h", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCaseSchedule(tt.value, caseIDs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCaseSchedule(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseCaseSchedule(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseJitter(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    float64
+		wantErr bool
+	}{
+		{value: "", want: 0},
+		{value: "20%", want: 0.2},
+		{value: "0.1", want: 0.1},
+		{value: "50%", want: 0.5},
+		{value: "60%", wantErr: true},
+		{value: "-5%", wantErr: true},
+		{value: "lots", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseJitter(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseJitter(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("parseJitter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseDelayRange(t *testing.T) {
+	tests := []struct {
+		value     string
+		wantMin   time.Duration
+		wantMax   time.Duration
+		wantError bool
+	}{
+		{value: ""},
+		{value: "5s", wantMin: 0, wantMax: 5 * time.Second},
+		{value: "2s-8s", wantMin: 2 * time.Second, wantMax: 8 * time.Second},
+		{value: "8s-2s", wantError: true},
+		{value: "soon", wantError: true},
+	}
+	for _, tt := range tests {
+		gotMin, gotMax, err := parseDelayRange(tt.value)
+		if (err != nil) != tt.wantError {
+			t.Errorf("parseDelayRange(%q) error = %v, wantErr %v", tt.value, err, tt.wantError)
+			continue
+		}
+		if gotMin != tt.wantMin || gotMax != tt.wantMax {
+			t.Errorf("parseDelayRange(%q) = %v, %v, want %v, %v", tt.value, gotMin, gotMax, tt.wantMin, tt.wantMax)
+		}
+	}
+}
+
+func TestLoadLocaleDefaults(t *testing.T) {
+	t.Setenv("TZ", "")
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LANG", "")
+	tests := []struct {
+		name         string
+		env          environment
+		wantTimezone string
+		wantLocale   string
+	}{
+		{
+			name:         "defaults",
+			env:          environment{"RECIPIENT_EMAIL": "me@example.com"},
+			wantTimezone: locale.DefaultTimezone,
+			wantLocale:   locale.DefaultLocale,
+		},
+		{
+			name:         "explicit UTC is kept",
+			env:          environment{"RECIPIENT_EMAIL": "me@example.com", "TIMEZONE": "UTC"},
+			wantTimezone: "UTC",
+			wantLocale:   locale.DefaultLocale,
+		},
+		{
+			name: "first recipient's settings",
+			env: environment{
+				"RECIPIENT_EMAIL":   "me@example.com,mom@example.com",
+				"RECIPIENT_LOCALES": "mom@example.com=Asia/Taipei:zh-TW;me@example.com=America/Chicago:es",
+			},
+			wantTimezone: "America/Chicago",
+			wantLocale:   "es",
+		},
+		{
+			name: "explicit settings win over the first recipient's",
+			env: environment{
+				"RECIPIENT_EMAIL":   "me@example.com",
+				"RECIPIENT_LOCALES": "me@example.com=America/Chicago:es",
+				"TIMEZONE":          "Europe/Berlin",
+			},
+			wantTimezone: "Europe/Berlin",
+			wantLocale:   "es",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := load(tt.env, true)
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			if got := cfg.Locale.Location.String(); got != tt.wantTimezone {
+				t.Errorf("timezone = %q, want %q", got, tt.wantTimezone)
+			}
+			if got := cfg.Locale.Locale; got != tt.wantLocale {
+				t.Errorf("locale = %q, want %q", got, tt.wantLocale)
+			}
+		})
+	}
+}
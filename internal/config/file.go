@@ -0,0 +1,306 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// fileCase is one entry of the cases list in a config file
+type fileCase struct {
+	ID         string   `yaml:"id" toml:"id" json:"id"`
+	Source     string   `yaml:"source" toml:"source" json:"source"`
+	Recipients []string `yaml:"recipients" toml:"recipients" json:"recipients"`
+	Bundle     string   `yaml:"bundle" toml:"bundle" json:"bundle"`
+	Webhooks   []string `yaml:"webhooks" toml:"webhooks" json:"webhooks"`
+	Interval   string   `yaml:"interval" toml:"interval" json:"interval"`
+	Nickname   string   `yaml:"nickname" toml:"nickname" json:"nickname"`
+	FormType   string   `yaml:"form_type" toml:"form_type" json:"form_type"`
+	Applicant  string   `yaml:"applicant" toml:"applicant" json:"applicant"`
+}
+
+// fileTenant is one entry of the tenants list in a config file
+type fileTenant struct {
+	Name           string     `yaml:"name" toml:"name" json:"name"`
+	Cases          []fileCase `yaml:"cases" toml:"cases" json:"cases"`
+	Recipients     []string   `yaml:"recipients" toml:"recipients" json:"recipients"`
+	Webhooks       []string   `yaml:"webhooks" toml:"webhooks" json:"webhooks"`
+	TelegramChatID string     `yaml:"telegram_chat_id" toml:"telegram_chat_id" json:"telegram_chat_id"`
+}
+
+// LoadFromFile loads configuration from a YAML, TOML or JSON file merged with the environment
+// Sections flatten to the environment variable names (poll.interval -> POLL_INTERVAL,
+// telegram.bot_token -> TELEGRAM_BOT_TOKEN) and the cases list replaces CASE_IDS,
+// CASE_SOURCES, CASE_RECIPIENTS, CASE_WEBHOOKS, CASE_SCHEDULE, CASE_NICKNAMES, CASE_FORM_TYPES,
+// CASE_APPLICANTS and CASE_BUNDLES.
+// The tenants list sets TENANTS and TENANT_*, and adds the tenants' cases to the cases list.
+// Variables already set in the environment take precedence over the file
+func LoadFromFile(path string) (*Config, error) {
+	env := processEnvironment()
+	if err := env.applyConfigFile(path); err != nil {
+		return nil, err
+	}
+	return load(env, false)
+}
+
+// applyConfigFile adds the settings of a config file that env doesn't have yet
+func (env environment) applyConfigFile(path string) error {
+	values, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+	for key, value := range values {
+		if _, set := env[key]; !set {
+			env[key] = value
+		}
+	}
+	return nil
+}
+
+// readConfigFile parses a config file into environment variable values
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var doc map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	case ".toml":
+		err = toml.Unmarshal(data, &doc)
+	case ".json":
+		err = json.Unmarshal(data, &doc)
+	default:
+		return nil, fmt.Errorf("unsupported config file %s: use .yaml, .yml, .toml or .json", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	var cases []fileCase
+	rawCases, hasCases := doc["cases"]
+	if hasCases {
+		delete(doc, "cases")
+		if err := decodeList(rawCases, &cases); err != nil {
+			return nil, fmt.Errorf("invalid cases in %s: expected a list of {id, source, recipients, webhooks, interval, nickname, form_type, applicant, bundle}", path)
+		}
+	}
+	if rawTenants, ok := doc["tenants"]; ok {
+		delete(doc, "tenants")
+		tenantCases, err := flattenTenants(rawTenants, values)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tenants in %s: %w", path, err)
+		}
+		cases = append(cases, tenantCases...)
+		hasCases = true
+	}
+	if hasCases {
+		if err := flattenCases(cases, values); err != nil {
+			return nil, fmt.Errorf("invalid cases in %s: %w", path, err)
+		}
+	}
+	if err := flattenSection("", doc, values); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// flattenSection maps nested keys to environment variable names
+// Every resulting name must be a setting the tracker reads, so typos fail loudly
+func flattenSection(prefix string, section map[string]interface{}, values map[string]string) error {
+	for key, raw := range section {
+		name := strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+		if prefix != "" {
+			name = prefix + "_" + name
+		}
+
+		switch v := raw.(type) {
+		case map[string]interface{}:
+			if err := flattenSection(name, v, values); err != nil {
+				return err
+			}
+			continue
+		case nil:
+			continue
+		}
+
+		if !isKnownEnvKey(name) {
+			return fmt.Errorf("unknown setting %q (%s)", strings.ToLower(strings.ReplaceAll(name, "_", ".")), name)
+		}
+		value, err := scalarString(raw)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		values[name] = value
+	}
+	return nil
+}
+
+// decodeList decodes a list of a config file into a slice of structs
+func decodeList(raw interface{}, list interface{}) error {
+	// Round-trip through JSON so YAML, TOML and JSON lists decode the same way
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, list)
+}
+
+// flattenTenants converts the tenants list into TENANTS and the TENANT_* variables and
+// returns the tenants' cases, which join the cases list
+func flattenTenants(raw interface{}, values map[string]string) ([]fileCase, error) {
+	var tenants []fileTenant
+	if err := decodeList(raw, &tenants); err != nil {
+		return nil, fmt.Errorf("expected a list of {name, cases, recipients, webhooks, telegram_chat_id}")
+	}
+
+	var cases []fileCase
+	var entries, recipients, webhooks, chats []string
+	for _, t := range tenants {
+		name := strings.TrimSpace(t.Name)
+		if name == "" {
+			return nil, fmt.Errorf("every tenant needs a name")
+		}
+		var ids []string
+		for _, c := range t.Cases {
+			ids = append(ids, strings.TrimSpace(c.ID))
+		}
+		entries = append(entries, name+"="+strings.Join(ids, ","))
+		cases = append(cases, t.Cases...)
+		if len(t.Recipients) > 0 {
+			recipients = append(recipients, name+"="+strings.Join(t.Recipients, ","))
+		}
+		if len(t.Webhooks) > 0 {
+			webhooks = append(webhooks, name+"="+strings.Join(t.Webhooks, ","))
+		}
+		if t.TelegramChatID != "" {
+			chats = append(chats, name+"="+t.TelegramChatID)
+		}
+	}
+
+	values["TENANTS"] = strings.Join(entries, ";")
+	if len(recipients) > 0 {
+		values["TENANT_RECIPIENTS"] = strings.Join(recipients, ";")
+	}
+	if len(webhooks) > 0 {
+		values["TENANT_WEBHOOK_URLS"] = strings.Join(webhooks, ";")
+	}
+	if len(chats) > 0 {
+		values["TENANT_TELEGRAM_CHATS"] = strings.Join(chats, ";")
+	}
+	return cases, nil
+}
+
+// flattenCases converts the cases list into the CASE_* variables
+func flattenCases(cases []fileCase, values map[string]string) error {
+	var ids, sources, recipients, webhooks, schedule, nicknames, formTypes, applicants []string
+	bundles := make(map[string][]string)
+	for _, c := range cases {
+		id := strings.TrimSpace(c.ID)
+		if id == "" {
+			return fmt.Errorf("every case needs an id")
+		}
+		ids = append(ids, id)
+		if c.Source != "" {
+			sources = append(sources, id+":"+c.Source)
+		}
+		if len(c.Recipients) > 0 {
+			recipients = append(recipients, id+":"+strings.Join(c.Recipients, ","))
+		}
+		if len(c.Webhooks) > 0 {
+			webhooks = append(webhooks, id+":"+strings.Join(c.Webhooks, ","))
+		}
+		if c.Interval != "" {
+			schedule = append(schedule, id+"="+c.Interval)
+		}
+		if c.Nickname != "" {
+			nicknames = append(nicknames, id+"="+c.Nickname)
+		}
+		if c.FormType != "" {
+			formTypes = append(formTypes, id+"="+c.FormType)
+		}
+		if c.Applicant != "" {
+			applicants = append(applicants, id+"="+c.Applicant)
+		}
+		if c.Bundle != "" {
+			bundles[c.Bundle] = append(bundles[c.Bundle], id)
+		}
+	}
+
+	values["CASE_IDS"] = strings.Join(ids, ",")
+	if len(sources) > 0 {
+		values["CASE_SOURCES"] = strings.Join(sources, ";")
+	}
+	if len(recipients) > 0 {
+		values["CASE_RECIPIENTS"] = strings.Join(recipients, ";")
+	}
+	if len(webhooks) > 0 {
+		values["CASE_WEBHOOKS"] = strings.Join(webhooks, ";")
+	}
+	if len(schedule) > 0 {
+		values["CASE_SCHEDULE"] = strings.Join(schedule, ",")
+	}
+	if len(nicknames) > 0 {
+		values["CASE_NICKNAMES"] = strings.Join(nicknames, ",")
+	}
+	if len(formTypes) > 0 {
+		values["CASE_FORM_TYPES"] = strings.Join(formTypes, ",")
+	}
+	if len(applicants) > 0 {
+		values["CASE_APPLICANTS"] = strings.Join(applicants, ",")
+	}
+	if len(bundles) > 0 {
+		names := make([]string, 0, len(bundles))
+		for name := range bundles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		entries := make([]string, 0, len(names))
+		for _, name := range names {
+			entries = append(entries, name+"="+strings.Join(bundles[name], ","))
+		}
+		values["CASE_BUNDLES"] = strings.Join(entries, ";")
+	}
+	return nil
+}
+
+// scalarString formats a setting value; lists become comma-separated
+func scalarString(raw interface{}) (string, error) {
+	switch v := raw.(type) {
+	case string:
+		return v, nil
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			s, err := scalarString(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	case map[string]interface{}:
+		return "", fmt.Errorf("expected a value, got a section")
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+// isKnownEnvKey reports whether the tracker reads an environment variable
+func isKnownEnvKey(key string) bool {
+	for _, known := range knownEnvKeys {
+		if key == known {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,195 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/logging"
+)
+
+// SecretRefPrefix marks a setting whose value is read from GCP Secret Manager, e.g.
+// USCIS_PASSWORD=sm://projects/my-project/secrets/uscis-password
+const SecretRefPrefix = "sm://"
+
+// Google endpoints used to resolve secret references
+const (
+	metadataURL      = "http://metadata.google.internal/computeMetadata/v1/"
+	secretManagerURL = "https://secretmanager.googleapis.com/v1/"
+)
+
+// secretHTTPClient fetches tokens and secrets; the metadata server answers fast or not at all
+var secretHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// resolveSecretRefs replaces every sm:// setting in the environment with the secret's value,
+// including the settings named after a case, tenant or account (CASE_*, TENANT_*,
+// USCIS_ACCOUNT_<N>_*). References take one of these forms (the version defaults to latest):
+//
+//	sm://projects/PROJECT/secrets/NAME[/versions/VERSION]
+//	sm://NAME                 project from GCP_PROJECT_ID or the metadata server
+//
+// It authenticates as the service account of the GCE VM or Cloud Run service, or
+// with gcloud's credentials elsewhere. Resolved values are masked in the logs like any
+// credential setting
+func (env environment) resolveSecretRefs() error {
+	var keys []string
+	for key, value := range env {
+		if strings.HasPrefix(value, SecretRefPrefix) {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	slices.Sort(keys)
+
+	token, err := gcpAccessToken()
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s from Secret Manager: %w", strings.Join(keys, ", "), err)
+	}
+	for _, key := range keys {
+		ref := env[key]
+		name, err := secretVersionName(env, ref)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+		value, err := accessSecret(name, token)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s (%s): %w", key, ref, err)
+		}
+		env[key] = value
+		logging.AddSecret(value)
+	}
+	return nil
+}
+
+// secretVersionName turns a reference into the resource name of a secret version
+func secretVersionName(env environment, ref string) (string, error) {
+	path := strings.Trim(strings.TrimPrefix(ref, SecretRefPrefix), "/")
+	parts := strings.Split(path, "/")
+	switch {
+	case len(parts) == 1 && parts[0] != "":
+		project, err := gcpProject(env)
+		if err != nil {
+			return "", fmt.Errorf("%s has no project: %w", ref, err)
+		}
+		return "projects/" + project + "/secrets/" + parts[0] + "/versions/latest", nil
+	case len(parts) == 4 && parts[0] == "projects" && parts[2] == "secrets" && parts[1] != "" && parts[3] != "":
+		return path + "/versions/latest", nil
+	case len(parts) == 6 && parts[0] == "projects" && parts[2] == "secrets" && parts[4] == "versions" && parts[5] != "":
+		return path, nil
+	}
+	return "", fmt.Errorf("%q is not sm://projects/PROJECT/secrets/NAME[/versions/VERSION] or sm://NAME", ref)
+}
+
+// accessSecret reads the payload of a secret version
+// A trailing newline (from `echo` without -n when the secret was created) is dropped
+func accessSecret(name, token string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, secretManagerURL+name+":access", nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	body, err := doGCPRequest(req)
+	if err != nil {
+		return "", err
+	}
+
+	var version struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(body, &version); err != nil {
+		return "", fmt.Errorf("failed to parse secret: %w", err)
+	}
+	data, err := base64.StdEncoding.DecodeString(version.Payload.Data)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode secret: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// gcpAccessToken returns an access token of the VM or Cloud Run service account, or of
+// the gcloud login when not running on Google Cloud
+func gcpAccessToken() (string, error) {
+	body, metadataErr := metadataGet("instance/service-accounts/default/token")
+	if metadataErr == nil {
+		var token struct {
+			AccessToken string `json:"access_token"`
+		}
+		if err := json.Unmarshal(body, &token); err != nil {
+			return "", fmt.Errorf("failed to parse access token: %w", err)
+		}
+		return token.AccessToken, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "gcloud", "auth", "print-access-token")
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("no credentials: metadata server unavailable (%v) and gcloud failed (%v: %s)", metadataErr, err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// gcpProject returns the project of short secret references
+func gcpProject(env environment) (string, error) {
+	if project := env["GCP_PROJECT_ID"]; project != "" {
+		return project, nil
+	}
+	body, err := metadataGet("project/project-id")
+	if err != nil {
+		return "", fmt.Errorf("set GCP_PROJECT_ID or use sm://projects/PROJECT/secrets/NAME (%v)", err)
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// metadataGet reads a value from the GCE / Cloud Run metadata server
+func metadataGet(path string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, metadataURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	return doGCPRequest(req)
+}
+
+// doGCPRequest sends a request and returns the body of a successful response
+func doGCPRequest(req *http.Request) ([]byte, error) {
+	resp, err := secretHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Error.Message != "" {
+			return nil, fmt.Errorf("status %d: %s", resp.StatusCode, apiErr.Error.Message)
+		}
+		return nil, fmt.Errorf("status %d from %s", resp.StatusCode, (&url.URL{Scheme: req.URL.Scheme, Host: req.URL.Host, Path: req.URL.Path}).String())
+	}
+	return body, nil
+}
@@ -0,0 +1,219 @@
+package config
+
+import (
+	"fmt"
+	"net/mail"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// Tenant is a named owner of some of the tracked cases, e.g. one client of an
+// immigration attorney, with notification channels and a snapshot directory of their own
+type Tenant struct {
+	Name           string
+	CaseIDs        []string
+	Recipients     []string // Email recipients of the tenant's cases, unless a case has CASE_RECIPIENTS
+	Webhooks       []string // Webhooks receiving the tenant's cases
+	TelegramChatID string   // Telegram chat receiving the tenant's cases (with TELEGRAM_BOT_TOKEN)
+}
+
+// tenantNamePattern restricts tenant names to what is safe as a directory name
+var tenantNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// TenantsDir is the directory under STATE_FILE_DIR holding a snapshot directory per tenant
+const TenantsDir = "tenants"
+
+// parseTenants parses TENANTS: "garcia=ID1,ID2;lee=ID3"
+// A case belongs to one tenant at most
+func parseTenants(value string) ([]Tenant, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	var tenants []Tenant
+	assigned := make(map[string]string)
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, idList, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid TENANTS entry %q: expected name=ID1,ID2", entry)
+		}
+		if !tenantNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid TENANTS name %q: use letters, digits, - and _", name)
+		}
+		if slices.ContainsFunc(tenants, func(t Tenant) bool { return t.Name == name }) {
+			return nil, fmt.Errorf("TENANTS lists %q more than once", name)
+		}
+
+		tenant := Tenant{Name: name}
+		for _, id := range strings.Split(idList, ",") {
+			id = uscis.NormalizeReceiptNumber(id)
+			if id == "" {
+				continue
+			}
+			if other, dup := assigned[id]; dup {
+				return nil, fmt.Errorf("TENANTS: %s belongs to both %q and %q", id, other, name)
+			}
+			assigned[id] = name
+			tenant.CaseIDs = append(tenant.CaseIDs, id)
+		}
+		if len(tenant.CaseIDs) == 0 {
+			return nil, fmt.Errorf("TENANTS entry for %q has no case ID", name)
+		}
+		tenants = append(tenants, tenant)
+	}
+	return tenants, nil
+}
+
+// parseTenantLists parses a per-tenant list setting: "garcia=a,b;lee=c"
+// Every name must be in TENANTS
+func parseTenantLists(key, value string, tenants []Tenant) (map[string][]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	lists := make(map[string][]string)
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, list, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid %s entry %q: expected name=value,value", key, entry)
+		}
+		if !slices.ContainsFunc(tenants, func(t Tenant) bool { return t.Name == name }) {
+			return nil, fmt.Errorf("%s references %q which is not in TENANTS", key, name)
+		}
+		if _, dup := lists[name]; dup {
+			return nil, fmt.Errorf("%s lists %q more than once", key, name)
+		}
+		for _, item := range strings.Split(list, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				lists[name] = append(lists[name], item)
+			}
+		}
+		if len(lists[name]) == 0 {
+			return nil, fmt.Errorf("%s entry for %q is empty", key, name)
+		}
+	}
+	return lists, nil
+}
+
+// loadTenantChannels reads where each tenant's notifications go and routes the
+// tenant's cases there: TENANT_RECIPIENTS, TENANT_WEBHOOK_URLS and TENANT_TELEGRAM_CHATS
+func (c *Config) loadTenantChannels(env environment) error {
+	if len(c.Tenants) == 0 {
+		for _, key := range []string{"TENANT_RECIPIENTS", "TENANT_WEBHOOK_URLS", "TENANT_TELEGRAM_CHATS"} {
+			if env[key] != "" {
+				return fmt.Errorf("%s needs TENANTS", key)
+			}
+		}
+		return nil
+	}
+
+	recipients, err := parseTenantLists("TENANT_RECIPIENTS", env["TENANT_RECIPIENTS"], c.Tenants)
+	if err != nil {
+		return err
+	}
+	webhooks, err := parseTenantLists("TENANT_WEBHOOK_URLS", env["TENANT_WEBHOOK_URLS"], c.Tenants)
+	if err != nil {
+		return err
+	}
+	chats, err := parseTenantLists("TENANT_TELEGRAM_CHATS", env["TENANT_TELEGRAM_CHATS"], c.Tenants)
+	if err != nil {
+		return err
+	}
+	if len(chats) > 0 && c.TelegramBotToken == "" {
+		return fmt.Errorf("TENANT_TELEGRAM_CHATS needs TELEGRAM_BOT_TOKEN")
+	}
+
+	for i := range c.Tenants {
+		tenant := &c.Tenants[i]
+		for _, address := range recipients[tenant.Name] {
+			if _, err := mail.ParseAddress(address); err != nil {
+				return fmt.Errorf("invalid TENANT_RECIPIENTS address %q for %s: %w", address, tenant.Name, err)
+			}
+		}
+		for _, hook := range webhooks[tenant.Name] {
+			if err := validateWebhookURL(hook); err != nil {
+				return fmt.Errorf("invalid TENANT_WEBHOOK_URLS entry for %s: %w", tenant.Name, err)
+			}
+		}
+		if len(chats[tenant.Name]) > 1 {
+			return fmt.Errorf("TENANT_TELEGRAM_CHATS lists several chats for %s", tenant.Name)
+		}
+		tenant.Recipients = recipients[tenant.Name]
+		tenant.Webhooks = webhooks[tenant.Name]
+		if len(chats[tenant.Name]) == 1 {
+			tenant.TelegramChatID = chats[tenant.Name][0]
+		}
+
+		// The tenant's channels become per-case routes, which every notification path
+		// already honors; a case's own CASE_RECIPIENTS entry wins
+		for _, caseID := range tenant.CaseIDs {
+			if _, ok := c.CaseRecipients[caseID]; !ok && len(tenant.Recipients) > 0 {
+				if c.CaseRecipients == nil {
+					c.CaseRecipients = make(map[string][]string)
+				}
+				c.CaseRecipients[caseID] = tenant.Recipients
+			}
+			for _, hook := range tenant.Webhooks {
+				if !slices.Contains(c.CaseWebhooks[caseID], hook) {
+					if c.CaseWebhooks == nil {
+						c.CaseWebhooks = make(map[string][]string)
+					}
+					c.CaseWebhooks[caseID] = append(c.CaseWebhooks[caseID], hook)
+				}
+			}
+		}
+	}
+
+	// A bundle is sent as one notification, so it can't mix the cases of several tenants
+	for _, bundle := range c.Bundles {
+		for _, caseID := range bundle.CaseIDs[1:] {
+			if c.TenantFor(caseID) != c.TenantFor(bundle.CaseIDs[0]) {
+				return fmt.Errorf("CASE_BUNDLES bundle %q mixes cases of different tenants", bundle.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// TenantFor returns the tenant a case belongs to, or nil
+func (c *Config) TenantFor(caseID string) *Tenant {
+	for i := range c.Tenants {
+		if slices.Contains(c.Tenants[i].CaseIDs, caseID) {
+			return &c.Tenants[i]
+		}
+	}
+	return nil
+}
+
+// TenantName returns the name of the tenant a case belongs to, or ""
+func (c *Config) TenantName(caseID string) string {
+	if tenant := c.TenantFor(caseID); tenant != nil {
+		return tenant.Name
+	}
+	return ""
+}
+
+// CaseStateDir returns the directory a case's snapshots and metadata are kept in: the
+// tenant's own directory under STATE_FILE_DIR, or STATE_FILE_DIR itself
+// The daemon-wide state (outbox, delivery log, acknowledgements, dead letters) stays
+// in STATE_FILE_DIR whatever the tenant
+func (c *Config) CaseStateDir(caseID string) string {
+	if tenant := c.TenantFor(caseID); tenant != nil {
+		return filepath.Join(c.StateFileDir, TenantsDir, tenant.Name)
+	}
+	return c.StateFileDir
+}
@@ -0,0 +1,165 @@
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+)
+
+// Message is an archived email with its decoded text
+type Message struct {
+	Date    time.Time
+	From    string
+	Subject string
+	Text    string // plain text body (HTML bodies are reduced to text)
+}
+
+// SearchMessages returns the messages in a mailbox whose sender contains from,
+// received on or after since, oldest first
+func (c *IMAPClient) SearchMessages(mailbox, from string, since time.Time) ([]Message, error) {
+	imapClient, err := client.DialTLS(c.server, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to IMAP server: %w", err)
+	}
+	defer imapClient.Logout()
+
+	if err := c.login(imapClient); err != nil {
+		return nil, err
+	}
+	if _, err := imapClient.Select(mailbox, true); err != nil {
+		return nil, fmt.Errorf("failed to select %s: %w", mailbox, err)
+	}
+
+	criteria := imap.NewSearchCriteria()
+	criteria.Header.Add("From", from)
+	criteria.Since = since
+	uids, err := imapClient.UidSearch(criteria)
+	if err != nil {
+		return nil, fmt.Errorf("search failed: %w", err)
+	}
+	if len(uids) == 0 {
+		return nil, nil
+	}
+
+	seqSet := new(imap.SeqSet)
+	seqSet.AddNum(uids...)
+	section := &imap.BodySectionName{Peek: true}
+	items := []imap.FetchItem{imap.FetchEnvelope, section.FetchItem()}
+
+	fetched := make(chan *imap.Message, 16)
+	done := make(chan error, 1)
+	go func() {
+		done <- imapClient.UidFetch(seqSet, items, fetched)
+	}()
+
+	var messages []Message
+	for msg := range fetched {
+		if msg == nil || msg.Envelope == nil {
+			continue
+		}
+		m := Message{Date: msg.Envelope.Date, Subject: msg.Envelope.Subject}
+		if len(msg.Envelope.From) > 0 {
+			m.From = msg.Envelope.From[0].Address()
+		}
+		if literal := msg.GetBody(section); literal != nil {
+			if raw, err := io.ReadAll(literal); err == nil {
+				m.Text = messageText(raw)
+			}
+		}
+		messages = append(messages, m)
+	}
+	if err := <-done; err != nil {
+		return nil, fmt.Errorf("fetch error: %w", err)
+	}
+
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].Date.Before(messages[j].Date)
+	})
+	return messages, nil
+}
+
+var (
+	htmlBreakPattern = regexp.MustCompile(`(?i)<(br|/p|/div|/tr|/li|/h[1-6])[^>]*>`)
+	htmlTagPattern   = regexp.MustCompile(`(?s)<[^>]*>`)
+	htmlStylePattern = regexp.MustCompile(`(?is)<(style|script)[^>]*>.*?</(style|script)>`)
+)
+
+// messageText extracts the readable text of a raw RFC 822 message
+// text/plain is preferred; an HTML-only message is reduced to its text
+func messageText(raw []byte) string {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return string(raw)
+	}
+	plain, htmlBody := partText(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body)
+	if plain != "" {
+		return plain
+	}
+	return htmlToText(htmlBody)
+}
+
+// partText decodes one MIME part, returning its plain and HTML text (descending into multiparts)
+func partText(contentType, encoding string, body io.Reader) (plain, htmlBody string) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err != nil {
+				break
+			}
+			p, h := partText(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part)
+			if plain == "" {
+				plain = p
+			}
+			if htmlBody == "" {
+				htmlBody = h
+			}
+		}
+		return plain, htmlBody
+	}
+
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", ""
+	}
+
+	switch mediaType {
+	case "text/plain":
+		return string(data), ""
+	case "text/html":
+		return "", string(data)
+	}
+	return "", ""
+}
+
+// htmlToText strips tags, keeping line breaks at block boundaries
+func htmlToText(s string) string {
+	s = htmlStylePattern.ReplaceAllString(s, "")
+	s = htmlBreakPattern.ReplaceAllString(s, "\n")
+	s = htmlTagPattern.ReplaceAllString(s, "")
+	return html.UnescapeString(s)
+}
@@ -1,6 +1,8 @@
 package email
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -10,6 +12,8 @@ import (
 
 	"github.com/emersion/go-imap"
 	"github.com/emersion/go-imap/client"
+
+	"github.com/phhowardchen/case-tracker/internal/logging"
 )
 
 // IMAPClient handles fetching 2FA codes from email
@@ -17,6 +21,7 @@ type IMAPClient struct {
 	server   string
 	username string
 	password string
+	oauth    *OAuthTokens // when set, logs in with XOAUTH2 instead of the password
 }
 
 // NewIMAPClient creates a new IMAP client
@@ -28,43 +33,134 @@ func NewIMAPClient(server, username, password string) *IMAPClient {
 	}
 }
 
-// FetchLatest2FACode fetches the latest 2FA verification code from email
-// Polls the inbox until a code is found or timeout is reached
-// The senderEmail parameter is kept for interface compatibility but not used -
-// we search for USCIS emails by checking sender/subject keywords instead
-func (c *IMAPClient) FetchLatest2FACode(senderEmail string, maxWaitTime time.Duration) (string, error) {
+// SetOAuth makes the client log in with OAuth2 access tokens (XOAUTH2) instead of a password,
+// for providers that no longer accept app passwords
+func (c *IMAPClient) SetOAuth(tokens *OAuthTokens) {
+	c.oauth = tokens
+}
+
+// login authenticates an IMAP connection with the password or an OAuth2 access token
+func (c *IMAPClient) login(imapClient *client.Client) error {
+	if c.oauth == nil {
+		if err := imapClient.Login(c.username, c.password); err != nil {
+			return fmt.Errorf("failed to login to IMAP: %w", err)
+		}
+		return nil
+	}
+
+	token, err := c.oauth.AccessToken()
+	if err != nil {
+		return err
+	}
+	if err := imapClient.Authenticate(&xoauth2Client{username: c.username, token: token}); err != nil {
+		// Revoked or expired early: get a fresh one next time
+		c.oauth.Invalidate()
+		return fmt.Errorf("failed to login to IMAP with OAuth2: %w", err)
+	}
+	return nil
+}
+
+// idlePollInterval is how often a server without IDLE support is polled for new mail
+const idlePollInterval = 5 * time.Second
+
+// reconnectDelay is the pause before reconnecting after the mailbox connection failed
+const reconnectDelay = 2 * time.Second
+
+// receivedSlack allows for the mail server's clock running behind ours when comparing
+// the time an email was received (INTERNALDATE, set by the server) with the login start
+const receivedSlack = 30 * time.Second
+
+// DefaultCodeSender is the address USCIS sends the 2FA emails from
+const DefaultCodeSender = "MyAccount@uscis.dhs.gov"
+
+// errNoCode means the wait ended without a 2FA email
+var errNoCode = errors.New("no 2FA email found")
+
+// FetchLatest2FACode fetches the 2FA verification code sent for a login started at since
+// Only emails from senderEmail received after since are read, so a code left over from
+// an earlier login is never used; a sender of the form "@domain" accepts any address
+// there, and without a sender USCIS emails are recognized by sender/subject keywords. It checks the recent emails, then waits in IMAP IDLE so a new
+// email is read within a second of arrival, until a code is found or timeout is reached.
+// A dropped connection is reopened while there is time left. The wait ends early when
+// ctx is done
+func (c *IMAPClient) FetchLatest2FACode(ctx context.Context, senderEmail string, since time.Time, maxWaitTime time.Duration) (string, error) {
 	deadline := time.Now().Add(maxWaitTime)
-	pollInterval := 5 * time.Second
+	filter := codeFilter{sender: senderEmail, since: since.Add(-receivedSlack)}
 
 	log.Printf("Waiting for 2FA email (timeout: %v)...", maxWaitTime)
 
 	for time.Now().Before(deadline) {
-		code, err := c.tryFetchCode()
-		if err == nil && code != "" {
-			log.Printf("Successfully retrieved 2FA code: %s", code)
+		code, err := c.waitForCode(ctx, filter, deadline)
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("stopped waiting for 2FA email: %w", ctx.Err())
+		}
+		if err == nil {
+			logging.AddSecret(code)
+			log.Printf("Successfully retrieved 2FA code")
 			return code, nil
 		}
-
-		// If error is not "not found", log and retry
-		if err != nil && !strings.Contains(err.Error(), "no 2FA email found") {
-			log.Printf("Error fetching 2FA code, retry...: %v", err)
-			continue
+		if errors.Is(err, errNoCode) {
+			break
 		}
 
-		// Wait before retrying
-		remaining := time.Until(deadline)
-		if remaining < pollInterval {
+		log.Printf("Error waiting for 2FA code, reconnecting...: %v", err)
+		if time.Until(deadline) < reconnectDelay {
 			break
 		}
-		log.Printf("No 2FA email yet, waiting %v before retry...", pollInterval)
-		time.Sleep(pollInterval)
+		select {
+		case <-time.After(reconnectDelay):
+		case <-ctx.Done():
+			return "", fmt.Errorf("stopped waiting for 2FA email: %w", ctx.Err())
+		}
 	}
 
 	return "", fmt.Errorf("timeout: no 2FA email received within %v", maxWaitTime)
 }
 
-// tryFetchCode attempts to fetch a 2FA code from recent emails
-func (c *IMAPClient) tryFetchCode() (string, error) {
+// CheckLogin connects and logs in without reading any mail
+func (c *IMAPClient) CheckLogin() error {
+	imapClient, err := client.DialTLS(c.server, nil)
+	if err != nil {
+		return fmt.Errorf("failed to connect to IMAP server: %w", err)
+	}
+	defer imapClient.Logout()
+
+	return c.login(imapClient)
+}
+
+// codeFilter selects the emails a 2FA code may be read from
+type codeFilter struct {
+	sender string    // required From address or @domain, "" to match USCIS emails by keywords
+	since  time.Time // emails received earlier are ignored
+}
+
+// matches reports whether an email may hold the code
+func (f codeFilter) matches(msg *imap.Message) bool {
+	if msg.Envelope == nil || msg.InternalDate.Before(f.since) {
+		return false
+	}
+	var fromAddr string
+	if len(msg.Envelope.From) > 0 {
+		fromAddr = msg.Envelope.From[0].Address()
+	}
+	if strings.HasPrefix(f.sender, "@") {
+		return strings.HasSuffix(strings.ToLower(fromAddr), strings.ToLower(f.sender))
+	}
+	if f.sender != "" {
+		return strings.EqualFold(fromAddr, f.sender)
+	}
+
+	// Check if this is from USCIS (flexible matching)
+	subject := strings.ToLower(msg.Envelope.Subject)
+	return strings.Contains(strings.ToLower(fromAddr), "uscis") ||
+		strings.Contains(subject, "verification") ||
+		strings.Contains(subject, "myaccount") ||
+		strings.Contains(subject, "secure")
+}
+
+// waitForCode reads a 2FA code over one connection: from the last 50 emails, then from
+// each email arriving before the deadline. Returns errNoCode when the deadline passes
+func (c *IMAPClient) waitForCode(ctx context.Context, filter codeFilter, deadline time.Time) (string, error) {
 	// Connect to IMAP server
 	imapClient, err := client.DialTLS(c.server, nil)
 	if err != nil {
@@ -72,9 +168,29 @@ func (c *IMAPClient) tryFetchCode() (string, error) {
 	}
 	defer imapClient.Logout()
 
+	// The client blocks until its updates are read; only new mail matters here
+	updates := make(chan client.Update, 16)
+	newMail := make(chan struct{}, 1)
+	imapClient.Updates = updates
+	go func() {
+		for {
+			select {
+			case update := <-updates:
+				if _, ok := update.(*client.MailboxUpdate); ok {
+					select {
+					case newMail <- struct{}{}:
+					default:
+					}
+				}
+			case <-imapClient.LoggedOut():
+				return
+			}
+		}
+	}()
+
 	// Login
-	if err := imapClient.Login(c.username, c.password); err != nil {
-		return "", fmt.Errorf("failed to login to IMAP: %w", err)
+	if err := c.login(imapClient); err != nil {
+		return "", err
 	}
 
 	// Select INBOX
@@ -83,28 +199,90 @@ func (c *IMAPClient) tryFetchCode() (string, error) {
 		return "", fmt.Errorf("failed to select INBOX: %w", err)
 	}
 
-	// Get the last 50 messages (more reliable than time-based search)
-	maxToCheck := uint32(50)
-	if mbox.Messages < maxToCheck {
-		maxToCheck = mbox.Messages
+	// The code may have arrived before the wait started: check the last 50 messages
+	// (more reliable than time-based search)
+	seen := mbox.Messages
+	if seen > 0 {
+		// seen is unsigned: seen-49 would wrap around below 50 messages
+		first := uint32(1)
+		if seen > 50 {
+			first = seen - 49
+		}
+		code, err := findCode(imapClient, filter, first, seen)
+		if err == nil || !errors.Is(err, errNoCode) {
+			return code, err
+		}
 	}
 
-	if maxToCheck == 0 {
-		return "", fmt.Errorf("no emails in INBOX")
+	for {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return "", errNoCode
+		}
+
+		if err := idleUntil(ctx, imapClient, newMail, remaining); err != nil {
+			return "", err
+		}
+
+		// Messages were expunged meanwhile: new ones are numbered from the new count
+		messages := imapClient.Mailbox().Messages
+		if messages <= seen {
+			seen = messages
+			continue
+		}
+		code, err := findCode(imapClient, filter, seen+1, messages)
+		if err == nil || !errors.Is(err, errNoCode) {
+			return code, err
+		}
+		seen = messages
+	}
+}
+
+// idleUntil waits in IDLE until the mailbox changes, the wait is over or ctx is done
+// Servers without IDLE are polled every idlePollInterval instead
+func idleUntil(ctx context.Context, imapClient *client.Client, newMail <-chan struct{}, wait time.Duration) error {
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- imapClient.Idle(stop, &client.IdleOptions{PollInterval: idlePollInterval})
+	}()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-newMail:
+	case <-timer.C:
+	case <-ctx.Done():
+		close(stop)
+		<-done
+		return ctx.Err()
+	case err := <-done:
+		if err == nil {
+			err = errors.New("IDLE ended unexpectedly")
+		}
+		return fmt.Errorf("failed to wait for new email: %w", err)
 	}
 
-	firstUID := mbox.Messages - maxToCheck + 1
-	lastUID := mbox.Messages
+	close(stop)
+	if err := <-done; err != nil {
+		return fmt.Errorf("failed to end IDLE: %w", err)
+	}
+	return nil
+}
 
+// findCode looks for a 2FA code in the messages from first to last (sequence numbers)
+// that pass the filter, newest first. Returns errNoCode if none of them has one
+func findCode(imapClient *client.Client, filter codeFilter, first, last uint32) (string, error) {
 	seqSet := new(imap.SeqSet)
-	seqSet.AddRange(firstUID, lastUID)
+	seqSet.AddRange(first, last)
 
 	// Fetch email headers and body for these messages
-	messages := make(chan *imap.Message, maxToCheck)
+	messages := make(chan *imap.Message, last-first+1)
 	done := make(chan error, 1)
 
 	items := []imap.FetchItem{
 		imap.FetchEnvelope,
+		imap.FetchInternalDate,
 		(&imap.BodySectionName{}).FetchItem(),
 	}
 
@@ -127,49 +305,30 @@ func (c *IMAPClient) tryFetchCode() (string, error) {
 	// Check messages from most recent to oldest
 	for i := len(allMessages) - 1; i >= 0; i-- {
 		msg := allMessages[i]
-		if msg == nil {
+		if msg == nil || !filter.matches(msg) {
 			continue
 		}
 
-		// Check if this is a USCIS email by sender/subject
-		if msg.Envelope != nil {
-			var fromAddr string
-			if len(msg.Envelope.From) > 0 {
-				fromAddr = msg.Envelope.From[0].Address()
-			}
-			subject := msg.Envelope.Subject
-
-			// Check if this is from USCIS (flexible matching)
-			isUSCIS := strings.Contains(strings.ToLower(fromAddr), "uscis") ||
-				strings.Contains(strings.ToLower(subject), "verification") ||
-				strings.Contains(strings.ToLower(subject), "myaccount") ||
-				strings.Contains(strings.ToLower(subject), "secure")
-
-			if !isUSCIS {
-				continue
-			}
-
-			// Found a USCIS email, try to extract code
-			section := &imap.BodySectionName{}
-			literal := msg.GetBody(section)
-			if literal == nil {
-				continue
-			}
+		// Found a USCIS email, try to extract code
+		section := &imap.BodySectionName{}
+		literal := msg.GetBody(section)
+		if literal == nil {
+			continue
+		}
 
-			bodyBytes, err := io.ReadAll(literal)
-			if err != nil {
-				continue
-			}
+		bodyBytes, err := io.ReadAll(literal)
+		if err != nil {
+			continue
+		}
 
-			code, err := extract2FACode(string(bodyBytes))
-			if err == nil {
-				log.Printf("Found 2FA code from: %s", fromAddr)
-				return code, nil
-			}
+		code, err := extract2FACode(string(bodyBytes))
+		if err == nil {
+			log.Printf("Found 2FA code from: %s (received %s)", msg.Envelope.From[0].Address(), msg.InternalDate.Format(time.RFC3339))
+			return code, nil
 		}
 	}
 
-	return "", fmt.Errorf("no 2FA email found from USCIS in last %d emails", maxToCheck)
+	return "", errNoCode
 }
 
 // extract2FACode extracts a 6-digit verification code from email text
@@ -0,0 +1,259 @@
+package email
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-sasl"
+
+	"github.com/phhowardchen/case-tracker/internal/logging"
+)
+
+// OAuthProvider holds the OAuth2 endpoints and IMAP scope of a mail provider
+type OAuthProvider struct {
+	AuthURL  string
+	TokenURL string
+	Scope    string
+}
+
+// outlookOAuth is Microsoft's OAuth2 setup, for Outlook.com and Microsoft 365 mailboxes
+var outlookOAuth = OAuthProvider{
+	AuthURL:  "https://login.microsoftonline.com/common/oauth2/v2.0/authorize",
+	TokenURL: "https://login.microsoftonline.com/common/oauth2/v2.0/token",
+	Scope:    "https://outlook.office.com/IMAP.AccessAsUser.All offline_access",
+}
+
+// Mail providers with known OAuth2 endpoints, by IMAP host
+var oauthProviders = map[string]OAuthProvider{
+	"imap.gmail.com": {
+		AuthURL:  "https://accounts.google.com/o/oauth2/v2/auth",
+		TokenURL: "https://oauth2.googleapis.com/token",
+		Scope:    "https://mail.google.com/",
+	},
+	"outlook.office365.com": outlookOAuth,
+	"imap-mail.outlook.com": outlookOAuth,
+}
+
+// ProviderFor returns the OAuth2 endpoints of an IMAP server ("host:port")
+func ProviderFor(server string) (OAuthProvider, bool) {
+	host := server
+	if h, _, found := strings.Cut(server, ":"); found {
+		host = h
+	}
+	provider, ok := oauthProviders[strings.ToLower(host)]
+	return provider, ok
+}
+
+// OAuthConfig identifies the OAuth2 client the tracker reads the mailbox as
+type OAuthConfig struct {
+	ClientID     string
+	ClientSecret string // empty for public clients
+	TokenURL     string
+}
+
+// accessTokenMargin renews an access token this long before it expires
+const accessTokenMargin = time.Minute
+
+// OAuthTokens exchanges a refresh token for IMAP access tokens (XOAUTH2)
+// Providers that rotate refresh tokens (Microsoft) send a new one with each access token;
+// it is kept in {stateDir}/email_oauth.json, so the token configured once keeps working.
+// A different configured token (e.g. after revoking the old one) replaces the stored one.
+// It is safe for concurrent use
+type OAuthTokens struct {
+	cfg        OAuthConfig
+	path       string
+	seed       string // hash of the configured refresh token the stored one descends from
+	httpClient *http.Client
+
+	mu           sync.Mutex
+	refreshToken string
+	accessToken  string
+	expiry       time.Time
+}
+
+// storedOAuth is the content of email_oauth.json
+type storedOAuth struct {
+	Seed         string    `json:"seed"`
+	RefreshToken string    `json:"refresh_token"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// NewOAuthTokens creates a token source for a configured refresh token
+// stateDir may be empty to not store rotated refresh tokens
+func NewOAuthTokens(cfg OAuthConfig, refreshToken, stateDir string) *OAuthTokens {
+	sum := sha256.Sum256([]byte(refreshToken))
+	t := &OAuthTokens{
+		cfg:          cfg,
+		seed:         hex.EncodeToString(sum[:]),
+		httpClient:   &http.Client{Timeout: 30 * time.Second},
+		refreshToken: refreshToken,
+	}
+	logging.AddSecret(refreshToken, cfg.ClientSecret)
+	if stateDir == "" {
+		return t
+	}
+
+	t.path = filepath.Join(stateDir, "email_oauth.json")
+	data, err := os.ReadFile(t.path)
+	if err != nil {
+		return t
+	}
+	var stored storedOAuth
+	if json.Unmarshal(data, &stored) == nil && stored.Seed == t.seed && stored.RefreshToken != "" {
+		logging.AddSecret(stored.RefreshToken)
+		t.refreshToken = stored.RefreshToken
+	}
+	return t
+}
+
+// AccessToken returns a valid access token, refreshing it when it is about to expire
+func (t *OAuthTokens) AccessToken() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.accessToken != "" && time.Until(t.expiry) > accessTokenMargin {
+		return t.accessToken, nil
+	}
+	if err := t.refresh(); err != nil {
+		return "", err
+	}
+	return t.accessToken, nil
+}
+
+// Invalidate drops the cached access token, e.g. after the server rejected it
+func (t *OAuthTokens) Invalidate() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.accessToken = ""
+}
+
+// tokenResponse is the token endpoint's answer (RFC 6749 section 5)
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiresIn        int    `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// refresh exchanges the refresh token for a new access token; callers hold mu
+func (t *OAuthTokens) refresh() error {
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {t.refreshToken},
+	}
+	token, err := requestToken(t.httpClient, t.cfg, form)
+	if err != nil {
+		return err
+	}
+
+	t.accessToken = token.AccessToken
+	t.expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	if token.ExpiresIn == 0 {
+		t.expiry = time.Now().Add(time.Hour)
+	}
+	if token.RefreshToken != "" && token.RefreshToken != t.refreshToken {
+		t.refreshToken = token.RefreshToken
+		if err := t.store(); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+	return nil
+}
+
+// store saves a rotated refresh token, readable only by the owner
+func (t *OAuthTokens) store() error {
+	if t.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(storedOAuth{Seed: t.seed, RefreshToken: t.refreshToken, UpdatedAt: time.Now()}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal mailbox refresh token: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(t.path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	tempFile := t.path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0600); err != nil {
+		return fmt.Errorf("failed to write mailbox refresh token: %w", err)
+	}
+	if err := os.Rename(tempFile, t.path); err != nil {
+		return fmt.Errorf("failed to rename mailbox refresh token: %w", err)
+	}
+	return nil
+}
+
+// ExchangeCode trades an authorization code for tokens and returns the refresh token
+// Used once, by the email-auth command, to obtain the token to configure
+func ExchangeCode(cfg OAuthConfig, code, redirectURI, verifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {verifier},
+	}
+	token, err := requestToken(&http.Client{Timeout: 30 * time.Second}, cfg, form)
+	if err != nil {
+		return "", err
+	}
+	if token.RefreshToken == "" {
+		return "", errors.New("the provider returned no refresh token; make sure offline access is granted")
+	}
+	return token.RefreshToken, nil
+}
+
+// requestToken posts a grant to the token endpoint
+func requestToken(httpClient *http.Client, cfg OAuthConfig, form url.Values) (*tokenResponse, error) {
+	form.Set("client_id", cfg.ClientID)
+	if cfg.ClientSecret != "" {
+		form.Set("client_secret", cfg.ClientSecret)
+	}
+	resp, err := httpClient.PostForm(cfg.TokenURL, form)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request mailbox access token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var token tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, fmt.Errorf("failed to parse mailbox token response (status %d): %w", resp.StatusCode, err)
+	}
+	if token.Error != "" {
+		return nil, fmt.Errorf("mailbox token request rejected: %s %s", token.Error, token.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK || token.AccessToken == "" {
+		return nil, fmt.Errorf("unexpected mailbox token response (status %d)", resp.StatusCode)
+	}
+	logging.AddSecret(token.AccessToken, token.RefreshToken)
+	return &token, nil
+}
+
+// xoauth2Client implements the XOAUTH2 SASL mechanism of Gmail and Outlook
+type xoauth2Client struct {
+	username string
+	token    string
+}
+
+// Start sends the user and the bearer token in one go
+func (c *xoauth2Client) Start() (string, []byte, error) {
+	return "XOAUTH2", []byte("user=" + c.username + "\x01auth=Bearer " + c.token + "\x01\x01"), nil
+}
+
+// Next answers the error details a failed authentication sends with an empty response,
+// after which the server reports the failure
+func (c *xoauth2Client) Next(challenge []byte) ([]byte, error) {
+	return []byte{}, nil
+}
+
+var _ sasl.Client = (*xoauth2Client)(nil)
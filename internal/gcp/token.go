@@ -0,0 +1,64 @@
+// Package gcp authenticates to Google Cloud APIs as the service account of the GCE VM
+// or Cloud Run service the tracker runs on
+package gcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// metadataTokenURL serves access tokens of the service account on GCE and Cloud Run
+const metadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// TokenSource hands out access tokens from the metadata server
+type TokenSource struct {
+	httpClient *http.Client
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// NewTokenSource creates a token source that fetches tokens with httpClient
+func NewTokenSource(httpClient *http.Client) *TokenSource {
+	return &TokenSource{httpClient: httpClient}
+}
+
+// Token returns a cached token, refreshing it before it expires
+func (t *TokenSource) Token() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.token != "" && time.Now().Before(t.expiry) {
+		return t.token, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, metadataTokenURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get an access token from the metadata server (gs:// locations need GCE or Cloud Run): %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get an access token from the metadata server: status %d", resp.StatusCode)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to parse access token: %w", err)
+	}
+	t.token = token.AccessToken
+	// Refresh a minute early so a token never expires mid-request
+	t.expiry = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return t.token, nil
+}
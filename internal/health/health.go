@@ -0,0 +1,286 @@
+package health
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CaseHealth describes the polling health of a single case
+type CaseHealth struct {
+	CaseID              string    `json:"case_id"`
+	Source              string    `json:"source,omitempty"` // Where the case is fetched from
+	LastCheck           time.Time `json:"last_check,omitempty"`
+	LastSuccess         time.Time `json:"last_success,omitempty"`
+	LastError           string    `json:"last_error,omitempty"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	FailingSince        time.Time `json:"failing_since,omitzero"` // First failure of the current streak
+	ConsecutiveSkips    int       `json:"consecutive_skips"`      // Cycles that ran out of budget before reaching the case
+	PollLagSeconds      float64   `json:"poll_lag_seconds"`       // Time since the case was last polled (or since startup)
+}
+
+// EndpointStatus is the startup reachability of one USCIS endpoint
+type EndpointStatus struct {
+	URL        string  `json:"url"`
+	Problem    string  `json:"problem,omitempty"` // dns, connect, timeout, tls, blocked or unavailable; empty when reachable
+	Detail     string  `json:"detail,omitempty"`
+	StatusCode int     `json:"status_code,omitempty"`
+	LatencyMS  float64 `json:"latency_ms"`
+}
+
+// Connectivity is the result of the startup connectivity check
+type Connectivity struct {
+	CheckedAt time.Time        `json:"checked_at"`
+	OK        bool             `json:"ok"`
+	Endpoints []EndpointStatus `json:"endpoints"`
+}
+
+// AuthHealth is the state of the USCIS login, from the outcome of authenticated fetches
+type AuthHealth struct {
+	State       string    `json:"state"` // "ok" or "failed"
+	LastSuccess time.Time `json:"last_success,omitzero"`
+	LastFailure time.Time `json:"last_failure,omitzero"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// Status is a point-in-time snapshot of the tracker's health
+type Status struct {
+	StartedAt      time.Time     `json:"started_at"`
+	AuthMode       string        `json:"auth_mode"`
+	FetchStrategy  string        `json:"fetch_strategy,omitempty"`  // How cases are actually fetched (browser, cookie, public)
+	FallbackReason string        `json:"fallback_reason,omitempty"` // Why the configured auth mode isn't in use
+	Auth           *AuthHealth   `json:"auth,omitempty"`            // Nil until a case needing a login was fetched
+	Connectivity   *Connectivity `json:"connectivity,omitempty"`    // Startup check of the USCIS endpoints
+	Cases          []CaseHealth  `json:"cases"`
+}
+
+// PollingFailure describes polling that has been failing for at least d, or returns ""
+// Polling fails when no case was fetched successfully for that long (since startup if
+// none ever was) and at least one case's latest fetch failed; cases for which ignore
+// returns true don't count. Idle polling, e.g. on a standby instance, never fails
+func (s Status) PollingFailure(now time.Time, d time.Duration, ignore func(caseID string) bool) string {
+	var lastSuccess time.Time
+	failing := 0
+	lastError := ""
+	for _, c := range s.Cases {
+		if ignore != nil && ignore(c.CaseID) {
+			continue
+		}
+		if c.LastSuccess.After(lastSuccess) {
+			lastSuccess = c.LastSuccess
+		}
+		if c.ConsecutiveFailures > 0 {
+			failing++
+			lastError = c.LastError
+		}
+	}
+	if failing == 0 {
+		return ""
+	}
+	since := lastSuccess
+	if since.IsZero() {
+		since = s.StartedAt
+	}
+	if now.Sub(since) < d {
+		return ""
+	}
+	if lastSuccess.IsZero() {
+		return fmt.Sprintf("no case fetched successfully since startup %v ago (%d case(s) failing, last error: %s)",
+			now.Sub(since).Round(time.Minute), failing, lastError)
+	}
+	return fmt.Sprintf("no case fetched successfully for %v (%d case(s) failing, last error: %s)",
+		now.Sub(since).Round(time.Minute), failing, lastError)
+}
+
+// Tracker records poll outcomes so they can be reported over HTTP
+// It is safe for concurrent use
+type Tracker struct {
+	mu        sync.RWMutex
+	startedAt time.Time
+	authMode  string
+	strategy  string
+	fallback  string
+	network   *Connectivity
+	auth      *AuthHealth
+	cases     map[string]*CaseHealth
+}
+
+// NewTracker creates a health tracker for the given cases
+func NewTracker(authMode string, caseIDs []string) *Tracker {
+	t := &Tracker{
+		startedAt: time.Now(),
+		authMode:  authMode,
+		cases:     make(map[string]*CaseHealth),
+	}
+	for _, caseID := range caseIDs {
+		t.cases[caseID] = &CaseHealth{CaseID: caseID}
+	}
+	return t
+}
+
+// RecordSuccess marks a successful poll for a case
+func (t *Tracker) RecordSuccess(caseID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	c := t.caseLocked(caseID)
+	now := time.Now()
+	c.LastCheck = now
+	c.LastSuccess = now
+	c.LastError = ""
+	c.ConsecutiveFailures = 0
+	c.FailingSince = time.Time{}
+	c.ConsecutiveSkips = 0
+}
+
+// RecordFailure marks a failed poll for a case
+func (t *Tracker) RecordFailure(caseID string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	c := t.caseLocked(caseID)
+	c.LastCheck = time.Now()
+	c.LastError = err.Error()
+	if c.ConsecutiveFailures == 0 {
+		c.FailingSince = c.LastCheck
+	}
+	c.ConsecutiveFailures++
+	c.ConsecutiveSkips = 0
+}
+
+// RecordAuthSuccess marks a fetch that needed the USCIS login and got through
+func (t *Tracker) RecordAuthSuccess() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	auth := t.authLocked()
+	auth.State = "ok"
+	auth.LastSuccess = time.Now()
+	auth.LastError = ""
+}
+
+// RecordAuthFailure marks a failed login or an expired session
+func (t *Tracker) RecordAuthFailure(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	auth := t.authLocked()
+	auth.State = "failed"
+	auth.LastFailure = time.Now()
+	auth.LastError = err.Error()
+}
+
+// RecordSkipped marks a case that a poll cycle didn't reach within its budget
+func (t *Tracker) RecordSkipped(caseID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.caseLocked(caseID).ConsecutiveSkips++
+}
+
+// SetCaseSource records where a case is fetched from
+func (t *Tracker) SetCaseSource(caseID, source string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.caseLocked(caseID).Source = source
+}
+
+// SetFetchStrategy records how cases are fetched and, after a fallback, why
+func (t *Tracker) SetFetchStrategy(strategy, fallbackReason string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.strategy = strategy
+	t.fallback = fallbackReason
+}
+
+// SetConnectivity records the result of the startup connectivity check
+func (t *Tracker) SetConnectivity(endpoints []EndpointStatus) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	c := &Connectivity{CheckedAt: time.Now(), OK: true, Endpoints: endpoints}
+	for _, e := range endpoints {
+		if e.Problem != "" {
+			c.OK = false
+		}
+	}
+	t.network = c
+}
+
+// NetworkProblem describes a connectivity problem found at startup, or returns ""
+// The problem is considered resolved once any case is fetched successfully
+func (t *Tracker) NetworkProblem() string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if t.network == nil || t.network.OK {
+		return ""
+	}
+	for _, c := range t.cases {
+		if c.LastSuccess.After(t.network.CheckedAt) {
+			return ""
+		}
+	}
+	for _, e := range t.network.Endpoints {
+		if e.Problem != "" {
+			return fmt.Sprintf("%s unreachable (%s): %s", e.URL, e.Problem, e.Detail)
+		}
+	}
+	return ""
+}
+
+// Snapshot returns a copy of the current health status
+func (t *Tracker) Snapshot() Status {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	status := Status{
+		StartedAt:      t.startedAt,
+		AuthMode:       t.authMode,
+		FetchStrategy:  t.strategy,
+		FallbackReason: t.fallback,
+		Connectivity:   t.network,
+		Cases:          make([]CaseHealth, 0, len(t.cases)),
+	}
+	if t.auth != nil {
+		auth := *t.auth
+		status.Auth = &auth
+	}
+	now := time.Now()
+	for _, c := range t.cases {
+		entry := *c
+		lastPolled := c.LastCheck
+		if lastPolled.IsZero() {
+			lastPolled = t.startedAt
+		}
+		entry.PollLagSeconds = now.Sub(lastPolled).Seconds()
+		status.Cases = append(status.Cases, entry)
+	}
+	sort.Slice(status.Cases, func(i, j int) bool {
+		return status.Cases[i].CaseID < status.Cases[j].CaseID
+	})
+	return status
+}
+
+// caseLocked returns the entry for a case, creating it if needed
+// Caller must hold the write lock
+func (t *Tracker) caseLocked(caseID string) *CaseHealth {
+	c, ok := t.cases[caseID]
+	if !ok {
+		c = &CaseHealth{CaseID: caseID}
+		t.cases[caseID] = c
+	}
+	return c
+}
+
+// authLocked returns the login state, creating it if needed
+// Caller must hold the write lock
+func (t *Tracker) authLocked() *AuthHealth {
+	if t.auth == nil {
+		t.auth = &AuthHealth{}
+	}
+	return t.auth
+}
@@ -0,0 +1,161 @@
+package lease
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/gcp"
+)
+
+// errPreconditionFailed is returned when the object changed since it was read
+var errPreconditionFailed = errors.New("lease object changed concurrently")
+
+// gcsLocker keeps the lease as a Cloud Storage object, changed only with a generation
+// precondition so two instances can't both take it
+// It authenticates as the service account of the GCE VM or Cloud Run service, which
+// needs read and write access to the object
+type gcsLocker struct {
+	httpClient *http.Client
+	bucket     string
+	object     string
+	tokens     *gcp.TokenSource
+}
+
+// newGCSLocker creates a locker for an object in a bucket
+func newGCSLocker(bucket, object string) *gcsLocker {
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+	return &gcsLocker{
+		httpClient: httpClient,
+		bucket:     bucket,
+		object:     object,
+		tokens:     gcp.NewTokenSource(httpClient),
+	}
+}
+
+// Acquire takes or extends the lease
+// Expiry is judged by each instance's own clock, which is fine for leases of a minute or more
+func (g *gcsLocker) Acquire(holder, hostname string, ttl time.Duration) (Lease, bool, error) {
+	current, generation, err := g.read()
+	if err != nil {
+		return Lease{}, false, err
+	}
+	now := time.Now()
+	if current.Holder != "" && current.Holder != holder && !current.Expired(now) {
+		return current, false, nil
+	}
+
+	next := Lease{Holder: holder, Hostname: hostname, Expires: now.Add(ttl)}
+	data, err := json.Marshal(next)
+	if err != nil {
+		return Lease{}, false, fmt.Errorf("failed to marshal lease: %w", err)
+	}
+	req, err := g.request(http.MethodPut, generation, data)
+	if err != nil {
+		return Lease{}, false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Cache-Control", "no-store")
+	_, _, err = g.do(req)
+	if errors.Is(err, errPreconditionFailed) {
+		// Another instance wrote the lease in between; it has it now
+		current, _, err := g.read()
+		return current, false, err
+	}
+	if err != nil {
+		return Lease{}, false, fmt.Errorf("failed to write lease gs://%s/%s: %w", g.bucket, g.object, err)
+	}
+	return next, true, nil
+}
+
+// Release deletes the lease object if holder has it
+func (g *gcsLocker) Release(holder string) error {
+	current, generation, err := g.read()
+	if err != nil || current.Holder != holder {
+		return err
+	}
+	req, err := g.request(http.MethodDelete, generation, nil)
+	if err != nil {
+		return err
+	}
+	if _, _, err := g.do(req); err != nil && !errors.Is(err, errPreconditionFailed) {
+		return fmt.Errorf("failed to delete lease gs://%s/%s: %w", g.bucket, g.object, err)
+	}
+	return nil
+}
+
+// String returns the object as a gs:// URL
+func (g *gcsLocker) String() string {
+	return "gs://" + g.bucket + "/" + g.object
+}
+
+// read returns the lease and the object generation, 0 when there is no object
+func (g *gcsLocker) read() (Lease, string, error) {
+	var current Lease
+	req, err := g.request(http.MethodGet, "", nil)
+	if err != nil {
+		return current, "", err
+	}
+	data, header, err := g.do(req)
+	if err != nil {
+		return current, "", fmt.Errorf("failed to read lease gs://%s/%s: %w", g.bucket, g.object, err)
+	}
+	if header == nil {
+		return current, "0", nil
+	}
+	if err := json.Unmarshal(data, &current); err != nil {
+		return current, "", fmt.Errorf("failed to parse lease gs://%s/%s: %w", g.bucket, g.object, err)
+	}
+	return current, header.Get("x-goog-generation"), nil
+}
+
+// request creates a request for the object, conditional on its generation if given
+func (g *gcsLocker) request(method, generation string, body []byte) (*http.Request, error) {
+	u := url.URL{Scheme: "https", Host: "storage.googleapis.com", Path: "/" + g.bucket + "/" + g.object}
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create lease request: %w", err)
+	}
+	if generation != "" {
+		req.Header.Set("x-goog-if-generation-match", generation)
+	}
+	return req, nil
+}
+
+// do sends an authenticated request and returns the response body and headers
+// A missing object returns no error and nil headers
+func (g *gcsLocker) do(req *http.Request) ([]byte, http.Header, error) {
+	token, err := g.tokens.Token()
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, nil, nil
+	case resp.StatusCode == http.StatusPreconditionFailed:
+		return nil, nil, errPreconditionFailed
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		if len(body) > 500 {
+			body = body[:500]
+		}
+		return nil, nil, fmt.Errorf("status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+	return body, resp.Header, nil
+}
@@ -0,0 +1,172 @@
+// Package lease elects the one instance that polls when several run at once, e.g. two
+// Cloud Run instances during a deploy or a local run next to the VM
+// The holder renews its lease well before it expires; when it stops renewing (crash,
+// shutdown, lost network) the lease runs out and a standby takes over
+package lease
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Lease says which instance holds a lock and until when
+type Lease struct {
+	Holder   string    `json:"holder"` // instance ID
+	Hostname string    `json:"hostname"`
+	Expires  time.Time `json:"expires"`
+}
+
+// Expired reports whether the lease ran out at the given time
+func (l Lease) Expired(now time.Time) bool {
+	return !now.Before(l.Expires)
+}
+
+// Locker keeps one lease that at most one holder has at a time
+type Locker interface {
+	// Acquire takes the lease for holder until ttl from now, or extends it if holder
+	// already has it. While another holder's lease runs it returns that lease and false
+	Acquire(holder, hostname string, ttl time.Duration) (Lease, bool, error)
+	// Release ends holder's lease early so a standby can take over at once
+	Release(holder string) error
+	// String describes where the lease is kept, for logs
+	String() string
+}
+
+// Open returns the lease at a location: "gs://bucket/object" for Google Cloud Storage,
+// otherwise a local file (which may be on a volume the instances share)
+func Open(location string) (Locker, error) {
+	switch {
+	case location == "":
+		return nil, errors.New("no lease location configured")
+	case strings.HasPrefix(location, "gs://"):
+		bucket, object, _ := strings.Cut(strings.TrimPrefix(location, "gs://"), "/")
+		if bucket == "" || strings.Trim(object, "/") == "" {
+			return nil, fmt.Errorf("invalid lease location %q: expected gs://bucket/object", location)
+		}
+		return newGCSLocker(bucket, strings.Trim(object, "/")), nil
+	case strings.Contains(location, "://"):
+		return nil, fmt.Errorf("unsupported lease location %q: use gs://bucket/object or a file path", location)
+	default:
+		return &fileLocker{path: location}, nil
+	}
+}
+
+// Guard file timings of fileLocker
+const (
+	guardWait  = 5 * time.Second  // how long to wait for another process's guard
+	guardStale = 30 * time.Second // a guard this old was left by a crashed process
+)
+
+// fileLocker keeps the lease as a JSON file
+// Changes are made under a guard file created exclusively, which works on any OS and
+// on network filesystems, so instances on one host or sharing a volume can compete
+type fileLocker struct {
+	path string
+}
+
+// Acquire takes or extends the lease
+func (f *fileLocker) Acquire(holder, hostname string, ttl time.Duration) (Lease, bool, error) {
+	var result Lease
+	var acquired bool
+	err := f.guarded(func() error {
+		current, err := f.read()
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if current.Holder != "" && current.Holder != holder && !current.Expired(now) {
+			result = current
+			return nil
+		}
+		result = Lease{Holder: holder, Hostname: hostname, Expires: now.Add(ttl)}
+		acquired = true
+		return f.write(result)
+	})
+	return result, acquired, err
+}
+
+// Release removes the lease file if holder has it
+func (f *fileLocker) Release(holder string) error {
+	return f.guarded(func() error {
+		current, err := f.read()
+		if err != nil || current.Holder != holder {
+			return err
+		}
+		if err := os.Remove(f.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove lease file: %w", err)
+		}
+		return nil
+	})
+}
+
+// String returns the lease file path
+func (f *fileLocker) String() string {
+	return f.path
+}
+
+// guarded runs fn while holding the guard file
+func (f *fileLocker) guarded(fn func() error) error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return fmt.Errorf("failed to create lease directory: %w", err)
+	}
+	guard := f.path + ".guard"
+	deadline := time.Now().Add(guardWait)
+	for {
+		file, err := os.OpenFile(guard, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			file.Close()
+			break
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("failed to create lease guard: %w", err)
+		}
+		if info, statErr := os.Stat(guard); statErr == nil && time.Since(info.ModTime()) > guardStale {
+			os.Remove(guard)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for lease guard %s", guard)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	defer os.Remove(guard)
+	return fn()
+}
+
+// read returns the lease in the file, or a zero lease without one
+func (f *fileLocker) read() (Lease, error) {
+	var current Lease
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return current, nil
+	}
+	if err != nil {
+		return current, fmt.Errorf("failed to read lease file: %w", err)
+	}
+	if err := json.Unmarshal(data, &current); err != nil {
+		// A torn write can't happen (see write), so this is someone else's file
+		return current, fmt.Errorf("failed to parse lease file %s: %w", f.path, err)
+	}
+	return current, nil
+}
+
+// write replaces the lease file atomically
+func (f *fileLocker) write(l Lease) error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal lease: %w", err)
+	}
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write lease file: %w", err)
+	}
+	if err := os.Rename(tmp, f.path); err != nil {
+		return fmt.Errorf("failed to rename lease file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package locale
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	_ "time/tzdata" // Embed the zone database; the runtime image may not ship one
+)
+
+// DefaultTimezone is used when nothing else is configured
+// USCIS reports case dates in US Eastern time, so that is the most faithful fallback
+const DefaultTimezone = "America/New_York"
+
+// DefaultLocale is used when neither LOCALE nor LANG say otherwise
+const DefaultLocale = "en-US"
+
+// Settings controls how dates are rendered for one recipient
+type Settings struct {
+	Location *time.Location
+	Locale   string // BCP 47 tag, e.g. "en-US", "zh-TW"
+}
+
+// layouts holds the date and date-time layouts per locale
+var layouts = map[string][2]string{
+	"en-US": {"Jan 2, 2006", "Jan 2, 2006 3:04 PM MST"},
+	"en-GB": {"2 Jan 2006", "2 Jan 2006 15:04 MST"},
+	"en-IN": {"2 Jan 2006", "2 Jan 2006 3:04 PM MST"},
+	"en-CA": {"2006-01-02", "2006-01-02 3:04 PM MST"},
+	"de-DE": {"02.01.2006", "02.01.2006 15:04 MST"},
+	"fr-FR": {"02/01/2006", "02/01/2006 15:04 MST"},
+	"es-ES": {"02/01/2006", "02/01/2006 15:04 MST"},
+	"es-MX": {"02/01/2006", "02/01/2006 15:04 MST"},
+	"pt-BR": {"02/01/2006", "02/01/2006 15:04 MST"},
+	"zh-CN": {"2006年1月2日", "2006年1月2日 15:04 MST"},
+	"zh-TW": {"2006年1月2日", "2006年1月2日 15:04 MST"},
+	"ja-JP": {"2006年1月2日", "2006年1月2日 15:04 MST"},
+	"ko-KR": {"2006년 1월 2일", "2006년 1월 2일 15:04 MST"},
+	"vi-VN": {"02/01/2006", "02/01/2006 15:04 MST"},
+	"hi-IN": {"2 Jan 2006", "2 Jan 2006 3:04 PM MST"},
+}
+
+// languageDefaults maps a bare language to the locale used for its layouts
+var languageDefaults = map[string]string{
+	"en": "en-US",
+	"de": "de-DE",
+	"fr": "fr-FR",
+	"es": "es-ES",
+	"pt": "pt-BR",
+	"zh": "zh-CN",
+	"ja": "ja-JP",
+	"ko": "ko-KR",
+	"vi": "vi-VN",
+	"hi": "hi-IN",
+}
+
+// New builds settings from a timezone name and locale tag
+func New(timezone, locale string) (Settings, error) {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return Settings{}, fmt.Errorf("invalid timezone %q: %w", timezone, err)
+	}
+	return Settings{Location: loc, Locale: Normalize(locale)}, nil
+}
+
+// Configured returns the timezone and locale as set, explicitly or else through TZ and
+// LC_ALL/LANG, with "" for what isn't set
+func Configured(timezone, locale string) (string, string) {
+	if timezone == "" {
+		timezone = os.Getenv("TZ")
+	}
+	if locale == "" {
+		for _, key := range []string{"LC_ALL", "LANG"} {
+			if value := os.Getenv(key); value != "" && value != "C" && value != "POSIX" && !strings.HasPrefix(value, "C.") {
+				locale = value
+				break
+			}
+		}
+	}
+	return timezone, locale
+}
+
+// Detect infers settings from the environment, in order of precedence:
+// the explicit timezone/locale, then TZ and LC_ALL/LANG, then the defaults
+// A timezone that is set is kept as it is, UTC included
+func Detect(timezone, locale string) (Settings, error) {
+	timezone, locale = Configured(timezone, locale)
+	if timezone == "" {
+		// Containers run in UTC without TZ; that says nothing about the user
+		timezone = DefaultTimezone
+	}
+	if locale == "" {
+		locale = DefaultLocale
+	}
+	return New(timezone, locale)
+}
+
+// Normalize converts POSIX locale names ("zh_TW.UTF-8") to BCP 47 tags ("zh-TW")
+func Normalize(locale string) string {
+	locale = strings.TrimSpace(locale)
+	if i := strings.IndexAny(locale, ".@"); i >= 0 {
+		locale = locale[:i]
+	}
+	locale = strings.ReplaceAll(locale, "_", "-")
+
+	parts := strings.SplitN(locale, "-", 2)
+	if parts[0] == "" {
+		return DefaultLocale
+	}
+	if len(parts) == 1 {
+		return strings.ToLower(parts[0])
+	}
+	return strings.ToLower(parts[0]) + "-" + strings.ToUpper(parts[1])
+}
+
+// layout returns the layouts for the locale, falling back by language and then to en-US
+func (s Settings) layout() [2]string {
+	if l, ok := layouts[s.Locale]; ok {
+		return l
+	}
+	language, _, _ := strings.Cut(s.Locale, "-")
+	if tag, ok := languageDefaults[language]; ok {
+		return layouts[tag]
+	}
+	return layouts[DefaultLocale]
+}
+
+// In converts a time to the recipient's timezone
+func (s Settings) In(t time.Time) time.Time {
+	if s.Location == nil {
+		return t
+	}
+	return t.In(s.Location)
+}
+
+// FormatDate renders a calendar date
+// Dates parsed from USCIS data carry no meaningful time of day, so they are not shifted
+func (s Settings) FormatDate(t time.Time) string {
+	return t.Format(s.layout()[0])
+}
+
+// FormatDateTime renders an instant in the recipient's timezone
+func (s Settings) FormatDateTime(t time.Time) string {
+	return s.In(t).Format(s.layout()[1])
+}
+
+// String describes the settings for logs
+func (s Settings) String() string {
+	name := "Local"
+	if s.Location != nil {
+		name = s.Location.String()
+	}
+	return fmt.Sprintf("%s, %s", name, s.Locale)
+}
@@ -0,0 +1,96 @@
+package locale
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name         string
+		timezone     string
+		locale       string
+		env          map[string]string
+		wantTimezone string
+		wantLocale   string
+		wantErr      bool
+	}{
+		{name: "nothing set", wantTimezone: DefaultTimezone, wantLocale: DefaultLocale},
+		{name: "explicit", timezone: "Asia/Taipei", locale: "zh-TW", wantTimezone: "Asia/Taipei", wantLocale: "zh-TW"},
+		{name: "explicit UTC is kept", timezone: "UTC", wantTimezone: "UTC", wantLocale: DefaultLocale},
+		{name: "TZ UTC is kept", env: map[string]string{"TZ": "UTC"}, wantTimezone: "UTC", wantLocale: DefaultLocale},
+		{name: "Etc/UTC is kept", env: map[string]string{"TZ": "Etc/UTC"}, wantTimezone: "Etc/UTC", wantLocale: DefaultLocale},
+		{
+			name:         "explicit wins over the environment",
+			timezone:     "Europe/Berlin",
+			locale:       "de-DE",
+			env:          map[string]string{"TZ": "America/Chicago", "LANG": "fr_FR.UTF-8"},
+			wantTimezone: "Europe/Berlin",
+			wantLocale:   "de-DE",
+		},
+		{name: "LANG", env: map[string]string{"LANG": "zh_TW.UTF-8"}, wantTimezone: DefaultTimezone, wantLocale: "zh-TW"},
+		{name: "LC_ALL wins over LANG", env: map[string]string{"LC_ALL": "de_DE.UTF-8", "LANG": "fr_FR.UTF-8"}, wantTimezone: DefaultTimezone, wantLocale: "de-DE"},
+		{name: "C locale is ignored", env: map[string]string{"LC_ALL": "C.UTF-8", "LANG": "POSIX"}, wantTimezone: DefaultTimezone, wantLocale: DefaultLocale},
+		{name: "unknown timezone", timezone: "Mars/Olympus", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"TZ", "LC_ALL", "LANG"} {
+				t.Setenv(key, tt.env[key])
+			}
+			got, err := Detect(tt.timezone, tt.locale)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Detect(%q, %q) error = %v, wantErr %v", tt.timezone, tt.locale, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Location.String() != tt.wantTimezone || got.Locale != tt.wantLocale {
+				t.Errorf("Detect(%q, %q) = %s, want %s, %s", tt.timezone, tt.locale, got, tt.wantTimezone, tt.wantLocale)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{locale: "en-US", want: "en-US"},
+		{locale: "zh_TW.UTF-8", want: "zh-TW"},
+		{locale: "de_DE@euro", want: "de-DE"},
+		{locale: "ES", want: "es"},
+		{locale: " pt_br ", want: "pt-BR"},
+		{locale: "", want: DefaultLocale},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.locale); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.locale, got, tt.want)
+		}
+	}
+}
+
+func TestFormatDateTime(t *testing.T) {
+	instant := time.Date(2025, 10, 11, 19, 4, 0, 0, time.UTC)
+	tests := []struct {
+		timezone string
+		locale   string
+		want     string
+	}{
+		{timezone: "America/New_York", locale: "en-US", want: "Oct 11, 2025 3:04 PM EDT"},
+		{timezone: "Europe/Berlin", locale: "de-DE", want: "11.10.2025 21:04 CEST"},
+		{timezone: "Asia/Taipei", locale: "zh-TW", want: "2025年10月12日 03:04 CST"},
+		// Unknown regions fall back to the language's layout
+		{timezone: "UTC", locale: "es-AR", want: "11/10/2025 19:04 UTC"},
+	}
+	for _, tt := range tests {
+		settings, err := New(tt.timezone, tt.locale)
+		if err != nil {
+			t.Fatalf("New(%q, %q): %v", tt.timezone, tt.locale, err)
+		}
+		if got := settings.FormatDateTime(instant); got != tt.want {
+			t.Errorf("FormatDateTime in %s = %q, want %q", settings, got, tt.want)
+		}
+	}
+}
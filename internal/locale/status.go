@@ -0,0 +1,260 @@
+package locale
+
+import (
+	"log"
+	"strings"
+	"sync"
+)
+
+// statusTranslations translates common USCIS status titles, keyed by locale and English title
+// Titles are matched case-insensitively, so USCIS capitalization changes don't matter
+var statusTranslations = map[string]map[string]string{
+	"zh-CN": {
+		"Case Was Received": "已收到案件",
+		"Case Was Received And A Receipt Notice Was Sent":        "已收到案件并已寄出收据通知",
+		"Case Was Received And A Receipt Notice Was Emailed":     "已收到案件并已通过电子邮件发送收据通知",
+		"Fingerprint Fee Was Received":                           "已收到指纹费",
+		"Case Was Updated To Show Fingerprints Were Taken":       "案件已更新，显示已采集指纹",
+		"Case Is Being Actively Reviewed By USCIS":               "USCIS 正在积极审理案件",
+		"Case Is Ready To Be Scheduled For An Interview":         "案件已可安排面谈",
+		"Interview Was Scheduled":                                "已安排面谈",
+		"Interview Was Rescheduled":                              "面谈已改期",
+		"Interview Was Completed And My Case Must Be Reviewed":   "面谈已完成，案件待审查",
+		"Request for Evidence Was Sent":                          "已发出补充证据要求（RFE）",
+		"Request for Initial Evidence Was Sent":                  "已发出初始证据要求",
+		"Response To USCIS' Request For Evidence Was Received":   "已收到对补充证据要求的回复",
+		"Case Was Transferred And A New Office Has Jurisdiction": "案件已转移，由新办公室管辖",
+		"Case Was Approved":                                      "案件已批准",
+		"Case Was Approved And My Decision Was Emailed":          "案件已批准，决定已通过电子邮件发送",
+		"Case Was Denied":                                        "案件被拒绝",
+		"Case Was Rejected":                                      "案件被退回",
+		"Case Was Reopened":                                      "案件已重新开启",
+		"New Card Is Being Produced":                             "新卡正在制作中",
+		"Card Is Being Produced":                                 "卡片正在制作中",
+		"Card Was Mailed To Me":                                  "卡片已寄出",
+		"Card Was Picked Up By The United States Postal Service": "卡片已由美国邮政取件",
+		"Card Was Delivered To Me By The Post Office":            "卡片已由邮局投递",
+		"Document Was Mailed To Me":                              "文件已寄出",
+		"Notice Explaining USCIS Actions Was Mailed":             "已寄出说明 USCIS 处理措施的通知",
+		"Oath Ceremony Was Scheduled":                            "已安排入籍宣誓仪式",
+		"Case Was Sent To The Department of State":               "案件已转交美国国务院",
+	},
+	"zh-TW": {
+		"Case Was Received": "已收到案件",
+		"Case Was Received And A Receipt Notice Was Sent":        "已收到案件並已寄出收據通知",
+		"Case Was Received And A Receipt Notice Was Emailed":     "已收到案件並已透過電子郵件寄送收據通知",
+		"Fingerprint Fee Was Received":                           "已收到指紋費",
+		"Case Was Updated To Show Fingerprints Were Taken":       "案件已更新，顯示已採集指紋",
+		"Case Is Being Actively Reviewed By USCIS":               "USCIS 正在積極審理案件",
+		"Case Is Ready To Be Scheduled For An Interview":         "案件已可安排面談",
+		"Interview Was Scheduled":                                "已安排面談",
+		"Interview Was Rescheduled":                              "面談已改期",
+		"Interview Was Completed And My Case Must Be Reviewed":   "面談已完成，案件待審查",
+		"Request for Evidence Was Sent":                          "已發出補件要求（RFE）",
+		"Request for Initial Evidence Was Sent":                  "已發出初始證據要求",
+		"Response To USCIS' Request For Evidence Was Received":   "已收到對補件要求的回覆",
+		"Case Was Transferred And A New Office Has Jurisdiction": "案件已轉移，由新辦公室管轄",
+		"Case Was Approved":                                      "案件已核准",
+		"Case Was Approved And My Decision Was Emailed":          "案件已核准，決定已透過電子郵件寄送",
+		"Case Was Denied":                                        "案件遭拒絕",
+		"Case Was Rejected":                                      "案件遭退件",
+		"Case Was Reopened":                                      "案件已重新開啟",
+		"New Card Is Being Produced":                             "新卡正在製作中",
+		"Card Is Being Produced":                                 "卡片正在製作中",
+		"Card Was Mailed To Me":                                  "卡片已寄出",
+		"Card Was Picked Up By The United States Postal Service": "卡片已由美國郵政取件",
+		"Card Was Delivered To Me By The Post Office":            "卡片已由郵局投遞",
+		"Document Was Mailed To Me":                              "文件已寄出",
+		"Notice Explaining USCIS Actions Was Mailed":             "已寄出說明 USCIS 處理措施的通知",
+		"Oath Ceremony Was Scheduled":                            "已安排入籍宣誓典禮",
+		"Case Was Sent To The Department of State":               "案件已轉交美國國務院",
+	},
+	"es": {
+		"Case Was Received": "Se recibió el caso",
+		"Case Was Received And A Receipt Notice Was Sent":        "Se recibió el caso y se envió un aviso de recibo",
+		"Case Was Received And A Receipt Notice Was Emailed":     "Se recibió el caso y se envió un aviso de recibo por correo electrónico",
+		"Fingerprint Fee Was Received":                           "Se recibió el pago de huellas dactilares",
+		"Case Was Updated To Show Fingerprints Were Taken":       "El caso se actualizó para indicar que se tomaron las huellas dactilares",
+		"Case Is Being Actively Reviewed By USCIS":               "USCIS está revisando activamente el caso",
+		"Case Is Ready To Be Scheduled For An Interview":         "El caso está listo para programar una entrevista",
+		"Interview Was Scheduled":                                "Se programó una entrevista",
+		"Interview Was Rescheduled":                              "Se reprogramó la entrevista",
+		"Interview Was Completed And My Case Must Be Reviewed":   "La entrevista se completó y el caso debe ser revisado",
+		"Request for Evidence Was Sent":                          "Se envió una solicitud de evidencia (RFE)",
+		"Request for Initial Evidence Was Sent":                  "Se envió una solicitud de evidencia inicial",
+		"Response To USCIS' Request For Evidence Was Received":   "Se recibió la respuesta a la solicitud de evidencia de USCIS",
+		"Case Was Transferred And A New Office Has Jurisdiction": "El caso fue transferido y otra oficina tiene jurisdicción",
+		"Case Was Approved":                                      "El caso fue aprobado",
+		"Case Was Approved And My Decision Was Emailed":          "El caso fue aprobado y la decisión se envió por correo electrónico",
+		"Case Was Denied":                                        "El caso fue denegado",
+		"Case Was Rejected":                                      "El caso fue rechazado",
+		"Case Was Reopened":                                      "El caso fue reabierto",
+		"New Card Is Being Produced":                             "Se está produciendo una nueva tarjeta",
+		"Card Is Being Produced":                                 "Se está produciendo la tarjeta",
+		"Card Was Mailed To Me":                                  "La tarjeta fue enviada por correo",
+		"Card Was Picked Up By The United States Postal Service": "El Servicio Postal de EE. UU. recogió la tarjeta",
+		"Card Was Delivered To Me By The Post Office":            "La oficina de correos entregó la tarjeta",
+		"Document Was Mailed To Me":                              "El documento fue enviado por correo",
+		"Notice Explaining USCIS Actions Was Mailed":             "Se envió un aviso que explica las acciones de USCIS",
+		"Oath Ceremony Was Scheduled":                            "Se programó la ceremonia de juramentación",
+		"Case Was Sent To The Department of State":               "El caso fue enviado al Departamento de Estado",
+	},
+	"pt": {
+		"Case Was Received": "O caso foi recebido",
+		"Case Was Received And A Receipt Notice Was Sent":        "O caso foi recebido e um aviso de recebimento foi enviado",
+		"Case Was Received And A Receipt Notice Was Emailed":     "O caso foi recebido e um aviso de recebimento foi enviado por e-mail",
+		"Fingerprint Fee Was Received":                           "A taxa de impressões digitais foi recebida",
+		"Case Was Updated To Show Fingerprints Were Taken":       "O caso foi atualizado para mostrar que as impressões digitais foram coletadas",
+		"Case Is Being Actively Reviewed By USCIS":               "O caso está sendo analisado ativamente pelo USCIS",
+		"Case Is Ready To Be Scheduled For An Interview":         "O caso está pronto para o agendamento de uma entrevista",
+		"Interview Was Scheduled":                                "A entrevista foi agendada",
+		"Interview Was Rescheduled":                              "A entrevista foi reagendada",
+		"Interview Was Completed And My Case Must Be Reviewed":   "A entrevista foi concluída e o caso deve ser analisado",
+		"Request for Evidence Was Sent":                          "Foi enviado um pedido de evidências (RFE)",
+		"Request for Initial Evidence Was Sent":                  "Foi enviado um pedido de evidências iniciais",
+		"Response To USCIS' Request For Evidence Was Received":   "A resposta ao pedido de evidências do USCIS foi recebida",
+		"Case Was Transferred And A New Office Has Jurisdiction": "O caso foi transferido e um novo escritório tem jurisdição",
+		"Case Was Approved":                                      "O caso foi aprovado",
+		"Case Was Approved And My Decision Was Emailed":          "O caso foi aprovado e a decisão foi enviada por e-mail",
+		"Case Was Denied":                                        "O caso foi negado",
+		"Case Was Rejected":                                      "O caso foi rejeitado",
+		"Case Was Reopened":                                      "O caso foi reaberto",
+		"New Card Is Being Produced":                             "Um novo cartão está sendo produzido",
+		"Card Is Being Produced":                                 "O cartão está sendo produzido",
+		"Card Was Mailed To Me":                                  "O cartão foi enviado pelo correio",
+		"Card Was Picked Up By The United States Postal Service": "O cartão foi retirado pelo Serviço Postal dos EUA",
+		"Card Was Delivered To Me By The Post Office":            "O cartão foi entregue pelos correios",
+		"Document Was Mailed To Me":                              "O documento foi enviado pelo correio",
+		"Notice Explaining USCIS Actions Was Mailed":             "Foi enviado um aviso explicando as ações do USCIS",
+		"Oath Ceremony Was Scheduled":                            "A cerimônia de juramento foi agendada",
+		"Case Was Sent To The Department of State":               "O caso foi enviado ao Departamento de Estado",
+	},
+	"vi": {
+		"Case Was Received": "Đã nhận hồ sơ",
+		"Case Was Received And A Receipt Notice Was Sent":        "Đã nhận hồ sơ và đã gửi thông báo biên nhận",
+		"Case Was Received And A Receipt Notice Was Emailed":     "Đã nhận hồ sơ và đã gửi thông báo biên nhận qua email",
+		"Fingerprint Fee Was Received":                           "Đã nhận phí lấy dấu vân tay",
+		"Case Was Updated To Show Fingerprints Were Taken":       "Hồ sơ đã được cập nhật cho thấy đã lấy dấu vân tay",
+		"Case Is Being Actively Reviewed By USCIS":               "USCIS đang tích cực xem xét hồ sơ",
+		"Case Is Ready To Be Scheduled For An Interview":         "Hồ sơ đã sẵn sàng để lên lịch phỏng vấn",
+		"Interview Was Scheduled":                                "Đã lên lịch phỏng vấn",
+		"Interview Was Rescheduled":                              "Đã dời lịch phỏng vấn",
+		"Interview Was Completed And My Case Must Be Reviewed":   "Đã phỏng vấn xong và hồ sơ cần được xem xét",
+		"Request for Evidence Was Sent":                          "Đã gửi yêu cầu bổ sung bằng chứng (RFE)",
+		"Request for Initial Evidence Was Sent":                  "Đã gửi yêu cầu bằng chứng ban đầu",
+		"Response To USCIS' Request For Evidence Was Received":   "Đã nhận phản hồi cho yêu cầu bổ sung bằng chứng của USCIS",
+		"Case Was Transferred And A New Office Has Jurisdiction": "Hồ sơ đã được chuyển và một văn phòng mới có thẩm quyền",
+		"Case Was Approved":                                      "Hồ sơ đã được chấp thuận",
+		"Case Was Approved And My Decision Was Emailed":          "Hồ sơ đã được chấp thuận và quyết định đã được gửi qua email",
+		"Case Was Denied":                                        "Hồ sơ đã bị từ chối",
+		"Case Was Rejected":                                      "Hồ sơ đã bị trả lại",
+		"Case Was Reopened":                                      "Hồ sơ đã được mở lại",
+		"New Card Is Being Produced":                             "Thẻ mới đang được sản xuất",
+		"Card Is Being Produced":                                 "Thẻ đang được sản xuất",
+		"Card Was Mailed To Me":                                  "Thẻ đã được gửi qua bưu điện",
+		"Card Was Picked Up By The United States Postal Service": "Thẻ đã được Bưu điện Hoa Kỳ nhận",
+		"Card Was Delivered To Me By The Post Office":            "Thẻ đã được bưu điện giao",
+		"Document Was Mailed To Me":                              "Giấy tờ đã được gửi qua bưu điện",
+		"Notice Explaining USCIS Actions Was Mailed":             "Đã gửi thông báo giải thích các hành động của USCIS",
+		"Oath Ceremony Was Scheduled":                            "Đã lên lịch lễ tuyên thệ",
+		"Case Was Sent To The Department of State":               "Hồ sơ đã được gửi đến Bộ Ngoại giao",
+	},
+	"ko": {
+		"Case Was Received": "사건이 접수되었습니다",
+		"Case Was Received And A Receipt Notice Was Sent":        "사건이 접수되어 접수증이 발송되었습니다",
+		"Case Was Received And A Receipt Notice Was Emailed":     "사건이 접수되어 접수증이 이메일로 발송되었습니다",
+		"Fingerprint Fee Was Received":                           "지문 채취 수수료가 접수되었습니다",
+		"Case Was Updated To Show Fingerprints Were Taken":       "지문 채취가 완료된 것으로 사건이 업데이트되었습니다",
+		"Case Is Being Actively Reviewed By USCIS":               "USCIS에서 사건을 심사 중입니다",
+		"Case Is Ready To Be Scheduled For An Interview":         "인터뷰 일정을 잡을 준비가 되었습니다",
+		"Interview Was Scheduled":                                "인터뷰 일정이 잡혔습니다",
+		"Interview Was Rescheduled":                              "인터뷰 일정이 변경되었습니다",
+		"Interview Was Completed And My Case Must Be Reviewed":   "인터뷰가 완료되었으며 사건 검토가 필요합니다",
+		"Request for Evidence Was Sent":                          "추가 증거 요청(RFE)이 발송되었습니다",
+		"Request for Initial Evidence Was Sent":                  "초기 증거 요청이 발송되었습니다",
+		"Response To USCIS' Request For Evidence Was Received":   "USCIS 추가 증거 요청에 대한 답변이 접수되었습니다",
+		"Case Was Transferred And A New Office Has Jurisdiction": "사건이 이관되어 새 사무소가 관할합니다",
+		"Case Was Approved":                                      "사건이 승인되었습니다",
+		"Case Was Approved And My Decision Was Emailed":          "사건이 승인되었으며 결정문이 이메일로 발송되었습니다",
+		"Case Was Denied":                                        "사건이 기각되었습니다",
+		"Case Was Rejected":                                      "사건이 반려되었습니다",
+		"Case Was Reopened":                                      "사건이 재개되었습니다",
+		"New Card Is Being Produced":                             "새 카드가 제작 중입니다",
+		"Card Is Being Produced":                                 "카드가 제작 중입니다",
+		"Card Was Mailed To Me":                                  "카드가 우편으로 발송되었습니다",
+		"Card Was Picked Up By The United States Postal Service": "미국 우정청이 카드를 수거했습니다",
+		"Card Was Delivered To Me By The Post Office":            "우체국이 카드를 배달했습니다",
+		"Document Was Mailed To Me":                              "서류가 우편으로 발송되었습니다",
+		"Notice Explaining USCIS Actions Was Mailed":             "USCIS 조치를 설명하는 통지서가 발송되었습니다",
+		"Oath Ceremony Was Scheduled":                            "시민권 선서식 일정이 잡혔습니다",
+		"Case Was Sent To The Department of State":               "사건이 국무부로 이관되었습니다",
+	},
+}
+
+// statusTableAliases maps locales to the table of a related locale
+var statusTableAliases = map[string]string{
+	"zh-HK": "zh-TW",
+	"zh-MO": "zh-TW",
+	"zh":    "zh-CN",
+}
+
+// statusIndex holds statusTranslations keyed by normalized English title
+var statusIndex = func() map[string]map[string]string {
+	index := make(map[string]map[string]string, len(statusTranslations))
+	for tag, table := range statusTranslations {
+		index[tag] = make(map[string]string, len(table))
+		for english, translated := range table {
+			index[tag][normalizeStatus(english)] = translated
+		}
+	}
+	return index
+}()
+
+// missingStatuses remembers untranslated titles already logged, so each is logged once
+var missingStatuses sync.Map
+
+// normalizeStatus makes titles comparable regardless of case, spacing and a trailing period
+func normalizeStatus(status string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.Join(strings.Fields(status), " "), "."))
+}
+
+// statusTable returns the translation table for the locale, if there is one
+// English and locales without a table have none
+func (s Settings) statusTable() (string, map[string]string) {
+	for _, tag := range []string{s.Locale, statusTableAliases[s.Locale]} {
+		if table, ok := statusIndex[tag]; ok {
+			return tag, table
+		}
+	}
+	language, _, _ := strings.Cut(s.Locale, "-")
+	if tag, ok := statusTableAliases[language]; ok {
+		return tag, statusIndex[tag]
+	}
+	return language, statusIndex[language]
+}
+
+// TranslateStatus returns the translation of a USCIS status title or action
+// description, and whether one was found
+func (s Settings) TranslateStatus(status string) (string, bool) {
+	_, table := s.statusTable()
+	translated, ok := table[normalizeStatus(status)]
+	return translated, ok
+}
+
+// Status renders a USCIS status title for the recipient: the translation followed by
+// the English original, which stays authoritative. Titles missing from the table are
+// shown in English and logged once so the table can be extended
+func (s Settings) Status(status string) string {
+	tag, table := s.statusTable()
+	if table == nil || strings.TrimSpace(status) == "" {
+		return status
+	}
+	translated, ok := table[normalizeStatus(status)]
+	if !ok {
+		if _, logged := missingStatuses.LoadOrStore(tag+"|"+normalizeStatus(status), true); !logged {
+			log.Printf("No %s translation for USCIS status %q - showing it in English (add it to internal/locale/status.go)", tag, status)
+		}
+		return status
+	}
+	return translated + " (" + status + ")"
+}
@@ -0,0 +1,153 @@
+package logging
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event is one log line kept in a Buffer
+type Event struct {
+	Seq     uint64    `json:"seq"`
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`             // info, warning or error
+	CaseID  string    `json:"case_id,omitempty"` // from a "[CASEID] " or "[CASEID nickname] " prefix
+	Message string    `json:"message"`
+}
+
+// casePrefix matches the "[IOE0123456789] " prefix of per-case log lines, also with the
+// nickname LabelCases adds
+var casePrefix = regexp.MustCompile(`^\[([A-Z]{3}\d{10})(?: [^\]]*)?\] `)
+
+// stdTimestamp matches the date and time the standard logger puts before each line
+var stdTimestamp = regexp.MustCompile(`^\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}(\.\d+)? `)
+
+// Buffer is an io.Writer that keeps the most recent log lines as events and
+// fans them out to subscribers
+// Lines pass through a scrub function first, so secrets never reach the buffer
+// It is safe for concurrent use
+type Buffer struct {
+	mu      sync.Mutex
+	scrub   func(string) string
+	events  []Event // ring of at most cap(events) entries
+	next    int     // ring index of the next write once full
+	seq     uint64
+	partial string // an incomplete line waiting for its newline
+	subs    map[chan Event]struct{}
+}
+
+// NewBuffer creates a buffer keeping the last size events
+// A nil scrub function keeps lines as written
+func NewBuffer(size int, scrub func(string) string) *Buffer {
+	if size < 1 {
+		size = 1
+	}
+	if scrub == nil {
+		scrub = func(s string) string { return s }
+	}
+	return &Buffer{
+		scrub:  scrub,
+		events: make([]Event, 0, size),
+		subs:   make(map[chan Event]struct{}),
+	}
+}
+
+// Write splits p into lines and records each complete line as an event
+func (b *Buffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	text := b.partial + string(p)
+	lines := strings.Split(text, "\n")
+	b.partial = lines[len(lines)-1]
+	for _, line := range lines[:len(lines)-1] {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		b.appendLocked(b.parse(line))
+	}
+	return len(p), nil
+}
+
+// parse turns a log line into an event
+func (b *Buffer) parse(line string) Event {
+	message := b.scrub(stdTimestamp.ReplaceAllString(line, ""))
+	event := Event{Time: time.Now(), Level: levelOf(message), Message: message}
+	if m := casePrefix.FindStringSubmatch(message); m != nil {
+		event.CaseID = m[1]
+	}
+	return event
+}
+
+// levelOf infers a level from the wording the tracker's log lines use
+func levelOf(message string) string {
+	lower := strings.ToLower(message)
+	switch {
+	case strings.Contains(lower, "warning"):
+		return "warning"
+	case strings.Contains(lower, "error") || strings.Contains(lower, "failed"):
+		return "error"
+	default:
+		return "info"
+	}
+}
+
+// appendLocked stores an event and hands it to subscribers
+func (b *Buffer) appendLocked(event Event) {
+	b.seq++
+	event.Seq = b.seq
+	if len(b.events) < cap(b.events) {
+		b.events = append(b.events, event)
+	} else {
+		b.events[b.next] = event
+		b.next = (b.next + 1) % len(b.events)
+	}
+
+	for ch := range b.subs {
+		select {
+		case ch <- event:
+		default:
+			// A slow subscriber misses events rather than blocking logging
+		}
+	}
+}
+
+// Since returns the buffered events after seq, oldest first
+// A seq from before a restart (larger than any issued since) returns everything
+func (b *Buffer) Since(seq uint64) []Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if seq > b.seq {
+		seq = 0
+	}
+
+	events := make([]Event, 0, len(b.events))
+	for i := range b.events {
+		event := b.events[(b.next+i)%len(b.events)]
+		if event.Seq > seq {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// Subscribe returns a channel receiving new events and a function to stop
+func (b *Buffer) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, 64)
+
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
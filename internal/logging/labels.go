@@ -0,0 +1,16 @@
+package logging
+
+// LabelCases returns a function adding case nicknames to per-case log lines, so
+// "[IOE0123456789] No changes detected" reads "[IOE0123456789 Mom's I-485] No changes detected"
+// Lines of cases without a nickname are kept as they are
+func LabelCases(nicknames map[string]string) func(string) string {
+	return func(line string) string {
+		stamp := stdTimestamp.FindString(line)
+		rest := line[len(stamp):]
+		m := casePrefix.FindStringSubmatch(rest)
+		if m == nil || nicknames[m[1]] == "" {
+			return line
+		}
+		return stamp + "[" + m[1] + " " + nicknames[m[1]] + "] " + rest[len(m[0]):]
+	}
+}
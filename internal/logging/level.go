@@ -0,0 +1,57 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+)
+
+// Levels are the log levels a LevelFilter accepts, lowest first
+var Levels = []string{"info", "warning", "error"}
+
+// LevelFilter is an io.Writer that drops log lines below a minimum level
+// Levels are inferred from the wording of each line, as for Buffer events, so
+// every Write is expected to hold whole lines (as the standard logger does)
+// It is safe for concurrent use
+type LevelFilter struct {
+	mu  sync.RWMutex
+	out io.Writer
+	min int // index into Levels
+}
+
+// NewLevelFilter creates a filter passing every line to out
+func NewLevelFilter(out io.Writer) *LevelFilter {
+	return &LevelFilter{out: out}
+}
+
+// SetLevel sets the lowest level that is still written
+func (f *LevelFilter) SetLevel(level string) error {
+	min := slices.Index(Levels, level)
+	if min < 0 {
+		return fmt.Errorf("unknown log level %q (want one of %v)", level, Levels)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.min = min
+	return nil
+}
+
+// Level returns the lowest level that is written
+func (f *LevelFilter) Level() string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return Levels[f.min]
+}
+
+// Write passes p on unless its level is below the minimum
+func (f *LevelFilter) Write(p []byte) (int, error) {
+	f.mu.RLock()
+	min := f.min
+	f.mu.RUnlock()
+
+	if slices.Index(Levels, levelOf(string(p))) < min {
+		return len(p), nil
+	}
+	return f.out.Write(p)
+}
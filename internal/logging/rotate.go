@@ -0,0 +1,253 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat is appended to rotated file names: tracker-2025-10-11T15-04-05.000.log
+// A rotation finding the name taken adds a sequence number: tracker-2025-10-11T15-04-05.000-1.log
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// legacyBackupTimeFormat named the backups of older releases, which rotated at most once a second
+const legacyBackupTimeFormat = "2006-01-02T15-04-05"
+
+// RotateOptions controls when a RotatingFile rolls over and what it keeps
+type RotateOptions struct {
+	MaxSize    int64         // Rotate once the file exceeds this many bytes (0 = no size limit)
+	Interval   time.Duration // Rotate once the file is older than this (0 = no time limit)
+	MaxBackups int           // Number of rotated files to keep (0 = keep all)
+	MaxAge     time.Duration // Delete rotated files older than this (0 = keep regardless of age)
+}
+
+// RotatingFile is an io.Writer that writes to a log file and rotates it
+// based on size and age, pruning old backups according to the retention policy
+// It is safe for concurrent use
+type RotatingFile struct {
+	mu       sync.Mutex
+	path     string
+	opts     RotateOptions
+	file     *os.File
+	size     int64
+	openedAt time.Time
+}
+
+// NewRotatingFile opens (or creates) the log file at path
+func NewRotatingFile(path string, opts RotateOptions) (*RotatingFile, error) {
+	r := &RotatingFile{path: path, opts: opts}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Write appends p to the log file, rotating first if a limit has been reached
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.shouldRotate(int64(len(p))) {
+		if err := r.rotate(); err != nil {
+			// Keep logging to the current file rather than losing output
+			fmt.Fprintf(os.Stderr, "log rotation failed: %v\n", err)
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Close closes the underlying file
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// Backups returns the rotated files belonging to this log, newest first
+func (r *RotatingFile) Backups() ([]string, error) {
+	return ListBackups(r.path)
+}
+
+// ListBackups returns the rotated files for a log path, newest first
+func ListBackups(path string) ([]string, error) {
+	prefix, ext := backupNameParts(path)
+	matches, err := filepath.Glob(prefix + "-*" + ext)
+	if err != nil {
+		return nil, err
+	}
+
+	type backup struct {
+		path string
+		at   time.Time
+		seq  int
+	}
+	var found []backup
+	for _, match := range matches {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(match, prefix+"-"), ext)
+		if at, seq, ok := parseBackupStamp(stamp); ok {
+			found = append(found, backup{path: match, at: at, seq: seq})
+		}
+	}
+
+	// A sequence number sorts before the name without one, so order by what the names hold
+	sort.Slice(found, func(i, j int) bool {
+		if !found[i].at.Equal(found[j].at) {
+			return found[i].at.After(found[j].at)
+		}
+		return found[i].seq > found[j].seq
+	})
+	backups := make([]string, len(found))
+	for i, b := range found {
+		backups[i] = b.path
+	}
+	return backups, nil
+}
+
+// parseBackupStamp returns the rotation time and sequence number in a backup's name
+func parseBackupStamp(stamp string) (time.Time, int, bool) {
+	for _, format := range []string{backupTimeFormat, legacyBackupTimeFormat} {
+		if at, err := time.Parse(format, stamp); err == nil {
+			return at, 0, true
+		}
+	}
+	i := strings.LastIndex(stamp, "-")
+	if i < 0 {
+		return time.Time{}, 0, false
+	}
+	seq, err := strconv.Atoi(stamp[i+1:])
+	if err != nil || seq < 1 {
+		return time.Time{}, 0, false
+	}
+	at, err := time.Parse(backupTimeFormat, stamp[:i])
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	return at, seq, true
+}
+
+// backupName returns a name for a backup rotated at t that no file has yet
+func backupName(path string, t time.Time) (string, error) {
+	prefix, ext := backupNameParts(path)
+	stamp := t.Format(backupTimeFormat)
+	name := fmt.Sprintf("%s-%s%s", prefix, stamp, ext)
+	for seq := 1; ; seq++ {
+		_, err := os.Lstat(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			return name, nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to check log backup: %w", err)
+		}
+		name = fmt.Sprintf("%s-%s-%d%s", prefix, stamp, seq, ext)
+	}
+}
+
+// open opens the log file for appending and records its current size
+func (r *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	r.file = file
+	r.size = info.Size()
+	r.openedAt = info.ModTime()
+	if r.size == 0 {
+		r.openedAt = time.Now()
+	}
+	return nil
+}
+
+// shouldRotate reports whether writing n more bytes requires a rotation first
+func (r *RotatingFile) shouldRotate(n int64) bool {
+	if r.size == 0 {
+		return false
+	}
+	if r.opts.MaxSize > 0 && r.size+n > r.opts.MaxSize {
+		return true
+	}
+	if r.opts.Interval > 0 && time.Since(r.openedAt) >= r.opts.Interval {
+		return true
+	}
+	return false
+}
+
+// rotate renames the current file to a timestamped backup and starts a new one
+func (r *RotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+
+	backup, err := backupName(r.path, time.Now())
+	if err == nil {
+		err = os.Rename(r.path, backup)
+	}
+	if err != nil {
+		// Reopen the original so writes keep working
+		if openErr := r.open(); openErr != nil {
+			return fmt.Errorf("failed to rename log file (%v) and reopen it: %w", err, openErr)
+		}
+		return fmt.Errorf("failed to rename log file: %w", err)
+	}
+
+	if err := r.open(); err != nil {
+		return err
+	}
+
+	r.prune()
+	return nil
+}
+
+// prune deletes backups beyond MaxBackups or older than MaxAge
+func (r *RotatingFile) prune() {
+	backups, err := ListBackups(r.path)
+	if err != nil {
+		return
+	}
+
+	cutoff := time.Now().Add(-r.opts.MaxAge)
+	for i, backup := range backups {
+		tooMany := r.opts.MaxBackups > 0 && i >= r.opts.MaxBackups
+		tooOld := false
+		if r.opts.MaxAge > 0 {
+			if info, err := os.Stat(backup); err == nil && info.ModTime().Before(cutoff) {
+				tooOld = true
+			}
+		}
+		if tooMany || tooOld {
+			os.Remove(backup)
+		}
+	}
+}
+
+// backupNameParts splits "logs/tracker.log" into "logs/tracker" and ".log"
+func backupNameParts(path string) (string, string) {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext), ext
+}
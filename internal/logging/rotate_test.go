@@ -0,0 +1,138 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseBackupStamp(t *testing.T) {
+	at := time.Date(2025, 10, 11, 15, 4, 5, 0, time.UTC)
+	tests := []struct {
+		stamp   string
+		wantAt  time.Time
+		wantSeq int
+		wantOK  bool
+	}{
+		{stamp: "2025-10-11T15-04-05.123", wantAt: at.Add(123 * time.Millisecond), wantOK: true},
+		{stamp: "2025-10-11T15-04-05.123-2", wantAt: at.Add(123 * time.Millisecond), wantSeq: 2, wantOK: true},
+		{stamp: "2025-10-11T15-04-05", wantAt: at, wantOK: true},
+		{stamp: "2025-10-11T15-04-05.123-0", wantOK: false},
+		{stamp: "2025-10-11T15-04-05.123-x", wantOK: false},
+		{stamp: "old", wantOK: false},
+	}
+	for _, tt := range tests {
+		gotAt, gotSeq, gotOK := parseBackupStamp(tt.stamp)
+		if gotOK != tt.wantOK || !gotAt.Equal(tt.wantAt) || gotSeq != tt.wantSeq {
+			t.Errorf("parseBackupStamp(%q) = %v, %d, %v, want %v, %d, %v", tt.stamp, gotAt, gotSeq, gotOK, tt.wantAt, tt.wantSeq, tt.wantOK)
+		}
+	}
+}
+
+func TestListBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tracker.log")
+	names := []string{
+		"tracker-2025-10-11T15-04-05.log", // legacy name
+		"tracker-2025-10-11T15-04-06.500.log",
+		"tracker-2025-10-11T15-04-06.500-1.log",
+		"tracker-2025-10-11T15-04-06.500-2.log",
+		"tracker-2025-10-12T00-00-00.000.log",
+		"tracker-notes.log", // not a backup
+		"other-2025-10-11T15-04-05.000.log",
+	}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := ListBackups(path)
+	if err != nil {
+		t.Fatalf("ListBackups: %v", err)
+	}
+	want := []string{
+		filepath.Join(dir, "tracker-2025-10-12T00-00-00.000.log"),
+		filepath.Join(dir, "tracker-2025-10-11T15-04-06.500-2.log"),
+		filepath.Join(dir, "tracker-2025-10-11T15-04-06.500-1.log"),
+		filepath.Join(dir, "tracker-2025-10-11T15-04-06.500.log"),
+		filepath.Join(dir, "tracker-2025-10-11T15-04-05.log"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ListBackups = %v, want %v", got, want)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        RotateOptions
+		writes      int
+		wantBackups int
+	}{
+		{name: "under the limit", opts: RotateOptions{MaxSize: 100}, writes: 5, wantBackups: 0},
+		{name: "every write over the limit", opts: RotateOptions{MaxSize: 15}, writes: 5, wantBackups: 4},
+		{name: "pruned to MaxBackups", opts: RotateOptions{MaxSize: 15, MaxBackups: 2}, writes: 6, wantBackups: 2},
+		{name: "no size limit", opts: RotateOptions{}, writes: 5, wantBackups: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tracker.log")
+			r, err := NewRotatingFile(path, tt.opts)
+			if err != nil {
+				t.Fatalf("NewRotatingFile: %v", err)
+			}
+			defer r.Close()
+
+			// Writes land within the same second, which must not overwrite backups
+			for i := 0; i < tt.writes; i++ {
+				if _, err := r.Write([]byte("0123456789\n")); err != nil {
+					t.Fatalf("Write: %v", err)
+				}
+			}
+
+			backups, err := r.Backups()
+			if err != nil {
+				t.Fatalf("Backups: %v", err)
+			}
+			if len(backups) != tt.wantBackups {
+				t.Fatalf("got %d backups %v, want %d", len(backups), backups, tt.wantBackups)
+			}
+			// Each rotation keeps exactly the one line written before it
+			for _, backup := range backups {
+				if data, err := os.ReadFile(backup); err != nil || string(data) != "0123456789\n" {
+					t.Errorf("backup %s holds %q, %v", filepath.Base(backup), data, err)
+				}
+			}
+		})
+	}
+}
+
+func TestPruneMaxAge(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tracker.log")
+	old := filepath.Join(dir, "tracker-2025-01-01T00-00-00.000.log")
+	recent := filepath.Join(dir, "tracker-2025-10-11T15-04-05.000.log")
+	for _, backup := range []string{old, recent} {
+		if err := os.WriteFile(backup, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Age is taken from the file's modification time, not its name
+	if err := os.Chtimes(old, time.Now().Add(-48*time.Hour), time.Now().Add(-48*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &RotatingFile{path: path, opts: RotateOptions{MaxAge: 24 * time.Hour}}
+	r.prune()
+
+	got, err := ListBackups(path)
+	if err != nil {
+		t.Fatalf("ListBackups: %v", err)
+	}
+	if want := []string{recent}; !reflect.DeepEqual(got, want) {
+		t.Errorf("backups after prune = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,105 @@
+package logging
+
+import (
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces a masked value
+const Redacted = "[redacted]"
+
+// maxSecrets bounds the registered secrets; the oldest (long expired cookies) go first
+const maxSecrets = 256
+
+// minSecretLength keeps short values (a "1", a "yes") from masking unrelated text
+const minSecretLength = 4
+
+// secretNames matches the parameter and field names that carry credentials
+const secretNames = `(?:cookie|session|token|secret|password|passwd|api[_-]?key|csrf)`
+
+// secretPatterns mask credentials by their shape, for values the tracker never
+// registered: cookie headers, name=value pairs and JSON fields of tokens and
+// sessions, bearer tokens and verification codes
+var secretPatterns = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`(?i)(\b(?:set-)?cookie:\s*)[^\n]+`), "${1}" + Redacted},
+	{regexp.MustCompile(`(?i)(\b[\w.-]*` + secretNames + `[\w.-]*=)("[^"]*"|[^\s;&,"]+)`), "${1}" + Redacted},
+	{regexp.MustCompile(`(?i)("[\w.-]*` + secretNames + `[\w.-]*"\s*:\s*)"[^"]*"`), `${1}"` + Redacted + `"`},
+	{regexp.MustCompile(`(?i)(\bpassword:\s+)\S+`), "${1}" + Redacted},
+	{regexp.MustCompile(`(?i)(\bbearer\s+)[\w.~+/=-]+`), "${1}" + Redacted},
+	{regexp.MustCompile(`(?i)(\b(?:2FA|verification|security|one-time)\s+code:?\s*)\d{4,8}\b`), "${1}" + Redacted},
+}
+
+var (
+	secretsMu sync.RWMutex
+	secrets   []string // oldest first
+)
+
+// AddSecret registers values obtained at runtime, such as a freshly minted session
+// cookie or a 2FA code, so Scrub masks them wherever they appear
+// It is safe for concurrent use
+func AddSecret(values ...string) {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if len(value) < minSecretLength {
+			continue
+		}
+		secrets = append(secrets, value)
+		// Cookie headers also show up one "name=value" pair at a time
+		for _, part := range strings.Split(value, ";") {
+			if _, v, ok := strings.Cut(part, "="); ok && len(strings.TrimSpace(v)) >= minSecretLength && part != value {
+				secrets = append(secrets, strings.TrimSpace(v))
+			}
+		}
+	}
+	if len(secrets) > maxSecrets {
+		secrets = append([]string(nil), secrets[len(secrets)-maxSecrets:]...)
+	}
+}
+
+// Scrub masks registered secrets, the given extra values and anything shaped like a
+// credential in a log line
+func Scrub(line string, extra ...string) string {
+	secretsMu.RLock()
+	values := append(append([]string(nil), secrets...), extra...)
+	secretsMu.RUnlock()
+
+	// Longest first, so a secret containing another is masked whole
+	sort.SliceStable(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	for _, value := range values {
+		if len(value) >= minSecretLength {
+			line = strings.ReplaceAll(line, value, Redacted)
+		}
+	}
+	for _, p := range secretPatterns {
+		line = p.pattern.ReplaceAllString(line, p.replacement)
+	}
+	return line
+}
+
+// Scrubber is an io.Writer that masks each line before passing it on
+// Every Write is expected to hold whole lines, as the standard logger does
+type Scrubber struct {
+	out   io.Writer
+	scrub func(string) string
+}
+
+// NewScrubber creates a writer passing lines through scrub to out
+func NewScrubber(out io.Writer, scrub func(string) string) *Scrubber {
+	return &Scrubber{out: out, scrub: scrub}
+}
+
+// Write masks p and writes it to the underlying writer
+func (s *Scrubber) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(s.out, s.scrub(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,220 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is a rendered notification, shared by every channel
+// Channels that can't display HTML convert it (see TelegramHTML)
+type Message struct {
+	ID          string   // Stable across retries of the same notification; lets channels deduplicate
+	CaseIDs     []string // Cases the message is about (empty for system alerts)
+	Recipients  []string // Email addresses for case notifications (empty: the channel's recipient)
+	Subject     string
+	HTML        string
+	Text        string   // Plain-text part of emails (empty: converted from HTML)
+	Footer      string   // Extra HTML appended by email channels only
+	Attachments []string // Local files attached by email channels only (e.g. notice PDFs)
+	Channels    []string // Names of the channels to deliver to (empty: every channel)
+	CopiesSent  bool     // The CC and BCC addresses already got this notification (a retry)
+
+	ctx context.Context // ends the send; see WithContext
+}
+
+// Context returns the context the message is sent under; channels give up on the send
+// once it ends
+func (m Message) Context() context.Context {
+	if m.ctx == nil {
+		return context.Background()
+	}
+	return m.ctx
+}
+
+// WithContext returns a copy of the message sent under ctx
+func (m Message) WithContext(ctx context.Context) Message {
+	m.ctx = ctx
+	return m
+}
+
+// Notifier delivers notifications over one channel
+type Notifier interface {
+	// SendInitial delivers the first status of newly tracked cases
+	SendInitial(msg Message) error
+	// SendChange delivers a detected status change
+	SendChange(msg Message) error
+	// SendAlert delivers an operational alert (auth failure, unreadable response...)
+	SendAlert(msg Message) error
+}
+
+// Backpressured is implemented by notifiers whose provider limits how much they send
+// HeldUntil returns until when non-urgent notifications should wait and why, or the zero
+// time if they can be sent now
+type Backpressured interface {
+	HeldUntil(now time.Time) (time.Time, string)
+}
+
+// Channel is a named notifier with the destination it delivers to
+type Channel struct {
+	Name        string // e.g. "email", "telegram"
+	Destination string // e.g. the recipient address or chat ID
+	Notifier    Notifier
+	CaseIDs     []string // When set, the channel only gets initial and change notifications about these cases
+}
+
+// MultiNotifier fans a notification out to several channels
+// A send succeeds when at least one channel delivered it, so a flaky secondary
+// channel doesn't cause the primary one to be sent again on retry
+// Channels are sent to in parallel; channels with limits send through their own
+// worker pool, so a slow channel doesn't delay the others
+type MultiNotifier struct {
+	channels []Channel
+
+	// OnResult is called after every channel attempt, e.g. to keep a delivery log
+	OnResult func(ch Channel, kind string, msg Message, err error)
+
+	// DryRun, when set and returning true, makes sends log the notification instead
+	// of delivering it, and succeed
+	DryRun func() bool
+
+	// OnQueue and OnSent report the queue depth and send latency of limited channels
+	OnQueue func(channel string, depth int)
+	OnSent  func(channel string, elapsed time.Duration)
+
+	mu     sync.Mutex
+	limits map[string]Limits
+	pools  map[string]*sendPool
+}
+
+// NewMultiNotifier creates a fan-out notifier over the given channels
+func NewMultiNotifier(channels ...Channel) *MultiNotifier {
+	return &MultiNotifier{channels: channels}
+}
+
+// Add registers another channel
+func (m *MultiNotifier) Add(ch Channel) {
+	m.channels = append(m.channels, ch)
+}
+
+// SetLimits bounds the sends of the channels with the given name
+// Channels sharing a name (e.g. several webhooks) share the limits
+func (m *MultiNotifier) SetLimits(channel string, limits Limits) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.limits == nil {
+		m.limits = make(map[string]Limits)
+	}
+	m.limits[channel] = limits
+}
+
+// Channels returns the registered channels
+func (m *MultiNotifier) Channels() []Channel {
+	return m.channels
+}
+
+// SendInitial fans out an initial-status notification
+func (m *MultiNotifier) SendInitial(msg Message) error {
+	return m.fanOut("initial", msg, Notifier.SendInitial)
+}
+
+// SendChange fans out a change notification
+func (m *MultiNotifier) SendChange(msg Message) error {
+	return m.fanOut("change", msg, Notifier.SendChange)
+}
+
+// SendAlert fans out an alert
+func (m *MultiNotifier) SendAlert(msg Message) error {
+	return m.fanOut("alert", msg, Notifier.SendAlert)
+}
+
+// fanOut sends to every channel and reports an error only if none succeeded
+func (m *MultiNotifier) fanOut(kind string, msg Message, send func(Notifier, Message) error) error {
+	if len(m.channels) == 0 {
+		return errors.New("no notification channels configured")
+	}
+	targets := m.targets(kind, msg)
+	if len(targets) == 0 {
+		return fmt.Errorf("none of the channels %v is configured", msg.Channels)
+	}
+	if m.DryRun != nil && m.DryRun() {
+		names := make([]string, len(targets))
+		for i, ch := range targets {
+			names[i] = ch.Name
+		}
+		log.Printf("Dry run: not sending %s notification %q via %s", kind, msg.Subject, strings.Join(names, ", "))
+		return nil
+	}
+
+	results := make([]error, len(targets))
+	var wg sync.WaitGroup
+	for i, ch := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = m.send(ch, func(ctx context.Context) error { return send(ch.Notifier, msg.WithContext(ctx)) })
+		}()
+	}
+	wg.Wait()
+
+	// Report in channel order, so the delivery log doesn't depend on which channel was faster
+	var errs []error
+	for i, ch := range targets {
+		err := results[i]
+		if m.OnResult != nil {
+			m.OnResult(ch, kind, msg, err)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", ch.Name, err))
+		}
+	}
+
+	if len(errs) == len(targets) {
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+// send delivers over one channel, through its pool when the channel has limits
+func (m *MultiNotifier) send(ch Channel, send func(ctx context.Context) error) error {
+	m.mu.Lock()
+	pool, ok := m.pools[ch.Name]
+	if !ok {
+		if limits, limited := m.limits[ch.Name]; limited {
+			if m.pools == nil {
+				m.pools = make(map[string]*sendPool)
+			}
+			pool = newSendPool(ch.Name, limits, m.OnQueue, m.OnSent)
+			m.pools[ch.Name] = pool
+		}
+	}
+	m.mu.Unlock()
+
+	if pool == nil {
+		return send(context.Background())
+	}
+	return pool.submit(send)
+}
+
+// targets returns the channels a message is addressed to
+// Channels limited to some cases never get alerts, nor messages about other cases
+func (m *MultiNotifier) targets(kind string, msg Message) []Channel {
+	var targets []Channel
+	for _, ch := range m.channels {
+		if len(msg.Channels) > 0 && !slices.Contains(msg.Channels, ch.Name) {
+			continue
+		}
+		if len(ch.CaseIDs) > 0 && (kind == "alert" || !slices.ContainsFunc(msg.CaseIDs, func(caseID string) bool {
+			return slices.Contains(ch.CaseIDs, caseID)
+		})) {
+			continue
+		}
+		targets = append(targets, ch)
+	}
+	return targets
+}
@@ -0,0 +1,93 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Limits bound how a channel sends
+type Limits struct {
+	Concurrency int           // Sends in flight at once
+	Timeout     time.Duration // How long one send may take before it is cancelled
+	QueueDepth  int           // Sends waiting for a free slot; more fail right away
+}
+
+// sendJob is one send waiting in a pool's queue
+type sendJob struct {
+	send func(ctx context.Context) error
+	done chan error
+}
+
+// sendPool sends for one channel with a fixed number of workers and a bounded queue,
+// so a slow channel only holds up its own sends
+type sendPool struct {
+	name    string
+	limits  Limits
+	jobs    chan sendJob
+	onQueue func(channel string, depth int)
+	onSent  func(channel string, elapsed time.Duration)
+}
+
+// newSendPool starts the workers of a channel's pool
+func newSendPool(name string, limits Limits, onQueue func(string, int), onSent func(string, time.Duration)) *sendPool {
+	if limits.Concurrency < 1 {
+		limits.Concurrency = 1
+	}
+	p := &sendPool{
+		name:    name,
+		limits:  limits,
+		jobs:    make(chan sendJob, limits.QueueDepth),
+		onQueue: onQueue,
+		onSent:  onSent,
+	}
+	for i := 0; i < limits.Concurrency; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// submit queues a send and waits for its result
+// It fails right away when the queue is full; the send gets a context that ends at the timeout
+func (p *sendPool) submit(send func(ctx context.Context) error) error {
+	job := sendJob{send: send, done: make(chan error, 1)}
+	select {
+	case p.jobs <- job:
+	default:
+		return fmt.Errorf("send queue full (%d in flight, %d waiting)", p.limits.Concurrency, p.limits.QueueDepth)
+	}
+	p.reportQueue()
+	return <-job.done
+}
+
+// work runs queued sends one at a time
+// A send that reaches the timeout is cancelled through its context, and the worker
+// waits for it to return: a worker never has more than one send in flight, and a send
+// that went through just as it timed out counts as sent
+func (p *sendPool) work() {
+	for job := range p.jobs {
+		p.reportQueue()
+		start := time.Now()
+		ctx, cancel := context.Background(), context.CancelFunc(func() {})
+		if p.limits.Timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, p.limits.Timeout)
+		}
+		err := job.send(ctx)
+		if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("send timed out after %v: %w", p.limits.Timeout, err)
+		}
+		cancel()
+		if p.onSent != nil {
+			p.onSent(p.name, time.Since(start))
+		}
+		job.done <- err
+	}
+}
+
+// reportQueue reports how many sends are waiting
+func (p *sendPool) reportQueue() {
+	if p.onQueue != nil {
+		p.onQueue(p.name, len(p.jobs))
+	}
+}
@@ -0,0 +1,51 @@
+package notifier
+
+import (
+	"errors"
+	"fmt"
+)
+
+// RecipientsError is returned by email channels when a notification reached some of its
+// recipients only. Failed lists the others, so a retry emails them alone, and Copied
+// whether the CC and BCC addresses got their copy
+// Its message leaves the addresses out: it ends up in logs and the delivery log
+type RecipientsError struct {
+	Failed []string
+	Copied bool
+	Err    error
+}
+
+// Error reports how many recipients weren't reached, without naming them
+func (e *RecipientsError) Error() string {
+	return fmt.Sprintf("failed to email %d recipient(s): %v", len(e.Failed), e.Err)
+}
+
+// Unwrap returns the errors of the failed sends
+func (e *RecipientsError) Unwrap() error {
+	return e.Err
+}
+
+// sendEach emails a notification to each recipient separately with send; withCopies
+// adds the CC and BCC addresses to the first email that goes out, so they still get
+// their copy when the email to the first recipient fails
+// Failed sends are reported by position in the list rather than by address
+func sendEach(recipients []string, withCopies bool, send func(to string, withCopies bool) error) error {
+	var failed []string
+	var errs []error
+	copied := !withCopies
+	for i, to := range recipients {
+		carriesCopies := !copied
+		if err := send(to, carriesCopies); err != nil {
+			failed = append(failed, to)
+			errs = append(errs, fmt.Errorf("recipient %d of %d: %w", i+1, len(recipients), err))
+			continue
+		}
+		if carriesCopies {
+			copied = true
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return &RecipientsError{Failed: failed, Copied: copied, Err: errors.Join(errs...)}
+}
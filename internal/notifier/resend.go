@@ -1,35 +1,109 @@
 package notifier
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/resend/resend-go/v2"
 )
 
 // ResendClient handles email notifications via Resend API
 type ResendClient struct {
-	client *resend.Client
-	from   string
+	client  *resend.Client
+	from    string
+	to      []string
+	cc      []string
+	bcc     []string
+	replyTo string
+	headers map[string]string
+	limits  *resendLimits
 }
 
 // NewResendClient creates a new Resend client
 func NewResendClient(apiKey string) *ResendClient {
+	limits := newResendLimits(http.DefaultTransport)
+	httpClient := &http.Client{Timeout: time.Minute, Transport: limits}
 	return &ResendClient{
-		client: resend.NewClient(apiKey),
-		from:   "Case Tracker Test <onboarding@resend.dev>",
+		client: resend.NewCustomClient(httpClient, strings.TrimSpace(apiKey)),
+		from:   "USCIS Case Tracker <onboarding@resend.dev>",
+		limits: limits,
 	}
 }
 
-// SendEmail sends an email notification
+// SetDailyQuota sets how many emails the Resend plan allows per day (free tier: 100)
+// Near it, HeldUntil holds non-urgent email so the rest of the quota is left for urgent updates
+func (r *ResendClient) SetDailyQuota(quota int) {
+	r.limits.dailyQuota = quota
+}
+
+// HeldUntil returns until when non-urgent email should wait for Resend's quota and why,
+// or the zero time if it can be sent now
+func (r *ResendClient) HeldUntil(now time.Time) (time.Time, string) {
+	return r.limits.heldUntil(now)
+}
+
+// SetFrom overrides the sender, e.g. "Smith Immigration Law <updates@smithlaw.com>"
+// The domain must be verified in Resend
+func (r *ResendClient) SetFrom(from string) {
+	r.from = from
+}
+
+// SetRecipients sets the addresses alerts, and notifications without recipients of
+// their own, are sent to
+func (r *ResendClient) SetRecipients(to []string) {
+	r.to = to
+}
+
+// SetCopies sets the addresses copied and blind-copied on case notifications
+// They get one copy of each notification, however many recipients it has
+func (r *ResendClient) SetCopies(cc, bcc []string) {
+	r.cc, r.bcc = cc, bcc
+}
+
+// SetReplyTo sets the address replies are sent to
+func (r *ResendClient) SetReplyTo(replyTo string) {
+	r.replyTo = replyTo
+}
+
+// SetUnsubscribeURL adds a List-Unsubscribe header pointing at the given page
+func (r *ResendClient) SetUnsubscribeURL(url string) {
+	r.headers = map[string]string{"List-Unsubscribe": "<" + url + ">"}
+}
+
+// SendEmail sends an email notification, with the CC and BCC addresses
 func (r *ResendClient) SendEmail(to, subject, body string) error {
+	return r.sendEmail(context.Background(), to, subject, body, EmailText(body), "", true, nil)
+}
+
+// sendEmail sends an email with an HTML and a plain-text part, deduplicated by Resend
+// when an idempotency key is given; withCopies adds the CC and BCC addresses
+// Resend keeps keys for 24 hours, which covers retries of a pending notification
+func (r *ResendClient) sendEmail(ctx context.Context, to, subject, body, text, idempotencyKey string, withCopies bool, attachments []*resend.Attachment) error {
 	params := &resend.SendEmailRequest{
-		From:    r.from,
-		To:      []string{to},
-		Subject: subject,
-		Html:    body,
+		From:        r.from,
+		To:          []string{to},
+		Subject:     subject,
+		Html:        body,
+		Text:        text,
+		ReplyTo:     r.replyTo,
+		Headers:     r.headers,
+		Attachments: attachments,
+	}
+	if withCopies {
+		params.Cc, params.Bcc = r.cc, r.bcc
 	}
 
-	sent, err := r.client.Emails.Send(params)
+	sent, err := r.client.Emails.SendWithOptions(ctx, params, &resend.SendEmailOptions{IdempotencyKey: idempotencyKey})
 	if err != nil {
 		return fmt.Errorf("failed to send email: %w", err)
 	}
@@ -40,3 +114,157 @@ func (r *ResendClient) SendEmail(to, subject, body string) error {
 
 	return nil
 }
+
+// SendInitial emails an initial-status notification to the case recipients
+func (r *ResendClient) SendInitial(msg Message) error {
+	return r.sendToRecipients(msg, msg.Recipients, !msg.CopiesSent)
+}
+
+// SendChange emails a change notification to the case recipients
+func (r *ResendClient) SendChange(msg Message) error {
+	return r.sendToRecipients(msg, msg.Recipients, !msg.CopiesSent)
+}
+
+// sendToRecipients emails a notification to each of its recipients separately,
+// so people tracking different cases don't see each other's addresses
+// Falls back to the configured recipients when there are none; withCopies adds the
+// CC and BCC addresses to the first email that goes out
+func (r *ResendClient) sendToRecipients(msg Message, recipients []string, withCopies bool) error {
+	if len(recipients) == 0 {
+		recipients = r.to
+	}
+
+	attachments := loadAttachments(msg.Attachments)
+	if len(recipients) > 1 && len(attachments) == 0 && r.limits.tight(len(recipients)) {
+		return r.sendBatch(recipients, msg, withCopies)
+	}
+
+	return sendEach(recipients, withCopies, func(to string, withCopies bool) error {
+		key := ""
+		if msg.ID != "" {
+			key = msg.ID + "/" + to
+		}
+		return r.sendEmail(msg.Context(), to, msg.Subject, msg.HTML+msg.Footer, messageText(msg), key, withCopies, attachments)
+	})
+}
+
+// sendBatch emails a notification to its recipients in one request (Resend's batch API),
+// for when the rate limit has fewer requests left than there are recipients
+// Resend's batch API takes no attachments, so messages with attachments are sent one by one
+// The emails are validated one by one, and the ones Resend rejects are reported in a
+// RecipientsError, so a retry emails those recipients alone
+func (r *ResendClient) sendBatch(recipients []string, msg Message, withCopies bool) error {
+	text := messageText(msg)
+	params := make([]*resend.SendEmailRequest, len(recipients))
+	for i, to := range recipients {
+		params[i] = &resend.SendEmailRequest{
+			From:    r.from,
+			To:      []string{to},
+			Subject: msg.Subject,
+			Html:    msg.HTML + msg.Footer,
+			Text:    text,
+			ReplyTo: r.replyTo,
+			Headers: r.headers,
+		}
+	}
+	if withCopies {
+		params[0].Cc, params[0].Bcc = r.cc, r.bcc
+	}
+	options := &resend.BatchSendEmailOptions{
+		IdempotencyKey:  batchIdempotencyKey(msg.ID, recipients),
+		BatchValidation: resend.BatchValidationPermissive,
+	}
+
+	sent, err := r.client.Batch.SendWithOptions(msg.Context(), params, options)
+	if err != nil {
+		return fmt.Errorf("failed to send email batch: %w", err)
+	}
+	if sent == nil {
+		return fmt.Errorf("email batch send returned nil response")
+	}
+	return batchErrors(recipients, withCopies, sent.Errors)
+}
+
+// batchIdempotencyKey returns the idempotency key of a batch: like the key of a single
+// email (ID/address), it names the recipients, so a retry to the same ones is
+// deduplicated and one to fewer recipients isn't refused as a changed request
+// The addresses are hashed to keep the key short
+func batchIdempotencyKey(id string, recipients []string) string {
+	if id == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(strings.Join(recipients, "\n")))
+	return id + "/batch-" + hex.EncodeToString(sum[:8])
+}
+
+// batchErrors turns the emails Resend rejected in a batch into a RecipientsError, or
+// returns nil when every email was accepted
+func batchErrors(recipients []string, withCopies bool, itemErrors []resend.BatchError) error {
+	if len(itemErrors) == 0 {
+		return nil
+	}
+	var failed []string
+	var errs []error
+	copied := true
+	for _, itemErr := range itemErrors {
+		if itemErr.Index < 0 || itemErr.Index >= len(recipients) {
+			return fmt.Errorf("email batch failed for unknown item %d: %s", itemErr.Index, itemErr.Message)
+		}
+		if itemErr.Index == 0 && withCopies {
+			copied = false
+		}
+		failed = append(failed, recipients[itemErr.Index])
+		errs = append(errs, fmt.Errorf("recipient %d of %d: %s", itemErr.Index+1, len(recipients), itemErr.Message))
+	}
+	return &RecipientsError{Failed: failed, Copied: copied, Err: errors.Join(errs...)}
+}
+
+// SendAlert emails an alert to the configured recipients, who operate the tracker
+// CC and BCC addresses are left out; they only follow the cases
+func (r *ResendClient) SendAlert(msg Message) error {
+	return r.sendToRecipients(msg, r.to, false)
+}
+
+// messageText returns the plain-text part of a message's emails: its Text, or its HTML
+// converted, followed by the footer
+func messageText(msg Message) string {
+	text := msg.Text
+	if text == "" {
+		text = EmailText(msg.HTML)
+	}
+	if footer := EmailText(msg.Footer); footer != "" {
+		text += "\n\n--\n" + footer
+	}
+	return text
+}
+
+var anchorPattern = regexp.MustCompile(`(?is)<a\s[^>]*href\s*=\s*["']([^"']*)["'][^>]*>(.*?)</a>`)
+
+// EmailText converts notification email HTML to the plain-text part of an email
+// Links are written out after their text, since a text client has nothing to click
+func EmailText(emailHTML string) string {
+	s := anchorPattern.ReplaceAllStringFunc(emailHTML, func(anchor string) string {
+		m := anchorPattern.FindStringSubmatch(anchor)
+		href, label := m[1], m[2]
+		if strings.HasPrefix(href, "#") {
+			return label
+		}
+		return label + " (" + href + ")"
+	})
+	return stripTags(TelegramHTML(s))
+}
+
+// loadAttachments reads the files to attach to an email
+// A file that can't be read is left out rather than holding back the notification
+func loadAttachments(paths []string) []*resend.Attachment {
+	var attachments []*resend.Attachment
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("Warning: Leaving out attachment %s: %v", filepath.Base(path), err)
+			continue
+		}
+		attachments = append(attachments, &resend.Attachment{Content: content, Filename: filepath.Base(path)})
+	}
+	return attachments
+}
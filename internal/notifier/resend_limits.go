@@ -0,0 +1,251 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Names Resend gives its 429 errors
+const (
+	resendRateLimited  = "rate_limit_exceeded"
+	resendDailyQuota   = "daily_quota_exceeded"
+	resendMonthlyQuota = "monthly_quota_exceeded"
+)
+
+// maxRateLimitWait bounds how long a send waits for Resend's rate limit window to reset
+// Resend's windows last about a second; a longer wait fails the send, which the outbox retries
+const maxRateLimitWait = 5 * time.Second
+
+// rateLimitRetries is how often a request rejected by the rate limit is tried again
+const rateLimitRetries = 2
+
+// resendLimits tracks Resend's rate limit and sending quota
+// It is the transport of the Resend client: requests wait out a used-up rate limit window
+// and are retried after a 429, and HeldUntil tells when non-urgent email should wait for
+// the quota. Counts live in memory and start over after a restart
+type resendLimits struct {
+	next       http.RoundTripper
+	dailyQuota int // emails per day (RESEND_DAILY_QUOTA), 0 if unknown
+
+	mu              sync.Mutex
+	remaining       int // requests left in the rate limit window, -1 if unknown
+	resetAt         time.Time
+	day             string // UTC day sentToday counts
+	sentToday       int
+	exhaustedUntil  time.Time // a quota ran out (429); non-urgent email waits until then
+	exhaustedReason string
+}
+
+// newResendLimits creates the limit tracking transport
+func newResendLimits(next http.RoundTripper) *resendLimits {
+	return &resendLimits{next: next, remaining: -1}
+}
+
+// RoundTrip sends a request to Resend within its rate limit
+func (l *resendLimits) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Buffered so the request can be sent again after a 429
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := l.wait(req); err != nil {
+			return nil, err
+		}
+		attemptReq := req.Clone(req.Context())
+		if body != nil {
+			attemptReq.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		resp, err := l.next.RoundTrip(attemptReq)
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 && req.Method == http.MethodPost {
+			l.countSent(req.URL.Path, body, now)
+		}
+		l.record(resp.Header, now)
+		if resp.StatusCode != http.StatusTooManyRequests {
+			return resp, nil
+		}
+
+		switch resendErrorName(resp) {
+		case resendDailyQuota:
+			l.exhaust(nextUTCDay(now), "Resend's daily quota is used up")
+			return resp, nil
+		case resendMonthlyQuota:
+			// The monthly quota resets with the billing cycle, which isn't known here: try again a day later
+			l.exhaust(now.Add(24*time.Hour), "Resend's monthly quota is used up")
+			return resp, nil
+		}
+
+		delay := retryAfter(resp.Header)
+		if attempt >= rateLimitRetries || delay > maxRateLimitWait {
+			return resp, nil
+		}
+		resp.Body.Close()
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// wait holds a request back until the rate limit window resets, if it has no requests left
+func (l *resendLimits) wait(req *http.Request) error {
+	l.mu.Lock()
+	var delay time.Duration
+	if l.remaining == 0 {
+		delay = time.Until(l.resetAt)
+		// Whoever waits takes the new window; what the next response says counts again
+		l.remaining = -1
+	}
+	l.mu.Unlock()
+	if delay <= 0 || delay > maxRateLimitWait {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
+
+// record reads the rate limit and quota headers of a response
+func (l *resendLimits) record(header http.Header, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if remaining, err := strconv.Atoi(header.Get("ratelimit-remaining")); err == nil {
+		l.remaining = remaining
+		l.resetAt = now.Add(time.Second)
+		if reset, err := strconv.Atoi(header.Get("ratelimit-reset")); err == nil {
+			l.resetAt = now.Add(time.Duration(reset) * time.Second)
+		}
+	}
+	// Emails sent today, when Resend reports it; more accurate than counting since the start
+	if used, err := strconv.Atoi(header.Get("x-resend-daily-quota")); err == nil {
+		l.rollDay(now)
+		l.sentToday = max(l.sentToday, used)
+	}
+}
+
+// countSent counts the emails of a successful send request: one, or each of a batch
+func (l *resendLimits) countSent(path string, body []byte, now time.Time) {
+	sent := 0
+	switch {
+	case strings.HasSuffix(path, "/emails/batch"):
+		var batch []json.RawMessage
+		if json.Unmarshal(body, &batch) == nil {
+			sent = len(batch)
+		}
+	case strings.HasSuffix(path, "/emails"):
+		sent = 1
+	}
+	if sent == 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rollDay(now)
+	l.sentToday += sent
+}
+
+// rollDay starts counting a new UTC day; callers hold mu
+func (l *resendLimits) rollDay(now time.Time) {
+	if day := now.UTC().Format("2006-01-02"); day != l.day {
+		l.day = day
+		l.sentToday = 0
+	}
+}
+
+// exhaust records that a quota ran out
+func (l *resendLimits) exhaust(until time.Time, reason string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until.After(l.exhaustedUntil) {
+		log.Printf("%s - holding non-urgent email until %s", reason, until.Format(time.RFC3339))
+		l.exhaustedUntil = until
+		l.exhaustedReason = reason
+	}
+}
+
+// tight reports whether the rate limit window has fewer than n requests left
+func (l *resendLimits) tight(n int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.remaining >= 0 && l.remaining < n && time.Now().Before(l.resetAt)
+}
+
+// heldUntil returns until when non-urgent email should wait and why, or the zero time
+// Near RESEND_DAILY_QUOTA, the last tenth of it is kept for urgent updates
+func (l *resendLimits) heldUntil(now time.Time) (time.Time, string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Before(l.exhaustedUntil) {
+		return l.exhaustedUntil, l.exhaustedReason
+	}
+	if l.dailyQuota == 0 {
+		return time.Time{}, ""
+	}
+	l.rollDay(now)
+	reserve := max(1, l.dailyQuota/10)
+	if l.sentToday >= l.dailyQuota-reserve {
+		return nextUTCDay(now), fmt.Sprintf("%d of %d daily Resend emails sent, the rest is kept for urgent updates", l.sentToday, l.dailyQuota)
+	}
+	return time.Time{}, ""
+}
+
+// resendErrorName reads the error name of a Resend error response and puts the body back
+// for the client library to report
+func resendErrorName(resp *http.Response) string {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+
+	var apiErr struct {
+		Name string `json:"name"`
+	}
+	json.Unmarshal(data, &apiErr)
+	return apiErr.Name
+}
+
+// retryAfter returns how long Resend asks to wait before the next request
+func retryAfter(header http.Header) time.Duration {
+	for _, key := range []string{"retry-after", "ratelimit-reset"} {
+		if seconds, err := strconv.Atoi(header.Get(key)); err == nil && seconds >= 0 {
+			return max(time.Duration(seconds)*time.Second, 200*time.Millisecond)
+		}
+	}
+	return time.Second
+}
+
+// nextUTCDay returns the next midnight UTC, when Resend's daily quota resets
+func nextUTCDay(now time.Time) time.Time {
+	y, m, d := now.UTC().Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+}
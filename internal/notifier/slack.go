@@ -0,0 +1,205 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Block Kit limits on one message
+const (
+	slackMaxHeader  = 150  // characters in a header block
+	slackMaxSection = 3000 // characters in a section block
+	slackMaxBlocks  = 50   // blocks in one message
+)
+
+// SlackClient handles notifications via a Slack incoming webhook
+type SlackClient struct {
+	httpClient *http.Client
+	webhookURL string
+}
+
+// NewSlackClient creates a client that posts to the channel of an incoming webhook
+func NewSlackClient(webhookURL string) *SlackClient {
+	return &SlackClient{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		webhookURL: webhookURL,
+	}
+}
+
+// slackText is a Block Kit text object
+type slackText struct {
+	Type  string `json:"type"` // "plain_text" or "mrkdwn"
+	Text  string `json:"text"`
+	Emoji bool   `json:"emoji,omitempty"`
+}
+
+// slackBlock is a Block Kit layout block (header, section, context or divider)
+type slackBlock struct {
+	Type     string      `json:"type"`
+	Text     *slackText  `json:"text,omitempty"`
+	Elements []slackText `json:"elements,omitempty"`
+}
+
+// slackMessage is the webhook payload
+// Text is the fallback shown in push notifications and clients without Block Kit
+type slackMessage struct {
+	Text   string       `json:"text"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+// SendInitial posts an initial-status notification
+func (s *SlackClient) SendInitial(msg Message) error {
+	return s.post(msg.Context(), slackBlocks(msg.Subject, msg))
+}
+
+// SendChange posts a change notification
+func (s *SlackClient) SendChange(msg Message) error {
+	return s.post(msg.Context(), slackBlocks(msg.Subject, msg))
+}
+
+// SendAlert posts an alert, marked so it stands out in the channel
+func (s *SlackClient) SendAlert(msg Message) error {
+	return s.post(msg.Context(), slackBlocks("⚠️ "+msg.Subject, msg))
+}
+
+// post sends one webhook request
+func (s *SlackClient) post(ctx context.Context, payload slackMessage) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal slack message: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.webhookURL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create slack request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		// Don't wrap the url.Error: the webhook URL is the credential
+		return fmt.Errorf("failed to send slack message: request failed")
+	}
+	defer resp.Body.Close()
+
+	// Webhooks answer "ok" on success and a short error code (e.g. invalid_blocks) otherwise
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to send slack message: status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// slackBlocks renders a notification as a header, the body as mrkdwn sections and
+// a context line with the cases it concerns
+func slackBlocks(title string, msg Message) slackMessage {
+	payload := slackMessage{Text: title}
+	payload.Blocks = append(payload.Blocks, slackBlock{
+		Type: "header",
+		Text: &slackText{Type: "plain_text", Text: truncateRunes(title, slackMaxHeader), Emoji: true},
+	})
+
+	// Reserve room for the divider and context blocks
+	for _, chunk := range splitSlackText(SlackMrkdwn(msg.HTML), slackMaxSection) {
+		if len(payload.Blocks) >= slackMaxBlocks-2 {
+			break
+		}
+		payload.Blocks = append(payload.Blocks, slackBlock{
+			Type: "section",
+			Text: &slackText{Type: "mrkdwn", Text: chunk},
+		})
+	}
+
+	if len(msg.CaseIDs) > 0 {
+		payload.Blocks = append(payload.Blocks,
+			slackBlock{Type: "divider"},
+			slackBlock{Type: "context", Elements: []slackText{{Type: "mrkdwn", Text: "Cases: " + strings.Join(msg.CaseIDs, ", ")}}},
+		)
+	}
+	return payload
+}
+
+// SlackMrkdwn converts notification email HTML to Slack mrkdwn
+// It starts from the Telegram rendering, which already reduces the layout to
+// bold, italic, code and links, then swaps those tags for mrkdwn markers
+func SlackMrkdwn(emailHTML string) string {
+	s := TelegramHTML(emailHTML)
+
+	var out strings.Builder
+	last := 0
+	var href string
+	for _, loc := range tagPattern.FindAllStringSubmatchIndex(s, -1) {
+		out.WriteString(slackEscape(s[last:loc[0]]))
+		last = loc[1]
+
+		closing, name := s[loc[2]:loc[3]] == "/", strings.ToLower(s[loc[4]:loc[5]])
+		switch name {
+		case "b":
+			out.WriteString("*")
+		case "i":
+			out.WriteString("_")
+		case "code":
+			out.WriteString("`")
+		case "pre":
+			out.WriteString("```")
+		case "a":
+			// Slack links are <url|text>; the text is written in between
+			if !closing {
+				if m := hrefPattern.FindStringSubmatch(s[loc[6]:loc[7]]); m != nil {
+					href = html.UnescapeString(m[1])
+					out.WriteString("<" + href + "|")
+					continue
+				}
+				href = ""
+				continue
+			}
+			if href != "" {
+				out.WriteString(">")
+				href = ""
+			}
+		}
+	}
+	out.WriteString(slackEscape(s[last:]))
+	return out.String()
+}
+
+// slackEscape turns HTML text into mrkdwn text: entities are decoded and the
+// three characters Slack treats as control characters are escaped
+func slackEscape(text string) string {
+	text = html.UnescapeString(text)
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
+
+// splitSlackText splits text into chunks of at most max characters, at line breaks where possible
+func splitSlackText(text string, max int) []string {
+	var chunks []string
+	for text != "" {
+		runes := []rune(text)
+		if len(runes) <= max {
+			chunks = append(chunks, text)
+			break
+		}
+		cut := string(runes[:max])
+		if i := strings.LastIndex(cut, "\n"); i > 0 {
+			cut = cut[:i]
+		}
+		chunks = append(chunks, cut)
+		text = strings.TrimLeft(text[len(cut):], "\n")
+	}
+	return chunks
+}
+
+// truncateRunes shortens text to at most max characters, marking the cut with an ellipsis
+func truncateRunes(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	return string(runes[:max-1]) + "…"
+}
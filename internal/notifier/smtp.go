@@ -0,0 +1,350 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/resend/resend-go/v2"
+)
+
+// How the connection to the SMTP server is secured
+const (
+	SMTPStartTLS = "starttls" // plain connection upgraded with STARTTLS, usually port 587
+	SMTPSSL      = "ssl"      // TLS from the first byte, usually port 465
+	SMTPNone     = "none"     // no encryption, for a relay on a trusted network
+)
+
+// SMTPSecurityModes lists the accepted SMTP security modes
+var SMTPSecurityModes = []string{SMTPStartTLS, SMTPSSL, SMTPNone}
+
+// smtpTimeout bounds one email delivery, from connecting to QUIT
+const smtpTimeout = time.Minute
+
+// SMTPClient handles email notifications via an SMTP server, for senders without a
+// Resend account (Gmail, Fastmail or their own relay)
+type SMTPClient struct {
+	host     string
+	port     int
+	security string
+	username string // no authentication when empty
+	password string
+	from     string
+	to       []string
+	cc       []string
+	bcc      []string
+	replyTo  string
+	headers  map[string]string
+}
+
+// NewSMTPClient creates a new SMTP client
+func NewSMTPClient(host string, port int, security, username, password string) *SMTPClient {
+	return &SMTPClient{
+		host:     host,
+		port:     port,
+		security: security,
+		username: username,
+		password: password,
+		from:     "USCIS Case Tracker <" + username + ">",
+	}
+}
+
+// SetFrom overrides the sender, e.g. "Smith Immigration Law <updates@smithlaw.com>"
+// Most providers only accept the account's own addresses
+func (s *SMTPClient) SetFrom(from string) {
+	s.from = from
+}
+
+// SetRecipients sets the addresses alerts, and notifications without recipients of
+// their own, are sent to
+func (s *SMTPClient) SetRecipients(to []string) {
+	s.to = to
+}
+
+// SetCopies sets the addresses copied and blind-copied on case notifications
+// They get one copy of each notification, however many recipients it has
+func (s *SMTPClient) SetCopies(cc, bcc []string) {
+	s.cc, s.bcc = cc, bcc
+}
+
+// SetReplyTo sets the address replies are sent to
+func (s *SMTPClient) SetReplyTo(replyTo string) {
+	s.replyTo = replyTo
+}
+
+// SetUnsubscribeURL adds a List-Unsubscribe header pointing at the given page
+func (s *SMTPClient) SetUnsubscribeURL(url string) {
+	s.headers = map[string]string{"List-Unsubscribe": "<" + url + ">"}
+}
+
+// SendEmail sends an email notification, with the CC and BCC addresses
+func (s *SMTPClient) SendEmail(to, subject, body string) error {
+	return s.sendEmail(context.Background(), to, subject, body, EmailText(body), true, nil)
+}
+
+// SendInitial emails an initial-status notification to the case recipients
+func (s *SMTPClient) SendInitial(msg Message) error {
+	return s.sendToRecipients(msg, msg.Recipients, !msg.CopiesSent)
+}
+
+// SendChange emails a change notification to the case recipients
+func (s *SMTPClient) SendChange(msg Message) error {
+	return s.sendToRecipients(msg, msg.Recipients, !msg.CopiesSent)
+}
+
+// SendAlert emails an alert to the configured recipients, who operate the tracker
+// CC and BCC addresses are left out; they only follow the cases
+func (s *SMTPClient) SendAlert(msg Message) error {
+	return s.sendToRecipients(msg, s.to, false)
+}
+
+// sendToRecipients emails a notification to each of its recipients separately,
+// so people tracking different cases don't see each other's addresses
+// Falls back to the configured recipients when there are none; withCopies adds the
+// CC and BCC addresses to the first email that goes out
+func (s *SMTPClient) sendToRecipients(msg Message, recipients []string, withCopies bool) error {
+	if len(recipients) == 0 {
+		recipients = s.to
+	}
+
+	attachments := loadAttachments(msg.Attachments)
+	text := messageText(msg)
+	return sendEach(recipients, withCopies, func(to string, withCopies bool) error {
+		return s.sendEmail(msg.Context(), to, msg.Subject, msg.HTML+msg.Footer, text, withCopies, attachments)
+	})
+}
+
+// sendEmail composes an email and delivers it over a new connection; withCopies adds
+// the CC and BCC addresses
+// SMTP has no idempotency keys, so a connection lost after DATA may deliver a retry twice
+func (s *SMTPClient) sendEmail(ctx context.Context, to, subject, html, text string, withCopies bool, attachments []*resend.Attachment) error {
+	from, err := mail.ParseAddress(s.from)
+	if err != nil {
+		return fmt.Errorf("invalid sender address %q: %w", s.from, err)
+	}
+	var cc, bcc []string
+	if withCopies {
+		cc, bcc = s.cc, s.bcc
+	}
+	email, err := s.composeEmail(from, to, cc, subject, html, text, attachments)
+	if err != nil {
+		return fmt.Errorf("failed to compose email: %w", err)
+	}
+
+	client, err := s.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if s.username != "" {
+		// PlainAuth refuses to send the password unencrypted, except to localhost
+		if err := client.Auth(smtp.PlainAuth("", s.username, s.password, s.host)); err != nil {
+			return fmt.Errorf("failed to authenticate with SMTP server: %w", err)
+		}
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return fmt.Errorf("SMTP server rejected sender %s: %w", from.Address, err)
+	}
+	// BCC addresses are only envelope recipients, so they don't show in the headers
+	// The envelope takes bare addresses, without the names "Name <address>" may carry
+	for _, rcpt := range append(append([]string{to}, cc...), bcc...) {
+		address, err := mail.ParseAddress(rcpt)
+		if err != nil {
+			return fmt.Errorf("invalid recipient address: %w", err)
+		}
+		if err := client.Rcpt(address.Address); err != nil {
+			return fmt.Errorf("SMTP server rejected a recipient: %w", err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	if _, err := w.Write(email); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	// The server has accepted the email; a failed QUIT must not cause it to be sent again
+	client.Quit()
+	return nil
+}
+
+// dial connects to the SMTP server and secures the connection
+// The connection is cut when ctx ends, which fails the command in progress
+func (s *SMTPClient) dial(ctx context.Context) (*smtp.Client, error) {
+	addr := net.JoinHostPort(s.host, strconv.Itoa(s.port))
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+	tlsConfig := &tls.Config{ServerName: s.host}
+
+	var conn net.Conn
+	var err error
+	if s.security == SMTPSSL {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	conn.SetDeadline(time.Now().Add(smtpTimeout))
+	context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	if s.security == SMTPStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, fmt.Errorf("SMTP server %s does not offer STARTTLS", addr)
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to start TLS with SMTP server: %w", err)
+		}
+	}
+	return client, nil
+}
+
+// composeEmail builds the MIME message: the HTML and plain-text parts as alternatives,
+// wrapped together with the attachments when there are any
+func (s *SMTPClient) composeEmail(from *mail.Address, to string, cc []string, subject, html, text string, attachments []*resend.Attachment) ([]byte, error) {
+	var body bytes.Buffer
+	alternative := multipart.NewWriter(&body)
+	if err := writeQuotedPrintable(alternative, "text/plain; charset=utf-8", text); err != nil {
+		return nil, err
+	}
+	if err := writeQuotedPrintable(alternative, "text/html; charset=utf-8", html); err != nil {
+		return nil, err
+	}
+	if err := alternative.Close(); err != nil {
+		return nil, err
+	}
+	contentType := "multipart/alternative; boundary=" + alternative.Boundary()
+
+	if len(attachments) > 0 {
+		var mixedBody bytes.Buffer
+		mixed := multipart.NewWriter(&mixedBody)
+		part, err := mixed.CreatePart(textproto.MIMEHeader{"Content-Type": {contentType}})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := part.Write(body.Bytes()); err != nil {
+			return nil, err
+		}
+		for _, attachment := range attachments {
+			if err := writeAttachment(mixed, attachment); err != nil {
+				return nil, err
+			}
+		}
+		if err := mixed.Close(); err != nil {
+			return nil, err
+		}
+		body, contentType = mixedBody, "multipart/mixed; boundary="+mixed.Boundary()
+	}
+
+	headers := map[string]string{
+		"From":         from.String(),
+		"To":           to,
+		"Subject":      mime.QEncoding.Encode("utf-8", subject),
+		"Date":         time.Now().Format(time.RFC1123Z),
+		"Message-ID":   messageID(from.Address),
+		"MIME-Version": "1.0",
+		"Content-Type": contentType,
+	}
+	if len(cc) > 0 {
+		headers["Cc"] = strings.Join(cc, ", ")
+	}
+	if s.replyTo != "" {
+		headers["Reply-To"] = s.replyTo
+	}
+	for name, value := range s.headers {
+		headers[name] = value
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var email bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&email, "%s: %s\r\n", name, headers[name])
+	}
+	email.WriteString("\r\n")
+	email.Write(body.Bytes())
+	return email.Bytes(), nil
+}
+
+// writeQuotedPrintable adds a text part, quoted-printable so long HTML lines stay
+// within SMTP's line length limit
+func writeQuotedPrintable(w *multipart.Writer, contentType, content string) error {
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return err
+	}
+	qp := quotedprintable.NewWriter(part)
+	if _, err := qp.Write([]byte(content)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// writeAttachment adds a file as a base64 part
+func writeAttachment(w *multipart.Writer, attachment *resend.Attachment) error {
+	contentType := mime.TypeByExtension(filepath.Ext(attachment.Filename))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename})},
+	})
+	if err != nil {
+		return err
+	}
+	encoded := base64.StdEncoding.EncodeToString(attachment.Content)
+	for len(encoded) > 76 {
+		if _, err := part.Write([]byte(encoded[:76] + "\r\n")); err != nil {
+			return err
+		}
+		encoded = encoded[76:]
+	}
+	_, err = part.Write([]byte(encoded + "\r\n"))
+	return err
+}
+
+// messageID returns a unique Message-ID in the sender's domain
+func messageID(sender string) string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	domain := "localhost"
+	if at := strings.LastIndex(sender, "@"); at >= 0 {
+		domain = sender[at+1:]
+	}
+	return "<" + hex.EncodeToString(buf) + "@" + domain + ">"
+}
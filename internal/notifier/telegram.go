@@ -0,0 +1,232 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// telegramMaxMessage is Telegram's limit on the length of one message
+const telegramMaxMessage = 4096
+
+// TelegramClient handles notifications via a Telegram bot
+type TelegramClient struct {
+	httpClient *http.Client
+	apiBase    string
+	chatID     string
+}
+
+// NewTelegramClient creates a client that posts to one chat using the given bot token
+func NewTelegramClient(botToken, chatID string) *TelegramClient {
+	return &TelegramClient{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		apiBase:    "https://api.telegram.org/bot" + botToken,
+		chatID:     chatID,
+	}
+}
+
+// telegramResponse is the envelope of every Bot API response
+type telegramResponse struct {
+	OK          bool   `json:"ok"`
+	Description string `json:"description"`
+}
+
+// SendMessage sends a notification rendered from the same HTML as the email
+// The subject becomes a bold first line
+func (t *TelegramClient) SendMessage(subject, body string) error {
+	return t.sendMessage(context.Background(), subject, body)
+}
+
+// sendMessage is SendMessage under ctx
+func (t *TelegramClient) sendMessage(ctx context.Context, subject, body string) error {
+	text := "<b>" + html.EscapeString(subject) + "</b>\n\n" + TelegramHTML(body)
+	text = truncateTelegram(text)
+
+	err := t.send(ctx, text, "HTML")
+	if err != nil && strings.Contains(err.Error(), "can't parse entities") {
+		// Email bodies aren't always well-formed HTML; plain text always goes through
+		return t.send(ctx, truncateTelegram(subject+"\n\n"+stripTags(TelegramHTML(body))), "")
+	}
+	return err
+}
+
+// SendInitial posts an initial-status notification
+func (t *TelegramClient) SendInitial(msg Message) error {
+	return t.sendMessage(msg.Context(), msg.Subject, msg.HTML)
+}
+
+// SendChange posts a change notification
+func (t *TelegramClient) SendChange(msg Message) error {
+	return t.sendMessage(msg.Context(), msg.Subject, msg.HTML)
+}
+
+// SendAlert posts an alert, marked so it stands out in the chat
+func (t *TelegramClient) SendAlert(msg Message) error {
+	return t.sendMessage(msg.Context(), "⚠️ "+msg.Subject, msg.HTML)
+}
+
+// send posts one sendMessage request
+func (t *TelegramClient) send(ctx context.Context, text, parseMode string) error {
+	payload := map[string]interface{}{
+		"chat_id":                  t.chatID,
+		"text":                     text,
+		"disable_web_page_preview": true,
+	}
+	if parseMode != "" {
+		payload["parse_mode"] = parseMode
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal telegram message: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.apiBase+"/sendMessage", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create telegram request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		// Don't wrap the url.Error: its message contains the bot token
+		return fmt.Errorf("failed to send telegram message: request failed")
+	}
+	defer resp.Body.Close()
+
+	var result telegramResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to decode telegram response (status %d): %w", resp.StatusCode, err)
+	}
+	if !result.OK {
+		return fmt.Errorf("failed to send telegram message: %s", result.Description)
+	}
+	return nil
+}
+
+var (
+	styleBlockPattern = regexp.MustCompile(`(?is)<style.*?</style>`)
+	tagPattern        = regexp.MustCompile(`<(/?)([a-zA-Z0-9]+)([^>]*)>`)
+	hrefPattern       = regexp.MustCompile(`href\s*=\s*["']([^"']*)["']`)
+	preBlockPattern   = regexp.MustCompile(`(?s)<pre>.*?</pre>`)
+	blankLinesPattern = regexp.MustCompile(`\n{3,}`)
+)
+
+// TelegramHTML converts notification email HTML to the subset Telegram supports
+// Headings and <strong> become bold, list items become bullets and tables become rows
+func TelegramHTML(emailHTML string) string {
+	s := styleBlockPattern.ReplaceAllString(emailHTML, "")
+
+	s = tagPattern.ReplaceAllStringFunc(s, func(tag string) string {
+		m := tagPattern.FindStringSubmatch(tag)
+		closing, name := m[1] == "/", strings.ToLower(m[2])
+
+		switch name {
+		case "h1", "h2", "h3", "h4":
+			if closing {
+				return "</b>\n"
+			}
+			return "\n<b>"
+		case "strong", "b":
+			if closing {
+				return "</b>"
+			}
+			return "<b>"
+		case "em", "i":
+			if closing {
+				return "</i>"
+			}
+			return "<i>"
+		case "code", "pre":
+			if closing {
+				return "</" + name + ">"
+			}
+			return "<" + name + ">"
+		case "a":
+			if closing {
+				return "</a>"
+			}
+			if href := hrefPattern.FindStringSubmatch(m[3]); href != nil {
+				return `<a href="` + href[1] + `">`
+			}
+			return "<a>"
+		case "li":
+			if closing {
+				return "\n"
+			}
+			return "• "
+		case "td", "th":
+			if closing {
+				return "  "
+			}
+			return ""
+		case "tr":
+			if closing {
+				return "\n"
+			}
+			return ""
+		case "p", "div", "ul", "ol", "table", "br", "hr":
+			return "\n"
+		default:
+			// span, small, etc. carry only styling
+			return ""
+		}
+	})
+
+	// Normalize whitespace outside <pre> blocks; their indentation is meaningful
+	var out strings.Builder
+	last := 0
+	for _, loc := range preBlockPattern.FindAllStringIndex(s, -1) {
+		out.WriteString(normalizeLines(s[last:loc[0]]))
+		out.WriteString(s[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	out.WriteString(normalizeLines(s[last:]))
+
+	return strings.TrimSpace(blankLinesPattern.ReplaceAllString(out.String(), "\n\n"))
+}
+
+// normalizeLines trims every line of a text fragment
+func normalizeLines(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(line), " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// truncateTelegram keeps a message within Telegram's limit
+// Long <pre> blocks (the raw case JSON) are shortened first so the summary survives
+func truncateTelegram(text string) string {
+	if len([]rune(text)) <= telegramMaxMessage {
+		return text
+	}
+
+	text = preBlockPattern.ReplaceAllStringFunc(text, func(block string) string {
+		inner := []rune(strings.TrimSuffix(strings.TrimPrefix(block, "<pre>"), "</pre>"))
+		if len(inner) > 1500 {
+			inner = append(inner[:1500], []rune("\n…")...)
+		}
+		return "<pre>" + string(inner) + "</pre>"
+	})
+	if len([]rune(text)) <= telegramMaxMessage {
+		return text
+	}
+
+	// Still too long: fall back to plain text
+	plain := []rune(stripTags(text))
+	if len(plain) > telegramMaxMessage-1 {
+		plain = append(plain[:telegramMaxMessage-1], '…')
+	}
+	return string(plain)
+}
+
+// stripTags removes every tag, leaving plain text
+func stripTags(s string) string {
+	return html.UnescapeString(tagPattern.ReplaceAllString(s, ""))
+}
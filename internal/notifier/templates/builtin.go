@@ -0,0 +1,52 @@
+package templates
+
+// builtinTemplates are the templates used unless a file replaces them
+const builtinTemplates = `
+{{- define "initial"}}
+		<h2>Initial Case Status</h2>
+		<p><strong>Case ID:</strong> {{.CaseID}}{{if .Nickname}} ({{.Nickname}}){{end}}</p>
+		{{- if .Applicant}}
+		<p><strong>Applicant:</strong> {{.Applicant}}</p>
+		{{- end}}
+		<p><strong>Checked:</strong> {{.Time}}</p>
+		<p>This is the first status check for your case. Future emails will only be sent when changes are detected.</p>
+		<h3>Current Status:</h3>
+		{{template "status" .}}
+		<p><a href="{{.CaseURL}}">View your cases on myUSCIS</a></p>
+		<h3>Full Response:</h3>
+		<pre style="background-color: #f5f5f5; padding: 15px; border-radius: 5px; overflow-x: auto; font-family: monospace;">{{.JSON}}</pre>
+{{end}}
+
+{{- define "change"}}
+		<h2>USCIS Case Status Update Detected!</h2>
+		<p><strong>Case ID:</strong> {{.CaseID}}{{if .Nickname}} ({{.Nickname}}){{end}}</p>
+		{{- if .Applicant}}
+		<p><strong>Applicant:</strong> {{.Applicant}}</p>
+		{{- end}}
+		<p><strong>Detected:</strong> {{.Time}}</p>
+		<p>The following changes were detected in your case status:</p>
+		{{template "changes" .}}
+		<h3>Current Status:</h3>
+		{{template "status" .}}
+		<p><a href="{{.CaseURL}}">View your cases on myUSCIS</a></p>
+		<h3>Full Response:</h3>
+		<pre style="background-color: #f5f5f5; padding: 15px; border-radius: 5px; overflow-x: auto; font-family: monospace;">{{.JSON}}</pre>
+{{end}}
+
+{{- define "status"}}
+{{- if .Rows}}<table style="border-collapse: collapse;">
+{{- range .Rows}}<tr><td style="padding: 4px 12px; vertical-align: top;"><strong>{{.Label}}</strong></td><td style="padding: 4px 12px;">{{.Value}}</td></tr>{{end -}}
+</table>
+{{- else}}<p>No status available.</p>{{end}}
+{{- end}}
+
+{{- define "changes"}}<ul>
+{{- range .Changes}}
+{{- if .Added}}<li><strong>{{.Field}}</strong>: <span style="color: green;">{{.New}}</span> (new field)</li>
+{{- else if .Removed}}<li><strong>{{.Field}}</strong>: <span style="color: red;">{{.Old}}</span> (removed)</li>
+{{- else}}<li><strong>{{.Field}}</strong>: <span style="color: red;">{{.Old}}</span> → <span style="color: green;">{{.New}}</span></li>
+{{- end}}
+{{- end -}}
+</ul>
+{{- end}}
+`
@@ -0,0 +1,255 @@
+// Package templates renders the HTML of case notification emails
+// The built-in templates can be replaced one by one with files in a directory
+package templates
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/locale"
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// Names of the templates the tracker renders; a file <name>.html in the template
+// directory replaces the built-in one
+const (
+	Initial = "initial" // first status of a newly tracked case
+	Change  = "change"  // detected change of a case
+	Status  = "status"  // status table, also used in bundle and digest emails
+	Changes = "changes" // list of changes, also used in bundle and digest emails
+)
+
+var names = []string{Initial, Change, Status, Changes}
+
+// AccountURL is where the applicant sees their cases on myUSCIS
+const AccountURL = "https://my.uscis.gov/account/applicant"
+
+// maxStatusActions limits how many recent history entries the status table lists
+const maxStatusActions = 5
+
+// Data is what a template can use
+type Data struct {
+	Brand     string
+	CaseID    string
+	Nickname  string // from CASE_NICKNAMES, "" if the case has none
+	Name      string // the nickname, or the case ID without one
+	FormType  string // e.g. "I-485", from the status or else CASE_FORM_TYPES
+	Applicant string // from CASE_APPLICANTS, "" if the case has none
+	Time      string // when the status was checked, in the recipient's format
+	Status    string // headline status, in the recipient's language
+	Event     string // what the change is, e.g. "Request for Evidence"; "" for the initial status
+	Severity  string // info, notice, important or critical; "" for the initial status
+	Rows      []Row  // typed fields of the case, for the status table
+	Changes   []ChangeRow
+	CaseURL   string // the applicant's myUSCIS account page
+	JSON      string // the full response, indented
+}
+
+// Row is a line of the status table
+type Row struct {
+	Label string
+	Value string
+}
+
+// ChangeRow is a change of one field, with values formatted for display
+type ChangeRow struct {
+	Field   string
+	Old     string
+	New     string
+	Added   bool // the field is new
+	Removed bool // the field is gone
+}
+
+// NewData fills in the case, its status and its changes for the recipient's locale
+// The caller adds the brand, nickname and event
+func NewData(caseID string, status *uscis.CaseStatus, changes []uscis.Change, loc locale.Settings, checked time.Time) Data {
+	data := Data{
+		CaseID:  caseID,
+		Name:    caseID,
+		Time:    loc.FormatDateTime(checked),
+		Rows:    statusRows(status, loc),
+		CaseURL: AccountURL,
+	}
+	if status != nil {
+		data.FormType = status.FormType
+		data.Status = status.String()
+		if status.StatusTitle != "" {
+			data.Status = loc.Status(status.StatusTitle)
+		}
+		jsonBytes, _ := json.MarshalIndent(status, "", "  ")
+		data.JSON = string(jsonBytes)
+	}
+	for _, change := range LocalizeChanges(changes, loc) {
+		row := ChangeRow{Field: change.Field, Added: change.OldValue == nil, Removed: change.NewValue == nil}
+		if change.OldValue != nil {
+			row.Old = fmt.Sprint(change.OldValue)
+		}
+		if change.NewValue != nil {
+			row.New = fmt.Sprint(change.NewValue)
+		}
+		data.Changes = append(data.Changes, row)
+	}
+	return data
+}
+
+// statusRows lists the typed fields of a case; fields missing from the payload are left out
+func statusRows(status *uscis.CaseStatus, loc locale.Settings) []Row {
+	if status == nil {
+		return nil
+	}
+	var rows []Row
+	add := func(label, value string) {
+		if value != "" {
+			rows = append(rows, Row{Label: label, Value: value})
+		}
+	}
+
+	statusText := status.String()
+	if status.StatusTitle != "" {
+		statusText = loc.Status(status.StatusTitle)
+	}
+	add("Status", statusText)
+	add("Description", status.Description)
+	add("Form", status.FormType)
+	add("Receipt Number", status.ReceiptNumber)
+	if !status.LastUpdated.IsZero() {
+		add("Last Updated", loc.FormatDate(status.LastUpdated))
+	}
+
+	actions := status.Actions
+	if len(actions) > maxStatusActions {
+		actions = actions[len(actions)-maxStatusActions:]
+	}
+	for i := len(actions) - 1; i >= 0; i-- {
+		label := ""
+		if i == len(actions)-1 {
+			label = "Recent History"
+		}
+		entry := actions[i].Description
+		if translated, ok := loc.TranslateStatus(entry); ok {
+			entry = translated + " (" + entry + ")"
+		}
+		if !actions[i].Date.IsZero() {
+			entry = loc.FormatDate(actions[i].Date) + ": " + entry
+		}
+		rows = append(rows, Row{Label: label, Value: entry})
+	}
+	return rows
+}
+
+// LocalizeChanges translates the old and new values of status changes
+func LocalizeChanges(changes []uscis.Change, loc locale.Settings) []uscis.Change {
+	localized := make([]uscis.Change, len(changes))
+	for i, change := range changes {
+		if change.Field == "Status" {
+			if s, ok := change.OldValue.(string); ok {
+				change.OldValue = loc.Status(s)
+			}
+			if s, ok := change.NewValue.(string); ok {
+				change.NewValue = loc.Status(s)
+			}
+		}
+		localized[i] = change
+	}
+	return localized
+}
+
+// Renderer renders notification emails from the built-in templates and any replacements
+type Renderer struct {
+	builtin *template.Template
+	custom  *template.Template // nil without replacements
+	files   []string           // replacement files, for the startup log
+}
+
+// New parses the built-in templates and the replacements in dir ("" for none)
+// Every *.html file defines the template named after it; files other than the
+// tracker's templates can hold partials for them
+func New(dir string) (*Renderer, error) {
+	builtin := template.Must(template.New("").Parse(builtinTemplates))
+	r := &Renderer{builtin: builtin}
+	if dir == "" {
+		return r, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.html"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list email templates: %w", err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no *.html email templates in %s", dir)
+	}
+	custom := template.Must(builtin.Clone())
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read email template: %w", err)
+		}
+		name := strings.TrimSuffix(filepath.Base(file), ".html")
+		if _, err := custom.New(name).Parse(string(content)); err != nil {
+			return nil, fmt.Errorf("failed to parse email template %s: %w", filepath.Base(file), err)
+		}
+		r.files = append(r.files, filepath.Base(file))
+	}
+	r.custom = custom
+
+	// Catch references to fields that don't exist now rather than at the next change
+	for _, name := range names {
+		var buf bytes.Buffer
+		if err := custom.ExecuteTemplate(&buf, name, sampleData()); err != nil {
+			return nil, fmt.Errorf("email template %s does not render: %w", name, err)
+		}
+	}
+	return r, nil
+}
+
+// Files lists the replacement template files
+func (r *Renderer) Files() []string {
+	return r.files
+}
+
+// Render renders the named template
+// A replacement that fails is logged and the built-in template is used instead, so a
+// broken template never costs a notification
+func (r *Renderer) Render(name string, data Data) string {
+	var buf bytes.Buffer
+	if r.custom != nil {
+		err := r.custom.ExecuteTemplate(&buf, name, data)
+		if err == nil {
+			return buf.String()
+		}
+		log.Printf("Warning: Email template %s failed, using the built-in one: %v", name, err)
+		buf.Reset()
+	}
+	if err := r.builtin.ExecuteTemplate(&buf, name, data); err != nil {
+		// The built-in templates are rendered at startup; this is a bug
+		log.Printf("Warning: Built-in email template %s failed: %v", name, err)
+	}
+	return buf.String()
+}
+
+// sampleData is a change with every field set, to try templates at startup
+func sampleData() Data {
+	return Data{
+		Brand:     "Case Tracker",
+		CaseID:    "IOE0000000000",
+		Nickname:  "Sample",
+		Name:      "Sample",
+		FormType:  "I-485",
+		Applicant: "Jane Doe",
+		Time:      "Jan 2, 2006 3:04 PM MST",
+		Status:    "Case Was Approved",
+		Event:     "Case Approved",
+		Severity:  "important",
+		Rows:      []Row{{Label: "Status", Value: "Case Was Approved"}},
+		Changes:   []ChangeRow{{Field: "Status", Old: "Case Was Received", New: "Case Was Approved"}},
+		CaseURL:   AccountURL,
+		JSON:      "{}",
+	}
+}
@@ -0,0 +1,102 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// WebhookClient posts notifications as JSON to an arbitrary HTTP endpoint
+type WebhookClient struct {
+	httpClient *http.Client
+	url        string
+}
+
+// NewWebhookClient creates a client that posts to the given URL
+func NewWebhookClient(url string) *WebhookClient {
+	return &WebhookClient{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		url:        url,
+	}
+}
+
+// webhookPayload is the JSON document posted for every notification
+type webhookPayload struct {
+	ID      string    `json:"id,omitempty"` // Same on retries of one notification
+	Kind    string    `json:"kind"`         // "initial", "change" or "alert"
+	CaseIDs []string  `json:"case_ids"`
+	Subject string    `json:"subject"`
+	HTML    string    `json:"html"`
+	SentAt  time.Time `json:"sent_at"`
+}
+
+// SendInitial posts an initial-status notification
+func (c *WebhookClient) SendInitial(msg Message) error {
+	return c.post("initial", msg)
+}
+
+// SendChange posts a change notification
+func (c *WebhookClient) SendChange(msg Message) error {
+	return c.post("change", msg)
+}
+
+// SendAlert posts an alert
+func (c *WebhookClient) SendAlert(msg Message) error {
+	return c.post("alert", msg)
+}
+
+// post sends one webhook request
+func (c *WebhookClient) post(kind string, msg Message) error {
+	caseIDs := msg.CaseIDs
+	if caseIDs == nil {
+		caseIDs = []string{}
+	}
+	data, err := json.Marshal(webhookPayload{
+		ID:      msg.ID,
+		Kind:    kind,
+		CaseIDs: caseIDs,
+		Subject: msg.Subject,
+		HTML:    msg.HTML,
+		SentAt:  time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(msg.Context(), http.MethodPost, c.url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if msg.ID != "" {
+		req.Header.Set("Idempotency-Key", msg.ID)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		// Don't wrap the url.Error: the webhook URL may hold a secret
+		return fmt.Errorf("failed to send webhook: request failed")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to send webhook: status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// WebhookDestination describes a webhook URL for logs without its path or query,
+// which often hold a secret
+func WebhookDestination(hook string) string {
+	u, err := url.Parse(hook)
+	if err != nil {
+		return "webhook"
+	}
+	return u.Scheme + "://" + u.Host
+}
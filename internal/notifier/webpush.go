@@ -0,0 +1,361 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// webPushMaxBody caps the notification text, keeping the encrypted payload well
+	// under the 4096 bytes push services accept
+	webPushMaxBody = 1000
+	// webPushRecordSize is the aes128gcm record size; the payload is a single record
+	webPushRecordSize = 4096
+	// webPushTTL is how long a push service keeps a notification for an offline device
+	webPushTTL = 24 * time.Hour
+)
+
+// PushSubscription is where and how to deliver push messages to one browser
+type PushSubscription struct {
+	Endpoint string // Push service URL of the subscription
+	P256dh   string // Browser's public key, base64url
+	Auth     string // Authentication secret, base64url
+}
+
+// Validate checks that the subscription's key and secret can encrypt messages
+func (s PushSubscription) Validate() error {
+	key, err := decodeBase64URL(s.P256dh)
+	if err == nil {
+		_, err = ecdh.P256().NewPublicKey(key)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid p256dh key")
+	}
+	if secret, err := decodeBase64URL(s.Auth); err != nil || len(secret) != 16 {
+		return fmt.Errorf("invalid auth secret")
+	}
+	return nil
+}
+
+// WebPushClient handles notifications via the Web Push protocol (RFC 8030), to every
+// browser that subscribed on the dashboard
+// Payloads are encrypted for each browser (RFC 8291) and requests are signed with the
+// application server key (VAPID, RFC 8292), so no push provider account is needed
+type WebPushClient struct {
+	httpClient    *http.Client
+	key           *ecdsa.PrivateKey
+	contact       string
+	clickURL      string
+	subscriptions func() ([]PushSubscription, error)
+
+	// OnGone is called with the endpoint of a subscription the push service no longer
+	// knows (the browser unsubscribed or the app was uninstalled)
+	OnGone func(endpoint string)
+}
+
+// NewWebPushClient creates a client that pushes to the subscriptions returned by
+// subscriptions at send time
+// contact is the mailto: or https: URL push services can reach the operator at, and
+// clickURL the page opened when a notification is tapped
+func NewWebPushClient(key *ecdsa.PrivateKey, contact, clickURL string, subscriptions func() ([]PushSubscription, error)) *WebPushClient {
+	return &WebPushClient{
+		httpClient:    &http.Client{Timeout: 30 * time.Second},
+		key:           key,
+		contact:       contact,
+		clickURL:      clickURL,
+		subscriptions: subscriptions,
+	}
+}
+
+// webPushPayload is the JSON document the service worker receives and displays
+type webPushPayload struct {
+	Kind  string `json:"kind"` // "initial", "change" or "alert"
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	Tag   string `json:"tag,omitempty"` // Same on retries, so a retry replaces the notification
+	URL   string `json:"url"`
+}
+
+// SendInitial pushes an initial-status notification
+func (c *WebPushClient) SendInitial(msg Message) error {
+	return c.push("initial", "normal", msg)
+}
+
+// SendChange pushes a change notification
+func (c *WebPushClient) SendChange(msg Message) error {
+	return c.push("change", "high", msg)
+}
+
+// SendAlert pushes an alert
+func (c *WebPushClient) SendAlert(msg Message) error {
+	return c.push("alert", "high", msg)
+}
+
+// push delivers one notification to every subscription
+// It succeeds when at least one browser got it, or when there is no subscription
+func (c *WebPushClient) push(kind, urgency string, msg Message) error {
+	subs, err := c.subscriptions()
+	if err != nil {
+		return fmt.Errorf("failed to load push subscriptions: %w", err)
+	}
+
+	payload, err := json.Marshal(webPushPayload{
+		Kind:  kind,
+		Title: msg.Subject,
+		Body:  truncateRunes(PlainText(msg.HTML), webPushMaxBody),
+		Tag:   msg.ID,
+		URL:   c.clickURL,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal push payload: %w", err)
+	}
+
+	var errs []error
+	delivered := 0
+	for _, sub := range subs {
+		gone, err := c.send(msg.Context(), sub, urgency, payload)
+		switch {
+		case gone:
+			if c.OnGone != nil {
+				c.OnGone(sub.Endpoint)
+			}
+		case err != nil:
+			errs = append(errs, err)
+		default:
+			delivered++
+		}
+	}
+	if delivered == 0 && len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+// send posts one encrypted message to a subscription's push service
+// gone reports that the subscription expired
+func (c *WebPushClient) send(ctx context.Context, sub PushSubscription, urgency string, payload []byte) (gone bool, err error) {
+	body, err := encryptPushPayload(sub, payload)
+	if err != nil {
+		return false, err
+	}
+	authorization, err := c.vapidAuthorization(sub.Endpoint)
+	if err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create push request")
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", fmt.Sprint(int(webPushTTL.Seconds())))
+	req.Header.Set("Urgency", urgency)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		// Don't wrap the url.Error: the endpoint URL identifies the subscription
+		return false, fmt.Errorf("failed to send push message to %s: request failed", PushDestination(sub.Endpoint))
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return true, nil
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		text, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return false, fmt.Errorf("failed to send push message to %s: status %d: %s", PushDestination(sub.Endpoint), resp.StatusCode, strings.TrimSpace(string(text)))
+	}
+	return false, nil
+}
+
+// vapidAuthorization returns the Authorization header identifying the tracker to the
+// push service of endpoint
+func (c *WebPushClient) vapidAuthorization(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid push endpoint")
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": time.Now().Add(12 * time.Hour).Unix(),
+		"sub": c.contact,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal VAPID claims: %w", err)
+	}
+
+	unsigned := base64url([]byte(`{"typ":"JWT","alg":"ES256"}`)) + "." + base64url(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, c.key, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign VAPID token: %w", err)
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	publicKey, err := VAPIDPublicKey(c.key)
+	if err != nil {
+		return "", err
+	}
+	return "vapid t=" + unsigned + "." + base64url(signature) + ", k=" + publicKey, nil
+}
+
+// encryptPushPayload encrypts a payload for one subscription with the aes128gcm
+// content coding, as RFC 8291 specifies
+func encryptPushPayload(sub PushSubscription, plaintext []byte) ([]byte, error) {
+	uaPublicBytes, err := decodeBase64URL(sub.P256dh)
+	if err != nil {
+		return nil, fmt.Errorf("invalid push subscription key: %w", err)
+	}
+	authSecret, err := decodeBase64URL(sub.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("invalid push subscription secret: %w", err)
+	}
+	uaPublic, err := ecdh.P256().NewPublicKey(uaPublicBytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid push subscription key: %w", err)
+	}
+
+	// A fresh key pair per message; its public half travels in the header
+	asPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate push message key: %w", err)
+	}
+	asPublicBytes := asPrivate.PublicKey().Bytes()
+	shared, err := asPrivate.ECDH(uaPublic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive push message key: %w", err)
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate push message salt: %w", err)
+	}
+	prkKey, err := hkdf.Extract(sha256.New, shared, authSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive push message key: %w", err)
+	}
+	ikm, err := hkdf.Expand(sha256.New, prkKey, "WebPush: info\x00"+string(uaPublicBytes)+string(asPublicBytes), 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive push message key: %w", err)
+	}
+	prk, err := hkdf.Extract(sha256.New, ikm, salt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive push message key: %w", err)
+	}
+	cek, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive push message key: %w", err)
+	}
+	nonce, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive push message nonce: %w", err)
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create push message cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create push message cipher: %w", err)
+	}
+
+	// Header: salt, record size, key ID (the public key); then the single, last record
+	header := make([]byte, 0, 16+4+1+len(asPublicBytes))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, webPushRecordSize)
+	header = append(header, byte(len(asPublicBytes)))
+	header = append(header, asPublicBytes...)
+	record := append(append([]byte{}, plaintext...), 0x02)
+	return gcm.Seal(header, nonce, record, nil), nil
+}
+
+// GenerateVAPIDKey creates an application server key, PEM-encoded
+func GenerateVAPIDKey() (string, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate VAPID key: %w", err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal VAPID key: %w", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})), nil
+}
+
+// ParseVAPIDKey decodes a key created by GenerateVAPIDKey
+func ParseVAPIDKey(encoded string) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(encoded))
+	if block == nil {
+		return nil, fmt.Errorf("failed to parse VAPID key: not PEM-encoded")
+	}
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse VAPID key: %w", err)
+	}
+	return key, nil
+}
+
+// VAPIDPublicKey returns the public half of an application server key in the form
+// browsers take as applicationServerKey: the uncompressed point, base64url
+func VAPIDPublicKey(key *ecdsa.PrivateKey) (string, error) {
+	public, err := key.PublicKey.ECDH()
+	if err != nil {
+		return "", fmt.Errorf("invalid VAPID key: %w", err)
+	}
+	return base64url(public.Bytes()), nil
+}
+
+// PushDestination describes a push endpoint for logs by its push service, without
+// the path identifying the subscription
+func PushDestination(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "push service"
+	}
+	return u.Host
+}
+
+var whitespacePattern = regexp.MustCompile(`[ \t]+`)
+
+// PlainText converts notification email HTML to plain text lines
+func PlainText(emailHTML string) string {
+	text := stripTags(TelegramHTML(emailHTML))
+	text = whitespacePattern.ReplaceAllString(text, " ")
+	return strings.TrimSpace(blankLinesPattern.ReplaceAllString(text, "\n\n"))
+}
+
+// base64url encodes without padding, as JWTs and the Push API use
+func base64url(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeBase64URL decodes base64url with or without padding
+func decodeBase64URL(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
@@ -0,0 +1,99 @@
+package pdf
+
+import "strings"
+
+// Glyph widths of the printable ASCII characters (32-126) in thousandths of the font
+// size, from the Adobe font metrics of the standard fonts
+var asciiWidths = map[string][95]int{
+	fontRegular: {
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+	},
+	fontBold: {
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+	},
+}
+
+// defaultWidth is used for the accented letters and symbols outside ASCII; close
+// enough for line wrapping
+const defaultWidth = 556
+
+// winAnsiExtras are the typographic characters Windows-1252 places in 0x80-0x9F, which
+// text copied from USCIS pages and notes tends to contain
+var winAnsiExtras = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '‘': 0x91, '’': 0x92,
+	'“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99,
+}
+
+// Encodable reports whether text can be printed as is, i.e. every character is in the
+// Windows-1252 set of the standard fonts
+func Encodable(text string) bool {
+	for _, r := range text {
+		if _, ok := encodeRune(r); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeRune maps a character to its WinAnsiEncoding byte
+func encodeRune(r rune) (byte, bool) {
+	switch {
+	case r == '\t':
+		return ' ', true
+	case r >= 32 && r <= 126, r >= 160 && r <= 255:
+		return byte(r), true
+	}
+	b, ok := winAnsiExtras[r]
+	return b, ok
+}
+
+// escape encodes text as the contents of a PDF string literal
+func escape(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		c, ok := encodeRune(r)
+		if !ok {
+			c = '?'
+		}
+		if c == '(' || c == ')' || c == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// textWidth measures text in points
+func textWidth(font string, size float64, text string) float64 {
+	widths := asciiWidths[font]
+	total := 0
+	for _, r := range text {
+		c, ok := encodeRune(r)
+		if !ok {
+			c = '?'
+		}
+		switch {
+		case c >= 32 && c <= 126:
+			total += widths[c-32]
+		case c == 0x97 || c == 0x85:
+			total += 1000
+		case c == 0x95:
+			total += 350
+		case c >= 0x91 && c <= 0x94, c == 0x82, c == 0x84:
+			total += 333
+		default:
+			total += defaultWidth
+		}
+	}
+	return float64(total) * size / 1000
+}
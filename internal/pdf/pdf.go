@@ -0,0 +1,207 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Page geometry in points (US Letter with 0.75in margins)
+const (
+	pageWidth    = 612.0
+	pageHeight   = 792.0
+	margin       = 54.0
+	contentWidth = pageWidth - 2*margin
+	footerY      = 30.0
+)
+
+// Fonts are the standard Helvetica faces every PDF reader has, so nothing is embedded
+const (
+	fontRegular = "F1"
+	fontBold    = "F2"
+)
+
+// Document is a simple text document laid out top to bottom over as many pages as needed
+// Text is limited to the Windows-1252 character set of the standard fonts; other
+// characters are printed as "?" (see Encodable)
+type Document struct {
+	title   string
+	created time.Time
+	pages   []*bytes.Buffer
+	y       float64 // baseline of the next line on the current page
+}
+
+// New creates an empty document; the title is stored in the document info and printed
+// in every page footer
+func New(title string) *Document {
+	d := &Document{title: title, created: time.Now()}
+	d.newPage()
+	return d
+}
+
+// Title prints a large bold line
+func (d *Document) Title(text string) {
+	d.lines(fontBold, 18, text)
+	d.Space(6)
+}
+
+// Heading prints a bold section heading, starting a new page if the heading would
+// otherwise be the last line on its page
+func (d *Document) Heading(text string) {
+	d.Space(10)
+	if d.y-3*lineHeight(10) < margin {
+		d.newPage()
+	}
+	d.lines(fontBold, 12, text)
+	d.Space(2)
+}
+
+// Text prints a wrapped paragraph
+func (d *Document) Text(text string) {
+	d.lines(fontRegular, 10, text)
+}
+
+// Field prints a bold label followed by a wrapped value
+func (d *Document) Field(label, value string) {
+	d.Row(label, value, 130)
+}
+
+// Row prints two columns: a label of the given width and a wrapped text beside it
+// The text may contain newlines
+func (d *Document) Row(label, text string, labelWidth float64) {
+	labelLines := wrap(fontBold, 10, labelWidth-8, label)
+	textLines := wrap(fontRegular, 10, contentWidth-labelWidth, text)
+	for i := 0; i < len(labelLines) || i < len(textLines); i++ {
+		d.ensureSpace(lineHeight(10))
+		if i < len(labelLines) {
+			d.show(fontBold, 10, margin, labelLines[i])
+		}
+		if i < len(textLines) {
+			d.show(fontRegular, 10, margin+labelWidth, textLines[i])
+		}
+		d.y -= lineHeight(10)
+	}
+	d.Space(2)
+}
+
+// Space adds vertical space
+func (d *Document) Space(points float64) {
+	d.y -= points
+}
+
+// Bytes finishes the document and returns the PDF file
+func (d *Document) Bytes() ([]byte, error) {
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	// Objects 1-4 are fixed; each page then takes a page and a content object
+	pageRefs := make([]string, len(d.pages))
+	for i := range d.pages {
+		pageRefs[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(pageRefs, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, page := range d.pages {
+		// The footer needs the page count, so it's added now
+		footer := fmt.Sprintf("%s - page %d of %d", d.title, i+1, len(d.pages))
+		fmt.Fprintf(page, "BT /%s 8 Tf %.2f %.2f Td (%s) Tj ET\n", fontRegular, margin, footerY, escape(footer))
+
+		var content bytes.Buffer
+		zw := zlib.NewWriter(&content)
+		if _, err := zw.Write(page.Bytes()); err != nil {
+			return nil, fmt.Errorf("failed to compress page: %w", err)
+		}
+		if err := zw.Close(); err != nil {
+			return nil, fmt.Errorf("failed to compress page: %w", err)
+		}
+
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /%s 3 0 R /%s 4 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, fontRegular, fontBold, 6+2*i))
+		object(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", content.Len(), content.Bytes()))
+	}
+	object(fmt.Sprintf("<< /Title (%s) /Producer (case-tracker) /CreationDate (D:%s) >>", escape(d.title), d.created.UTC().Format("20060102150405Z")))
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, len(offsets), xref)
+	return out.Bytes(), nil
+}
+
+// lines prints wrapped text in one font across the full content width
+func (d *Document) lines(font string, size float64, text string) {
+	for _, line := range wrap(font, size, contentWidth, text) {
+		d.ensureSpace(lineHeight(size))
+		d.show(font, size, margin, line)
+		d.y -= lineHeight(size)
+	}
+}
+
+// ensureSpace starts a new page when the next line wouldn't fit above the bottom margin
+func (d *Document) ensureSpace(height float64) {
+	if d.y-height < margin {
+		d.newPage()
+	}
+}
+
+// newPage starts a page and moves to its top
+func (d *Document) newPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.y = pageHeight - margin - lineHeight(10)
+}
+
+// show draws one line of text with its baseline at the current position
+func (d *Document) show(font string, size, x float64, text string) {
+	fmt.Fprintf(d.pages[len(d.pages)-1], "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, d.y, escape(text))
+}
+
+// lineHeight is the distance between baselines for a font size
+func lineHeight(size float64) float64 {
+	return size * 1.35
+}
+
+// wrap breaks text into lines no wider than width, at spaces where possible
+// Newlines in the text are kept; an empty text gives one empty line
+func wrap(font string, size, width float64, text string) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if textWidth(font, size, candidate) <= width {
+				line = candidate
+				continue
+			}
+			if line != "" {
+				lines = append(lines, line)
+			}
+			// A word longer than the line is split wherever it overflows
+			for textWidth(font, size, word) > width {
+				cut := len([]rune(word)) - 1
+				for cut > 1 && textWidth(font, size, string([]rune(word)[:cut])) > width {
+					cut--
+				}
+				lines = append(lines, string([]rune(word)[:cut]))
+				word = string([]rune(word)[cut:])
+			}
+			line = word
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
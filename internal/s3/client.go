@@ -0,0 +1,193 @@
+// Package s3 is a small client for Amazon S3 and S3-compatible services like MinIO,
+// covering what the tracker needs: putting, getting, deleting and listing objects
+// It wraps the AWS SDK, authenticating with static keys or the SDK's default
+// credential chain
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	awss3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ErrNotFound is returned for an object that doesn't exist
+var ErrNotFound = errors.New("object not found")
+
+// Config locates a bucket and says how to authenticate
+type Config struct {
+	Bucket    string
+	Region    string
+	Endpoint  string // S3-compatible service, e.g. http://minio:9000; "" for AWS
+	PathStyle bool   // address the bucket in the path rather than the host name
+
+	// Static keys; both empty uses the default credential chain
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// requestTimeout bounds each request, retries included
+const requestTimeout = time.Minute
+
+// Client talks to one bucket
+type Client struct {
+	api    *awss3.Client
+	creds  aws.CredentialsProvider
+	bucket string
+	static bool
+}
+
+// New creates a client for a bucket
+// Credentials are looked up on the first request, so a missing role shows up there
+func New(cfg Config) (*Client, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("no bucket configured")
+	}
+	if cfg.Endpoint != "" {
+		u, err := url.Parse(cfg.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid S3 endpoint %q: expected http(s)://host[:port]", cfg.Endpoint)
+		}
+	}
+
+	options := []func(*awsconfig.LoadOptions) error{
+		awsconfig.WithRegion(cfg.Region),
+		awsconfig.WithHTTPClient(awshttp.NewBuildableClient().WithTimeout(requestTimeout)),
+	}
+	static := cfg.AccessKeyID != "" || cfg.SecretAccessKey != ""
+	if static {
+		options = append(options, awsconfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(cfg.AccessKeyID, cfg.SecretAccessKey, "")))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background(), options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+
+	api := awss3.NewFromConfig(awsCfg, func(o *awss3.Options) {
+		o.UsePathStyle = cfg.PathStyle
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+			// S3-compatible services don't all accept the SDK's default checksums
+			o.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
+			o.ResponseChecksumValidation = aws.ResponseChecksumValidationWhenRequired
+		}
+	})
+	return &Client{api: api, creds: awsCfg.Credentials, bucket: cfg.Bucket, static: static}, nil
+}
+
+// String returns the bucket as an s3:// URL, for logs
+func (c *Client) String() string {
+	return "s3://" + c.bucket
+}
+
+// CredentialSource names where the credentials come from, once a request was made
+func (c *Client) CredentialSource() string {
+	if c.static {
+		return "static keys"
+	}
+	if c.creds == nil {
+		return ""
+	}
+	// Cached by the SDK after the first request, so this doesn't look them up again
+	creds, err := c.creds.Retrieve(context.Background())
+	if err != nil {
+		return ""
+	}
+	return creds.Source
+}
+
+// Put writes an object, replacing any object of the same key
+func (c *Client) Put(key string, data []byte, contentType string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	input := &awss3.PutObjectInput{
+		Bucket:        aws.String(c.bucket),
+		Key:           aws.String(key),
+		Body:          bytes.NewReader(data),
+		ContentLength: aws.Int64(int64(len(data))),
+	}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+	if _, err := c.api.PutObject(ctx, input); err != nil {
+		return fmt.Errorf("failed to upload %s/%s: %w", c, key, err)
+	}
+	return nil
+}
+
+// Get reads an object; a missing object returns an error wrapping ErrNotFound
+func (c *Client) Get(key string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	out, err := c.api.GetObject(ctx, &awss3.GetObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s/%s: %w", c, key, notFound(err))
+	}
+	defer out.Body.Close()
+
+	data, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s/%s: %w", c, key, err)
+	}
+	return data, nil
+}
+
+// Delete removes an object; deleting a missing object succeeds
+func (c *Client) Delete(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	_, err := c.api.DeleteObject(ctx, &awss3.DeleteObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil && !errors.Is(notFound(err), ErrNotFound) {
+		return fmt.Errorf("failed to delete %s/%s: %w", c, key, err)
+	}
+	return nil
+}
+
+// List returns the keys starting with prefix in lexicographic order, following pagination
+func (c *Client) List(prefix string) ([]string, error) {
+	var keys []string
+	pages := awss3.NewListObjectsV2Paginator(c.api, &awss3.ListObjectsV2Input{
+		Bucket: aws.String(c.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for pages.HasMorePages() {
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		page, err := pages.NextPage(ctx)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s/%s: %w", c, prefix, err)
+		}
+		for _, object := range page.Contents {
+			keys = append(keys, aws.ToString(object.Key))
+		}
+	}
+	return keys, nil
+}
+
+// notFound returns ErrNotFound for the SDK's error about a missing object, and err
+// otherwise
+func notFound(err error) error {
+	var noSuchKey *types.NoSuchKey
+	var missing *types.NotFound
+	if errors.As(err, &noSuchKey) || errors.As(err, &missing) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,130 @@
+package source
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Fetcher fetches the current status of a tracked item as a JSON payload
+// Implemented by the USCIS clients (account API, browser, public status service)
+type Fetcher interface {
+	FetchCaseStatus(caseID string) (map[string]interface{}, error)
+}
+
+// Built-in source names
+const (
+	MyUSCIS = "myuscis" // myUSCIS account API, via browser auto-login or a session cookie
+	Public  = "public"  // public Case Status Online service, no login
+)
+
+// Canary is the source of the synthetic pipeline canary (CANARY_INTERVAL); it is
+// assigned by the configuration, never declared for a tracked item
+const Canary = "canary"
+
+// Account returns the source of the cases of an additional USCIS account
+// (USCIS_ACCOUNT_<N>_*), served like MyUSCIS by a login of its own: "myuscis:<name>"
+// The configuration assigns it to the account's cases, they never declare it
+func Account(name string) string {
+	return MyUSCIS + ":" + name
+}
+
+// IsAccount reports whether name is the source of an additional USCIS account
+func IsAccount(name string) bool {
+	return strings.HasPrefix(name, MyUSCIS+":")
+}
+
+// Known lists the source names a tracked item can declare
+var Known = []string{MyUSCIS, Public}
+
+// IsKnown reports whether name is a built-in source
+func IsKnown(name string) bool {
+	for _, known := range Known {
+		if name == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Registry routes each tracked item to the fetcher of its source
+// Items without an assignment use the default source. Assignments are set up before
+// polling starts; fetchers can be registered later, e.g. once an account's login succeeds
+type Registry struct {
+	mu            sync.RWMutex
+	fetchers      map[string]Fetcher
+	assignments   map[string]string
+	defaultSource string
+}
+
+// NewRegistry creates a registry routing items by assignment (item ID -> source name)
+func NewRegistry(defaultSource string, assignments map[string]string) *Registry {
+	return &Registry{
+		fetchers:      make(map[string]Fetcher),
+		assignments:   assignments,
+		defaultSource: defaultSource,
+	}
+}
+
+// Register sets the fetcher serving a source, replacing any previous one
+func (r *Registry) Register(name string, fetcher Fetcher) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fetchers[name] = fetcher
+}
+
+// Names returns the registered source names, sorted
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.names()
+}
+
+// names returns the registered source names, sorted; the caller holds mu
+func (r *Registry) names() []string {
+	names := make([]string, 0, len(r.fetchers))
+	for name := range r.fetchers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get returns the fetcher serving a source
+func (r *Registry) Get(name string) (Fetcher, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	fetcher, ok := r.fetchers[name]
+	return fetcher, ok
+}
+
+// SourceOf returns the source name an item is fetched from
+func (r *Registry) SourceOf(caseID string) string {
+	if name, ok := r.assignments[caseID]; ok {
+		return name
+	}
+	return r.defaultSource
+}
+
+// For returns the fetcher serving an item's source
+func (r *Registry) For(caseID string) (Fetcher, error) {
+	name := r.SourceOf(caseID)
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	fetcher, ok := r.fetchers[name]
+	if !ok {
+		return nil, fmt.Errorf("source %q for %s is not available (registered: %v)", name, caseID, r.names())
+	}
+	return fetcher, nil
+}
+
+// FetchCaseStatus fetches an item from its source
+// Fetcher errors are returned unwrapped so callers can inspect their type
+func (r *Registry) FetchCaseStatus(caseID string) (map[string]interface{}, error) {
+	fetcher, err := r.For(caseID)
+	if err != nil {
+		return nil, err
+	}
+	return fetcher.FetchCaseStatus(caseID)
+}
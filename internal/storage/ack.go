@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// PendingAck is a critical notification waiting for the recipient to confirm they saw it
+type PendingAck struct {
+	ID          string    `json:"id"`
+	CaseID      string    `json:"case_id"`
+	Status      string    `json:"status"` // The status line that made the notification critical
+	SentAt      time.Time `json:"sent_at"`
+	Escalations int       `json:"escalations"` // Fallback channels notified so far
+	NextAt      time.Time `json:"next_at"`     // When to escalate if still unacknowledged
+}
+
+// AckStore persists pending acknowledgements in {stateDir}/acks.json
+// It is safe for concurrent use
+type AckStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewAckStore creates an acknowledgement store under the state directory
+func NewAckStore(stateDir string) *AckStore {
+	return &AckStore{path: filepath.Join(stateDir, "acks.json")}
+}
+
+// Add records a notification that must be acknowledged
+func (s *AckStore) Add(ack *PendingAck) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acks, err := s.load()
+	if err != nil {
+		return err
+	}
+	acks[ack.ID] = ack
+	return s.save(acks)
+}
+
+// Update replaces a pending acknowledgement, unless it was acknowledged in the meantime
+func (s *AckStore) Update(ack *PendingAck) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acks, err := s.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := acks[ack.ID]; !ok {
+		return nil
+	}
+	acks[ack.ID] = ack
+	return s.save(acks)
+}
+
+// Remove drops a pending acknowledgement and returns it, or nil if it wasn't pending
+// (already acknowledged, or given up on)
+func (s *AckStore) Remove(id string) (*PendingAck, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acks, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	ack, ok := acks[id]
+	if !ok {
+		return nil, nil
+	}
+	delete(acks, id)
+	return ack, s.save(acks)
+}
+
+// Due returns the pending acknowledgements whose escalation time has come, oldest first
+func (s *AckStore) Due(now time.Time) ([]*PendingAck, error) {
+	acks, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	var due []*PendingAck
+	for _, ack := range acks {
+		if !now.Before(ack.NextAt) {
+			due = append(due, ack)
+		}
+	}
+	return due, nil
+}
+
+// List returns every pending acknowledgement, oldest first
+func (s *AckStore) List() ([]*PendingAck, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acks, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*PendingAck, 0, len(acks))
+	for _, ack := range acks {
+		list = append(list, ack)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].SentAt.Before(list[j].SentAt)
+	})
+	return list, nil
+}
+
+// load reads the acknowledgement file; a missing file means nothing is pending
+// Caller must hold the lock
+func (s *AckStore) load() (map[string]*PendingAck, error) {
+	acks := make(map[string]*PendingAck)
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return acks, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read acknowledgements: %w", err)
+	}
+	if err := json.Unmarshal(data, &acks); err != nil {
+		return nil, fmt.Errorf("failed to parse acknowledgements: %w", err)
+	}
+	return acks, nil
+}
+
+// save writes the acknowledgement file atomically
+// Caller must hold the lock
+func (s *AckStore) save(acks map[string]*PendingAck) error {
+	data, err := json.MarshalIndent(acks, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal acknowledgements: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	tempFile := s.path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write acknowledgements: %w", err)
+	}
+	if err := os.Rename(tempFile, s.path); err != nil {
+		return fmt.Errorf("failed to rename acknowledgements: %w", err)
+	}
+	return nil
+}
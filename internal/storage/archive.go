@@ -0,0 +1,443 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Kinds of archived records
+const (
+	ArchiveSnapshot = "snapshot"
+	ArchiveChange   = "change" // a row of the SQLite change log
+)
+
+// ArchiveRecord is one snapshot or logged change moved out of hot storage
+type ArchiveRecord struct {
+	Kind     string                 `json:"kind"`
+	CaseID   string                 `json:"case_id"`
+	Time     time.Time              `json:"time"`
+	Data     map[string]interface{} `json:"data,omitempty"`      // snapshots
+	Field    string                 `json:"field,omitempty"`     // changes
+	OldValue json.RawMessage        `json:"old_value,omitempty"` // changes, absent when the field was new
+	NewValue json.RawMessage        `json:"new_value,omitempty"` // changes, absent when the field was removed
+
+	id int64 // SQLite row, for deleting exactly what was archived
+}
+
+// Archiver is implemented by backends whose old history can be moved to an archive
+type Archiver interface {
+	// ArchiveRecords returns the records recorded before the given time, oldest first
+	// The latest snapshot is never included: the next poll compares against it
+	ArchiveRecords(before time.Time) ([]ArchiveRecord, error)
+	// DeleteRecords removes records returned by ArchiveRecords
+	DeleteRecords(records []ArchiveRecord) error
+	// RestoreRecords puts archived records back, skipping those already present,
+	// and returns how many were restored
+	RestoreRecords(records []ArchiveRecord) (int, error)
+}
+
+// EncodeArchive writes records as gzip-compressed JSON lines
+func EncodeArchive(records []ArchiveRecord) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(zw)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			return nil, fmt.Errorf("failed to encode archive record: %w", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress archive: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeArchive reads records written by EncodeArchive
+func DecodeArchive(data []byte) ([]ArchiveRecord, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress archive: %w", err)
+	}
+	defer zr.Close()
+
+	var records []ArchiveRecord
+	scanner := bufio.NewScanner(zr)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64<<20)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var record ArchiveRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("failed to parse archive record: %w", err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	return records, nil
+}
+
+// ArchiveRecords returns the snapshot files written before the given time, except the latest
+func (f *FileStorage) ArchiveRecords(before time.Time) ([]ArchiveRecord, error) {
+	snapshots, err := f.ListSnapshots()
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshots) > 0 {
+		snapshots = snapshots[:len(snapshots)-1]
+	}
+
+	var records []ArchiveRecord
+	for _, snap := range snapshots {
+		if !snap.Timestamp.Before(before) {
+			break
+		}
+		records = append(records, ArchiveRecord{Kind: ArchiveSnapshot, CaseID: f.caseID, Time: snap.Timestamp, Data: snap.Data})
+	}
+	return records, nil
+}
+
+// DeleteRecords removes archived snapshot files
+func (f *FileStorage) DeleteRecords(records []ArchiveRecord) error {
+	for _, record := range records {
+		if record.Kind != ArchiveSnapshot {
+			continue
+		}
+		if err := os.Remove(f.snapshotPath(record.Time)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to delete archived state file: %w", err)
+		}
+	}
+	return nil
+}
+
+// RestoreRecords writes archived snapshots back as state files
+// Change records are skipped: file storage has no change log
+func (f *FileStorage) RestoreRecords(records []ArchiveRecord) (int, error) {
+	restored := 0
+	for _, record := range records {
+		if record.Kind != ArchiveSnapshot {
+			continue
+		}
+		if _, err := os.Stat(f.snapshotPath(record.Time)); err == nil {
+			continue
+		}
+		if err := f.SaveAt(record.Time, record.Data); err != nil {
+			return restored, err
+		}
+		restored++
+	}
+	return restored, nil
+}
+
+// ArchiveRecords returns the snapshots and logged changes recorded before the given
+// time, except the latest snapshot
+func (s *SQLiteStorage) ArchiveRecords(before time.Time) ([]ArchiveRecord, error) {
+	var latestID int64
+	err := s.db.QueryRow(`SELECT id FROM snapshots WHERE case_id = ? ORDER BY recorded_at DESC, id DESC LIMIT 1`, s.caseID).Scan(&latestID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find latest snapshot: %w", err)
+	}
+
+	records, err := s.archivedSnapshots(before, latestID)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := s.archivedChanges(before)
+	if err != nil {
+		return nil, err
+	}
+	return append(records, changes...), nil
+}
+
+// archivedSnapshots reads the snapshots recorded before the given time, except one row
+func (s *SQLiteStorage) archivedSnapshots(before time.Time, keepID int64) ([]ArchiveRecord, error) {
+	rows, err := s.db.Query(`SELECT id, recorded_at, data FROM snapshots WHERE case_id = ? AND recorded_at < ? AND id != ? ORDER BY recorded_at, id`,
+		s.caseID, before.UnixMilli(), keepID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	var records []ArchiveRecord
+	for rows.Next() {
+		var (
+			record     = ArchiveRecord{Kind: ArchiveSnapshot, CaseID: s.caseID}
+			recordedAt int64
+			data       string
+		)
+		if err := rows.Scan(&record.id, &recordedAt, &data); err != nil {
+			return nil, fmt.Errorf("failed to read snapshot: %w", err)
+		}
+		if err := json.Unmarshal([]byte(data), &record.Data); err != nil {
+			return nil, fmt.Errorf("failed to parse snapshot: %w", err)
+		}
+		record.Time = time.UnixMilli(recordedAt)
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// archivedChanges reads the change log entries recorded before the given time
+func (s *SQLiteStorage) archivedChanges(before time.Time) ([]ArchiveRecord, error) {
+	rows, err := s.db.Query(`SELECT id, detected_at, field, old_value, new_value FROM changes WHERE case_id = ? AND detected_at < ? ORDER BY detected_at, id`,
+		s.caseID, before.UnixMilli())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query changes: %w", err)
+	}
+	defer rows.Close()
+
+	var records []ArchiveRecord
+	for rows.Next() {
+		var (
+			record             = ArchiveRecord{Kind: ArchiveChange, CaseID: s.caseID}
+			detectedAt         int64
+			oldValue, newValue sql.NullString
+		)
+		if err := rows.Scan(&record.id, &detectedAt, &record.Field, &oldValue, &newValue); err != nil {
+			return nil, fmt.Errorf("failed to read change: %w", err)
+		}
+		record.Time = time.UnixMilli(detectedAt)
+		if oldValue.Valid {
+			record.OldValue = json.RawMessage(oldValue.String)
+		}
+		if newValue.Valid {
+			record.NewValue = json.RawMessage(newValue.String)
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// DeleteRecords removes archived snapshots and changes in one transaction
+// Search entries are kept, so archived history stays searchable
+func (s *SQLiteStorage) DeleteRecords(records []ArchiveRecord) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, record := range records {
+		table := "snapshots"
+		if record.Kind == ArchiveChange {
+			table = "changes"
+		}
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE id = ? AND case_id = ?`, record.id, s.caseID); err != nil {
+			return fmt.Errorf("failed to delete archived %s: %w", record.Kind, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit archive deletion: %w", err)
+	}
+	return nil
+}
+
+// RestoreRecords inserts archived snapshots and changes that aren't in the database
+// Search entries were kept at archival time, so restored snapshots aren't indexed again
+func (s *SQLiteStorage) RestoreRecords(records []ArchiveRecord) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	restored := 0
+	for _, record := range records {
+		at := record.Time.UnixMilli()
+		var exists bool
+		switch record.Kind {
+		case ArchiveSnapshot:
+			err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM snapshots WHERE case_id = ? AND recorded_at = ?)`, s.caseID, at).Scan(&exists)
+		case ArchiveChange:
+			err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM changes WHERE case_id = ? AND detected_at = ? AND field = ?)`, s.caseID, at, record.Field).Scan(&exists)
+		default:
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to check archived %s: %w", record.Kind, err)
+		}
+		if exists {
+			continue
+		}
+
+		if record.Kind == ArchiveSnapshot {
+			data, err := json.Marshal(record.Data)
+			if err != nil {
+				return 0, fmt.Errorf("failed to marshal state: %w", err)
+			}
+			_, err = tx.Exec(`INSERT INTO snapshots (case_id, recorded_at, data) VALUES (?, ?, ?)`, s.caseID, at, string(data))
+		} else {
+			_, err = tx.Exec(`INSERT INTO changes (case_id, detected_at, field, old_value, new_value) VALUES (?, ?, ?, ?, ?)`,
+				s.caseID, at, record.Field, rawOrNull(record.OldValue), rawOrNull(record.NewValue))
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to restore %s: %w", record.Kind, err)
+		}
+		restored++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit restore: %w", err)
+	}
+	return restored, nil
+}
+
+// rawOrNull converts an optional JSON value to a nullable column value
+func rawOrNull(value json.RawMessage) sql.NullString {
+	if value == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: string(value), Valid: true}
+}
+
+// ArchiveRecords returns the snapshots and logged changes recorded before the given
+// time, except the latest snapshot
+func (s *PostgresStorage) ArchiveRecords(before time.Time) ([]ArchiveRecord, error) {
+	var latestID int64
+	err := s.db.QueryRow(`SELECT id FROM snapshots WHERE case_id = $1 ORDER BY recorded_at DESC, id DESC LIMIT 1`, s.caseID).Scan(&latestID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find latest snapshot: %w", err)
+	}
+
+	rows, err := s.db.Query(`SELECT id, recorded_at, data FROM snapshots WHERE case_id = $1 AND recorded_at < $2 AND id != $3 ORDER BY recorded_at, id`,
+		s.caseID, before, latestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query snapshots: %w", err)
+	}
+	defer rows.Close()
+	var records []ArchiveRecord
+	for rows.Next() {
+		var (
+			record = ArchiveRecord{Kind: ArchiveSnapshot, CaseID: s.caseID}
+			data   string
+		)
+		if err := rows.Scan(&record.id, &record.Time, &data); err != nil {
+			return nil, fmt.Errorf("failed to read snapshot: %w", err)
+		}
+		if err := json.Unmarshal([]byte(data), &record.Data); err != nil {
+			return nil, fmt.Errorf("failed to parse snapshot: %w", err)
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read snapshots: %w", err)
+	}
+
+	rows, err = s.db.Query(`SELECT id, detected_at, field, old_value, new_value FROM changes WHERE case_id = $1 AND detected_at < $2 ORDER BY detected_at, id`,
+		s.caseID, before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query changes: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			record             = ArchiveRecord{Kind: ArchiveChange, CaseID: s.caseID}
+			oldValue, newValue sql.NullString
+		)
+		if err := rows.Scan(&record.id, &record.Time, &record.Field, &oldValue, &newValue); err != nil {
+			return nil, fmt.Errorf("failed to read change: %w", err)
+		}
+		if oldValue.Valid {
+			record.OldValue = json.RawMessage(oldValue.String)
+		}
+		if newValue.Valid {
+			record.NewValue = json.RawMessage(newValue.String)
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// DeleteRecords removes archived snapshots and changes in one transaction
+func (s *PostgresStorage) DeleteRecords(records []ArchiveRecord) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, record := range records {
+		table := "snapshots"
+		if record.Kind == ArchiveChange {
+			table = "changes"
+		}
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE id = $1 AND case_id = $2`, record.id, s.caseID); err != nil {
+			return fmt.Errorf("failed to delete archived %s: %w", record.Kind, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit archive deletion: %w", err)
+	}
+	return nil
+}
+
+// RestoreRecords inserts archived snapshots and changes that aren't in the database
+func (s *PostgresStorage) RestoreRecords(records []ArchiveRecord) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The snapshots reference the case row, which may be gone if the case was deleted
+	if len(records) > 0 {
+		if _, err := tx.Exec(`INSERT INTO cases (case_id, first_seen) VALUES ($1, $2) ON CONFLICT (case_id) DO UPDATE SET first_seen = LEAST(cases.first_seen, excluded.first_seen)`,
+			s.caseID, records[0].Time); err != nil {
+			return 0, fmt.Errorf("failed to restore case: %w", err)
+		}
+	}
+
+	restored := 0
+	for _, record := range records {
+		var exists bool
+		switch record.Kind {
+		case ArchiveSnapshot:
+			err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM snapshots WHERE case_id = $1 AND recorded_at = $2)`, s.caseID, record.Time).Scan(&exists)
+		case ArchiveChange:
+			err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM changes WHERE case_id = $1 AND detected_at = $2 AND field = $3)`, s.caseID, record.Time, record.Field).Scan(&exists)
+		default:
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to check archived %s: %w", record.Kind, err)
+		}
+		if exists {
+			continue
+		}
+
+		if record.Kind == ArchiveSnapshot {
+			data, err := json.Marshal(record.Data)
+			if err != nil {
+				return 0, fmt.Errorf("failed to marshal state: %w", err)
+			}
+			_, err = tx.Exec(`INSERT INTO snapshots (case_id, recorded_at, data) VALUES ($1, $2, $3)`, s.caseID, record.Time, string(data))
+		} else {
+			_, err = tx.Exec(`INSERT INTO changes (case_id, detected_at, field, old_value, new_value) VALUES ($1, $2, $3, $4, $5)`,
+				s.caseID, record.Time, record.Field, rawOrNull(record.OldValue), rawOrNull(record.NewValue))
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to restore %s: %w", record.Kind, err)
+		}
+		restored++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit restore: %w", err)
+	}
+	return restored, nil
+}
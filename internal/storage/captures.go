@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// maxLoginCaptures is how many failed logins are kept; older captures are removed
+const maxLoginCaptures = 20
+
+// LoginCaptureStore keeps screenshots and DOM snapshots of failed browser logins in
+// STATE_FILE_DIR/diagnostics, for the file backend
+type LoginCaptureStore struct {
+	dir string
+}
+
+// NewLoginCaptureStore creates a capture store under the state directory
+func NewLoginCaptureStore(stateDir string) *LoginCaptureStore {
+	return &LoginCaptureStore{dir: filepath.Join(stateDir, "diagnostics")}
+}
+
+// SaveCapture writes a capture as login_<time>.jpg and login_<time>.html and returns the
+// files written. The pages can show the account's name, so they are only readable by the owner
+func (s *LoginCaptureStore) SaveCapture(capture *uscis.LoginCapture) ([]string, error) {
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create diagnostics directory: %w", err)
+	}
+
+	var files []string
+	for _, part := range captureParts(capture) {
+		path := filepath.Join(s.dir, part.name)
+		if err := writeFileAtomic(path, part.data); err != nil {
+			return files, err
+		}
+		files = append(files, path)
+	}
+
+	s.prune()
+	return files, nil
+}
+
+// CaptureFile is one saved file of a login capture
+type CaptureFile struct {
+	Name string
+	Data []byte
+}
+
+// CaptureReader reads back the login captures a backend saved, e.g. for support bundles
+type CaptureReader interface {
+	// RecentCaptures returns up to limit files of the most recent captures, newest first
+	RecentCaptures(limit int) ([]CaptureFile, error)
+}
+
+// RecentCaptures returns up to limit files of the most recent captures, newest first
+func (s *LoginCaptureStore) RecentCaptures(limit int) ([]CaptureFile, error) {
+	matches, err := filepath.Glob(filepath.Join(s.dir, "login_*"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list login captures: %w", err)
+	}
+	// Names start with the capture's time, which sorts chronologically
+	sort.Sort(sort.Reverse(sort.StringSlice(matches)))
+
+	var files []CaptureFile
+	for _, path := range matches {
+		if len(files) == limit {
+			break
+		}
+		if strings.HasSuffix(path, ".tmp") {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return files, fmt.Errorf("failed to read login capture: %w", err)
+		}
+		files = append(files, CaptureFile{Name: filepath.Base(path), Data: data})
+	}
+	return files, nil
+}
+
+// capturePart is one file of a login capture
+type capturePart struct {
+	name        string
+	contentType string
+	data        []byte
+}
+
+// captureParts returns the files of a capture, login_<time>.jpg and login_<time>.html,
+// as every capture store names them
+func captureParts(capture *uscis.LoginCapture) []capturePart {
+	base := "login_" + capture.Time.Format("2006-01-02T15-04-05")
+	var parts []capturePart
+	if capture.Screenshot != nil {
+		parts = append(parts, capturePart{name: base + ".jpg", contentType: "image/jpeg", data: capture.Screenshot})
+	}
+	if capture.HTML != "" {
+		// Where and why, ahead of the page itself
+		header := fmt.Sprintf("<!-- Failed login at %s\n     URL: %s\n     Error: %s -->\n",
+			capture.Time.Format("2006-01-02 15:04:05 MST"), html.EscapeString(capture.URL), html.EscapeString(capture.Error))
+		parts = append(parts, capturePart{name: base + ".html", contentType: "text/html", data: []byte(header + capture.HTML)})
+	}
+	return parts
+}
+
+// prune removes all but the most recent maxLoginCaptures captures
+func (s *LoginCaptureStore) prune() {
+	matches, err := filepath.Glob(filepath.Join(s.dir, "login_*"))
+	if err != nil {
+		return
+	}
+	// Both files of a capture share its time, which sorts chronologically
+	byCapture := make(map[string][]string)
+	for _, path := range matches {
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		byCapture[name] = append(byCapture[name], path)
+	}
+	names := make([]string, 0, len(byCapture))
+	for name := range byCapture {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for len(names) > maxLoginCaptures {
+		for _, path := range byCapture[names[0]] {
+			os.Remove(path)
+		}
+		names = names[1:]
+	}
+}
+
+// writeFileAtomic writes a file readable only by the owner via a temp file and rename
+func writeFileAtomic(path string, data []byte) error {
+	tempFile := path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	if err := os.Rename(tempFile, path); err != nil {
+		return fmt.Errorf("failed to rename %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DeadLetter is a USCIS response that could not be processed, kept with its context
+type DeadLetter struct {
+	ID        string    `json:"id"`
+	CaseID    string    `json:"case_id"`
+	Source    string    `json:"source"`
+	Reason    string    `json:"reason"`
+	Error     string    `json:"error"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// DeadLetterStore persists unprocessable responses as JSON files in a directory
+type DeadLetterStore struct {
+	dir string
+}
+
+// NewDeadLetterStore creates a dead-letter store under the state directory
+func NewDeadLetterStore(stateDir string) *DeadLetterStore {
+	return &DeadLetterStore{dir: filepath.Join(stateDir, "deadletter")}
+}
+
+// Put stores a dead letter, assigning its ID and timestamp
+func (d *DeadLetterStore) Put(letter *DeadLetter) error {
+	if err := os.MkdirAll(d.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create dead-letter directory: %w", err)
+	}
+
+	letter.CreatedAt = time.Now()
+	letter.ID = fmt.Sprintf("%s_%s", letter.CaseID, letter.CreatedAt.Format("2006-01-02T15-04-05.000"))
+
+	data, err := json.MarshalIndent(letter, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead letter: %w", err)
+	}
+
+	path := d.path(letter.ID)
+	tempFile := path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0600); err != nil {
+		return fmt.Errorf("failed to write dead letter: %w", err)
+	}
+	if err := os.Rename(tempFile, path); err != nil {
+		return fmt.Errorf("failed to rename dead letter: %w", err)
+	}
+	return nil
+}
+
+// List returns every stored dead letter, oldest first
+func (d *DeadLetterStore) List() ([]*DeadLetter, error) {
+	matches, err := filepath.Glob(filepath.Join(d.dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search dead letters: %w", err)
+	}
+
+	var letters []*DeadLetter
+	for _, path := range matches {
+		letter, err := d.Get(strings.TrimSuffix(filepath.Base(path), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		letters = append(letters, letter)
+	}
+
+	sort.Slice(letters, func(i, j int) bool {
+		return letters[i].CreatedAt.Before(letters[j].CreatedAt)
+	})
+	return letters, nil
+}
+
+// CountForCase returns how many dead letters are stored for a case
+func (d *DeadLetterStore) CountForCase(caseID string) int {
+	matches, _ := filepath.Glob(filepath.Join(d.dir, caseID+"_*.json"))
+	return len(matches)
+}
+
+// Get loads a single dead letter by ID
+func (d *DeadLetterStore) Get(id string) (*DeadLetter, error) {
+	data, err := os.ReadFile(d.path(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dead letter %s: %w", id, err)
+	}
+
+	var letter DeadLetter
+	if err := json.Unmarshal(data, &letter); err != nil {
+		return nil, fmt.Errorf("failed to parse dead letter %s: %w", id, err)
+	}
+	return &letter, nil
+}
+
+// Delete removes a dead letter once it has been handled
+func (d *DeadLetterStore) Delete(id string) error {
+	if err := os.Remove(d.path(id)); err != nil {
+		return fmt.Errorf("failed to delete dead letter %s: %w", id, err)
+	}
+	return nil
+}
+
+// path returns the file path for a dead letter ID
+func (d *DeadLetterStore) path(id string) string {
+	return filepath.Join(d.dir, filepath.Base(id)+".json")
+}
@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// heartbeatFile is rewritten after every poll cycle, whether or not anything changed
+const heartbeatFile = "heartbeat"
+
+// WriteHeartbeat records that a poll cycle completed
+func WriteHeartbeat(stateDir string, at time.Time) error {
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	data := strconv.FormatInt(at.Unix(), 10) + "\n"
+	if err := os.WriteFile(filepath.Join(stateDir, heartbeatFile), []byte(data), 0644); err != nil {
+		return fmt.Errorf("failed to write heartbeat: %w", err)
+	}
+	return nil
+}
+
+// LastHeartbeat returns when the last poll cycle completed
+func LastHeartbeat(stateDir string) (time.Time, error) {
+	data, err := os.ReadFile(filepath.Join(stateDir, heartbeatFile))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read heartbeat: %w", err)
+	}
+	seconds, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid heartbeat: %w", err)
+	}
+	return time.Unix(seconds, 0), nil
+}
@@ -0,0 +1,212 @@
+package storage
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LoadOrCreateInstanceID returns the tracker instance ID persisted in the state directory,
+// generating and saving a new random one on first use
+func LoadOrCreateInstanceID(stateDir string) (string, error) {
+	path := filepath.Join(stateDir, "instance-id")
+
+	if data, err := os.ReadFile(path); err == nil {
+		if id := strings.TrimSpace(string(data)); id != "" {
+			return id, nil
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("failed to read instance ID: %w", err)
+	}
+
+	buf := make([]byte, 4)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate instance ID: %w", err)
+	}
+	id := hex.EncodeToString(buf)
+
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(id+"\n"), 0644); err != nil {
+		return "", fmt.Errorf("failed to save instance ID: %w", err)
+	}
+	return id, nil
+}
+
+// LoadOrCreateInstallKey returns the random key of this installation, persisted in the
+// state directory and generated on first use. It keys fingerprints of credentials, so
+// what is saved can't be checked against guessed passwords without the key
+func LoadOrCreateInstallKey(stateDir string) ([]byte, error) {
+	path := filepath.Join(stateDir, "install-key")
+
+	if data, err := os.ReadFile(path); err == nil {
+		if key, err := hex.DecodeString(strings.TrimSpace(string(data))); err == nil && len(key) == 32 {
+			return key, nil
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read install key: %w", err)
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate install key: %w", err)
+	}
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(key)+"\n"), 0600); err != nil {
+		return nil, fmt.Errorf("failed to save install key: %w", err)
+	}
+	return key, nil
+}
+
+// StateMeta records which tracker instance last wrote a case's state
+type StateMeta struct {
+	InstanceID string    `json:"instance_id"`
+	Hostname   string    `json:"hostname,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// metaPath returns the metadata file for this case
+// Named {caseID}.meta.json so it never matches the {caseID}_*.json snapshot pattern
+func (f *FileStorage) metaPath() string {
+	return filepath.Join(f.stateDir, f.caseID+".meta.json")
+}
+
+// LoadMeta returns the metadata of the last write, or nil if none was recorded
+func (f *FileStorage) LoadMeta() (*StateMeta, error) {
+	data, err := os.ReadFile(f.metaPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state metadata: %w", err)
+	}
+
+	var meta StateMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("failed to parse state metadata: %w", err)
+	}
+	return &meta, nil
+}
+
+// SaveMeta records the instance that just wrote this case's state
+func (f *FileStorage) SaveMeta(meta StateMeta) error {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state metadata: %w", err)
+	}
+
+	if err := os.MkdirAll(f.stateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	tempFile := f.metaPath() + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write state metadata: %w", err)
+	}
+	return os.Rename(tempFile, f.metaPath())
+}
+
+// Delivery is one notification recorded in the delivery log
+type Delivery struct {
+	Time       time.Time `json:"time"`
+	InstanceID string    `json:"instance_id"`
+	CaseIDs    []string  `json:"case_ids,omitempty"`
+	Channel    string    `json:"channel"`
+	Recipient  string    `json:"recipient"`
+	Subject    string    `json:"subject"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// DeliveryLog appends every notification attempt to a JSON-lines file
+type DeliveryLog struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewDeliveryLog creates a delivery log in the state directory
+func NewDeliveryLog(stateDir string) *DeliveryLog {
+	return &DeliveryLog{path: filepath.Join(stateDir, "deliveries.jsonl")}
+}
+
+// Append records a delivery attempt
+func (d *DeliveryLog) Append(delivery Delivery) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	data, err := json.Marshal(delivery)
+	if err != nil {
+		return fmt.Errorf("failed to marshal delivery: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(d.path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	file, err := os.OpenFile(d.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open delivery log: %w", err)
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+// Recent returns up to limit most recent deliveries, newest first
+func (d *DeliveryLog) Recent(limit int) ([]Delivery, error) {
+	all, err := d.read()
+	var recent []Delivery
+	for i := len(all) - 1; i >= 0 && len(recent) < limit; i-- {
+		recent = append(recent, all[i])
+	}
+	return recent, err
+}
+
+// Since returns the deliveries recorded at or after t, oldest first
+func (d *DeliveryLog) Since(t time.Time) ([]Delivery, error) {
+	all, err := d.read()
+	var since []Delivery
+	for _, delivery := range all {
+		if !delivery.Time.Before(t) {
+			since = append(since, delivery)
+		}
+	}
+	return since, err
+}
+
+// read returns every recorded delivery, oldest first
+func (d *DeliveryLog) read() ([]Delivery, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	file, err := os.Open(d.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open delivery log: %w", err)
+	}
+	defer file.Close()
+
+	var all []Delivery
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var delivery Delivery
+		if err := json.Unmarshal(scanner.Bytes(), &delivery); err == nil {
+			all = append(all, delivery)
+		}
+	}
+	return all, scanner.Err()
+}
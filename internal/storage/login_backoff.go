@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LoginBackoff is the state of the retried startup login: how often it failed in a row
+// and when the next attempt may be made
+type LoginBackoff struct {
+	Failures    int       `json:"failures"`
+	LastFailure time.Time `json:"last_failure"`
+	NextAttempt time.Time `json:"next_attempt"`
+	LastError   string    `json:"last_error,omitempty"`
+	Rejected    bool      `json:"rejected,omitempty"` // USCIS rejected the credentials; they aren't tried again
+	Credentials string    `json:"credentials"`        // fingerprint of the credentials that failed
+}
+
+// LoginBackoffStore persists the startup login backoff in {stateDir}/login_backoff.json,
+// so a restarted tracker waits out the backoff instead of logging in right away
+type LoginBackoffStore struct {
+	path string
+}
+
+// NewLoginBackoffStore creates a login backoff store under the state directory
+func NewLoginBackoffStore(stateDir string) *LoginBackoffStore {
+	return &LoginBackoffStore{path: filepath.Join(stateDir, "login_backoff.json")}
+}
+
+// NewAccountLoginBackoffStore creates the login backoff store of an additional USCIS
+// account, in {stateDir}/login_backoff_{account}.json
+func NewAccountLoginBackoffStore(stateDir, account string) *LoginBackoffStore {
+	return &LoginBackoffStore{path: filepath.Join(stateDir, "login_backoff_"+account+".json")}
+}
+
+// Load returns the saved backoff, nil if there is none
+func (s *LoginBackoffStore) Load() (*LoginBackoff, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read login backoff: %w", err)
+	}
+	var backoff LoginBackoff
+	if err := json.Unmarshal(data, &backoff); err != nil {
+		return nil, fmt.Errorf("failed to parse login backoff: %w", err)
+	}
+	return &backoff, nil
+}
+
+// Save writes the backoff atomically
+func (s *LoginBackoffStore) Save(backoff *LoginBackoff) error {
+	data, err := json.MarshalIndent(backoff, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal login backoff: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	tempFile := s.path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0600); err != nil {
+		return fmt.Errorf("failed to write login backoff: %w", err)
+	}
+	if err := os.Rename(tempFile, s.path); err != nil {
+		return fmt.Errorf("failed to rename login backoff: %w", err)
+	}
+	return nil
+}
+
+// Clear removes the backoff after a successful login
+func (s *LoginBackoffStore) Clear() error {
+	if err := os.Remove(s.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove login backoff: %w", err)
+	}
+	return nil
+}
+
+// String returns the path of the backoff file
+func (s *LoginBackoffStore) String() string {
+	return s.path
+}
@@ -0,0 +1,385 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// Migration upgrades stored state from the version before it to Version
+// Apply must be safe to run again: a migration interrupted by a crash runs once more
+// on the next start, over state it partly upgraded already
+type Migration struct {
+	Version     int
+	Name        string
+	Description string
+	Apply       func(env *MigrationEnv) error
+}
+
+// MigrationEnv is the stored state a migration works on
+type MigrationEnv struct {
+	StateDir string
+	DB       *SQLiteDB // nil with the file storage backend
+	Logf     func(format string, args ...interface{})
+}
+
+// migrations are every schema change of the stored state, in order
+// New ones are appended with the next version; released ones never change
+// They cover snapshots and case metadata. The outbox, the delivery log and the other
+// records in STATE_FILE_DIR have no migration: their fields were only ever added, as
+// optional ones, so entries written by an older tracker still read. A change that
+// renames or removes one of their fields needs a migration of its own
+var migrations = []Migration{
+	{
+		Version:     1,
+		Name:        "canonical-snapshots",
+		Description: "rewrite snapshots saved before the canonical form (history oldest first, normalized numbers)",
+		Apply:       canonicalizeSnapshots,
+	},
+	{
+		Version:     2,
+		Name:        "normalized-receipt-numbers",
+		Description: "move snapshots and metadata saved under a lowercase or spaced receipt number to its normalized form",
+		Apply:       normalizeCaseIDs,
+	},
+}
+
+// CurrentStateVersion is the state version this build writes
+func CurrentStateVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// stateVersionFile records the version of the state in STATE_FILE_DIR
+const stateVersionFile = "state_version.json"
+
+// StateVersion is the content of state_version.json
+type StateVersion struct {
+	Version int                `json:"version"`
+	Applied []AppliedMigration `json:"applied,omitempty"`
+}
+
+// AppliedMigration records when a migration ran
+type AppliedMigration struct {
+	Version   int       `json:"version"`
+	Name      string    `json:"name"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+// LoadStateVersion reads the version of the state in a directory
+// State written before versioning (or no state at all) is version 0
+func LoadStateVersion(stateDir string) (*StateVersion, error) {
+	data, err := os.ReadFile(filepath.Join(stateDir, stateVersionFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return &StateVersion{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state version: %w", err)
+	}
+	var version StateVersion
+	if err := json.Unmarshal(data, &version); err != nil {
+		return nil, fmt.Errorf("failed to parse state version: %w", err)
+	}
+	return &version, nil
+}
+
+// save writes the state version atomically
+func (v *StateVersion) save(stateDir string) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state version: %w", err)
+	}
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	path := filepath.Join(stateDir, stateVersionFile)
+	tempFile := path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write state version: %w", err)
+	}
+	if err := os.Rename(tempFile, path); err != nil {
+		return fmt.Errorf("failed to rename state version: %w", err)
+	}
+	return nil
+}
+
+// PendingMigrations returns the migrations state at a version still needs, in order
+// It fails for state written by a newer tracker, which this build can't read safely
+func PendingMigrations(version int) ([]Migration, error) {
+	if version > CurrentStateVersion() {
+		return nil, fmt.Errorf("state version %d is newer than this tracker supports (%d): upgrade the tracker", version, CurrentStateVersion())
+	}
+	var pending []Migration
+	for _, m := range migrations {
+		if m.Version > version {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// Migrate brings the stored state up to the current version and returns the migrations
+// it applied. The version is recorded after each migration, so a failed one is the first
+// to run next time
+func Migrate(env *MigrationEnv) ([]Migration, error) {
+	version, err := LoadStateVersion(env.StateDir)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := PendingMigrations(version.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, m := range pending {
+		if err := m.Apply(env); err != nil {
+			return applied, fmt.Errorf("failed to apply migration %d (%s): %w", m.Version, m.Name, err)
+		}
+		version.Version = m.Version
+		version.Applied = append(version.Applied, AppliedMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()})
+		if err := version.save(env.StateDir); err != nil {
+			return applied, err
+		}
+		applied = append(applied, m)
+	}
+	return applied, nil
+}
+
+// snapshotFilePattern matches state file names: {caseID}_{timestamp}.json
+var snapshotFilePattern = regexp.MustCompile(`^[A-Za-z0-9]+_\d{4}-\d{2}-\d{2}T\d{2}-\d{2}-\d{2}\.json$`)
+
+// canonicalizeSnapshots rewrites snapshots that aren't in canonical form, so they
+// compare and hash like the ones saved since
+func canonicalizeSnapshots(env *MigrationEnv) error {
+	matches, err := filepath.Glob(filepath.Join(env.StateDir, "*_*.json"))
+	if err != nil {
+		return fmt.Errorf("failed to search for state files: %w", err)
+	}
+	// Cases of a tenant keep their snapshots in the tenant's directory
+	tenantMatches, err := filepath.Glob(filepath.Join(env.StateDir, "tenants", "*", "*_*.json"))
+	if err != nil {
+		return fmt.Errorf("failed to search for state files: %w", err)
+	}
+	matches = append(matches, tenantMatches...)
+	rewritten, total := 0, 0
+	for _, path := range matches {
+		if !snapshotFilePattern.MatchString(filepath.Base(path)) {
+			continue
+		}
+		total++
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read state file %s: %w", path, err)
+		}
+		var state map[string]interface{}
+		if err := json.Unmarshal(data, &state); err != nil {
+			// Left alone; loading the snapshot reports it
+			env.Logf("Skipping unreadable state file %s: %v", path, err)
+			continue
+		}
+		canonical, err := json.MarshalIndent(uscis.Canonicalize(state), "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal state file %s: %w", path, err)
+		}
+		if bytes.Equal(data, canonical) {
+			continue
+		}
+		tempFile := path + ".tmp"
+		if err := os.WriteFile(tempFile, canonical, 0644); err != nil {
+			return fmt.Errorf("failed to write temp state file: %w", err)
+		}
+		if err := os.Rename(tempFile, path); err != nil {
+			return fmt.Errorf("failed to rename temp state file: %w", err)
+		}
+		rewritten++
+	}
+	env.Logf("Rewrote %d of %d state files", rewritten, total)
+
+	if env.DB == nil {
+		return nil
+	}
+	updated, total, err := env.DB.canonicalizeSnapshots()
+	if err != nil {
+		return err
+	}
+	env.Logf("Rewrote %d of %d database snapshots", updated, total)
+	return nil
+}
+
+// canonicalizeSnapshots rewrites the database snapshots that aren't in canonical form
+// It returns how many were rewritten, of how many
+func (s *SQLiteDB) canonicalizeSnapshots() (int, int, error) {
+	rows, err := s.db.Query(`SELECT id, data FROM snapshots`)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to query snapshots: %w", err)
+	}
+	// Read everything first: the database has a single connection, which the query holds
+	updates := make(map[int64]string)
+	total := 0
+	for rows.Next() {
+		var (
+			id   int64
+			data string
+		)
+		if err := rows.Scan(&id, &data); err != nil {
+			rows.Close()
+			return 0, 0, fmt.Errorf("failed to read snapshot: %w", err)
+		}
+		total++
+		var state map[string]interface{}
+		if err := json.Unmarshal([]byte(data), &state); err != nil {
+			continue
+		}
+		canonical, err := json.Marshal(uscis.Canonicalize(state))
+		if err != nil {
+			rows.Close()
+			return 0, 0, fmt.Errorf("failed to marshal snapshot: %w", err)
+		}
+		if string(canonical) != data {
+			updates[id] = string(canonical)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, fmt.Errorf("failed to query snapshots: %w", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	for id, data := range updates {
+		if _, err := tx.Exec(`UPDATE snapshots SET data = ? WHERE id = ?`, data, id); err != nil {
+			return 0, 0, fmt.Errorf("failed to update snapshot: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("failed to commit snapshots: %w", err)
+	}
+	return len(updates), total, nil
+}
+
+// caseFilePattern matches the state files of a case: {caseID}_{timestamp}.json and
+// {caseID}.meta.json; the ID may hold spaces, as receipt numbers did before they
+// were normalized
+var caseFilePattern = regexp.MustCompile(`^(.+?)(_\d{4}-\d{2}-\d{2}T\d{2}-\d{2}-\d{2}\.json|\.meta\.json)$`)
+
+// normalizedCaseID returns the normalized form of a receipt number saved in another
+// spelling, or "" when id is already normalized or isn't a receipt number
+func normalizedCaseID(id string) string {
+	normalized, err := uscis.ValidateReceiptNumber(id)
+	if err != nil || normalized == id {
+		return ""
+	}
+	return normalized
+}
+
+// normalizeCaseIDs renames the state files and database rows of cases saved under a
+// receipt number in lowercase or with spaces, which the configuration now normalizes;
+// left alone, such a case would be taken for a new one
+// A file whose normalized name exists already is left in place
+func normalizeCaseIDs(env *MigrationEnv) error {
+	dirs := []string{env.StateDir}
+	tenantDirs, err := filepath.Glob(filepath.Join(env.StateDir, "tenants", "*"))
+	if err != nil {
+		return fmt.Errorf("failed to search for tenant directories: %w", err)
+	}
+	dirs = append(dirs, tenantDirs...)
+	renamed := 0
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read state directory: %w", err)
+		}
+		for _, entry := range entries {
+			m := caseFilePattern.FindStringSubmatch(entry.Name())
+			if entry.IsDir() || m == nil {
+				continue
+			}
+			normalized := normalizedCaseID(m[1])
+			if normalized == "" {
+				continue
+			}
+			path, target := filepath.Join(dir, entry.Name()), filepath.Join(dir, normalized+m[2])
+			if _, err := os.Stat(target); err == nil {
+				env.Logf("Leaving %s in place: %s exists", path, filepath.Base(target))
+				continue
+			}
+			if err := os.Rename(path, target); err != nil {
+				return fmt.Errorf("failed to rename state file: %w", err)
+			}
+			renamed++
+		}
+	}
+	env.Logf("Renamed %d state files to normalized receipt numbers", renamed)
+
+	if env.DB == nil {
+		return nil
+	}
+	updated, err := env.DB.normalizeCaseIDs()
+	if err != nil {
+		return err
+	}
+	env.Logf("Moved %d cases of the database to normalized receipt numbers", updated)
+	return nil
+}
+
+// normalizeCaseIDs moves the rows of cases saved under another spelling of their
+// receipt number to the normalized one and returns how many cases moved
+// Metadata already recorded under the normalized number is kept
+func (s *SQLiteDB) normalizeCaseIDs() (int, error) {
+	rows, err := s.db.Query(`SELECT case_id FROM snapshots UNION SELECT case_id FROM changes UNION SELECT case_id FROM case_meta`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query cases: %w", err)
+	}
+	// Read everything first: the database has a single connection, which the query holds
+	renames := make(map[string]string)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to read case: %w", err)
+		}
+		if normalized := normalizedCaseID(id); normalized != "" {
+			renames[id] = normalized
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to query cases: %w", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	for id, normalized := range renames {
+		for _, table := range []string{"snapshots", "changes"} {
+			if _, err := tx.Exec(`UPDATE `+table+` SET case_id = ? WHERE case_id = ?`, normalized, id); err != nil {
+				return 0, fmt.Errorf("failed to update %s: %w", table, err)
+			}
+		}
+		if _, err := tx.Exec(`UPDATE OR IGNORE case_meta SET case_id = ? WHERE case_id = ?`, normalized, id); err != nil {
+			return 0, fmt.Errorf("failed to update case_meta: %w", err)
+		}
+		if _, err := tx.Exec(`DELETE FROM case_meta WHERE case_id = ?`, id); err != nil {
+			return 0, fmt.Errorf("failed to update case_meta: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit cases: %w", err)
+	}
+	return len(renames), nil
+}
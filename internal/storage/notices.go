@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// NoticeStore keeps downloaded USCIS notices (PDFs) in {stateDir}/notices/{caseID}
+// Files are named by the caller and never overwritten, so a notice downloaded once
+// stays as it was
+type NoticeStore struct {
+	dir string
+}
+
+// NewNoticeStore creates a notice store under the state directory
+func NewNoticeStore(stateDir string) *NoticeStore {
+	return &NoticeStore{dir: filepath.Join(stateDir, "notices")}
+}
+
+// Save writes a notice of a case and returns its path
+// A notice already saved under the name is kept and its path returned
+func (n *NoticeStore) Save(caseID, name string, data []byte) (string, error) {
+	dir := filepath.Join(n.dir, safeFileName(caseID))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create notice directory: %w", err)
+	}
+
+	path := filepath.Join(dir, safeFileName(name))
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		return path, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to save notice: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(path)
+		return "", fmt.Errorf("failed to save notice: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("failed to save notice: %w", err)
+	}
+	return path, nil
+}
+
+// safeFileName replaces what can't appear in a portable file name
+func safeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+	name = strings.Trim(name, "._")
+	if name == "" {
+		return "_"
+	}
+	return name
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// OutboxEntry is a notification committed together with the case snapshots it reports
+// Writing the entry is the single atomic step of a poll: once it exists, the
+// snapshots are applied (idempotently) and the notification is sent until delivered
+type OutboxEntry struct {
+	ID          string                            `json:"id"`
+	Kind        string                            `json:"kind"` // "initial" or "change"
+	CaseIDs     []string                          `json:"case_ids"`
+	Recipients  []string                          `json:"recipients,omitempty"`
+	Subject     string                            `json:"subject"`
+	HTML        string                            `json:"html"`
+	Text        string                            `json:"text,omitempty"` // plain-text part of emails
+	Footer      string                            `json:"footer,omitempty"`
+	Attachments []string                          `json:"attachments,omitempty"` // local files attached by email channels
+	Channels    []string                          `json:"channels,omitempty"`    // empty: every channel
+	CopiesSent  bool                              `json:"copies_sent,omitempty"` // the CC and BCC addresses got their copy
+	Urgent      bool                              `json:"urgent,omitempty"`      // sent even during quiet hours and over the hourly limit
+	NotBefore   time.Time                         `json:"not_before,omitzero"`   // held for quiet hours or the hourly limit until then
+	Snapshots   map[string]map[string]interface{} `json:"snapshots"`             // case ID -> state to save
+	Applied     bool                              `json:"applied"`               // snapshots have been saved
+	CreatedAt   time.Time                         `json:"created_at"`
+	Attempts    int                               `json:"attempts"`
+	LastError   string                            `json:"last_error,omitempty"`
+	Followups   []*OutboxEntry                    `json:"followups,omitempty"` // committed with this entry; queued on their own by Expand
+}
+
+// OutboxStore persists pending notifications as JSON files in a directory
+type OutboxStore struct {
+	dir string
+}
+
+// NewOutboxStore creates an outbox under the state directory
+func NewOutboxStore(stateDir string) *OutboxStore {
+	return &OutboxStore{dir: filepath.Join(stateDir, "outbox")}
+}
+
+// Put commits a new entry, assigning its ID and timestamp
+func (o *OutboxStore) Put(entry *OutboxEntry) error {
+	entry.CreatedAt = time.Now()
+	entry.ID = fmt.Sprintf("%s_%s", entry.CreatedAt.Format("2006-01-02T15-04-05.000000"), strings.Join(entry.CaseIDs, "-"))
+	if len(entry.ID) > 120 {
+		entry.ID = entry.ID[:120]
+	}
+	return o.write(entry)
+}
+
+// Update rewrites an entry, e.g. after a failed delivery attempt
+func (o *OutboxStore) Update(entry *OutboxEntry) error {
+	return o.write(entry)
+}
+
+// Expand queues the follow-ups of an entry as entries of their own and clears them from it
+// Follow-up IDs derive from the entry's, so expanding again after a crash rewrites the
+// same files instead of queuing duplicates; nothing may be sent before this returns
+func (o *OutboxStore) Expand(entry *OutboxEntry) ([]*OutboxEntry, error) {
+	followups := entry.Followups
+	for i, followup := range followups {
+		followup.ID = fmt.Sprintf("%s_%d", entry.ID, i+1)
+		followup.CreatedAt = entry.CreatedAt
+		followup.Applied = true // the entry carries the snapshots
+		if err := o.write(followup); err != nil {
+			return nil, err
+		}
+	}
+	entry.Followups = nil
+	if err := o.write(entry); err != nil {
+		return nil, err
+	}
+	return followups, nil
+}
+
+// write stores an entry atomically
+func (o *OutboxStore) write(entry *OutboxEntry) error {
+	if err := os.MkdirAll(o.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create outbox directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal outbox entry: %w", err)
+	}
+
+	path := o.path(entry.ID)
+	tempFile := path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0600); err != nil {
+		return fmt.Errorf("failed to write outbox entry: %w", err)
+	}
+	if err := os.Rename(tempFile, path); err != nil {
+		return fmt.Errorf("failed to rename outbox entry: %w", err)
+	}
+	return nil
+}
+
+// Pending returns every undelivered entry, oldest first
+func (o *OutboxStore) Pending() ([]*OutboxEntry, error) {
+	matches, err := filepath.Glob(filepath.Join(o.dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search outbox: %w", err)
+	}
+
+	var entries []*OutboxEntry
+	for _, path := range matches {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read outbox entry %s: %w", path, err)
+		}
+		var entry OutboxEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return nil, fmt.Errorf("failed to parse outbox entry %s: %w", path, err)
+		}
+		entries = append(entries, &entry)
+	}
+
+	// Follow-ups share their entry's timestamp and sort after it by name
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].CreatedAt.Before(entries[j].CreatedAt)
+	})
+	return entries, nil
+}
+
+// MarkDelivered removes a delivered entry
+func (o *OutboxStore) MarkDelivered(id string) error {
+	if err := os.Remove(o.path(id)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove delivered outbox entry %s: %w", id, err)
+	}
+	return nil
+}
+
+// path returns the file path for an entry ID
+func (o *OutboxStore) path(id string) string {
+	return filepath.Join(o.dir, filepath.Base(id)+".json")
+}
@@ -0,0 +1,449 @@
+package storage
+
+import (
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq" // Pure Go driver, registered as "postgres"
+
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// postgresMigrations are the schema changes, applied in file name order: NNNN_name.sql
+//
+//go:embed postgres/*.sql
+var postgresMigrations embed.FS
+
+// postgresMigrationLock is the advisory lock key held while migrating, so instances
+// starting together don't apply the same migration twice
+const postgresMigrationLock = 7251937
+
+// PostgresMigration is one embedded schema change
+type PostgresMigration struct {
+	Version int
+	Name    string
+	sql     string
+}
+
+// PostgresMigrations lists the embedded schema changes in order
+func PostgresMigrations() ([]PostgresMigration, error) {
+	files, err := postgresMigrations.ReadDir("postgres")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedded migrations: %w", err)
+	}
+	var migrations []PostgresMigration
+	for _, file := range files {
+		name := strings.TrimSuffix(file.Name(), ".sql")
+		number, label, ok := strings.Cut(name, "_")
+		version, err := strconv.Atoi(number)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("invalid migration file name %s: expected NNNN_name.sql", file.Name())
+		}
+		content, err := postgresMigrations.ReadFile(path.Join("postgres", file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", file.Name(), err)
+		}
+		migrations = append(migrations, PostgresMigration{Version: version, Name: label, sql: string(content)})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// PostgresDB is a PostgreSQL database holding the state history of every case
+// Several tracker instances can share it, each polling its own cases
+type PostgresDB struct {
+	db *sql.DB
+}
+
+// OpenPostgres connects to the database at a postgres:// URL
+// Migrate brings its schema up to date before the tracker uses it
+func OpenPostgres(url string) (*PostgresDB, error) {
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	db.SetMaxOpenConns(4)
+	db.SetConnMaxIdleTime(5 * time.Minute)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	return &PostgresDB{db: db}, nil
+}
+
+// Migrate applies the embedded migrations the database doesn't have yet, each in
+// its own transaction, and returns them
+// A database migrated by a newer tracker is refused rather than misread
+func (p *PostgresDB) Migrate() ([]PostgresMigration, error) {
+	pending, err := p.PendingMigrations()
+	if err != nil {
+		return nil, err
+	}
+	var applied []PostgresMigration
+	for _, m := range pending {
+		done, err := p.applyMigration(m)
+		if err != nil {
+			return applied, err
+		}
+		if done {
+			applied = append(applied, m)
+		}
+	}
+	return applied, nil
+}
+
+// PendingMigrations returns the embedded migrations the database doesn't have yet
+func (p *PostgresDB) PendingMigrations() ([]PostgresMigration, error) {
+	migrations, err := PostgresMigrations()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.createMigrationTable(); err != nil {
+		return nil, err
+	}
+	version, err := p.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	if latest := migrations[len(migrations)-1].Version; version > latest {
+		return nil, fmt.Errorf("database schema version %d is newer than this tracker supports (%d); upgrade the tracker", version, latest)
+	}
+
+	var pending []PostgresMigration
+	for _, m := range migrations {
+		if m.Version > version {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// createMigrationTable creates the table recording applied migrations on first use
+func (p *PostgresDB) createMigrationTable() error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, postgresMigrationLock); err != nil {
+		return fmt.Errorf("failed to lock schema for migration: %w", err)
+	}
+	if _, err := tx.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER     PRIMARY KEY,
+		name       TEXT        NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	return nil
+}
+
+// applyMigration applies one migration unless it already was, under the migration lock
+func (p *PostgresDB) applyMigration(m PostgresMigration) (bool, error) {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, postgresMigrationLock); err != nil {
+		return false, fmt.Errorf("failed to lock schema for migration: %w", err)
+	}
+	var exists bool
+	if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, m.Version).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check migration %d: %w", m.Version, err)
+	}
+	if exists {
+		return false, nil
+	}
+	if _, err := tx.Exec(m.sql); err != nil {
+		return false, fmt.Errorf("failed to apply migration %d (%s): %w", m.Version, m.Name, err)
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.Version, m.Name); err != nil {
+		return false, fmt.Errorf("failed to record migration %d: %w", m.Version, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit migration %d: %w", m.Version, err)
+	}
+	return true, nil
+}
+
+// SchemaVersion returns the version of the latest applied migration, 0 for none
+func (p *PostgresDB) SchemaVersion() (int, error) {
+	var version int
+	if err := p.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// Close closes the connections
+func (p *PostgresDB) Close() error {
+	return p.db.Close()
+}
+
+// ForCase returns the storage for one case
+func (p *PostgresDB) ForCase(caseID string) *PostgresStorage {
+	return &PostgresStorage{db: p.db, caseID: caseID}
+}
+
+// ListChanges returns the change log of every case since the given time, oldest first
+func (p *PostgresDB) ListChanges(since time.Time) ([]ChangeRecord, error) {
+	rows, err := p.db.Query(`SELECT case_id, detected_at, field, old_value, new_value FROM changes WHERE detected_at >= $1 ORDER BY detected_at, id`, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query changes: %w", err)
+	}
+	defer rows.Close()
+
+	var records []ChangeRecord
+	for rows.Next() {
+		var (
+			record             ChangeRecord
+			oldValue, newValue sql.NullString
+		)
+		if err := rows.Scan(&record.CaseID, &record.DetectedAt, &record.Field, &oldValue, &newValue); err != nil {
+			return nil, fmt.Errorf("failed to read change: %w", err)
+		}
+		if oldValue.Valid {
+			record.OldValue = json.RawMessage(oldValue.String)
+		}
+		if newValue.Valid {
+			record.NewValue = json.RawMessage(newValue.String)
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// LoadSession returns the saved browser session of an account, or nil if there is none
+func (p *PostgresDB) LoadSession(username string) ([]byte, error) {
+	var data []byte
+	err := p.db.QueryRow(`SELECT data FROM sessions WHERE username = $1`, username).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session: %w", err)
+	}
+	return data, nil
+}
+
+// SaveSession replaces the saved browser session of an account
+func (p *PostgresDB) SaveSession(username string, data []byte) error {
+	_, err := p.db.Exec(`INSERT INTO sessions (username, saved_at, data) VALUES ($1, now(), $2)
+		ON CONFLICT (username) DO UPDATE SET saved_at = excluded.saved_at, data = excluded.data`,
+		username, data)
+	if err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	return nil
+}
+
+// ClearSession forgets the saved browser session of an account
+func (p *PostgresDB) ClearSession(username string) error {
+	if _, err := p.db.Exec(`DELETE FROM sessions WHERE username = $1`, username); err != nil {
+		return fmt.Errorf("failed to clear session: %w", err)
+	}
+	return nil
+}
+
+// SaveCapture stores the files of a failed login's capture and returns their names
+// Only the most recent maxLoginCaptures captures are kept
+func (p *PostgresDB) SaveCapture(capture *uscis.LoginCapture) ([]string, error) {
+	var names []string
+	for _, part := range captureParts(capture) {
+		_, err := p.db.Exec(`INSERT INTO login_captures (name, captured_at, data) VALUES ($1, $2, $3)
+			ON CONFLICT (name) DO UPDATE SET captured_at = excluded.captured_at, data = excluded.data`,
+			part.name, capture.Time, part.data)
+		if err != nil {
+			return names, fmt.Errorf("failed to save login capture: %w", err)
+		}
+		names = append(names, part.name)
+	}
+	_, err := p.db.Exec(`DELETE FROM login_captures WHERE captured_at NOT IN
+		(SELECT DISTINCT captured_at FROM login_captures ORDER BY captured_at DESC LIMIT $1)`, maxLoginCaptures)
+	if err != nil {
+		return names, fmt.Errorf("failed to prune login captures: %w", err)
+	}
+	return names, nil
+}
+
+// RecentCaptures returns up to limit files of the most recent login captures, newest first
+func (p *PostgresDB) RecentCaptures(limit int) ([]CaptureFile, error) {
+	rows, err := p.db.Query(`SELECT name, data FROM login_captures ORDER BY captured_at DESC, name DESC LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list login captures: %w", err)
+	}
+	defer rows.Close()
+
+	var files []CaptureFile
+	for rows.Next() {
+		var file CaptureFile
+		if err := rows.Scan(&file.Name, &file.Data); err != nil {
+			return nil, fmt.Errorf("failed to read login capture: %w", err)
+		}
+		files = append(files, file)
+	}
+	return files, rows.Err()
+}
+
+// PostgresStorage implements Storage for one case in a shared PostgreSQL database
+type PostgresStorage struct {
+	db     *sql.DB
+	caseID string
+}
+
+// Load loads the most recent snapshot for this case, or nil on first run
+func (s *PostgresStorage) Load() (map[string]interface{}, error) {
+	return s.loadBefore(s.db, time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC))
+}
+
+// queryRower is a database or a transaction
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// loadBefore loads the most recent snapshot recorded at or before the given time
+func (s *PostgresStorage) loadBefore(q queryRower, at time.Time) (map[string]interface{}, error) {
+	var data string
+	err := q.QueryRow(`SELECT data FROM snapshots WHERE case_id = $1 AND recorded_at <= $2 ORDER BY recorded_at DESC, id DESC LIMIT 1`, s.caseID, at).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+
+	var state map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &state); err != nil {
+		return nil, fmt.Errorf("failed to parse state: %w", err)
+	}
+	return state, nil
+}
+
+// Save stores a new snapshot and logs the fields that changed since the previous one
+func (s *PostgresStorage) Save(data map[string]interface{}) error {
+	return s.SaveAt(time.Now(), data)
+}
+
+// SaveAt stores a snapshot recorded at the given time, e.g. when importing history
+// Changes are logged against the snapshot immediately before it, and the case's
+// status is updated unless a later snapshot exists
+func (s *PostgresStorage) SaveAt(at time.Time, data map[string]interface{}) error {
+	data = uscis.Canonicalize(data)
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Locks the case row, so two writers of the same case log changes in turn
+	if _, err := tx.Exec(`INSERT INTO cases (case_id, first_seen, last_saved, status, form_type) VALUES ($1, $2, $2, $3, $4)
+		ON CONFLICT (case_id) DO UPDATE SET
+			first_seen = LEAST(cases.first_seen, excluded.first_seen),
+			last_saved = GREATEST(cases.last_saved, excluded.last_saved),
+			status     = CASE WHEN cases.last_saved IS NULL OR excluded.last_saved >= cases.last_saved THEN excluded.status ELSE cases.status END,
+			form_type  = CASE WHEN cases.last_saved IS NULL OR excluded.last_saved >= cases.last_saved THEN excluded.form_type ELSE cases.form_type END`,
+		s.caseID, at, uscis.StatusSummary(data), uscis.FormType(data)); err != nil {
+		return fmt.Errorf("failed to save case: %w", err)
+	}
+
+	previous, err := s.loadBefore(tx, at)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO snapshots (case_id, recorded_at, data) VALUES ($1, $2, $3)`, s.caseID, at, string(jsonData)); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+	if previous != nil {
+		for _, c := range diffTopLevel(previous, data) {
+			if _, err := tx.Exec(`INSERT INTO changes (case_id, detected_at, field, old_value, new_value) VALUES ($1, $2, $3, $4, $5)`,
+				s.caseID, at, c.field, c.oldValue, c.newValue); err != nil {
+				return fmt.Errorf("failed to log change: %w", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit state: %w", err)
+	}
+	return nil
+}
+
+// ListSnapshots loads every saved state for this case, oldest first
+func (s *PostgresStorage) ListSnapshots() ([]Snapshot, error) {
+	rows, err := s.db.Query(`SELECT recorded_at, data FROM snapshots WHERE case_id = $1 ORDER BY recorded_at, id`, s.caseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	var snapshots []Snapshot
+	for rows.Next() {
+		var (
+			recordedAt time.Time
+			data       string
+		)
+		if err := rows.Scan(&recordedAt, &data); err != nil {
+			return nil, fmt.Errorf("failed to read snapshot: %w", err)
+		}
+		var state map[string]interface{}
+		if err := json.Unmarshal([]byte(data), &state); err != nil {
+			return nil, fmt.Errorf("failed to parse snapshot: %w", err)
+		}
+		snapshots = append(snapshots, Snapshot{Timestamp: recordedAt.Local(), Data: state})
+	}
+	return snapshots, rows.Err()
+}
+
+// LoadMeta returns the metadata of the last write, or nil if none was recorded
+func (s *PostgresStorage) LoadMeta() (*StateMeta, error) {
+	var (
+		instanceID, hostname sql.NullString
+		updatedAt            sql.NullTime
+	)
+	err := s.db.QueryRow(`SELECT instance_id, hostname, meta_updated_at FROM cases WHERE case_id = $1`, s.caseID).Scan(&instanceID, &hostname, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !instanceID.Valid) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state metadata: %w", err)
+	}
+	return &StateMeta{InstanceID: instanceID.String, Hostname: hostname.String, UpdatedAt: updatedAt.Time}, nil
+}
+
+// SaveMeta records which instance wrote the state
+func (s *PostgresStorage) SaveMeta(meta StateMeta) error {
+	_, err := s.db.Exec(`INSERT INTO cases (case_id, instance_id, hostname, meta_updated_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (case_id) DO UPDATE SET instance_id = excluded.instance_id, hostname = excluded.hostname, meta_updated_at = excluded.meta_updated_at`,
+		s.caseID, meta.InstanceID, meta.Hostname, meta.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save state metadata: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the case with its snapshots and logged changes
+func (s *PostgresStorage) Delete() error {
+	if _, err := s.db.Exec(`DELETE FROM cases WHERE case_id = $1`, s.caseID); err != nil {
+		return fmt.Errorf("failed to delete case: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+-- Cases, their snapshots and the change log
+-- cases holds the latest status of every case, so questions across cases don't have
+-- to open the snapshots
+
+CREATE TABLE cases (
+	case_id         TEXT PRIMARY KEY,
+	first_seen      TIMESTAMPTZ NOT NULL DEFAULT now(),
+	last_saved      TIMESTAMPTZ,                     -- NULL until the first snapshot
+	status          TEXT        NOT NULL DEFAULT '', -- headline status of the latest snapshot
+	form_type       TEXT        NOT NULL DEFAULT '', -- e.g. I-485
+	instance_id     TEXT,                            -- tracker instance that last wrote the case
+	hostname        TEXT,
+	meta_updated_at TIMESTAMPTZ
+);
+
+CREATE TABLE snapshots (
+	id          BIGSERIAL   PRIMARY KEY,
+	case_id     TEXT        NOT NULL REFERENCES cases ON DELETE CASCADE,
+	recorded_at TIMESTAMPTZ NOT NULL,
+	data        JSONB       NOT NULL
+);
+CREATE INDEX snapshots_case_time ON snapshots (case_id, recorded_at);
+
+CREATE TABLE changes (
+	id          BIGSERIAL   PRIMARY KEY,
+	case_id     TEXT        NOT NULL REFERENCES cases ON DELETE CASCADE,
+	detected_at TIMESTAMPTZ NOT NULL,
+	field       TEXT        NOT NULL,
+	old_value   JSONB, -- NULL when the field is new
+	new_value   JSONB  -- NULL when the field was removed
+);
+CREATE INDEX changes_case_time ON changes (case_id, detected_at);
+CREATE INDEX changes_time ON changes (detected_at);
@@ -0,0 +1,8 @@
+-- Leases elect the one instance that polls when several share the database
+
+CREATE TABLE leases (
+	name       TEXT        PRIMARY KEY, -- e.g. poll
+	holder     TEXT        NOT NULL,    -- instance ID
+	hostname   TEXT        NOT NULL DEFAULT '',
+	expires_at TIMESTAMPTZ NOT NULL
+);
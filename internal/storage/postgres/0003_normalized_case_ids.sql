@@ -0,0 +1,19 @@
+-- Receipt numbers are normalized (no whitespace, upper case) by the configuration
+-- Cases saved under another spelling move to the normalized one; when both exist, the
+-- normalized row is kept and takes over the snapshots and changes of the other
+
+CREATE TEMPORARY TABLE case_renames ON COMMIT DROP AS
+SELECT case_id AS old_id, upper(regexp_replace(case_id, '\s', '', 'g')) AS new_id
+FROM cases
+WHERE case_id <> upper(regexp_replace(case_id, '\s', '', 'g'))
+	AND upper(regexp_replace(case_id, '\s', '', 'g')) ~ '^[A-Z]{3}[0-9]{10}$';
+
+INSERT INTO cases (case_id, first_seen, last_saved, status, form_type, instance_id, hostname, meta_updated_at)
+SELECT DISTINCT ON (r.new_id) r.new_id, c.first_seen, c.last_saved, c.status, c.form_type, c.instance_id, c.hostname, c.meta_updated_at
+FROM case_renames r JOIN cases c ON c.case_id = r.old_id
+ORDER BY r.new_id, c.last_saved DESC NULLS LAST
+ON CONFLICT (case_id) DO NOTHING;
+
+UPDATE snapshots s SET case_id = r.new_id FROM case_renames r WHERE s.case_id = r.old_id;
+UPDATE changes c SET case_id = r.new_id FROM case_renames r WHERE c.case_id = r.old_id;
+DELETE FROM cases WHERE case_id IN (SELECT old_id FROM case_renames);
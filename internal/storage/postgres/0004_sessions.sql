@@ -0,0 +1,7 @@
+-- Saved browser sessions, keyed by USCIS username, so restarts skip a full login and 2FA
+
+CREATE TABLE sessions (
+	username TEXT        PRIMARY KEY,
+	saved_at TIMESTAMPTZ NOT NULL,
+	data     BYTEA       NOT NULL -- opaque to storage (the browser's cookies)
+);
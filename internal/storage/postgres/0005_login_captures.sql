@@ -0,0 +1,7 @@
+-- Screenshots and pages of failed browser logins (LOGIN_CAPTURES), the most recent 20 kept
+
+CREATE TABLE login_captures (
+	name        TEXT        PRIMARY KEY, -- login_<time>.jpg or login_<time>.html
+	captured_at TIMESTAMPTZ NOT NULL,
+	data        BYTEA       NOT NULL
+);
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/lease"
+)
+
+// PostgresLease is a named lease in the leases table, implementing lease.Locker
+// Expiry is judged by the database clock, so the instances' clocks don't matter
+type PostgresLease struct {
+	db   *sql.DB
+	name string
+}
+
+// Lease returns the named lease
+func (p *PostgresDB) Lease(name string) *PostgresLease {
+	return &PostgresLease{db: p.db, name: name}
+}
+
+// Acquire takes or extends the lease in a single statement
+func (l *PostgresLease) Acquire(holder, hostname string, ttl time.Duration) (lease.Lease, bool, error) {
+	var current lease.Lease
+	err := l.db.QueryRow(`INSERT INTO leases (name, holder, hostname, expires_at)
+		VALUES ($1, $2, $3, now() + $4 * interval '1 millisecond')
+		ON CONFLICT (name) DO UPDATE SET holder = EXCLUDED.holder, hostname = EXCLUDED.hostname, expires_at = EXCLUDED.expires_at
+		WHERE leases.holder = EXCLUDED.holder OR leases.expires_at <= now()
+		RETURNING holder, hostname, expires_at`,
+		l.name, holder, hostname, ttl.Milliseconds()).Scan(&current.Holder, &current.Hostname, &current.Expires)
+	if err == nil {
+		return current, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return current, false, fmt.Errorf("failed to acquire lease %s: %w", l.name, err)
+	}
+
+	// Another instance holds it
+	if err := l.db.QueryRow(`SELECT holder, hostname, expires_at FROM leases WHERE name = $1`, l.name).
+		Scan(&current.Holder, &current.Hostname, &current.Expires); err != nil {
+		return current, false, fmt.Errorf("failed to read lease %s: %w", l.name, err)
+	}
+	return current, false, nil
+}
+
+// Release deletes the lease if holder has it
+func (l *PostgresLease) Release(holder string) error {
+	if _, err := l.db.Exec(`DELETE FROM leases WHERE name = $1 AND holder = $2`, l.name, holder); err != nil {
+		return fmt.Errorf("failed to release lease %s: %w", l.name, err)
+	}
+	return nil
+}
+
+// String names the lease, for logs
+func (l *PostgresLease) String() string {
+	return "postgres leases/" + l.name
+}
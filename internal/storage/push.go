@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// PushSubscription is a browser's web push subscription, as the Push API reports it
+type PushSubscription struct {
+	Endpoint  string    `json:"endpoint"`
+	P256dh    string    `json:"p256dh"` // Browser's public key, base64url
+	Auth      string    `json:"auth"`   // Authentication secret, base64url
+	UserAgent string    `json:"user_agent,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// pushState is the content of the push file
+type pushState struct {
+	VAPIDKey      string             `json:"vapid_key,omitempty"` // PEM-encoded application server key
+	Subscriptions []PushSubscription `json:"subscriptions"`
+}
+
+// PushStore persists web push subscriptions and the key signing push requests in
+// {stateDir}/push.json
+// Subscriptions are bound to the key, so losing the file means subscribing again
+// It is safe for concurrent use
+type PushStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewPushStore creates a push store under the state directory
+func NewPushStore(stateDir string) *PushStore {
+	return &PushStore{path: filepath.Join(stateDir, "push.json")}
+}
+
+// Key returns the stored application server key, creating and saving one with
+// generate the first time
+func (s *PushStore) Key(generate func() (string, error)) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, err := s.load()
+	if err != nil {
+		return "", err
+	}
+	if state.VAPIDKey != "" {
+		return state.VAPIDKey, nil
+	}
+	if state.VAPIDKey, err = generate(); err != nil {
+		return "", err
+	}
+	return state.VAPIDKey, s.save(state)
+}
+
+// Add saves a subscription, replacing any with the same endpoint
+func (s *PushStore) Add(sub PushSubscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, err := s.load()
+	if err != nil {
+		return err
+	}
+	state.Subscriptions = removeSubscription(state.Subscriptions, sub.Endpoint)
+	state.Subscriptions = append(state.Subscriptions, sub)
+	return s.save(state)
+}
+
+// Remove deletes the subscription with the given endpoint, if any
+func (s *PushStore) Remove(endpoint string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, err := s.load()
+	if err != nil {
+		return err
+	}
+	kept := removeSubscription(state.Subscriptions, endpoint)
+	if len(kept) == len(state.Subscriptions) {
+		return nil
+	}
+	state.Subscriptions = kept
+	return s.save(state)
+}
+
+// List returns every subscription, oldest first
+func (s *PushStore) List() ([]PushSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	return state.Subscriptions, nil
+}
+
+// removeSubscription returns the subscriptions without the one with the given endpoint
+func removeSubscription(subs []PushSubscription, endpoint string) []PushSubscription {
+	kept := make([]PushSubscription, 0, len(subs))
+	for _, sub := range subs {
+		if sub.Endpoint != endpoint {
+			kept = append(kept, sub)
+		}
+	}
+	return kept
+}
+
+// load reads the push file; a missing file means no key and no subscriptions yet
+// Caller must hold the lock
+func (s *PushStore) load() (*pushState, error) {
+	state := &pushState{}
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read push subscriptions: %w", err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse push subscriptions: %w", err)
+	}
+	return state, nil
+}
+
+// save writes the push file atomically
+// It holds a private key, so only the owner can read it
+// Caller must hold the lock
+func (s *PushStore) save(state *pushState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal push subscriptions: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	tempFile := s.path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0600); err != nil {
+		return fmt.Errorf("failed to write push subscriptions: %w", err)
+	}
+	if err := os.Rename(tempFile, s.path); err != nil {
+		return fmt.Errorf("failed to rename push subscriptions: %w", err)
+	}
+	return nil
+}
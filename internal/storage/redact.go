@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Redactor strips or hashes sensitive fields from a snapshot before it is stored
+// Fields are matched by name (case-insensitive) at any depth of the payload
+type Redactor struct {
+	fields  map[string]string // lower-cased field name -> "strip" or "hash"
+	hashKey []byte
+	text    map[string]*regexp.Regexp // field name -> pattern of the field's value in free text
+}
+
+// NewRedactor creates a redactor for the given field rules ("strip" or "hash")
+// Hashed values are HMAC-SHA256 with hashKey when set, plain SHA-256 otherwise;
+// a key keeps short values such as A-numbers from being recovered by brute force
+func NewRedactor(fields map[string]string, hashKey string) *Redactor {
+	lowered := make(map[string]string, len(fields))
+	text := make(map[string]*regexp.Regexp, len(fields))
+	for name, mode := range fields {
+		name = strings.ToLower(name)
+		lowered[name] = mode
+		text[name] = regexp.MustCompile(`(?i)("?` + regexp.QuoteMeta(name) + `"?\s*[:=]\s*)("[^"]*"|[^\s,;}\]]+)`)
+	}
+	return &Redactor{fields: lowered, hashKey: []byte(hashKey), text: text}
+}
+
+// Apply returns a redacted copy of a snapshot, leaving the original untouched
+// A nil or empty redactor returns the snapshot as is
+func (r *Redactor) Apply(data map[string]interface{}) map[string]interface{} {
+	if r == nil || len(r.fields) == 0 || data == nil {
+		return data
+	}
+	return r.redactMap(data)
+}
+
+// redactMap copies one object level, redacting matching keys
+func (r *Redactor) redactMap(m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		switch r.fields[strings.ToLower(key)] {
+		case "strip":
+			continue
+		case "hash":
+			out[key] = r.hash(value)
+		default:
+			out[key] = r.redactValue(value)
+		}
+	}
+	return out
+}
+
+// redactValue descends into nested objects and arrays
+func (r *Redactor) redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return r.redactMap(v)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = r.redactValue(item)
+		}
+		return out
+	default:
+		return value
+	}
+}
+
+// hash replaces a value with a stable digest so changes are still detected
+func (r *Redactor) hash(value interface{}) string {
+	text := fmt.Sprint(value)
+	if s, ok := value.(string); ok {
+		text = s
+	}
+
+	var sum []byte
+	if len(r.hashKey) > 0 {
+		mac := hmac.New(sha256.New, r.hashKey)
+		mac.Write([]byte(text))
+		sum = mac.Sum(nil)
+	} else {
+		digest := sha256.Sum256([]byte(text))
+		sum = digest[:]
+	}
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// RedactText applies the field rules to free text such as log lines
+// Values following a redacted field name ("name": "value", name=value) are
+// removed or hashed the same way they are in snapshots
+func (r *Redactor) RedactText(text string) string {
+	if r == nil || len(r.fields) == 0 {
+		return text
+	}
+	for name, mode := range r.fields {
+		pattern := r.text[name]
+		text = pattern.ReplaceAllStringFunc(text, func(match string) string {
+			parts := pattern.FindStringSubmatch(match)
+			if mode == "hash" {
+				return parts[1] + `"` + r.hash(strings.Trim(parts[2], `"`)) + `"`
+			}
+			return parts[1] + `"[redacted]"`
+		})
+	}
+	return text
+}
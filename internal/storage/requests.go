@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// requestDayFormat keys the request count by calendar day, in the timezone of the
+// times given
+const requestDayFormat = "2006-01-02"
+
+// requestCount is the number of USCIS requests made on one day
+type requestCount struct {
+	Day     string `json:"day"`
+	Count   int    `json:"count"`
+	Alerted bool   `json:"alerted,omitempty"` // the budget exhaustion alert was sent
+}
+
+// RequestCounter persists the day's USCIS request count in {stateDir}/requests.json,
+// so restarts and run-once invocations share one daily budget
+// The count is also kept in memory, so the cap holds even if the file can't be written
+// It is safe for concurrent use
+type RequestCounter struct {
+	mu      sync.Mutex
+	path    string
+	current *requestCount
+}
+
+// NewRequestCounter creates a request counter under the state directory
+func NewRequestCounter(stateDir string) *RequestCounter {
+	return &RequestCounter{path: filepath.Join(stateDir, "requests.json")}
+}
+
+// Add counts one request made at the given time and returns the day's total
+func (c *RequestCounter) Add(now time.Time) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	count, err := c.load(now)
+	if err != nil {
+		return 0, err
+	}
+	count.Count++
+	return count.Count, c.save()
+}
+
+// Used returns the number of requests made on the day of the given time
+func (c *RequestCounter) Used(now time.Time) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	count, err := c.load(now)
+	if err != nil {
+		return 0, err
+	}
+	return count.Count, nil
+}
+
+// MarkAlerted records that the day's exhaustion alert is being sent
+// It returns false if it already was, so each day alerts at most once
+func (c *RequestCounter) MarkAlerted(now time.Time) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	count, err := c.load(now)
+	if err != nil {
+		return false, err
+	}
+	if count.Alerted {
+		return false, nil
+	}
+	count.Alerted = true
+	return true, c.save()
+}
+
+// load returns the count of the day of now, reading the file the first time
+// A missing file or an earlier day means no requests yet
+// Caller must hold the lock
+func (c *RequestCounter) load(now time.Time) (*requestCount, error) {
+	day := now.Format(requestDayFormat)
+	if c.current == nil {
+		data, err := os.ReadFile(c.path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			c.current = &requestCount{Day: day}
+		case err != nil:
+			return nil, fmt.Errorf("failed to read request count: %w", err)
+		default:
+			var count requestCount
+			if err := json.Unmarshal(data, &count); err != nil {
+				return nil, fmt.Errorf("failed to parse request count: %w", err)
+			}
+			c.current = &count
+		}
+	}
+	if c.current.Day != day {
+		c.current = &requestCount{Day: day}
+	}
+	return c.current, nil
+}
+
+// save writes the current count atomically
+// Caller must hold the lock
+func (c *RequestCounter) save() error {
+	data, err := json.Marshal(c.current)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request count: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	tempFile := c.path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write request count: %w", err)
+	}
+	if err := os.Rename(tempFile, c.path); err != nil {
+		return fmt.Errorf("failed to rename request count: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,355 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/s3"
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// s3TimestampFormat is the timestamp suffix of snapshot keys, in UTC so keys sort
+// chronologically wherever the trackers writing them run
+const s3TimestampFormat = "2006-01-02T15-04-05Z"
+
+// S3Bucket keeps the state history of every case in an S3 bucket, with the layout
+// of the file backend: {prefix}/{caseID}_{timestamp}.json and {prefix}/{caseID}.meta.json,
+// the saved browser sessions in {prefix}/sessions/ and login captures in {prefix}/diagnostics/
+type S3Bucket struct {
+	client *s3.Client
+	prefix string // key prefix without trailing slash, may be empty
+}
+
+// OpenS3 returns the state bucket below prefix
+func OpenS3(client *s3.Client, prefix string) *S3Bucket {
+	return &S3Bucket{client: client, prefix: strings.Trim(prefix, "/")}
+}
+
+// String returns the bucket and prefix as an s3:// URL, for logs
+func (b *S3Bucket) String() string {
+	if b.prefix == "" {
+		return b.client.String()
+	}
+	return b.client.String() + "/" + b.prefix
+}
+
+// CredentialSource names where the bucket's credentials come from
+func (b *S3Bucket) CredentialSource() string {
+	return b.client.CredentialSource()
+}
+
+// ForCase returns the storage for one case
+func (b *S3Bucket) ForCase(caseID string) *S3Storage {
+	return &S3Storage{bucket: b, caseID: caseID}
+}
+
+// key returns the object key of a name below the prefix
+func (b *S3Bucket) key(name string) string {
+	if b.prefix == "" {
+		return name
+	}
+	return b.prefix + "/" + name
+}
+
+// sessionKey returns the key of an account's saved browser session; the username is
+// hashed so it doesn't show in the bucket's listings
+func (b *S3Bucket) sessionKey(username string) string {
+	sum := sha256.Sum256([]byte(username))
+	return b.key("sessions/" + hex.EncodeToString(sum[:]) + ".json")
+}
+
+// LoadSession returns the saved browser session of an account, or nil if there is none
+func (b *S3Bucket) LoadSession(username string) ([]byte, error) {
+	data, err := b.client.Get(b.sessionKey(username))
+	if errors.Is(err, s3.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session: %w", err)
+	}
+	var session savedSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to parse session: %w", err)
+	}
+	return session.Data, nil
+}
+
+// SaveSession replaces the saved browser session of an account
+func (b *S3Bucket) SaveSession(username string, data []byte) error {
+	encoded, err := json.Marshal(savedSession{SavedAt: time.Now(), Data: data})
+	if err != nil {
+		return fmt.Errorf("failed to marshal session: %w", err)
+	}
+	if err := b.client.Put(b.sessionKey(username), encoded, "application/json"); err != nil {
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+	return nil
+}
+
+// ClearSession forgets the saved browser session of an account
+func (b *S3Bucket) ClearSession(username string) error {
+	return b.client.Delete(b.sessionKey(username))
+}
+
+// SaveCapture uploads the files of a failed login's capture and returns their URLs
+// Only the most recent maxLoginCaptures captures are kept
+func (b *S3Bucket) SaveCapture(capture *uscis.LoginCapture) ([]string, error) {
+	var urls []string
+	for _, part := range captureParts(capture) {
+		key := b.key("diagnostics/" + part.name)
+		if err := b.client.Put(key, part.data, part.contentType); err != nil {
+			return urls, fmt.Errorf("failed to save login capture: %w", err)
+		}
+		urls = append(urls, b.client.String()+"/"+key)
+	}
+	b.pruneCaptures()
+	return urls, nil
+}
+
+// pruneCaptures removes all but the most recent maxLoginCaptures captures
+func (b *S3Bucket) pruneCaptures() {
+	keys, err := b.client.List(b.key("diagnostics/login_"))
+	if err != nil {
+		return
+	}
+	// Both files of a capture share its time, and List returns keys in order
+	var names []string
+	byCapture := make(map[string][]string)
+	for _, key := range keys {
+		name := strings.TrimSuffix(key, path.Ext(key))
+		if _, ok := byCapture[name]; !ok {
+			names = append(names, name)
+		}
+		byCapture[name] = append(byCapture[name], key)
+	}
+	for len(names) > maxLoginCaptures {
+		for _, key := range byCapture[names[0]] {
+			b.client.Delete(key)
+		}
+		names = names[1:]
+	}
+}
+
+// RecentCaptures returns up to limit files of the most recent login captures, newest first
+func (b *S3Bucket) RecentCaptures(limit int) ([]CaptureFile, error) {
+	keys, err := b.client.List(b.key("diagnostics/login_"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list login captures: %w", err)
+	}
+	slices.Reverse(keys)
+
+	var files []CaptureFile
+	for _, key := range keys[:min(limit, len(keys))] {
+		data, err := b.client.Get(key)
+		if err != nil {
+			return files, fmt.Errorf("failed to read login capture: %w", err)
+		}
+		files = append(files, CaptureFile{Name: path.Base(key), Data: data})
+	}
+	return files, nil
+}
+
+// S3Storage implements Storage for one case in an S3 bucket
+type S3Storage struct {
+	bucket *S3Bucket
+	caseID string
+}
+
+// snapshotKey returns the key of the snapshot recorded at the given time
+func (s *S3Storage) snapshotKey(at time.Time) string {
+	return s.bucket.key(s.caseID + "_" + at.UTC().Format(s3TimestampFormat) + ".json")
+}
+
+// metaKey returns the key of the metadata, which never matches the snapshot prefix
+func (s *S3Storage) metaKey() string {
+	return s.bucket.key(s.caseID + ".meta.json")
+}
+
+// snapshotKeys lists the snapshot keys of this case, oldest first, with their times
+func (s *S3Storage) snapshotKeys() ([]string, []time.Time, error) {
+	prefix := s.bucket.key(s.caseID + "_")
+	keys, err := s.bucket.client.List(prefix)
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(keys)
+
+	var snapshotKeys []string
+	var times []time.Time
+	for _, key := range keys {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(key, prefix), ".json")
+		at, err := time.Parse(s3TimestampFormat, stamp)
+		if err != nil {
+			// Not a snapshot (e.g. an object from another tool)
+			continue
+		}
+		snapshotKeys = append(snapshotKeys, key)
+		times = append(times, at)
+	}
+	return snapshotKeys, times, nil
+}
+
+// loadSnapshot reads and parses one snapshot object
+func (s *S3Storage) loadSnapshot(key string) (map[string]interface{}, error) {
+	data, err := s.bucket.client.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	var state map[string]interface{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse state object %s: %w", key, err)
+	}
+	return state, nil
+}
+
+// Load loads the most recent snapshot for this case, or nil on first run
+func (s *S3Storage) Load() (map[string]interface{}, error) {
+	keys, _, err := s.snapshotKeys()
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	return s.loadSnapshot(keys[len(keys)-1])
+}
+
+// Save saves the current state as a new timestamped object
+func (s *S3Storage) Save(data map[string]interface{}) error {
+	return s.SaveAt(time.Now(), data)
+}
+
+// SaveAt saves a state recorded at the given time, e.g. when importing history
+// An existing snapshot with the same timestamp is replaced
+func (s *S3Storage) SaveAt(at time.Time, data map[string]interface{}) error {
+	jsonData, err := json.MarshalIndent(uscis.Canonicalize(data), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+	// A PUT replaces the object in one step, so readers never see a partial snapshot
+	return s.bucket.client.Put(s.snapshotKey(at), jsonData, "application/json")
+}
+
+// ListSnapshots loads every saved state for this case, oldest first
+func (s *S3Storage) ListSnapshots() ([]Snapshot, error) {
+	keys, times, err := s.snapshotKeys()
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []Snapshot
+	for i, key := range keys {
+		state, err := s.loadSnapshot(key)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, Snapshot{Timestamp: times[i].Local(), Data: state})
+	}
+	return snapshots, nil
+}
+
+// LoadMeta returns the metadata of the last write, or nil if none was recorded
+func (s *S3Storage) LoadMeta() (*StateMeta, error) {
+	data, err := s.bucket.client.Get(s.metaKey())
+	if errors.Is(err, s3.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state metadata: %w", err)
+	}
+	var meta StateMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("failed to parse state metadata: %w", err)
+	}
+	return &meta, nil
+}
+
+// SaveMeta records the instance that just wrote this case's state
+func (s *S3Storage) SaveMeta(meta StateMeta) error {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state metadata: %w", err)
+	}
+	if err := s.bucket.client.Put(s.metaKey(), data, "application/json"); err != nil {
+		return fmt.Errorf("failed to write state metadata: %w", err)
+	}
+	return nil
+}
+
+// Delete removes every saved state and the metadata of this case
+func (s *S3Storage) Delete() error {
+	keys, _, err := s.snapshotKeys()
+	if err != nil {
+		return err
+	}
+	for _, key := range append(keys, s.metaKey()) {
+		if err := s.bucket.client.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ArchiveRecords returns the snapshots written before the given time, except the latest
+func (s *S3Storage) ArchiveRecords(before time.Time) ([]ArchiveRecord, error) {
+	snapshots, err := s.ListSnapshots()
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshots) > 0 {
+		snapshots = snapshots[:len(snapshots)-1]
+	}
+
+	var records []ArchiveRecord
+	for _, snap := range snapshots {
+		if !snap.Timestamp.Before(before) {
+			break
+		}
+		records = append(records, ArchiveRecord{Kind: ArchiveSnapshot, CaseID: s.caseID, Time: snap.Timestamp, Data: snap.Data})
+	}
+	return records, nil
+}
+
+// DeleteRecords removes archived snapshot objects
+func (s *S3Storage) DeleteRecords(records []ArchiveRecord) error {
+	for _, record := range records {
+		if record.Kind != ArchiveSnapshot {
+			continue
+		}
+		if err := s.bucket.client.Delete(s.snapshotKey(record.Time)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RestoreRecords writes archived snapshots back as objects
+// Change records are skipped: the bucket has no change log
+func (s *S3Storage) RestoreRecords(records []ArchiveRecord) (int, error) {
+	keys, _, err := s.snapshotKeys()
+	if err != nil {
+		return 0, err
+	}
+	restored := 0
+	for _, record := range records {
+		if record.Kind != ArchiveSnapshot {
+			continue
+		}
+		key := s.snapshotKey(record.Time)
+		if i := sort.SearchStrings(keys, key); i < len(keys) && keys[i] == key {
+			continue
+		}
+		if err := s.SaveAt(record.Time, record.Data); err != nil {
+			return restored, err
+		}
+		restored++
+	}
+	return restored, nil
+}
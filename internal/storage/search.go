@@ -0,0 +1,313 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// searchSchema creates the notes table and the full-text index over status
+// changes, case history events (notices) and notes
+const searchSchema = `
+CREATE TABLE IF NOT EXISTS notes (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	case_id    TEXT    NOT NULL,
+	created_at INTEGER NOT NULL, -- unix milliseconds
+	text       TEXT    NOT NULL
+);
+CREATE INDEX IF NOT EXISTS notes_case_time ON notes (case_id, created_at);
+
+CREATE VIRTUAL TABLE IF NOT EXISTS search_index USING fts5(
+	case_id UNINDEXED,
+	kind    UNINDEXED,
+	at      UNINDEXED, -- unix milliseconds
+	body,
+	tokenize = 'porter unicode61'
+);
+`
+
+// Kinds of search index entries
+const (
+	SearchStatus = "status" // the case moved to a new status
+	SearchNotice = "notice" // an event in the case history USCIS reports
+	SearchNote   = "note"   // a note added by the user
+)
+
+// Search snippets mark the matched terms with these bytes so callers can
+// highlight them without confusing them with the text
+const (
+	SnippetStart = "\x02"
+	SnippetEnd   = "\x03"
+)
+
+// searchAbbreviations expands shorthand that doesn't appear in USCIS texts
+var searchAbbreviations = map[string]string{
+	"rfe":  "request for evidence",
+	"noid": "notice of intent to deny",
+	"ead":  "employment authorization",
+}
+
+// SearchQuery selects index entries matching Text
+// Since, Until and CaseID narrow the results when set; Limit 0 means 50
+type SearchQuery struct {
+	Text   string
+	CaseID string
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+}
+
+// SearchResult is one matching index entry
+type SearchResult struct {
+	CaseID  string    `json:"case_id"`
+	Kind    string    `json:"kind"`
+	At      time.Time `json:"at"`
+	Text    string    `json:"text"`
+	Snippet string    `json:"snippet"` // Text around the match, terms between SnippetStart and SnippetEnd
+}
+
+// Note is a free-text note attached to a case
+type Note struct {
+	CaseID    string    `json:"case_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Text      string    `json:"text"`
+}
+
+// searchEntry is an index entry derived from a saved state
+type searchEntry struct {
+	kind string
+	at   time.Time
+	body string
+}
+
+// searchEntries returns the index entries for a state saved at the given time:
+// its status if it differs from the previous one, and history events not seen before
+func searchEntries(at time.Time, previous, current map[string]interface{}) []searchEntry {
+	prev := uscis.NewCaseStatus(previous)
+	cur := uscis.NewCaseStatus(current)
+	if cur == nil {
+		return nil
+	}
+
+	var entries []searchEntry
+	if prev == nil || prev.StatusTitle != cur.StatusTitle || prev.Description != cur.Description {
+		body := strings.TrimSpace(cur.StatusTitle + "\n" + cur.Description)
+		if body != "" {
+			entries = append(entries, searchEntry{kind: SearchStatus, at: at, body: body})
+		}
+	}
+
+	seen := make(map[uscis.CaseAction]bool)
+	if prev != nil {
+		for _, action := range prev.Actions {
+			seen[action] = true
+		}
+	}
+	for _, action := range cur.Actions {
+		if seen[action] || action.Description == "" {
+			continue
+		}
+		entry := searchEntry{kind: SearchNotice, at: action.Date, body: action.Description}
+		if entry.at.IsZero() {
+			entry.at = at
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// indexEntries adds entries for a case to the search index
+func indexEntries(tx *sql.Tx, caseID string, entries []searchEntry) error {
+	for _, e := range entries {
+		if _, err := tx.Exec(`INSERT INTO search_index (case_id, kind, at, body) VALUES (?, ?, ?, ?)`,
+			caseID, e.kind, e.at.UnixMilli(), e.body); err != nil {
+			return fmt.Errorf("failed to index %s: %w", e.kind, err)
+		}
+	}
+	return nil
+}
+
+// backfillSearchIndex indexes the saved history of databases created before the
+// search index existed. It does nothing once the index has entries
+func (s *SQLiteDB) backfillSearchIndex() error {
+	var indexed, saved int
+	if err := s.db.QueryRow(`SELECT (SELECT count(*) FROM search_index), (SELECT count(*) FROM snapshots)`).Scan(&indexed, &saved); err != nil {
+		return fmt.Errorf("failed to inspect search index: %w", err)
+	}
+	if indexed > 0 || saved == 0 {
+		return nil
+	}
+
+	rows, err := s.db.Query(`SELECT DISTINCT case_id FROM snapshots`)
+	if err != nil {
+		return fmt.Errorf("failed to list cases: %w", err)
+	}
+	var caseIDs []string
+	for rows.Next() {
+		var caseID string
+		if err := rows.Scan(&caseID); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read case: %w", err)
+		}
+		caseIDs = append(caseIDs, caseID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to list cases: %w", err)
+	}
+
+	// Read everything first: the database has a single connection, which the transaction holds
+	entries := make(map[string][]searchEntry, len(caseIDs))
+	for _, caseID := range caseIDs {
+		snapshots, err := s.ForCase(caseID).ListSnapshots()
+		if err != nil {
+			return err
+		}
+		var previous map[string]interface{}
+		for _, snap := range snapshots {
+			entries[caseID] = append(entries[caseID], searchEntries(snap.Timestamp, previous, snap.Data)...)
+			previous = snap.Data
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, caseID := range caseIDs {
+		if err := indexEntries(tx, caseID, entries[caseID]); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`INSERT INTO search_index (case_id, kind, at, body) SELECT case_id, ?, created_at, text FROM notes`, SearchNote); err != nil {
+		return fmt.Errorf("failed to index notes: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit search index: %w", err)
+	}
+	return nil
+}
+
+// AddNote attaches a note to a case and makes it searchable
+func (s *SQLiteDB) AddNote(caseID, text string, at time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`INSERT INTO notes (case_id, created_at, text) VALUES (?, ?, ?)`, caseID, at.UnixMilli(), text); err != nil {
+		return fmt.Errorf("failed to save note: %w", err)
+	}
+	if err := indexEntries(tx, caseID, []searchEntry{{kind: SearchNote, at: at, body: text}}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit note: %w", err)
+	}
+	return nil
+}
+
+// ListNotes returns the notes of a case, oldest first
+func (s *SQLiteDB) ListNotes(caseID string) ([]Note, error) {
+	rows, err := s.db.Query(`SELECT created_at, text FROM notes WHERE case_id = ? ORDER BY created_at, id`, caseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notes: %w", err)
+	}
+	defer rows.Close()
+
+	var notes []Note
+	for rows.Next() {
+		var (
+			note      = Note{CaseID: caseID}
+			createdAt int64
+		)
+		if err := rows.Scan(&createdAt, &note.Text); err != nil {
+			return nil, fmt.Errorf("failed to read note: %w", err)
+		}
+		note.CreatedAt = time.UnixMilli(createdAt)
+		notes = append(notes, note)
+	}
+	return notes, rows.Err()
+}
+
+// Search returns the index entries matching the query, newest first
+func (s *SQLiteDB) Search(q SearchQuery) ([]SearchResult, error) {
+	match := searchMatchExpr(q.Text)
+	if match == "" {
+		return nil, fmt.Errorf("search text is empty")
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+
+	query := `SELECT case_id, kind, at, body, snippet(search_index, 3, ?, ?, '…', 16) FROM search_index WHERE search_index MATCH ?`
+	args := []interface{}{SnippetStart, SnippetEnd, match}
+	if q.CaseID != "" {
+		query += ` AND case_id = ?`
+		args = append(args, q.CaseID)
+	}
+	if !q.Since.IsZero() {
+		query += ` AND CAST(at AS INTEGER) >= ?`
+		args = append(args, q.Since.UnixMilli())
+	}
+	if !q.Until.IsZero() {
+		query += ` AND CAST(at AS INTEGER) < ?`
+		args = append(args, q.Until.UnixMilli())
+	}
+	query += ` ORDER BY CAST(at AS INTEGER) DESC, rank LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search: %w", err)
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var (
+			result SearchResult
+			at     int64
+		)
+		if err := rows.Scan(&result.CaseID, &result.Kind, &at, &result.Text, &result.Snippet); err != nil {
+			return nil, fmt.Errorf("failed to read search result: %w", err)
+		}
+		result.At = time.UnixMilli(at)
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// searchMatchExpr turns free text into an FTS5 query that matches entries
+// containing every word, so punctuation in the input can't be a syntax error
+// A trailing * matches words starting with the prefix ("employ*"), and known
+// abbreviations also match their spelled-out form ("RFE" finds "Request for Evidence")
+func searchMatchExpr(text string) string {
+	var terms []string
+	for _, field := range strings.Fields(text) {
+		prefix := strings.HasSuffix(field, "*")
+		words := strings.FieldsFunc(field, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for i, word := range words {
+			term := `"` + word + `"`
+			if prefix && i == len(words)-1 {
+				term += "*"
+			} else if expanded, ok := searchAbbreviations[strings.ToLower(word)]; ok {
+				term = `(` + term + ` OR "` + expanded + `")`
+			}
+			terms = append(terms, term)
+		}
+	}
+	return strings.Join(terms, " AND ")
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// savedSession is the persisted browser session of one USCIS account
+type savedSession struct {
+	SavedAt time.Time       `json:"saved_at"`
+	Data    json.RawMessage `json:"data"` // opaque to storage (the browser's cookies)
+}
+
+// SessionStore persists authenticated browser sessions in {stateDir}/sessions.json,
+// keyed by USCIS username, so restarts can skip a full login and 2FA
+// The file holds live credentials and is written readable by the owner only
+type SessionStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewSessionStore creates a session store under the state directory
+func NewSessionStore(stateDir string) *SessionStore {
+	return &SessionStore{path: filepath.Join(stateDir, "sessions.json")}
+}
+
+// LoadSession returns the saved session of an account, or nil if there is none
+func (s *SessionStore) LoadSession(username string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sessions, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	session, ok := sessions[username]
+	if !ok {
+		return nil, nil
+	}
+	return session.Data, nil
+}
+
+// SaveSession replaces the saved session of an account
+func (s *SessionStore) SaveSession(username string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sessions, err := s.load()
+	if err != nil {
+		return err
+	}
+	sessions[username] = savedSession{SavedAt: time.Now(), Data: data}
+	return s.save(sessions)
+}
+
+// ClearSession forgets the saved session of an account
+func (s *SessionStore) ClearSession(username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sessions, err := s.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := sessions[username]; !ok {
+		return nil
+	}
+	delete(sessions, username)
+	return s.save(sessions)
+}
+
+// load reads every saved session; caller must hold the lock
+func (s *SessionStore) load() (map[string]savedSession, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return make(map[string]savedSession), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sessions: %w", err)
+	}
+
+	sessions := make(map[string]savedSession)
+	if err := json.Unmarshal(data, &sessions); err != nil {
+		return nil, fmt.Errorf("failed to parse sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// save writes every saved session atomically; caller must hold the lock
+func (s *SessionStore) save(sessions map[string]savedSession) error {
+	data, err := json.MarshalIndent(sessions, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal sessions: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	tempFile := s.path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0600); err != nil {
+		return fmt.Errorf("failed to write sessions: %w", err)
+	}
+	if err := os.Rename(tempFile, s.path); err != nil {
+		return fmt.Errorf("failed to rename sessions file: %w", err)
+	}
+	return nil
+}
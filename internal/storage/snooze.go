@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Snooze mutes notifications for a case until a point in time
+type Snooze struct {
+	CaseID    string    `json:"case_id"`
+	Until     time.Time `json:"until"`
+	Source    string    `json:"source,omitempty"` // api, link, bot...
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SnoozeStore persists snoozes in {stateDir}/snoozes.json
+// It is safe for concurrent use
+type SnoozeStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewSnoozeStore creates a snooze store under the state directory
+func NewSnoozeStore(stateDir string) *SnoozeStore {
+	return &SnoozeStore{path: filepath.Join(stateDir, "snoozes.json")}
+}
+
+// Set snoozes a case until the given time, replacing any existing snooze
+func (s *SnoozeStore) Set(caseID string, until time.Time, source string) (*Snooze, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snoozes, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	snooze := &Snooze{CaseID: caseID, Until: until, Source: source, CreatedAt: time.Now()}
+	snoozes[caseID] = snooze
+	return snooze, s.save(snoozes)
+}
+
+// Clear removes the snooze of a case, if any
+func (s *SnoozeStore) Clear(caseID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snoozes, err := s.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := snoozes[caseID]; !ok {
+		return nil
+	}
+	delete(snoozes, caseID)
+	return s.save(snoozes)
+}
+
+// Active returns the snooze of a case if it has not expired yet, or nil
+func (s *SnoozeStore) Active(caseID string, now time.Time) (*Snooze, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snoozes, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	if snooze, ok := snoozes[caseID]; ok && now.Before(snooze.Until) {
+		return snooze, nil
+	}
+	return nil, nil
+}
+
+// List returns every unexpired snooze, soonest to expire first
+func (s *SnoozeStore) List(now time.Time) ([]*Snooze, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snoozes, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	var active []*Snooze
+	for _, snooze := range snoozes {
+		if now.Before(snooze.Until) {
+			active = append(active, snooze)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].Until.Before(active[j].Until)
+	})
+	return active, nil
+}
+
+// load reads the snooze file; a missing file means no snoozes
+// Caller must hold the lock
+func (s *SnoozeStore) load() (map[string]*Snooze, error) {
+	snoozes := make(map[string]*Snooze)
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return snoozes, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snoozes: %w", err)
+	}
+	if err := json.Unmarshal(data, &snoozes); err != nil {
+		return nil, fmt.Errorf("failed to parse snoozes: %w", err)
+	}
+	return snoozes, nil
+}
+
+// save writes the snooze file atomically, dropping expired entries
+// Caller must hold the lock
+func (s *SnoozeStore) save(snoozes map[string]*Snooze) error {
+	now := time.Now()
+	for caseID, snooze := range snoozes {
+		if !now.Before(snooze.Until) {
+			delete(snoozes, caseID)
+		}
+	}
+
+	data, err := json.MarshalIndent(snoozes, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal snoozes: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	tempFile := s.path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write snoozes: %w", err)
+	}
+	if err := os.Rename(tempFile, s.path); err != nil {
+		return fmt.Errorf("failed to rename snoozes: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,441 @@
+package storage
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+	_ "modernc.org/sqlite" // Pure Go driver; the image is built with CGO_ENABLED=0
+)
+
+// sqliteSchema creates the tables on first use
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS snapshots (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	case_id     TEXT    NOT NULL,
+	recorded_at INTEGER NOT NULL, -- unix milliseconds
+	data        TEXT    NOT NULL  -- JSON
+);
+CREATE INDEX IF NOT EXISTS snapshots_case_time ON snapshots (case_id, recorded_at);
+
+CREATE TABLE IF NOT EXISTS changes (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	case_id     TEXT    NOT NULL,
+	detected_at INTEGER NOT NULL, -- unix milliseconds
+	field       TEXT    NOT NULL,
+	old_value   TEXT,             -- JSON, NULL when the field is new
+	new_value   TEXT              -- JSON, NULL when the field was removed
+);
+CREATE INDEX IF NOT EXISTS changes_case_time ON changes (case_id, detected_at);
+
+CREATE TABLE IF NOT EXISTS case_meta (
+	case_id     TEXT PRIMARY KEY,
+	instance_id TEXT    NOT NULL,
+	hostname    TEXT    NOT NULL,
+	updated_at  INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+	username TEXT    PRIMARY KEY,
+	saved_at INTEGER NOT NULL, -- unix milliseconds
+	data     BLOB    NOT NULL  -- opaque to storage (the browser's cookies)
+);
+
+CREATE TABLE IF NOT EXISTS login_captures (
+	name        TEXT    PRIMARY KEY, -- login_<time>.jpg or login_<time>.html
+	captured_at INTEGER NOT NULL,    -- unix milliseconds
+	data        BLOB    NOT NULL
+);
+`
+
+// SQLiteDB is a SQLite database holding the state history of every case
+type SQLiteDB struct {
+	db *sql.DB
+}
+
+// OpenSQLite opens (creating if needed) the database at path
+func OpenSQLite(path string) (*SQLiteDB, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create database directory: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	// SQLite allows one writer at a time; a single connection avoids SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema + searchSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create schema: %w", err)
+	}
+
+	s := &SQLiteDB{db: db}
+	if err := s.backfillSearchIndex(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close closes the database
+func (s *SQLiteDB) Close() error {
+	return s.db.Close()
+}
+
+// ForCase returns the storage for one case
+func (s *SQLiteDB) ForCase(caseID string) *SQLiteStorage {
+	return &SQLiteStorage{db: s.db, caseID: caseID}
+}
+
+// ChangeRecord is one field change in the change log
+type ChangeRecord struct {
+	CaseID     string          `json:"case_id"`
+	DetectedAt time.Time       `json:"detected_at"`
+	Field      string          `json:"field"`
+	OldValue   json.RawMessage `json:"old_value"`
+	NewValue   json.RawMessage `json:"new_value"`
+}
+
+// ListChanges returns the change log of every case since the given time, oldest first
+func (s *SQLiteDB) ListChanges(since time.Time) ([]ChangeRecord, error) {
+	rows, err := s.db.Query(`SELECT case_id, detected_at, field, old_value, new_value FROM changes WHERE detected_at >= ? ORDER BY detected_at, id`, since.UnixMilli())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query changes: %w", err)
+	}
+	defer rows.Close()
+
+	var records []ChangeRecord
+	for rows.Next() {
+		var (
+			record             ChangeRecord
+			detectedAt         int64
+			oldValue, newValue sql.NullString
+		)
+		if err := rows.Scan(&record.CaseID, &detectedAt, &record.Field, &oldValue, &newValue); err != nil {
+			return nil, fmt.Errorf("failed to read change: %w", err)
+		}
+		record.DetectedAt = time.UnixMilli(detectedAt)
+		if oldValue.Valid {
+			record.OldValue = json.RawMessage(oldValue.String)
+		}
+		if newValue.Valid {
+			record.NewValue = json.RawMessage(newValue.String)
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// Empty reports whether the database holds no snapshot yet, as when it was just created
+func (s *SQLiteDB) Empty() (bool, error) {
+	var exists bool
+	if err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM snapshots)`).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to query snapshots: %w", err)
+	}
+	return !exists, nil
+}
+
+// ImportFiles copies the state files of a case in stateDir into the database, every
+// snapshot with its time and the metadata, and returns the number of snapshots copied
+// The change log is rebuilt from the snapshots; the files are left in place
+func (s *SQLiteDB) ImportFiles(stateDir, caseID string) (int, error) {
+	files := NewFileStorage(stateDir, caseID)
+	snapshots, err := files.ListSnapshots()
+	if err != nil {
+		return 0, err
+	}
+	target := s.ForCase(caseID)
+	for i, snap := range snapshots {
+		if err := target.SaveAt(snap.Timestamp, snap.Data); err != nil {
+			return i, err
+		}
+	}
+	meta, err := files.LoadMeta()
+	if err != nil {
+		return len(snapshots), err
+	}
+	if meta != nil {
+		if err := target.SaveMeta(*meta); err != nil {
+			return len(snapshots), err
+		}
+	}
+	return len(snapshots), nil
+}
+
+// LoadSession returns the saved browser session of an account, or nil if there is none
+func (s *SQLiteDB) LoadSession(username string) ([]byte, error) {
+	var data []byte
+	err := s.db.QueryRow(`SELECT data FROM sessions WHERE username = ?`, username).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session: %w", err)
+	}
+	return data, nil
+}
+
+// SaveSession replaces the saved browser session of an account
+func (s *SQLiteDB) SaveSession(username string, data []byte) error {
+	_, err := s.db.Exec(`INSERT INTO sessions (username, saved_at, data) VALUES (?, ?, ?)
+		ON CONFLICT (username) DO UPDATE SET saved_at = excluded.saved_at, data = excluded.data`,
+		username, time.Now().UnixMilli(), data)
+	if err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	return nil
+}
+
+// ClearSession forgets the saved browser session of an account
+func (s *SQLiteDB) ClearSession(username string) error {
+	if _, err := s.db.Exec(`DELETE FROM sessions WHERE username = ?`, username); err != nil {
+		return fmt.Errorf("failed to clear session: %w", err)
+	}
+	return nil
+}
+
+// SaveCapture stores the files of a failed login's capture and returns their names
+// Only the most recent maxLoginCaptures captures are kept
+func (s *SQLiteDB) SaveCapture(capture *uscis.LoginCapture) ([]string, error) {
+	var names []string
+	for _, part := range captureParts(capture) {
+		_, err := s.db.Exec(`INSERT INTO login_captures (name, captured_at, data) VALUES (?, ?, ?)
+			ON CONFLICT (name) DO UPDATE SET captured_at = excluded.captured_at, data = excluded.data`,
+			part.name, capture.Time.UnixMilli(), part.data)
+		if err != nil {
+			return names, fmt.Errorf("failed to save login capture: %w", err)
+		}
+		names = append(names, part.name)
+	}
+	_, err := s.db.Exec(`DELETE FROM login_captures WHERE captured_at NOT IN
+		(SELECT DISTINCT captured_at FROM login_captures ORDER BY captured_at DESC LIMIT ?)`, maxLoginCaptures)
+	if err != nil {
+		return names, fmt.Errorf("failed to prune login captures: %w", err)
+	}
+	return names, nil
+}
+
+// RecentCaptures returns up to limit files of the most recent login captures, newest first
+func (s *SQLiteDB) RecentCaptures(limit int) ([]CaptureFile, error) {
+	rows, err := s.db.Query(`SELECT name, data FROM login_captures ORDER BY captured_at DESC, name DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list login captures: %w", err)
+	}
+	defer rows.Close()
+
+	var files []CaptureFile
+	for rows.Next() {
+		var file CaptureFile
+		if err := rows.Scan(&file.Name, &file.Data); err != nil {
+			return nil, fmt.Errorf("failed to read login capture: %w", err)
+		}
+		files = append(files, file)
+	}
+	return files, rows.Err()
+}
+
+// SQLiteStorage implements Storage for one case in a shared SQLite database
+type SQLiteStorage struct {
+	db     *sql.DB
+	caseID string
+}
+
+// Load loads the most recent snapshot for this case, or nil on first run
+func (s *SQLiteStorage) Load() (map[string]interface{}, error) {
+	return s.loadBefore(math.MaxInt64)
+}
+
+// loadBefore loads the most recent snapshot recorded at or before the given unix milliseconds
+func (s *SQLiteStorage) loadBefore(atMilli int64) (map[string]interface{}, error) {
+	var data string
+	err := s.db.QueryRow(`SELECT data FROM snapshots WHERE case_id = ? AND recorded_at <= ? ORDER BY recorded_at DESC, id DESC LIMIT 1`, s.caseID, atMilli).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+
+	var state map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &state); err != nil {
+		return nil, fmt.Errorf("failed to parse state: %w", err)
+	}
+	return state, nil
+}
+
+// Save stores a new snapshot and logs the fields that changed since the previous one
+func (s *SQLiteStorage) Save(data map[string]interface{}) error {
+	return s.SaveAt(time.Now(), data)
+}
+
+// SaveAt stores a snapshot recorded at the given time, e.g. when importing history
+// The snapshot is stored in canonical form; changes are logged against the one immediately before it
+func (s *SQLiteStorage) SaveAt(at time.Time, data map[string]interface{}) error {
+	data = uscis.Canonicalize(data)
+	previous, err := s.loadBefore(at.UnixMilli())
+	if err != nil {
+		return err
+	}
+
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := at.UnixMilli()
+	if _, err := tx.Exec(`INSERT INTO snapshots (case_id, recorded_at, data) VALUES (?, ?, ?)`, s.caseID, now, string(jsonData)); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+
+	if err := indexEntries(tx, s.caseID, searchEntries(at, previous, data)); err != nil {
+		return err
+	}
+
+	if previous != nil {
+		for _, c := range diffTopLevel(previous, data) {
+			if _, err := tx.Exec(`INSERT INTO changes (case_id, detected_at, field, old_value, new_value) VALUES (?, ?, ?, ?, ?)`,
+				s.caseID, now, c.field, c.oldValue, c.newValue); err != nil {
+				return fmt.Errorf("failed to log change: %w", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit state: %w", err)
+	}
+	return nil
+}
+
+// ListSnapshots loads every saved state for this case, oldest first
+func (s *SQLiteStorage) ListSnapshots() ([]Snapshot, error) {
+	rows, err := s.db.Query(`SELECT recorded_at, data FROM snapshots WHERE case_id = ? ORDER BY recorded_at, id`, s.caseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	var snapshots []Snapshot
+	for rows.Next() {
+		var (
+			recordedAt int64
+			data       string
+		)
+		if err := rows.Scan(&recordedAt, &data); err != nil {
+			return nil, fmt.Errorf("failed to read snapshot: %w", err)
+		}
+		var state map[string]interface{}
+		if err := json.Unmarshal([]byte(data), &state); err != nil {
+			return nil, fmt.Errorf("failed to parse snapshot: %w", err)
+		}
+		snapshots = append(snapshots, Snapshot{Timestamp: time.UnixMilli(recordedAt), Data: state})
+	}
+	return snapshots, rows.Err()
+}
+
+// LoadMeta returns the metadata of the last write, or nil if none was recorded
+func (s *SQLiteStorage) LoadMeta() (*StateMeta, error) {
+	var (
+		meta      StateMeta
+		updatedAt int64
+	)
+	err := s.db.QueryRow(`SELECT instance_id, hostname, updated_at FROM case_meta WHERE case_id = ?`, s.caseID).Scan(&meta.InstanceID, &meta.Hostname, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state metadata: %w", err)
+	}
+	meta.UpdatedAt = time.UnixMilli(updatedAt)
+	return &meta, nil
+}
+
+// SaveMeta records which instance wrote the state
+func (s *SQLiteStorage) SaveMeta(meta StateMeta) error {
+	_, err := s.db.Exec(`INSERT INTO case_meta (case_id, instance_id, hostname, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (case_id) DO UPDATE SET instance_id = excluded.instance_id, hostname = excluded.hostname, updated_at = excluded.updated_at`,
+		s.caseID, meta.InstanceID, meta.Hostname, meta.UpdatedAt.UnixMilli())
+	if err != nil {
+		return fmt.Errorf("failed to save state metadata: %w", err)
+	}
+	return nil
+}
+
+// Delete removes every snapshot, logged change, note, search entry and the metadata of this case
+func (s *SQLiteStorage) Delete() error {
+	for _, table := range []string{"snapshots", "changes", "case_meta", "notes", "search_index"} {
+		if _, err := s.db.Exec(`DELETE FROM `+table+` WHERE case_id = ?`, s.caseID); err != nil {
+			return fmt.Errorf("failed to delete from %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// fieldChange is a top-level field difference with JSON-encoded values
+type fieldChange struct {
+	field              string
+	oldValue, newValue sql.NullString
+}
+
+// diffTopLevel compares two states field by field, in field order
+func diffTopLevel(previous, current map[string]interface{}) []fieldChange {
+	fields := make(map[string]bool)
+	for key := range previous {
+		fields[key] = true
+	}
+	for key := range current {
+		fields[key] = true
+	}
+	sorted := make([]string, 0, len(fields))
+	for key := range fields {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	var changes []fieldChange
+	for _, key := range sorted {
+		oldValue, hadOld := encodeField(previous, key)
+		newValue, hasNew := encodeField(current, key)
+		if hadOld == hasNew && bytes.Equal(oldValue, newValue) {
+			continue
+		}
+
+		c := fieldChange{field: key}
+		if hadOld {
+			c.oldValue = sql.NullString{String: string(oldValue), Valid: true}
+		}
+		if hasNew {
+			c.newValue = sql.NullString{String: string(newValue), Valid: true}
+		}
+		changes = append(changes, c)
+	}
+	return changes
+}
+
+// encodeField returns the JSON encoding of a field and whether it is present
+func encodeField(state map[string]interface{}, key string) ([]byte, bool) {
+	value, ok := state[key]
+	if !ok {
+		return nil, false
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return []byte(fmt.Sprint(value)), true
+	}
+	return data, true
+}
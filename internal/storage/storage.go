@@ -6,7 +6,10 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/uscis"
 )
 
 // Storage interface for persisting case status
@@ -15,6 +18,15 @@ type Storage interface {
 	Save(data map[string]interface{}) error
 }
 
+// Snapshot is a saved case status together with when it was recorded
+type Snapshot struct {
+	Timestamp time.Time
+	Data      map[string]interface{}
+}
+
+// stateTimestampFormat is the timestamp suffix used in state file names
+const stateTimestampFormat = "2006-01-02T15-04-05"
+
 // FileStorage implements Storage using a JSON file with timestamps
 type FileStorage struct {
 	stateDir string
@@ -72,8 +84,14 @@ func (f *FileStorage) Load() (map[string]interface{}, error) {
 
 // Save saves the current state to a new timestamped file
 func (f *FileStorage) Save(data map[string]interface{}) error {
-	// Marshal to JSON with indentation for readability
-	jsonData, err := json.MarshalIndent(data, "", "  ")
+	return f.SaveAt(time.Now(), data)
+}
+
+// SaveAt saves a state recorded at the given time, e.g. when importing history
+// An existing snapshot with the same timestamp is replaced
+func (f *FileStorage) SaveAt(at time.Time, data map[string]interface{}) error {
+	// Marshal the canonical form with indentation, so equal states give identical files
+	jsonData, err := json.MarshalIndent(uscis.Canonicalize(data), "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal state: %w", err)
 	}
@@ -83,11 +101,7 @@ func (f *FileStorage) Save(data map[string]interface{}) error {
 		return fmt.Errorf("failed to create state directory: %w", err)
 	}
 
-	// Generate timestamped filename: {caseID}_{timestamp}.json
-	// Format: IOE0933798378_2025-10-11T15-04-05.json
-	timestamp := time.Now().Format("2006-01-02T15-04-05")
-	filename := fmt.Sprintf("%s_%s.json", f.caseID, timestamp)
-	filePath := filepath.Join(f.stateDir, filename)
+	filePath := f.snapshotPath(at)
 
 	// Write to temp file first for atomic write
 	tempFile := filePath + ".tmp"
@@ -102,3 +116,96 @@ func (f *FileStorage) Save(data map[string]interface{}) error {
 
 	return nil
 }
+
+// snapshotPath returns the path of the state file recorded at the given time
+// Format: {caseID}_{timestamp}.json, e.g. IOE0933798378_2025-10-11T15-04-05.json
+func (f *FileStorage) snapshotPath(at time.Time) string {
+	timestamp := at.Local().Format(stateTimestampFormat)
+	return filepath.Join(f.stateDir, fmt.Sprintf("%s_%s.json", f.caseID, timestamp))
+}
+
+// ListSnapshots loads every saved state for this case, oldest first
+func (f *FileStorage) ListSnapshots() ([]Snapshot, error) {
+	pattern := filepath.Join(f.stateDir, f.caseID+"_*.json")
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search for state files: %w", err)
+	}
+
+	// Timestamp in filename sorts chronologically
+	sort.Strings(matches)
+
+	var snapshots []Snapshot
+	for _, path := range matches {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), f.caseID+"_"), ".json")
+		timestamp, err := time.ParseInLocation(stateTimestampFormat, stamp, time.Local)
+		if err != nil {
+			// Not a state snapshot (e.g. a file from another tool)
+			continue
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read state file %s: %w", path, err)
+		}
+
+		var state map[string]interface{}
+		if err := json.Unmarshal(data, &state); err != nil {
+			return nil, fmt.Errorf("failed to parse state file %s: %w", path, err)
+		}
+
+		snapshots = append(snapshots, Snapshot{Timestamp: timestamp, Data: state})
+	}
+
+	return snapshots, nil
+}
+
+// Delete removes every saved state and the metadata of this case
+func (f *FileStorage) Delete() error {
+	matches, err := filepath.Glob(filepath.Join(f.stateDir, f.caseID+"_*.json"))
+	if err != nil {
+		return fmt.Errorf("failed to search for state files: %w", err)
+	}
+	for _, path := range append(matches, f.metaPath()) {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to delete state file: %w", err)
+		}
+	}
+	return nil
+}
+
+// MoveTo moves the saved states and the metadata of this case into another directory,
+// e.g. when the case is assigned to a tenant, and returns how many files it moved
+// Files already in the target directory are kept, so a move interrupted by a crash
+// finishes on the next call
+func (f *FileStorage) MoveTo(dir string) (int, error) {
+	matches, err := filepath.Glob(filepath.Join(f.stateDir, f.caseID+"_*.json"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to search for state files: %w", err)
+	}
+	if _, err := os.Stat(f.metaPath()); err == nil {
+		matches = append(matches, f.metaPath())
+	}
+	if len(matches) == 0 {
+		return 0, nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	moved := 0
+	for _, path := range matches {
+		target := filepath.Join(dir, filepath.Base(path))
+		if _, err := os.Stat(target); err == nil {
+			if err := os.Remove(path); err != nil {
+				return moved, fmt.Errorf("failed to remove moved state file: %w", err)
+			}
+			continue
+		}
+		if err := os.Rename(path, target); err != nil {
+			return moved, fmt.Errorf("failed to move state file: %w", err)
+		}
+		moved++
+	}
+	return moved, nil
+}
@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/uscis"
+)
+
+// openTestSQLite opens a fresh database in a temporary directory
+func openTestSQLite(t *testing.T) *SQLiteDB {
+	t.Helper()
+	db, err := OpenSQLite(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// stored returns data the way a backend gives it back: canonical and through JSON
+func stored(t *testing.T, data map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	encoded, err := json.Marshal(uscis.Canonicalize(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	return decoded
+}
+
+func TestSQLiteRoundTrip(t *testing.T) {
+	db := openTestSQLite(t)
+	store := db.ForCase("IOE0933798378")
+
+	state, err := store.Load()
+	if err != nil || state != nil {
+		t.Fatalf("Load on an empty database = %v, %v, want nil, nil", state, err)
+	}
+
+	first := map[string]interface{}{"status": "Case Was Received", "formType": "I-485"}
+	second := map[string]interface{}{"status": "Case Was Approved", "formType": "I-485"}
+	start := time.Date(2025, 10, 11, 15, 4, 5, 0, time.UTC)
+	if err := store.SaveAt(start, first); err != nil {
+		t.Fatalf("SaveAt: %v", err)
+	}
+	if err := store.SaveAt(start.Add(time.Hour), second); err != nil {
+		t.Fatalf("SaveAt: %v", err)
+	}
+
+	state, err = store.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if want := stored(t, second); !reflect.DeepEqual(state, want) {
+		t.Errorf("Load = %v, want %v", state, want)
+	}
+
+	snapshots, err := store.ListSnapshots()
+	if err != nil {
+		t.Fatalf("ListSnapshots: %v", err)
+	}
+	if len(snapshots) != 2 {
+		t.Fatalf("ListSnapshots returned %d snapshots, want 2", len(snapshots))
+	}
+	if !snapshots[0].Timestamp.Equal(start) || !reflect.DeepEqual(snapshots[0].Data, stored(t, first)) {
+		t.Errorf("first snapshot = %v at %v, want %v at %v", snapshots[0].Data, snapshots[0].Timestamp, stored(t, first), start)
+	}
+
+	// Other cases don't see this one's state
+	if other, err := db.ForCase("IOE0933798379").Load(); err != nil || other != nil {
+		t.Errorf("Load of another case = %v, %v, want nil, nil", other, err)
+	}
+
+	changes, err := db.ListChanges(start.Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("ListChanges: %v", err)
+	}
+	if len(changes) == 0 {
+		t.Errorf("ListChanges found no changes after a status change")
+	}
+
+	if err := store.Delete(); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if state, err := store.Load(); err != nil || state != nil {
+		t.Errorf("Load after Delete = %v, %v, want nil, nil", state, err)
+	}
+}
+
+func TestSQLiteMetaRoundTrip(t *testing.T) {
+	store := openTestSQLite(t).ForCase("IOE0933798378")
+
+	if meta, err := store.LoadMeta(); err != nil || meta != nil {
+		t.Fatalf("LoadMeta before SaveMeta = %v, %v, want nil, nil", meta, err)
+	}
+	for _, want := range []StateMeta{
+		{InstanceID: "a", Hostname: "host-a", UpdatedAt: time.UnixMilli(1760195045000)},
+		{InstanceID: "b", Hostname: "host-b", UpdatedAt: time.UnixMilli(1760198645000)},
+	} {
+		if err := store.SaveMeta(want); err != nil {
+			t.Fatalf("SaveMeta: %v", err)
+		}
+		got, err := store.LoadMeta()
+		if err != nil {
+			t.Fatalf("LoadMeta: %v", err)
+		}
+		if got == nil || got.InstanceID != want.InstanceID || got.Hostname != want.Hostname || !got.UpdatedAt.Equal(want.UpdatedAt) {
+			t.Errorf("LoadMeta = %+v, want %+v", got, want)
+		}
+	}
+}
+
+func TestSQLiteSessionRoundTrip(t *testing.T) {
+	db := openTestSQLite(t)
+
+	if session, err := db.LoadSession("me"); err != nil || session != nil {
+		t.Fatalf("LoadSession before SaveSession = %q, %v, want nil, nil", session, err)
+	}
+	if err := db.SaveSession("me", []byte("cookies")); err != nil {
+		t.Fatalf("SaveSession: %v", err)
+	}
+	if session, err := db.LoadSession("me"); err != nil || string(session) != "cookies" {
+		t.Errorf("LoadSession = %q, %v, want %q", session, err, "cookies")
+	}
+	if err := db.ClearSession("me"); err != nil {
+		t.Fatalf("ClearSession: %v", err)
+	}
+	if session, err := db.LoadSession("me"); err != nil || session != nil {
+		t.Errorf("LoadSession after ClearSession = %q, %v, want nil, nil", session, err)
+	}
+}
+
+func TestRecentCaptures(t *testing.T) {
+	start := time.Date(2025, 10, 11, 15, 4, 5, 0, time.UTC)
+	capture := func(i int) *uscis.LoginCapture {
+		return &uscis.LoginCapture{Time: start.Add(time.Duration(i) * time.Minute), Screenshot: []byte("jpg"), HTML: "<p>page</p>"}
+	}
+	stores := map[string]interface {
+		uscis.CaptureStore
+		CaptureReader
+	}{
+		"file":   NewLoginCaptureStore(t.TempDir()),
+		"sqlite": openTestSQLite(t),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			for i := 0; i < maxLoginCaptures+2; i++ {
+				if _, err := store.SaveCapture(capture(i)); err != nil {
+					t.Fatalf("SaveCapture: %v", err)
+				}
+			}
+
+			files, err := store.RecentCaptures(3)
+			if err != nil {
+				t.Fatalf("RecentCaptures: %v", err)
+			}
+			newest := "login_" + capture(maxLoginCaptures+1).Time.Format("2006-01-02T15-04-05")
+			var names []string
+			for _, file := range files {
+				names = append(names, file.Name)
+			}
+			want := []string{newest + ".jpg", newest + ".html", "login_" + capture(maxLoginCaptures).Time.Format("2006-01-02T15-04-05") + ".jpg"}
+			if !reflect.DeepEqual(names, want) {
+				t.Errorf("RecentCaptures(3) = %v, want %v", names, want)
+			}
+
+			// Pruning keeps the files of the most recent captures only
+			all, err := store.RecentCaptures(100)
+			if err != nil {
+				t.Fatalf("RecentCaptures: %v", err)
+			}
+			if len(all) != 2*maxLoginCaptures {
+				t.Errorf("RecentCaptures kept %d files, want %d", len(all), 2*maxLoginCaptures)
+			}
+		})
+	}
+}
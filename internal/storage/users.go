@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Roles of dashboard and API users
+const (
+	RoleAdmin  = "admin"  // sees every case and may change state (snoozes, toggles...)
+	RoleViewer = "viewer" // sees only the cases granted to them, read-only
+)
+
+// User is a person allowed into the dashboard and API
+type User struct {
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	Cases     []string  `json:"cases,omitempty"` // case IDs and bundle names a viewer may see
+	TokenHash string    `json:"token_hash"`      // SHA-256 of the access token, hex
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserStore persists dashboard and API users in {stateDir}/users.json
+// Only token hashes are stored. It is safe for concurrent use
+type UserStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewUserStore creates a user store under the state directory
+func NewUserStore(stateDir string) *UserStore {
+	return &UserStore{path: filepath.Join(stateDir, "users.json")}
+}
+
+// HashToken returns the stored form of an access token
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// List returns every user, sorted by name
+func (s *UserStore) List() ([]User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load()
+}
+
+// Put adds a user, or replaces the user of the same name
+func (s *UserStore) Put(user User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users, err := s.load()
+	if err != nil {
+		return err
+	}
+	users = slices.DeleteFunc(users, func(u User) bool { return u.Name == user.Name })
+	users = append(users, user)
+	slices.SortFunc(users, func(a, b User) int { return strings.Compare(a.Name, b.Name) })
+	return s.save(users)
+}
+
+// Remove deletes a user and reports whether there was one
+func (s *UserStore) Remove(name string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users, err := s.load()
+	if err != nil {
+		return false, err
+	}
+	kept := slices.DeleteFunc(users, func(u User) bool { return u.Name == name })
+	if len(kept) == len(users) {
+		return false, nil
+	}
+	return true, s.save(kept)
+}
+
+// ByToken returns the user holding an access token, or nil
+func (s *UserStore) ByToken(token string) (*User, error) {
+	if token == "" {
+		return nil, nil
+	}
+	users, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	hash := []byte(HashToken(token))
+	for i := range users {
+		if subtle.ConstantTimeCompare(hash, []byte(users[i].TokenHash)) == 1 {
+			return &users[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// load reads the users; a missing file means none
+func (s *UserStore) load() ([]User, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read users: %w", err)
+	}
+	var users []User
+	if err := json.Unmarshal(data, &users); err != nil {
+		return nil, fmt.Errorf("failed to parse users: %w", err)
+	}
+	return users, nil
+}
+
+// save writes the users atomically, readable only by the owner
+func (s *UserStore) save(users []User) error {
+	if users == nil {
+		users = []User{}
+	}
+	data, err := json.MarshalIndent(users, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal users: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	tempFile := s.path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0600); err != nil {
+		return fmt.Errorf("failed to write users: %w", err)
+	}
+	if err := os.Rename(tempFile, s.path); err != nil {
+		return fmt.Errorf("failed to rename users: %w", err)
+	}
+	return nil
+}
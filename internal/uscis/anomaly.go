@@ -0,0 +1,61 @@
+package uscis
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Anomaly checks catch payloads that can't be a real update of the case: a login on the
+// wrong account or a response for another case. Such payloads must be kept out of change
+// detection, which would report every field of the foreign case as changed
+
+// ReceiptAnomaly reports a payload whose receipt number belongs to another case
+// Returns an empty string when the receipt number matches or the payload has none
+func ReceiptAnomaly(caseID string, current *CaseStatus) string {
+	if current == nil || current.ReceiptNumber == "" {
+		return ""
+	}
+	if normalizeReceipt(current.ReceiptNumber) != normalizeReceipt(caseID) {
+		return fmt.Sprintf("receipt number %s doesn't match the case", current.ReceiptNumber)
+	}
+	return ""
+}
+
+// ShapeAnomalies compares a payload with the case's saved state and reports a changed
+// form type and headline fields (receipt number, form type, status) that disappeared
+// Both must be redacted the same way. Returns nil without saved state
+func ShapeAnomalies(previous, current *CaseStatus) []string {
+	if previous == nil || current == nil {
+		return nil
+	}
+
+	var anomalies []string
+	if previous.FormType != "" && current.FormType != "" && !strings.EqualFold(previous.FormType, current.FormType) {
+		anomalies = append(anomalies, fmt.Sprintf("form type changed from %s to %s", previous.FormType, current.FormType))
+	}
+	expected := []struct {
+		name          string
+		before, after string
+	}{
+		{"receipt number", previous.ReceiptNumber, current.ReceiptNumber},
+		{"form type", previous.FormType, current.FormType},
+		{"status", previous.StatusTitle, current.StatusTitle},
+	}
+	for _, field := range expected {
+		if field.before != "" && field.after == "" {
+			anomalies = append(anomalies, field.name+" is missing")
+		}
+	}
+	return anomalies
+}
+
+// normalizeReceipt reduces a receipt number to its upper-case letters and digits
+func normalizeReceipt(receipt string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToUpper(r)
+		}
+		return -1
+	}, receipt)
+}
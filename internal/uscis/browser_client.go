@@ -1,21 +1,34 @@
 package uscis
 
 import (
-	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"os"
+	"net/http"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
 	"github.com/chromedp/chromedp"
+
+	"github.com/phhowardchen/case-tracker/internal/logging"
 )
 
 // EmailFetcher is an interface for fetching 2FA codes from email
 type EmailFetcher interface {
-	FetchLatest2FACode(senderEmail string, maxWaitTime time.Duration) (string, error)
+	// FetchLatest2FACode waits for the code of a login started at since, until ctx is done
+	FetchLatest2FACode(ctx context.Context, senderEmail string, since time.Time, maxWaitTime time.Duration) (string, error)
+}
+
+// SessionStore persists the cookies of a logged-in browser between restarts
+// The data is opaque to the store
+type SessionStore interface {
+	LoadSession(username string) ([]byte, error)
+	SaveSession(username string, data []byte) error
 }
 
 const (
@@ -27,14 +40,23 @@ const (
 // BrowserClient uses chromedp browser automation for authentication and API access
 // The browser session is kept alive and used for all API calls
 type BrowserClient struct {
-	ctx             context.Context
-	cancel          context.CancelFunc
-	allocCancel     context.CancelFunc
-	uscisUsername   string
-	uscisPassword   string
-	emailClient     EmailFetcher  // Optional: for automated 2FA
-	email2FASender  string        // Sender email for 2FA emails
-	email2FATimeout time.Duration // Timeout for waiting for 2FA email
+	parent        context.Context // cancelled on shutdown: closes Chrome and aborts logins
+	ctx           context.Context
+	cancel        context.CancelFunc
+	allocCancel   context.CancelFunc
+	uscisUsername string
+	uscisPassword string
+	codes         CodeProvider  // Optional: automated 2FA; nil = prompt on stdin
+	fetchTimeout  time.Duration // Deadline for a single API navigation (0 = none)
+	sessions      SessionStore  // Optional: reuse session cookies across restarts
+	gate          LoginGate     // Optional: may hold automatic session refreshes
+
+	// Fetches share the browser (read lock); logins and recycles replace its session (write lock)
+	mu         sync.RWMutex
+	sessionGen uint64        // incremented by every refresh, so concurrent fetches refresh once
+	tabs       chan struct{} // limits parallel fetches, each in its own tab; nil = main tab only
+
+	refreshes refreshBackoff // failed refreshes, so later fetches don't each log in again (guarded by mu)
 }
 
 // NewBrowserClient creates a new browser client and performs login with 2FA support
@@ -47,10 +69,58 @@ func NewBrowserClient(uscisUsername, uscisPassword string) (*BrowserClient, erro
 // NewBrowserClientWithEmail creates a new browser client with automated email 2FA support
 // If emailClient is nil, falls back to manual stdin prompt for 2FA
 func NewBrowserClientWithEmail(uscisUsername, uscisPassword string, emailClient EmailFetcher, email2FASender string, email2FATimeout time.Duration) (*BrowserClient, error) {
+	return NewBrowserClientWithSession(context.Background(), uscisUsername, uscisPassword, emailClient, email2FASender, email2FATimeout, nil)
+}
+
+// NewBrowserClientWithSession creates a browser client that first tries to reuse the
+// session cookies saved in sessions, and only does a full login (with 2FA) if they are
+// missing or expired. Cookies are saved again after every login
+// If sessions is nil, behaves like NewBrowserClientWithEmail
+// Cancelling ctx aborts in-flight navigations and 2FA waits and shuts Chrome down
+func NewBrowserClientWithSession(ctx context.Context, uscisUsername, uscisPassword string, emailClient EmailFetcher, email2FASender string, email2FATimeout time.Duration, sessions SessionStore) (*BrowserClient, error) {
+	var codes CodeProvider
+	if emailClient != nil && email2FASender != "" {
+		codes = NewEmailCodes(emailClient, email2FASender, email2FATimeout)
+	}
+	return NewBrowserClientWithCodes(ctx, uscisUsername, uscisPassword, codes, sessions)
+}
+
+// NewBrowserClientWithCodes is NewBrowserClientWithSession with any source of 2FA codes
+// If codes is nil, or it fails, the code is asked for on stdin
+func NewBrowserClientWithCodes(ctx context.Context, uscisUsername, uscisPassword string, codes CodeProvider, sessions SessionStore) (*BrowserClient, error) {
 	log.Printf("Creating browser client...")
 
-	// Create context without timeout - we want to keep it alive
-	ctx := context.Background()
+	client := &BrowserClient{
+		parent:        ctx,
+		uscisUsername: uscisUsername,
+		uscisPassword: uscisPassword,
+		codes:         codes,
+		sessions:      sessions,
+	}
+
+	client.startBrowser()
+
+	// Reuse the saved session or perform login
+	if err := client.authenticate(); err != nil {
+		client.Close()
+		if IsLoginBlocked(err) {
+			// Kept apart: the remedy isn't checking the credentials
+			return nil, err
+		}
+		// Wrap login failure in ErrAuthenticationFailed for consistent error handling
+		return nil, &ErrAuthenticationFailed{StatusCode: 0, Captures: LoginCaptures(err), Err: err} // 0 indicates browser login failure (not HTTP status)
+	}
+
+	return client, nil
+}
+
+// startBrowser launches a fresh headless Chrome instance and browser context
+// With SetRemoteChrome it connects to the external Chrome instead
+func (bc *BrowserClient) startBrowser() {
+	if devtoolsURL := remoteChromeURL(); devtoolsURL != "" {
+		bc.connectRemoteBrowser(devtoolsURL)
+		return
+	}
 
 	// Configure headless browser with bot detection evasion
 	log.Printf("Configuring Chrome options...")
@@ -64,42 +134,220 @@ func NewBrowserClientWithEmail(uscisUsername, uscisPassword string, emailClient
 	)
 
 	log.Printf("Creating Chrome allocator context...")
-	allocCtx, allocCancel := chromedp.NewExecAllocator(ctx, opts...)
+	// No timeout - the browser stays alive until Close or shutdown
+	allocCtx, allocCancel := chromedp.NewExecAllocator(bc.parent, opts...)
 
 	log.Printf("Creating browser context...")
 	browserCtx, cancel := chromedp.NewContext(allocCtx, chromedp.WithLogf(log.Printf))
 
-	client := &BrowserClient{
-		ctx:             browserCtx,
-		cancel:          cancel,
-		allocCancel:     allocCancel,
-		uscisUsername:   uscisUsername,
-		uscisPassword:   uscisPassword,
-		emailClient:     emailClient,
-		email2FASender:  email2FASender,
-		email2FATimeout: email2FATimeout,
-	}
-
-	// Perform login
-	if err := client.login(); err != nil {
-		client.Close()
-		// Wrap login failure in ErrAuthenticationFailed for consistent error handling
-		return nil, &ErrAuthenticationFailed{StatusCode: 0} // 0 indicates browser login failure (not HTTP status)
+	bc.ctx = browserCtx
+	bc.cancel = cancel
+	bc.allocCancel = allocCancel
+}
+
+// connectRemoteBrowser connects to an external Chrome over the DevTools protocol
+// The session gets a browser context of its own, so its cookies stay apart from
+// anything else the shared Chrome runs; Close disposes of it but leaves Chrome running
+func (bc *BrowserClient) connectRemoteBrowser(devtoolsURL string) {
+	label := RemoteChromeLabel(devtoolsURL)
+	log.Printf("Connecting to remote Chrome at %s...", label)
+	var opts []chromedp.RemoteAllocatorOption
+	if useURLAsIs(devtoolsURL) {
+		opts = append(opts, chromedp.NoModifyURL)
+	}
+	allocCtx, allocCancel := chromedp.NewRemoteAllocator(bc.parent, devtoolsURL, opts...)
+	connCtx, connCancel := chromedp.NewContext(allocCtx, chromedp.WithLogf(log.Printf))
+	bc.allocCancel = allocCancel
+
+	// Connect right away: a browser context can only be created on a live connection
+	if err := chromedp.Run(connCtx); err != nil {
+		// The login reports the failure when it navigates
+		log.Printf("Failed to connect to remote Chrome at %s: %v", label, err)
+		bc.ctx = connCtx
+		bc.cancel = connCancel
+		return
+	}
+	sessionCtx, sessionCancel := chromedp.NewContext(connCtx, chromedp.WithNewBrowserContext())
+	bc.ctx = sessionCtx
+	bc.cancel = func() {
+		sessionCancel()
+		connCancel()
 	}
+}
 
-	return client, nil
+// SetFetchTimeout bounds how long a single API navigation may take
+// A zero timeout disables the deadline
+func (bc *BrowserClient) SetFetchTimeout(timeout time.Duration) {
+	bc.fetchTimeout = timeout
+}
+
+// SetParallelFetches allows up to n fetches at once, each in its own tab of the
+// logged-in browser. The tabs share the session cookies
+// With n <= 1 every fetch navigates the main tab, one at a time
+func (bc *BrowserClient) SetParallelFetches(n int) {
+	if n <= 1 {
+		bc.tabs = nil
+		return
+	}
+	bc.tabs = make(chan struct{}, n)
+}
+
+// Recycle tears down the current Chrome instance and logs in again with a fresh one
+// Used by the poll watchdog when navigations keep hanging
+func (bc *BrowserClient) Recycle() error {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	if err := bc.refreshes.check(time.Now()); IsAccountLocked(err) {
+		// The new browser would have to log in
+		return fmt.Errorf("failed to recycle the browser: %w", err)
+	}
+	bc.sessionGen++
+	log.Printf("Recycling browser: closing current Chrome instance...")
+	bc.Close()
+
+	bc.startBrowser()
+	if err := bc.authenticate(); err != nil {
+		return fmt.Errorf("failed to log in after browser recycle: %w", err)
+	}
+
+	log.Printf("Browser recycled successfully")
+	return nil
+}
+
+// authenticate restores the saved session if it is still valid, otherwise logs in
+func (bc *BrowserClient) authenticate() error {
+	if bc.restoreSession() {
+		return nil
+	}
+	return bc.login()
+}
+
+// restoreSession loads the saved cookies into the browser and reports whether they
+// still give access to the account
+func (bc *BrowserClient) restoreSession() bool {
+	if bc.sessions == nil {
+		return false
+	}
+	data, err := bc.sessions.LoadSession(bc.uscisUsername)
+	if err != nil {
+		log.Printf("Warning: Failed to load saved browser session: %v", err)
+		return false
+	}
+	if data == nil {
+		log.Printf("No saved browser session - logging in")
+		return false
+	}
+
+	var cookies []*network.Cookie
+	if err := json.Unmarshal(data, &cookies); err != nil {
+		log.Printf("Warning: Saved browser session is unreadable: %v", err)
+		return false
+	}
+
+	now := time.Now()
+	var params []*network.CookieParam
+	for _, c := range cookies {
+		param := &network.CookieParam{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			Secure:   c.Secure,
+			HTTPOnly: c.HTTPOnly,
+			SameSite: c.SameSite,
+		}
+		if !c.Session {
+			expires := time.Unix(int64(c.Expires), 0)
+			if expires.Before(now) {
+				continue
+			}
+			epoch := cdp.TimeSinceEpoch(expires)
+			param.Expires = &epoch
+		}
+		params = append(params, param)
+	}
+	if len(params) == 0 {
+		log.Printf("Saved browser session has expired - logging in")
+		return false
+	}
+
+	log.Printf("Restoring saved browser session (%d cookies)...", len(params))
+	var currentURL string
+	err = chromedp.Run(bc.ctx,
+		network.SetCookies(params),
+		chromedp.Navigate(applicantURL),
+		chromedp.Sleep(3*time.Second),
+		chromedp.Location(&currentURL),
+	)
+	if err != nil {
+		log.Printf("Warning: Failed to restore browser session: %v", err)
+		return false
+	}
+
+	// An expired session is redirected to the sign-in page
+	if strings.Contains(currentURL, "/sign-in") || !strings.HasPrefix(currentURL, applicantURL) {
+		log.Printf("Saved browser session is no longer valid (redirected to %s) - logging in", currentURL)
+		return false
+	}
+
+	log.Printf("Saved browser session is valid - skipping login and 2FA")
+	return true
+}
+
+// saveSession exports the browser's cookies so the next start can skip login
+func (bc *BrowserClient) saveSession() {
+	if bc.sessions == nil {
+		return
+	}
+
+	var cookies []*network.Cookie
+	err := chromedp.Run(bc.ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		cookies, err = network.GetCookies().WithURLs([]string{applicantURL, loginPageURL}).Do(ctx)
+		return err
+	}))
+	if err != nil {
+		log.Printf("Warning: Failed to export browser session: %v", err)
+		return
+	}
+
+	data, err := json.Marshal(cookies)
+	if err != nil {
+		log.Printf("Warning: Failed to marshal browser session: %v", err)
+		return
+	}
+	if err := bc.sessions.SaveSession(bc.uscisUsername, data); err != nil {
+		log.Printf("Warning: Failed to save browser session: %v", err)
+		return
+	}
+	log.Printf("Saved browser session (%d cookies) for reuse after restart", len(cookies))
 }
 
 // login performs the authentication flow with 2FA support
-func (bc *BrowserClient) login() error {
+// A failure is captured (screenshot and DOM) while the page is still up
+func (bc *BrowserClient) login() (err error) {
+	// Serialize with other sessions so logins are spaced out
+	release, err := logins.acquire(bc.parent, bc.uscisUsername)
+	if err != nil {
+		return err
+	}
+	defer release()
+	defer func() {
+		if err != nil {
+			err = bc.withCaptures(bc.classifyLoginFailure(err))
+		}
+	}()
+	// The 2FA email of this login arrives after this
+	started := time.Now()
+
 	log.Printf("Starting login automation...")
-	log.Printf("Username: %s", bc.uscisUsername)
-	log.Printf("Password: %s (length: %d)", strings.Repeat("*", len(bc.uscisPassword)), len(bc.uscisPassword))
+	log.Printf("Username: %s", redactUsername(bc.uscisUsername))
 	var currentURL string
 
 	// Perform login and wait for AWS WAF challenges
 	log.Printf("Navigating to login page: %s", loginPageURL)
-	err := chromedp.Run(bc.ctx,
+	err = chromedp.Run(bc.ctx,
 		chromedp.Navigate(loginPageURL),
 		chromedp.WaitVisible(`#email-address`, chromedp.ByQuery),
 	)
@@ -161,7 +409,7 @@ func (bc *BrowserClient) login() error {
 	// Handle 2FA if required
 	if strings.Contains(currentURL, "/auth") {
 		log.Printf("2FA required - URL contains /auth")
-		if err := bc.handle2FA(); err != nil {
+		if err := bc.handle2FA(started); err != nil {
 			return err
 		}
 		log.Printf("2FA verification completed successfully")
@@ -180,45 +428,44 @@ func (bc *BrowserClient) login() error {
 	}
 
 	log.Printf("Login completed successfully, browser session ready for API calls")
+	bc.saveSession()
 	return nil
 }
 
-// handle2FA handles the 2FA flow by fetching code from email or prompting user
-func (bc *BrowserClient) handle2FA() error {
+// handle2FA handles the 2FA flow with the code provider, or by prompting the user
+// Only emails received after the login started are read
+func (bc *BrowserClient) handle2FA(started time.Time) error {
 	log.Printf("2FA verification required")
 
 	var code string
 	var err error
 
-	// Try automated email fetch if configured
-	if bc.emailClient != nil && bc.email2FASender != "" {
-		log.Printf("Attempting automated 2FA code fetch from email...")
-		log.Printf("  Email sender: %s", bc.email2FASender)
-		log.Printf("  Timeout: %v", bc.email2FATimeout)
-		log.Printf("Waiting for 2FA email (this may take up to %v)...", bc.email2FATimeout)
-
-		code, err = bc.emailClient.FetchLatest2FACode(bc.email2FASender, bc.email2FATimeout)
+	// Try the automated code provider if configured
+	if bc.codes != nil {
+		log.Printf("Attempting automated 2FA code fetch from %s...", bc.codes)
+		code, err = bc.codes.Code(bc.parent, started)
+		if bc.parent.Err() != nil {
+			// Shutting down: nobody is there to type the code
+			return fmt.Errorf("2FA aborted: %w", bc.parent.Err())
+		}
 		if err != nil {
-			log.Printf("Failed to fetch 2FA code from email: %v", err)
+			log.Printf("Failed to get 2FA code from %s: %v", bc.codes, err)
 			log.Printf("Falling back to manual input...")
+			code = ""
 		} else {
-			log.Printf("Successfully retrieved 2FA code from email")
+			log.Printf("Successfully retrieved 2FA code from %s", bc.codes)
 		}
 	} else {
-		log.Printf("Automated email fetch not configured")
+		log.Printf("Automated 2FA not configured")
 	}
 
-	// Fall back to manual input if email fetch failed or not configured
+	// Fall back to manual input if the provider failed or is not configured
 	if code == "" {
-		log.Printf("Please check your email for the verification code")
-		fmt.Print("Enter 2FA verification code: ")
-		reader := bufio.NewReader(os.Stdin)
-		code, err = reader.ReadString('\n')
-		if err != nil {
-			return fmt.Errorf("failed to read verification code: %w", err)
+		if code, err = (stdinCodes{}).Code(bc.parent, started); err != nil {
+			return err
 		}
-		code = strings.TrimSpace(code)
 	}
+	logging.AddSecret(code)
 
 	log.Printf("Submitting verification code...")
 	var currentURL string
@@ -255,16 +502,65 @@ func (bc *BrowserClient) handle2FA() error {
 	return nil
 }
 
+// SetLoginGate makes automatic session refreshes ask gate first
+func (bc *BrowserClient) SetLoginGate(gate LoginGate) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	bc.gate = gate
+}
+
 // RefreshSession re-authenticates by running the login flow again
 // Useful when the browser session expires during long-running polling
 func (bc *BrowserClient) RefreshSession() error {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	bc.sessionGen++
 	log.Printf("Refreshing browser session...")
-	return bc.login()
+	err := bc.login()
+	bc.refreshes.record(err, time.Now())
+	return err
+}
+
+// refreshSessionAfter refreshes the session unless another fetch already did so
+// since gen was observed
+// It is the automatic refresh, so the login gate is asked first
+func (bc *BrowserClient) refreshSessionAfter(gen uint64) error {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	if bc.sessionGen != gen {
+		log.Printf("Browser session was already refreshed by another fetch")
+		return nil
+	}
+	if err := bc.refreshes.check(time.Now()); err != nil {
+		return err
+	}
+	if bc.gate != nil {
+		if err := bc.gate(); err != nil {
+			return err
+		}
+	}
+	log.Printf("Refreshing browser session...")
+	err := bc.login()
+	bc.refreshes.record(err, time.Now())
+	if err != nil {
+		return err
+	}
+	bc.sessionGen++
+	return nil
 }
 
 // FetchCaseStatus fetches case status by navigating to the API URL in the browser
 // Automatically retries once with session refresh if the response indicates auth failure
 func (bc *BrowserClient) FetchCaseStatus(caseID string) (map[string]interface{}, error) {
+	if _, err := ValidateReceiptNumber(caseID); err != nil {
+		return nil, err
+	}
+	bc.mu.RLock()
+	gen := bc.sessionGen
+	bc.mu.RUnlock()
+
 	result, err := bc.fetchCaseStatusInternal(caseID)
 
 	// Check if response indicates authentication failure
@@ -280,10 +576,15 @@ func (bc *BrowserClient) FetchCaseStatus(caseID string) (map[string]interface{},
 	if shouldRefresh {
 		log.Printf("Possible session expiration detected (null data), attempting to refresh...")
 
-		if refreshErr := bc.RefreshSession(); refreshErr != nil {
+		if refreshErr := bc.refreshSessionAfter(gen); refreshErr != nil {
+			var held *ErrLoginHeld
+			var backoff *ErrRefreshBackoff
+			if errors.As(refreshErr, &held) || errors.As(refreshErr, &backoff) || IsLoginBlocked(refreshErr) {
+				return nil, refreshErr
+			}
 			log.Printf("Failed to refresh session: %v", refreshErr)
 			// Return ErrAuthenticationFailed for consistent error handling
-			return nil, &ErrAuthenticationFailed{StatusCode: 0} // 0 indicates session refresh failure
+			return nil, &ErrAuthenticationFailed{StatusCode: 0, Captures: LoginCaptures(refreshErr), Err: refreshErr} // 0 indicates session refresh failure
 		}
 
 		log.Printf("Session refreshed, retrying request...")
@@ -293,24 +594,25 @@ func (bc *BrowserClient) FetchCaseStatus(caseID string) (map[string]interface{},
 	return result, err
 }
 
+// FetchCase fetches the current status of a case as a typed CaseStatus
+func (bc *BrowserClient) FetchCase(caseID string) (*CaseStatus, error) {
+	payload, err := bc.FetchCaseStatus(caseID)
+	if err != nil {
+		return nil, err
+	}
+	return NewCaseStatus(payload), nil
+}
+
 // fetchCaseStatusInternal performs the actual API call via browser navigation
 func (bc *BrowserClient) fetchCaseStatusInternal(caseID string) (map[string]interface{}, error) {
 	url := fmt.Sprintf("%s/%s", caseAPIURL, caseID)
-	log.Printf("Navigating to API URL: %s", url)
 
-	var apiResponse string
-	err := chromedp.Run(bc.ctx,
-		chromedp.Navigate(url),
-		chromedp.Sleep(2*time.Second), // Wait for API response
-		chromedp.ActionFunc(func(ctx context.Context) error {
-			// Extract the JSON from the <pre> tag
-			return chromedp.Text("pre", &apiResponse, chromedp.ByQuery).Do(ctx)
-		}),
-	)
+	tabCtx, release := bc.acquireTab()
+	defer release()
 
+	apiResponse, err := bc.readAPIPage(tabCtx, url, caseID)
 	if err != nil {
-		log.Printf("Failed to navigate to API URL: %v", err)
-		return nil, fmt.Errorf("failed to navigate to API URL: %w", err)
+		return nil, err
 	}
 
 	log.Printf("API response received (length: %d bytes)", len(apiResponse))
@@ -320,11 +622,14 @@ func (bc *BrowserClient) fetchCaseStatusInternal(caseID string) (map[string]inte
 		log.Printf("API response: %s", apiResponse)
 	}
 
-	// Parse JSON response
-	var result map[string]interface{}
-	if err := json.Unmarshal([]byte(apiResponse), &result); err != nil {
+	// Parse JSON response, unwrapping whatever the page put around it
+	result, err := parseBrowserBody(apiResponse)
+	if err != nil {
 		log.Printf("Failed to parse API response as JSON: %v", err)
-		return nil, fmt.Errorf("failed to parse API response: %w", err)
+		result, err = bc.refetchViaNetwork(tabCtx, url, caseID, err.(*ParseError))
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// Check if data field is null
@@ -339,6 +644,157 @@ func (bc *BrowserClient) fetchCaseStatusInternal(caseID string) (map[string]inte
 	return result, nil
 }
 
+// FetchCaseHistory fetches the entries of every history endpoint of a case
+// The browser doesn't see status codes, so an endpoint that serves no list (USCIS
+// doesn't offer it for the case) contributes nothing
+func (bc *BrowserClient) FetchCaseHistory(caseID string) ([]interface{}, error) {
+	tabCtx, release := bc.acquireTab()
+	defer release()
+
+	var entries []interface{}
+	for _, endpoint := range HistoryEndpoints {
+		body, err := bc.readAPIPage(tabCtx, historyURL(caseID, endpoint), caseID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch case %s: %w", endpoint, err)
+		}
+		items, err := ParseHistoryResponse([]byte(strings.TrimSpace(body)))
+		if err != nil {
+			log.Printf("[%s] No case %s available: %v", caseID, endpoint, err)
+			continue
+		}
+		entries = append(entries, items...)
+	}
+	return entries, nil
+}
+
+// FetchDocument downloads a notice PDF with the browser's session cookies
+// Chrome would show the PDF in its viewer rather than hand over the bytes, so the
+// download is a plain HTTP request carrying the same cookies
+func (bc *BrowserClient) FetchDocument(doc Document) ([]byte, error) {
+	cookie, err := bc.CookieHeader()
+	if err != nil {
+		return nil, err
+	}
+	httpClient := &http.Client{Timeout: bc.fetchTimeout}
+	return downloadDocument(httpClient, doc, cookie)
+}
+
+// acquireTab takes the browser for a fetch and returns the context to navigate in
+// Parallel fetches each get a tab of their own; closing it leaves the session intact.
+// release must be called when the fetch is done
+func (bc *BrowserClient) acquireTab() (context.Context, func()) {
+	if bc.tabs != nil {
+		bc.tabs <- struct{}{}
+	}
+	bc.mu.RLock()
+
+	tabCtx := bc.ctx
+	closeTab := func() {}
+	if bc.tabs != nil {
+		tabCtx, closeTab = chromedp.NewContext(bc.ctx)
+	}
+	return tabCtx, func() {
+		closeTab()
+		bc.mu.RUnlock()
+		if bc.tabs != nil {
+			<-bc.tabs
+		}
+	}
+}
+
+// readAPIPage navigates to an account API URL and returns the text the page shows
+func (bc *BrowserClient) readAPIPage(tabCtx context.Context, url, caseID string) (string, error) {
+	log.Printf("Navigating to API URL: %s", url)
+
+	// Bound the navigation so a hung page cannot stall the poll cycle
+	fetchCtx := tabCtx
+	if bc.fetchTimeout > 0 {
+		var cancel context.CancelFunc
+		fetchCtx, cancel = context.WithTimeout(tabCtx, bc.fetchTimeout)
+		defer cancel()
+	}
+
+	var body string
+	err := chromedp.Run(fetchCtx,
+		chromedp.Navigate(url),
+		chromedp.Sleep(2*time.Second), // Wait for API response
+		// Take the JSON from the viewer's <pre>, or the whole page if something else was served
+		chromedp.Evaluate(readPageBody, &body),
+	)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Printf("API navigation exceeded %v deadline", bc.fetchTimeout)
+			return "", &ErrFetchTimeout{CaseID: caseID, Timeout: bc.fetchTimeout}
+		}
+		log.Printf("Failed to navigate to API URL: %v", err)
+		return "", fmt.Errorf("failed to navigate to API URL: %w", err)
+	}
+	return body, nil
+}
+
+// CookieHeader returns the session cookies of the logged-in browser as a Cookie
+// header for the account API, e.g. to hand a fresh session to the HTTP client
+func (bc *BrowserClient) CookieHeader() (string, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	var cookies []*network.Cookie
+	err := chromedp.Run(bc.ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		cookies, err = network.GetCookies().WithURLs([]string{caseAPIURL}).Do(ctx)
+		return err
+	}))
+	if err != nil {
+		return "", fmt.Errorf("failed to export browser cookies: %w", err)
+	}
+	if len(cookies) == 0 {
+		return "", errors.New("browser has no session cookies for the account API")
+	}
+
+	pairs := make([]string, 0, len(cookies))
+	for _, c := range cookies {
+		pairs = append(pairs, c.Name+"="+c.Value)
+	}
+	header := strings.Join(pairs, "; ")
+	logging.AddSecret(header)
+	return header, nil
+}
+
+// refetchViaNetwork retries a fetch whose scraped page couldn't be parsed by reading
+// the response from the network layer. If that fails too, the returned ParseError
+// carries both bodies so the dead letter shows what the browser actually got
+func (bc *BrowserClient) refetchViaNetwork(tabCtx context.Context, url, caseID string, pageErr *ParseError) (map[string]interface{}, error) {
+	log.Printf("Retrying via network interception...")
+
+	fetchCtx := tabCtx
+	if bc.fetchTimeout > 0 {
+		var cancel context.CancelFunc
+		fetchCtx, cancel = context.WithTimeout(tabCtx, bc.fetchTimeout)
+		defer cancel()
+	}
+
+	body, err := fetchViaNetwork(fetchCtx, url)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, &ErrFetchTimeout{CaseID: caseID, Timeout: bc.fetchTimeout}
+		}
+		log.Printf("Network interception failed: %v", err)
+		return nil, pageErr
+	}
+
+	result, err := parseBrowserBody(body)
+	if err != nil {
+		log.Printf("Network response is not case JSON either: %v", err)
+		return nil, &ParseError{
+			Body: pageErr.Body + "\n\n--- response body from network interception ---\n" + body,
+			Err:  fmt.Errorf("%v (network response: %v)", pageErr.Err, err.(*ParseError).Err),
+		}
+	}
+
+	log.Printf("Network interception returned valid JSON")
+	return result, nil
+}
+
 // Close cleans up the browser resources
 func (bc *BrowserClient) Close() error {
 	if bc.cancel != nil {
@@ -0,0 +1,175 @@
+package uscis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// maxJSONCandidates bounds how many '{' positions are tried when looking for the
+// case object inside a wrapped body
+const maxJSONCandidates = 64
+
+// readPageBody returns the text of the <pre> the browser's JSON viewer renders,
+// or the whole document when there is none (a WAF page or a different viewer)
+const readPageBody = `(() => {
+	const pre = document.querySelector('pre');
+	return pre ? pre.textContent : document.documentElement.outerHTML;
+})()`
+
+// xssiPrefixes are anti-hijacking prefixes some proxies put in front of JSON
+var xssiPrefixes = []string{")]}',", ")]}'", "while(1);", "for(;;);"}
+
+var (
+	// preContentPattern captures the content of the first <pre> element
+	preContentPattern = regexp.MustCompile(`(?is)<pre\b[^>]*>(.*?)</pre>`)
+	// scriptPattern matches script and style elements injected into a page
+	scriptPattern = regexp.MustCompile(`(?is)<(script|style)\b.*?</(script|style)>`)
+)
+
+// ErrHTMLResponse is the ParseError cause for a page that carries no JSON at all
+var ErrHTMLResponse = errors.New("response is an HTML page, not JSON (possibly a WAF challenge)")
+
+// parseBrowserBody decodes a case response scraped from the browser
+// It tolerates what the page may wrap around the JSON: the viewer's HTML,
+// escaping, anti-hijacking prefixes, callbacks and injected scripts
+// Returns a *ParseError carrying the raw body on failure
+func parseBrowserBody(body string) (map[string]interface{}, error) {
+	result, err := ParseCaseResponse([]byte(body))
+	if err == nil {
+		return result, nil
+	}
+
+	normalized, ok := normalizeJSONBody(body)
+	if !ok {
+		if looksLikeHTML(body) {
+			return nil, &ParseError{Body: body, Err: ErrHTMLResponse}
+		}
+		return nil, err
+	}
+	result, err = ParseCaseResponse([]byte(normalized))
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		// Keep the body as received for the dead letter
+		parseErr.Body = body
+	}
+	return result, err
+}
+
+// normalizeJSONBody strips what surrounds the case object in a scraped body
+// Reports false when no JSON object could be found
+func normalizeJSONBody(body string) (string, bool) {
+	s := strings.TrimSpace(strings.TrimPrefix(body, "\ufeff"))
+
+	if looksLikeHTML(s) {
+		if m := preContentPattern.FindStringSubmatch(s); m != nil {
+			s = m[1]
+		} else {
+			s = scriptPattern.ReplaceAllString(s, "")
+		}
+		s = strings.TrimSpace(html.UnescapeString(s))
+	}
+
+	for _, prefix := range xssiPrefixes {
+		s = strings.TrimSpace(strings.TrimPrefix(s, prefix))
+	}
+
+	// JSON delivered as a string literal: "{\"data\": ...}"
+	if strings.HasPrefix(s, `"`) {
+		var inner string
+		if json.Unmarshal([]byte(s), &inner) == nil {
+			s = strings.TrimSpace(inner)
+		}
+	}
+
+	if json.Valid([]byte(s)) && strings.HasPrefix(s, "{") {
+		return s, true
+	}
+	return firstJSONObject(s)
+}
+
+// firstJSONObject finds the first non-empty JSON object embedded in text,
+// e.g. inside a JSONP callback or after an injected script
+func firstJSONObject(s string) (string, bool) {
+	offset := 0
+	for tries := 0; tries < maxJSONCandidates; tries++ {
+		i := strings.IndexByte(s[offset:], '{')
+		if i < 0 {
+			return "", false
+		}
+		start := offset + i
+
+		var obj map[string]json.RawMessage
+		dec := json.NewDecoder(strings.NewReader(s[start:]))
+		if err := dec.Decode(&obj); err == nil && len(obj) > 0 {
+			return s[start : start+int(dec.InputOffset())], true
+		}
+		offset = start + 1
+	}
+	return "", false
+}
+
+// looksLikeHTML reports whether a body is markup (a page or a fragment) rather than JSON
+func looksLikeHTML(body string) bool {
+	return strings.HasPrefix(strings.TrimSpace(body), "<")
+}
+
+// fetchViaNetwork loads url and reads the response body from the browser's network
+// layer instead of the rendered page, so nothing the page does to the text matters
+func fetchViaNetwork(ctx context.Context, url string) (string, error) {
+	listenCtx, stopListening := context.WithCancel(ctx)
+	defer stopListening()
+
+	var (
+		mu        sync.Mutex
+		requestID network.RequestID
+		finished  = make(chan network.RequestID, 1)
+	)
+	chromedp.ListenTarget(listenCtx, func(ev interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch e := ev.(type) {
+		case *network.EventResponseReceived:
+			if e.Response != nil && strings.HasPrefix(e.Response.URL, url) {
+				requestID = e.RequestID
+			}
+		case *network.EventLoadingFinished:
+			if requestID != "" && e.RequestID == requestID {
+				select {
+				case finished <- requestID:
+				default:
+				}
+			}
+		}
+	})
+
+	if err := chromedp.Run(ctx, network.Enable(), chromedp.Navigate(url)); err != nil {
+		return "", fmt.Errorf("failed to load %s: %w", url, err)
+	}
+
+	var id network.RequestID
+	select {
+	case id = <-finished:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+
+	var body []byte
+	err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		body, err = network.GetResponseBody(id).Do(ctx)
+		return err
+	}))
+	if err != nil {
+		return "", fmt.Errorf("failed to read response body: %w", err)
+	}
+	return string(body), nil
+}
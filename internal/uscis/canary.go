@@ -0,0 +1,43 @@
+package uscis
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// CanaryClient serves the synthetic pipeline canary: a case whose status changes every
+// interval, so a change is detected, stored and notified on schedule whether or not
+// USCIS changes anything
+type CanaryClient struct {
+	interval time.Duration
+}
+
+// NewCanaryClient creates the canary source for a change interval
+func NewCanaryClient(interval time.Duration) *CanaryClient {
+	return &CanaryClient{interval: interval}
+}
+
+// Generation numbers the canary status current at a time; it goes up by one every interval
+func (c *CanaryClient) Generation(t time.Time) int64 {
+	return t.UnixNano() / int64(c.interval)
+}
+
+// FetchCaseStatus returns the canary status of the current generation
+// The payload uses the account API's field names, so it goes through the same
+// parsing and change detection as a real case
+func (c *CanaryClient) FetchCaseStatus(caseID string) (map[string]interface{}, error) {
+	generation := c.Generation(time.Now())
+	changedAt := time.Unix(0, generation*int64(c.interval)).UTC()
+	return map[string]interface{}{
+		"data": map[string]interface{}{
+			"receiptNumber":     caseID,
+			"formType":          "CANARY",
+			"statusTitle":       fmt.Sprintf("Canary Check %d", generation),
+			"statusDescription": fmt.Sprintf("Synthetic status of the tracker's pipeline canary. It changes every %s; this notification shows that detection, storage and delivery work.", c.interval),
+			"updatedAt":         changedAt.Format(time.RFC3339),
+			"canaryGeneration":  strconv.FormatInt(generation, 10),
+		},
+		"source": "canary",
+	}, nil
+}
@@ -0,0 +1,78 @@
+package uscis
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// Canonicalize returns a copy of a case payload in canonical form, so the same case
+// data serializes to the same bytes whichever fetch path produced it
+// Values are normalized through JSON (every number becomes a float64, structs become
+// objects) and the history list, whose order differs between endpoints, is ordered
+// oldest first like CaseStatus.Actions. Object keys need no work: encoding/json
+// always writes them sorted
+// Returns nil for a nil payload, and the payload itself if it can't be encoded
+func Canonicalize(payload map[string]interface{}) map[string]interface{} {
+	if payload == nil {
+		return nil
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return payload
+	}
+	var canonical map[string]interface{}
+	if err := json.Unmarshal(data, &canonical); err != nil {
+		return payload
+	}
+
+	fields := caseData(canonical)
+	for _, key := range append([]string{HistoryKey}, actionListKeys...) {
+		if items, ok := fields[key].([]interface{}); ok {
+			sortActionItems(items)
+		}
+	}
+	return canonical
+}
+
+// Fingerprint returns a hash of the canonical form of a payload
+// Payloads with the same fingerprint hold the same case data
+func Fingerprint(payload map[string]interface{}) string {
+	data, _ := json.Marshal(Canonicalize(payload))
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// sortActionItems orders raw history entries by date, oldest first
+// Entries on the same date (or without one) are ordered by their encoding, so the
+// result doesn't depend on the order they arrived in
+func sortActionItems(items []interface{}) {
+	type keyed struct {
+		date    time.Time
+		encoded string
+		item    interface{}
+	}
+	keys := make([]keyed, len(items))
+	for i, item := range items {
+		keys[i].item = item
+		encoded, _ := json.Marshal(item)
+		keys[i].encoded = string(encoded)
+		if entry, ok := item.(map[string]interface{}); ok {
+			if date := firstString(entry, actionDateKeys); date != "" {
+				keys[i].date, _ = ParseDate(date)
+			}
+		}
+	}
+
+	sort.SliceStable(keys, func(i, j int) bool {
+		if !keys[i].date.Equal(keys[j].date) {
+			return keys[i].date.Before(keys[j].date)
+		}
+		return keys[i].encoded < keys[j].encoded
+	})
+	for i := range keys {
+		items[i] = keys[i].item
+	}
+}
@@ -0,0 +1,106 @@
+package uscis
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+)
+
+// caseListKeys are the keys a case list response may keep its cases under
+var caseListKeys = []string{"cases", "caseList", "items", "results"}
+
+// caseListReceiptKeys are the fields of a case list entry that may hold its receipt number
+var caseListReceiptKeys = []string{"receiptNumber", "receipt_number", "receiptNum", "caseId", "caseNumber"}
+
+// ParseCaseListResponse extracts the receipt numbers of a case list response,
+// normalized and in the order listed
+// The list may be the whole body, the data field or a list inside it, of case objects
+// or plain receipt numbers. Entries that aren't receipt numbers are skipped
+// Returns a *ParseError carrying the raw body when the body holds no list, and
+// ErrSessionExpired when the data field is null, as for an expired session
+func ParseCaseListResponse(body []byte) ([]string, error) {
+	var decoded interface{}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		if looksLikeHTML(string(body)) {
+			return nil, &ParseError{Body: string(body), Err: ErrHTMLResponse}
+		}
+		return nil, &ParseError{Body: string(body), Err: fmt.Errorf("invalid case list JSON: %w", err)}
+	}
+
+	items, ok := decoded.([]interface{})
+	if object, isObject := decoded.(map[string]interface{}); isObject {
+		if data, hasData := object["data"]; hasData && data == nil {
+			return nil, fmt.Errorf("case list has no data: %w", ErrSessionExpired)
+		}
+		if items, ok = object["data"].([]interface{}); !ok {
+			data := caseData(object)
+			for _, key := range caseListKeys {
+				if items, ok = data[key].([]interface{}); ok {
+					break
+				}
+			}
+		}
+	}
+	if !ok {
+		return nil, &ParseError{Body: string(body), Err: fmt.Errorf("case list response has no list of cases")}
+	}
+
+	var caseIDs []string
+	for _, item := range items {
+		var raw string
+		switch v := item.(type) {
+		case string:
+			raw = v
+		case map[string]interface{}:
+			for _, key := range caseListReceiptKeys {
+				if s, ok := v[key].(string); ok && s != "" {
+					raw = s
+					break
+				}
+			}
+		}
+		caseID, err := ValidateReceiptNumber(raw)
+		if err != nil {
+			continue
+		}
+		if !slices.Contains(caseIDs, caseID) {
+			caseIDs = append(caseIDs, caseID)
+		}
+	}
+	return caseIDs, nil
+}
+
+// FetchAllCases returns the receipt numbers of every case on the account, from the
+// case list of the account API
+// An expired session is refreshed and the list requested once more, as for a case
+func (bc *BrowserClient) FetchAllCases() ([]string, error) {
+	bc.mu.RLock()
+	gen := bc.sessionGen
+	bc.mu.RUnlock()
+
+	caseIDs, err := bc.fetchCaseList()
+	if !errors.Is(err, ErrSessionExpired) {
+		return caseIDs, err
+	}
+
+	log.Printf("Possible session expiration detected (no case list), attempting to refresh...")
+	if refreshErr := bc.refreshSessionAfter(gen); refreshErr != nil {
+		return nil, fmt.Errorf("failed to refresh session for the case list: %w", refreshErr)
+	}
+	return bc.fetchCaseList()
+}
+
+// fetchCaseList reads the case list page once
+func (bc *BrowserClient) fetchCaseList() ([]string, error) {
+	tabCtx, release := bc.acquireTab()
+	defer release()
+
+	body, err := bc.readAPIPage(tabCtx, caseAPIURL, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the case list: %w", err)
+	}
+	return ParseCaseListResponse([]byte(strings.TrimSpace(body)))
+}
@@ -0,0 +1,258 @@
+package uscis
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CaseStatus is the typed view of a case payload
+// Raw keeps the full payload so fields without a typed counterpart are not lost;
+// it is also what gets marshaled, so a CaseStatus round-trips through storage unchanged
+type CaseStatus struct {
+	ReceiptNumber string
+	FormType      string
+	StatusTitle   string
+	Description   string
+	LastUpdated   time.Time // zero if the payload has no update timestamp
+	Actions       []CaseAction
+	Raw           map[string]interface{}
+}
+
+// CaseAction is one entry of the case history USCIS reports (events, notices...)
+type CaseAction struct {
+	Date        time.Time // zero if the entry has no parsable date
+	Description string
+}
+
+// Payload keys mapped to CaseStatus fields, in order of preference
+// statusTitleKeys and the form keys in FormType are shared with the map helpers
+var (
+	receiptKeys     = []string{"receiptNumber", "receipt_number", "caseId", "caseNumber"}
+	formKeys        = []string{"formType", "formNum", "formName"}
+	descriptionKeys = []string{"statusDescription", "statusText", "actionCodeDesc", "description"}
+	updatedKeys     = []string{"updatedAt", "updatedAtTimestamp", "modifiedAt", "lastUpdated", "statusDate"}
+	actionListKeys  = []string{"events", "actions", "historicalActions"}
+	actionDateKeys  = []string{"eventDateTime", "eventTimestamp", "date", "createdAt", "actionDate"}
+	actionTextKeys  = []string{"eventCode", "description", "title", "text", "actionCodeText"}
+)
+
+// NewCaseStatus builds the typed view of a case payload
+// Returns nil for a nil payload (no saved state)
+func NewCaseStatus(payload map[string]interface{}) *CaseStatus {
+	if payload == nil {
+		return nil
+	}
+
+	data := caseData(payload)
+	status := &CaseStatus{
+		ReceiptNumber: firstString(data, receiptKeys),
+		FormType:      FormType(payload),
+		StatusTitle:   StatusSummary(payload),
+		Description:   firstString(data, descriptionKeys),
+		Raw:           payload,
+	}
+	if updated := firstString(data, updatedKeys); updated != "" {
+		status.LastUpdated, _ = ParseDate(updated)
+	}
+
+	for _, key := range actionListKeys {
+		items, ok := data[key].([]interface{})
+		if !ok {
+			continue
+		}
+		status.Actions = appendActions(status.Actions, items)
+		break
+	}
+	// Entries of the history endpoints often repeat the ones above
+	if items, ok := data[HistoryKey].([]interface{}); ok {
+		status.Actions = appendActions(status.Actions, items)
+	}
+	sort.SliceStable(status.Actions, func(i, j int) bool {
+		return status.Actions[i].Date.Before(status.Actions[j].Date)
+	})
+
+	return status
+}
+
+// appendActions appends the history entries in items that aren't in actions yet
+func appendActions(actions []CaseAction, items []interface{}) []CaseAction {
+	seen := make(map[CaseAction]bool, len(actions))
+	for _, action := range actions {
+		seen[action] = true
+	}
+	for _, item := range items {
+		action, ok := actionOf(item)
+		if ok && !seen[action] {
+			seen[action] = true
+			actions = append(actions, action)
+		}
+	}
+	return actions
+}
+
+// actionOf returns the history entry a raw list item describes, if it describes one
+func actionOf(item interface{}) (CaseAction, bool) {
+	entry, ok := item.(map[string]interface{})
+	if !ok {
+		return CaseAction{}, false
+	}
+	action := CaseAction{Description: firstString(entry, actionTextKeys)}
+	if date := firstString(entry, actionDateKeys); date != "" {
+		action.Date, _ = ParseDate(date)
+	}
+	return action, action.Description != "" || !action.Date.IsZero()
+}
+
+// ParseCaseStatus decodes a case API response body into a CaseStatus
+// Returns a *ParseError carrying the raw body on failure
+func ParseCaseStatus(body []byte) (*CaseStatus, error) {
+	payload, err := ParseCaseResponse(body)
+	if err != nil {
+		return nil, err
+	}
+	return NewCaseStatus(payload), nil
+}
+
+// UnmarshalJSON decodes a case payload
+func (s *CaseStatus) UnmarshalJSON(body []byte) error {
+	status, err := ParseCaseStatus(body)
+	if err != nil {
+		return err
+	}
+	*s = *status
+	return nil
+}
+
+// MarshalJSON encodes the raw payload
+func (s CaseStatus) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.Raw)
+}
+
+// String returns the status line, or "unknown" when the payload has none
+func (s *CaseStatus) String() string {
+	if s == nil || s.StatusTitle == "" {
+		return "unknown"
+	}
+	return s.StatusTitle
+}
+
+// Field returns the value of one field of the case, and whether the payload has it
+// name is a typed field's display name ("Status", "Last Updated"...), matched
+// case-insensitively, or a dotted path into the case data as reported by the change
+// detector ("actionCodeText", "meta.etag", "events[0].eventCode")
+func (s *CaseStatus) Field(name string) (interface{}, bool) {
+	if s == nil {
+		return nil, false
+	}
+	var typed string
+	switch strings.ToLower(name) {
+	case "status":
+		typed = s.StatusTitle
+	case "description":
+		typed = s.Description
+	case "form":
+		typed = s.FormType
+	case "receipt number":
+		typed = s.ReceiptNumber
+	case "last updated":
+		typed = formatStatusDate(s.LastUpdated)
+	default:
+		if value, ok := valueAtPath(caseData(s.Raw), name); ok {
+			return value, true
+		}
+		// Paths from the top of the payload ("data.actionCodeText") work too
+		return valueAtPath(s.Raw, name)
+	}
+	return typed, typed != ""
+}
+
+// valueAtPath follows a dotted path with [i] indexes ("events[0].eventCode") into a payload
+func valueAtPath(value interface{}, path string) (interface{}, bool) {
+	for _, part := range strings.Split(path, ".") {
+		key, rest, _ := strings.Cut(part, "[")
+		if key != "" {
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if value, ok = object[key]; !ok {
+				return nil, false
+			}
+		}
+		for rest != "" {
+			index, after, ok := strings.Cut(rest, "]")
+			if !ok {
+				return nil, false
+			}
+			i, err := strconv.Atoi(index)
+			items, isList := value.([]interface{})
+			if err != nil || !isList || i < 0 || i >= len(items) {
+				return nil, false
+			}
+			value = items[i]
+			rest = strings.TrimPrefix(after, "[")
+		}
+	}
+	return value, value != nil
+}
+
+// mappedKeys returns the payload keys the typed fields are read from in any of payloads
+// Only the key actually backing a field counts: the other aliases of it are ordinary
+// fields, whose changes are reported by path
+func mappedKeys(payloads ...map[string]interface{}) map[string]bool {
+	mapped := map[string]bool{HistoryKey: true}
+	for _, data := range payloads {
+		for _, keys := range [][]string{receiptKeys, formKeys, statusTitleKeys, descriptionKeys, updatedKeys} {
+			if key := backingKey(data, keys); key != "" {
+				mapped[key] = true
+			}
+		}
+		// NewCaseStatus reads the first action list only
+		for _, key := range actionListKeys {
+			if _, ok := data[key].([]interface{}); ok {
+				mapped[key] = true
+				break
+			}
+		}
+	}
+	return mapped
+}
+
+// backingKey returns the first key among keys with a non-empty value, or ""
+func backingKey(data map[string]interface{}, keys []string) string {
+	for _, key := range keys {
+		switch v := data[key].(type) {
+		case nil:
+			continue
+		case string:
+			if strings.TrimSpace(v) == "" {
+				continue
+			}
+		}
+		return key
+	}
+	return ""
+}
+
+// firstString returns the first non-empty value among keys, formatted as a string
+func firstString(data map[string]interface{}, keys []string) string {
+	for _, key := range keys {
+		switch v := data[key].(type) {
+		case nil:
+			continue
+		case string:
+			if v = strings.TrimSpace(v); v != "" {
+				return v
+			}
+		case map[string]interface{}:
+			continue
+		default:
+			return fmt.Sprint(v)
+		}
+	}
+	return ""
+}
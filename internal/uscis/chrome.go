@@ -0,0 +1,115 @@
+package uscis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// remoteChrome is the external Chrome browser clients connect to ("" = start a local one)
+var remoteChrome struct {
+	mu  sync.Mutex
+	url string
+}
+
+// SetRemoteChrome makes browser clients connect to the Chrome at a DevTools URL instead of
+// starting a headless Chrome of their own. An empty URL goes back to local Chrome
+func SetRemoteChrome(devtoolsURL string) {
+	remoteChrome.mu.Lock()
+	defer remoteChrome.mu.Unlock()
+	remoteChrome.url = devtoolsURL
+}
+
+// remoteChromeURL returns the DevTools URL set by SetRemoteChrome, or ""
+func remoteChromeURL() string {
+	remoteChrome.mu.Lock()
+	defer remoteChrome.mu.Unlock()
+	return remoteChrome.url
+}
+
+// RemoteChromeLabel names a remote Chrome for logs: the scheme and host only, since
+// hosted services take their API token in the query
+func RemoteChromeLabel(devtoolsURL string) string {
+	u, err := url.Parse(devtoolsURL)
+	if err != nil {
+		return "(invalid URL)"
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// useURLAsIs reports whether a remote Chrome URL is a websocket endpoint to connect to
+// directly. Hosted services (browserless, Steel) hand out such URLs with a path or token;
+// a bare ws://host:port is resolved through /json/version like an http:// URL
+func useURLAsIs(devtoolsURL string) bool {
+	u, err := url.Parse(devtoolsURL)
+	if err != nil || (u.Scheme != "ws" && u.Scheme != "wss") {
+		return false
+	}
+	return strings.Trim(u.Path, "/") != "" || u.RawQuery != ""
+}
+
+// chromeCandidates are the executables chromedp looks for, in the same order
+var chromeCandidates = map[string][]string{
+	"darwin": {
+		"/Applications/Chromium.app/Contents/MacOS/Chromium",
+		"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome",
+	},
+	"windows": {
+		"chrome",
+		"chrome.exe",
+		`C:\Program Files (x86)\Google\Chrome\Application\chrome.exe`,
+		`C:\Program Files\Google\Chrome\Application\chrome.exe`,
+	},
+	"linux": {
+		"headless_shell",
+		"headless-shell",
+		"chromium",
+		"chromium-browser",
+		"google-chrome",
+		"google-chrome-stable",
+		"google-chrome-beta",
+		"google-chrome-unstable",
+		"/usr/bin/google-chrome",
+		"/usr/local/bin/chrome",
+		"/snap/bin/chromium",
+		"chrome",
+	},
+}
+
+// FindChrome locates a Chrome executable that can run on this machine
+// A binary built for another architecture (e.g. an amd64 image on an arm64 NAS)
+// is found but fails to start, so each candidate is run once with --version
+func FindChrome() (path, version string, err error) {
+	candidates := chromeCandidates[runtime.GOOS]
+	if candidates == nil {
+		candidates = chromeCandidates["linux"]
+	}
+
+	var failures []string
+	for _, name := range candidates {
+		found, err := exec.LookPath(name)
+		if err != nil {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		out, err := exec.CommandContext(ctx, found, "--version").CombinedOutput()
+		cancel()
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", found, err))
+			continue
+		}
+		return found, strings.TrimSpace(string(out)), nil
+	}
+
+	if len(failures) > 0 {
+		return "", "", fmt.Errorf("no working Chrome on %s/%s (%s)", runtime.GOOS, runtime.GOARCH, strings.Join(failures, "; "))
+	}
+	return "", "", errors.New("no Chrome or Chromium executable found on " + runtime.GOOS + "/" + runtime.GOARCH)
+}
@@ -0,0 +1,123 @@
+package uscis
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Severity ranks how much a change matters to the applicant
+type Severity int
+
+const (
+	SeverityInfo      Severity = iota // fields changed, the status didn't
+	SeverityNotice                    // routine progress, e.g. a transfer or fingerprints taken
+	SeverityImportant                 // a decision or an appointment, e.g. an approval
+	SeverityCritical                  // needs the applicant to act or show up, or an adverse decision
+)
+
+var severityNames = []string{"info", "notice", "important", "critical"}
+
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+	return severityNames[s]
+}
+
+// ParseSeverity parses a severity name: info, notice, important or critical
+func ParseSeverity(name string) (Severity, error) {
+	for i, known := range severityNames {
+		if strings.EqualFold(strings.TrimSpace(name), known) {
+			return Severity(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown severity %q (allowed: %s)", name, strings.Join(severityNames, ", "))
+}
+
+// EventType names the kind of transition a change is
+type EventType string
+
+const (
+	EventApproved            EventType = "approved"
+	EventDenied              EventType = "denied"
+	EventRejected            EventType = "rejected"
+	EventRevoked             EventType = "revoked"
+	EventRFE                 EventType = "rfe"
+	EventRFEResponse         EventType = "rfe_response_received"
+	EventIntentToDeny        EventType = "intent_to_deny"
+	EventInterviewScheduled  EventType = "interview_scheduled"
+	EventInterviewCompleted  EventType = "interview_completed"
+	EventBiometricsScheduled EventType = "biometrics_scheduled"
+	EventBiometricsTaken     EventType = "biometrics_taken"
+	EventOathScheduled       EventType = "oath_scheduled"
+	EventCardProduced        EventType = "card_produced"
+	EventCardMailed          EventType = "card_mailed"
+	EventCardDelivered       EventType = "card_delivered"
+	EventTransferred         EventType = "transferred"
+	EventReceived            EventType = "received"
+	EventStatusChanged       EventType = "status_changed" // a status no rule knows
+	EventUpdated             EventType = "updated"        // fields changed, the status didn't
+)
+
+// Event is a classified set of changes of one case
+type Event struct {
+	Type     EventType
+	Severity Severity
+	Label    string // short title, e.g. "Request for Evidence"
+	Status   string // the new status line, "" when it didn't change
+}
+
+// statusRule classifies status lines containing any of its phrases (lowercase)
+type statusRule struct {
+	event    EventType
+	severity Severity
+	label    string
+	phrases  []string
+}
+
+// statusRules are checked in order and the first match wins, so narrower phrases
+// ("response to ... request for evidence", "card was mailed") come before broader
+// ones ("request for evidence", "approved")
+var statusRules = []statusRule{
+	{EventRFEResponse, SeverityNotice, "RFE Response Received", []string{"response to uscis' request for evidence", "response to uscis request for evidence", "response to request for evidence"}},
+	{EventRevoked, SeverityCritical, "Approval Revoked", []string{"revoked"}},
+	{EventRejected, SeverityCritical, "Case Rejected", []string{"rejected"}},
+	{EventDenied, SeverityCritical, "Case Denied", []string{"denied", "terminated", "not approved"}},
+	{EventIntentToDeny, SeverityCritical, "Notice of Intent to Deny", []string{"intent to deny", "intent to revoke"}},
+	{EventRFE, SeverityCritical, "Request for Evidence", []string{"request for evidence", "request for additional evidence"}},
+	{EventInterviewScheduled, SeverityCritical, "Interview Scheduled", []string{"interview was scheduled", "interview is scheduled", "interview was rescheduled"}},
+	{EventInterviewCompleted, SeverityNotice, "Interview Completed", []string{"interview was completed"}},
+	{EventBiometricsScheduled, SeverityImportant, "Biometrics Appointment Scheduled", []string{"biometrics appointment was scheduled", "fingerprint appointment was scheduled"}},
+	{EventBiometricsTaken, SeverityNotice, "Fingerprints Taken", []string{"fingerprints were taken", "biometrics were taken"}},
+	{EventOathScheduled, SeverityImportant, "Oath Ceremony Scheduled", []string{"oath ceremony"}},
+	{EventCardDelivered, SeverityNotice, "Card Delivered", []string{"card was delivered", "card was picked up"}},
+	{EventCardMailed, SeverityNotice, "Card Mailed", []string{"card was mailed", "document was mailed"}},
+	{EventCardProduced, SeverityImportant, "Card Being Produced", []string{"card is being produced"}},
+	{EventApproved, SeverityImportant, "Case Approved", []string{"case was approved", "case approval was", "approved"}},
+	{EventTransferred, SeverityNotice, "Case Transferred", []string{"transferred", "relocated"}},
+	{EventReceived, SeverityNotice, "Case Received", []string{"received", "accepted"}},
+}
+
+// ClassifyStatus classifies a case that moved into the status line summary
+func ClassifyStatus(summary string) Event {
+	lower := strings.ToLower(summary)
+	for _, rule := range statusRules {
+		for _, phrase := range rule.phrases {
+			if strings.Contains(lower, phrase) {
+				return Event{Type: rule.event, Severity: rule.severity, Label: rule.label, Status: summary}
+			}
+		}
+	}
+	return Event{Type: EventStatusChanged, Severity: SeverityNotice, Label: "Status Changed", Status: summary}
+}
+
+// Classify classifies the changes DetectStatusChanges reported for a case by the status
+// it moved into; changes that leave the status alone are informational
+func Classify(changes []Change) Event {
+	for _, change := range changes {
+		if change.Field == "Status" && change.NewValue != nil {
+			return ClassifyStatus(fmt.Sprint(change.NewValue))
+		}
+	}
+	return Event{Type: EventUpdated, Severity: SeverityInfo, Label: "Case Updated"}
+}
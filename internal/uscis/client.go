@@ -1,10 +1,16 @@
 package uscis
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"net/url"
+	"sync"
+	"time"
 )
 
 const (
@@ -14,48 +20,297 @@ const (
 // Client is the USCIS API client for manual cookie mode
 type Client struct {
 	httpClient *http.Client
-	cookie     string
+	ctx        context.Context // cancelled on shutdown; aborts in-flight requests
+
+	mu        sync.Mutex
+	cookie    string
+	cookieGen uint64          // incremented by every refresh, so concurrent fetches refresh once
+	refresh   CookieRefresher // Optional: replaces an expired cookie
+	gate      LoginGate       // Optional: may hold automatic refreshes
+
+	refreshes refreshBackoff // failed refreshes, so later fetches don't each log in again (guarded by mu)
+}
+
+// CookieRefresher returns a fresh Cookie header for the account API, e.g. by
+// running the browser login with stored credentials
+type CookieRefresher func() (string, error)
+
+// LoginGate is consulted before a session is refreshed automatically after USCIS
+// rejected it; an error (usually *ErrLoginHeld) holds the refresh and is returned to
+// the fetch. Refreshes asked for explicitly (RefreshSession) aren't gated
+type LoginGate func() error
+
+// ErrLoginHeld is returned when an expired session wasn't refreshed because the login
+// (and its 2FA code) waits for the user's approval
+type ErrLoginHeld struct {
+	Until time.Time // when the refresh happens without approval
+}
+
+func (e *ErrLoginHeld) Error() string {
+	return fmt.Sprintf("session expired; the new login is held for approval until %s", e.Until.Format(time.RFC3339))
 }
 
 // ErrAuthenticationFailed is returned when the cookie has expired (401)
 type ErrAuthenticationFailed struct {
 	StatusCode int
+	Captures   []string // Screenshot and page saved of a failed browser login
+	Err        error    // The failed browser login, if that was the cause
 }
 
 func (e *ErrAuthenticationFailed) Error() string {
 	return fmt.Sprintf("authentication failed: received status code %d (cookie may have expired)", e.StatusCode)
 }
 
+func (e *ErrAuthenticationFailed) Unwrap() error {
+	return e.Err
+}
+
+// ErrFetchTimeout is returned when a case fetch exceeds the configured deadline
+type ErrFetchTimeout struct {
+	CaseID  string
+	Timeout time.Duration
+}
+
+func (e *ErrFetchTimeout) Error() string {
+	return fmt.Sprintf("fetch for case %s timed out after %v", e.CaseID, e.Timeout)
+}
+
+// ParseError is returned when a USCIS response body is not valid case JSON
+// The raw body is kept so it can be stored for later inspection
+type ParseError struct {
+	Body string
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("failed to parse API response: %v", e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ParseCaseResponse decodes a case API response body
+// Returns a *ParseError carrying the raw body on failure
+func ParseCaseResponse(body []byte) (map[string]interface{}, error) {
+	var result map[string]interface{}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, &ParseError{Body: string(body), Err: err}
+	}
+	if result == nil {
+		return nil, &ParseError{Body: string(body), Err: fmt.Errorf("response is not a JSON object")}
+	}
+	return result, nil
+}
+
 // NewClient creates a new USCIS client with manual cookie
 func NewClient(cookie string) *Client {
 	return &Client{
 		httpClient: &http.Client{},
+		ctx:        context.Background(),
 		cookie:     cookie,
 	}
 }
 
+// SetFetchTimeout bounds how long a single case request may take
+func (c *Client) SetFetchTimeout(timeout time.Duration) {
+	c.httpClient.Timeout = timeout
+}
+
+// SetContext makes requests stop when ctx is done, e.g. on shutdown
+func (c *Client) SetContext(ctx context.Context) {
+	c.ctx = ctx
+}
+
+// SetCookieRefresher makes the client replace its cookie with refresh when USCIS
+// rejects it, and retry the request once, instead of failing with ErrAuthenticationFailed
+func (c *Client) SetCookieRefresher(refresh CookieRefresher) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.refresh = refresh
+}
+
+// SetLoginGate makes automatic cookie refreshes ask gate first
+func (c *Client) SetLoginGate(gate LoginGate) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gate = gate
+}
+
 // FetchCaseStatus fetches the current status of a case
+// With a cookie refresher, an expired cookie is refreshed and the request retried once
+// A case ID that isn't a receipt number fails with ErrInvalidReceipt without a request
 func (c *Client) FetchCaseStatus(caseID string) (map[string]interface{}, error) {
-	return c.fetchCaseStatusInternal(caseID)
+	if _, err := ValidateReceiptNumber(caseID); err != nil {
+		return nil, err
+	}
+	cookie, gen := c.currentCookie()
+	result, err := c.fetchCaseStatusInternal(caseID, cookie)
+
+	var authErr *ErrAuthenticationFailed
+	if !errors.As(err, &authErr) || c.refresher() == nil {
+		return result, err
+	}
+
+	log.Printf("[%s] Session cookie rejected (status %d), refreshing it with the stored credentials...", caseID, authErr.StatusCode)
+	cookie, refreshErr := c.refreshCookieAfter(gen, true)
+	if refreshErr != nil {
+		var held *ErrLoginHeld
+		var backoff *ErrRefreshBackoff
+		if errors.As(refreshErr, &held) || errors.As(refreshErr, &backoff) || IsLoginBlocked(refreshErr) {
+			return nil, refreshErr
+		}
+		log.Printf("Failed to refresh session cookie: %v", refreshErr)
+		return nil, err
+	}
+
+	log.Printf("[%s] Session cookie refreshed, retrying request...", caseID)
+	return c.fetchCaseStatusInternal(caseID, cookie)
+}
+
+// RefreshSession replaces the session cookie using the cookie refresher
+// Fails when no refresher is configured, since a manual cookie can't be renewed
+func (c *Client) RefreshSession() error {
+	if c.refresher() == nil {
+		return errors.New("no cookie refresher configured")
+	}
+	_, gen := c.currentCookie()
+	if _, err := c.refreshCookieAfter(gen, false); err != nil {
+		return fmt.Errorf("failed to refresh session cookie: %w", err)
+	}
+	log.Printf("Session cookie refreshed")
+	return nil
+}
+
+// currentCookie returns the cookie in use and its generation
+func (c *Client) currentCookie() (string, uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cookie, c.cookieGen
+}
+
+// refresher returns the configured cookie refresher, if any
+func (c *Client) refresher() CookieRefresher {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.refresh
+}
+
+// refreshCookieAfter replaces the cookie unless another fetch already did so since
+// gen was observed, and returns the cookie to retry with
+// gated is the automatic refresh: it waits out the backoff of failed refreshes and asks
+// the login gate first. Holding the lock during the refresh makes concurrent fetches
+// wait for one login
+func (c *Client) refreshCookieAfter(gen uint64, gated bool) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cookieGen != gen {
+		log.Printf("Session cookie was already refreshed by another fetch")
+		return c.cookie, nil
+	}
+	if gated {
+		if err := c.refreshes.check(time.Now()); err != nil {
+			return "", err
+		}
+		if c.gate != nil {
+			if err := c.gate(); err != nil {
+				return "", err
+			}
+		}
+	}
+	cookie, err := c.refresh()
+	if err == nil && cookie == "" {
+		err = errors.New("refresh returned an empty cookie")
+	}
+	c.refreshes.record(err, time.Now())
+	if err != nil {
+		return "", err
+	}
+	c.cookie = cookie
+	c.cookieGen++
+	return cookie, nil
+}
+
+// FetchCase fetches the current status of a case as a typed CaseStatus
+func (c *Client) FetchCase(caseID string) (*CaseStatus, error) {
+	payload, err := c.FetchCaseStatus(caseID)
+	if err != nil {
+		return nil, err
+	}
+	return NewCaseStatus(payload), nil
 }
 
 // fetchCaseStatusInternal performs the actual HTTP request
-func (c *Client) fetchCaseStatusInternal(caseID string) (map[string]interface{}, error) {
-	url := fmt.Sprintf("%s/%s", baseURL, caseID)
+func (c *Client) fetchCaseStatusInternal(caseID, cookie string) (map[string]interface{}, error) {
+	body, err := c.get(fmt.Sprintf("%s/%s", baseURL, caseID), caseID, cookie)
+	if err != nil {
+		return nil, err
+	}
 
-	req, err := http.NewRequest("GET", url, nil)
+	// Parse JSON response
+	return ParseCaseResponse(body)
+}
+
+// FetchCaseHistory fetches the entries of every history endpoint of a case
+// An endpoint USCIS doesn't offer for the case (404) contributes nothing. The cookie
+// isn't refreshed here: the status fetch before it already did that if needed
+func (c *Client) FetchCaseHistory(caseID string) ([]interface{}, error) {
+	cookie, _ := c.currentCookie()
+
+	var entries []interface{}
+	for _, endpoint := range HistoryEndpoints {
+		body, err := c.get(historyURL(caseID, endpoint), caseID, cookie)
+		var statusErr *errUnexpectedStatus
+		if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch case %s: %w", endpoint, err)
+		}
+		items, err := ParseHistoryResponse(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch case %s: %w", endpoint, err)
+		}
+		entries = append(entries, items...)
+	}
+	return entries, nil
+}
+
+// FetchDocument downloads a notice PDF with the session cookie
+func (c *Client) FetchDocument(doc Document) ([]byte, error) {
+	cookie, _ := c.currentCookie()
+	return downloadDocument(c.httpClient, doc, cookie)
+}
+
+// errUnexpectedStatus is returned for a response that is neither 200 nor 401
+type errUnexpectedStatus struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *errUnexpectedStatus) Error() string {
+	return fmt.Sprintf("unexpected status code: %d, body: %s", e.StatusCode, e.Body)
+}
+
+// get performs a GET request against the account API and returns the response body
+func (c *Client) get(apiURL, caseID, cookie string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(c.ctx, "GET", apiURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	// Set headers to match browser/curl behavior
-	req.Header.Set("Cookie", c.cookie)
+	req.Header.Set("Cookie", cookie)
 	req.Header.Set("Accept", "application/json, text/plain, */*")
 	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36")
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
+		if urlErr, ok := err.(*url.Error); ok && urlErr.Timeout() {
+			return nil, &ErrFetchTimeout{CaseID: caseID, Timeout: c.httpClient.Timeout}
+		}
 		return nil, fmt.Errorf("failed to fetch case status: %w", err)
 	}
 	defer resp.Body.Close()
@@ -73,14 +328,7 @@ func (c *Client) fetchCaseStatusInternal(caseID string) (map[string]interface{},
 
 	// Check for other HTTP errors
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(body))
-	}
-
-	// Parse JSON response
-	var result map[string]interface{}
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("failed to parse JSON response: %w", err)
+		return nil, &errUnexpectedStatus{StatusCode: resp.StatusCode, Body: string(body)}
 	}
-
-	return result, nil
+	return body, nil
 }
@@ -2,18 +2,23 @@ package uscis
 
 import (
 	"fmt"
+	"path"
 	"reflect"
+	"sort"
 	"strings"
+	"time"
 )
 
 // Change represents a single field change
 type Change struct {
-	Field    string
-	OldValue interface{}
-	NewValue interface{}
+	Field    string      `json:"field"`
+	OldValue interface{} `json:"old_value"`
+	NewValue interface{} `json:"new_value"`
 }
 
-// DetectChanges compares two case status maps and returns a list of changes
+// DetectChanges compares two case status maps and returns the changed leaf values
+// Nested objects and arrays are compared recursively and reported by path, e.g.
+// "data.actions[0].title"; a field added or removed as a whole is reported once
 func DetectChanges(previous, current map[string]interface{}) []Change {
 	if previous == nil {
 		// First run - no previous state
@@ -21,42 +26,327 @@ func DetectChanges(previous, current map[string]interface{}) []Change {
 	}
 
 	var changes []Change
+	diffMaps("", previous, current, &changes)
+	return changes
+}
+
+// diffValues appends the differences between two values at path
+func diffValues(path string, oldVal, newVal interface{}, changes *[]Change) {
+	switch o := oldVal.(type) {
+	case map[string]interface{}:
+		if n, ok := newVal.(map[string]interface{}); ok {
+			diffMaps(path, o, n, changes)
+			return
+		}
+	case []interface{}:
+		if n, ok := newVal.([]interface{}); ok {
+			diffSlices(path, o, n, changes)
+			return
+		}
+	}
+	if !deepEqual(oldVal, newVal) {
+		*changes = append(*changes, Change{Field: path, OldValue: oldVal, NewValue: newVal})
+	}
+}
+
+// diffMaps compares two objects key by key, in key order
+func diffMaps(path string, previous, current map[string]interface{}, changes *[]Change) {
+	keys := make([]string, 0, len(previous)+len(current))
+	for key := range previous {
+		keys = append(keys, key)
+	}
+	for key := range current {
+		if _, ok := previous[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		keyPath := key
+		if path != "" {
+			keyPath = path + "." + key
+		}
+		oldVal, hadOld := previous[key]
+		newVal, hasNew := current[key]
+		switch {
+		case !hadOld:
+			*changes = append(*changes, Change{Field: keyPath, NewValue: newVal})
+		case !hasNew:
+			*changes = append(*changes, Change{Field: keyPath, OldValue: oldVal})
+		default:
+			diffValues(keyPath, oldVal, newVal, changes)
+		}
+	}
+}
+
+// diffSlices compares two arrays element by element
+// Elements added to or removed from either end are reported as such, instead of
+// every following index looking changed
+func diffSlices(path string, previous, current []interface{}, changes *[]Change) {
+	index := func(i int) string { return fmt.Sprintf("%s[%d]", path, i) }
+
+	if offset, ok := shiftedBy(previous, current); ok {
+		for i := range current {
+			if i < offset || i >= offset+len(previous) {
+				*changes = append(*changes, Change{Field: index(i), NewValue: current[i]})
+			}
+		}
+		return
+	}
+	if offset, ok := shiftedBy(current, previous); ok {
+		for i := range previous {
+			if i < offset || i >= offset+len(current) {
+				*changes = append(*changes, Change{Field: index(i), OldValue: previous[i]})
+			}
+		}
+		return
+	}
 
-	// Check for changed or new fields in current
-	for key, newVal := range current {
-		oldVal, exists := previous[key]
+	for i := 0; i < len(previous) || i < len(current); i++ {
+		switch {
+		case i >= len(previous):
+			*changes = append(*changes, Change{Field: index(i), NewValue: current[i]})
+		case i >= len(current):
+			*changes = append(*changes, Change{Field: index(i), OldValue: previous[i]})
+		default:
+			diffValues(index(i), previous[i], current[i], changes)
+		}
+	}
+}
 
-		if !exists {
-			// New field added
-			changes = append(changes, Change{
-				Field:    key,
-				OldValue: nil,
-				NewValue: newVal,
-			})
-		} else if !deepEqual(oldVal, newVal) {
-			// Field value changed
-			changes = append(changes, Change{
-				Field:    key,
-				OldValue: oldVal,
-				NewValue: newVal,
-			})
+// diffActionItems compares the history entries of two lists, matched by what they
+// describe (date and text) rather than by index, since the order differs between
+// endpoints. Entries added or removed are reported as actions already; items that
+// aren't entries are reported when added or removed
+func diffActionItems(path string, previous, current []interface{}, changes *[]Change) {
+	index := func(i int) string { return fmt.Sprintf("%s[%d]", path, i) }
+
+	used := make([]bool, len(current))
+	match := func(item interface{}) (int, bool) {
+		action, isAction := actionOf(item)
+		for i, other := range current {
+			if used[i] {
+				continue
+			}
+			same := deepEqual(item, other)
+			if isAction {
+				otherAction, ok := actionOf(other)
+				same = ok && otherAction == action
+			}
+			if same {
+				used[i] = true
+				return i, true
+			}
 		}
+		return 0, false
 	}
 
-	// Check for removed fields
-	for key, oldVal := range previous {
-		if _, exists := current[key]; !exists {
-			changes = append(changes, Change{
-				Field:    key,
-				OldValue: oldVal,
-				NewValue: nil,
-			})
+	for i, item := range previous {
+		j, ok := match(item)
+		switch {
+		case ok:
+			diffValues(index(j), item, current[j], changes)
+		case !isActionItem(item):
+			*changes = append(*changes, Change{Field: index(i), OldValue: item})
+		}
+	}
+	for i, item := range current {
+		if !used[i] && !isActionItem(item) {
+			*changes = append(*changes, Change{Field: index(i), NewValue: item})
 		}
 	}
+}
+
+// isActionItem reports whether a raw list item is a history entry
+func isActionItem(item interface{}) bool {
+	_, ok := actionOf(item)
+	return ok
+}
+
+// envelope returns the top-level fields around the "data" object of a payload, or
+// nothing if the payload has no such envelope
+func envelope(payload map[string]interface{}) map[string]interface{} {
+	if _, ok := payload["data"].(map[string]interface{}); !ok {
+		return nil
+	}
+	out := make(map[string]interface{}, len(payload)-1)
+	for key, value := range payload {
+		if key != "data" {
+			out[key] = value
+		}
+	}
+	return out
+}
+
+// shiftedBy reports whether longer is shorter with elements added only at the start
+// or only at the end, and at which index shorter begins within it
+func shiftedBy(shorter, longer []interface{}) (int, bool) {
+	if len(shorter) == 0 || len(shorter) >= len(longer) {
+		return 0, false
+	}
+	if deepEqual(shorter, longer[:len(shorter)]) {
+		return 0, true
+	}
+	offset := len(longer) - len(shorter)
+	if deepEqual(shorter, longer[offset:]) {
+		return offset, true
+	}
+	return 0, false
+}
+
+// DetectStatusChanges compares two typed case statuses and returns readable changes
+// Typed fields are reported under their display names ("Status", "Last Updated"...);
+// changes in payload fields without a typed counterpart are reported by their dotted path
+func DetectStatusChanges(previous, current *CaseStatus) []Change {
+	if previous == nil || current == nil {
+		// First run - no previous state
+		return nil
+	}
+
+	var changes []Change
+	compare := func(field string, oldVal, newVal string) {
+		if oldVal == newVal {
+			return
+		}
+		change := Change{Field: field}
+		if oldVal != "" {
+			change.OldValue = oldVal
+		}
+		if newVal != "" {
+			change.NewValue = newVal
+		}
+		changes = append(changes, change)
+	}
+
+	compare("Status", previous.StatusTitle, current.StatusTitle)
+	compare("Description", previous.Description, current.Description)
+	compare("Form", previous.FormType, current.FormType)
+	compare("Receipt Number", previous.ReceiptNumber, current.ReceiptNumber)
+	compare("Last Updated", formatStatusDate(previous.LastUpdated), formatStatusDate(current.LastUpdated))
+
+	// History entries are reported as a whole, added or removed
+	had := make(map[CaseAction]bool, len(previous.Actions))
+	for _, action := range previous.Actions {
+		had[action] = true
+	}
+	has := make(map[CaseAction]bool, len(current.Actions))
+	for _, action := range current.Actions {
+		has[action] = true
+		if !had[action] {
+			changes = append(changes, Change{Field: "New Action", NewValue: action.String()})
+		}
+	}
+	for _, action := range previous.Actions {
+		if !has[action] {
+			changes = append(changes, Change{Field: "Removed Action", OldValue: action.String()})
+		}
+	}
+
+	// Everything else, aliases of typed fields included, compared recursively and
+	// reported by path
+	oldData, newData := caseData(previous.Raw), caseData(current.Raw)
+	mapped := mappedKeys(oldData, newData)
+	diffMaps("", unmapped(oldData, mapped), unmapped(newData, mapped), &changes)
+
+	// The other fields of the history entries in both payloads
+	for _, key := range append([]string{HistoryKey}, actionListKeys...) {
+		if mapped[key] {
+			oldItems, _ := oldData[key].([]interface{})
+			newItems, _ := newData[key].([]interface{})
+			diffActionItems(key, oldItems, newItems, &changes)
+		}
+	}
+
+	// The envelope around the case data, if there is one
+	diffMaps("", envelope(previous.Raw), envelope(current.Raw), &changes)
 
 	return changes
 }
 
+// ChangeOptions tunes which detected changes are reported
+type ChangeOptions struct {
+	// IgnoreFields lists fields whose changes are dropped, matched case-insensitively
+	// against the reported name ("Last Updated"), the payload keys behind it
+	// ("updatedAt") or a dotted path ("meta.etag"). "*" matches any characters
+	// ("*.requestId" matches at any depth below the top level), and a path also
+	// ignores everything below it
+	IgnoreFields []string
+}
+
+// typedFieldKeys maps the display names of typed fields to the payload keys they are read from
+var typedFieldKeys = map[string][]string{
+	"Status":         statusTitleKeys,
+	"Description":    descriptionKeys,
+	"Form":           formKeys,
+	"Receipt Number": receiptKeys,
+	"Last Updated":   updatedKeys,
+	"New Action":     append([]string{HistoryKey}, actionListKeys...),
+	"Removed Action": append([]string{HistoryKey}, actionListKeys...),
+}
+
+// Filter returns the changes that aren't ignored
+func (o ChangeOptions) Filter(changes []Change) []Change {
+	if len(o.IgnoreFields) == 0 {
+		return changes
+	}
+	var kept []Change
+	for _, change := range changes {
+		if !o.Ignores(change.Field) {
+			kept = append(kept, change)
+		}
+	}
+	return kept
+}
+
+// Ignores reports whether changes of a field are ignored
+func (o ChangeOptions) Ignores(field string) bool {
+	names := append([]string{field}, typedFieldKeys[field]...)
+	for _, pattern := range o.IgnoreFields {
+		pattern = strings.ToLower(pattern)
+		for _, name := range names {
+			name = strings.ToLower(name)
+			if matched, _ := path.Match(pattern, name); matched || strings.HasPrefix(name, pattern+".") || strings.HasPrefix(name, pattern+"[") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// String formats an action as "2006-01-02: description"
+func (a CaseAction) String() string {
+	if a.Date.IsZero() {
+		return a.Description
+	}
+	if a.Description == "" {
+		return formatStatusDate(a.Date)
+	}
+	return formatStatusDate(a.Date) + ": " + a.Description
+}
+
+// formatStatusDate formats a payload date for change lists, or "" for the zero time
+func formatStatusDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 {
+		return t.Format("2006-01-02")
+	}
+	return t.Format("2006-01-02 15:04 MST")
+}
+
+// unmapped returns the top-level fields that have no typed counterpart
+func unmapped(data map[string]interface{}, mapped map[string]bool) map[string]interface{} {
+	out := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		if !mapped[key] {
+			out[key] = value
+		}
+	}
+	return out
+}
+
 // deepEqual performs deep comparison of two values
 func deepEqual(a, b interface{}) bool {
 	return reflect.DeepEqual(a, b)
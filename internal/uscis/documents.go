@@ -0,0 +1,170 @@
+package uscis
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Document is a notice (e.g. an I-797 receipt or approval notice) a case payload links to
+type Document struct {
+	ID    string // USCIS document ID, empty if the payload only has a URL
+	Title string
+	Date  time.Time // zero if the entry has no parsable date
+	URL   string    // absolute download URL on a uscis.gov host
+}
+
+// Key identifies the document across polls
+func (d Document) Key() string {
+	if d.ID != "" {
+		return d.ID
+	}
+	return d.URL
+}
+
+// maxDocumentSize bounds a downloaded notice; real ones are well below a megabyte
+const maxDocumentSize = 10 << 20
+
+// Payload keys of document references inside history and notice entries
+var (
+	documentListKeys  = []string{"documents", "notices"}
+	documentURLKeys   = []string{"documentUrl", "downloadUrl", "pdfUrl", "noticeUrl"}
+	documentIDKeys    = []string{"documentId", "noticeId", "docId"}
+	documentTitleKeys = []string{"documentTitle", "noticeType", "title", "description", "eventCode"}
+)
+
+// documentBaseURL resolves relative document links and builds links from document IDs
+const documentBaseURL = "https://my.uscis.gov"
+
+// Documents returns the documents a case payload links to, in payload order
+// Entries are read from the document lists and the history (events, caseHistory...)
+func Documents(payload map[string]interface{}) []Document {
+	if payload == nil {
+		return nil
+	}
+	data := caseData(payload)
+	caseID := firstString(data, receiptKeys)
+
+	var docs []Document
+	seen := make(map[string]bool)
+	for _, key := range append(append([]string{HistoryKey}, documentListKeys...), actionListKeys...) {
+		items, _ := data[key].([]interface{})
+		for _, item := range items {
+			entry, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			doc, ok := documentOf(entry, caseID)
+			if !ok || seen[doc.Key()] {
+				continue
+			}
+			seen[doc.Key()] = true
+			docs = append(docs, doc)
+		}
+	}
+	return docs
+}
+
+// NewDocuments returns the documents of current that previous doesn't link to
+func NewDocuments(previous, current map[string]interface{}) []Document {
+	known := make(map[string]bool)
+	for _, doc := range Documents(previous) {
+		known[doc.Key()] = true
+	}
+	var docs []Document
+	for _, doc := range Documents(current) {
+		if !known[doc.Key()] {
+			docs = append(docs, doc)
+		}
+	}
+	return docs
+}
+
+// documentOf reads the document reference of an entry
+// Links off uscis.gov are ignored: the session cookie is sent along with the download
+func documentOf(entry map[string]interface{}, caseID string) (Document, bool) {
+	doc := Document{
+		ID:    firstString(entry, documentIDKeys),
+		Title: firstString(entry, documentTitleKeys),
+	}
+	if date := firstString(entry, actionDateKeys); date != "" {
+		doc.Date, _ = ParseDate(date)
+	}
+
+	if link := firstString(entry, documentURLKeys); link != "" {
+		base, _ := url.Parse(documentBaseURL)
+		ref, err := url.Parse(link)
+		if err != nil {
+			return Document{}, false
+		}
+		doc.URL = base.ResolveReference(ref).String()
+	} else if doc.ID != "" && caseID != "" {
+		doc.URL = fmt.Sprintf("%s/%s/documents/%s", baseURL, url.PathEscape(caseID), url.PathEscape(doc.ID))
+	} else {
+		return Document{}, false
+	}
+
+	if !IsUSCISURL(doc.URL) {
+		return Document{}, false
+	}
+	if doc.Title == "" {
+		doc.Title = "USCIS notice"
+	}
+	return doc, true
+}
+
+// IsUSCISURL reports whether a URL points at an https uscis.gov host
+func IsUSCISURL(link string) bool {
+	u, err := url.Parse(link)
+	if err != nil || u.Scheme != "https" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	return host == "uscis.gov" || strings.HasSuffix(host, ".uscis.gov")
+}
+
+// ErrNotPDF is returned when a document download isn't a PDF, e.g. a login page
+var ErrNotPDF = errors.New("downloaded document is not a PDF")
+
+// downloadDocument fetches a document with the given session cookie
+func downloadDocument(httpClient *http.Client, doc Document, cookie string) ([]byte, error) {
+	if !IsUSCISURL(doc.URL) {
+		return nil, fmt.Errorf("refusing to download %s: not a uscis.gov URL", doc.URL)
+	}
+	req, err := http.NewRequest("GET", doc.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Cookie", cookie)
+	req.Header.Set("Accept", "application/pdf, */*")
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download document: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, &ErrAuthenticationFailed{StatusCode: resp.StatusCode}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code downloading document: %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDocumentSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document: %w", err)
+	}
+	if len(body) > maxDocumentSize {
+		return nil, fmt.Errorf("document is larger than %d MB", maxDocumentSize>>20)
+	}
+	if !bytes.HasPrefix(body, []byte("%PDF-")) {
+		return nil, ErrNotPDF
+	}
+	return body, nil
+}
@@ -0,0 +1,86 @@
+package uscis
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Errors callers can tell apart with errors.Is, whichever client returned them; the
+// typed errors (ErrAuthenticationFailed, ParseError, ...) match the one they are a case of
+var (
+	// ErrCaseNotFound is returned when USCIS has no case with the receipt number
+	ErrCaseNotFound = errors.New("case not found")
+	// ErrRateLimited is returned when USCIS answered 429 Too Many Requests
+	ErrRateLimited = errors.New("rate limited by USCIS")
+	// ErrInvalidReceipt is returned for a case ID that isn't a receipt number
+	// (ValidateReceiptNumber); it is never sent to USCIS
+	ErrInvalidReceipt = errors.New("invalid receipt number")
+	// ErrSessionExpired is returned when there is no valid USCIS session: it expired and
+	// a new login failed or is held (ErrAuthenticationFailed, ErrLoginHeld)
+	ErrSessionExpired = errors.New("session expired")
+	// ErrParse is returned when a response isn't case JSON (ParseError)
+	ErrParse = errors.New("unparseable USCIS response")
+)
+
+// receiptPattern matches a normalized receipt number: three letters for the service
+// center, then ten digits
+var receiptPattern = regexp.MustCompile(`^[A-Z]{3}[0-9]{10}$`)
+
+// NormalizeReceiptNumber removes the whitespace from a receipt number and upper-cases it
+func NormalizeReceiptNumber(id string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(id), ""))
+}
+
+// ValidateReceiptNumber returns id normalized (NormalizeReceiptNumber) if it is a
+// receipt number like IOE0123456789, or an error wrapping ErrInvalidReceipt that says
+// what is wrong with it
+func ValidateReceiptNumber(id string) (string, error) {
+	normalized := NormalizeReceiptNumber(id)
+	if receiptPattern.MatchString(normalized) {
+		return normalized, nil
+	}
+	return "", fmt.Errorf("%w %q: expected 3 letters and 10 digits, e.g. IOE0123456789; %s", ErrInvalidReceipt, id, receiptProblem(normalized))
+}
+
+// receiptProblem says what is wrong with a normalized ID that isn't a receipt number
+func receiptProblem(id string) string {
+	letters := len(id) - len(strings.TrimLeft(id, "ABCDEFGHIJKLMNOPQRSTUVWXYZ"))
+	rest := id[letters:]
+	switch {
+	case id == "":
+		return "it is empty"
+	case letters != 3:
+		return fmt.Sprintf("it starts with %d letters", letters)
+	case strings.ContainsAny(rest, "OIL"):
+		return "it has a letter O, I or L among the digits (0 or 1?)"
+	case strings.Trim(rest, "0123456789") != "":
+		return "it has characters other than digits after the letters"
+	default:
+		return fmt.Sprintf("it has %d digits", len(rest))
+	}
+}
+
+func (e *ErrAuthenticationFailed) Is(target error) bool {
+	return target == ErrSessionExpired
+}
+
+func (e *ErrLoginHeld) Is(target error) bool {
+	return target == ErrSessionExpired
+}
+
+func (e *ParseError) Is(target error) bool {
+	return target == ErrParse
+}
+
+func (e *errUnexpectedStatus) Is(target error) bool {
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return target == ErrCaseNotFound
+	case http.StatusTooManyRequests:
+		return target == ErrRateLimited
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package uscis
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// HistoryKey is the payload key holding the entries of the case history endpoints,
+// merged into the case data by WithHistory
+const HistoryKey = "caseHistory"
+
+// HistoryEndpoints are the case-service endpoints below a case listing its history
+// They report interim actions ("fingerprint fee received") the status payload drops
+var HistoryEndpoints = []string{"history", "notices"}
+
+// historyListKeys are the keys a history response may keep its entries under
+var historyListKeys = append([]string{"history", "notices", "items"}, actionListKeys...)
+
+// ParseHistoryResponse extracts the entries of a history endpoint response
+// The list may be the whole body, the data field or a list inside it
+// Returns a *ParseError carrying the raw body when the body holds no list
+func ParseHistoryResponse(body []byte) ([]interface{}, error) {
+	var decoded interface{}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return nil, &ParseError{Body: string(body), Err: fmt.Errorf("invalid history JSON: %w", err)}
+	}
+
+	if items, ok := decoded.([]interface{}); ok {
+		return items, nil
+	}
+	object, ok := decoded.(map[string]interface{})
+	if !ok {
+		return nil, &ParseError{Body: string(body), Err: fmt.Errorf("history response is a %T, not a list", decoded)}
+	}
+	if items, ok := object["data"].([]interface{}); ok {
+		return items, nil
+	}
+	data := caseData(object)
+	for _, key := range historyListKeys {
+		if items, ok := data[key].([]interface{}); ok {
+			return items, nil
+		}
+	}
+	if _, ok := object["data"]; ok && object["data"] == nil {
+		// No history for this case yet
+		return nil, nil
+	}
+	return nil, &ParseError{Body: string(body), Err: fmt.Errorf("history response has no list of entries")}
+}
+
+// WithHistory returns a copy of a case payload with the history entries merged into its
+// case data under HistoryKey, duplicates dropped
+// The payload itself is left unchanged; a nil payload stays nil
+func WithHistory(payload map[string]interface{}, entries []interface{}) map[string]interface{} {
+	if payload == nil {
+		return nil
+	}
+
+	merged := make([]interface{}, 0, len(entries))
+	seen := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		encoded, err := json.Marshal(entry)
+		if err != nil || seen[string(encoded)] {
+			continue
+		}
+		seen[string(encoded)] = true
+		merged = append(merged, entry)
+	}
+
+	out := make(map[string]interface{}, len(payload))
+	for key, value := range payload {
+		out[key] = value
+	}
+	if data, ok := payload["data"].(map[string]interface{}); ok {
+		copied := make(map[string]interface{}, len(data)+1)
+		for key, value := range data {
+			copied[key] = value
+		}
+		copied[HistoryKey] = merged
+		out["data"] = copied
+	} else {
+		out[HistoryKey] = merged
+	}
+	return out
+}
+
+// HistoryEntries returns the history entries merged into a payload, if any
+func HistoryEntries(payload map[string]interface{}) ([]interface{}, bool) {
+	if payload == nil {
+		return nil, false
+	}
+	entries, ok := caseData(payload)[HistoryKey].([]interface{})
+	return entries, ok
+}
+
+// historyURL returns the URL of a history endpoint of a case
+func historyURL(caseID, endpoint string) string {
+	return fmt.Sprintf("%s/%s/%s", strings.TrimRight(baseURL, "/"), caseID, endpoint)
+}
@@ -0,0 +1,193 @@
+package uscis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/chromedp/chromedp"
+)
+
+// ErrAccountLocked is returned when USCIS refuses the sign-in because the account is
+// locked or saw too many attempts; logging in again only extends the lock
+type ErrAccountLocked struct {
+	Message string // what the page said
+	Err     error  // the login error the page explains
+}
+
+func (e *ErrAccountLocked) Error() string {
+	return fmt.Sprintf("USCIS account is locked: %q (%v)", e.Message, e.Err)
+}
+
+func (e *ErrAccountLocked) Unwrap() error {
+	return e.Err
+}
+
+// ErrWAFBlocked is returned when AWS WAF in front of myUSCIS served a block or challenge
+// page instead of the sign-in, usually because of the IP address or the request rate
+// The credentials were never checked
+type ErrWAFBlocked struct {
+	URL    string
+	Detail string // what gave the page away
+	Err    error  // the login error the page explains
+}
+
+func (e *ErrWAFBlocked) Error() string {
+	return fmt.Sprintf("blocked by the USCIS firewall at %s: %s (%v)", e.URL, e.Detail, e.Err)
+}
+
+func (e *ErrWAFBlocked) Unwrap() error {
+	return e.Err
+}
+
+// ErrBadCredentials is returned when USCIS rejected the username or password; retrying
+// can't help and only brings the account closer to a lock
+type ErrBadCredentials struct {
+	Message string // what the page said
+	Err     error  // the login error the page explains
+}
+
+func (e *ErrBadCredentials) Error() string {
+	return fmt.Sprintf("USCIS rejected the username or password: %q (%v)", e.Message, e.Err)
+}
+
+func (e *ErrBadCredentials) Unwrap() error {
+	return e.Err
+}
+
+// IsBadCredentials reports whether err is a login USCIS rejected for its credentials
+func IsBadCredentials(err error) bool {
+	var bad *ErrBadCredentials
+	return errors.As(err, &bad)
+}
+
+// IsAccountLocked reports whether err is a login refused because the account is locked
+func IsAccountLocked(err error) bool {
+	var locked *ErrAccountLocked
+	return errors.As(err, &locked)
+}
+
+// IsLoginBlocked reports whether err is a login refused before the credentials could
+// help: a locked account (ErrAccountLocked) or a firewall block (ErrWAFBlocked)
+func IsLoginBlocked(err error) bool {
+	var locked *ErrAccountLocked
+	var blocked *ErrWAFBlocked
+	return errors.As(err, &locked) || errors.As(err, &blocked)
+}
+
+// lockoutMarkers are phrases of the myUSCIS pages refusing a locked account (lowercase)
+var lockoutMarkers = []string{
+	"account is locked",
+	"account has been locked",
+	"account is temporarily locked",
+	"account has been disabled",
+	"too many attempts",
+	"too many failed",
+	"too many unsuccessful",
+	"too many sign-in attempts",
+	"exceeded the maximum number",
+}
+
+// badCredentialMarkers are phrases of the sign-in page rejecting the username or
+// password (lowercase)
+var badCredentialMarkers = []string{
+	"incorrect email or password",
+	"incorrect email address or password",
+	"invalid email or password",
+	"invalid email address or password",
+	"email or password is incorrect",
+	"email address or password is incorrect",
+	"email or password you entered is incorrect",
+	"email address or password you entered is incorrect",
+	"incorrect username or password",
+	"invalid username or password",
+}
+
+// loginWAFMarkers are phrases of the block and challenge pages AWS WAF and CloudFront
+// serve instead of the sign-in (lowercase)
+var loginWAFMarkers = []string{
+	"you don't have permission to access",
+	"request blocked",
+	"request rejected",
+	"the requested url was rejected",
+	"the request could not be satisfied",
+	"verify that you're a human",
+	"verify you are human",
+	"confirm you are human",
+	"human verification",
+}
+
+// maxChallengeText is the most visible text an AWS WAF challenge interstitial has
+const maxChallengeText = 300
+
+// classifyLoginPage tells a locked account, rejected credentials or a firewall block
+// from other login failures by the page the browser was left on; it returns nil for anything else
+func classifyLoginPage(url, text, html string, loginErr error) error {
+	lowerText := strings.ToLower(text)
+	for _, marker := range lockoutMarkers {
+		if i := strings.Index(lowerText, marker); i >= 0 {
+			return &ErrAccountLocked{Message: lineAround(text, i), Err: loginErr}
+		}
+	}
+	for _, marker := range badCredentialMarkers {
+		if i := strings.Index(lowerText, marker); i >= 0 {
+			return &ErrBadCredentials{Message: lineAround(text, i), Err: loginErr}
+		}
+	}
+	for _, marker := range loginWAFMarkers {
+		if strings.Contains(lowerText, marker) {
+			return &ErrWAFBlocked{URL: url, Detail: fmt.Sprintf("block page (%q)", marker), Err: loginErr}
+		}
+	}
+	// The challenge runs a script on an otherwise empty page, and never shows the form
+	lowerHTML := strings.ToLower(html)
+	if strings.Contains(lowerHTML, "awswaf") && !strings.Contains(lowerHTML, "email-address") &&
+		len(strings.TrimSpace(text)) < maxChallengeText {
+		return &ErrWAFBlocked{URL: url, Detail: "AWS WAF challenge that didn't complete", Err: loginErr}
+	}
+	return nil
+}
+
+// lineAround returns the line of text around index i, trimmed to a readable length
+func lineAround(text string, i int) string {
+	i = min(i, len(text))
+	start := strings.LastIndex(text[:i], "\n") + 1
+	end := len(text)
+	if n := strings.Index(text[i:], "\n"); n >= 0 {
+		end = i + n
+	}
+	line := strings.TrimSpace(text[start:end])
+	if len(line) > 200 {
+		line = line[:200] + "..."
+	}
+	return line
+}
+
+// classifyLoginFailure replaces a login error with ErrAccountLocked, ErrBadCredentials
+// or ErrWAFBlocked when the page the browser is on says so; other errors are returned as they are
+func (bc *BrowserClient) classifyLoginFailure(loginErr error) error {
+	if bc.parent.Err() != nil {
+		// Shutting down and the page is being torn down
+		return loginErr
+	}
+	ctx, cancel := context.WithTimeout(bc.ctx, captureTimeout)
+	defer cancel()
+
+	var url, text, html string
+	if err := chromedp.Run(ctx,
+		chromedp.Location(&url),
+		chromedp.Evaluate(`document.title + "\n" + (document.body ? document.body.innerText : "")`, &text),
+		chromedp.OuterHTML("html", &html, chromedp.ByQuery),
+	); err != nil {
+		log.Printf("Warning: Failed to read the page of the failed login: %v", err)
+		return loginErr
+	}
+	classified := classifyLoginPage(url, text, html, loginErr)
+	if classified == nil {
+		return loginErr
+	}
+	log.Printf("Login refused: %v", classified)
+	return classified
+}
@@ -0,0 +1,125 @@
+package uscis
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// LoginCapture is what the browser showed when a login failed
+type LoginCapture struct {
+	Time       time.Time
+	URL        string
+	Error      string
+	Screenshot []byte // full-page JPEG, nil if it couldn't be taken
+	HTML       string // the page's DOM, "" if it couldn't be read
+}
+
+// CaptureStore keeps login failure captures and returns where it saved them
+type CaptureStore interface {
+	SaveCapture(capture *LoginCapture) ([]string, error)
+}
+
+// ErrLoginFailed is a failed browser login with the captures saved of the page
+type ErrLoginFailed struct {
+	Err      error
+	Captures []string      // where the store saved them
+	Capture  *LoginCapture // what was saved, for attaching it to an alert
+}
+
+func (e *ErrLoginFailed) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ErrLoginFailed) Unwrap() error {
+	return e.Err
+}
+
+// LoginCaptures returns the capture files saved for the login failure behind err, if any
+func LoginCaptures(err error) []string {
+	var authErr *ErrAuthenticationFailed
+	if errors.As(err, &authErr) && len(authErr.Captures) > 0 {
+		return authErr.Captures
+	}
+	var loginErr *ErrLoginFailed
+	if errors.As(err, &loginErr) {
+		return loginErr.Captures
+	}
+	return nil
+}
+
+// SavedLoginCapture returns the capture saved for the login failure behind err, or nil
+func SavedLoginCapture(err error) *LoginCapture {
+	var loginErr *ErrLoginFailed
+	if errors.As(err, &loginErr) {
+		return loginErr.Capture
+	}
+	return nil
+}
+
+// captureTimeout bounds taking the screenshot and DOM of a failed login
+const captureTimeout = 15 * time.Second
+
+var captures struct {
+	mu    sync.Mutex
+	store CaptureStore
+}
+
+// SetCaptureStore makes browser clients capture the page when a login fails
+// A nil store turns captures off
+func SetCaptureStore(store CaptureStore) {
+	captures.mu.Lock()
+	defer captures.mu.Unlock()
+	captures.store = store
+}
+
+// captureLoginFailure saves a screenshot and the DOM of the page a login failed on
+// It returns the capture and where it was saved, or nil without a capture store
+func (bc *BrowserClient) captureLoginFailure(loginErr error) (*LoginCapture, []string) {
+	captures.mu.Lock()
+	store := captures.store
+	captures.mu.Unlock()
+	if store == nil || bc.parent.Err() != nil {
+		// Nothing to keep, or shutting down and the page is being torn down
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(bc.ctx, captureTimeout)
+	defer cancel()
+
+	capture := &LoginCapture{Time: time.Now(), Error: loginErr.Error()}
+	// Each part separately: a page that hangs on one may still give the others
+	if err := chromedp.Run(ctx, chromedp.Location(&capture.URL)); err != nil {
+		log.Printf("Warning: Failed to read the URL of the failed login: %v", err)
+	}
+	if err := chromedp.Run(ctx, chromedp.FullScreenshot(&capture.Screenshot, 80)); err != nil {
+		log.Printf("Warning: Failed to take a screenshot of the failed login: %v", err)
+	}
+	if err := chromedp.Run(ctx, chromedp.OuterHTML("html", &capture.HTML, chromedp.ByQuery)); err != nil {
+		log.Printf("Warning: Failed to read the page of the failed login: %v", err)
+	}
+	if capture.Screenshot == nil && capture.HTML == "" {
+		return nil, nil
+	}
+
+	files, err := store.SaveCapture(capture)
+	if err != nil {
+		log.Printf("Warning: Failed to save the login failure capture: %v", err)
+		return nil, nil
+	}
+	log.Printf("Saved a capture of the failed login page (%s): %v", capture.URL, files)
+	return capture, files
+}
+
+// withCaptures attaches the captures of a failed login to its error
+func (bc *BrowserClient) withCaptures(loginErr error) error {
+	capture, files := bc.captureLoginFailure(loginErr)
+	if len(files) == 0 {
+		return loginErr
+	}
+	return &ErrLoginFailed{Err: loginErr, Captures: files, Capture: capture}
+}
@@ -0,0 +1,136 @@
+package uscis
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LoginQueueState describes the login queue for logs and health reporting
+// Accounts are named by their redacted usernames (redactUsername)
+type LoginQueueState struct {
+	Waiting     []string  `json:"waiting"`
+	Active      string    `json:"active,omitempty"`
+	LastLogin   time.Time `json:"last_login,omitempty"`
+	NextAllowed time.Time `json:"next_allowed,omitempty"`
+	Spacing     string    `json:"spacing"`
+}
+
+// loginQueue serializes browser logins across every BrowserClient in the process
+// Logins are spaced apart (plus random jitter) so that several sessions needing
+// a (re)login at the same time - e.g. right after a deploy - don't hit USCIS in a burst
+type loginQueue struct {
+	turn sync.Mutex // held for the duration of a login
+
+	mu        sync.Mutex // guards the fields below
+	spacing   time.Duration
+	jitter    time.Duration
+	lastLogin time.Time
+	waiting   []string
+	active    string
+}
+
+var logins = &loginQueue{}
+
+// SetLoginSpacing configures the minimum gap and random jitter between logins
+func SetLoginSpacing(spacing, jitter time.Duration) {
+	logins.mu.Lock()
+	defer logins.mu.Unlock()
+	logins.spacing = spacing
+	logins.jitter = jitter
+}
+
+// CurrentLoginQueueState returns a snapshot of the login queue
+func CurrentLoginQueueState() LoginQueueState {
+	logins.mu.Lock()
+	defer logins.mu.Unlock()
+
+	state := LoginQueueState{
+		Waiting:   append([]string{}, logins.waiting...),
+		Active:    logins.active,
+		LastLogin: logins.lastLogin,
+		Spacing:   logins.spacing.String(),
+	}
+	if !logins.lastLogin.IsZero() {
+		state.NextAllowed = logins.lastLogin.Add(logins.spacing)
+	}
+	return state
+}
+
+// acquire waits for this login's turn and for the spacing since the previous login
+// The returned function must be called when the login attempt finishes. It fails
+// without a turn when ctx is done during the spacing wait
+func (q *loginQueue) acquire(ctx context.Context, username string) (func(), error) {
+	// The queue shows up in logs, /status and support bundles
+	account := redactUsername(username)
+	q.mu.Lock()
+	q.waiting = append(q.waiting, account)
+	if len(q.waiting) > 1 || q.active != "" {
+		log.Printf("Login queue: %s waiting (%d queued, active: %q)", account, len(q.waiting), q.active)
+	}
+	q.mu.Unlock()
+
+	q.turn.Lock()
+
+	q.mu.Lock()
+	q.removeWaitingLocked(account)
+	q.active = account
+	wait := time.Duration(0)
+	if !q.lastLogin.IsZero() {
+		wait = time.Until(q.lastLogin.Add(q.spacing))
+		if q.jitter > 0 {
+			wait += time.Duration(rand.Int63n(int64(q.jitter)))
+		}
+	}
+	q.mu.Unlock()
+
+	if wait > 0 {
+		log.Printf("Login queue: waiting %v before logging in as %s", wait.Round(time.Second), account)
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			q.mu.Lock()
+			q.active = ""
+			q.mu.Unlock()
+			q.turn.Unlock()
+			return nil, fmt.Errorf("login as %s aborted: %w", account, ctx.Err())
+		}
+	}
+
+	return func() {
+		q.mu.Lock()
+		q.lastLogin = time.Now()
+		q.active = ""
+		q.mu.Unlock()
+		q.turn.Unlock()
+	}, nil
+}
+
+// removeWaitingLocked drops the first queue entry for account
+// Caller must hold q.mu
+func (q *loginQueue) removeWaitingLocked(account string) {
+	for i, name := range q.waiting {
+		if name == account {
+			q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+			return
+		}
+	}
+}
+
+// redactUsername keeps enough of a USCIS username to tell accounts apart:
+// "jane.doe@gmail.com" becomes "ja***@gmail.com"
+func redactUsername(username string) string {
+	local, domain, hasDomain := strings.Cut(username, "@")
+	runes := []rune(local)
+	redacted := string(runes[:min(2, len(runes)/2)]) + "***"
+	if hasDomain {
+		redacted += "@" + domain
+	}
+	return redacted
+}
@@ -0,0 +1,156 @@
+package uscis
+
+import (
+	"strings"
+	"time"
+)
+
+// Milestone is a status the case reached and when it was first observed
+type Milestone struct {
+	Status string
+	Date   time.Time
+}
+
+// Observation is a case payload as seen at a point in time
+type Observation struct {
+	Time   time.Time
+	Status map[string]interface{}
+}
+
+// approvalPhrases mark terminal approval statuses (lowercase)
+var approvalPhrases = []string{
+	"case was approved",
+	"case approval was",
+	"new card is being produced",
+	"card is being produced",
+	"card was mailed",
+	"card was delivered",
+	"card was picked up",
+	"oath ceremony",
+	"approved",
+}
+
+// denialPhrases take precedence over approval phrases ("approval was revoked")
+var denialPhrases = []string{"denied", "rejected", "revoked", "terminated", "not approved"}
+
+// criticalPhrases mark statuses that ask the applicant to act or show up (lowercase)
+var criticalPhrases = []string{
+	"request for evidence",
+	"request for additional evidence",
+	"intent to deny",
+	"intent to revoke",
+	"interview was scheduled",
+	"interview is scheduled",
+	"interview was rescheduled",
+}
+
+// filedDateKeys are the payload keys that may carry the filing date
+var filedDateKeys = []string{"submissionDate", "receiptDate", "filedDate", "receivedDate", "submissionTimestamp"}
+
+// dateLayouts are the timestamp formats seen in USCIS payloads
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05.000Z",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+	"01/02/2006",
+	"January 2, 2006",
+}
+
+// BuildMilestones collapses chronological observations into status transitions
+// Consecutive observations with the same status line produce a single milestone
+func BuildMilestones(observations []Observation) []Milestone {
+	var milestones []Milestone
+	last := ""
+	for _, obs := range observations {
+		summary := StatusSummary(obs.Status)
+		if summary == "" || summary == last {
+			continue
+		}
+		milestones = append(milestones, Milestone{Status: summary, Date: obs.Time})
+		last = summary
+	}
+	return milestones
+}
+
+// IsApproved reports whether a status line represents a terminal approval
+func IsApproved(summary string) bool {
+	lower := strings.ToLower(summary)
+	for _, phrase := range denialPhrases {
+		if strings.Contains(lower, phrase) {
+			return false
+		}
+	}
+	for _, phrase := range approvalPhrases {
+		if strings.Contains(lower, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsCritical reports whether a status line needs the applicant's attention, e.g. an RFE
+// with a response deadline or a scheduled interview
+func IsCritical(summary string) bool {
+	lower := strings.ToLower(summary)
+	for _, phrase := range criticalPhrases {
+		if strings.Contains(lower, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+// FiledDate extracts the date the case was filed, if the payload includes it
+func FiledDate(status map[string]interface{}) (time.Time, bool) {
+	data := caseData(status)
+	for _, key := range filedDateKeys {
+		if value, ok := data[key].(string); ok && value != "" {
+			if t, ok := ParseDate(value); ok {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// ParseDate parses a date in any of the formats USCIS is known to use
+func ParseDate(value string) (time.Time, bool) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Stages is the coarse progression most USCIS cases follow
+var Stages = []string{"Received", "Biometrics", "Interview", "Decision", "Document Issued"}
+
+// stageKeywords map lowercase status phrases to an index into Stages
+// Later stages are checked first so the most advanced match wins
+var stageKeywords = []struct {
+	stage    int
+	keywords []string
+}{
+	{4, []string{"card was mailed", "card was delivered", "card was picked up", "card is being produced", "document was mailed", "oath ceremony"}},
+	{3, []string{"approved", "denied", "decision", "rejected", "terminated", "withdrawal"}},
+	{2, []string{"interview"}},
+	{1, []string{"fingerprint", "biometric"}},
+	{0, []string{"received", "accepted", "transferred", "fee"}},
+}
+
+// Stage maps a status line to its position in Stages
+// Returns -1 if the status cannot be placed
+func Stage(summary string) int {
+	lower := strings.ToLower(summary)
+	for _, sk := range stageKeywords {
+		for _, keyword := range sk.keywords {
+			if strings.Contains(lower, keyword) {
+				return sk.stage
+			}
+		}
+	}
+	return -1
+}
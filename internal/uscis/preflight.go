@@ -0,0 +1,157 @@
+package uscis
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Connectivity problems found by CheckConnectivity
+// None of them is an authentication problem: credentials are never sent
+const (
+	ProblemDNS         = "dns"         // the host name does not resolve
+	ProblemConnect     = "connect"     // no route or connection refused, e.g. no egress
+	ProblemTimeout     = "timeout"     // no answer within the timeout
+	ProblemTLS         = "tls"         // TLS handshake or certificate failure, e.g. an intercepting proxy
+	ProblemBlocked     = "blocked"     // a firewall (WAF) rejected the request
+	ProblemUnavailable = "unavailable" // the service answered with a server error
+)
+
+// EndpointCheck is the result of probing one USCIS endpoint
+type EndpointCheck struct {
+	URL        string        `json:"url"`
+	Problem    string        `json:"problem,omitempty"` // empty when reachable
+	Detail     string        `json:"detail,omitempty"`
+	StatusCode int           `json:"status_code,omitempty"`
+	Latency    time.Duration `json:"latency"`
+}
+
+// OK reports whether the endpoint was reachable
+func (c EndpointCheck) OK() bool {
+	return c.Problem == ""
+}
+
+// PreflightURLs returns the endpoints the tracker needs for the given sources
+func PreflightURLs(myUSCIS, public bool) []string {
+	var urls []string
+	if myUSCIS {
+		urls = append(urls, loginPageURL, caseAPIURL)
+	}
+	if public {
+		urls = append(urls, publicAuthURL)
+	}
+	return urls
+}
+
+// wafMarkers are body fragments of the block pages served in front of USCIS
+var wafMarkers = []string{"access denied", "request rejected", "the requested url was rejected", "captcha", "incapsula", "errors.edgesuite.net"}
+
+// CheckConnectivity resolves and requests each endpoint without credentials
+// Any HTTP answer that isn't a block page or a server error (including 401 and
+// 404) counts as reachable: the goal is telling network problems from auth problems
+func CheckConnectivity(urls []string, timeout time.Duration) []EndpointCheck {
+	client := &http.Client{
+		Timeout: timeout,
+		// The login page redirects through the identity provider; the first answer is enough
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+
+	checks := make([]EndpointCheck, 0, len(urls))
+	for _, rawURL := range urls {
+		checks = append(checks, checkEndpoint(client, rawURL, timeout))
+	}
+	return checks
+}
+
+// checkEndpoint probes a single endpoint
+func checkEndpoint(client *http.Client, rawURL string, timeout time.Duration) (check EndpointCheck) {
+	check.URL = rawURL
+	start := time.Now()
+	defer func() { check.Latency = time.Since(start) }()
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		check.Problem, check.Detail = ProblemConnect, err.Error()
+		return check
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if _, err := net.DefaultResolver.LookupHost(ctx, u.Hostname()); err != nil {
+		check.Problem, check.Detail = ProblemDNS, err.Error()
+		return check
+	}
+
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		check.Problem, check.Detail = ProblemConnect, err.Error()
+		return check
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/125.0.0.0 Safari/537.36")
+	resp, err := client.Do(req)
+	if err != nil {
+		check.Problem, check.Detail = classifyNetError(err), err.Error()
+		return check
+	}
+	defer resp.Body.Close()
+
+	check.StatusCode = resp.StatusCode
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 16<<10))
+	lower := strings.ToLower(string(body))
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		check.Problem, check.Detail = ProblemBlocked, "rate limited (HTTP 429)"
+	case resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusNotAcceptable:
+		check.Problem, check.Detail = ProblemBlocked, fmt.Sprintf("HTTP %d from %s", resp.StatusCode, serverName(resp))
+	case resp.StatusCode >= 500:
+		check.Problem, check.Detail = ProblemUnavailable, fmt.Sprintf("HTTP %d", resp.StatusCode)
+	default:
+		for _, marker := range wafMarkers {
+			if strings.Contains(lower, marker) {
+				check.Problem, check.Detail = ProblemBlocked, fmt.Sprintf("block page (%q) with HTTP %d", marker, resp.StatusCode)
+				break
+			}
+		}
+	}
+	return check
+}
+
+// classifyNetError maps a request error to a connectivity problem
+func classifyNetError(err error) string {
+	var dnsErr *net.DNSError
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var recordErr tls.RecordHeaderError
+	var netErr net.Error
+
+	switch {
+	case errors.As(err, &dnsErr):
+		return ProblemDNS
+	case errors.As(err, &certErr), errors.As(err, &unknownAuthority), errors.As(err, &hostnameErr), errors.As(err, &recordErr):
+		return ProblemTLS
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return ProblemTimeout
+	case strings.Contains(err.Error(), "tls:"):
+		return ProblemTLS
+	default:
+		return ProblemConnect
+	}
+}
+
+// serverName describes who answered, which tells a CDN/WAF block from the application
+func serverName(resp *http.Response) string {
+	if server := resp.Header.Get("Server"); server != "" {
+		return server
+	}
+	return "server"
+}
@@ -0,0 +1,206 @@
+package uscis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	publicAuthURL   = "https://egov.uscis.gov/csol-api/ui-auth"
+	publicStatusURL = "https://egov.uscis.gov/csol-api/case-statuses"
+)
+
+// PublicClient fetches case status from the public Case Status Online service
+// It needs no account, but only reports the headline status, description and form
+type PublicClient struct {
+	httpClient *http.Client
+	ctx        context.Context // cancelled on shutdown; aborts in-flight requests
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+// NewPublicClient creates a client for the public case status service
+func NewPublicClient() *PublicClient {
+	return &PublicClient{httpClient: &http.Client{Timeout: 30 * time.Second}, ctx: context.Background()}
+}
+
+// SetFetchTimeout bounds how long a single case request may take
+func (c *PublicClient) SetFetchTimeout(timeout time.Duration) {
+	c.httpClient.Timeout = timeout
+}
+
+// SetContext makes requests stop when ctx is done, e.g. on shutdown
+func (c *PublicClient) SetContext(ctx context.Context) {
+	c.ctx = ctx
+}
+
+// publicAuthResponse is the anonymous token the status page fetches before a lookup
+type publicAuthResponse struct {
+	JwtResponse struct {
+		AccessToken string `json:"accessToken"`
+		ExpiresIn   int    `json:"expiresIn"` // seconds
+	} `json:"JwtResponse"`
+}
+
+// publicStatusResponse is the case-statuses payload
+type publicStatusResponse struct {
+	CaseStatusResponse struct {
+		ReceiptNumber string `json:"receiptNumber"`
+		IsValid       bool   `json:"isValid"`
+		DetailsEng    struct {
+			FormNum        string `json:"formNum"`
+			FormTitle      string `json:"formTitle"`
+			ActionCodeText string `json:"actionCodeText"`
+			ActionCodeDesc string `json:"actionCodeDesc"`
+		} `json:"detailsEng"`
+	} `json:"CaseStatusResponse"`
+}
+
+// FetchCaseStatus fetches the current status of a case
+// The payload uses the same field names as the account API where they exist,
+// so status summaries and change detection work unchanged
+func (c *PublicClient) FetchCaseStatus(caseID string) (map[string]interface{}, error) {
+	if _, err := ValidateReceiptNumber(caseID); err != nil {
+		return nil, err
+	}
+	body, err := c.lookup(caseID)
+	var authErr *ErrAuthenticationFailed
+	if errors.As(err, &authErr) {
+		// The anonymous token expired early; get a new one and retry once
+		c.mu.Lock()
+		c.token = ""
+		c.mu.Unlock()
+		body, err = c.lookup(caseID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var result publicStatusResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, &ParseError{Body: string(body), Err: err}
+	}
+	status := result.CaseStatusResponse
+	if !status.IsValid {
+		return nil, fmt.Errorf("receipt number %s was not found by the public case status service: %w", caseID, ErrCaseNotFound)
+	}
+
+	return map[string]interface{}{
+		"data": map[string]interface{}{
+			"receiptNumber":  status.ReceiptNumber,
+			"formNum":        status.DetailsEng.FormNum,
+			"formTitle":      status.DetailsEng.FormTitle,
+			"actionCodeText": status.DetailsEng.ActionCodeText,
+			"actionCodeDesc": status.DetailsEng.ActionCodeDesc,
+		},
+		"source": "public",
+	}, nil
+}
+
+// FetchCase fetches the current status of a case as a typed CaseStatus
+func (c *PublicClient) FetchCase(caseID string) (*CaseStatus, error) {
+	payload, err := c.FetchCaseStatus(caseID)
+	if err != nil {
+		return nil, err
+	}
+	return NewCaseStatus(payload), nil
+}
+
+// lookup performs one case-statuses request and returns the raw body
+func (c *PublicClient) lookup(caseID string) ([]byte, error) {
+	token, err := c.accessToken()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(c.ctx, "GET", publicStatusURL+"/"+caseID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+
+	body, status, err := c.do(req, caseID)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusUnauthorized || status == http.StatusForbidden {
+		return nil, &ErrAuthenticationFailed{StatusCode: status}
+	}
+	if status != http.StatusOK {
+		return nil, &errUnexpectedStatus{StatusCode: status, Body: string(body)}
+	}
+	return body, nil
+}
+
+// accessToken returns the cached anonymous token, fetching a new one when it has expired
+func (c *PublicClient) accessToken() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && time.Now().Before(c.tokenExpiry) {
+		return c.token, nil
+	}
+
+	req, err := http.NewRequestWithContext(c.ctx, "GET", publicAuthURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	body, status, err := c.do(req, "")
+	if err != nil {
+		return "", err
+	}
+	if status == http.StatusTooManyRequests {
+		return "", fmt.Errorf("failed to get public status token: %w", ErrRateLimited)
+	}
+	if status != http.StatusOK {
+		return "", fmt.Errorf("failed to get public status token: status %d", status)
+	}
+
+	var auth publicAuthResponse
+	if err := json.Unmarshal(body, &auth); err != nil || auth.JwtResponse.AccessToken == "" {
+		return "", fmt.Errorf("failed to get public status token: unexpected response")
+	}
+
+	lifetime := time.Duration(auth.JwtResponse.ExpiresIn) * time.Second
+	if lifetime <= 0 {
+		lifetime = 5 * time.Minute
+	}
+	c.token = auth.JwtResponse.AccessToken
+	// Renew a little early so a token never expires mid-cycle
+	c.tokenExpiry = time.Now().Add(lifetime * 9 / 10)
+	return c.token, nil
+}
+
+// do sends a request with browser-like headers and reads the whole response
+func (c *PublicClient) do(req *http.Request, caseID string) ([]byte, int, error) {
+	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36")
+	req.Header.Set("Referer", "https://egov.uscis.gov/")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if urlErr, ok := err.(*url.Error); ok && urlErr.Timeout() && caseID != "" {
+			return nil, 0, &ErrFetchTimeout{CaseID: caseID, Timeout: c.httpClient.Timeout}
+		}
+		return nil, 0, fmt.Errorf("failed to fetch case status: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read response body: %w", err)
+	}
+	return body, resp.StatusCode, nil
+}
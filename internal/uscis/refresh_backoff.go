@@ -0,0 +1,59 @@
+package uscis
+
+import (
+	"fmt"
+	"time"
+)
+
+// refreshDelays are the waits before another automatic session refresh after 1, 2 and
+// 3+ failed ones in a row, like the daemon's retries of a failed startup login
+var refreshDelays = []time.Duration{30 * time.Minute, 2 * time.Hour, 6 * time.Hour}
+
+// ErrRefreshBackoff is returned when an expired session wasn't refreshed because the last
+// automatic refresh failed recently; another is tried after Until
+type ErrRefreshBackoff struct {
+	Until time.Time
+	Err   error // the failed refresh
+}
+
+func (e *ErrRefreshBackoff) Error() string {
+	return fmt.Sprintf("session expired; the last refresh failed, the next one is tried after %s: %v", e.Until.Format(time.RFC3339), e.Err)
+}
+
+func (e *ErrRefreshBackoff) Unwrap() error {
+	return e.Err
+}
+
+// refreshBackoff spaces out the automatic refreshes of a session after failed ones, so
+// every fetch of a poll cycle doesn't log in again
+// A refresh refused for a locked account holds them until one is asked for explicitly
+// (RefreshSession), since every further login can extend the lock
+type refreshBackoff struct {
+	failures int
+	until    time.Time
+	err      error
+}
+
+// check returns why an automatic refresh can't run now, or nil if it can
+func (b *refreshBackoff) check(now time.Time) error {
+	switch {
+	case b.err == nil:
+		return nil
+	case IsAccountLocked(b.err):
+		return b.err
+	case now.Before(b.until):
+		return &ErrRefreshBackoff{Until: b.until, Err: b.err}
+	}
+	return nil
+}
+
+// record notes the outcome of a refresh
+func (b *refreshBackoff) record(err error, now time.Time) {
+	if err == nil {
+		*b = refreshBackoff{}
+		return
+	}
+	b.failures++
+	b.until = now.Add(refreshDelays[min(b.failures, len(refreshDelays))-1])
+	b.err = err
+}
@@ -0,0 +1,47 @@
+package uscis
+
+import "fmt"
+
+// statusTitleKeys are the payload keys that may carry the headline status text
+var statusTitleKeys = []string{"statusTitle", "actionCodeText", "currentStatus", "caseStatus", "status"}
+
+// caseData returns the case object from a payload, unwrapping the "data" envelope if present
+func caseData(status map[string]interface{}) map[string]interface{} {
+	if data, ok := status["data"].(map[string]interface{}); ok {
+		return data
+	}
+	return status
+}
+
+// StatusSummary extracts a short human-readable status line from a case payload
+// Returns an empty string if no recognizable status field is present
+func StatusSummary(status map[string]interface{}) string {
+	data := caseData(status)
+	for _, key := range statusTitleKeys {
+		switch v := data[key].(type) {
+		case string:
+			if v != "" {
+				return v
+			}
+		case map[string]interface{}:
+			// Some payloads nest the status under an object with a title/text field
+			for _, inner := range []string{"title", "text", "en"} {
+				if text, ok := v[inner].(string); ok && text != "" {
+					return text
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// FormType extracts the form type (e.g. "I-485") from a case payload
+func FormType(status map[string]interface{}) string {
+	data := caseData(status)
+	for _, key := range formKeys {
+		if v, ok := data[key]; ok && v != nil {
+			return fmt.Sprint(v)
+		}
+	}
+	return ""
+}
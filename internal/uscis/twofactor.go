@@ -0,0 +1,229 @@
+package uscis
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/phhowardchen/case-tracker/internal/logging"
+)
+
+// CodeProvider supplies the 2FA verification code of a browser login
+type CodeProvider interface {
+	// Code returns the code for a login started at since, giving up when ctx is done
+	Code(ctx context.Context, since time.Time) (string, error)
+	// String names the provider in logs
+	String() string
+}
+
+// emailCodes reads the code from the email USCIS sends
+type emailCodes struct {
+	fetcher EmailFetcher
+	sender  string
+	timeout time.Duration
+}
+
+// NewEmailCodes returns a provider that waits up to timeout for the 2FA email from sender
+func NewEmailCodes(fetcher EmailFetcher, sender string, timeout time.Duration) CodeProvider {
+	return &emailCodes{fetcher: fetcher, sender: sender, timeout: timeout}
+}
+
+func (e *emailCodes) Code(ctx context.Context, since time.Time) (string, error) {
+	log.Printf("  Email sender: %s", e.sender)
+	log.Printf("  Timeout: %v", e.timeout)
+	log.Printf("Waiting for 2FA email (this may take up to %v)...", e.timeout)
+	return e.fetcher.FetchLatest2FACode(ctx, e.sender, since, e.timeout)
+}
+
+func (e *emailCodes) String() string {
+	return "email"
+}
+
+// TOTP settings of authenticator apps (RFC 6238)
+const (
+	totpPeriod = 30 * time.Second
+	totpDigits = 6
+)
+
+// totpMinRemaining is how long a code must still be valid when it is typed in; with less
+// left the provider waits for the next one rather than have it expire on the way
+const totpMinRemaining = 5 * time.Second
+
+// TOTP computes codes from the shared secret of an authenticator app enrollment
+type TOTP struct {
+	key []byte
+	now func() time.Time
+}
+
+// NewTOTP creates a provider from a base32 secret, as shown when setting up an
+// authenticator app; spaces and case don't matter
+func NewTOTP(secret string) (*TOTP, error) {
+	key, err := DecodeTOTPSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+	logging.AddSecret(secret)
+	return &TOTP{key: key, now: time.Now}, nil
+}
+
+// DecodeTOTPSecret decodes a base32 authenticator secret
+func DecodeTOTPSecret(secret string) ([]byte, error) {
+	cleaned := strings.ToUpper(strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(secret)))
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(cleaned, "="))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode TOTP secret (expected base32): %w", err)
+	}
+	if len(key) == 0 {
+		return nil, fmt.Errorf("TOTP secret is empty")
+	}
+	return key, nil
+}
+
+func (t *TOTP) Code(ctx context.Context, since time.Time) (string, error) {
+	now := t.now()
+	if left := totpPeriod - time.Duration(now.UnixNano())%totpPeriod; left < totpMinRemaining {
+		log.Printf("Current TOTP code expires in %v, waiting for the next one...", left.Round(time.Second))
+		select {
+		case <-time.After(left):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+		now = now.Add(left)
+	}
+	code := t.codeAt(now)
+	logging.AddSecret(code)
+	return code, nil
+}
+
+func (t *TOTP) String() string {
+	return "TOTP"
+}
+
+// codeAt computes the HOTP value (RFC 4226) of the time step containing at
+func (t *TOTP) codeAt(at time.Time) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(at.Unix()/int64(totpPeriod/time.Second)))
+	mac := hmac.New(sha1.New, t.key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%mod)
+}
+
+// smsCodePattern finds the 6-digit code in the text USCIS sends
+var smsCodePattern = regexp.MustCompile(`\b(\d{6})\b`)
+
+// maxSMSMessages bounds the texts kept while no login is waiting for them
+const maxSMSMessages = 10
+
+// smsMessage is a text with a code, as received
+type smsMessage struct {
+	code string
+	at   time.Time
+}
+
+// SMSCodes waits for the code USCIS texts to the account's phone, delivered to it by
+// an inbound SMS webhook
+type SMSCodes struct {
+	timeout time.Duration
+
+	mu       sync.Mutex
+	messages []smsMessage  // oldest first
+	arrived  chan struct{} // closed and replaced by every delivery
+}
+
+// NewSMSCodes returns a provider that waits up to timeout for a text with the code
+func NewSMSCodes(timeout time.Duration) *SMSCodes {
+	return &SMSCodes{timeout: timeout, arrived: make(chan struct{})}
+}
+
+// Deliver passes on a received text and reports whether it held a code
+func (s *SMSCodes) Deliver(body string, at time.Time) bool {
+	match := smsCodePattern.FindStringSubmatch(body)
+	if match == nil {
+		return false
+	}
+	logging.AddSecret(match[1])
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, smsMessage{code: match[1], at: at})
+	if len(s.messages) > maxSMSMessages {
+		s.messages = s.messages[len(s.messages)-maxSMSMessages:]
+	}
+	close(s.arrived)
+	s.arrived = make(chan struct{})
+	return true
+}
+
+// Code returns the newest code texted since the login started, waiting for one
+// if none arrived yet. A code is only used once
+func (s *SMSCodes) Code(ctx context.Context, since time.Time) (string, error) {
+	log.Printf("Waiting for 2FA text (this may take up to %v)...", s.timeout)
+	timeout := time.NewTimer(s.timeout)
+	defer timeout.Stop()
+	for {
+		code, arrived := s.take(since)
+		if code != "" {
+			return code, nil
+		}
+		select {
+		case <-arrived:
+		case <-timeout.C:
+			return "", fmt.Errorf("timeout: no 2FA text received within %v", s.timeout)
+		case <-ctx.Done():
+			return "", fmt.Errorf("stopped waiting for 2FA text: %w", ctx.Err())
+		}
+	}
+}
+
+// take removes and returns the newest code received since, or the channel to wait on
+func (s *SMSCodes) take(since time.Time) (string, <-chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.messages) - 1; i >= 0; i-- {
+		if msg := s.messages[i]; !msg.at.Before(since) {
+			s.messages = s.messages[i+1:]
+			return msg.code, nil
+		}
+	}
+	return "", s.arrived
+}
+
+func (s *SMSCodes) String() string {
+	return "SMS"
+}
+
+// stdinCodes asks whoever runs the tracker to type the code in
+type stdinCodes struct{}
+
+func (stdinCodes) Code(ctx context.Context, since time.Time) (string, error) {
+	log.Printf("Please check your email for the verification code")
+	fmt.Print("Enter 2FA verification code: ")
+	reader := bufio.NewReader(os.Stdin)
+	code, err := reader.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("failed to read verification code: %w", err)
+	}
+	return strings.TrimSpace(code), nil
+}
+
+func (stdinCodes) String() string {
+	return "stdin"
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 	"time"
@@ -34,7 +35,7 @@ func main() {
 	if err != nil {
 		log.Fatalf("invalid EMAIL_2FA_TIMEOUT: %v", err)
 	}
-	code, err := imapClient.FetchLatest2FACode(email2FASender, timeout)
+	code, err := imapClient.FetchLatest2FACode(context.Background(), email2FASender, time.Now(), timeout)
 	if err != nil {
 		log.Fatalf("Failed to fetch 2FA code from email: %v\n", err)
 	}